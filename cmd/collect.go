@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/parlakisik/k8s_diagnostic/pkg/diagnostic"
+
+	"github.com/spf13/cobra"
+)
+
+// collectCmd represents the collect command
+var collectCmd = &cobra.Command{
+	Use:   "collect",
+	Short: "Gather a support bundle after a failed run",
+	Long: `Gather a support bundle with the artifacts usually requested after a failed run.
+
+The bundle is a tar.gz containing:
+- The most recent JSON reports and log files from test_results/
+- The detected CNI: agent DaemonSet, pods, configuration, status output and recent logs per node
+- CoreDNS Corefile and recent logs
+- Node addresses, pod CIDRs, conditions and versions, and the detected kube-proxy mode
+- Warning events from kube-system, the CNI namespace and the test namespace
+
+An index.json at the root of the bundle lists every file and any collection errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		output, _ := cmd.Flags().GetString("output")
+		reports, _ := cmd.Flags().GetInt("reports")
+		logSince, _ := cmd.Flags().GetDuration("log-since")
+		redact, _ := cmd.Flags().GetBool("redact")
+		redactKey, _ := cmd.Flags().GetString("redact-key")
+
+		if output == "" {
+			output = fmt.Sprintf("test_results/k8s-diagnostic-bundle-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		tester, err := diagnostic.NewTester(kubeconfig, namespace)
+		if err != nil {
+			fmt.Printf("ERROR: Failed to create diagnostic tester: %v\n", err)
+			return
+		}
+
+		var redactor *diagnostic.Redactor
+		if redact {
+			if redactor, err = tester.NewRedactor(ctx, redactKey); err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				return
+			}
+			if len(redactor.Unmasked) > 0 {
+				fmt.Printf("⚠️ Names too short to mask stay in clear text in the bundle: %s\n", strings.Join(redactor.Unmasked, ", "))
+			}
+		}
+
+		fmt.Printf("📦 Collecting support bundle...\n")
+		index, err := tester.CollectSupportBundle(ctx, diagnostic.BundleOptions{
+			OutputPath: output,
+			MaxReports: reports,
+			LogSince:   logSince,
+			Redactor:   redactor,
+		})
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			return
+		}
+
+		fmt.Printf("✅ Support bundle saved: %s\n", output)
+		fmt.Printf("  CNI: %s, kube-proxy mode: %s\n", index.CNI.Name, index.KubeProxy.Mode)
+		fmt.Printf("  Files: %d", len(index.Entries))
+		if index.FailedEntries > 0 {
+			fmt.Printf(" (%d could not be collected, see index.json)", index.FailedEntries)
+		}
+		fmt.Printf("\n")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(collectCmd)
+
+	collectCmd.Flags().StringP("namespace", "n", "diagnostic-test", "test namespace whose events are included")
+	collectCmd.Flags().StringP("output", "o", "", "bundle file to write (default test_results/k8s-diagnostic-bundle-<timestamp>.tar.gz)")
+	collectCmd.Flags().Int("reports", 5, "number of most recent JSON reports and log files to include")
+	collectCmd.Flags().Duration("log-since", 30*time.Minute, "how far back to fetch CNI and CoreDNS pod logs")
+	collectCmd.Flags().Bool("redact", false, "mask node names, namespace names and IP addresses in every file of the bundle")
+	collectCmd.Flags().String("redact-key", "", "key for the redaction tokens (default: derived from the cluster, so tokens match across reports and bundles)")
+}
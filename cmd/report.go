@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/parlakisik/k8s_diagnostic/pkg/diagnostic"
+
+	"github.com/spf13/cobra"
+)
+
+// reportCmd groups the commands that work on JSON reports written by the test command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Validate, merge and describe JSON reports",
+	Long: `Work with the JSON reports written by the test command.
+
+Every report carries a schema_version. Tools reading reports can validate them
+against the schema of this release before parsing, so a layout change shows up
+as a validation error instead of a parser failure. Reports of several runs
+are merged into one document for reliability reviews.`,
+}
+
+// reportValidateCmd checks reports against the schema of this release
+var reportValidateCmd = &cobra.Command{
+	Use:   "validate <file>...",
+	Short: "Check JSON reports against the report schema",
+	Long: fmt.Sprintf(`Check JSON reports against the schema of this release (schema_version %s).
+
+Reports of another schema version are rejected with both versions named. Exits
+with 1 when a report does not match.`, diagnostic.ReportSchemaVersion),
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, file := range args {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				exitCode = exitFailed
+				continue
+			}
+			version, problems := diagnostic.ValidateReport(content)
+			if len(problems) > 0 {
+				fmt.Printf("❌ %s does not match the schema_version %s report schema:\n", file, diagnostic.ReportSchemaVersion)
+				for _, problem := range problems {
+					fmt.Printf("  - %s\n", problem)
+				}
+				exitCode = exitFailed
+				continue
+			}
+			fmt.Printf("✅ %s is a valid schema_version %s report\n", file, version)
+		}
+	},
+}
+
+// reportSchemaCmd prints the schema for consumers to pin or generate parsers from
+var reportSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the report",
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := json.MarshalIndent(diagnostic.ReportSchema(), "", "  ")
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
+		fmt.Println(string(content))
+	},
+}
+
+// reportMergeCmd aggregates the reports of several runs for reliability reviews
+var reportMergeCmd = &cobra.Command{
+	Use:   "merge [cluster=]<file>...",
+	Short: "Merge JSON reports of several runs with per-cluster and per-day breakdowns",
+	Long: `Merge JSON reports, e.g. the per-cluster reports of a fleet run or a week of
+runs on one cluster, into one document with totals, per-cluster and per-day
+pass rates, per-test failure rates (least reliable first) and every failure.
+
+A report counts for the cluster named in its execution_info.cluster (the
+kubeconfig context or --cluster-name of the test run); prefix the file with
+"cluster=" to set it. Days are the UTC dates the runs started. Reports of older
+releases are merged for the fields they share.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		var sources []diagnostic.MergeSource
+		for _, arg := range args {
+			file, cluster := arg, ""
+			if _, err := os.Stat(arg); err != nil {
+				if name, path, ok := strings.Cut(arg, "="); ok {
+					file, cluster = path, name
+				}
+			}
+			report, err := diagnostic.LoadReport(file)
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				exitCode = exitFailed
+				return
+			}
+			sources = append(sources, diagnostic.MergeSource{File: file, Cluster: cluster, Report: report})
+		}
+
+		merged := diagnostic.MergeReports(sources)
+		content, err := json.MarshalIndent(merged, "", "  ")
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
+		if output == "" {
+			fmt.Println(string(content))
+			return
+		}
+		if err := os.WriteFile(output, content, 0644); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
+
+		fmt.Printf("📊 Merged %d runs from %s to %s: %.1f%% of %d tests passed\n", merged.Summary.Runs, merged.FirstRun, merged.LastRun,
+			merged.Summary.PassRatePct, merged.Summary.Tests-merged.Summary.Skipped)
+		fmt.Printf("  %-30s %6s %12s %10s\n", "CLUSTER", "RUNS", "FAILED RUNS", "PASS RATE")
+		for _, cluster := range merged.Clusters {
+			fmt.Printf("  %-30s %6d %12d %9.1f%%\n", cluster.Name, cluster.Runs, cluster.RunsFailed, cluster.PassRatePct)
+		}
+		for _, test := range merged.Tests {
+			if test.Failed > 0 {
+				fmt.Printf("  ❌ %s failed %d of %d runs (%s)\n", test.TestName, test.Failed, test.Runs-test.Skipped, strings.Join(test.FailedClusters, ", "))
+			}
+		}
+		fmt.Printf("✅ Merged report saved: %s\n", output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportValidateCmd)
+	reportCmd.AddCommand(reportSchemaCmd)
+	reportCmd.AddCommand(reportMergeCmd)
+
+	reportMergeCmd.Flags().StringP("output", "o", "", "file to write the merged report to (default: print it)")
+}
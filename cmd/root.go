@@ -10,6 +10,17 @@ import (
 
 var cfgFile string
 
+// Exit codes of a test run
+const (
+	exitPassed   = 0 // every test passed, with or without warnings, or was skipped
+	exitFailed   = 1 // a test failed, or the run could not start
+	exitSkipped  = 2 // no test failed, some were skipped and --fail-on-skip is set
+	exitWarnings = 3 // no test failed, some passed with warnings and --fail-on-warning is set
+)
+
+// exitCode is the exit code of the command that ran, set by the test and report commands
+var exitCode = exitPassed
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "k8s-diagnostic",
@@ -28,6 +39,9 @@ DNS resolution, and other networking aspects within Kubernetes clusters.`,
 		fmt.Println("")
 		fmt.Println("Available commands:")
 		fmt.Println("  test    - Run diagnostic tests")
+		fmt.Println("  collect - Gather a support bundle (reports, logs, CNI and CoreDNS state, events)")
+		fmt.Println("  report  - Validate or merge JSON reports and print the report schema")
+		fmt.Println("  serve   - Collect reports from many clusters and serve a fleet dashboard")
 		fmt.Println("")
 		fmt.Println("Use --help for more information about available commands")
 	},
@@ -39,6 +53,13 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// ExitCode returns the exit code of the command that ran: 0 when every test passed, 1 when a test failed or the run
+// could not start, 2 when tests were only skipped and --fail-on-skip is set, 3 when tests passed with warnings and
+// --fail-on-warning is set
+func ExitCode() int {
+	return exitCode
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/parlakisik/k8s_diagnostic/pkg/diagnostic"
+
+	"github.com/spf13/cobra"
+)
+
+// serveCmd collects the reports of many clusters and serves a fleet dashboard
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Collect reports from many clusters and serve a fleet dashboard",
+	Long: `Run a server that stores the reports test runs of many clusters send to it and
+serves aggregated views of them: per-cluster pass rates (lowest first), failure
+rates per test per cluster and the latest status of every test on every cluster.
+
+Point test runs at it with --report-url http://<host>:8080/api/v1/reports, adding
+?cluster=<name> when the report's cluster name is not the one to show. Reports
+can also be uploaded with any HTTP client:
+
+  curl --data-binary @report.json http://<host>:8080/api/v1/reports?cluster=prod-eu
+
+Endpoints:
+  POST /api/v1/reports    store a report or a --report-url event
+  GET  /api/v1/summary    all runs merged like report merge
+  GET  /api/v1/clusters   per-cluster stats, lowest pass rate first
+  GET  /api/v1/tests      failure rate of every test on every cluster
+  GET  /api/v1/matrix     latest status of every test on every cluster
+  GET  /                  HTML dashboard
+
+GET endpoints take ?days=<n> to only count runs of the last n days. Reports are
+stored as files under --data-dir, one directory per cluster, and loaded again on
+start. The server has no authentication; expose it behind an ingress or proxy
+that provides it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+
+		store, err := diagnostic.NewFleetStore(dataDir)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
+		for _, skipped := range store.Skipped {
+			fmt.Printf("⚠️ Skipping stored report %s\n", skipped)
+		}
+		server := &diagnostic.FleetServer{
+			Store: store,
+			Log: func(format string, args ...interface{}) {
+				fmt.Printf(format+"\n", args...)
+			},
+		}
+
+		fmt.Printf("📊 Serving the fleet dashboard of %d stored reports on %s (data in %s)\n", len(store.Sources(0)), listen, dataDir)
+		if err := http.ListenAndServe(listen, server.Handler()); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("listen", ":8080", "address to listen on")
+	serveCmd.Flags().String("data-dir", "fleet_reports", "directory to store uploaded reports in")
+}
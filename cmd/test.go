@@ -3,18 +3,24 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
-	"k8s-diagnostic/internal/diagnostic"
+	"github.com/parlakisik/k8s_diagnostic/pkg/diagnostic"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // Global logger instance
 var logger *diagnostic.Logger
 
-// Test registry - maps test names to their functions
+// Test registry - maps test names to their display names
 type TestEntry struct {
 	Name     string
 	Function func(context.Context) diagnostic.TestResult
@@ -25,27 +31,48 @@ type TestEntryWithConfig struct {
 	Function func(context.Context, diagnostic.TestConfig) diagnostic.TestResult
 }
 
-// Available tests registry
-var availableTests = map[string]TestEntry{
-	"pod-to-pod":         {"Pod-to-Pod Connectivity", nil}, // Special handling with config
-	"service-to-pod":     {"Service to Pod Connectivity", nil},
-	"cross-node":         {"Cross-Node Service Connectivity", nil},
-	"dns":                {"DNS Resolution", nil},
-	"nodeport":           {"NodePort Service Connectivity", nil},
-	"loadbalancer":       {"LoadBalancer Service Connectivity", nil},
-	"accepting-all-pods": {"Accepting All Requests from Other Pods", nil},
-	"rejecting-all-pods": {"Rejecting All Requests from Other Pods", nil},
+// Available tests registry: the built-in tests of the library, joined by plugin tests at startup
+var availableTests = builtinTestEntries()
+
+// builtinTestEntries lists the built-in tests under their display names
+func builtinTestEntries() map[string]TestEntry {
+	entries := map[string]TestEntry{}
+	for _, test := range diagnostic.BuiltinTests() {
+		entries[test.Name()] = TestEntry{test.Title(), nil}
+	}
+	return entries
+}
+
+// builtinTestNames lists the --test-list names of the built-in tests in run order
+func builtinTestNames() []string {
+	var names []string
+	for _, test := range diagnostic.BuiltinTests() {
+		names = append(names, test.Name())
+	}
+	return names
 }
 
 // Test groups for logical organization
 var testGroups = map[string][]string{
-	"networking": {"pod-to-pod", "service-to-pod", "cross-node", "dns", "nodeport", "loadbalancer"},
-	"policies":   {"accepting-all-pods", "rejecting-all-pods"},
-	// Future groups will be added here, e.g.:
-	// "firewall": {"ingress-policy", "egress-policy"},
-	// "storage": {"pv-binding", "pvc-access"},
+	"networking":    {"pod-to-pod", "service-to-pod", "cross-node", "dns", "nodeport-range", "nodeport", "loadbalancer", "external-dns", "ip-family", "dns-nodes", "snat", "ipam-sanity", "ip-exhaustion", "overlay-health", "native-routes", "asymmetric-routing", "zone-latency", "windows-connectivity"},
+	"policies":      {"accepting-all-pods", "rejecting-all-pods", "l4-ingress-ports", "l4-egress-ports", "default-deny-allowlist", "namespace-isolation", "egress-dns-allow", "policy-propagation"},
+	"cilium":        {"cilium-lb-ipam", "cilium-kpr", "cilium-health", "cilium-identity", "cilium-bpf-maps", "cilium-bgp"},
+	"calico":        {"calico-health"},
+	"protocols":     {"tls", "grpc", "websocket-http2", "idle-timeout"},
+	"firewall":      {"firewall-ports", "host-firewall"},
+	"netpol":        {"netpol-ingress", "netpol-egress"},
+	"mesh":          {"mesh-mtls", "weighted-routing"},
+	"mesh-policies": {"mesh-authz"},
+	"integration":   {"cilium-connectivity"},
+	"storage":       {"pvc-access", "pvc-rwx", "csi-health", "pvc-expand"},
+	"control-plane": {"apiserver-latency", "admission-webhooks", "control-plane-health", "scheduling-latency", "cert-expiry", "serviceaccount-token", "port-forward", "apiserver-proxy", "kubelet-ports"},
+	"workload":      {"deployment-rollout", "hpa-scaling", "pod-churn", "namespace-churn", "readiness-shift"},
+	"chaos":         {"fault-latency", "fault-loss", "node-isolation", "dns-failure", "cni-restart"},
 }
 
+// setupTimeout bounds the preflight checks and environment setup before the first test starts
+const setupTimeout = 3 * time.Minute
+
 // Default test list when no --test-list or --test-group is specified
 var defaultTests = []string{"pod-to-pod", "service-to-pod", "cross-node", "dns", "nodeport", "loadbalancer"}
 
@@ -58,18 +85,115 @@ var testCmd = &cobra.Command{
 Available test groups:
 - networking: All network connectivity tests
 - policies: Network policy tests
+- cilium: Cilium-specific feature tests
+- calico: Calico-specific health tests (skipped on other CNIs)
+- protocols: Application protocol tests (TLS, HTTP/2, gRPC, WebSocket, idle connections)
+- firewall: Required ports between nodes and Cilium host firewall tests (host firewall opt-in with --host-firewall)
+- netpol: CNI-agnostic NetworkPolicy conformance subset (networking.k8s.io policies)
+- mesh: Istio/Linkerd service mesh and Gateway API traffic splitting tests (skipped without a mesh control plane or Gateway)
+- mesh-policies: Istio/Linkerd L7 authorization policy tests, the mesh counterpart of the policies group
+- integration: Wrappers around external test suites (cilium connectivity test)
+- storage: Persistent volume provisioning and mount tests
+- control-plane: API server and control-plane component tests
+- workload: Workload lifecycle tests (rollouts, autoscaling, pod and namespace churn, readiness traffic shifting)
+- chaos: Opt-in fault injection that verifies the diagnostics measure injected faults
+- plugins: Tests from executable plugins in --plugin-dir, --probe-file definitions and compiled-in registrations
 
 Networking tests include:
 - Pod-to-Pod Connectivity: Creates two netshoot pods on different worker nodes and tests ping connectivity
 - Service-to-Pod Connectivity: Creates nginx deployment + service and tests HTTP connectivity and load balancing
 - Cross-Node Service Connectivity: Tests service connectivity from a remote node to validate kube-proxy inter-node routing
 - DNS Resolution: Tests service DNS resolution including FQDN, short names, and pod-to-pod DNS
-- NodePort Service Connectivity: Tests external access to services through node ports
-- LoadBalancer Service Connectivity: Tests LoadBalancer service functionality
+- NodePort Range and Conflicts: Reads the --service-node-port-range, reports how much of it Services have allocated and finds host processes listening inside it on every node, so the NodePort test can warn when it is assigned a colliding port
+- NodePort Service Connectivity: Probes the node port on every node address and reports a per-node reachability table, optionally also from outside the cluster (--nodeport-external-probe)
+- LoadBalancer Service Connectivity: Waits for an external address (MetalLB or cloud), curls it from a hostNetwork pod and the CLI host until it forwards, reporting the health-check propagation time, and checks L2/BGP announcement
+- ExternalDNS Record Publication: Annotates a LoadBalancer service for external-dns and polls the zone until the record resolves to the load balancer address (opt-in with --external-dns-zone)
+- Service IP Family Validation: Creates services with explicit ipFamilies and verifies assigned ClusterIPs and per-family reachability
+- kube-dns Reachability Per Node: Queries the kube-dns ClusterIP from a probe pod on every worker node and reports a per-node table
+- SNAT/Masquerade Validation: Has a pod connect to another node's address and checks the observed source IP matches the masquerade configuration
+- PodCIDR/IPAM Sanity: Cross-checks node podCIDRs, CNI IPAM pools and pod IPs for overlaps with services and node networks, and reports free pod addresses per node
+- Per-Node IP Exhaustion Early Warning: Computes how many more pods each node can start from its free pod addresses and max-pods, and flags nodes below --ip-free-threshold
+- Overlay (VXLAN/Geneve) Health: For tunnel routing modes, checks the overlay interface on every node, UDP reachability of the overlay port between every node pair and that cross-node pod traffic is encapsulated
+- Native Routing Table Validation: For Cilium native routing, checks every node routes every other node's pod CIDR via that node and lists the exact missing routes
+- Asymmetric Routing Detection: Probes two worker nodes in both directions at pod and node level and flags one-way failures, replies leaving through another interface (rp_filter, multi-NIC) and traceroutes of different length
+- Cross-Zone Latency Matrix: Groups worker nodes by topology.kubernetes.io/zone and reports pod-to-pod latency within and between zones as a zone x zone matrix
+- Windows Node Connectivity: On mixed clusters, runs client and server pods on a Linux worker and up to two Windows nodes and reports a Linux/Windows pod and service connectivity matrix (curl.exe and Test-NetConnection on Windows); skipped without Windows nodes
 
 Policies tests include:
 - Accepting All Requests from Other Pods: Tests the allow-all Cilium policy that permits traffic between all pods
 - Rejecting All Requests from Other Pods: Tests the deny-all Cilium policy that blocks traffic between pods
+- L4 Ingress Port Policies: Applies port-restricted ingress allow/deny policies and verifies each allowed and denied port per rule
+- L4 Egress Port Policies: Applies port-restricted egress allow/deny policies and verifies each allowed and denied port per rule
+- Default-Deny Allowlist Suite: Isolates test pods with default-deny ingress/egress, then layers DNS, label and port allow rules and verifies each step
+- Namespace Isolation Policy: Uses namespace-label selectors to allow same-tenant traffic, deny a second tenant namespace, then selectively allow one of its workloads
+- Egress DNS Allow Policy: Applies egress default-deny, then a UDP/TCP 53 allow to kube-dns, and verifies resolution returns while other egress stays blocked
+- Network Policy Propagation Latency: Applies a deny policy and times per worker node how long until traffic is blocked and until its removal restores traffic
+
+Cilium tests include:
+- Cilium LB-IPAM LoadBalancer: Requests an address from a CiliumLoadBalancerIPPool and verifies assignment and reachability
+- Cilium Kube-Proxy Replacement: Detects the KPR mode, checks the agent service map for the test service and validates hostPort and per-interface NodePort behaviour
+- Cilium Agent and Endpoint Health: Runs status and endpoint list in every agent and aggregates unhealthy endpoints, controller failures and node health checks per node
+- Cilium Identity Resolution: Resolves the security identities of labelled test pods and flags init/reserved identities, missing labels and identity churn
+- Cilium BPF Map Pressure: Collects CT, NAT and policy map utilization from every agent and flags maps above 90% (also attached to failed networking tests)
+- Cilium BGP Control Plane: Detects BGP peering policies or cluster configs, verifies each session is Established per node and checks PodCIDR/LoadBalancer routes are advertised (opt-in with --bgp)
+
+Calico tests include:
+- Calico Node, BGP and Felix Health: Checks calico-node readiness, BIRD BGP sessions per node, IP pool IPIP/VXLAN modes and recent Felix dataplane errors
+
+Protocols tests include:
+- TLS/HTTPS Connectivity: Serves nginx over TLS and validates SNI, certificate chain, expiry and protocol version from a client pod
+- gRPC Connectivity: Runs a gRPC echo server and calls it over ClusterIP and, where present, through Ingress and Gateway API routes
+- WebSocket and HTTP/2 Upgrade: Verifies WebSocket handshakes, h2c and HTTP/2 over TLS through ClusterIP and ingress/gateway paths
+- Long-Lived Connection Idle Timeout: Keeps TCP connections through a ClusterIP idle for each --idle-timeouts period, then sends data to detect silent conntrack/NAT drops (opt-in)
+
+Firewall tests include:
+- Node Firewall Port Matrix: Checks the API server, etcd, kubelet, health, overlay, WireGuard and NodePort ports the cluster uses are open between every node pair, with a per-port matrix and remediation hints
+- Host Firewall Policy: Applies a host policy to one node blocking a test port and verifies kubelet/SSH stay reachable, with automatic rollback
+
+Netpol tests include:
+- NetworkPolicy Ingress Conformance: Applies standard ingress policies (deny-all, podSelector, namespaceSelector, ports, ipBlock) one at a time and verifies each probe
+- NetworkPolicy Egress Conformance: Applies standard egress policies (deny-all, podSelector with port, ipBlock with except) one at a time and verifies each probe
+
+Mesh tests include:
+- Service Mesh mTLS: Runs a server and client in a meshed namespace requiring mTLS and verifies the client's identity reaches the server and a client without a sidecar is refused
+- Canary Weighted Routing: Splits traffic 90/10 between two backend versions with an HTTPRoute on a Gateway and/or the mesh, sends --canary-requests requests and verifies the measured split is within three standard deviations of the weights
+
+Mesh-policies tests include:
+- Service Mesh L7 Authorization: Applies deny-all, identity/method/path allow and admin path protection policies from mesh-policies/ in turn and verifies every client request per phase
+
+Integration tests include:
+- Cilium CLI Connectivity Suite: Runs 'cilium connectivity test' when the CLI is installed and merges each scenario from its JUnit report into this report
+
+Storage tests include:
+- PVC Binding and Mount: Creates a PVC against the default (or --storage-class) StorageClass, mounts it in a pod, writes and reads data and reports bind/attach/mount latency
+- RWX Cross-Node Volume Access: Mounts a ReadWriteMany PVC from two nodes and verifies concurrent writes are visible across nodes (skipped without an RWX-capable StorageClass)
+- CSI Driver Health: Checks CSI controller and node plugin pods, CSIDriver/CSINode registration and the VolumeAttachment backlog
+- PVC Volume Expansion: Expands a mounted PVC and verifies the filesystem grows inside the pod, timing each phase (opt-in with --volume-expansion)
+
+Control-plane tests include:
+- API Server Latency: Measures GET/LIST/WATCH latency of small objects from the tool and GET/LIST from a pod, reporting p50/p90/p99 and flagging unusual latency
+- Admission Webhook Connectivity: Checks every validating/mutating webhook's service endpoints, probes it from a pod and looks for recent failed webhook calls
+- Control Plane Health: Snapshot of /readyz checks, etcd size and rejected requests from the API server metrics, scheduler/controller-manager leader leases and kube-system control-plane pods
+- Pod Scheduling Latency: Creates pause pods on each worker node and reports creation-to-Scheduled and Scheduled-to-Ready percentiles overall and per node
+- Certificate Expiry: Checks the API server, kubelet and test-namespace cert-manager certificates against --cert-expiry-window
+- ServiceAccount Token Authentication: Verifies pods get bound, expiring tokens that authenticate, that TokenRequest works and that tokens are invalidated with their pod
+- API Server Port-Forward: Opens a port-forward to an echo pod through the API server like kubectl port-forward and verifies 1 MiB sent through the tunnel comes back intact
+- API Server Proxy Path: Requests the test service and a backend pod through the API server proxy subresource and compares with direct access from a pod to tell which path is broken
+- Kubelet Port Audit: Checks every node's kubelet read-only port 10255, healthz port 10248 and anonymous access to 10250 from a pod, and 10250 /healthz through the API server, warning on exposed ports and nodes that differ
+
+Workload tests include:
+- Deployment Rollout and Rollback: Rolls an nginx deployment to a new image tag and back while a client pod probes its Service continuously, reporting failed requests and downtime windows
+- HPA Scaling Responsiveness: Loads a CPU burner behind an HPA and times the scale decision, new replicas becoming Ready and receiving Service traffic (opt-in with --hpa)
+- Pod Startup at Scale: Creates --pod-churn-count pause pods across the nodes, replaces them with a second wave and reports scheduling, IP assignment and readiness percentiles, failing on IPAM exhaustion
+- Namespace Create/Delete Churn: Creates and deletes namespaces with a small workload and flags namespaces stuck Terminating with their blocking finalizers
+- Readiness Traffic Shifting: Flips a backend's readiness off and on during continuous Service requests and times the Ready condition, EndpointSlice and datapath changes
+
+Chaos tests include:
+- Latency Fault Injection: Adds --inject-latency with tc netem in a test pod and verifies the measured pod-to-pod RTT rises by that amount and recovers
+- Packet Loss Fault Injection: Drops --inject-loss percent of a test pod's packets with tc netem and reports pod-to-pod loss and service request failures separately from the baseline
+- Node Isolation Simulation: Cordons a worker node and isolates the Service backend on it, verifies the Service stops routing there and recovers after rollback (opt-in with --node-isolation)
+- DNS Failure Injection: Blocks DNS for a test pod or scales CoreDNS to zero, verifies lookups fail promptly, NodeLocal DNSCache serves cached names and DNS recovers (opt-in with --dns-failure=block|coredns)
+- CNI Agent Restart Resilience: Deletes the CNI agent pod on one node during a continuous pod-to-pod ping and measures the connectivity interruption (opt-in with --cni-restart)
 
 The tool will use the current kubectl context unless --kubeconfig is specified.
 All test resources will be created in the specified namespace (default: diagnostic-test).`,
@@ -80,17 +204,107 @@ All test resources will be created in the specified namespace (default: diagnost
 		placement, _ := cmd.Flags().GetString("placement")
 		testList, _ := cmd.Flags().GetStringSlice("test-list")
 		testGroup, _ := cmd.Flags().GetString("test-group")
+		nodePortExternalIPs, _ := cmd.Flags().GetBool("nodeport-external-ips")
+		nodePortExternalProbe, _ := cmd.Flags().GetString("nodeport-external-probe")
+		lbTimeout, _ := cmd.Flags().GetDuration("lb-timeout")
+		lbReadyTimeout, _ := cmd.Flags().GetDuration("lb-ready-timeout")
+		lbProbeFrom, _ := cmd.Flags().GetStringSlice("lb-probe-from")
+		externalDNSZone, _ := cmd.Flags().GetString("external-dns-zone")
+		externalDNSServer, _ := cmd.Flags().GetString("external-dns-server")
+		externalDNSTimeout, _ := cmd.Flags().GetDuration("external-dns-timeout")
+		lbIPAMCIDR, _ := cmd.Flags().GetString("lb-ipam-cidr")
+		tlsIssuer, _ := cmd.Flags().GetString("tls-issuer")
+		hostFirewall, _ := cmd.Flags().GetBool("host-firewall")
+		hostFirewallAllowedPorts, _ := cmd.Flags().GetIntSlice("host-firewall-allowed-ports")
+		hubbleVerify, _ := cmd.Flags().GetBool("hubble-verify")
+		bgp, _ := cmd.Flags().GetBool("bgp")
+		ciliumConnectivityArgs, _ := cmd.Flags().GetStringSlice("cilium-connectivity-args")
+		captureOnFailure, _ := cmd.Flags().GetBool("capture-on-failure")
+		captureNodes, _ := cmd.Flags().GetBool("capture-node-interfaces")
+		redact, _ := cmd.Flags().GetBool("redact")
+		redactKey, _ := cmd.Flags().GetString("redact-key")
+		nodeInfo, _ := cmd.Flags().GetBool("node-info")
+		emitEvents, _ := cmd.Flags().GetBool("emit-events")
+		summaryConfigMap, _ := cmd.Flags().GetBool("summary-configmap")
+		persistNamespace, _ := cmd.Flags().GetString("persist-namespace")
+		persistKeep, _ := cmd.Flags().GetInt("persist-keep")
+		storageClass, _ := cmd.Flags().GetString("storage-class")
+		rwxStorageClass, _ := cmd.Flags().GetString("rwx-storage-class")
+		volumeExpansion, _ := cmd.Flags().GetBool("volume-expansion")
+		certExpiryWindow, _ := cmd.Flags().GetDuration("cert-expiry-window")
+		skipPreflight, _ := cmd.Flags().GetBool("skip-preflight")
+		failOnSkip, _ := cmd.Flags().GetBool("fail-on-skip")
+		failOnWarning, _ := cmd.Flags().GetBool("fail-on-warning")
+		hpa, _ := cmd.Flags().GetBool("hpa")
+		podChurnCount, _ := cmd.Flags().GetInt("pod-churn-count")
+		canaryRequests, _ := cmd.Flags().GetInt("canary-requests")
+		injectLatency, _ := cmd.Flags().GetDuration("inject-latency")
+		injectLoss, _ := cmd.Flags().GetFloat64("inject-loss")
+		nodeIsolation, _ := cmd.Flags().GetBool("node-isolation")
+		dnsFailure, _ := cmd.Flags().GetString("dns-failure")
+		cniRestart, _ := cmd.Flags().GetBool("cni-restart")
+		idleTimeouts, _ := cmd.Flags().GetDurationSlice("idle-timeouts")
+		runTimeout, _ := cmd.Flags().GetDuration("timeout")
+		ipFreeThreshold, _ := cmd.Flags().GetInt("ip-free-threshold")
+		pluginDir, _ := cmd.Flags().GetString("plugin-dir")
+		ignoreDependencies, _ := cmd.Flags().GetBool("ignore-dependencies")
+		probeFiles, _ := cmd.Flags().GetStringSlice("probe-file")
+		isolatedFixtures, _ := cmd.Flags().GetBool("isolated-fixtures")
+		reportURL, _ := cmd.Flags().GetString("report-url")
+		clusterName, _ := cmd.Flags().GetString("cluster-name")
+		// The ping and HTTP probes can also be tuned in the config file, e.g. one file per suite passed with --config
+		ping := diagnostic.PingOptions{
+			Count:     viper.GetInt("ping-count"),
+			Interval:  viper.GetDuration("ping-interval"),
+			Size:      viper.GetInt("ping-size"),
+			Deadline:  viper.GetDuration("ping-deadline"),
+			Interface: viper.GetString("ping-interface"),
+			// Above the soft threshold a ping that gets through passes with a warning
+			WarnLatency: viper.GetDuration("ping-warn-latency"),
+		}
+		httpProbe := diagnostic.HTTPOptions{
+			Scheme:          viper.GetString("http-scheme"),
+			Port:            viper.GetInt("http-port"),
+			Path:            viper.GetString("http-path"),
+			Method:          viper.GetString("http-method"),
+			Headers:         viper.GetStringSlice("http-header"),
+			ConnectTimeout:  viper.GetDuration("http-connect-timeout"),
+			Timeout:         viper.GetDuration("http-timeout"),
+			FollowRedirects: viper.GetBool("http-follow-redirects"),
+			ExpectedStatus:  viper.GetIntSlice("http-expected-status"),
+		}
+		// Disconnected clusters list their mirrors once in the config file instead of on every run
+		imageMirrors, err := diagnostic.ParseImageMirrors(viper.GetStringSlice("image-mirror"))
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
+
+		// pcaps hold unmasked addresses and payloads, so a run meant to be shared writes none
+		if redact && (captureOnFailure || captureNodes) {
+			fmt.Printf("⚠️ WARNING: --capture-on-failure is ignored with --redact - packet captures cannot be redacted\n")
+			captureOnFailure, captureNodes = false, false
+		}
+
+		// Everything the run writes goes into one test_results/<run-id>/ directory
+		run, err := diagnostic.NewRunArtifacts("", time.Now())
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			exitCode = exitFailed
+			return
+		}
 
 		// Initialize logger with debug level when verbose mode is enabled
-		var err error
 		if verbose {
-			logger, err = diagnostic.NewLoggerWithLevel(true, diagnostic.DEBUG) // true = console output enabled
+			logger, err = diagnostic.NewLoggerToFile(true, diagnostic.DEBUG, run.LogPath()) // true = console output enabled
 		} else {
-			logger, err = diagnostic.NewLoggerWithLevel(true, diagnostic.INFO)
+			logger, err = diagnostic.NewLoggerToFile(true, diagnostic.INFO, run.LogPath())
 		}
 
 		if err != nil {
 			fmt.Printf("ERROR: Failed to initialize logger: %v\n", err)
+			exitCode = exitFailed
 			return
 		}
 		defer logger.Close()
@@ -106,17 +320,30 @@ All test resources will be created in the specified namespace (default: diagnost
 			logger.LogInfo("Using default kubectl context")
 		}
 
-		// Create tester with timeout context
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
-		defer cancel()
+		// Interrupting the run aborts the current test, which still removes its resources
+		interruptCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		// Setup gets a fixed budget; the tests get theirs once they are selected
+		ctx, cancelSetup := context.WithTimeout(interruptCtx, setupTimeout)
+		defer cancelSetup()
 		logger.LogDebug("Creating diagnostic tester with kubeconfig: %s, namespace: %s", kubeconfig, namespace)
 		tester, err := diagnostic.NewTester(kubeconfig, namespace)
 		if err != nil {
 			logger.LogError("Failed to create diagnostic tester: %v", err)
+			exitCode = exitFailed
 			return
 		}
 		logger.LogDebug("Tester created successfully")
 
+		// Plugin tests join the registry before the test list is resolved, so they can be selected like built-in tests
+		registerPluginTests(ctx, pluginDir, probeFiles)
+
+		// Service and DNS tests share one nginx backend and their netshoot clients unless each test should start clean
+		if !isolatedFixtures {
+			tester.EnableSharedFixtures()
+		}
+		tester.SetImageMirrors(imageMirrors)
+
 		// Record overall start time
 		overallStartTime := time.Now()
 
@@ -133,21 +360,6 @@ All test resources will be created in the specified namespace (default: diagnost
 
 		fmt.Printf("Running connectivity diagnostic tests in namespace '%s'\n\n", namespace)
 
-		// Create namespace before running tests
-		fmt.Printf("🔍 Setting up test environment...\n")
-		if err := tester.EnsureNamespace(ctx); err != nil {
-			fmt.Printf("ERROR: Failed to create namespace %s: %v\n", namespace, err)
-			return
-		}
-		fmt.Printf("✅ Namespace %s ready\n\n", namespace)
-
-		// Run all diagnostic tests
-		fmt.Printf("🧪 Running diagnostic tests...\n")
-
-		// Store timed test results for JSON output
-		var timedResults []diagnostic.TimedTestResult
-		var testNames []string
-
 		// Determine which tests to run
 		testsToRun := defaultTests
 
@@ -179,39 +391,223 @@ All test resources will be created in the specified namespace (default: diagnost
 			}
 		}
 
+		// Check the permissions of the selected tests up front instead of failing mid-run with Forbidden errors
+		if !skipPreflight {
+			required := diagnostic.RequiredPermissions(testsToRun, namespace)
+			if emitEvents {
+				required = append(required, diagnostic.Permission{Verb: "create", Resource: "events", Namespace: namespace})
+			}
+			if summaryConfigMap {
+				required = append(required,
+					diagnostic.Permission{Verb: "create", Resource: "configmaps", Namespace: namespace},
+					diagnostic.Permission{Verb: "update", Resource: "configmaps", Namespace: namespace})
+			}
+			if persistNamespace != "" {
+				for _, verb := range []string{"create", "list", "delete"} {
+					required = append(required, diagnostic.Permission{Verb: verb, Resource: "configmaps", Namespace: persistNamespace})
+				}
+			}
+			missing, err := tester.CheckPermissions(ctx, required)
+			if err != nil {
+				fmt.Printf("⚠️ Permission preflight skipped: %v\n", err)
+				logger.LogWarning("Permission preflight skipped: %v", err)
+			} else if len(missing) > 0 {
+				fmt.Printf("❌ Missing %d permissions for the selected tests:\n", len(missing))
+				for _, permission := range missing {
+					if len(permission.Tests) > 0 {
+						fmt.Printf("  - %s (needed by: %s)\n", permission, strings.Join(permission.Tests, ", "))
+					} else {
+						fmt.Printf("  - %s (needed by every run)\n", permission)
+					}
+				}
+				fmt.Printf("\nA Role/ClusterRole granting them (bind it to your user or service account):\n\n%s\n", diagnostic.PermissionsRoleYAML(missing))
+				fmt.Printf("Run with --skip-preflight to run anyway.\n")
+				logger.LogError("Missing %d permissions for the selected tests", len(missing))
+				exitCode = exitFailed
+				return
+			}
+			logger.LogDebug("Permission preflight passed for %d permissions", len(required))
+		}
+
+		// Create namespace before running tests
+		fmt.Printf("🔍 Setting up test environment...\n")
+		if err := tester.EnsureNamespace(ctx); err != nil {
+			fmt.Printf("ERROR: Failed to create namespace %s: %v\n", namespace, err)
+			exitCode = exitFailed
+			return
+		}
+		fmt.Printf("✅ Namespace %s ready\n", namespace)
+
+		// Pull every mirrored image once, so a missing mirror fails here rather than as pull timeouts in every test
+		if len(imageMirrors) > 0 && !skipPreflight {
+			if problems := tester.CheckImageMirrors(ctx); len(problems) > 0 {
+				fmt.Printf("❌ %d mirrored images cannot be pulled:\n", len(problems))
+				for _, problem := range problems {
+					fmt.Printf("  - %s\n", problem)
+				}
+				fmt.Printf("\nCheck the image-mirror entries, that the mirror holds the image and that the default service account's imagePullSecrets grant access.\n")
+				fmt.Printf("Run with --skip-preflight to run anyway.\n")
+				logger.LogError("%d mirrored images cannot be pulled", len(problems))
+				exitCode = exitFailed
+				return
+			}
+			fmt.Printf("✅ Image mirrors pullable\n")
+			logger.LogDebug("Image mirror preflight passed")
+		}
+
+		// Detect how Services are programmed so failures can point at the right dataplane
+		kubeProxy := tester.DetectKubeProxyMode(ctx)
+		fmt.Printf("ℹ️ kube-proxy mode: %s (%s)\n\n", kubeProxy.Mode, kubeProxy.Source)
+		logger.LogDebug("kube-proxy mode %s detected from %s", kubeProxy.Mode, kubeProxy.Source)
+
+		// Probe images lacking a variant for a node architecture fail there with pull or exec format errors
+		if architectures, err := tester.NodeArchitectures(ctx); err == nil && len(architectures) > 0 {
+			var counts []string
+			for arch, count := range architectures {
+				counts = append(counts, fmt.Sprintf("%s (%d)", arch, count))
+			}
+			sort.Strings(counts)
+			logger.LogDebug("Linux node architectures: %s", strings.Join(counts, ", "))
+			for _, gap := range tester.CheckImageArchitectures(ctx, architectures) {
+				fmt.Printf("⚠️ %s\n", gap)
+				logger.LogWarning("%s", gap)
+			}
+		}
+
+		// Store timed test results for JSON output
+		var timedResults []diagnostic.TimedTestResult
+		var testNames []string
+		networkingFailed := false
+
 		// Execute tests based on test registry
 		testConfig := diagnostic.TestConfig{
-			Placement: placement,
+			Placement:                placement,
+			NodePortExternalIPs:      nodePortExternalIPs,
+			NodePortExternalProbe:    nodePortExternalProbe,
+			LoadBalancerTimeout:      lbTimeout,
+			LoadBalancerReadyTimeout: lbReadyTimeout,
+			LoadBalancerProbeFrom:    lbProbeFrom,
+			ExternalDNSZone:          externalDNSZone,
+			ExternalDNSServer:        externalDNSServer,
+			ExternalDNSTimeout:       externalDNSTimeout,
+			LBIPAMCIDR:               lbIPAMCIDR,
+			TLSIssuer:                tlsIssuer,
+			HostFirewall:             hostFirewall,
+			HostFirewallAllowedPorts: hostFirewallAllowedPorts,
+			HubbleVerify:             hubbleVerify,
+			BGP:                      bgp,
+			CiliumConnectivityArgs:   ciliumConnectivityArgs,
+			CaptureOnFailure:         captureOnFailure,
+			CaptureNodes:             captureNodes,
+			CaptureDir:               run.CapturesDir(),
+			StorageClass:             storageClass,
+			RWXStorageClass:          rwxStorageClass,
+			VolumeExpansion:          volumeExpansion,
+			CertExpiryWindow:         certExpiryWindow,
+			HPA:                      hpa,
+			PodChurnCount:            podChurnCount,
+			CanaryRequests:           canaryRequests,
+			InjectLatency:            injectLatency,
+			InjectLoss:               injectLoss,
+			NodeIsolation:            nodeIsolation,
+			DNSFailure:               dnsFailure,
+			CNIRestart:               cniRestart,
+			IdleTimeouts:             idleTimeouts,
+			IPFreeThreshold:          ipFreeThreshold,
+			Ping:                     ping,
+			HTTP:                     httpProbe,
 		}
 
-		testNum := 1
+		// Resolve the selected tests; the runner moves prerequisites first so a failure can stop the tests that
+		// depend on it
+		var selectedTests []diagnostic.Test
 		for _, testName := range testsToRun {
-			testEntry, exists := availableTests[testName]
-			if !exists {
+			test, exists := diagnostic.LookupTest(testName)
+			if _, available := availableTests[testName]; !exists || !available {
 				fmt.Printf("WARNING: Unknown test '%s' - skipping\n", testName)
 				continue
 			}
+			selectedTests = append(selectedTests, test)
+		}
+		runner := diagnostic.NewRunner(tester, testConfig)
+		if ignoreDependencies {
+			runner.Dependencies = nil
+		}
+
+		// Progress goes to the console and, with --report-url, to a collecting server. Redacted runs only send the
+		// final, redacted report to the server.
+		console := &diagnostic.ConsoleReporter{Logger: logger, Verbose: verbose}
+		reporters := diagnostic.MultiReporter{console}
+		var httpReporter *diagnostic.HTTPReporter
+		if reportURL != "" {
+			httpReporter = &diagnostic.HTTPReporter{URL: reportURL}
+			if !redact {
+				reporters = append(reporters, httpReporter)
+			}
+		}
+		runner.Reporter = reporters
+		logger.LogInfo("Test configuration: %+v", testConfig)
+
+		runner.AfterTest = func(test diagnostic.Test, result *diagnostic.TimedTestResult) {
+			testName := test.Name()
+			testNames = append(testNames, availableTests[testName].Name)
+
+			// Full CT/NAT/policy maps and node settings such as rp_filter cause connectivity failures, so record
+			// map utilization and node network findings with them
+			skipped := result.DetailedDiagnostics != nil &&
+				(result.DetailedDiagnostics.FailureStage == "Prerequisite" || result.DetailedDiagnostics.FailureStage == "Cancelled")
+			if !result.Success && !skipped {
+				for _, networkingTest := range testGroups["networking"] {
+					if networkingTest == testName {
+						tester.AttachBPFMapPressure(ctx, &result.TestResult)
+						tester.AttachNodeNetwork(ctx, &result.TestResult)
+						networkingFailed = true
+						break
+					}
+				}
+				// CSI driver health separates provisioning and attach problems from filesystem problems
+				if testName == "pvc-access" || testName == "pvc-rwx" || testName == "pvc-expand" {
+					tester.AttachCSIHealth(ctx, &result.TestResult)
+				}
+			}
+		}
+		var selectedNames []string
+		for _, test := range selectedTests {
+			selectedNames = append(selectedNames, test.Name())
+		}
+		// Tests run one at a time, so the run gets the sum of their budgets unless --timeout sets it
+		if runTimeout <= 0 {
+			runTimeout = diagnostic.RunTimeout(selectedTests, testConfig)
+		}
+		logger.LogInfo("Run timeout: %v", runTimeout)
+		ctx, cancelRun := context.WithTimeout(interruptCtx, runTimeout)
+		defer cancelRun()
+		reporters.RunStarted(ctx, diagnostic.RunInfo{Namespace: namespace, Tests: selectedNames, StartTime: overallStartTime})
+		timedResults = runner.Run(ctx, selectedTests)
+
+		// Reporting and namespace cleanup still happen when the run timed out or was interrupted
+		if ctx.Err() != nil {
+			logger.LogWarning("Run cancelled: %v", ctx.Err())
+		}
+		ctx, cancelReport := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+		defer cancelReport()
 
-			// Special handling for tests that require config
-			switch testName {
-			case "pod-to-pod":
-				executeTimedTestWithConfig(testNum, testEntry.Name, tester.TestPodToPodConnectivityWithConfig, ctx, verbose, testConfig, &timedResults, &testNames)
-			case "service-to-pod":
-				executeTimedTest(testNum, testEntry.Name, tester.TestServiceToPodConnectivity, ctx, verbose, &timedResults, &testNames)
-			case "cross-node":
-				executeTimedTest(testNum, testEntry.Name, tester.TestCrossNodeServiceConnectivity, ctx, verbose, &timedResults, &testNames)
-			case "dns":
-				executeTimedTest(testNum, testEntry.Name, tester.TestDNSResolution, ctx, verbose, &timedResults, &testNames)
-			case "nodeport":
-				executeTimedTest(testNum, testEntry.Name, tester.TestNodePortServiceConnectivity, ctx, verbose, &timedResults, &testNames)
-			case "loadbalancer":
-				executeTimedTest(testNum, testEntry.Name, tester.TestLoadBalancerServiceConnectivity, ctx, verbose, &timedResults, &testNames)
-			case "accepting-all-pods":
-				executeTimedTest(testNum, testEntry.Name, tester.TestAcceptingAllPods, ctx, verbose, &timedResults, &testNames)
-			case "rejecting-all-pods":
-				executeTimedTest(testNum, testEntry.Name, tester.TestRejectingAllPods, ctx, verbose, &timedResults, &testNames)
+		// Node network environments are collected on the first networking failure, or for every run with --node-info
+		var nodeNetwork []diagnostic.NodeNetworkInfo
+		if nodeInfo || networkingFailed {
+			nodeNetwork = tester.CollectNodeNetwork(ctx)
+		}
+		if nodeInfo {
+			fmt.Printf("\nℹ️ Node network environment:\n")
+			for _, info := range nodeNetwork {
+				if info.Error != "" {
+					fmt.Printf("  %-30s ⚠️ %s\n", info.Node, info.Error)
+					continue
+				}
+				fmt.Printf("  %-30s kernel %-20s ip_forward=%s rp_filter=%s %s mtu %d\n", info.Node, info.KernelVersion,
+					info.Sysctls["net.ipv4.ip_forward"], info.Sysctls["net.ipv4.conf.all.rp_filter"],
+					info.DefaultInterface, info.DefaultMTU)
 			}
-			testNum++
 		}
 
 		// Record overall end time
@@ -223,51 +619,23 @@ All test resources will be created in the specified namespace (default: diagnost
 			testResults = append(testResults, timedResult.TestResult)
 		}
 
-		// Calculate test statistics
-		totalTests := len(testResults)
-		passedTests := 0
-		failedTests := 0
-		var passedTestNames []string
-		var failedTestNames []string
-
-		for i, result := range testResults {
-			if result.Success {
-				passedTests++
-				passedTestNames = append(passedTestNames, testNames[i])
-			} else {
-				failedTests++
-				failedTestNames = append(failedTestNames, testNames[i])
-			}
-		}
-
-		// Determine overall result
-		allTestsPassed := failedTests == 0
-		var overallResult diagnostic.TestResult
-		if allTestsPassed {
-			overallResult = diagnostic.TestResult{
-				Success: true,
-				Message: fmt.Sprintf("All %d diagnostic tests passed", totalTests),
-				Details: []string{},
-			}
-		} else {
-			overallResult = diagnostic.TestResult{
-				Success: false,
-				Message: fmt.Sprintf("%d of %d diagnostic tests failed", failedTests, totalTests),
-				Details: []string{},
+		// Publish outcomes in the cluster for alerting and operators
+		if emitEvents {
+			for i, testResult := range testResults {
+				if err := tester.EmitTestEvent(ctx, testNames[i], testResult); err != nil {
+					logger.LogWarning("%v", err)
+				}
 			}
+			logger.LogInfo("Test outcome events emitted: kubectl get events -n %s --field-selector source=k8s-diagnostic", namespace)
 		}
-
-		// Add individual test results to details
-		for i, result := range testResults {
-			if result.Success {
-				overallResult.Details = append(overallResult.Details, fmt.Sprintf("✓ PASS: %s: %s", testNames[i], result.Message))
+		if summaryConfigMap {
+			if err := tester.WriteSummaryConfigMap(ctx, testNames, testResults); err != nil {
+				logger.LogWarning("%v", err)
 			} else {
-				overallResult.Details = append(overallResult.Details, fmt.Sprintf("✗ FAIL: %s: %s", testNames[i], result.Message))
+				logger.LogInfo("Summary written: kubectl get configmap %s -n %s -o yaml", diagnostic.SummaryConfigMapName, namespace)
 			}
 		}
 
-		result := overallResult
-
 		// Get the keep-namespace flag
 		keepNamespace, _ := cmd.Flags().GetBool("keep-namespace")
 
@@ -281,7 +649,30 @@ All test resources will be created in the specified namespace (default: diagnost
 				break
 			}
 		}
-		shouldCleanup := isRunningAllTests && !keepNamespace
+		// Published events and the summary ConfigMap live in the test namespace, so it is kept for them
+		shouldCleanup := isRunningAllTests && !keepNamespace && !emitEvents && !summaryConfigMap
+
+		// Mask topology before anything is written when the report is meant to be shared
+		var redactor *diagnostic.Redactor
+		var redactErr error
+		if redact {
+			redactor, redactErr = tester.NewRedactor(ctx, redactKey)
+			if redactErr == nil {
+				if len(redactor.Unmasked) > 0 {
+					logger.LogWarning("Names too short to mask stay in clear text in the report: %s", strings.Join(redactor.Unmasked, ", "))
+				}
+				if err := logger.RedactFile(redactor); err != nil {
+					logger.LogWarning("Failed to redact %s: %v", run.LogPath(), err)
+				}
+			}
+		}
+
+		// Keep what the tests left in the namespace before cleanup removes it
+		if redactErr == nil {
+			if err := tester.CollectNamespaceManifests(ctx, run.ManifestsDir(), redactor); err != nil {
+				logger.LogWarning("Failed to collect test namespace manifests: %v", err)
+			}
+		}
 
 		if shouldCleanup {
 			// Clean up namespace after tests
@@ -315,216 +706,111 @@ All test resources will be created in the specified namespace (default: diagnost
 		)
 
 		// Add log file information to the JSON report
+		jsonReport.ExecutionInfo.RunID = run.ID
+		jsonReport.ExecutionInfo.Cluster = clusterName
+		if clusterName == "" {
+			jsonReport.ExecutionInfo.Cluster = tester.ClusterName()
+		}
 		jsonReport.ExecutionInfo.LogFile = logger.GetLogFilename()
+		jsonReport.ExecutionInfo.KubeProxyMode = kubeProxy.Mode
+		jsonReport.NodeNetwork = nodeNetwork
+		console.RunFinished(ctx, &jsonReport)
 
-		// Save the JSON report
-		if err := diagnostic.SaveJSONReport(&jsonReport); err != nil {
-			logger.LogWarning("Failed to save JSON report: %v", err)
-		} else {
-			logger.LogInfo("JSON report saved: test_results/%s", jsonReport.ExecutionInfo.Filename)
+		if redactor != nil {
+			redactErr = diagnostic.RedactJSONReport(&jsonReport, redactor)
 		}
 
-		// Display test summary
-		fmt.Printf("\n📊 Test Summary:\n")
-		fmt.Printf("  Total Tests: %d, Passed: %d, Failed: %d\n", totalTests, passedTests, failedTests)
-
-		if len(passedTestNames) > 0 {
-			fmt.Printf("  ✅ Passed Tests:\n")
-			for _, testName := range passedTestNames {
-				fmt.Printf("    ✅ %s\n", testName)
+		// Save the JSON and HTML reports
+		if redactErr != nil {
+			logger.LogWarning("Failed to redact JSON report, not saving it: %v", redactErr)
+		} else if err := run.JSONReporter().RunFinished(ctx, &jsonReport); err != nil {
+			logger.LogWarning("Failed to save JSON report: %v", err)
+		} else {
+			logger.LogInfo("JSON report saved: %s/%s", run.Dir, jsonReport.ExecutionInfo.Filename)
+			if err := run.HTMLReporter().RunFinished(ctx, &jsonReport); err != nil {
+				logger.LogWarning("Failed to render HTML report: %v", err)
 			}
 		}
 
-		if len(failedTestNames) > 0 {
-			fmt.Printf("  ❌ Failed Tests:\n")
-			for _, testName := range failedTestNames {
-				fmt.Printf("    ❌ %s\n", testName)
+		// Keep the run history in the cluster, outside the test namespace so it survives cleanup
+		if persistNamespace != "" && redactErr == nil {
+			if name, err := tester.PersistReport(ctx, &jsonReport, persistNamespace, persistKeep); err != nil {
+				logger.LogWarning("Failed to persist report in the cluster: %v", err)
+			} else {
+				logger.LogInfo("Report persisted: kubectl get configmap %s -n %s -o jsonpath='{.data.report\\.json}'", name, persistNamespace)
 			}
 		}
 
-		// Display detailed results in verbose mode
-		if verbose {
-			fmt.Printf("\n📋 Detailed Test Results:\n")
-			for _, detail := range result.Details {
-				fmt.Printf("  %s\n", detail)
+		if httpReporter != nil && redactErr == nil {
+			// RunFinished drains the progress queue first, so its errors and drops are known afterwards
+			finishErr := httpReporter.RunFinished(ctx, &jsonReport)
+			if err := httpReporter.ProgressError(); err != nil {
+				logger.LogWarning("Stopped streaming progress to %s: %v", reportURL, err)
 			}
-		}
-
-		// Display final result
-		fmt.Printf("\n")
-		if result.Success {
-			fmt.Printf("🎉 Overall Result: %s\n", result.Message)
-			if !verbose && len(result.Details) > 0 {
-				fmt.Printf("💡 Run with --verbose for detailed test steps\n")
+			if dropped := httpReporter.DroppedEvents(); dropped > 0 {
+				logger.LogWarning("Dropped %d progress events for %s, which did not keep up", dropped, reportURL)
 			}
-		} else {
-			fmt.Printf("🛑 Overall Result: %s\n", result.Message)
-			if !verbose && len(result.Details) > 0 {
-				fmt.Printf("📋 Individual Test Results:\n")
-				for _, detail := range result.Details {
-					fmt.Printf("  %s\n", detail)
-				}
+			if finishErr != nil {
+				logger.LogWarning("Failed to report the run to %s: %v", reportURL, finishErr)
+			} else {
+				logger.LogInfo("Report posted to %s", reportURL)
 			}
 		}
 
-		// Final reminder about JSON file availability
-		fmt.Printf("\n📁 Detailed results are stored in JSON file in the test_results/ folder for further analysis\n")
-	},
-}
-
-// executeTimedTestUnified is a unified helper function that captures timing information for tests with or without config
-func executeTimedTestUnified(
-	testNum int,
-	testName string,
-	ctx context.Context,
-	verbose bool,
-	timedResults *[]diagnostic.TimedTestResult,
-	testNames *[]string,
-	execute func() diagnostic.TestResult,
-	logStartMessage string,
-) {
-	// Select emoji based on test name
-	var testEmoji string
-	switch {
-	case strings.Contains(testName, "Pod-to-Pod"):
-		testEmoji = "🔄"
-	case strings.Contains(testName, "Service to Pod"):
-		testEmoji = "🌐"
-	case strings.Contains(testName, "Cross-Node"):
-		testEmoji = "📡"
-	case strings.Contains(testName, "DNS"):
-		testEmoji = "🔤"
-	case strings.Contains(testName, "NodePort"):
-		testEmoji = "🚪"
-	case strings.Contains(testName, "LoadBalancer"):
-		testEmoji = "⚖️"
-	default:
-		testEmoji = "🧪"
-	}
-	fmt.Printf("Test %d: %s %s\n", testNum, testEmoji, testName)
-
-	// Set test context in logger
-	testContext := fmt.Sprintf("Test %d: %s", testNum, testName)
-	logger.SetContext(testContext)
-
-	// Log start message
-	logger.LogInfo("%s", logStartMessage)
-
-	// Capture start time
-	startTime := time.Now()
-
-	// Execute test function
-	logger.LogDebug("Executing test function")
-	result := execute()
-
-	// Capture end time
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
-	logger.LogInfo("Test completed in %.2f seconds", executionTime.Seconds())
-
-	// Log test result details
-	if result.Success {
-		logger.LogInfo("Test PASSED: %s", result.Message)
-	} else {
-		logger.LogError("Test FAILED: %s", result.Message)
-	}
-
-	// Log detailed results
-	for _, detail := range result.Details {
-		logger.LogDebug("Detail: %s", detail)
-	}
-
-	// Log diagnostic info if available
-	if result.DetailedDiagnostics != nil {
-		if result.DetailedDiagnostics.FailureStage != "" {
-			logger.LogWarning("Failure stage: %s", result.DetailedDiagnostics.FailureStage)
-		}
-		if result.DetailedDiagnostics.TechnicalError != "" {
-			logger.LogError("Technical error: %s", result.DetailedDiagnostics.TechnicalError)
+		// The index is written last so it lists every artifact of the run
+		if err := run.WriteIndex(namespace, &jsonReport); err != nil {
+			logger.LogWarning("Failed to write the artifact index: %v", err)
 		}
 
-		// Log command outputs
-		for _, cmd := range result.DetailedDiagnostics.CommandOutputs {
-			logger.CaptureCommandOutput(cmd)
+		// Failed tests fail the run; warnings only with --fail-on-warning and skipped tests only with --fail-on-skip
+		if jsonReport.Summary.Failed > 0 {
+			exitCode = exitFailed
+		} else if jsonReport.Summary.Warnings > 0 && failOnWarning {
+			exitCode = exitWarnings
+		} else if jsonReport.Summary.Skipped > 0 && failOnSkip {
+			exitCode = exitSkipped
 		}
 
-		// Log network context if available
-		if result.DetailedDiagnostics.NetworkContext != nil {
-			netContext := result.DetailedDiagnostics.NetworkContext
-			logger.LogDebug("Network context: source=%s, target=%s",
-				netContext.SourcePodIP, netContext.TargetPodIP)
-		}
+		// Final reminder about the run directory
+		fmt.Printf("\n📁 Reports, log, captures and namespace manifests of this run are in %s/ for further analysis\n", run.Dir)
+	},
+}
 
-		// Log troubleshooting hints
-		for _, hint := range result.DetailedDiagnostics.TroubleshootingHints {
-			logger.LogInfo("Troubleshooting hint: %s", hint)
+// registerPluginTests loads the executable plugins from dir and the probe tests from probeFiles, and adds every
+// registered plugin test to the test registry and the plugins group. A plugin named like a built-in test is skipped.
+func registerPluginTests(ctx context.Context, dir string, probeFiles []string) {
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".k8s-diagnostic", "plugins")
 		}
 	}
-
-	// Create timed result
-	timedResult := diagnostic.TimedTestResult{
-		TestResult: result,
-		StartTime:  startTime,
-		EndTime:    endTime,
+	if dir != "" {
+		_, warnings := diagnostic.LoadExecutablePlugins(ctx, dir)
+		for _, warning := range warnings {
+			fmt.Printf("⚠️ %s\n", warning)
+			logger.LogWarning("%s", warning)
+		}
 	}
-
-	*timedResults = append(*timedResults, timedResult)
-	*testNames = append(*testNames, testName)
-
-	// Display result
-	if result.Success {
-		fmt.Printf("✅ Test %d PASSED: %s\n", testNum, result.Message)
-	} else {
-		fmt.Printf("❌ Test %d FAILED: %s\n", testNum, result.Message)
+	for _, path := range probeFiles {
+		if _, err := diagnostic.LoadProbeTests(path); err != nil {
+			fmt.Printf("⚠️ %v\n", err)
+			logger.LogWarning("%v", err)
+		}
 	}
-
-	// Show verbose details if enabled
-	if verbose && len(result.Details) > 0 {
-		fmt.Printf("  Details:\n")
-		for _, detail := range result.Details {
-			fmt.Printf("    %s\n", detail)
+	for _, plugin := range diagnostic.RegisteredTests() {
+		name := plugin.Name()
+		if _, exists := availableTests[name]; exists {
+			fmt.Printf("⚠️ Plugin test '%s' has the name of a built-in test - skipping\n", name)
+			logger.LogWarning("Plugin test '%s' has the name of a built-in test - skipping", name)
+			continue
+		}
+		availableTests[name] = TestEntry{name, nil}
+		testGroups["plugins"] = append(testGroups["plugins"], name)
+		if description := plugin.Describe(); description != "" {
+			diagnostic.TestDescriptions[name] = description
 		}
+		logger.LogDebug("Registered plugin test %s", name)
 	}
-	fmt.Printf("\n")
-
-	// Clear test context
-	logger.ClearContext()
-}
-
-// executeTimedTestWithConfig is a helper function that captures timing information for tests that need configuration
-func executeTimedTestWithConfig(testNum int, testName string, testFunc func(context.Context, diagnostic.TestConfig) diagnostic.TestResult,
-	ctx context.Context, verbose bool, config diagnostic.TestConfig, timedResults *[]diagnostic.TimedTestResult, testNames *[]string) {
-
-	executeTimedTestUnified(
-		testNum,
-		testName,
-		ctx,
-		verbose,
-		timedResults,
-		testNames,
-		func() diagnostic.TestResult {
-			return testFunc(ctx, config)
-		},
-		fmt.Sprintf("Starting test with configuration: %+v", config),
-	)
-}
-
-// executeTimedTest is a helper function that captures timing information for each test
-func executeTimedTest(testNum int, testName string, testFunc func(context.Context) diagnostic.TestResult,
-	ctx context.Context, verbose bool, timedResults *[]diagnostic.TimedTestResult, testNames *[]string) {
-
-	executeTimedTestUnified(
-		testNum,
-		testName,
-		ctx,
-		verbose,
-		timedResults,
-		testNames,
-		func() diagnostic.TestResult {
-			return testFunc(ctx)
-		},
-		"Starting test",
-	)
 }
 
 func init() {
@@ -534,8 +820,77 @@ func init() {
 	testCmd.Flags().StringP("namespace", "n", "diagnostic-test", "namespace to run diagnostic tests in")
 	testCmd.Flags().String("kubeconfig", "", "path to kubeconfig file (inherits from global flag)")
 	testCmd.Flags().String("placement", "both", "pod placement strategy for pod-to-pod connectivity: same-node|cross-node|both")
-	testCmd.Flags().String("test-group", "", "run tests by group: networking (more groups coming soon)")
+	testCmd.Flags().Bool("nodeport-external-ips", false, "also probe NodePort services on node ExternalIP addresses")
+	testCmd.Flags().String("nodeport-external-probe", "", "also probe the NodePort from outside the cluster: \"local\" (the machine running the CLI) or an SSH destination such as user@bastion, which needs ssh and curl")
+	testCmd.Flags().Duration("lb-timeout", 0, "how long to wait for a LoadBalancer external IP (default 60s when a LoadBalancer implementation is detected)")
+	testCmd.Flags().Duration("lb-ready-timeout", 0, "how long the LoadBalancer external address may take to pass health checks and forward traffic (default 5m for cloud load balancers, 60s otherwise)")
+	testCmd.Flags().StringSlice("lb-probe-from", nil, "where the loadbalancer test probes the external address from: pod (a hostNetwork pod), host (the machine running the CLI) (default pod,host)")
+	testCmd.Flags().String("external-dns-zone", "", "opt in to the external-dns test, which annotates a LoadBalancer test service with a hostname in this DNS zone and waits for the record")
+	testCmd.Flags().String("external-dns-server", "", "nameserver (host[:port]) polled by the external-dns test (default: the zone's first authoritative nameserver)")
+	testCmd.Flags().Duration("external-dns-timeout", 0, "time budget for the external-dns record to resolve to the load balancer address (default 5m)")
+	testCmd.Flags().String("lb-ipam-cidr", "", "CIDR for a dedicated CiliumLoadBalancerIPPool used by cilium-lb-ipam (default: use existing pools, else 172.31.255.240/28)")
+	testCmd.Flags().String("tls-issuer", "", "cert-manager ClusterIssuer used by the tls test (default: generate a throwaway CA)")
+	testCmd.Flags().Bool("host-firewall", false, "opt in to the host-firewall test, which temporarily applies a Cilium host policy to one node")
+	testCmd.Flags().IntSlice("host-firewall-allowed-ports", nil, "node ports that must stay reachable during the host-firewall test, e.g. 10250,22 (default 10250)")
+	testCmd.Flags().Bool("hubble-verify", false, "cross-check service test HTTP requests against Hubble flows (requires Hubble)")
+	testCmd.Flags().Bool("bgp", false, "opt in to the cilium-bgp test, which checks BGP sessions and advertised routes")
+	testCmd.Flags().StringSlice("cilium-connectivity-args", nil, "extra arguments passed to `cilium connectivity test`, e.g. --test=no-policies")
+	testCmd.Flags().Bool("capture-on-failure", false, "repeat failing probes under tcpdump and save the pcaps under test_results/<run-id>/captures/ (ignored with --redact)")
+	testCmd.Flags().Bool("capture-node-interfaces", false, "with --capture-on-failure, also capture on the source and target nodes through privileged hostNetwork pods")
+	testCmd.Flags().Bool("node-info", false, "collect kernel version, forwarding/rp_filter sysctls, interface MTUs and default routes from every node via privileged pods (always done after a networking test failure)")
+	testCmd.Flags().Bool("emit-events", false, "create a Kubernetes Event in the test namespace for each test pass or fail (keeps the namespace)")
+	testCmd.Flags().Bool("summary-configmap", false, "write the run summary to the k8s-diagnostic-summary ConfigMap in the test namespace (keeps the namespace)")
+	testCmd.Flags().String("storage-class", "", "StorageClass for the storage tests (default: the cluster default StorageClass)")
+	testCmd.Flags().String("rwx-storage-class", "", "ReadWriteMany StorageClass for the pvc-rwx test (default: first StorageClass with a known shared-filesystem provisioner)")
+	testCmd.Flags().Bool("volume-expansion", false, "opt in to the pvc-expand test, which resizes a test PVC from 1Gi to 2Gi (requires allowVolumeExpansion)")
+	testCmd.Flags().Duration("cert-expiry-window", 0, "flag certificates expiring within this window in the cert-expiry test (default 720h)")
+	testCmd.Flags().Bool("skip-preflight", false, "skip the SelfSubjectAccessReview check of the permissions the selected tests need")
+	testCmd.Flags().Bool("fail-on-skip", false, "exit with code 2 when no test failed but some were skipped for unmet preconditions, e.g. too few nodes")
+	testCmd.Flags().Bool("fail-on-warning", false, "exit with code 3 when no test failed but some passed with warnings, e.g. packet loss or a redirect (takes precedence over --fail-on-skip)")
+	testCmd.Flags().Bool("hpa", false, "opt in to the hpa-scaling test, which drives CPU load against a test deployment (requires metrics-server)")
+	testCmd.Flags().Int("pod-churn-count", 0, "pods per wave in the pod-churn test (default 100)")
+	testCmd.Flags().Int("canary-requests", 0, "requests sent through each routing path in the weighted-routing test (default 200)")
+	testCmd.Flags().Duration("inject-latency", 0, "opt in to the fault-latency test, which adds this delay with tc netem in a test pod, e.g. 50ms")
+	testCmd.Flags().Float64("inject-loss", 0, "opt in to the fault-loss test, which drops this percentage of a test pod's egress packets with tc netem, e.g. 10")
+	testCmd.Flags().Bool("node-isolation", false, "opt in to the node-isolation test, which temporarily cordons a worker node and isolates a test backend on it")
+	testCmd.Flags().String("dns-failure", "", "opt in to the dns-failure test: \"block\" blocks DNS for a test pod with a NetworkPolicy, \"coredns\" temporarily scales CoreDNS to zero (cluster-wide outage)")
+	testCmd.Flags().Bool("cni-restart", false, "opt in to the cni-restart test, which deletes the CNI agent pod on one node during a continuous ping")
+	testCmd.Flags().DurationSlice("idle-timeouts", nil, "opt in to the idle-timeout test with these idle periods for connections through a ClusterIP, e.g. 30s,5m,15m (extends the run timeout)")
+	testCmd.Flags().Duration("timeout", 0, "time budget of the tests; tests not started when it runs out are reported as not run (default: the sum of the selected tests' budgets, 3m per test plus their configured waits)")
+	testCmd.Flags().Int("ip-free-threshold", 0, "free pod addresses below which the ip-exhaustion test flags a node (default 10)")
+	testCmd.Flags().Int("ping-count", 0, "echo requests per ping probe in the pod-to-pod test (default 3)")
+	testCmd.Flags().Duration("ping-interval", 0, "interval between echo requests of the ping probe, e.g. 200ms (default 1s)")
+	testCmd.Flags().Int("ping-size", 0, "payload bytes of the ping probe, e.g. 1400 to probe close to the MTU (default 56)")
+	testCmd.Flags().Duration("ping-deadline", 0, "overall time limit of one ping probe, e.g. 30s (default: none)")
+	testCmd.Flags().String("ping-interface", "", "source interface or address of the ping probe in the client pod (default: routed)")
+	testCmd.Flags().Duration("ping-warn-latency", 0, "average ping round trip above which the pod-to-pod test passes with a warning, e.g. 5ms (default: none)")
+	testCmd.Flags().String("http-scheme", "", "scheme of the HTTP probe of the service tests: http or https (default http; certificates are not verified)")
+	testCmd.Flags().Int("http-port", 0, "port of the HTTP probe for targets without one, e.g. service names (default: the scheme's port)")
+	testCmd.Flags().String("http-path", "", "path requested by the HTTP probe (default /)")
+	testCmd.Flags().String("http-method", "", "method of the HTTP probe (default GET)")
+	testCmd.Flags().StringArray("http-header", nil, "extra header of the HTTP probe as \"Name: value\", repeatable")
+	testCmd.Flags().Duration("http-connect-timeout", 0, "connect timeout of the HTTP probe (default 3s)")
+	testCmd.Flags().Duration("http-timeout", 0, "total timeout of one HTTP probe request (default 5s)")
+	testCmd.Flags().Bool("http-follow-redirects", false, "follow redirects in the HTTP probe and judge the final status")
+	testCmd.Flags().IntSlice("http-expected-status", nil, "status codes that pass the HTTP probe, e.g. 200,301 (default: any 2xx)")
+	testCmd.Flags().StringArray("image-mirror", nil, "pull an upstream image, repository or registry from a mirror as \"upstream=mirror\", e.g. \"nicolaka/netshoot=registry.internal/netshoot\", repeatable")
+	for _, name := range []string{"ping-count", "ping-interval", "ping-size", "ping-deadline", "ping-interface", "ping-warn-latency",
+		"http-scheme", "http-port", "http-path", "http-method", "http-header", "http-connect-timeout", "http-timeout",
+		"http-follow-redirects", "http-expected-status", "image-mirror"} {
+		viper.BindPFlag(name, testCmd.Flags().Lookup(name))
+	}
+	testCmd.Flags().Bool("ignore-dependencies", false, "run every selected test even when a prerequisite test failed earlier in the run")
+	testCmd.Flags().String("plugin-dir", "", "directory of executable test plugins, registered as tests in the plugins group (default $HOME/.k8s-diagnostic/plugins)")
+	testCmd.Flags().Bool("isolated-fixtures", false, "give every service and DNS test its own nginx deployment and netshoot client instead of sharing them across the run")
+	testCmd.Flags().StringSlice("probe-file", nil, "YAML files declaring probe tests (image, command, expected exit code and output), registered in the plugins group")
+	testCmd.Flags().String("cluster-name", "", "name of the cluster in the report, used by report merge to group runs (default: the kubeconfig's current context)")
+	testCmd.Flags().String("report-url", "", "POST run progress events and the final report as JSON to this URL, e.g. a server collecting runs (with --redact only the redacted report is sent)")
+	testCmd.Flags().String("persist-namespace", "", "also write the report into a ConfigMap in this namespace, keeping the run history in the cluster")
+	testCmd.Flags().Int("persist-keep", 10, "number of persisted runs to keep with --persist-namespace (0 keeps all)")
+	testCmd.Flags().Bool("redact", false, "mask node names, namespace names and IP addresses in the JSON report so it can be shared externally")
+	testCmd.Flags().String("redact-key", "", "key for the redaction tokens (default: derived from the cluster, so tokens match across reports and bundles)")
+	testCmd.Flags().String("test-group", "", "run tests by group: networking, policies, cilium, calico, protocols, firewall, netpol, mesh, mesh-policies, integration, storage, control-plane, workload, chaos, plugins")
 	testCmd.Flags().Bool("keep-namespace", false, "keep the test namespace after tests complete (useful for running multiple test sequences)")
-	testCmd.Flags().StringSlice("test-list", nil, "comma-separated list of tests to run: pod-to-pod,service-to-pod,cross-node,dns,nodeport,loadbalancer")
+	testCmd.Flags().StringSlice("test-list", nil, "comma-separated list of tests to run: "+strings.Join(builtinTestNames(), ","))
 	// Removed the simulated failure flag as we now use actual Cilium misconfiguration via routing mode
 }
@@ -1,4 +1,4 @@
-module k8s-diagnostic
+module github.com/parlakisik/k8s_diagnostic
 
 go 1.21
 
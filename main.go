@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"k8s-diagnostic/cmd"
+	"github.com/parlakisik/k8s_diagnostic/cmd"
 )
 
 func main() {
@@ -12,4 +12,5 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	os.Exit(cmd.ExitCode())
 }
@@ -0,0 +1,353 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// apiLatencySamples is the number of timed requests per operation
+	apiLatencySamples = 20
+	// apiLatencyWatchSamples is the number of watch event deliveries timed; each one creates and deletes a ConfigMap
+	apiLatencyWatchSamples = 10
+	// apiLatencyObject is the small ConfigMap read by the GET and LIST measurements
+	apiLatencyObject = "apiserver-latency-probe"
+)
+
+// apiLatencyThresholds are the p99 limits per operation: above warn is flagged as unusual, above fail exceeds the
+// upstream API server latency SLOs (1s for single objects, 5s for namespaced LISTs)
+var apiLatencyThresholds = map[string]struct{ warn, fail time.Duration }{
+	"GET":   {200 * time.Millisecond, time.Second},
+	"LIST":  {500 * time.Millisecond, 5 * time.Second},
+	"WATCH": {500 * time.Millisecond, time.Second},
+}
+
+// latencyPercentile returns the p-th percentile (0-100) of the samples using the nearest-rank method
+func latencyPercentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// apiLatencySeries holds the samples of one operation measured from one vantage point
+type apiLatencySeries struct {
+	Source    string // "tool" or "pod"
+	Operation string // GET, LIST or WATCH
+	Samples   []time.Duration
+}
+
+// unlimitedClientset returns a clientset without client-side rate limiting, so the default 5 QPS throttle does not
+// show up as API server latency
+func (t *Tester) unlimitedClientset() (*kubernetes.Clientset, error) {
+	config := rest.CopyConfig(t.config)
+	config.QPS = -1
+	return kubernetes.NewForConfig(config)
+}
+
+// measureToolAPILatency times GET and LIST of the probe ConfigMap and the delivery of watch events for created
+// ConfigMaps, from the machine running the tool
+func (t *Tester) measureToolAPILatency(ctx context.Context, clientset *kubernetes.Clientset) ([]apiLatencySeries, error) {
+	configMaps := clientset.CoreV1().ConfigMaps(t.namespace)
+	get := apiLatencySeries{Source: "tool", Operation: "GET"}
+	list := apiLatencySeries{Source: "tool", Operation: "LIST"}
+	// The first request establishes the connection and is not counted
+	if _, err := configMaps.Get(ctx, apiLatencyObject, metav1.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("warm-up GET failed: %v", err)
+	}
+	for i := 0; i < apiLatencySamples; i++ {
+		start := time.Now()
+		if _, err := configMaps.Get(ctx, apiLatencyObject, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("GET configmap %s failed: %v", apiLatencyObject, err)
+		}
+		get.Samples = append(get.Samples, time.Since(start))
+
+		start = time.Now()
+		if _, err := configMaps.List(ctx, metav1.ListOptions{}); err != nil {
+			return nil, fmt.Errorf("LIST configmaps failed: %v", err)
+		}
+		list.Samples = append(list.Samples, time.Since(start))
+		sleepContext(ctx, 50*time.Millisecond)
+	}
+
+	watchSeries := apiLatencySeries{Source: "tool", Operation: "WATCH"}
+	current, err := configMaps.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("LIST configmaps failed: %v", err)
+	}
+	watcher, err := configMaps.Watch(ctx, metav1.ListOptions{ResourceVersion: current.ResourceVersion})
+	if err != nil {
+		return nil, fmt.Errorf("WATCH configmaps failed: %v", err)
+	}
+	defer watcher.Stop()
+	for i := 0; i < apiLatencyWatchSamples; i++ {
+		name := fmt.Sprintf("apiserver-latency-watch-%d", i)
+		start := time.Now()
+		if _, err := configMaps.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name}}, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create configmap %s: %v", name, err)
+		}
+		delivered := false
+		timeout := time.After(10 * time.Second)
+		for !delivered {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					return nil, fmt.Errorf("watch closed before the event for %s was delivered", name)
+				}
+				if configMap, isConfigMap := event.Object.(*corev1.ConfigMap); isConfigMap && event.Type == watch.Added && configMap.Name == name {
+					watchSeries.Samples = append(watchSeries.Samples, time.Since(start))
+					delivered = true
+				}
+			case <-timeout:
+				return nil, fmt.Errorf("watch event for %s not delivered within 10s", name)
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		configMaps.Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	return []apiLatencySeries{get, list, watchSeries}, nil
+}
+
+// grantAPILatencyReader lets the namespace's default service account read ConfigMaps, so the in-cluster
+// measurement exercises the same requests as the tool
+func (t *Tester) grantAPILatencyReader(ctx context.Context) error {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "apiserver-latency-reader", Namespace: t.namespace},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"configmaps"},
+			Verbs:     []string{"get", "list"},
+		}},
+	}
+	if _, err := t.clientset.RbacV1().Roles(t.namespace).Create(ctx, role, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "apiserver-latency-reader", Namespace: t.namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: role.Name},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "default", Namespace: t.namespace}},
+	}
+	_, err := t.clientset.RbacV1().RoleBindings(t.namespace).Create(ctx, binding, metav1.CreateOptions{})
+	return err
+}
+
+// measurePodAPILatency times GET and LIST of the probe ConfigMap with curl from a pod, over one reused connection
+// to kubernetes.default.svc with the pod's service account token
+func (t *Tester) measurePodAPILatency(ctx context.Context, podName string) ([]apiLatencySeries, CommandOutput, error) {
+	base := fmt.Sprintf("https://kubernetes.default.svc/api/v1/namespaces/%s/configmaps", t.namespace)
+	var urls []string
+	// The first URL of each batch is the connection warm-up and is dropped
+	for i := 0; i <= apiLatencySamples; i++ {
+		urls = append(urls, fmt.Sprintf("-o /dev/null %s/%s", base, apiLatencyObject))
+	}
+	for i := 0; i <= apiLatencySamples; i++ {
+		urls = append(urls, fmt.Sprintf("-o /dev/null %s", base))
+	}
+	script := fmt.Sprintf(`TOKEN=$(cat /var/run/secrets/kubernetes.io/serviceaccount/token); `+
+		`curl -sk --fail -H "Authorization: Bearer $TOKEN" -w '%%{http_code} %%{time_total}\n' %s`, strings.Join(urls, " "))
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"sh", "-c", script},
+		"Time GET and LIST of a ConfigMap from inside the cluster")
+	if err != nil {
+		return nil, output, fmt.Errorf("in-cluster requests failed: %v", err)
+	}
+
+	get := apiLatencySeries{Source: "pod", Operation: "GET"}
+	list := apiLatencySeries{Source: "pod", Operation: "LIST"}
+	for i, line := range strings.Split(strings.TrimSpace(output.Stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "200" {
+			return nil, output, fmt.Errorf("unexpected curl result %q", line)
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, output, fmt.Errorf("unexpected curl timing %q", line)
+		}
+		sample := time.Duration(seconds * float64(time.Second))
+		switch {
+		case i == 0 || i == apiLatencySamples+1:
+		case i <= apiLatencySamples:
+			get.Samples = append(get.Samples, sample)
+		default:
+			list.Samples = append(list.Samples, sample)
+		}
+	}
+	if len(get.Samples) != apiLatencySamples || len(list.Samples) != apiLatencySamples {
+		return nil, output, fmt.Errorf("expected %d GET and LIST timings, got %d and %d", apiLatencySamples, len(get.Samples), len(list.Samples))
+	}
+	return []apiLatencySeries{get, list}, output, nil
+}
+
+// TestAPIServerLatency measures GET, LIST and WATCH latency of small objects from the tool and GET and LIST from a
+// pod, reporting p50/p90/p99 and flagging latency above the usual range or the upstream SLOs
+func (t *Tester) TestAPIServerLatency(ctx context.Context) TestResult {
+	var details []string
+	podName := "netshoot-apiserver-latency"
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().ConfigMaps(t.namespace).Delete(ctx, apiLatencyObject, metav1.DeleteOptions{})
+		t.clientset.RbacV1().RoleBindings(t.namespace).Delete(ctx, "apiserver-latency-reader", metav1.DeleteOptions{})
+		t.clientset.RbacV1().Roles(t.namespace).Delete(ctx, "apiserver-latency-reader", metav1.DeleteOptions{})
+	}()
+
+	clientset, err := t.unlimitedClientset()
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create API client: %v", err),
+			Details: details,
+		}
+	}
+
+	// Step 1: Create the probe ConfigMap and the in-cluster client pod
+	probe := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: apiLatencyObject, Namespace: t.namespace},
+		Data:       map[string]string{"probe": "k8s-diagnostic"},
+	}
+	if _, err := t.clientset.CoreV1().ConfigMaps(t.namespace).Create(ctx, probe, metav1.CreateOptions{}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create probe ConfigMap: %v", err),
+			Details: details,
+		}
+	}
+	podAvailable := true
+	if err := t.grantAPILatencyReader(ctx); err != nil {
+		podAvailable = false
+		details = append(details, fmt.Sprintf("ℹ️ In-cluster measurement skipped - could not grant the service account ConfigMap read access: %v", err))
+	} else if _, err := t.createNetshootPod(ctx, podName, ""); err != nil {
+		podAvailable = false
+		details = append(details, fmt.Sprintf("ℹ️ In-cluster measurement skipped - failed to create pod: %v", err))
+	}
+
+	// Step 2: Measure from the tool while the pod starts
+	series, err := t.measureToolAPILatency(ctx, clientset)
+	if err != nil {
+		details = append(details, fmt.Sprintf("✗ %v", err))
+		return TestResult{
+			Success: false,
+			Message: "API server requests from the tool failed",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Tool API Requests",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"Check API server health: kubectl get --raw='/readyz?verbose'",
+					"Failing watches can come from a load balancer or proxy in front of the API server closing long-lived connections",
+				},
+			},
+		}
+	}
+
+	// Step 3: Measure from inside the cluster
+	var commandOutputs []CommandOutput
+	if podAvailable {
+		if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ In-cluster measurement skipped - pod not ready: %v", err))
+		} else {
+			podSeries, output, err := t.measurePodAPILatency(ctx, podName)
+			commandOutputs = append(commandOutputs, output)
+			if err != nil {
+				details = append(details, fmt.Sprintf("⚠️ In-cluster measurement failed: %v", err))
+			} else {
+				series = append(series, podSeries...)
+			}
+		}
+	}
+
+	// Step 4: Report percentiles and compare against the thresholds
+	metrics := map[string]float64{}
+	var failures, warnings []string
+	p50 := map[string]time.Duration{}
+	details = append(details, fmt.Sprintf("  %-12s %-8s %10s %10s %10s", "SOURCE", "VERB", "P50", "P90", "P99"))
+	for _, s := range series {
+		p50[s.Source+s.Operation] = latencyPercentile(s.Samples, 50)
+		p90 := latencyPercentile(s.Samples, 90)
+		p99 := latencyPercentile(s.Samples, 99)
+		details = append(details, fmt.Sprintf("  %-12s %-8s %10v %10v %10v", s.Source, s.Operation,
+			p50[s.Source+s.Operation].Round(100*time.Microsecond), p90.Round(100*time.Microsecond), p99.Round(100*time.Microsecond)))
+		prefix := fmt.Sprintf("%s.%s", s.Source, strings.ToLower(s.Operation))
+		metrics[prefix+".p50_ms"] = float64(p50[s.Source+s.Operation].Microseconds()) / 1000
+		metrics[prefix+".p90_ms"] = float64(p90.Microseconds()) / 1000
+		metrics[prefix+".p99_ms"] = float64(p99.Microseconds()) / 1000
+
+		threshold := apiLatencyThresholds[s.Operation]
+		switch {
+		case p99 > threshold.fail:
+			failures = append(failures, fmt.Sprintf("%s %s p99 %v exceeds the %v SLO", s.Source, s.Operation, p99.Round(time.Millisecond), threshold.fail))
+		case p99 > threshold.warn:
+			warnings = append(warnings, fmt.Sprintf("%s %s p99 %v is above the usual %v", s.Source, s.Operation, p99.Round(time.Millisecond), threshold.warn))
+		case p99 > 5*p50[s.Source+s.Operation] && p99 > 100*time.Millisecond:
+			warnings = append(warnings, fmt.Sprintf("%s %s has a long tail: p99 %v vs p50 %v", s.Source, s.Operation, p99.Round(time.Millisecond), p50[s.Source+s.Operation].Round(time.Millisecond)))
+		}
+	}
+	for _, warning := range warnings {
+		details = append(details, fmt.Sprintf("⚠️ Unusual latency: %s", warning))
+	}
+	// The typed latency is the tool's single-object GET, the series the success message reports
+	typedMetrics := &TestMetrics{Latency: latencyMetrics(series[0].Samples)}
+	// Much slower requests from the tool than from a pod point at the path to the API server rather than the server
+	if pod, ok := p50["podGET"]; ok && p50["toolGET"] > 4*pod && p50["toolGET"]-pod > 50*time.Millisecond {
+		details = append(details, fmt.Sprintf("ℹ️ GET from the tool (p50 %v) is much slower than from a pod (p50 %v) - the latency is on the network path to the API server, not the server itself",
+			p50["toolGET"].Round(time.Millisecond), pod.Round(time.Millisecond)))
+	}
+
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			details = append(details, fmt.Sprintf("✗ %s", failure))
+		}
+		return TestResult{
+			Success:      false,
+			Message:      fmt.Sprintf("API server latency exceeds SLOs: %s", strings.Join(failures, "; ")),
+			Details:      details,
+			Metrics:      metrics,
+			TypedMetrics: typedMetrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "API Server Latency",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check API server request latency by verb and resource: kubectl get --raw /metrics | grep apiserver_request_duration_seconds",
+					"Slow requests from a pod as well as the tool point at the API server or etcd - check etcd disk latency (etcd_disk_wal_fsync_duration_seconds)",
+					"Check for API Priority and Fairness queuing: kubectl get --raw /metrics | grep apiserver_flowcontrol_request_wait_duration_seconds",
+					"Large numbers of objects or heavy LIST clients (controllers without informers) slow every request",
+				},
+			},
+		}
+	}
+
+	message := fmt.Sprintf("API server latency within SLOs (tool GET p99 %v)", latencyPercentile(series[0].Samples, 99).Round(time.Millisecond))
+	if len(warnings) > 0 {
+		message = fmt.Sprintf("API server latency within SLOs with %d unusual measurements", len(warnings))
+	}
+	return TestResult{
+		Success:      true,
+		Message:      message,
+		Details:      details,
+		Metrics:      metrics,
+		TypedMetrics: typedMetrics,
+		Warnings:     warnings,
+	}
+}
@@ -0,0 +1,190 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// konnectivityAgentSelectors find the agents that tunnel API server traffic to the nodes when the control plane is
+// not on the node network, as in most managed clusters
+var konnectivityAgentSelectors = []string{"k8s-app=konnectivity-agent", "app=konnectivity-agent"}
+
+// apiServerProxyResult is the outcome of one request through the API server proxy subresource
+type apiServerProxyResult struct {
+	Path     string
+	OK       bool
+	Duration time.Duration
+	Error    string
+}
+
+// proxyGet requests / on a service or pod through the API server proxy subresource
+func (t *Tester) proxyGet(ctx context.Context, resource, name, port string) apiServerProxyResult {
+	result := apiServerProxyResult{Path: fmt.Sprintf("/api/v1/namespaces/%s/%s/http:%s:%s/proxy/", t.namespace, resource, name, port)}
+	t.steps.recordCommand(shellJoin("kubectl", "get", "--raw", result.Path))
+	start := time.Now()
+	var err error
+	if resource == "services" {
+		_, err = t.clientset.CoreV1().Services(t.namespace).ProxyGet("http", name, port, "/", nil).DoRaw(ctx)
+	} else {
+		_, err = t.clientset.CoreV1().Pods(t.namespace).ProxyGet("http", name, port, "/", nil).DoRaw(ctx)
+	}
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Error = proxyErrorText(err)
+		return result
+	}
+	result.OK = true
+	return result
+}
+
+// proxyErrorText shortens the error of a proxy request to the API server's message, e.g. the failed dial
+func proxyErrorText(err error) string {
+	if status, ok := err.(apierrors.APIStatus); ok {
+		message := strings.TrimSpace(status.Status().Message)
+		if message == "" {
+			message = string(status.Status().Reason)
+		}
+		return fmt.Sprintf("HTTP %d: %s", status.Status().Code, message)
+	}
+	return err.Error()
+}
+
+// konnectivityAgents counts the konnectivity agents and returns their namespace
+func (t *Tester) konnectivityAgents(ctx context.Context) (int, string) {
+	for _, selector := range konnectivityAgentSelectors {
+		pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err == nil && len(pods.Items) > 0 {
+			return len(pods.Items), pods.Items[0].Namespace
+		}
+	}
+	return 0, ""
+}
+
+// TestAPIServerProxy requests the test service and one of its pods through the API server proxy subresource, the
+// API server to node to pod path dashboards and addons use, and compares it with direct access to the service from a
+// pod so a broken proxy path is told apart from a broken service
+func (t *Tester) TestAPIServerProxy(ctx context.Context) TestResult {
+	var details []string
+	deploymentName := "web-apiserver-proxy"
+	serviceName := "web-apiserver-proxy"
+	testPodName := "netshoot-apiserver-proxy"
+
+	fail := func(stage, message string, hints ...string) TestResult {
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       message,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 1: Create the backend, its service and a client pod
+	deploymentName, reused, err := t.nginxBackend(ctx, deploymentName)
+	if err != nil {
+		return fail("Backend Setup", fmt.Sprintf("Failed to create nginx deployment: %v", err))
+	}
+	details = append(details, fmt.Sprintf("✓ %s nginx deployment '%s'", fixtureAction(reused), deploymentName))
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		return fail("Backend Setup", fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err))
+	}
+	if _, err := t.createNginxService(ctx, serviceName, deploymentName); err != nil {
+		return fail("Service Creation", fmt.Sprintf("Failed to create service: %v", err))
+	}
+	details = append(details, fmt.Sprintf("✓ Created service '%s'", serviceName))
+	testPodName, reused, err = t.netshootClient(ctx, testPodName, "")
+	if err != nil {
+		return fail("Pod Creation", fmt.Sprintf("Failed to create test pod: %v", err))
+	}
+	if err := t.waitForPodReady(ctx, testPodName, 120*time.Second); err != nil {
+		return fail("Pod Readiness", fmt.Sprintf("Test pod did not become ready: %v", err))
+	}
+	details = append(details, fmt.Sprintf("✓ %s test pod '%s'", fixtureAction(reused), testPodName))
+
+	// Step 2: Direct access from a pod, the reference for the proxy path
+	directOK, directMessage := false, ""
+	statusCode, _, err := t.testHTTPConnectivityWithStatusCode(ctx, testPodName, serviceName, HTTPOptions{})
+	if err != nil {
+		directMessage = err.Error()
+	} else {
+		directOK, directMessage = evaluateHTTPStatusCode(statusCode)
+	}
+	if directOK {
+		details = append(details, fmt.Sprintf("✓ Direct access from pod to service %s: %s", serviceName, directMessage))
+	} else {
+		details = append(details, fmt.Sprintf("✗ Direct access from pod to service %s: %s", serviceName, directMessage))
+	}
+
+	// Step 3: The same service and one backend pod through the API server
+	backend := ""
+	pods, err := t.clientset.CoreV1().Pods(t.namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", deploymentName)})
+	if err == nil && len(pods.Items) > 0 {
+		backend = pods.Items[0].Name
+	}
+	serviceProxy := t.proxyGet(ctx, "services", serviceName, "80")
+	results := []apiServerProxyResult{serviceProxy}
+	if backend != "" {
+		results = append(results, t.proxyGet(ctx, "pods", backend, "80"))
+	}
+	metrics := map[string]float64{}
+	for _, result := range results {
+		if result.OK {
+			details = append(details, fmt.Sprintf("✓ API server proxy %s answered in %s", result.Path, result.Duration.Round(time.Millisecond)))
+		} else {
+			details = append(details, fmt.Sprintf("✗ API server proxy %s failed after %s: %s", result.Path, result.Duration.Round(time.Millisecond), result.Error))
+		}
+	}
+	if serviceProxy.OK {
+		metrics["service_proxy_ms"] = float64(serviceProxy.Duration.Milliseconds())
+	}
+	if agents, namespace := t.konnectivityAgents(ctx); agents > 0 {
+		details = append(details, fmt.Sprintf("ℹ️ API server reaches the nodes through %d konnectivity agent(s) in %s", agents, namespace))
+	}
+
+	proxyOK := true
+	var proxyErrors []string
+	for _, result := range results {
+		proxyOK = proxyOK && result.OK
+		if !result.OK {
+			proxyErrors = append(proxyErrors, fmt.Sprintf("%s: %s", result.Path, result.Error))
+		}
+	}
+
+	// Step 4: Tell which path is broken
+	switch {
+	case directOK && !proxyOK:
+		return fail("API Server Proxy", fmt.Sprintf("Service works from pods but not through the API server proxy: %s", strings.Join(proxyErrors, "; ")),
+			"The API server dials pod IPs itself; it needs a route to the pod network, or konnectivity/SSH tunnels on clusters where the control plane is outside it",
+			"A dial timeout usually means a firewall between the control plane and the nodes, e.g. a security group missing the pod CIDR or the kubelet port",
+			"Check the konnectivity server and agents when used: kubectl logs -n kube-system -l k8s-app=konnectivity-agent",
+			"A 403 means RBAC does not allow get on services/proxy or pods/proxy")
+	case !directOK && proxyOK:
+		return fail("Direct Service Access", fmt.Sprintf("Service answers through the API server proxy but not from pods: %s", directMessage),
+			"The backend is healthy (the API server reached it), so the in-cluster service datapath is broken",
+			"Check kube-proxy or the CNI service implementation, e.g. with the service-to-pod test",
+			fmt.Sprintf("Check the service endpoints: kubectl get endpointslices -n %s -l kubernetes.io/service-name=%s", t.namespace, serviceName))
+	case !directOK && !proxyOK:
+		return fail("Backend Reachability", fmt.Sprintf("Service unreachable both from pods (%s) and through the API server proxy", directMessage),
+			fmt.Sprintf("Check the backend pods are Ready: kubectl get pods -n %s -l app=%s", t.namespace, deploymentName),
+			"A 503 'no endpoints available' from the proxy means the service has no ready endpoints")
+	}
+
+	t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+	details = append(details, "✓ Cleaned up API server proxy test resources")
+
+	return TestResult{
+		Success: true,
+		Message: "API server proxy path works - service and pod reachable through the API server as well as directly",
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,295 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// registryTimeout bounds each request to an image registry when verifying image platforms
+const registryTimeout = 15 * time.Second
+
+// probeImages are the images the built-in tests run on Linux nodes
+var probeImages = []string{netshootImage, nginxImage, upgradeEchoImage, schedulingTestImage, hpaBurnerImage, crossOSServerImage}
+
+// registryManifestTypes are the manifest media types requested from a registry, image indexes first
+var registryManifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// nodeArch returns the CPU architecture of a node from its kubernetes.io/arch label, or from what the kubelet reports
+func nodeArch(node corev1.Node) string {
+	if arch := node.Labels[corev1.LabelArchStable]; arch != "" {
+		return arch
+	}
+	return node.Status.NodeInfo.Architecture
+}
+
+// imageReference is an image split into the registry host, repository and tag or digest
+type imageReference struct {
+	Registry   string
+	Repository string
+	Reference  string
+}
+
+// parseImageReference applies Docker's defaults: Docker Hub for names without a registry host, library/ for
+// official images and the latest tag
+func parseImageReference(image string) imageReference {
+	ref := imageReference{Registry: "registry-1.docker.io", Reference: "latest"}
+	name := image
+	if before, digest, ok := strings.Cut(name, "@"); ok {
+		name, ref.Reference = before, digest
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Reference = name[:i], name[i+1:]
+	}
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, name = first, rest
+		if ref.Registry == "docker.io" {
+			ref.Registry = "registry-1.docker.io"
+		}
+	}
+	if ref.Registry == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = name
+	return ref
+}
+
+// registryGet fetches a registry path, answering a Bearer challenge with an anonymous token
+func registryGet(ctx context.Context, client *http.Client, ref imageReference, path string, accept []string) ([]byte, error) {
+	token := ""
+	for attempt := 0; attempt < 2; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/%s", ref.Registry, ref.Repository, path), nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Accept", strings.Join(accept, ", "))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(response.Body, 4<<20))
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case response.StatusCode == http.StatusOK:
+			return body, nil
+		case response.StatusCode == http.StatusUnauthorized && token == "":
+			token, err = registryToken(ctx, client, response.Header.Get("Www-Authenticate"))
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%s/%s: HTTP %d", ref.Registry, ref.Repository, response.StatusCode)
+		}
+	}
+	return nil, fmt.Errorf("%s/%s: anonymous pull not allowed", ref.Registry, ref.Repository)
+}
+
+// registryToken requests an anonymous pull token from the realm of a Bearer challenge
+func registryToken(ctx context.Context, client *http.Client, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported registry authentication %q", scheme)
+	}
+	values := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok {
+			values[key] = strings.Trim(value, `"`)
+		}
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("registry challenge without a realm")
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("registry token: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return token.Token, nil
+}
+
+// imagePlatforms returns the os/arch pairs an image is published for, from its index or, for a single-platform
+// image, from its config
+func imagePlatforms(ctx context.Context, image string) ([]string, error) {
+	client := &http.Client{Timeout: registryTimeout}
+	ref := parseImageReference(image)
+	body, err := registryGet(ctx, client, ref, "manifests/"+ref.Reference, registryManifestTypes)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Manifests []struct {
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: invalid manifest: %v", image, err)
+	}
+	var platforms []string
+	for _, entry := range manifest.Manifests {
+		// Attestation manifests are listed with platform unknown/unknown
+		if entry.Platform.OS != "" && entry.Platform.OS != "unknown" {
+			platforms = append(platforms, entry.Platform.OS+"/"+entry.Platform.Architecture)
+		}
+	}
+	if len(manifest.Manifests) > 0 || manifest.Config.Digest == "" {
+		return platforms, nil
+	}
+	body, err = registryGet(ctx, client, ref, "blobs/"+manifest.Config.Digest, []string{"*/*"})
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, fmt.Errorf("%s: invalid image config: %v", image, err)
+	}
+	return []string{config.OS + "/" + config.Architecture}, nil
+}
+
+// NodeArchitectures counts the Linux nodes per CPU architecture
+func (t *Tester) NodeArchitectures(ctx context.Context) (map[string]int, error) {
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	architectures := map[string]int{}
+	for _, node := range nodes.Items {
+		if !isWindowsNode(node) {
+			architectures[nodeArch(node)]++
+		}
+	}
+	return architectures, nil
+}
+
+// CheckImageArchitectures verifies against their registries that the probe images are published for every
+// architecture of the cluster's Linux nodes and describes the gaps. Clusters of amd64 nodes only are not checked,
+// as every probe image is built for amd64; registries that cannot be reached are reported as unverified.
+func (t *Tester) CheckImageArchitectures(ctx context.Context, architectures map[string]int) []string {
+	var required []string
+	for arch := range architectures {
+		if arch != "amd64" {
+			required = append(required, arch)
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+	sort.Strings(required)
+	var gaps, unverified []string
+	var lastErr error
+	for _, upstream := range probeImages {
+		image := t.image(upstream)
+		platforms, err := imagePlatforms(ctx, image)
+		if err != nil {
+			unverified, lastErr = append(unverified, image), err
+			continue
+		}
+		var missing []string
+		for _, arch := range required {
+			found := false
+			for _, platform := range platforms {
+				found = found || platform == "linux/"+arch
+			}
+			if !found {
+				missing = append(missing, arch)
+			}
+		}
+		if len(missing) > 0 {
+			gaps = append(gaps, fmt.Sprintf("%s has no linux/%s variant (published for %s) - tests running it on %s nodes will fail",
+				image, strings.Join(missing, ", linux/"), strings.Join(platforms, ", "), strings.Join(missing, "/")))
+		}
+	}
+	if len(unverified) > 0 {
+		gaps = append(gaps, fmt.Sprintf("could not verify the %s platforms of %s: %v", strings.Join(required, "/"), strings.Join(unverified, ", "), lastErr))
+	}
+	return gaps
+}
+
+// imageArchitectureError recognizes a container that cannot run because its image has no variant for the node's
+// architecture - a pull error naming the platform, or an "exec format error" from the entrypoint - which otherwise
+// shows up as a generic readiness timeout
+func (t *Tester) imageArchitectureError(ctx context.Context, pod *corev1.Pod) error {
+	for _, status := range pod.Status.ContainerStatuses {
+		mismatch := false
+		if waiting := status.State.Waiting; waiting != nil && (waiting.Reason == "ErrImagePull" || waiting.Reason == "ImagePullBackOff") {
+			message := strings.ToLower(waiting.Message)
+			mismatch = strings.Contains(message, "no matching manifest") || strings.Contains(message, "no match for platform")
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if !mismatch && terminated != nil && terminated.ExitCode != 0 {
+			logs, err := t.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: status.Name,
+				Previous:  status.State.Terminated == nil,
+			}).DoRaw(ctx)
+			mismatch = err == nil && strings.Contains(string(logs), "exec format error")
+		}
+		if !mismatch {
+			continue
+		}
+		arch := "unknown"
+		if node, err := t.clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{}); err == nil {
+			arch = nodeArch(*node)
+		}
+		image := status.Image
+		for _, container := range pod.Spec.Containers {
+			if container.Name == status.Name {
+				image = container.Image
+			}
+		}
+		return fmt.Errorf("pod %s cannot run on node %s: image %s has no linux/%s variant - use a multi-arch image or keep the test off %s nodes",
+			pod.Name, pod.Spec.NodeName, image, arch, arch)
+	}
+	return nil
+}
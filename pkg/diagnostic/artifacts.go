@@ -0,0 +1,209 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultResultsDir holds one directory per run
+	defaultResultsDir = "test_results"
+	// Fixed names of the artifacts inside a run directory
+	runReportFile   = "report.json"
+	runHTMLFile     = "report.html"
+	runLogFile      = "run.log"
+	runIndexFile    = "index.json"
+	runCapturesDir  = "captures"
+	runManifestsDir = "manifests"
+)
+
+// runArtifactDescriptions describe the files of a run directory by name or, for directories, by prefix
+var runArtifactDescriptions = map[string]string{
+	runReportFile:         "JSON test report",
+	runHTMLFile:           "HTML test report",
+	runLogFile:            "Test run log",
+	runCapturesDir + "/":  "Packet capture of a failing probe",
+	runManifestsDir + "/": "Test namespace objects at the end of the run",
+	"":                    "Run artifact",
+}
+
+// RunArtifacts is the directory test_results/<run-id>/ holding everything a run writes: the JSON and HTML reports,
+// the log, packet captures and the test namespace manifests, listed in an index.json, so one folder can be archived
+// or uploaded per run
+type RunArtifacts struct {
+	ID  string // run ID, the start time of the run, e.g. 20250710-145139
+	Dir string // run directory
+}
+
+// RunIndex is written to index.json in the run directory
+type RunIndex struct {
+	RunID         string        `json:"run_id"`
+	CreatedAt     string        `json:"created_at"`
+	Namespace     string        `json:"namespace"`
+	OverallStatus string        `json:"overall_status,omitempty"`
+	Entries       []BundleEntry `json:"entries"`
+}
+
+// NewRunArtifacts creates the directory of a run started at start under baseDir (test_results when empty). Runs
+// started in the same second get a numeric suffix.
+func NewRunArtifacts(baseDir string, start time.Time) (*RunArtifacts, error) {
+	if baseDir == "" {
+		baseDir = defaultResultsDir
+	}
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %v", baseDir, err)
+	}
+	id := start.Format("20060102-150405")
+	for attempt := 2; ; attempt++ {
+		dir := filepath.Join(baseDir, id)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return &RunArtifacts{ID: id, Dir: dir}, nil
+		}
+		if !os.IsExist(err) || attempt > 100 {
+			return nil, fmt.Errorf("failed to create run directory %s: %v", dir, err)
+		}
+		id = fmt.Sprintf("%s-%d", start.Format("20060102-150405"), attempt)
+	}
+}
+
+// Path returns the path of an artifact in the run directory
+func (r *RunArtifacts) Path(name string) string {
+	return filepath.Join(r.Dir, name)
+}
+
+// LogPath is the path of the run log
+func (r *RunArtifacts) LogPath() string {
+	return r.Path(runLogFile)
+}
+
+// CapturesDir is the directory of the packet captures, passed as TestConfig.CaptureDir
+func (r *RunArtifacts) CapturesDir() string {
+	return r.Path(runCapturesDir)
+}
+
+// ManifestsDir is the directory of the test namespace manifests
+func (r *RunArtifacts) ManifestsDir() string {
+	return r.Path(runManifestsDir)
+}
+
+// JSONReporter writes the JSON report into the run directory
+func (r *RunArtifacts) JSONReporter() JSONFileReporter {
+	return JSONFileReporter{Dir: r.Dir, Filename: runReportFile}
+}
+
+// HTMLReporter renders the HTML report into the run directory
+func (r *RunArtifacts) HTMLReporter() HTMLFileReporter {
+	return HTMLFileReporter{Path: r.Path(runHTMLFile)}
+}
+
+// WriteIndex lists every file of the run directory in index.json, with the run's overall status when the report is
+// known
+func (r *RunArtifacts) WriteIndex(namespace string, report *DiagnosticReportJSON) error {
+	index := RunIndex{
+		RunID:     r.ID,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Namespace: namespace,
+	}
+	if report != nil {
+		index.OverallStatus = report.Summary.OverallStatus
+	}
+	err := filepath.WalkDir(r.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(r.Dir, path)
+		if err != nil || rel == runIndexFile {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		index.Entries = append(index.Entries, BundleEntry{Path: rel, Description: runArtifactDescription(rel), Size: int(info.Size())})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %v", r.Dir, err)
+	}
+	sort.Slice(index.Entries, func(i, j int) bool { return index.Entries[i].Path < index.Entries[j].Path })
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path(runIndexFile), content, 0644)
+}
+
+// runArtifactDescription describes a file of a run directory by its name or the directory it is in
+func runArtifactDescription(path string) string {
+	if description, ok := runArtifactDescriptions[path]; ok {
+		return description
+	}
+	if dir, _, ok := strings.Cut(path, "/"); ok {
+		if description, ok := runArtifactDescriptions[dir+"/"]; ok {
+			return description
+		}
+	}
+	return runArtifactDescriptions[""]
+}
+
+// CollectNamespaceManifests writes the pods, services, deployments, network policies and events of the test
+// namespace as JSON into dir, before cleanup removes them; values are masked when redactor is set
+func (t *Tester) CollectNamespaceManifests(ctx context.Context, dir string, redactor *Redactor) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	collectors := []struct {
+		file string
+		list func() (interface{}, error)
+	}{
+		{"pods.json", func() (interface{}, error) {
+			return t.clientset.CoreV1().Pods(t.namespace).List(ctx, metav1.ListOptions{})
+		}},
+		{"services.json", func() (interface{}, error) {
+			return t.clientset.CoreV1().Services(t.namespace).List(ctx, metav1.ListOptions{})
+		}},
+		{"deployments.json", func() (interface{}, error) {
+			return t.clientset.AppsV1().Deployments(t.namespace).List(ctx, metav1.ListOptions{})
+		}},
+		{"networkpolicies.json", func() (interface{}, error) {
+			return t.clientset.NetworkingV1().NetworkPolicies(t.namespace).List(ctx, metav1.ListOptions{})
+		}},
+		{"events.json", func() (interface{}, error) {
+			return t.clientset.CoreV1().Events(t.namespace).List(ctx, metav1.ListOptions{})
+		}},
+	}
+	var errs []string
+	for _, collector := range collectors {
+		list, err := collector.list()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", collector.file, err))
+			continue
+		}
+		content, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", collector.file, err))
+			continue
+		}
+		if redactor != nil {
+			content = redactor.redactFile(collector.file, content)
+		}
+		if err := os.WriteFile(filepath.Join(dir, collector.file), content, 0644); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to collect %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
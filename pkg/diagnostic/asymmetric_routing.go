@@ -0,0 +1,324 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// directionalProbe is one direction of a probe between two nodes, at pod or node (host network) level
+type directionalProbe struct {
+	Level     string // "pod" or "node"
+	From      string // node names
+	To        string
+	Target    string
+	Reachable bool
+	Hops      []string // traceroute hop addresses, "*" for silent hops
+	// Node level only: the interface the probe arrived on at the target and the one the target routes replies out of
+	ArrivalDev string
+	ReplyDev   string
+	RPFilter   string // effective rp_filter of the arrival interface
+}
+
+// asymmetricEndpoint is one side of the probes: a netshoot pod and a privileged host-network pod on the same node
+type asymmetricEndpoint struct {
+	Node    string
+	NodeIP  string
+	Pod     string
+	PodIP   string
+	HostPod string
+}
+
+// tracerouteHops parses `traceroute -n -q 1` output into hop addresses, "*" for hops that did not answer
+func tracerouteHops(output string) []string {
+	var hops []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] == "traceroute" {
+			continue
+		}
+		if _, err := fmt.Sscanf(fields[0], "%d", new(int)); err != nil {
+			continue
+		}
+		hops = append(hops, fields[1])
+	}
+	return hops
+}
+
+// arrivalInterface returns the interface of the first inbound packet in `tcpdump -i any` output, whose lines start
+// with the interface and direction, e.g. "eth1  In  IP 10.0.0.1 > 10.0.0.2: ICMP echo request"
+func arrivalInterface(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i+1] == "In" {
+				return fields[i]
+			}
+		}
+	}
+	return ""
+}
+
+// routeDevice returns the device of `ip route get` output, e.g. "10.0.0.1 dev eth0 src 10.0.0.2 uid 0"
+func routeDevice(output string) string {
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "dev" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// effectiveRPFilterScript prints the effective rp_filter of an interface, the maximum of the "all" and interface
+// settings; interface names are passed as an argument so VLAN devices with dots work
+const effectiveRPFilterScript = `a=$(cat /proc/sys/net/ipv4/conf/all/rp_filter); i=$(cat "/proc/sys/net/ipv4/conf/$1/rp_filter" 2>/dev/null || echo 0); [ "$a" -gt "$i" ] && echo "$a" || echo "$i"`
+
+// probePodDirection pings and traceroutes from one pod to the other
+func (t *Tester) probePodDirection(ctx context.Context, from, to asymmetricEndpoint) (directionalProbe, []CommandOutput) {
+	probe := directionalProbe{Level: "pod", From: from.Node, To: to.Node, Target: to.PodIP}
+	ping, err := t.execInPodWithOutput(ctx, t.namespace, from.Pod, "netshoot", []string{"ping", "-c", "3", "-W", "1", to.PodIP},
+		fmt.Sprintf("Ping pod %s on %s from %s", to.PodIP, to.Node, from.Node))
+	probe.Reachable = err == nil
+	trace, _ := t.execInPodWithOutput(ctx, t.namespace, from.Pod, "netshoot", []string{"traceroute", "-n", "-w", "1", "-q", "1", "-m", "15", to.PodIP},
+		fmt.Sprintf("Traceroute to pod %s on %s from %s", to.PodIP, to.Node, from.Node))
+	probe.Hops = tracerouteHops(trace.Stdout)
+	return probe, []CommandOutput{ping, trace}
+}
+
+// probeNodeDirection pings from one node to the other while capturing the arrival interface at the target, then
+// reads the interface the target routes replies out of and the rp_filter applied on arrival
+func (t *Tester) probeNodeDirection(ctx context.Context, from, to asymmetricEndpoint) (directionalProbe, []CommandOutput) {
+	probe := directionalProbe{Level: "node", From: from.Node, To: to.Node, Target: to.NodeIP}
+
+	var capture CommandOutput
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		capture, _ = t.execInPodWithOutput(ctx, t.namespace, to.HostPod, "netshoot",
+			[]string{"sh", "-c", fmt.Sprintf("timeout 6 tcpdump -i any -nn -l -c 3 '(icmp or icmp6) and src host %s' 2>/dev/null", from.NodeIP)},
+			fmt.Sprintf("Probes from %s arriving on %s", from.Node, to.Node))
+	}()
+	// Give tcpdump time to attach
+	sleepContext(ctx, 2*time.Second)
+	ping, err := t.execInPodWithOutput(ctx, t.namespace, from.HostPod, "netshoot", []string{"ping", "-c", "3", "-W", "1", to.NodeIP},
+		fmt.Sprintf("Ping node %s from %s", to.Node, from.Node))
+	probe.Reachable = err == nil
+	wg.Wait()
+	probe.ArrivalDev = arrivalInterface(capture.Stdout)
+
+	route, _ := t.execInPodWithOutput(ctx, t.namespace, to.HostPod, "netshoot", []string{"ip", "route", "get", from.NodeIP},
+		fmt.Sprintf("Reply route from %s to %s", to.Node, from.Node))
+	probe.ReplyDev = routeDevice(route.Stdout)
+	if probe.ArrivalDev != "" {
+		rpFilter, _ := t.execInPod(ctx, t.namespace, to.HostPod, "netshoot", []string{"sh", "-c", effectiveRPFilterScript, "rp_filter", probe.ArrivalDev})
+		probe.RPFilter = strings.TrimSpace(rpFilter)
+	}
+
+	trace, _ := t.execInPodWithOutput(ctx, t.namespace, from.HostPod, "netshoot", []string{"traceroute", "-n", "-w", "1", "-q", "1", "-m", "15", to.NodeIP},
+		fmt.Sprintf("Traceroute to node %s from %s", to.Node, from.Node))
+	probe.Hops = tracerouteHops(trace.Stdout)
+	return probe, []CommandOutput{ping, capture, route, trace}
+}
+
+// TestAsymmetricRouting runs paired probes in both directions between two worker nodes, at pod and at node level:
+// one direction working while the other fails, replies leaving a node through another interface than the requests
+// arrived on (multi-NIC nodes, where strict rp_filter drops traffic), and traceroutes of different length in each
+// direction are reported
+func (t *Tester) TestAsymmetricRouting(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: A netshoot pod and a privileged host-network pod on each of two worker nodes
+	workers, err := t.getWorkerNodes(ctx)
+	if err != nil || len(workers) < 2 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Asymmetric routing test skipped - needs at least 2 worker nodes",
+			Details: details,
+		}
+	}
+	endpoints := []asymmetricEndpoint{
+		{Node: workers[0], Pod: "netshoot-asym-1", HostPod: "asym-host-1"},
+		{Node: workers[1], Pod: "netshoot-asym-2", HostPod: "asym-host-2"},
+	}
+	defer t.cleanupPods(ctx, endpoints[0].Pod, endpoints[1].Pod)
+	defer t.cleanupPods(ctx, endpoints[0].HostPod, endpoints[1].HostPod)
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		node, err := t.clientset.CoreV1().Nodes().Get(ctx, endpoint.Node, metav1.GetOptions{})
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get node %s: %v", endpoint.Node, err),
+				Details: details,
+			}
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP && endpoint.NodeIP == "" {
+				endpoint.NodeIP = address.Address
+			}
+		}
+		if endpoint.NodeIP == "" {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Node %s has no InternalIP", endpoint.Node),
+				Details: details,
+			}
+		}
+		if _, err := t.createNetshootPod(ctx, endpoint.Pod, endpoint.Node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create pod %s: %v", endpoint.Pod, err),
+				Details: details,
+			}
+		}
+		if _, err := t.createPrivilegedDebugPod(ctx, endpoint.HostPod, endpoint.Node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create host-network pod %s: %v", endpoint.HostPod, err),
+				Details: details,
+			}
+		}
+	}
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		for _, pod := range []string{endpoint.Pod, endpoint.HostPod} {
+			if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+				return TestResult{
+					Success: false,
+					Message: fmt.Sprintf("Pod %s not ready: %v", pod, err),
+					Details: details,
+				}
+			}
+		}
+		pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, endpoint.Pod, metav1.GetOptions{})
+		if err != nil || pod.Status.PodIP == "" {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s has no IP", endpoint.Pod),
+				Details: details,
+			}
+		}
+		endpoint.PodIP = pod.Status.PodIP
+		details = append(details, fmt.Sprintf("✓ %s: node %s, pod %s", endpoint.Node, endpoint.NodeIP, endpoint.PodIP))
+	}
+
+	// Step 2: Both directions at pod and node level
+	a, b := endpoints[0], endpoints[1]
+	var probes []directionalProbe
+	var commandOutputs []CommandOutput
+	for _, direction := range [][2]asymmetricEndpoint{{a, b}, {b, a}} {
+		probe, outputs := t.probePodDirection(ctx, direction[0], direction[1])
+		probes = append(probes, probe)
+		commandOutputs = append(commandOutputs, outputs...)
+	}
+	for _, direction := range [][2]asymmetricEndpoint{{a, b}, {b, a}} {
+		probe, outputs := t.probeNodeDirection(ctx, direction[0], direction[1])
+		probes = append(probes, probe)
+		commandOutputs = append(commandOutputs, outputs...)
+	}
+
+	details = append(details, fmt.Sprintf("  %-6s %-50s %-10s %-5s %-22s %s", "LEVEL", "DIRECTION", "RESULT", "HOPS", "ARRIVAL/REPLY DEV", "RP_FILTER"))
+	for _, probe := range probes {
+		result := "✓ ok"
+		if !probe.Reachable {
+			result = "✗ failed"
+		}
+		devices, rpFilter := "-", "-"
+		if probe.Level == "node" {
+			devices = fmt.Sprintf("%s/%s", valueOrUnknown(probe.ArrivalDev), valueOrUnknown(probe.ReplyDev))
+			rpFilter = valueOrUnknown(probe.RPFilter)
+		}
+		details = append(details, fmt.Sprintf("  %-6s %-50s %-10s %-5d %-22s %s", probe.Level, fmt.Sprintf("%s -> %s", probe.From, probe.To), result, len(probe.Hops), devices, rpFilter))
+	}
+
+	// Step 3: Compare the directions
+	metrics := map[string]float64{}
+	var problems, warnings []string
+	rpFilterInvolved := false
+	for i := 0; i+1 < len(probes); i += 2 {
+		forward, reverse := probes[i], probes[i+1]
+		if forward.Reachable != reverse.Reachable {
+			working, failing := forward, reverse
+			if !forward.Reachable {
+				working, failing = reverse, forward
+			}
+			problems = append(problems, fmt.Sprintf("%s level: %s -> %s works but %s -> %s fails", forward.Level, working.From, working.To, failing.From, failing.To))
+		} else if !forward.Reachable {
+			problems = append(problems, fmt.Sprintf("%s level: %s and %s cannot reach each other in either direction", forward.Level, forward.From, forward.To))
+		}
+		if len(forward.Hops) > 0 && len(reverse.Hops) > 0 && len(forward.Hops) != len(reverse.Hops) {
+			warnings = append(warnings, fmt.Sprintf("%s level: %d hops %s -> %s (%s) but %d hops back (%s)", forward.Level,
+				len(forward.Hops), forward.From, forward.To, strings.Join(forward.Hops, " "), len(reverse.Hops), strings.Join(reverse.Hops, " ")))
+		}
+	}
+	for _, probe := range probes {
+		if probe.Level != "node" || probe.ArrivalDev == "" || probe.ReplyDev == "" || probe.ArrivalDev == probe.ReplyDev {
+			continue
+		}
+		finding := fmt.Sprintf("%s receives traffic from %s on %s but routes replies out of %s", probe.To, probe.From, probe.ArrivalDev, probe.ReplyDev)
+		if probe.RPFilter == "1" {
+			rpFilterInvolved = true
+			problems = append(problems, fmt.Sprintf("%s - strict rp_filter on %s drops such traffic", finding, probe.ArrivalDev))
+		} else {
+			warnings = append(warnings, finding)
+		}
+	}
+	metrics["asymmetric_findings"] = float64(len(problems) + len(warnings))
+	for _, warning := range warnings {
+		details = append(details, fmt.Sprintf("⚠️ %s", warning))
+	}
+	for _, problem := range problems {
+		details = append(details, fmt.Sprintf("✗ %s", problem))
+	}
+
+	if len(problems) > 0 {
+		hints := []string{
+			"One-way failures usually mean the reply path differs from the request path: check routes for the peer's address on both nodes (ip route get <peer-ip>)",
+			"On multi-NIC nodes, pin replies to the arrival interface with source-based policy routing (ip rule add from <nic-ip> table <n>) or keep node traffic on one interface",
+			"Stateful firewalls and security groups drop replies that return on a different path than the request",
+		}
+		if rpFilterInvolved {
+			hints = append([]string{"Strict reverse path filtering (rp_filter=1) drops packets arriving on an interface other than the route back - set net.ipv4.conf.<dev>.rp_filter=2 (loose) on the affected interfaces"}, hints...)
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Asymmetric routing between %s and %s: %s", a.Node, b.Node, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Asymmetric Routing",
+				TechnicalError:       strings.Join(problems, "; "),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	message := fmt.Sprintf("Traffic between %s and %s is symmetric at pod and node level", a.Node, b.Node)
+	if len(warnings) > 0 {
+		message = fmt.Sprintf("Both directions work between %s and %s, with %d asymmetric path warnings", a.Node, b.Node, len(warnings))
+	}
+	return TestResult{
+		Success:  true,
+		Message:  message,
+		Details:  details,
+		Metrics:  metrics,
+		Warnings: warnings,
+	}
+}
+
+// valueOrUnknown returns "?" for an empty value in tables
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "?"
+	}
+	return value
+}
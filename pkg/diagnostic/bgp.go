@@ -0,0 +1,342 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	ciliumBGPPeeringPolicyGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2alpha1", Resource: "ciliumbgppeeringpolicies"}
+	ciliumBGPClusterConfigGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2alpha1", Resource: "ciliumbgpclusterconfigs"}
+	ciliumBGPAdvertisementGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2alpha1", Resource: "ciliumbgpadvertisements"}
+)
+
+// bgpPeerSession is the state of one BGP session as reported by a Cilium agent
+type bgpPeerSession struct {
+	Node        string
+	LocalAS     string
+	PeerAS      string
+	PeerAddress string
+	State       string
+}
+
+// bgpExpectations describes which routes the BGP configuration asks Cilium to advertise
+type bgpExpectations struct {
+	API            string
+	PodCIDR        bool
+	LoadBalancerIP bool
+}
+
+// detectBGPConfiguration looks for the v1 (CiliumBGPPeeringPolicy) and v2 (CiliumBGPClusterConfig) BGP APIs and
+// derives which prefixes should be advertised
+func (t *Tester) detectBGPConfiguration(ctx context.Context) (bgpExpectations, bool) {
+	var expectations bgpExpectations
+
+	if policies, err := t.dynamicClient.Resource(ciliumBGPPeeringPolicyGVR).List(ctx, metav1.ListOptions{}); err == nil && len(policies.Items) > 0 {
+		expectations.API = fmt.Sprintf("CiliumBGPPeeringPolicy (%d)", len(policies.Items))
+		for _, policy := range policies.Items {
+			routers, _, _ := unstructured.NestedSlice(policy.Object, "spec", "virtualRouters")
+			for _, router := range routers {
+				routerMap, ok := router.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if exportPodCIDR, _, _ := unstructured.NestedBool(routerMap, "exportPodCIDR"); exportPodCIDR {
+					expectations.PodCIDR = true
+				}
+				if _, found, _ := unstructured.NestedMap(routerMap, "serviceSelector"); found {
+					expectations.LoadBalancerIP = true
+				}
+			}
+		}
+		return expectations, true
+	}
+
+	if configs, err := t.dynamicClient.Resource(ciliumBGPClusterConfigGVR).List(ctx, metav1.ListOptions{}); err == nil && len(configs.Items) > 0 {
+		expectations.API = fmt.Sprintf("CiliumBGPClusterConfig (%d)", len(configs.Items))
+		if advertisements, err := t.dynamicClient.Resource(ciliumBGPAdvertisementGVR).List(ctx, metav1.ListOptions{}); err == nil {
+			for _, advertisement := range advertisements.Items {
+				entries, _, _ := unstructured.NestedSlice(advertisement.Object, "spec", "advertisements")
+				for _, entry := range entries {
+					entryMap, ok := entry.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					switch advertisementType, _, _ := unstructured.NestedString(entryMap, "advertisementType"); advertisementType {
+					case "PodCIDR":
+						expectations.PodCIDR = true
+					case "Service":
+						expectations.LoadBalancerIP = true
+					}
+				}
+			}
+		}
+		return expectations, true
+	}
+
+	return expectations, false
+}
+
+// parseBGPPeers extracts sessions from `cilium-dbg bgp peers` output
+func parseBGPPeers(nodeName, output string) []bgpPeerSession {
+	var sessions []bgpPeerSession
+	for _, line := range strings.Split(output, "\n") {
+		// Example: 65001      65000     172.18.0.5:179   established   1h2m     ipv4/unicast   2   3
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		host, _, err := net.SplitHostPort(fields[2])
+		if err != nil {
+			host = fields[2]
+		}
+		if net.ParseIP(host) == nil {
+			continue
+		}
+		sessions = append(sessions, bgpPeerSession{
+			Node:        nodeName,
+			LocalAS:     fields[0],
+			PeerAS:      fields[1],
+			PeerAddress: fields[2],
+			State:       strings.ToLower(fields[3]),
+		})
+	}
+	return sessions
+}
+
+// parseAdvertisedPrefixes returns every CIDR found in `cilium-dbg bgp routes advertised` output
+func parseAdvertisedPrefixes(output string) map[string]bool {
+	prefixes := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		for _, field := range strings.Fields(line) {
+			if _, network, err := net.ParseCIDR(field); err == nil {
+				prefixes[network.String()] = true
+			}
+		}
+	}
+	return prefixes
+}
+
+// TestBGPControlPlane tests Cilium BGP with the default (disabled) configuration
+func (t *Tester) TestBGPControlPlane(ctx context.Context) TestResult {
+	return t.TestBGPControlPlaneWithConfig(ctx, TestConfig{})
+}
+
+// TestBGPControlPlaneWithConfig detects the Cilium BGP configuration, verifies every session is Established on each
+// node and checks that PodCIDR and LoadBalancer routes are advertised where the configuration asks for them
+func (t *Tester) TestBGPControlPlaneWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	if !config.BGP {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "BGP control plane test skipped - opt in with --bgp",
+			Details: []string{"ℹ️ Only clusters peering with BGP routers need this test, so it only runs when explicitly enabled"},
+		}
+	}
+
+	// Step 1: Detect the BGP configuration
+	expectations, found := t.detectBGPConfiguration(ctx)
+	if !found {
+		return TestResult{
+			Success: false,
+			Message: "No Cilium BGP configuration found (no CiliumBGPPeeringPolicy or CiliumBGPClusterConfig)",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage: "Feature Detection",
+				TroubleshootingHints: []string{
+					"Enable the BGP control plane with the Helm value bgpControlPlane.enabled=true",
+					"Check the CRDs exist: kubectl get crd | grep -i bgp",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ BGP configured with %s; PodCIDR advertisement=%t, Service advertisement=%t",
+		expectations.API, expectations.PodCIDR, expectations.LoadBalancerIP))
+
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+
+	var lbIPs []string
+	if expectations.LoadBalancerIP {
+		services, err := t.clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+		if err == nil {
+			for _, service := range services.Items {
+				if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+					continue
+				}
+				for _, ingress := range service.Status.LoadBalancer.Ingress {
+					if ingress.IP != "" {
+						lbIPs = append(lbIPs, ingress.IP)
+					}
+				}
+			}
+		}
+	}
+
+	// Step 2: Sessions and advertised routes per node
+	var failures []string
+	var warnings []string
+	var sessions []bgpPeerSession
+	advertisedAnywhere := map[string]bool{}
+	for _, node := range nodes.Items {
+		agentPod, err := t.getCiliumAgentPod(ctx, node.Name)
+		if err != nil {
+			details = append(details, fmt.Sprintf("⚠️ %s: %v", node.Name, err))
+			continue
+		}
+		peersOutput, err := t.execCiliumCLI(ctx, agentPod, "bgp peers", fmt.Sprintf("BGP peers on %s", node.Name))
+		commandOutputs = append(commandOutputs, peersOutput)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: bgp peers failed", node.Name))
+			details = append(details, fmt.Sprintf("✗ %s: could not query BGP peers: %v", node.Name, err))
+			continue
+		}
+		nodeSessions := parseBGPPeers(node.Name, peersOutput.Stdout)
+		if len(nodeSessions) == 0 {
+			details = append(details, fmt.Sprintf("ℹ️ %s: no BGP peers (node not selected by the BGP configuration)", node.Name))
+			continue
+		}
+		sessions = append(sessions, nodeSessions...)
+
+		var advertised map[string]bool
+		for _, family := range []string{"ipv4", "ipv6"} {
+			routesOutput, err := t.execCiliumCLI(ctx, agentPod, fmt.Sprintf("bgp routes advertised %s unicast", family),
+				fmt.Sprintf("BGP %s routes advertised from %s", family, node.Name))
+			commandOutputs = append(commandOutputs, routesOutput)
+			if err != nil {
+				continue
+			}
+			if advertised == nil {
+				advertised = map[string]bool{}
+			}
+			for prefix := range parseAdvertisedPrefixes(routesOutput.Stdout) {
+				advertised[prefix] = true
+				advertisedAnywhere[prefix] = true
+			}
+		}
+
+		if expectations.PodCIDR && advertised != nil {
+			podCIDRs := node.Spec.PodCIDRs
+			if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+				podCIDRs = []string{node.Spec.PodCIDR}
+			}
+			for _, podCIDR := range podCIDRs {
+				_, network, err := net.ParseCIDR(podCIDR)
+				if err != nil {
+					continue
+				}
+				if advertised[network.String()] {
+					details = append(details, fmt.Sprintf("✓ %s: PodCIDR %s advertised", node.Name, network))
+				} else {
+					details = append(details, fmt.Sprintf("✗ %s: PodCIDR %s not advertised", node.Name, network))
+					failures = append(failures, fmt.Sprintf("%s: PodCIDR %s not advertised", node.Name, network))
+				}
+			}
+		}
+	}
+
+	if len(sessions) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "BGP is configured but no node reports any BGP peer",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "BGP Sessions",
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check the nodeSelector of the BGP configuration matches node labels",
+					"Check the agent logs for BGP errors: kubectl logs -n kube-system -l k8s-app=cilium | grep -i bgp",
+				},
+			},
+		}
+	}
+
+	// Step 3: Per-peer session table
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Node != sessions[j].Node {
+			return sessions[i].Node < sessions[j].Node
+		}
+		return sessions[i].PeerAddress < sessions[j].PeerAddress
+	})
+	details = append(details, "  BGP sessions per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-40s %s", "NODE", "LOCAL AS", "PEER AS", "PEER", "SESSION"))
+	established := 0
+	for _, session := range sessions {
+		marker := "✓"
+		if session.State == "established" {
+			established++
+		} else {
+			marker = "✗"
+			failures = append(failures, fmt.Sprintf("%s: peer %s (AS %s) %s", session.Node, session.PeerAddress, session.PeerAS, session.State))
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-40s %s %s",
+			session.Node, session.LocalAS, session.PeerAS, session.PeerAddress, marker, session.State))
+	}
+	details = append(details, "  kubectl -n kube-system exec ds/cilium -c cilium-agent -- cilium-dbg bgp peers")
+
+	// Step 4: LoadBalancer addresses advertised by at least one node
+	for _, ip := range lbIPs {
+		prefix := hostCIDR(ip)
+		if _, network, err := net.ParseCIDR(prefix); err == nil && advertisedAnywhere[network.String()] {
+			details = append(details, fmt.Sprintf("✓ LoadBalancer address %s advertised", ip))
+		} else {
+			details = append(details, fmt.Sprintf("⚠️ LoadBalancer address %s not advertised by any node", ip))
+			warnings = append(warnings, ip)
+		}
+	}
+	if len(warnings) > 0 {
+		details = append(details, "ℹ️ Service advertisements only cover services matching their selector; unselected addresses are expected to be missing")
+	}
+
+	metrics := map[string]float64{
+		"bgp_sessions":             float64(len(sessions)),
+		"bgp_sessions_established": float64(established),
+	}
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("BGP control plane problems: %s", strings.Join(failures, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "BGP Control Plane",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Sessions stuck in active/connect usually mean the peer is unreachable on TCP 179 or the peer does not list this node as a neighbor",
+					"Check ASNs and peer addresses in the BGP configuration match the router configuration",
+					"Missing PodCIDR routes: check exportPodCIDR (v1) or a PodCIDR CiliumBGPAdvertisement selected by the peer config (v2)",
+					"Inspect routes: kubectl -n kube-system exec ds/cilium -- cilium-dbg bgp routes advertised ipv4 unicast",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d BGP sessions Established and expected routes advertised", len(sessions)),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
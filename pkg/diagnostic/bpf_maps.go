@@ -0,0 +1,260 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bpfMapPressureThreshold is the utilization above which a BPF map is flagged
+const bpfMapPressureThreshold = 0.9
+
+// bpfMapFallbackLimits are the cilium-config keys and defaults used to size maps when the pressure metric is not
+// available; the CT limit is the sum of the TCP and non-TCP tables
+var bpfMapFallbackLimits = []struct {
+	Map        string
+	ListArgs   string
+	ConfigKeys []string
+	Defaults   []int
+}{
+	{Map: "cilium_ct_global", ListArgs: "bpf ct list global", ConfigKeys: []string{"bpf-ct-global-tcp-max", "bpf-ct-global-any-max"}, Defaults: []int{524288, 262144}},
+	{Map: "cilium_snat_v4_external", ListArgs: "bpf nat list", ConfigKeys: []string{"bpf-nat-global-max"}, Defaults: []int{524288}},
+}
+
+// BPFMapUsage is the utilization of one Cilium BPF map on one node
+type BPFMapUsage struct {
+	Node       string  `json:"node"`
+	Map        string  `json:"map"`
+	Entries    int     `json:"entries,omitempty"`
+	MaxEntries int     `json:"max_entries,omitempty"`
+	Pressure   float64 `json:"pressure"`
+}
+
+// ciliumMetricRecord is one entry of `cilium-dbg metrics list -o json`
+type ciliumMetricRecord struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// collectBPFMapPressure reads BPF map utilization from every Cilium agent. The cilium_bpf_map_pressure metric is used
+// where the agent exports it (CT, NAT and policy maps); otherwise CT and NAT entries are counted against the
+// configured map sizes.
+func (t *Tester) collectBPFMapPressure(ctx context.Context) ([]BPFMapUsage, []CommandOutput, error) {
+	var usages []BPFMapUsage
+	var commandOutputs []CommandOutput
+
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: "k8s-app=cilium",
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list Cilium agent pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil, fmt.Errorf("no Cilium pods found in kube-system namespace")
+	}
+	ciliumConfig, _ := t.getCiliumConfig(ctx)
+
+	for _, pod := range pods.Items {
+		if !isPodReady(&pod) {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+
+		output, err := t.execCiliumCLI(ctx, pod.Name, "metrics list -p cilium_bpf_map_pressure -o json",
+			fmt.Sprintf("BPF map pressure on %s", nodeName))
+		commandOutputs = append(commandOutputs, output)
+		var metrics []ciliumMetricRecord
+		if err == nil {
+			json.Unmarshal([]byte(output.Stdout), &metrics)
+		}
+		found := false
+		for _, metric := range metrics {
+			if metric.Name != "cilium_bpf_map_pressure" {
+				continue
+			}
+			found = true
+			usages = append(usages, BPFMapUsage{Node: nodeName, Map: metric.Labels["map_name"], Pressure: metric.Value})
+		}
+		if found {
+			continue
+		}
+
+		// Fallback: count entries and compare with the configured limits
+		for _, limit := range bpfMapFallbackLimits {
+			maxEntries := 0
+			for i, key := range limit.ConfigKeys {
+				value, err := strconv.Atoi(ciliumConfig[key])
+				if err != nil || value == 0 {
+					value = limit.Defaults[i]
+				}
+				maxEntries += value
+			}
+			countOutput, err := t.execInPodWithOutput(ctx, "kube-system", pod.Name, "cilium-agent",
+				[]string{"sh", "-c", fmt.Sprintf("(cilium-dbg %s 2>/dev/null || cilium %s) | wc -l", limit.ListArgs, limit.ListArgs)},
+				fmt.Sprintf("Count %s entries on %s", limit.Map, nodeName))
+			commandOutputs = append(commandOutputs, countOutput)
+			if err != nil {
+				continue
+			}
+			entries, err := strconv.Atoi(strings.TrimSpace(countOutput.Stdout))
+			if err != nil {
+				continue
+			}
+			usages = append(usages, BPFMapUsage{
+				Node:       nodeName,
+				Map:        limit.Map,
+				Entries:    entries,
+				MaxEntries: maxEntries,
+				Pressure:   float64(entries) / float64(maxEntries),
+			})
+		}
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Node != usages[j].Node {
+			return usages[i].Node < usages[j].Node
+		}
+		return usages[i].Map < usages[j].Map
+	})
+	return usages, commandOutputs, nil
+}
+
+// highPressureMaps returns the usages above bpfMapPressureThreshold
+func highPressureMaps(usages []BPFMapUsage) []BPFMapUsage {
+	var high []BPFMapUsage
+	for _, usage := range usages {
+		if usage.Pressure >= bpfMapPressureThreshold {
+			high = append(high, usage)
+		}
+	}
+	return high
+}
+
+// bpfMapPressureHints returns troubleshooting hints for the maps above the threshold
+func bpfMapPressureHints(high []BPFMapUsage) []string {
+	var hints []string
+	for _, usage := range high {
+		hints = append(hints, fmt.Sprintf("BPF map %s on %s is %.0f%% full - full maps drop new connections intermittently",
+			usage.Map, usage.Node, usage.Pressure*100))
+	}
+	if len(high) > 0 {
+		hints = append(hints,
+			"Raise map sizes with bpf-map-dynamic-size-ratio or the bpf-ct-global-*/bpf-nat-global-max/bpf-policy-map-max settings",
+			"Check for connection churn filling the CT table: kubectl -n kube-system exec ds/cilium -- cilium-dbg bpf ct list global | wc -l")
+	}
+	return hints
+}
+
+// AttachBPFMapPressure adds BPF map utilization to the diagnostics of a failed test, flagging maps above the
+// threshold, since full CT/NAT/policy maps cause exactly the intermittent connectivity failures under investigation
+func (t *Tester) AttachBPFMapPressure(ctx context.Context, result *TestResult) {
+	if result.Success {
+		return
+	}
+	usages, _, err := t.collectBPFMapPressure(ctx)
+	if err != nil || len(usages) == 0 {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	result.DetailedDiagnostics.BPFMapPressure = usages
+
+	high := highPressureMaps(usages)
+	for _, usage := range high {
+		result.Details = append(result.Details, fmt.Sprintf("⚠️ BPF map %s on %s at %.0f%% utilization", usage.Map, usage.Node, usage.Pressure*100))
+	}
+	result.DetailedDiagnostics.TroubleshootingHints = append(result.DetailedDiagnostics.TroubleshootingHints, bpfMapPressureHints(high)...)
+}
+
+// TestBPFMapPressure reports BPF map utilization per node and fails if any map is above the threshold
+func (t *Tester) TestBPFMapPressure(ctx context.Context) TestResult {
+	var details []string
+
+	usages, commandOutputs, err := t.collectBPFMapPressure(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("BPF map check failed: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Agent Discovery",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"This test only applies to clusters running Cilium",
+				},
+			},
+		}
+	}
+	if len(usages) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Could not read BPF map utilization from any Cilium agent",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "BPF Map Collection",
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check the agents are ready: kubectl -n kube-system get pods -l k8s-app=cilium",
+				},
+			},
+		}
+	}
+
+	metrics := map[string]float64{}
+	details = append(details, "  BPF map utilization per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-32s %-20s %s", "NODE", "MAP", "ENTRIES", "PRESSURE"))
+	for _, usage := range usages {
+		entries := "-"
+		if usage.MaxEntries > 0 {
+			entries = fmt.Sprintf("%d/%d", usage.Entries, usage.MaxEntries)
+		}
+		marker := "✓"
+		if usage.Pressure >= bpfMapPressureThreshold {
+			marker = "✗"
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-32s %-20s %s %.1f%%", usage.Node, usage.Map, entries, marker, usage.Pressure*100))
+		key := "pressure_pct." + usage.Map
+		if usage.Pressure*100 > metrics[key] {
+			metrics[key] = usage.Pressure * 100
+		}
+	}
+	details = append(details, "  kubectl -n kube-system exec ds/cilium -c cilium-agent -- cilium-dbg metrics list -p cilium_bpf_map_pressure")
+
+	high := highPressureMaps(usages)
+	if len(high) > 0 {
+		var names []string
+		for _, usage := range high {
+			names = append(names, fmt.Sprintf("%s on %s (%.0f%%)", usage.Map, usage.Node, usage.Pressure*100))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d BPF maps above %.0f%% utilization: %s", len(high), bpfMapPressureThreshold*100, strings.Join(names, ", ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "BPF Map Pressure",
+				TechnicalError:       strings.Join(names, ", "),
+				CommandOutputs:       commandOutputs,
+				BPFMapPressure:       usages,
+				TroubleshootingHints: bpfMapPressureHints(high),
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d BPF maps below %.0f%% utilization", len(usages), bpfMapPressureThreshold*100),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			BPFMapPressure: usages,
+		},
+	}
+}
@@ -0,0 +1,137 @@
+package diagnostic
+
+import "context"
+
+// Test is one diagnostic check: a built-in test, an executable plugin, a probe test from YAML or a test compiled in
+// by a package that calls RegisterTest from its init function
+type Test interface {
+	// Name is the test name used with --test-list, e.g. "pod-to-pod" or "acme-artifactory"
+	Name() string
+	// Describe is a one-line description shown in the JSON report
+	Describe() string
+	// Run executes the test against the cluster of the tester, in its namespace
+	Run(ctx context.Context, t *Tester, config TestConfig) TestResult
+}
+
+// BuiltinTest is a test implemented by a Tester method
+type BuiltinTest struct {
+	name  string
+	title string
+	run   func(*Tester, context.Context, TestConfig) TestResult
+}
+
+func (b *BuiltinTest) Name() string { return b.name }
+
+// Title is the display name of the test in the output and the JSON report, e.g. "Pod-to-Pod Connectivity"
+func (b *BuiltinTest) Title() string { return b.title }
+
+func (b *BuiltinTest) Describe() string { return TestDescriptions[b.title] }
+
+// TestTitle returns the display name of a test: the title of a built-in test and the name of any other
+func TestTitle(test Test) string {
+	if builtin, ok := test.(*BuiltinTest); ok {
+		return builtin.Title()
+	}
+	return test.Name()
+}
+
+func (b *BuiltinTest) Run(ctx context.Context, t *Tester, config TestConfig) TestResult {
+	return b.run(t, ctx, config)
+}
+
+// withoutConfig adapts a test method that takes no configuration
+func withoutConfig(run func(*Tester, context.Context) TestResult) func(*Tester, context.Context, TestConfig) TestResult {
+	return func(t *Tester, ctx context.Context, _ TestConfig) TestResult {
+		return run(t, ctx)
+	}
+}
+
+// builtinTests lists the built-in tests in the order of the --test-list help
+var builtinTests = []*BuiltinTest{
+	{"pod-to-pod", "Pod-to-Pod Connectivity", (*Tester).TestPodToPodConnectivityWithConfig},
+	{"service-to-pod", "Service to Pod Connectivity", (*Tester).TestServiceToPodConnectivityWithConfig},
+	{"cross-node", "Cross-Node Service Connectivity", (*Tester).TestCrossNodeServiceConnectivityWithConfig},
+	{"dns", "DNS Resolution", withoutConfig((*Tester).TestDNSResolution)},
+	{"nodeport-range", "NodePort Range and Conflicts", withoutConfig((*Tester).TestNodePortRange)},
+	{"nodeport", "NodePort Service Connectivity", (*Tester).TestNodePortServiceConnectivityWithConfig},
+	{"loadbalancer", "LoadBalancer Service Connectivity", (*Tester).TestLoadBalancerServiceConnectivityWithConfig},
+	{"external-dns", "ExternalDNS Record Publication", (*Tester).TestExternalDNS},
+	{"ip-family", "Service IP Family Validation", (*Tester).TestServiceIPFamiliesWithConfig},
+	{"dns-nodes", "kube-dns Reachability Per Node", withoutConfig((*Tester).TestKubeDNSNodeReachability)},
+	{"snat", "SNAT/Masquerade Validation", withoutConfig((*Tester).TestSNATMasquerade)},
+	{"ipam-sanity", "PodCIDR/IPAM Sanity", withoutConfig((*Tester).TestIPAMSanity)},
+	{"ip-exhaustion", "Per-Node IP Exhaustion Early Warning", (*Tester).TestIPExhaustion},
+	{"overlay-health", "Overlay (VXLAN/Geneve) Health", withoutConfig((*Tester).TestOverlayHealth)},
+	{"native-routes", "Native Routing Table Validation", withoutConfig((*Tester).TestNativeRoutingTable)},
+	{"asymmetric-routing", "Asymmetric Routing Detection", withoutConfig((*Tester).TestAsymmetricRouting)},
+	{"zone-latency", "Cross-Zone Latency Matrix", withoutConfig((*Tester).TestZoneLatencyMatrix)},
+	{"windows-connectivity", "Windows Node Connectivity", withoutConfig((*Tester).TestWindowsConnectivity)},
+	{"cilium-lb-ipam", "Cilium LB-IPAM LoadBalancer", (*Tester).TestCiliumLBIPAMWithConfig},
+	{"cilium-kpr", "Cilium Kube-Proxy Replacement", (*Tester).TestKubeProxyReplacementWithConfig},
+	{"cilium-health", "Cilium Agent and Endpoint Health", withoutConfig((*Tester).TestCiliumHealth)},
+	{"cilium-identity", "Cilium Identity Resolution", withoutConfig((*Tester).TestCiliumIdentity)},
+	{"cilium-bpf-maps", "Cilium BPF Map Pressure", withoutConfig((*Tester).TestBPFMapPressure)},
+	{"cilium-bgp", "Cilium BGP Control Plane", (*Tester).TestBGPControlPlaneWithConfig},
+	{"cilium-connectivity", "Cilium CLI Connectivity Suite", (*Tester).TestCiliumConnectivitySuiteWithConfig},
+	{"calico-health", "Calico Node, BGP and Felix Health", withoutConfig((*Tester).TestCalicoHealth)},
+	{"tls", "TLS/HTTPS Connectivity", (*Tester).TestTLSConnectivityWithConfig},
+	{"grpc", "gRPC Connectivity", withoutConfig((*Tester).TestGRPCConnectivity)},
+	{"websocket-http2", "WebSocket and HTTP/2 Upgrade", withoutConfig((*Tester).TestWebSocketAndHTTP2)},
+	{"idle-timeout", "Long-Lived Connection Idle Timeout", (*Tester).TestIdleConnectionTimeout},
+	{"accepting-all-pods", "Accepting All Requests from Other Pods", withoutConfig((*Tester).TestAcceptingAllPods)},
+	{"rejecting-all-pods", "Rejecting All Requests from Other Pods", withoutConfig((*Tester).TestRejectingAllPods)},
+	{"l4-ingress-ports", "L4 Ingress Port Policies", withoutConfig((*Tester).TestL4IngressPortPolicies)},
+	{"l4-egress-ports", "L4 Egress Port Policies", withoutConfig((*Tester).TestL4EgressPortPolicies)},
+	{"default-deny-allowlist", "Default-Deny Allowlist Suite", withoutConfig((*Tester).TestDefaultDenyAllowlist)},
+	{"namespace-isolation", "Namespace Isolation Policy", withoutConfig((*Tester).TestNamespaceIsolation)},
+	{"egress-dns-allow", "Egress DNS Allow Policy", withoutConfig((*Tester).TestEgressDNSAllow)},
+	{"policy-propagation", "Network Policy Propagation Latency", withoutConfig((*Tester).TestPolicyPropagation)},
+	{"firewall-ports", "Node Firewall Port Matrix", withoutConfig((*Tester).TestFirewallPorts)},
+	{"host-firewall", "Host Firewall Policy", (*Tester).TestHostFirewallWithConfig},
+	{"netpol-ingress", "NetworkPolicy Ingress Conformance", withoutConfig((*Tester).TestNetpolIngressConformance)},
+	{"netpol-egress", "NetworkPolicy Egress Conformance", withoutConfig((*Tester).TestNetpolEgressConformance)},
+	{"mesh-mtls", "Service Mesh mTLS", withoutConfig((*Tester).TestMeshMTLS)},
+	{"weighted-routing", "Canary Weighted Routing", (*Tester).TestWeightedRouting},
+	{"mesh-authz", "Service Mesh L7 Authorization", withoutConfig((*Tester).TestMeshAuthorizationPolicies)},
+	{"pvc-access", "PVC Binding and Mount", (*Tester).TestPVCBindingAndMount},
+	{"pvc-rwx", "RWX Cross-Node Volume Access", (*Tester).TestRWXCrossNodeAccess},
+	{"csi-health", "CSI Driver Health", withoutConfig((*Tester).TestCSIHealth)},
+	{"pvc-expand", "PVC Volume Expansion", (*Tester).TestVolumeExpansion},
+	{"apiserver-latency", "API Server Latency", withoutConfig((*Tester).TestAPIServerLatency)},
+	{"admission-webhooks", "Admission Webhook Connectivity", withoutConfig((*Tester).TestAdmissionWebhooks)},
+	{"control-plane-health", "Control Plane Health", withoutConfig((*Tester).TestControlPlaneHealth)},
+	{"scheduling-latency", "Pod Scheduling Latency", withoutConfig((*Tester).TestSchedulingLatency)},
+	{"cert-expiry", "Certificate Expiry", (*Tester).TestCertificateExpiry},
+	{"serviceaccount-token", "ServiceAccount Token Authentication", withoutConfig((*Tester).TestServiceAccountTokens)},
+	{"port-forward", "API Server Port-Forward", withoutConfig((*Tester).TestPortForward)},
+	{"apiserver-proxy", "API Server Proxy Path", withoutConfig((*Tester).TestAPIServerProxy)},
+	{"kubelet-ports", "Kubelet Port Audit", withoutConfig((*Tester).TestKubeletPorts)},
+	{"deployment-rollout", "Deployment Rollout and Rollback", withoutConfig((*Tester).TestDeploymentRollout)},
+	{"hpa-scaling", "HPA Scaling Responsiveness", (*Tester).TestHPAScaling},
+	{"pod-churn", "Pod Startup at Scale", (*Tester).TestPodChurn},
+	{"namespace-churn", "Namespace Create/Delete Churn", withoutConfig((*Tester).TestNamespaceChurn)},
+	{"readiness-shift", "Readiness Traffic Shifting", withoutConfig((*Tester).TestReadinessTrafficShift)},
+	{"fault-latency", "Latency Fault Injection", (*Tester).TestLatencyInjection},
+	{"fault-loss", "Packet Loss Fault Injection", (*Tester).TestPacketLossInjection},
+	{"node-isolation", "Node Isolation Simulation", (*Tester).TestNodeIsolation},
+	{"dns-failure", "DNS Failure Injection", (*Tester).TestDNSFailure},
+	{"cni-restart", "CNI Agent Restart Resilience", (*Tester).TestCNIAgentRestart},
+}
+
+// BuiltinTests returns the built-in tests in the order of the --test-list help
+func BuiltinTests() []*BuiltinTest {
+	return append([]*BuiltinTest(nil), builtinTests...)
+}
+
+// LookupTest finds a test by name among the built-in tests and then the registered plugin tests
+func LookupTest(name string) (Test, bool) {
+	for _, test := range builtinTests {
+		if test.name == name {
+			return test, true
+		}
+	}
+	registeredTestsMu.Lock()
+	defer registeredTestsMu.Unlock()
+	test, ok := registeredTests[name]
+	return test, ok
+}
@@ -0,0 +1,379 @@
+package diagnostic
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BundleOptions controls what the support bundle contains
+type BundleOptions struct {
+	OutputPath string        // tar.gz file to write
+	ReportsDir string        // directory holding JSON reports and logs/ (default test_results)
+	MaxReports int           // number of most recent reports and log files to include
+	LogSince   time.Duration // how far back to fetch pod logs
+	Redactor   *Redactor     // masks node names, namespaces and IPs in every file when set
+}
+
+// BundleEntry is one file in the support bundle, listed in its index.json
+type BundleEntry struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Size        int    `json:"size"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BundleIndex is written to index.json at the root of the support bundle
+type BundleIndex struct {
+	CreatedAt     string        `json:"created_at"`
+	Namespace     string        `json:"namespace"`
+	CNI           CNIInfo       `json:"cni"`
+	KubeProxy     KubeProxyInfo `json:"kube_proxy"`
+	Entries       []BundleEntry `json:"entries"`
+	FailedEntries int           `json:"failed_entries"`
+}
+
+// bundleWriter adds files to a gzipped tar archive and records them in the index
+type bundleWriter struct {
+	tarWriter *tar.Writer
+	prefix    string
+	redactor  *Redactor
+	index     BundleIndex
+}
+
+// add writes a file to the bundle; a collection error is recorded in the index instead of the content
+func (b *bundleWriter) add(path, description string, content []byte, collectErr error) error {
+	if b.redactor != nil {
+		path = b.redactor.Redact(path)
+		description = b.redactor.Redact(description)
+		content = b.redactor.redactFile(path, content)
+		if collectErr != nil {
+			collectErr = fmt.Errorf("%s", b.redactor.Redact(collectErr.Error()))
+		}
+	}
+	entry := BundleEntry{Path: path, Description: description, Size: len(content)}
+	if collectErr != nil {
+		entry.Error = collectErr.Error()
+		b.index.FailedEntries++
+	}
+	b.index.Entries = append(b.index.Entries, entry)
+	if collectErr != nil && len(content) == 0 {
+		return nil
+	}
+	header := &tar.Header{
+		Name:    filepath.ToSlash(filepath.Join(b.prefix, path)),
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := b.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := b.tarWriter.Write(content)
+	return err
+}
+
+// addJSON marshals obj and adds it to the bundle
+func (b *bundleWriter) addJSON(path, description string, obj interface{}, collectErr error) error {
+	if collectErr != nil {
+		return b.add(path, description, nil, collectErr)
+	}
+	content, err := json.MarshalIndent(obj, "", "  ")
+	return b.add(path, description, content, err)
+}
+
+// recentFiles returns the newest limit files in dir matching any of the patterns
+func recentFiles(dir string, limit int, patterns ...string) []string {
+	var matches []string
+	for _, pattern := range patterns {
+		found, _ := filepath.Glob(filepath.Join(dir, pattern))
+		matches = append(matches, found...)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		infoI, errI := os.Stat(matches[i])
+		infoJ, errJ := os.Stat(matches[j])
+		if errI != nil || errJ != nil {
+			return matches[i] > matches[j]
+		}
+		return infoI.ModTime().After(infoJ.ModTime())
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// podLogs returns the logs of a pod container since the given duration
+func (t *Tester) podLogs(ctx context.Context, namespace, podName, container string, since time.Duration) ([]byte, error) {
+	sinceSeconds := int64(since.Seconds())
+	return t.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container:    container,
+		SinceSeconds: &sinceSeconds,
+	}).DoRaw(ctx)
+}
+
+// CollectSupportBundle gathers recent reports and logs, CNI configuration and status, CoreDNS configuration and
+// logs, node network information and warning events into a tar.gz with an index.json describing every file
+func (t *Tester) CollectSupportBundle(ctx context.Context, opts BundleOptions) (*BundleIndex, error) {
+	if opts.ReportsDir == "" {
+		opts.ReportsDir = "test_results"
+	}
+	if opts.MaxReports <= 0 {
+		opts.MaxReports = 5
+	}
+	if opts.LogSince <= 0 {
+		opts.LogSince = 30 * time.Minute
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.OutputPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %v", err)
+	}
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	bundle := &bundleWriter{
+		tarWriter: tar.NewWriter(gzipWriter),
+		prefix:    strings.TrimSuffix(filepath.Base(opts.OutputPath), ".tar.gz"),
+		redactor:  opts.Redactor,
+		index: BundleIndex{
+			CreatedAt: time.Now().Format(time.RFC3339),
+			Namespace: t.namespace,
+			CNI:       t.detectCNI(ctx),
+			KubeProxy: t.DetectKubeProxyMode(ctx),
+		},
+	}
+
+	steps := []func(context.Context, *bundleWriter, BundleOptions) error{
+		t.bundleReports,
+		t.bundleCNI,
+		t.bundleCoreDNS,
+		t.bundleNodes,
+		t.bundleEvents,
+	}
+	for _, step := range steps {
+		if err := step(ctx, bundle, opts); err != nil {
+			return nil, fmt.Errorf("failed to write support bundle: %v", err)
+		}
+	}
+
+	// The index is written last so it covers every entry
+	indexContent, err := json.MarshalIndent(bundle.index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if opts.Redactor != nil {
+		indexContent, err = opts.Redactor.RedactJSON(indexContent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := bundle.tarWriter.WriteHeader(&tar.Header{
+		Name:    filepath.ToSlash(filepath.Join(bundle.prefix, "index.json")),
+		Mode:    0644,
+		Size:    int64(len(indexContent)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := bundle.tarWriter.Write(indexContent); err != nil {
+		return nil, err
+	}
+	if err := bundle.tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(opts.OutputPath, archive.Bytes(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %v", opts.OutputPath, err)
+	}
+	return &bundle.index, nil
+}
+
+// bundleReports adds the most recent JSON reports and log files, from run directories and from the flat layout of
+// older versions
+func (t *Tester) bundleReports(ctx context.Context, bundle *bundleWriter, opts BundleOptions) error {
+	for _, file := range recentFiles(opts.ReportsDir, opts.MaxReports, "*/"+runReportFile, "k8s-diagnostic-results-*.json") {
+		content, err := os.ReadFile(file)
+		if err := bundle.add("reports/"+bundleRunPath(opts.ReportsDir, file), "JSON test report", content, err); err != nil {
+			return err
+		}
+	}
+	for _, file := range recentFiles(opts.ReportsDir, opts.MaxReports, "*/"+runLogFile, "logs/k8s-diagnostic-logs-*.log") {
+		content, err := os.ReadFile(file)
+		if err := bundle.add("logs/"+strings.TrimPrefix(bundleRunPath(opts.ReportsDir, file), "logs/"), "Test run log", content, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleRunPath is the path of a results file relative to the results directory, e.g. <run-id>/report.json
+func bundleRunPath(reportsDir, file string) string {
+	rel, err := filepath.Rel(reportsDir, file)
+	if err != nil {
+		return filepath.Base(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// bundleCNI adds the CNI DaemonSet, its configuration and the CNI's own status output from every agent
+func (t *Tester) bundleCNI(ctx context.Context, bundle *bundleWriter, opts BundleOptions) error {
+	cni := bundle.index.CNI
+	if cni.Name == CNIUnknown {
+		return bundle.add("cni/README.txt", "CNI detection result", []byte("No known CNI agent DaemonSet was found\n"), nil)
+	}
+
+	daemonSet, err := t.clientset.AppsV1().DaemonSets(cni.Namespace).Get(ctx, cni.DaemonSet, metav1.GetOptions{})
+	if err := bundle.addJSON("cni/daemonset.json", fmt.Sprintf("%s agent DaemonSet", cni.Name), daemonSet, err); err != nil {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+
+	switch cni.Name {
+	case CNICilium:
+		ciliumConfig, err := t.getCiliumConfig(ctx)
+		if err := bundle.addJSON("cni/cilium-config.json", "cilium-config ConfigMap data", ciliumConfig, err); err != nil {
+			return err
+		}
+	case CNICalico:
+		pools, err := t.listCalicoIPPools(ctx)
+		if err := bundle.addJSON("cni/calico-ippools.json", "Calico IP pools", pools, err); err != nil {
+			return err
+		}
+	}
+
+	pods, err := t.clientset.CoreV1().Pods(cni.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(daemonSet.Spec.Selector),
+	})
+	if err != nil {
+		return bundle.add("cni/pods.json", fmt.Sprintf("%s agent pods", cni.Name), nil, err)
+	}
+	if err := bundle.addJSON("cni/pods.json", fmt.Sprintf("%s agent pods", cni.Name), pods.Items, nil); err != nil {
+		return err
+	}
+
+	for _, pod := range pods.Items {
+		nodeName := pod.Spec.NodeName
+		container := ""
+		if len(pod.Spec.Containers) > 0 {
+			container = pod.Spec.Containers[0].Name
+		}
+		logs, err := t.podLogs(ctx, cni.Namespace, pod.Name, container, opts.LogSince)
+		if err := bundle.add(fmt.Sprintf("cni/logs/%s.log", nodeName), fmt.Sprintf("%s agent log on %s (last %s)", cni.Name, nodeName, opts.LogSince), logs, err); err != nil {
+			return err
+		}
+		if !isPodReady(&pod) {
+			continue
+		}
+
+		var status CommandOutput
+		switch cni.Name {
+		case CNICilium:
+			status, err = t.execCiliumCLI(ctx, pod.Name, "status --verbose", fmt.Sprintf("Cilium status on %s", nodeName))
+		case CNICalico:
+			status, err = t.execInPodWithOutput(ctx, cni.Namespace, pod.Name, "calico-node",
+				[]string{"birdcl", "-s", "/var/run/calico/bird.ctl", "show", "protocols"}, fmt.Sprintf("BIRD protocols on %s", nodeName))
+		default:
+			continue
+		}
+		if err := bundle.add(fmt.Sprintf("cni/status/%s.txt", nodeName), status.Description, []byte(status.Stdout+status.Stderr), err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleCoreDNS adds the CoreDNS Corefile and recent logs of every CoreDNS pod
+func (t *Tester) bundleCoreDNS(ctx context.Context, bundle *bundleWriter, opts BundleOptions) error {
+	configMap, err := t.clientset.CoreV1().ConfigMaps("kube-system").Get(ctx, "coredns", metav1.GetOptions{})
+	corefile := ""
+	if err == nil {
+		corefile = configMap.Data["Corefile"]
+	}
+	if err := bundle.add("coredns/Corefile", "CoreDNS configuration", []byte(corefile), err); err != nil {
+		return err
+	}
+
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=kube-dns"})
+	if err != nil {
+		return bundle.add("coredns/pods.json", "CoreDNS pods", nil, err)
+	}
+	for _, pod := range pods.Items {
+		logs, err := t.podLogs(ctx, "kube-system", pod.Name, "", opts.LogSince)
+		if err := bundle.add(fmt.Sprintf("coredns/logs/%s.log", pod.Name), fmt.Sprintf("CoreDNS log (last %s)", opts.LogSince), logs, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bundleNodeNetwork is the network-relevant subset of a Node
+type bundleNodeNetwork struct {
+	Name       string                 `json:"name"`
+	Addresses  []corev1.NodeAddress   `json:"addresses"`
+	PodCIDRs   []string               `json:"pod_cidrs,omitempty"`
+	Conditions []corev1.NodeCondition `json:"conditions"`
+	Kernel     string                 `json:"kernel_version"`
+	OSImage    string                 `json:"os_image"`
+	Runtime    string                 `json:"container_runtime"`
+	Kubelet    string                 `json:"kubelet_version"`
+	Taints     []corev1.Taint         `json:"taints,omitempty"`
+}
+
+// bundleNodes adds addresses, pod CIDRs, conditions and versions of every node
+func (t *Tester) bundleNodes(ctx context.Context, bundle *bundleWriter, opts BundleOptions) error {
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return bundle.add("nodes/network.json", "Node network information", nil, err)
+	}
+	var nodeInfo []bundleNodeNetwork
+	for _, node := range nodes.Items {
+		nodeInfo = append(nodeInfo, bundleNodeNetwork{
+			Name:       node.Name,
+			Addresses:  node.Status.Addresses,
+			PodCIDRs:   node.Spec.PodCIDRs,
+			Conditions: node.Status.Conditions,
+			Kernel:     node.Status.NodeInfo.KernelVersion,
+			OSImage:    node.Status.NodeInfo.OSImage,
+			Runtime:    node.Status.NodeInfo.ContainerRuntimeVersion,
+			Kubelet:    node.Status.NodeInfo.KubeletVersion,
+			Taints:     node.Spec.Taints,
+		})
+	}
+	if err := bundle.addJSON("nodes/network.json", "Node addresses, pod CIDRs, conditions and versions", nodeInfo, nil); err != nil {
+		return err
+	}
+	return bundle.addJSON("nodes/kube-proxy.json", "Detected kube-proxy mode", bundle.index.KubeProxy, nil)
+}
+
+// bundleEvents adds warning events from kube-system, the CNI namespace and the test namespace
+func (t *Tester) bundleEvents(ctx context.Context, bundle *bundleWriter, opts BundleOptions) error {
+	namespaces := []string{"kube-system", t.namespace}
+	if cni := bundle.index.CNI; cni.Namespace != "" && cni.Namespace != "kube-system" {
+		namespaces = append(namespaces, cni.Namespace)
+	}
+	for _, namespace := range namespaces {
+		events, err := t.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=Warning"})
+		var items []corev1.Event
+		if err == nil {
+			items = events.Items
+		}
+		if err := bundle.addJSON(fmt.Sprintf("events/%s.json", namespace), fmt.Sprintf("Warning events in %s", namespace), items, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
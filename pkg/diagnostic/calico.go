@@ -0,0 +1,346 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	calicoIPPoolGVR  = schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1", Resource: "ippools"}
+	calicoBGPPeerGVR = schema.GroupVersionResource{Group: "crd.projectcalico.org", Version: "v1", Resource: "bgppeers"}
+)
+
+// calicoFelixLogTailLines is how much of each calico-node log is scanned for Felix errors
+const calicoFelixLogTailLines = 500
+
+// calicoIPPool is the subset of an IPPool that decides how pod traffic is encapsulated
+type calicoIPPool struct {
+	Name        string
+	CIDR        string
+	IPIPMode    string
+	VXLANMode   string
+	NATOutgoing bool
+	Disabled    bool
+}
+
+// calicoNodeHealth is the aggregated health of one calico-node agent
+type calicoNodeHealth struct {
+	Node            string
+	Pod             string
+	Ready           bool
+	BGPEnabled      bool
+	PeersTotal      int
+	PeersUp         int
+	DownPeers       []string
+	FelixErrors     []string
+	FelixErrorCount int
+	Errors          []string
+}
+
+// Healthy reports whether the agent is ready with all BGP sessions established and no Felix errors
+func (h calicoNodeHealth) Healthy() bool {
+	return h.Ready && len(h.Errors) == 0 && len(h.DownPeers) == 0 && h.FelixErrorCount == 0
+}
+
+// parseBirdProtocols returns the BGP sessions from `birdcl show protocols` output as name -> established
+func parseBirdProtocols(output string) map[string]bool {
+	sessions := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "BGP" {
+			continue
+		}
+		sessions[fields[0]] = fields[3] == "up" && strings.Contains(line, "Established")
+	}
+	return sessions
+}
+
+// isFelixErrorLine reports whether a calico-node log line is an error logged by Felix
+func isFelixErrorLine(line string) bool {
+	return (strings.Contains(line, "[ERROR]") || strings.Contains(line, "[FATAL]")) && strings.Contains(line, "felix/")
+}
+
+// listCalicoIPPools reads the IPPool CRDs
+func (t *Tester) listCalicoIPPools(ctx context.Context) ([]calicoIPPool, error) {
+	list, err := t.dynamicClient.Resource(calicoIPPoolGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var pools []calicoIPPool
+	for _, item := range list.Items {
+		pool := calicoIPPool{Name: item.GetName()}
+		pool.CIDR, _, _ = unstructured.NestedString(item.Object, "spec", "cidr")
+		pool.IPIPMode, _, _ = unstructured.NestedString(item.Object, "spec", "ipipMode")
+		pool.VXLANMode, _, _ = unstructured.NestedString(item.Object, "spec", "vxlanMode")
+		pool.NATOutgoing, _, _ = unstructured.NestedBool(item.Object, "spec", "natOutgoing")
+		pool.Disabled, _, _ = unstructured.NestedBool(item.Object, "spec", "disabled")
+		if pool.IPIPMode == "" {
+			pool.IPIPMode = "Never"
+		}
+		if pool.VXLANMode == "" {
+			pool.VXLANMode = "Never"
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// calicoNetworkingBackend returns the CALICO_NETWORKING_BACKEND of the calico-node container, "bird" by default
+func calicoNetworkingBackend(pod *corev1.Pod) string {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != "calico-node" {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == "CALICO_NETWORKING_BACKEND" && env.Value != "" {
+				return env.Value
+			}
+		}
+	}
+	return "bird"
+}
+
+// collectCalicoHealth checks readiness, BGP sessions and recent Felix errors of every calico-node agent
+func (t *Tester) collectCalicoHealth(ctx context.Context, cni CNIInfo) ([]calicoNodeHealth, []CommandOutput, error) {
+	var commandOutputs []CommandOutput
+
+	pods, err := t.clientset.CoreV1().Pods(cni.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "k8s-app=calico-node",
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list calico-node pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil, fmt.Errorf("no calico-node pods found in %s namespace", cni.Namespace)
+	}
+
+	var agents []calicoNodeHealth
+	for _, pod := range pods.Items {
+		agent := calicoNodeHealth{
+			Node:       pod.Spec.NodeName,
+			Pod:        pod.Name,
+			Ready:      isPodReady(&pod),
+			BGPEnabled: calicoNetworkingBackend(&pod) == "bird",
+		}
+		if !agent.Ready {
+			agent.Errors = append(agent.Errors, fmt.Sprintf("calico-node pod not ready (phase %s)", pod.Status.Phase))
+			if isPodInCrashLoop(&pod) {
+				agent.Errors = append(agent.Errors, "calico-node in CrashLoopBackOff")
+			}
+			agents = append(agents, agent)
+			continue
+		}
+
+		// BGP sessions as seen by BIRD
+		if agent.BGPEnabled {
+			output, err := t.execInPodWithOutput(ctx, cni.Namespace, pod.Name, "calico-node",
+				[]string{"birdcl", "-s", "/var/run/calico/bird.ctl", "show", "protocols"},
+				fmt.Sprintf("BIRD protocols on %s", agent.Node))
+			commandOutputs = append(commandOutputs, output)
+			if err != nil {
+				agent.Errors = append(agent.Errors, fmt.Sprintf("birdcl failed: %v", err))
+			} else {
+				for name, established := range parseBirdProtocols(output.Stdout) {
+					agent.PeersTotal++
+					if established {
+						agent.PeersUp++
+					} else {
+						agent.DownPeers = append(agent.DownPeers, name)
+					}
+				}
+			}
+		}
+
+		// Felix dataplane errors in the recent log
+		tailLines := int64(calicoFelixLogTailLines)
+		logs, err := t.clientset.CoreV1().Pods(cni.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container: "calico-node",
+			TailLines: &tailLines,
+		}).DoRaw(ctx)
+		if err != nil {
+			agent.Errors = append(agent.Errors, fmt.Sprintf("could not read calico-node logs: %v", err))
+		} else {
+			for _, line := range strings.Split(string(logs), "\n") {
+				if !isFelixErrorLine(line) {
+					continue
+				}
+				agent.FelixErrorCount++
+				if len(agent.FelixErrors) < 3 {
+					agent.FelixErrors = append(agent.FelixErrors, strings.TrimSpace(line))
+				}
+			}
+		}
+
+		agents = append(agents, agent)
+	}
+
+	return agents, commandOutputs, nil
+}
+
+// TestCalicoHealth checks calico-node readiness, BGP peer sessions, IP pool encapsulation and recent Felix dataplane
+// errors, reporting them per node in the same form as the Cilium health check
+func (t *Tester) TestCalicoHealth(ctx context.Context) TestResult {
+	var details []string
+
+	cni := t.detectCNI(ctx)
+	if cni.Name != CNICalico {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Calico health check skipped - cluster CNI is %s", cni.Name),
+			Details: []string{fmt.Sprintf("ℹ️ Detected CNI: %s", cni.Name)},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Detected Calico (DaemonSet %s/%s)", cni.Namespace, cni.DaemonSet))
+
+	// Step 1: Pod-level status, the same check used before connectivity tests
+	podsHealthy, podIssue := t.checkCNIStatus(ctx)
+	if podsHealthy {
+		details = append(details, "✓ All calico-node pods running and ready")
+	} else {
+		details = append(details, fmt.Sprintf("✗ %s", podIssue))
+	}
+
+	var failures []string
+	if !podsHealthy {
+		failures = append(failures, podIssue)
+	}
+
+	// Step 2: IP pools and encapsulation mode
+	pools, err := t.listCalicoIPPools(ctx)
+	if err != nil {
+		details = append(details, fmt.Sprintf("⚠️ Could not read IP pools: %v", err))
+	} else {
+		details = append(details, "  Calico IP pools:")
+		details = append(details, fmt.Sprintf("  %-30s %-20s %-12s %-12s %-8s %s", "NAME", "CIDR", "IPIP", "VXLAN", "NAT", "STATE"))
+		enabled := 0
+		for _, pool := range pools {
+			state := "enabled"
+			if pool.Disabled {
+				state = "disabled"
+			} else {
+				enabled++
+			}
+			details = append(details, fmt.Sprintf("  %-30s %-20s %-12s %-12s %-8t %s",
+				pool.Name, pool.CIDR, pool.IPIPMode, pool.VXLANMode, pool.NATOutgoing, state))
+			if pool.IPIPMode != "Never" && pool.VXLANMode != "Never" {
+				details = append(details, fmt.Sprintf("✗ IP pool %s enables both IPIP and VXLAN", pool.Name))
+				failures = append(failures, fmt.Sprintf("IP pool %s enables both IPIP and VXLAN", pool.Name))
+			}
+		}
+		if enabled == 0 {
+			details = append(details, "✗ No enabled IP pool - new pods cannot get addresses")
+			failures = append(failures, "no enabled IP pool")
+		}
+		details = append(details, "  kubectl get ippools.crd.projectcalico.org -o yaml")
+	}
+
+	// Step 3: Explicitly configured BGP peers
+	if peers, err := t.dynamicClient.Resource(calicoBGPPeerGVR).List(ctx, metav1.ListOptions{}); err == nil && len(peers.Items) > 0 {
+		for _, peer := range peers.Items {
+			peerIP, _, _ := unstructured.NestedString(peer.Object, "spec", "peerIP")
+			asNumber, _, _ := unstructured.NestedInt64(peer.Object, "spec", "asNumber")
+			details = append(details, fmt.Sprintf("ℹ️ BGPPeer %s: %s AS %d", peer.GetName(), peerIP, asNumber))
+		}
+	}
+
+	// Step 4: Per-node agent health
+	agents, commandOutputs, err := t.collectCalicoHealth(ctx, cni)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Calico health check failed: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Agent Discovery",
+				TechnicalError:       err.Error(),
+				TroubleshootingHints: cniTroubleshootingHints(cni),
+			},
+		}
+	}
+
+	details = append(details, "  Calico agent health per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-20s %-10s %-12s %s", "NODE", "AGENT", "BGP", "FELIX ERR", "RESULT"))
+	felixErrors := 0
+	for _, agent := range agents {
+		marker := "✓"
+		if !agent.Healthy() {
+			marker = "✗"
+		}
+		bgp := "disabled"
+		if agent.BGPEnabled {
+			bgp = fmt.Sprintf("%d/%d", agent.PeersUp, agent.PeersTotal)
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-20s %-10s %-12d %s", agent.Node, agent.Pod, bgp, agent.FelixErrorCount, marker))
+	}
+	for _, agent := range agents {
+		for _, agentErr := range agent.Errors {
+			details = append(details, fmt.Sprintf("✗ %s: %s", agent.Node, agentErr))
+			failures = append(failures, fmt.Sprintf("%s: %s", agent.Node, agentErr))
+		}
+		if len(agent.DownPeers) > 0 {
+			details = append(details, fmt.Sprintf("✗ %s: BGP sessions not established: %s", agent.Node, strings.Join(agent.DownPeers, ", ")))
+			failures = append(failures, fmt.Sprintf("%s: %d of %d BGP sessions down", agent.Node, len(agent.DownPeers), agent.PeersTotal))
+		}
+		if agent.FelixErrorCount > 0 {
+			felixErrors += agent.FelixErrorCount
+			details = append(details, fmt.Sprintf("✗ %s: %d Felix errors in the last %d log lines", agent.Node, agent.FelixErrorCount, calicoFelixLogTailLines))
+			for _, line := range agent.FelixErrors {
+				details = append(details, fmt.Sprintf("    %s", line))
+			}
+			failures = append(failures, fmt.Sprintf("%s: Felix dataplane errors", agent.Node))
+		}
+	}
+	details = append(details, fmt.Sprintf("  kubectl -n %s exec ds/calico-node -c calico-node -- birdcl -s /var/run/calico/bird.ctl show protocols", cni.Namespace))
+
+	metrics := map[string]float64{
+		"agents":       float64(len(agents)),
+		"felix_errors": float64(felixErrors),
+	}
+
+	if len(failures) > 0 {
+		unhealthy := 0
+		for _, agent := range agents {
+			if !agent.Healthy() {
+				unhealthy++
+			}
+		}
+		message := fmt.Sprintf("Calico is unhealthy on %d of %d nodes", unhealthy, len(agents))
+		if unhealthy == 0 {
+			message = fmt.Sprintf("Calico configuration problem: %s", failures[0])
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Calico Agent Health",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"BGP sessions stuck in Connect/Active usually mean TCP 179 is blocked between nodes",
+					"IPIP needs IP protocol 4 and VXLAN needs UDP 4789 allowed between nodes",
+					fmt.Sprintf("Inspect Felix errors: kubectl logs -n %s ds/calico-node -c calico-node | grep ERROR", cni.Namespace),
+					"Check node status with calicoctl: calicoctl node status",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Calico healthy on all %d nodes - BGP sessions, IP pools and Felix OK", len(agents)),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
@@ -0,0 +1,372 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// canaryWeight is the percentage of requests the route sends to the canary version
+	canaryWeight = 10
+	// defaultCanaryRequests is the number of requests per routing path when no count is configured
+	defaultCanaryRequests = 200
+	// canaryTolerance is how many standard deviations the observed canary share may deviate from the weight
+	canaryTolerance = 3.0
+	// canaryMaxFailedPct fails a path when more requests than this percentage get no response
+	canaryMaxFailedPct = 5.0
+	// canaryTestHost is the host name of the weighted HTTPRoute attached to a Gateway
+	canaryTestHost = "canary.diagnostic.local"
+)
+
+// canarySplit counts the versions that served the requests sent through one routing path
+type canarySplit struct {
+	Path   string
+	Key    string // metrics prefix
+	Stable int
+	Canary int
+	Failed int
+	Output CommandOutput
+}
+
+// canaryPct is the measured share of the canary among the answered requests
+func (s canarySplit) canaryPct() float64 {
+	answered := s.Stable + s.Canary
+	if answered == 0 {
+		return 0
+	}
+	return float64(s.Canary) * 100 / float64(answered)
+}
+
+// evaluate checks the canary count against a binomial distribution with the configured weight and returns the
+// tolerance in percentage points; too many failed requests also fail the path
+func (s canarySplit) evaluate() (bool, float64, string) {
+	answered := s.Stable + s.Canary
+	total := answered + s.Failed
+	if answered == 0 {
+		return false, 0, "no request was answered"
+	}
+	p := float64(canaryWeight) / 100
+	tolerance := canaryTolerance * math.Sqrt(p*(1-p)/float64(answered)) * 100
+	if failedPct := float64(s.Failed) * 100 / float64(total); failedPct > canaryMaxFailedPct {
+		return false, tolerance, fmt.Sprintf("%d of %d requests failed", s.Failed, total)
+	}
+	if math.Abs(s.canaryPct()-canaryWeight) > tolerance {
+		return false, tolerance, fmt.Sprintf("canary served %.1f%% of requests, outside %d%% ± %.1f%%", s.canaryPct(), canaryWeight, tolerance)
+	}
+	return true, tolerance, ""
+}
+
+// createCanaryBackends creates the stable (v1) and canary (v2) echo server pods, a Service per version that the
+// weighted route points at, and a Service in front of both that mesh routes attach to
+func (t *Tester) createCanaryBackends(ctx context.Context, namespace string, annotations map[string]string) error {
+	for _, version := range []string{"v1", "v2"} {
+		name := "canary-" + version
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Labels:      map[string]string{"app": "canary", "version": version},
+				Annotations: annotations,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "echo",
+						Image: t.image(upgradeEchoImage),
+						Env: []corev1.EnvVar{
+							{Name: "PORT", Value: fmt.Sprintf("%d", meshServerPort)},
+						},
+						Ports: []corev1.ContainerPort{
+							{
+								ContainerPort: meshServerPort,
+							},
+						},
+					},
+				},
+				RestartPolicy: corev1.RestartPolicyNever,
+			},
+		}
+		if _, err := t.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create backend pod %s: %v", name, err)
+		}
+	}
+
+	services := map[string]map[string]string{
+		"canary":    {"app": "canary"},
+		"canary-v1": {"app": "canary", "version": "v1"},
+		"canary-v2": {"app": "canary", "version": "v2"},
+	}
+	for name, selector := range services {
+		appProtocol := "http"
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: selector,
+				Ports: []corev1.ServicePort{
+					{
+						Name:        "http",
+						Port:        meshServerPort,
+						TargetPort:  intstr.FromInt(meshServerPort),
+						AppProtocol: &appProtocol,
+					},
+				},
+			},
+		}
+		if _, err := t.clientset.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create service %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// createWeightedRoute creates an HTTPRoute splitting traffic between the version Services with the canary weight.
+// parentRef is a Gateway, or the canary Service itself for a mesh (GAMMA) route.
+func (t *Tester) createWeightedRoute(ctx context.Context, namespace, name string, parentRef map[string]interface{}, hostnames []interface{}) error {
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": "canary-v1", "port": int64(meshServerPort), "weight": int64(100 - canaryWeight)},
+					map[string]interface{}{"name": "canary-v2", "port": int64(meshServerPort), "weight": int64(canaryWeight)},
+				},
+			},
+		},
+	}
+	if len(hostnames) > 0 {
+		spec["hostnames"] = hostnames
+	}
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", httpRouteGVR.Group, httpRouteGVR.Version),
+			"kind":       "HTTPRoute",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"spec": spec,
+		},
+	}
+	_, err := t.dynamicClient.Resource(httpRouteGVR).Namespace(namespace).Create(ctx, route, metav1.CreateOptions{})
+	return err
+}
+
+// measureCanarySplit sends count requests from the client pod and counts which version served each one; the echo
+// server answers with "Request served by <pod name>"
+func (t *Tester) measureCanarySplit(ctx context.Context, namespace, clientPod, path, key, url, host string, count int) canarySplit {
+	curl := []string{"curl", "-s", "--max-time", "3"}
+	if host != "" {
+		curl = append(curl, "-H", "Host: "+host)
+	}
+	curl = append(curl, url)
+	script := fmt.Sprintf(`for i in $(seq 1 %d); do %s | grep -m1 "served by" || echo "request failed"; done`, count, shellJoin(curl...))
+	output, _ := t.execInPodWithOutput(ctx, namespace, clientPod, "netshoot", []string{"sh", "-c", script},
+		fmt.Sprintf("%d requests to %s via %s", count, url, path))
+
+	split := canarySplit{Path: path, Key: key, Output: output}
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		switch {
+		case strings.Contains(line, "served by canary-v1"):
+			split.Stable++
+		case strings.Contains(line, "served by canary-v2"):
+			split.Canary++
+		case strings.TrimSpace(line) != "":
+			split.Failed++
+		}
+	}
+	// Requests that produced no line at all, e.g. when the exec was cut short, count as failed
+	if missing := count - split.Stable - split.Canary - split.Failed; missing > 0 {
+		split.Failed += missing
+	}
+	return split
+}
+
+// TestWeightedRouting configures a 90/10 split between two backend versions through a Gateway API HTTPRoute and,
+// with Istio or Linkerd, a mesh route, and verifies the observed distribution is consistent with the weights
+func (t *Tester) TestWeightedRouting(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	count := config.CanaryRequests
+	if count <= 0 {
+		count = defaultCanaryRequests
+	}
+	gatewayRouteName := "canary-gateway-route"
+	meshRouteName := "canary-mesh-route"
+	clientPodName := "netshoot-canary-test"
+	meshNamespace := fmt.Sprintf("%s-canary-%d", t.namespace, time.Now().Unix())
+
+	// Step 1: Find the routing paths
+	gateway, gatewayErr := t.detectGateway(ctx)
+	mesh := t.detectMesh(ctx)
+	if gatewayErr != nil && mesh.Name == MeshNone {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Weighted routing test skipped - no programmed Gateway and no Istio or Linkerd control plane found",
+			Details: []string{fmt.Sprintf("ℹ️ Gateway path unavailable: %v", gatewayErr), "ℹ️ No istiod or linkerd-destination Deployment in the cluster"},
+		}
+	}
+
+	meshNamespaceCreated := false
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.dynamicClient.Resource(httpRouteGVR).Namespace(t.namespace).Delete(ctx, gatewayRouteName, metav1.DeleteOptions{})
+		for _, name := range []string{"canary", "canary-v1", "canary-v2"} {
+			t.clientset.CoreV1().Services(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		}
+		for _, name := range []string{"canary-v1", "canary-v2", clientPodName} {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		}
+		if meshNamespaceCreated {
+			t.clientset.CoreV1().Namespaces().Delete(ctx, meshNamespace, metav1.DeleteOptions{})
+		}
+	}
+	fail := func(stage, message string) TestResult {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   stage,
+				TechnicalError: message,
+			},
+		}
+	}
+	// setup creates the backends and the client in namespace and waits for them
+	setup := func(namespace string, annotations map[string]string) error {
+		if err := t.createCanaryBackends(ctx, namespace, annotations); err != nil {
+			return err
+		}
+		if err := t.createMeshClient(ctx, namespace, clientPodName, "", annotations); err != nil {
+			return err
+		}
+		for _, pod := range []string{"canary-v1", "canary-v2", clientPodName} {
+			if err := t.waitForPodReadyInNamespace(ctx, namespace, pod, 180*time.Second); err != nil {
+				return fmt.Errorf("pod %s did not become ready: %v", pod, err)
+			}
+		}
+		return nil
+	}
+
+	var splits []canarySplit
+
+	// Step 2: Gateway API path
+	if gatewayErr != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: %v", gatewayErr))
+	} else {
+		if err := setup(t.namespace, t.testPodAnnotations(ctx, t.namespace)); err != nil {
+			return fail("Backend Setup", err.Error())
+		}
+		details = append(details, fmt.Sprintf("✓ Backends canary-v1 (stable) and canary-v2 (canary) ready in %s", t.namespace))
+		parent := map[string]interface{}{"name": gateway.Name, "namespace": gateway.Namespace}
+		if err := t.createWeightedRoute(ctx, t.namespace, gatewayRouteName, parent, []interface{}{canaryTestHost}); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: could not create HTTPRoute: %v", err))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Attached HTTPRoute '%s' with weights %d/%d to Gateway %s/%s listener %s",
+				gatewayRouteName, 100-canaryWeight, canaryWeight, gateway.Namespace, gateway.Name, gateway.Listener))
+			// Give the gateway controller a moment to program the route
+			sleepContext(ctx, 5*time.Second)
+			splits = append(splits, t.measureCanarySplit(ctx, t.namespace, clientPodName,
+				fmt.Sprintf("Gateway (%s/%s)", gateway.Namespace, gateway.Name), "gateway",
+				fmt.Sprintf("http://%s:%d/", httpTargetForIP(gateway.Address), gateway.Port), canaryTestHost, count))
+		}
+	}
+
+	// Step 3: Mesh path - an HTTPRoute attached to the canary Service (GAMMA), served by the client's sidecar
+	if mesh.Name == MeshNone {
+		details = append(details, "ℹ️ Mesh path skipped: no Istio or Linkerd control plane found")
+	} else {
+		if err := t.createMeshTestNamespace(ctx, meshNamespace, mesh, true); err != nil {
+			return fail("Backend Setup", err.Error())
+		}
+		meshNamespaceCreated = true
+		if err := setup(meshNamespace, meshPodAnnotations(mesh)); err != nil {
+			return fail("Backend Setup", err.Error())
+		}
+		details = append(details, fmt.Sprintf("✓ Meshed backends and client ready in %s", meshNamespace))
+		parent := map[string]interface{}{"group": "", "kind": "Service", "name": "canary", "port": int64(meshServerPort)}
+		if err := t.createWeightedRoute(ctx, meshNamespace, meshRouteName, parent, nil); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Mesh path skipped: could not create HTTPRoute for Service canary: %v", err))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Attached HTTPRoute '%s' with weights %d/%d to Service %s/canary",
+				meshRouteName, 100-canaryWeight, canaryWeight, meshNamespace))
+			sleepContext(ctx, 5*time.Second)
+			splits = append(splits, t.measureCanarySplit(ctx, meshNamespace, clientPodName, fmt.Sprintf("Mesh (%s)", mesh.Name), "mesh",
+				fmt.Sprintf("http://canary.%s.svc.cluster.local:%d/", meshNamespace, meshServerPort), "", count))
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up weighted routing test resources")
+
+	if len(splits) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Weighted routing test skipped - no weighted route could be created",
+			Details: details,
+		}
+	}
+
+	// Step 4: Compare each measured split with the weights
+	metrics := map[string]float64{}
+	var commandOutputs []CommandOutput
+	var failures []string
+	for _, split := range splits {
+		commandOutputs = append(commandOutputs, split.Output)
+		metrics[split.Key+".canary_pct"] = split.canaryPct()
+		metrics[split.Key+".requests"] = float64(split.Stable + split.Canary + split.Failed)
+		metrics[split.Key+".failed"] = float64(split.Failed)
+
+		ok, tolerance, reason := split.evaluate()
+		measured := fmt.Sprintf("%s: stable %d, canary %d, failed %d - measured split %.1f/%.1f (expected %d/%d ± %.1f)",
+			split.Path, split.Stable, split.Canary, split.Failed, 100-split.canaryPct(), split.canaryPct(), 100-canaryWeight, canaryWeight, tolerance)
+		if ok {
+			details = append(details, "✓ "+measured)
+		} else {
+			details = append(details, "✗ "+measured)
+			failures = append(failures, fmt.Sprintf("%s: %s", split.Path, reason))
+		}
+	}
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Weighted routing does not match the %d/%d weights: %s", 100-canaryWeight, canaryWeight, strings.Join(failures, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Traffic Split Verification",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check the route was accepted and its backends resolved: kubectl get httproutes -A -o yaml (status.parents[].conditions)",
+					"A split of 100/0 usually means the route is not programmed and traffic reaches one Service directly",
+					"Weights are applied per request; clients reusing one connection through an L4 proxy see per-connection splits instead",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Weighted routing verified via %d path(s) - measured split within tolerance of %d/%d", len(splits), 100-canaryWeight, canaryWeight),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// captureDuration is how long each tcpdump runs while the failing probe is repeated
+const captureDuration = 8 * time.Second
+
+// defaultCaptureDir is used when the run does not provide an artifact directory
+const defaultCaptureDir = "test_results/captures"
+
+// PacketCapture references a pcap file captured while a failing probe was repeated
+type PacketCapture struct {
+	Node      string `json:"node,omitempty"`
+	Pod       string `json:"pod"`
+	Interface string `json:"interface"`
+	Filter    string `json:"filter"`
+	File      string `json:"file,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// captureProbe describes a failing probe to repeat under capture
+type captureProbe struct {
+	Name       string   // file name prefix, e.g. "pod-to-pod-cross-node"
+	SourcePod  string   // netshoot pod in the test namespace issuing the probe
+	TargetIP   string   // destination address, omitted from the filter when empty (DNATed service traffic)
+	TargetNode string   // node of the destination, also captured with CaptureNodes
+	Port       int      // TCP destination port, 0 for ICMP
+	Reprobe    []string // command re-run in the source pod while capturing
+}
+
+// captureFilter builds the tcpdump filter for the probe, scoped as closely to its 5-tuple as the datapath allows
+func captureFilter(sourceIP string, probe captureProbe) string {
+	parts := []string{"icmp"}
+	if probe.Port > 0 {
+		parts = []string{fmt.Sprintf("tcp port %d", probe.Port)}
+	}
+	parts = append(parts, fmt.Sprintf("host %s", sourceIP))
+	if probe.TargetIP != "" {
+		parts = append(parts, fmt.Sprintf("host %s", probe.TargetIP))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// runCapture runs tcpdump in the pod for captureDuration and writes the returned pcap to file
+func (t *Tester) runCapture(ctx context.Context, capture *PacketCapture) {
+	script := fmt.Sprintf("timeout %d tcpdump -i %s -U -w /tmp/capture.pcap \"$1\" >/dev/null 2>&1; base64 /tmp/capture.pcap && rm -f /tmp/capture.pcap",
+		int(captureDuration.Seconds()), capture.Interface)
+	output, err := t.execInPod(ctx, t.namespace, capture.Pod, "netshoot", []string{"sh", "-c", script, "capture", capture.Filter})
+	if err != nil {
+		capture.Error = fmt.Sprintf("tcpdump failed: %v", err)
+		return
+	}
+	pcap, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(output), ""))
+	if err != nil || len(pcap) == 0 {
+		capture.Error = "tcpdump produced no capture file"
+		return
+	}
+	if err := os.WriteFile(capture.File, pcap, 0644); err != nil {
+		capture.Error = fmt.Sprintf("failed to write %s: %v", capture.File, err)
+		capture.File = ""
+	}
+}
+
+// capturePackets repeats a failing probe while tcpdump runs in the source pod and, with CaptureNodes, on the
+// source and target nodes through privileged hostNetwork pods. The pcaps are saved under config.CaptureDir.
+func (t *Tester) capturePackets(ctx context.Context, config TestConfig, probe captureProbe) []PacketCapture {
+	dir := config.CaptureDir
+	if dir == "" {
+		dir = defaultCaptureDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return []PacketCapture{{Pod: probe.SourcePod, Error: fmt.Sprintf("failed to create %s: %v", dir, err)}}
+	}
+
+	sourcePod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, probe.SourcePod, metav1.GetOptions{})
+	if err != nil || sourcePod.Status.PodIP == "" {
+		return []PacketCapture{{Pod: probe.SourcePod, Error: fmt.Sprintf("could not read source pod IP: %v", err)}}
+	}
+	filter := captureFilter(sourcePod.Status.PodIP, probe)
+	fileName := func(suffix string) string {
+		return filepath.Join(dir, fmt.Sprintf("%s-%s.pcap", probe.Name, suffix))
+	}
+
+	captures := []PacketCapture{{
+		Node:      sourcePod.Spec.NodeName,
+		Pod:       probe.SourcePod,
+		Interface: "any",
+		Filter:    filter,
+		File:      fileName(probe.SourcePod),
+	}}
+
+	// Optional node-level captures show whether packets leave the source node and reach the target node
+	var debugPods []string
+	if config.CaptureNodes {
+		seen := make(map[string]bool)
+		for _, nodeName := range []string{sourcePod.Spec.NodeName, probe.TargetNode} {
+			if nodeName == "" || seen[nodeName] {
+				continue
+			}
+			seen[nodeName] = true
+			debugPodName := fmt.Sprintf("capture-%s", nodeName)
+			if len(debugPodName) > 63 {
+				debugPodName = strings.TrimRight(debugPodName[:63], "-.")
+			}
+			capture := PacketCapture{Node: nodeName, Pod: debugPodName, Interface: "any", Filter: filter, File: fileName("node-" + nodeName)}
+			if _, err := t.createPrivilegedDebugPod(ctx, debugPodName, nodeName); err != nil {
+				capture.Error = fmt.Sprintf("failed to create privileged capture pod: %v", err)
+				capture.File = ""
+			} else if err := t.waitForPodReady(ctx, debugPodName, 60*time.Second); err != nil {
+				capture.Error = fmt.Sprintf("privileged capture pod did not become ready: %v", err)
+				capture.File = ""
+			}
+			debugPods = append(debugPods, debugPodName)
+			captures = append(captures, capture)
+		}
+	}
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, name := range debugPods {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := range captures {
+		if captures[i].Error != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(capture *PacketCapture) {
+			defer wg.Done()
+			t.runCapture(ctx, capture)
+		}(&captures[i])
+	}
+
+	// Give tcpdump time to attach, then repeat the failing probe inside the capture window
+	sleepContext(ctx, 2*time.Second)
+	t.execInPod(ctx, t.namespace, probe.SourcePod, "netshoot", probe.Reprobe)
+	wg.Wait()
+
+	return captures
+}
+
+// attachPacketCaptures captures the failing probe when --capture-on-failure is set and references the pcaps in the
+// result's diagnostics and details
+func (t *Tester) attachPacketCaptures(ctx context.Context, config TestConfig, result *TestResult, details *[]string, probe captureProbe) {
+	if !config.CaptureOnFailure || result.Success {
+		return
+	}
+	captures := t.capturePackets(ctx, config, probe)
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	result.DetailedDiagnostics.PacketCaptures = append(result.DetailedDiagnostics.PacketCaptures, captures...)
+
+	for _, capture := range captures {
+		if capture.Error != "" {
+			*details = append(*details, fmt.Sprintf("⚠️ Packet capture in %s failed: %s", capture.Pod, capture.Error))
+			continue
+		}
+		*details = append(*details, fmt.Sprintf("ℹ️ Packet capture saved: %s (%s on %s, filter '%s')", capture.File, capture.Pod, capture.Node, capture.Filter))
+	}
+	*details = append(*details, "  tcpdump -nn -r <file> (or open it in Wireshark)")
+}
+
+// pingCaptureProbe describes a failed pod-to-pod ping for capture
+func (t *Tester) pingCaptureProbe(ctx context.Context, name, fromPod, toPod, toNode string) captureProbe {
+	targetIP := ""
+	if pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, toPod, metav1.GetOptions{}); err == nil {
+		targetIP = pod.Status.PodIP
+	}
+	return captureProbe{
+		Name:       name,
+		SourcePod:  fromPod,
+		TargetIP:   targetIP,
+		TargetNode: toNode,
+		Reprobe:    []string{"ping", "-c", "3", "-W", "1", targetIP},
+	}
+}
+
+// httpCaptureProbe describes a failed HTTP probe for capture; the destination is left out of the filter since
+// service traffic is translated on its way to the backend
+func httpCaptureProbe(name, fromPod, target string, port int) captureProbe {
+	return captureProbe{
+		Name:      name,
+		SourcePod: fromPod,
+		Port:      port,
+		Reprobe:   []string{"curl", "-s", "-o", "/dev/null", "--max-time", "5", fmt.Sprintf("http://%s", target)},
+	}
+}
@@ -0,0 +1,229 @@
+package diagnostic
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultCertExpiryWindow is how far ahead certificate expiry is flagged when no window is configured
+const defaultCertExpiryWindow = 30 * 24 * time.Hour
+
+// certificateExpiry is the expiry of one inspected certificate
+type certificateExpiry struct {
+	Source   string // "apiserver", "kubelet" or "cert-manager"
+	Name     string
+	Subject  string
+	NotAfter time.Time
+	Error    string
+}
+
+// apiServerCertificate reads the serving certificate presented at the kubeconfig's API server address
+func (t *Tester) apiServerCertificate(ctx context.Context) certificateExpiry {
+	expiry := certificateExpiry{Source: "apiserver", Name: t.config.Host}
+	host, err := url.Parse(t.config.Host)
+	if err != nil || host.Host == "" {
+		expiry.Error = fmt.Sprintf("cannot parse API server address %q", t.config.Host)
+		return expiry
+	}
+	address := host.Host
+	if host.Port() == "" {
+		address = net.JoinHostPort(host.Hostname(), "443")
+	}
+	// Only the presented certificate is read, trust is already established by the client configuration
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+		Config: &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         host.Hostname(),
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		expiry.Error = fmt.Sprintf("TLS connection to %s failed: %v", address, err)
+		return expiry
+	}
+	defer conn.Close()
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		expiry.Error = fmt.Sprintf("%s presented no certificate", address)
+		return expiry
+	}
+	expiry.Subject = certificates[0].Subject.String()
+	expiry.NotAfter = certificates[0].NotAfter
+	return expiry
+}
+
+// kubeletCertificates reads each node's kubelet serving certificate on port 10250 from a pod
+func (t *Tester) kubeletCertificates(ctx context.Context, podName string) ([]certificateExpiry, []CommandOutput) {
+	var expiries []certificateExpiry
+	var outputs []CommandOutput
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return []certificateExpiry{{Source: "kubelet", Name: "nodes", Error: fmt.Sprintf("failed to list nodes: %v", err)}}, nil
+	}
+	for _, node := range nodes.Items {
+		expiry := certificateExpiry{Source: "kubelet", Name: node.Name}
+		nodeIP := ""
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				nodeIP = address.Address
+				break
+			}
+		}
+		if nodeIP == "" {
+			expiry.Error = "node has no InternalIP"
+			expiries = append(expiries, expiry)
+			continue
+		}
+		target := net.JoinHostPort(nodeIP, "10250")
+		output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+			[]string{"sh", "-c", fmt.Sprintf("timeout 10 openssl s_client -connect %s </dev/null 2>/dev/null | openssl x509 -noout -subject -issuer -enddate", target)},
+			fmt.Sprintf("Read the kubelet serving certificate of %s", node.Name))
+		outputs = append(outputs, output)
+		info := parseOpenSSLSession(output.Stdout)
+		if info.NotAfter.IsZero() {
+			expiry.Error = fmt.Sprintf("no certificate read from %s", target)
+		}
+		expiry.Subject = info.Subject
+		expiry.NotAfter = info.NotAfter
+		expiries = append(expiries, expiry)
+	}
+	return expiries, outputs
+}
+
+// certManagerCertificates reads status.notAfter of the cert-manager Certificates in the test namespace and reports
+// whether cert-manager is installed. Other list errors, e.g. Forbidden, are returned as an unreadable certificate.
+func (t *Tester) certManagerCertificates(ctx context.Context) ([]certificateExpiry, bool) {
+	list, err := t.dynamicClient.Resource(certManagerCertificateGVR).Namespace(t.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, false
+	}
+	if err != nil {
+		// Forbidden or a timeout is not an absent cert-manager: the certificates were not checked
+		return []certificateExpiry{{Source: "cert-manager", Name: "certificates", Error: fmt.Sprintf("failed to list Certificates: %v", err)}}, true
+	}
+	var expiries []certificateExpiry
+	for _, item := range list.Items {
+		expiry := certificateExpiry{Source: "cert-manager", Name: fmt.Sprintf("%s/%s", item.GetNamespace(), item.GetName())}
+		notAfter, _, _ := unstructured.NestedString(item.Object, "status", "notAfter")
+		if parsed, err := time.Parse(time.RFC3339, notAfter); err == nil {
+			expiry.NotAfter = parsed
+		} else {
+			expiry.Error = "certificate not issued (no status.notAfter)"
+		}
+		expiry.Subject, _, _ = unstructured.NestedString(item.Object, "spec", "secretName")
+		expiries = append(expiries, expiry)
+	}
+	return expiries, true
+}
+
+// TestCertificateExpiry inspects the API server serving certificate, every kubelet serving certificate and the
+// cert-manager Certificates in the test namespace, flagging certificates that expire within the configured window
+func (t *Tester) TestCertificateExpiry(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	podName := "netshoot-cert-expiry-test"
+	window := config.CertExpiryWindow
+	if window <= 0 {
+		window = defaultCertExpiryWindow
+	}
+
+	// Step 1: API server and cert-manager certificates need no pod
+	certificates := []certificateExpiry{t.apiServerCertificate(ctx)}
+	certManager, installed := t.certManagerCertificates(ctx)
+	if !installed {
+		details = append(details, "ℹ️ cert-manager Certificates not checked - cert-manager is not installed")
+	}
+
+	// Step 2: Kubelet certificates are read from a pod, since node addresses are often unreachable from the tool
+	var commandOutputs []CommandOutput
+	if _, err := t.createNetshootPod(ctx, podName, ""); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Kubelet certificates not checked - failed to create pod: %v", err))
+	} else {
+		defer t.cleanupPod(ctx, podName)
+		if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Kubelet certificates not checked - pod not ready: %v", err))
+		} else {
+			kubelet, outputs := t.kubeletCertificates(ctx, podName)
+			certificates = append(certificates, kubelet...)
+			commandOutputs = outputs
+		}
+	}
+	certificates = append(certificates, certManager...)
+	sort.SliceStable(certificates, func(i, j int) bool { return certificates[i].Source < certificates[j].Source })
+
+	// Step 3: Compare every expiry against the window
+	metrics := map[string]float64{}
+	var expiring, unreadable []string
+	earliest := time.Time{}
+	details = append(details, fmt.Sprintf("  %-14s %-40s %-22s %s", "SOURCE", "NAME", "NOT AFTER", "REMAINING"))
+	for _, certificate := range certificates {
+		if certificate.Error != "" {
+			unreadable = append(unreadable, fmt.Sprintf("%s %s: %s", certificate.Source, certificate.Name, certificate.Error))
+			details = append(details, fmt.Sprintf("  %-14s %-40s %-22s %s", certificate.Source, certificate.Name, "-", certificate.Error))
+			continue
+		}
+		remaining := time.Until(certificate.NotAfter)
+		details = append(details, fmt.Sprintf("  %-14s %-40s %-22s %.1f days", certificate.Source, certificate.Name,
+			certificate.NotAfter.UTC().Format("2006-01-02 15:04 MST"), remaining.Hours()/24))
+		if earliest.IsZero() || certificate.NotAfter.Before(earliest) {
+			earliest = certificate.NotAfter
+		}
+		switch {
+		case remaining <= 0:
+			expiring = append(expiring, fmt.Sprintf("%s certificate %s expired on %s", certificate.Source, certificate.Name, certificate.NotAfter.UTC().Format(time.RFC3339)))
+		case remaining < window:
+			expiring = append(expiring, fmt.Sprintf("%s certificate %s expires in %.1f days", certificate.Source, certificate.Name, remaining.Hours()/24))
+		}
+	}
+	if !earliest.IsZero() {
+		metrics["min_days_remaining"] = time.Until(earliest).Hours() / 24
+	}
+	// Certificates that could not be read, e.g. when listing is forbidden, leave a passing check incomplete
+	var warnings []string
+	for _, problem := range unreadable {
+		details = append(details, fmt.Sprintf("⚠️ %s", problem))
+		warnings = append(warnings, fmt.Sprintf("%s not checked", problem))
+	}
+
+	if len(expiring) > 0 {
+		for _, problem := range expiring {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d certificates expire within %.0f days", len(expiring), window.Hours()/24),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Certificate Expiry",
+				TechnicalError: strings.Join(expiring, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"On kubeadm clusters check and renew control-plane certificates: kubeadm certs check-expiration / kubeadm certs renew all",
+					"Kubelet serving certificates rotate with serverTLSBootstrap: true and approved CSRs - check kubectl get csr for pending requests",
+					"cert-manager renews before expiry - a certificate close to expiry means renewal is failing: kubectl describe certificate -n <namespace> <name>",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success:  true,
+		Message:  fmt.Sprintf("%d certificates valid for more than %.0f days", len(certificates)-len(unreadable), window.Hours()/24),
+		Details:  details,
+		Metrics:  metrics,
+		Warnings: warnings,
+	}
+}
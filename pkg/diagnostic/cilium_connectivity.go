@@ -0,0 +1,198 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ciliumCLIOutputLimit caps how much of the CLI output is kept in the report
+const ciliumCLIOutputLimit = 4000
+
+// ciliumJUnitSuites is the JUnit report written by `cilium connectivity test --junit-file`
+type ciliumJUnitSuites struct {
+	Suites []ciliumJUnitSuite `xml:"testsuite"`
+}
+
+// ciliumJUnitSuite is one suite of the connectivity JUnit report
+type ciliumJUnitSuite struct {
+	Name  string            `xml:"name,attr"`
+	Cases []ciliumJUnitCase `xml:"testcase"`
+}
+
+// ciliumJUnitCase is one connectivity scenario and its outcome
+type ciliumJUnitCase struct {
+	Name    string  `xml:"name,attr"`
+	Time    float64 `xml:"time,attr"`
+	Failure *struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	} `xml:"failure"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// tailOutput keeps the last limit bytes of s
+func tailOutput(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return "..." + s[len(s)-limit:]
+}
+
+// TestCiliumConnectivitySuite runs the upstream connectivity suite with the default configuration
+func (t *Tester) TestCiliumConnectivitySuite(ctx context.Context) TestResult {
+	return t.TestCiliumConnectivitySuiteWithConfig(ctx, TestConfig{})
+}
+
+// TestCiliumConnectivitySuiteWithConfig invokes `cilium connectivity test` when the Cilium CLI is installed, parses
+// its JUnit report and folds each scenario into this test's result so the unified JSON report carries both
+func (t *Tester) TestCiliumConnectivitySuiteWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+
+	// Step 1: Locate the Cilium CLI
+	cliPath, err := exec.LookPath("cilium")
+	if err != nil {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Cilium connectivity suite skipped - cilium CLI not found in PATH",
+			Details: []string{
+				"ℹ️ Install the Cilium CLI to include the upstream suite: https://github.com/cilium/cilium-cli/releases",
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Found Cilium CLI at %s", cliPath))
+
+	junitDir, err := os.MkdirTemp("", "cilium-connectivity-*")
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create temp directory: %v", err),
+			Details: details,
+		}
+	}
+	defer os.RemoveAll(junitDir)
+	junitFile := filepath.Join(junitDir, "junit.xml")
+
+	// Step 2: Run the suite in its own namespace so it never collides with the diagnostic resources
+	args := []string{"connectivity", "test",
+		"--test-namespace", fmt.Sprintf("%s-cilium-test", t.namespace),
+		"--junit-file", junitFile}
+	args = append(args, config.CiliumConnectivityArgs...)
+	commandLine := "cilium " + strings.Join(args, " ")
+	details = append(details, fmt.Sprintf("  %s", commandLine))
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cliPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	start := time.Now()
+	runErr := cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if runErr != nil {
+		exitCode = -1
+	}
+	output := CommandOutput{
+		Command:     commandLine,
+		ExitCode:    exitCode,
+		Stdout:      tailOutput(stdout.String(), ciliumCLIOutputLimit),
+		Stderr:      tailOutput(stderr.String(), ciliumCLIOutputLimit),
+		Duration:    time.Since(start).Round(time.Second).String(),
+		Description: "Cilium CLI connectivity test",
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Suite finished in %s with exit code %d", output.Duration, exitCode))
+
+	// Step 3: Parse the JUnit report
+	report, err := os.ReadFile(junitFile)
+	var suites ciliumJUnitSuites
+	if err == nil {
+		err = xml.Unmarshal(report, &suites)
+	}
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Cilium connectivity suite produced no readable JUnit report (exit code %d)", exitCode),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Cilium Connectivity Suite",
+				TechnicalError: err.Error(),
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					"Check the CLI can reach the cluster: cilium status",
+					"Older CLI versions do not support --junit-file; upgrade the Cilium CLI",
+				},
+			},
+		}
+	}
+
+	// Step 4: Fold scenarios into the result
+	passed, failed, skipped := 0, 0, 0
+	var failedNames []string
+	for _, suite := range suites.Suites {
+		for _, testCase := range suite.Cases {
+			switch {
+			case testCase.Failure != nil:
+				failed++
+				failedNames = append(failedNames, testCase.Name)
+				message := strings.TrimSpace(testCase.Failure.Message)
+				if message == "" {
+					message = strings.TrimSpace(testCase.Failure.Text)
+				}
+				details = append(details, fmt.Sprintf("✗ %s (%.1fs): %s", testCase.Name, testCase.Time, tailOutput(message, 300)))
+			case testCase.Skipped != nil:
+				skipped++
+				details = append(details, fmt.Sprintf("ℹ️ %s skipped", testCase.Name))
+			default:
+				passed++
+				details = append(details, fmt.Sprintf("✓ %s (%.1fs)", testCase.Name, testCase.Time))
+			}
+		}
+	}
+	metrics := map[string]float64{
+		"scenarios_passed":  float64(passed),
+		"scenarios_failed":  float64(failed),
+		"scenarios_skipped": float64(skipped),
+	}
+
+	if failed > 0 || runErr != nil {
+		message := fmt.Sprintf("Cilium connectivity suite: %d of %d scenarios failed", failed, passed+failed)
+		if failed == 0 {
+			message = fmt.Sprintf("Cilium connectivity suite exited with code %d", exitCode)
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Cilium Connectivity Suite",
+				TechnicalError: strings.Join(failedNames, ", "),
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					"Re-run a single scenario with more output: cilium connectivity test --test <name> -v",
+					"Scenarios needing external access (to-fqdns, to-cidr) fail on air-gapped clusters; exclude them with --test '!<name>'",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Cilium connectivity suite passed - %d scenarios passed, %d skipped", passed, skipped),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: []CommandOutput{output},
+		},
+	}
+}
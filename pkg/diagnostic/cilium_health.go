@@ -0,0 +1,266 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ciliumHealthRatio matches the "<healthy>/<total>" summaries in `cilium-dbg status` output
+var ciliumHealthRatio = regexp.MustCompile(`(\d+)/(\d+)`)
+
+// ciliumTransientEndpointStates are endpoint states that resolve on their own and are not reported as failures
+var ciliumTransientEndpointStates = map[string]bool{
+	"ready":                 true,
+	"regenerating":          true,
+	"waiting-to-regenerate": true,
+	"restoring":             true,
+}
+
+// ciliumEndpointRecord is the subset of `cilium-dbg endpoint list -o json` used for health aggregation
+type ciliumEndpointRecord struct {
+	ID     int `json:"id"`
+	Status struct {
+		State               string `json:"state"`
+		ExternalIdentifiers struct {
+			K8sNamespace string `json:"k8s-namespace"`
+			K8sPodName   string `json:"k8s-pod-name"`
+		} `json:"external-identifiers"`
+	} `json:"status"`
+}
+
+// ciliumAgentHealth is the aggregated health of one Cilium agent and the endpoints it manages
+type ciliumAgentHealth struct {
+	Node               string
+	Pod                string
+	Ready              bool
+	EndpointsTotal     int
+	UnhealthyEndpoints []string
+	ControllersHealthy int
+	ControllersTotal   int
+	NodesReachable     int
+	NodesTotal         int
+	Errors             []string
+}
+
+// Healthy reports whether the agent, its endpoints, controllers and health probes are all OK
+func (h ciliumAgentHealth) Healthy() bool {
+	return h.Ready && len(h.Errors) == 0 && len(h.UnhealthyEndpoints) == 0 &&
+		h.ControllersHealthy == h.ControllersTotal && h.NodesReachable == h.NodesTotal
+}
+
+// parseCiliumStatusRatio returns the healthy/total pair from the status line starting with prefix
+func parseCiliumStatusRatio(statusOutput, prefix string) (int, int, bool) {
+	for _, line := range strings.Split(statusOutput, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		match := ciliumHealthRatio.FindStringSubmatch(line)
+		if len(match) < 3 {
+			return 0, 0, false
+		}
+		healthy, _ := strconv.Atoi(match[1])
+		total, _ := strconv.Atoi(match[2])
+		return healthy, total, true
+	}
+	return 0, 0, false
+}
+
+// collectCiliumHealth runs `status --verbose` and `endpoint list` in every Cilium agent and aggregates the results per node
+func (t *Tester) collectCiliumHealth(ctx context.Context) ([]ciliumAgentHealth, []CommandOutput, error) {
+	var commandOutputs []CommandOutput
+
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: "k8s-app=cilium",
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list Cilium agent pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil, fmt.Errorf("no Cilium pods found in kube-system namespace")
+	}
+
+	var agents []ciliumAgentHealth
+	for _, pod := range pods.Items {
+		agent := ciliumAgentHealth{
+			Node:  pod.Spec.NodeName,
+			Pod:   pod.Name,
+			Ready: isPodReady(&pod),
+		}
+		if !agent.Ready {
+			agent.Errors = append(agent.Errors, fmt.Sprintf("agent pod not ready (phase %s)", pod.Status.Phase))
+			if isPodInCrashLoop(&pod) {
+				agent.Errors = append(agent.Errors, "agent in CrashLoopBackOff")
+			}
+			agents = append(agents, agent)
+			continue
+		}
+
+		statusOutput, err := t.execCiliumCLI(ctx, pod.Name, "status --verbose", fmt.Sprintf("Cilium status on %s", agent.Node))
+		commandOutputs = append(commandOutputs, statusOutput)
+		if err != nil {
+			agent.Errors = append(agent.Errors, fmt.Sprintf("status failed: %v", err))
+		} else {
+			if healthy, total, ok := parseCiliumStatusRatio(statusOutput.Stdout, "Controller Status:"); ok {
+				agent.ControllersHealthy, agent.ControllersTotal = healthy, total
+			}
+			if reachable, total, ok := parseCiliumStatusRatio(statusOutput.Stdout, "Cluster health:"); ok {
+				agent.NodesReachable, agent.NodesTotal = reachable, total
+			}
+		}
+
+		endpointOutput, err := t.execCiliumCLI(ctx, pod.Name, "endpoint list -o json", fmt.Sprintf("Cilium endpoints on %s", agent.Node))
+		commandOutputs = append(commandOutputs, endpointOutput)
+		if err != nil {
+			agent.Errors = append(agent.Errors, fmt.Sprintf("endpoint list failed: %v", err))
+		} else {
+			var endpoints []ciliumEndpointRecord
+			if err := json.Unmarshal([]byte(endpointOutput.Stdout), &endpoints); err != nil {
+				agent.Errors = append(agent.Errors, fmt.Sprintf("could not parse endpoint list: %v", err))
+			}
+			agent.EndpointsTotal = len(endpoints)
+			for _, endpoint := range endpoints {
+				if ciliumTransientEndpointStates[endpoint.Status.State] {
+					continue
+				}
+				name := fmt.Sprintf("endpoint %d", endpoint.ID)
+				if ids := endpoint.Status.ExternalIdentifiers; ids.K8sPodName != "" {
+					name = fmt.Sprintf("%s/%s", ids.K8sNamespace, ids.K8sPodName)
+				}
+				agent.UnhealthyEndpoints = append(agent.UnhealthyEndpoints, fmt.Sprintf("%s (%s)", name, endpoint.Status.State))
+			}
+		}
+
+		agents = append(agents, agent)
+	}
+
+	return agents, commandOutputs, nil
+}
+
+// TestCiliumHealth aggregates agent readiness, endpoint states, controller failures and cluster health-check
+// reachability from every Cilium agent into a per-node report
+func (t *Tester) TestCiliumHealth(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Pod-level status, the same check used before connectivity tests
+	podsHealthy, podIssue := t.checkCiliumStatus(ctx)
+	if podsHealthy {
+		details = append(details, "✓ All Cilium agent pods running and ready")
+	} else {
+		details = append(details, fmt.Sprintf("✗ %s", podIssue))
+	}
+
+	// Step 2: Agent-level status from inside every agent
+	agents, commandOutputs, err := t.collectCiliumHealth(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Cilium health check failed: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Agent Discovery",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"This test only applies to clusters running Cilium",
+					"Check the agent DaemonSet: kubectl -n kube-system get ds cilium",
+				},
+			},
+		}
+	}
+
+	// Step 3: Per-node report
+	details = append(details, "  Cilium agent health per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-20s %-12s %-13s %-10s %s", "NODE", "AGENT", "ENDPOINTS", "CONTROLLERS", "HEALTH", "RESULT"))
+	var failures []string
+	unhealthyEndpoints := 0
+	for _, agent := range agents {
+		marker := "✓"
+		if !agent.Healthy() {
+			marker = "✗"
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-20s %-12s %-13s %-10s %s",
+			agent.Node, agent.Pod,
+			fmt.Sprintf("%d/%d", agent.EndpointsTotal-len(agent.UnhealthyEndpoints), agent.EndpointsTotal),
+			fmt.Sprintf("%d/%d", agent.ControllersHealthy, agent.ControllersTotal),
+			fmt.Sprintf("%d/%d", agent.NodesReachable, agent.NodesTotal),
+			marker))
+	}
+	for _, agent := range agents {
+		for _, agentErr := range agent.Errors {
+			details = append(details, fmt.Sprintf("✗ %s: %s", agent.Node, agentErr))
+			failures = append(failures, fmt.Sprintf("%s: %s", agent.Node, agentErr))
+		}
+		for _, endpoint := range agent.UnhealthyEndpoints {
+			details = append(details, fmt.Sprintf("✗ %s: unhealthy endpoint %s", agent.Node, endpoint))
+		}
+		if len(agent.UnhealthyEndpoints) > 0 {
+			unhealthyEndpoints += len(agent.UnhealthyEndpoints)
+			failures = append(failures, fmt.Sprintf("%s: %d unhealthy endpoints", agent.Node, len(agent.UnhealthyEndpoints)))
+		}
+		if agent.ControllersHealthy < agent.ControllersTotal {
+			details = append(details, fmt.Sprintf("✗ %s: %d of %d controllers failing", agent.Node,
+				agent.ControllersTotal-agent.ControllersHealthy, agent.ControllersTotal))
+			failures = append(failures, fmt.Sprintf("%s: controller failures", agent.Node))
+		}
+		if agent.NodesReachable < agent.NodesTotal {
+			details = append(details, fmt.Sprintf("✗ %s: health checks reach only %d of %d nodes", agent.Node, agent.NodesReachable, agent.NodesTotal))
+			failures = append(failures, fmt.Sprintf("%s: %d of %d nodes unreachable", agent.Node, agent.NodesTotal-agent.NodesReachable, agent.NodesTotal))
+		}
+	}
+	details = append(details, "  kubectl -n kube-system exec ds/cilium -c cilium-agent -- cilium-dbg status --verbose")
+
+	metrics := map[string]float64{
+		"agents":              float64(len(agents)),
+		"unhealthy_endpoints": float64(unhealthyEndpoints),
+	}
+
+	if !podsHealthy || len(failures) > 0 {
+		if !podsHealthy {
+			failures = append([]string{podIssue}, failures...)
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Cilium is unhealthy on %d of %d nodes", countUnhealthyAgents(agents), len(agents)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Cilium Agent Health",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Inspect failing controllers: kubectl -n kube-system exec <agent> -c cilium-agent -- cilium-dbg status --all-controllers",
+					"Endpoints stuck in not-ready or waiting-for-identity usually mean the agent cannot reach the API server or kvstore",
+					"Health-check failures between nodes point at the underlay (firewalls, MTU, tunnel ports 8472/6081)",
+					"Investigate Cilium agent logs: kubectl logs -n kube-system -l k8s-app=cilium",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Cilium healthy on all %d nodes - endpoints, controllers and node health checks OK", len(agents)),
+		Details: details,
+		Metrics: metrics,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
+
+// countUnhealthyAgents returns the number of agents with at least one health problem
+func countUnhealthyAgents(agents []ciliumAgentHealth) int {
+	count := 0
+	for _, agent := range agents {
+		if !agent.Healthy() {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,259 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	ciliumEndpointGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumendpoints"}
+	ciliumIdentityGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumidentities"}
+)
+
+const (
+	// ciliumInitIdentity is the reserved identity of endpoints whose labels are not yet resolved
+	ciliumInitIdentity = 5
+	// ciliumMinimumClusterIdentity is the first identity allocated to workloads; lower values are reserved
+	ciliumMinimumClusterIdentity = 256
+)
+
+// ciliumReservedIdentities names the well-known reserved identities
+var ciliumReservedIdentities = map[int64]string{
+	1: "reserved:host",
+	2: "reserved:world",
+	3: "reserved:unmanaged",
+	4: "reserved:health",
+	5: "reserved:init",
+	6: "reserved:remote-node",
+	7: "reserved:kube-apiserver",
+	8: "reserved:ingress",
+}
+
+// identityTestPod is a test pod with the Cilium identity labels it is expected to carry
+type identityTestPod struct {
+	Name           string
+	Labels         map[string]string
+	IdentityID     int64
+	IdentityLabels []string
+}
+
+// getEndpointIdentity returns the security identity and its labels from the pod's CiliumEndpoint
+func (t *Tester) getEndpointIdentity(ctx context.Context, podName string) (int64, []string, error) {
+	endpoint, err := t.dynamicClient.Resource(ciliumEndpointGVR).Namespace(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return 0, nil, err
+	}
+	id, found, err := unstructured.NestedInt64(endpoint.Object, "status", "identity", "id")
+	if err != nil || !found {
+		return 0, nil, fmt.Errorf("CiliumEndpoint %s has no identity yet", podName)
+	}
+	labels, _, _ := unstructured.NestedStringSlice(endpoint.Object, "status", "identity", "labels")
+	return id, labels, nil
+}
+
+// waitForEndpointIdentity polls the pod's CiliumEndpoint until it carries a non-init identity. If the endpoint is
+// still on the init identity at the timeout, that identity is returned so the caller can flag it.
+func (t *Tester) waitForEndpointIdentity(ctx context.Context, podName string, timeout time.Duration) (int64, []string, error) {
+	deadline := time.Now().Add(timeout)
+	var id int64
+	var labels []string
+	var err error
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		id, labels, err = t.getEndpointIdentity(ctx, podName)
+		if err == nil && id != ciliumInitIdentity {
+			return id, labels, nil
+		}
+		sleepContext(ctx, 2*time.Second)
+	}
+	return id, labels, err
+}
+
+// TestCiliumIdentity resolves the Cilium security identities of labelled test pods and verifies the identity labels,
+// flagging reserved or init identities, identities shared across different label sets and identity churn
+func (t *Tester) TestCiliumIdentity(ctx context.Context) TestResult {
+	var details []string
+	identityInfo := map[string]string{}
+
+	pods := []*identityTestPod{
+		{Name: "identity-frontend-test", Labels: map[string]string{"app": "identity-test", "tier": "frontend"}},
+		{Name: "identity-backend-test", Labels: map[string]string{"app": "identity-test", "tier": "backend"}},
+	}
+
+	cleanup := func() {
+		t.cleanupPods(ctx, pods[0].Name, pods[1].Name)
+	}
+
+	// Step 1: Identity allocation mode from the agent configuration
+	ciliumConfig, err := t.getCiliumConfig(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: "Cilium identity test requires Cilium - cilium-config ConfigMap not found",
+			Details: []string{fmt.Sprintf("✗ Could not read kube-system/cilium-config: %v", err)},
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Feature Detection",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"This test only applies to clusters running Cilium",
+				},
+			},
+		}
+	}
+	allocationMode := ciliumConfig["identity-allocation-mode"]
+	if allocationMode == "" {
+		allocationMode = "crd"
+	}
+	identityInfo["identity_allocation_mode"] = allocationMode
+	details = append(details, fmt.Sprintf("ℹ️ identity-allocation-mode=%s", allocationMode))
+	if labelFilter := ciliumConfig["labels"]; labelFilter != "" {
+		identityInfo["labels_filter"] = labelFilter
+		details = append(details, fmt.Sprintf("ℹ️ Identity-relevant labels restricted by 'labels: %s'", labelFilter))
+	}
+
+	// Step 2: Create the labelled test pods
+	for _, pod := range pods {
+		if err := t.createPolicyClientPod(ctx, t.namespace, pod.Name, "", pod.Labels); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: err.Error(),
+				Details: details,
+			}
+		}
+	}
+	for _, pod := range pods {
+		if err := t.waitForPodReady(ctx, pod.Name, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", pod.Name, err),
+				Details: details,
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Test pods '%s' (tier=frontend) and '%s' (tier=backend) ready", pods[0].Name, pods[1].Name))
+
+	// Step 3: Resolve identities and verify their labels
+	var problems []string
+	for _, pod := range pods {
+		id, labels, err := t.waitForEndpointIdentity(ctx, pod.Name, 30*time.Second)
+		if err != nil {
+			details = append(details, fmt.Sprintf("✗ %s: %v", pod.Name, err))
+			problems = append(problems, fmt.Sprintf("%s: no identity resolved", pod.Name))
+			continue
+		}
+		pod.IdentityID = id
+		pod.IdentityLabels = labels
+		identityInfo[pod.Name] = fmt.Sprintf("%d", id)
+		details = append(details, fmt.Sprintf("ℹ️ %s: identity %d [%s]", pod.Name, id, strings.Join(labels, ", ")))
+		details = append(details, fmt.Sprintf("  kubectl get ciliumendpoint %s -n %s -o jsonpath='{.status.identity}'", pod.Name, t.namespace))
+
+		if id < ciliumMinimumClusterIdentity {
+			name := ciliumReservedIdentities[id]
+			if name == "" {
+				name = "reserved"
+			}
+			details = append(details, fmt.Sprintf("✗ %s: has reserved identity %d (%s) instead of a workload identity", pod.Name, id, name))
+			problems = append(problems, fmt.Sprintf("%s: reserved identity %d (%s)", pod.Name, id, name))
+			continue
+		}
+
+		expected := []string{fmt.Sprintf("k8s:io.kubernetes.pod.namespace=%s", t.namespace)}
+		for key, value := range pod.Labels {
+			expected = append(expected, fmt.Sprintf("k8s:%s=%s", key, value))
+		}
+		var missing []string
+		for _, label := range expected {
+			found := false
+			for _, actual := range labels {
+				if actual == label {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, label)
+			}
+		}
+		if len(missing) > 0 {
+			details = append(details, fmt.Sprintf("✗ %s: identity labels missing %s", pod.Name, strings.Join(missing, ", ")))
+			problems = append(problems, fmt.Sprintf("%s: identity missing labels %s", pod.Name, strings.Join(missing, ", ")))
+		} else {
+			details = append(details, fmt.Sprintf("✓ %s: identity labels match the pod labels and namespace", pod.Name))
+		}
+
+		// In CRD mode every allocated identity is backed by a CiliumIdentity object
+		if allocationMode == "crd" {
+			if _, err := t.dynamicClient.Resource(ciliumIdentityGVR).Get(ctx, fmt.Sprintf("%d", id), metav1.GetOptions{}); err != nil {
+				details = append(details, fmt.Sprintf("✗ %s: CiliumIdentity %d not found: %v", pod.Name, id, err))
+				problems = append(problems, fmt.Sprintf("%s: CiliumIdentity %d missing", pod.Name, id))
+			} else {
+				details = append(details, fmt.Sprintf("✓ %s: CiliumIdentity %d exists", pod.Name, id))
+			}
+		}
+	}
+
+	// Step 4: Different label sets must resolve to different identities
+	if pods[0].IdentityID != 0 && pods[0].IdentityID == pods[1].IdentityID {
+		details = append(details, fmt.Sprintf("✗ Pods with different tier labels share identity %d", pods[0].IdentityID))
+		problems = append(problems, fmt.Sprintf("tier=frontend and tier=backend share identity %d", pods[0].IdentityID))
+	} else if pods[0].IdentityID != 0 && pods[1].IdentityID != 0 {
+		details = append(details, "✓ Different label sets resolved to different identities")
+	}
+
+	// Step 5: Identities must be stable - churn causes intermittent policy drops
+	sleepContext(ctx, 10*time.Second)
+	for _, pod := range pods {
+		if pod.IdentityID == 0 {
+			continue
+		}
+		id, _, err := t.getEndpointIdentity(ctx, pod.Name)
+		if err != nil {
+			continue
+		}
+		if id != pod.IdentityID {
+			details = append(details, fmt.Sprintf("✗ %s: identity changed from %d to %d within 10s", pod.Name, pod.IdentityID, id))
+			problems = append(problems, fmt.Sprintf("%s: identity churn %d→%d", pod.Name, pod.IdentityID, id))
+		}
+	}
+	if len(problems) == 0 {
+		details = append(details, "✓ Identities stable over 10s")
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up identity test pods")
+
+	if len(problems) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Cilium identity problems found: %s", strings.Join(problems, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Identity Resolution",
+				TechnicalError: strings.Join(problems, "; "),
+				NetworkContext: &NetworkContext{AdditionalInfo: identityInfo},
+				TroubleshootingHints: []string{
+					"An init identity means the agent has not resolved the pod's labels yet; check agent connectivity to the API server",
+					"Missing labels usually come from the 'labels' filter in cilium-config excluding them from identity computation",
+					"Identity churn is often caused by labels that change at runtime (e.g. controller-revision-hash) being identity-relevant",
+					"List identities and their labels: kubectl get ciliumidentities -o wide",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: "Cilium identities resolved, labelled correctly and stable for all test pods",
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			NetworkContext: &NetworkContext{AdditionalInfo: identityInfo},
+		},
+	}
+}
@@ -0,0 +1,392 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var ciliumLBIPPoolGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2alpha1", Resource: "ciliumloadbalancerippools"}
+
+const (
+	// diagnosticLBPoolName is the pool created by the test when no existing pool can serve the test service
+	diagnosticLBPoolName = "k8s-diagnostic-lb-pool"
+	// diagnosticLBIPAMLabel selects the test service from the diagnostic pool without affecting other services
+	diagnosticLBIPAMLabel = "k8s-diagnostic/lb-ipam"
+	// defaultLBIPAMCIDR is used for the diagnostic pool when no CIDR is configured
+	defaultLBIPAMCIDR = "172.31.255.240/28"
+)
+
+// ciliumPoolCIDRs extracts the CIDRs of a CiliumLoadBalancerIPPool (spec.blocks in 1.15+, spec.cidrs before)
+func ciliumPoolCIDRs(pool *unstructured.Unstructured) []string {
+	var cidrs []string
+	for _, field := range []string{"blocks", "cidrs"} {
+		entries, found, err := unstructured.NestedSlice(pool.Object, "spec", field)
+		if err != nil || !found {
+			continue
+		}
+		for _, entry := range entries {
+			block, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if cidr, ok := block["cidr"].(string); ok && cidr != "" {
+				cidrs = append(cidrs, cidr)
+			}
+			if start, ok := block["start"].(string); ok && start != "" {
+				stop, _ := block["stop"].(string)
+				cidrs = append(cidrs, fmt.Sprintf("%s-%s", start, stop))
+			}
+		}
+	}
+	return cidrs
+}
+
+// addressInPoolRanges reports whether an address lies in any of the given CIDRs or start-stop ranges
+func addressInPoolRanges(address string, ranges []string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, r := range ranges {
+		if _, network, err := net.ParseCIDR(r); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		bounds := strings.SplitN(r, "-", 2)
+		if len(bounds) != 2 {
+			continue
+		}
+		start, stop := net.ParseIP(bounds[0]), net.ParseIP(bounds[1])
+		if start == nil || stop == nil {
+			continue
+		}
+		if bytes.Compare(ip.To16(), start.To16()) >= 0 && bytes.Compare(ip.To16(), stop.To16()) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCiliumLBIPAM exercises Cilium LB-IPAM using an existing pool or the default diagnostic CIDR
+func (t *Tester) TestCiliumLBIPAM(ctx context.Context) TestResult {
+	return t.TestCiliumLBIPAMWithConfig(ctx, TestConfig{})
+}
+
+// TestCiliumLBIPAMWithConfig exercises Cilium LB-IPAM by requesting an address from a CiliumLoadBalancerIPPool
+func (t *Tester) TestCiliumLBIPAMWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+
+	deploymentName := "web-lb-ipam"
+	serviceName := "web-lb-ipam"
+	testPodName := "netshoot-lb-ipam-test"
+	hostPodName := "netshoot-lb-ipam-host"
+
+	// Step 1: Check the feature is enabled in the agent configuration
+	ciliumConfig, err := t.getCiliumConfig(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: "Cilium LB-IPAM test requires Cilium - cilium-config ConfigMap not found",
+			Details: []string{fmt.Sprintf("✗ Could not read kube-system/cilium-config: %v", err)},
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Feature Detection",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"This test only applies to clusters running Cilium",
+				},
+			},
+		}
+	}
+	if ciliumConfig["enable-lb-ipam"] == "false" {
+		return TestResult{
+			Success: false,
+			Message: "Cilium LB-IPAM is disabled (enable-lb-ipam=false in cilium-config)",
+			Details: []string{"✗ LB-IPAM disabled in cilium-config"},
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Feature Detection",
+				TechnicalError: "enable-lb-ipam=false",
+				TroubleshootingHints: []string{
+					"Enable LB-IPAM with the Helm value enableLBIPAM=true (enabled by default since Cilium 1.13)",
+				},
+			},
+		}
+	}
+	details = append(details, "✓ LB-IPAM not disabled in cilium-config")
+
+	// Step 2: Find an existing pool or create a dedicated one
+	pools, err := t.dynamicClient.Resource(ciliumLBIPPoolGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		message := fmt.Sprintf("Failed to list CiliumLoadBalancerIPPools: %v", err)
+		if apierrors.IsNotFound(err) {
+			message = "Cilium LB-IPAM is not available - CiliumLoadBalancerIPPool CRD is not installed"
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: append(details, fmt.Sprintf("✗ %s", message)),
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Feature Detection",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"LB-IPAM requires Cilium 1.13 or newer",
+					"Check installed CRDs with: kubectl get crd ciliumloadbalancerippools.cilium.io",
+				},
+			},
+		}
+	}
+
+	createdPool := false
+	var poolRanges []string
+	serviceLabels := map[string]string{}
+	if len(pools.Items) > 0 && config.LBIPAMCIDR == "" {
+		for _, pool := range pools.Items {
+			ranges := ciliumPoolCIDRs(&pool)
+			poolRanges = append(poolRanges, ranges...)
+			details = append(details, fmt.Sprintf("✓ Found existing pool %s with ranges %v", pool.GetName(), ranges))
+		}
+	} else {
+		cidr := config.LBIPAMCIDR
+		if cidr == "" {
+			cidr = defaultLBIPAMCIDR
+		}
+		pool := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "cilium.io/v2alpha1",
+				"kind":       "CiliumLoadBalancerIPPool",
+				"metadata": map[string]interface{}{
+					"name": diagnosticLBPoolName,
+				},
+				"spec": map[string]interface{}{
+					"blocks": []interface{}{
+						map[string]interface{}{"cidr": cidr},
+					},
+					"serviceSelector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{
+							diagnosticLBIPAMLabel: "true",
+						},
+					},
+				},
+			},
+		}
+		if _, err := t.dynamicClient.Resource(ciliumLBIPPoolGVR).Create(ctx, pool, metav1.CreateOptions{}); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create CiliumLoadBalancerIPPool %s: %v", diagnosticLBPoolName, err),
+				Details: details,
+			}
+		}
+		createdPool = true
+		poolRanges = []string{cidr}
+		serviceLabels[diagnosticLBIPAMLabel] = "true"
+		details = append(details, fmt.Sprintf("✓ Created CiliumLoadBalancerIPPool %s with CIDR %s (serviceSelector %s=true)", diagnosticLBPoolName, cidr, diagnosticLBIPAMLabel))
+	}
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+		t.cleanupPod(ctx, hostPodName)
+		if createdPool {
+			t.dynamicClient.Resource(ciliumLBIPPoolGVR).Delete(ctx, diagnosticLBPoolName, metav1.DeleteOptions{})
+		}
+	}
+
+	// Step 3: Create the backend and a LoadBalancer service requesting an address
+	if _, err := t.createNginxDeployment(ctx, deploymentName); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create nginx deployment: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Deployment '%s' is ready", deploymentName))
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: t.namespace,
+			Labels:    serviceLabels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": deploymentName},
+			Ports: []corev1.ServicePort{
+				{
+					Port:       80,
+					TargetPort: intstr.FromInt(80),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+			Type: corev1.ServiceTypeLoadBalancer,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Services(t.namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create LoadBalancer service: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created LoadBalancer service '%s'", serviceName))
+
+	// Step 4: Verify LB-IPAM assigned an address from the pool
+	timeout := config.LoadBalancerTimeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	lbIP, err := t.waitForLoadBalancerIngress(ctx, serviceName, timeout)
+	if err != nil {
+		// LB-IPAM reports why a request could not be satisfied as a service condition
+		var conditionInfo string
+		if svc, getErr := t.clientset.CoreV1().Services(t.namespace).Get(ctx, serviceName, metav1.GetOptions{}); getErr == nil {
+			for _, condition := range svc.Status.Conditions {
+				if condition.Type == "cilium.io/IPAMRequestSatisfied" {
+					conditionInfo = fmt.Sprintf("%s=%s (%s: %s)", condition.Type, condition.Status, condition.Reason, condition.Message)
+				}
+			}
+		}
+		cleanup()
+		if conditionInfo != "" {
+			details = append(details, fmt.Sprintf("✗ %s", conditionInfo))
+		}
+		return TestResult{
+			Success: false,
+			Message: "Cilium LB-IPAM did not assign an address to the LoadBalancer service",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "LB-IPAM Address Assignment",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"Check the pool is not exhausted or disabled: kubectl get ciliumloadbalancerippools",
+					"Verify the pool's serviceSelector matches the service labels",
+					"Check cilium-operator logs for LB-IPAM errors: kubectl logs -n kube-system deploy/cilium-operator",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ LB-IPAM assigned %s", lbIP))
+
+	if !addressInPoolRanges(lbIP, poolRanges) {
+		cleanup()
+		details = append(details, fmt.Sprintf("✗ Address %s is outside the pool ranges %v", lbIP, poolRanges))
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("LoadBalancer address %s was not assigned from a CiliumLoadBalancerIPPool", lbIP),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "LB-IPAM Address Assignment",
+				TechnicalError: fmt.Sprintf("address %s not in %v", lbIP, poolRanges),
+				TroubleshootingHints: []string{
+					"Another LoadBalancer implementation (MetalLB, cloud controller) may be assigning addresses",
+					"Set spec.loadBalancerClass on services to choose the implementation explicitly",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Address %s lies within pool ranges %v", lbIP, poolRanges))
+
+	// Step 5: Verify reachability from the pod network and from a node
+	if _, err := t.createNetshootPod(ctx, testPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	if _, err := t.createHostNetworkPod(ctx, hostPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create hostNetwork test pod: %v", err),
+			Details: details,
+		}
+	}
+	for _, podName := range []string{testPodName, hostPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Test pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	details = append(details, "✓ Test pods are ready")
+
+	var failures []string
+	for _, probe := range []struct {
+		pod   string
+		label string
+	}{
+		{testPodName, "pod network"},
+		{hostPodName, "host network"},
+	} {
+		statusCode, _, err := t.testHTTPConnectivityWithStatusCode(ctx, probe.pod, httpTargetForIP(lbIP), config.HTTP)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", probe.label, err))
+			details = append(details, fmt.Sprintf("✗ LB-IPAM address unreachable from %s: %v", probe.label, err))
+			continue
+		}
+		if success, message := config.HTTP.evaluate(statusCode); success {
+			details = append(details, fmt.Sprintf("✓ LB-IPAM address reachable from %s - Status: %s", probe.label, statusCode))
+		} else {
+			failures = append(failures, fmt.Sprintf("%s: %s", probe.label, message))
+			details = append(details, fmt.Sprintf("✗ LB-IPAM address issue from %s - %s", probe.label, message))
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up LB-IPAM test resources")
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("LB-IPAM assigned %s but the address is not reachable", lbIP),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "LB-IPAM Reachability",
+				TechnicalError: strings.Join(failures, "; "),
+				NetworkContext: &NetworkContext{
+					AdditionalInfo: map[string]string{
+						"lb_ipam_address":        lbIP,
+						"kube_proxy_replacement": ciliumConfig["kube-proxy-replacement"],
+					},
+				},
+				TroubleshootingHints: []string{
+					"In-cluster access to LB addresses requires kube-proxy replacement or kube-proxy handling of the LB IP",
+					"Access from outside the cluster additionally requires L2 announcements or BGP advertisement of the pool",
+					"Check the Cilium service table: kubectl exec -n kube-system ds/cilium -- cilium-dbg service list",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Cilium LB-IPAM test passed - %s assigned from pool and reachable", lbIP),
+		Details: details,
+	}
+}
@@ -0,0 +1,162 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CNI names reported by detectCNI
+const (
+	CNICilium  = "Cilium"
+	CNICalico  = "Calico"
+	CNIFlannel = "Flannel"
+	CNIAWSVPC  = "AWS VPC CNI"
+	CNIAntrea  = "Antrea"
+	CNIKindnet = "kindnet"
+	CNIWeave   = "Weave Net"
+	CNIUnknown = "unknown"
+)
+
+// knownCNIDaemonSets maps the agent DaemonSet of each supported CNI to its name, checked in order
+var knownCNIDaemonSets = []CNIInfo{
+	{Name: CNICilium, Namespace: "kube-system", DaemonSet: "cilium"},
+	{Name: CNICalico, Namespace: "calico-system", DaemonSet: "calico-node"},
+	{Name: CNICalico, Namespace: "kube-system", DaemonSet: "calico-node"},
+	{Name: CNIFlannel, Namespace: "kube-flannel", DaemonSet: "kube-flannel-ds"},
+	{Name: CNIFlannel, Namespace: "kube-system", DaemonSet: "kube-flannel-ds"},
+	{Name: CNIAWSVPC, Namespace: "kube-system", DaemonSet: "aws-node"},
+	{Name: CNIAntrea, Namespace: "kube-system", DaemonSet: "antrea-agent"},
+	{Name: CNIKindnet, Namespace: "kube-system", DaemonSet: "kindnet"},
+	{Name: CNIWeave, Namespace: "kube-system", DaemonSet: "weave-net"},
+}
+
+// CNIInfo identifies the CNI plugin running in the cluster by its agent DaemonSet
+type CNIInfo struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	DaemonSet string `json:"daemonset,omitempty"`
+}
+
+// detectCNI finds the CNI plugin by looking for the agent DaemonSet of each known CNI. The result is cached on the
+// Tester; clusters running none of them report CNIUnknown.
+func (t *Tester) detectCNI(ctx context.Context) CNIInfo {
+	if t.cni != nil {
+		return *t.cni
+	}
+
+	detected := CNIInfo{Name: CNIUnknown}
+	for _, candidate := range knownCNIDaemonSets {
+		_, err := t.clientset.AppsV1().DaemonSets(candidate.Namespace).Get(ctx, candidate.DaemonSet, metav1.GetOptions{})
+		if err == nil {
+			detected = candidate
+			break
+		}
+	}
+	t.cni = &detected
+	return detected
+}
+
+// isCilium reports whether the detected CNI is Cilium, gating Cilium-specific advice
+func (t *Tester) isCilium(ctx context.Context) bool {
+	return t.detectCNI(ctx).Name == CNICilium
+}
+
+// checkCNIStatus runs the pre-flight health check for the detected CNI. Cilium keeps its dedicated check; other
+// CNIs are checked through the readiness of their agent DaemonSet pods. Unknown CNIs pass since there is nothing
+// to check.
+func (t *Tester) checkCNIStatus(ctx context.Context) (bool, string) {
+	cni := t.detectCNI(ctx)
+	switch cni.Name {
+	case CNICilium:
+		return t.checkCiliumStatus(ctx)
+	case CNIUnknown:
+		return true, ""
+	}
+
+	daemonSet, err := t.clientset.AppsV1().DaemonSets(cni.Namespace).Get(ctx, cni.DaemonSet, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Sprintf("Failed to check %s DaemonSet %s/%s: %v", cni.Name, cni.Namespace, cni.DaemonSet, err)
+	}
+	pods, err := t.clientset.CoreV1().Pods(cni.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(daemonSet.Spec.Selector),
+	})
+	if err != nil {
+		return false, fmt.Sprintf("Failed to check %s pod status: %v", cni.Name, err)
+	}
+	if len(pods.Items) == 0 {
+		return false, fmt.Sprintf("No %s pods found in %s namespace", cni.Name, cni.Namespace)
+	}
+
+	var running, failing int
+	var failingPodNames []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && isPodReady(&pod) {
+			running++
+		} else if pod.Status.Phase == corev1.PodFailed ||
+			isPodInCrashLoop(&pod) ||
+			(time.Since(pod.CreationTimestamp.Time) > time.Minute && pod.Status.Phase == corev1.PodPending) {
+			failing++
+			failingPodNames = append(failingPodNames, pod.Name)
+		}
+	}
+
+	if running == len(pods.Items) {
+		return true, ""
+	}
+	if failing > 0 {
+		return false, fmt.Sprintf("%s is unhealthy: %d of %d pods failing, failing pods: %s",
+			cni.Name, failing, len(pods.Items), strings.Join(failingPodNames, ", "))
+	}
+	return false, fmt.Sprintf("%s is not fully ready: %d of %d pods running", cni.Name, running, len(pods.Items))
+}
+
+// cniTroubleshootingHints returns pre-flight troubleshooting hints for the given CNI
+func cniTroubleshootingHints(cni CNIInfo) []string {
+	switch cni.Name {
+	case CNICilium:
+		return []string{
+			"Verify Cilium pods are running properly in the kube-system namespace",
+			"Check Cilium logs for specific errors: kubectl logs -n kube-system [cilium-pod-name]",
+			"Try a different Cilium routing mode using build_test_k8s.sh -r [tunnel|native|direct]",
+			"The 'tunnel' mode is usually most compatible with Kind clusters",
+		}
+	case CNICalico:
+		return []string{
+			fmt.Sprintf("Check calico-node pods: kubectl get pods -n %s -l k8s-app=calico-node", cni.Namespace),
+			fmt.Sprintf("Check Felix logs for dataplane errors: kubectl logs -n %s ds/calico-node -c calico-node", cni.Namespace),
+			"Verify BGP peering and IP pools: calicoctl node status && calicoctl get ippools -o wide",
+		}
+	case CNIFlannel:
+		return []string{
+			fmt.Sprintf("Check flannel pods: kubectl get pods -n %s -l app=flannel", cni.Namespace),
+			"Verify every node has a podCIDR assigned: kubectl get nodes -o jsonpath='{.items[*].spec.podCIDR}'",
+			"Check the VXLAN backend can use UDP 8472 between nodes",
+		}
+	case CNIAWSVPC:
+		return []string{
+			"Check aws-node pods: kubectl get pods -n kube-system -l k8s-app=aws-node",
+			"Check IPAM logs for ENI/IP exhaustion: kubectl logs -n kube-system ds/aws-node -c aws-node",
+			"Verify the node IAM role has the AmazonEKS_CNI_Policy attached",
+		}
+	case CNIAntrea:
+		return []string{
+			"Check antrea-agent pods: kubectl get pods -n kube-system -l component=antrea-agent",
+			"Check agent logs: kubectl logs -n kube-system ds/antrea-agent -c antrea-agent",
+			"Verify antrea-controller is running: kubectl get pods -n kube-system -l component=antrea-controller",
+		}
+	case CNIUnknown:
+		return []string{
+			"No known CNI agent DaemonSet was found; check the CNI plugin installed on the nodes",
+			"Look for NetworkPluginNotReady in node conditions: kubectl describe nodes",
+		}
+	}
+	return []string{
+		fmt.Sprintf("Check %s pods: kubectl get pods -n %s", cni.DaemonSet, cni.Namespace),
+		fmt.Sprintf("Check agent logs: kubectl logs -n %s ds/%s", cni.Namespace, cni.DaemonSet),
+	}
+}
@@ -0,0 +1,287 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// cniRestartProbeDuration is how long the continuous ping runs across the agent restart
+	cniRestartProbeDuration = 45
+	// cniRestartProbeInterval is the ping interval in seconds, the resolution of the measured interruption
+	cniRestartProbeInterval = 0.2
+	// cniRestartLead is how long the probe runs before the agent pod is deleted
+	cniRestartLead = 5 * time.Second
+	// cniRestartMaxInterruption fails the test; shorter non-zero interruptions are reported as warnings
+	cniRestartMaxInterruption = time.Second
+)
+
+// icmpSeqPattern extracts the sequence number of a ping reply line
+var icmpSeqPattern = regexp.MustCompile(`icmp_seq=(\d+)`)
+
+// pingReply returns the sequence number of a ping reply line
+func pingReply(line string) (int, bool) {
+	match := icmpSeqPattern.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(match[1])
+	return seq, err == nil
+}
+
+// pingGaps returns the runs of consecutive lost replies as [first, last] sequence numbers of a continuous ping that
+// sent the given number of probes and received the replies in received
+func pingGaps(received map[int]bool, sent int) [][2]int {
+	var gaps [][2]int
+	for seq := 1; seq <= sent; seq++ {
+		if received[seq] {
+			continue
+		}
+		if len(gaps) > 0 && gaps[len(gaps)-1][1] == seq-1 {
+			gaps[len(gaps)-1][1] = seq
+		} else {
+			gaps = append(gaps, [2]int{seq, seq})
+		}
+	}
+	return gaps
+}
+
+// cniAgentPodOnNode returns the agent pod of the CNI DaemonSet running on the node
+func (t *Tester) cniAgentPodOnNode(ctx context.Context, cni CNIInfo, nodeName string) (*corev1.Pod, error) {
+	daemonSet, err := t.clientset.AppsV1().DaemonSets(cni.Namespace).Get(ctx, cni.DaemonSet, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := t.clientset.CoreV1().Pods(cni.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(daemonSet.Spec.Selector),
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		if pods.Items[i].DeletionTimestamp == nil {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no %s agent pod on node %s", cni.Name, nodeName)
+}
+
+// waitForReplacementAgent waits for a new Ready agent pod on the node after the old one was deleted
+func (t *Tester) waitForReplacementAgent(ctx context.Context, cni CNIInfo, nodeName, oldUID string, timeout time.Duration) (*corev1.Pod, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		pod, err := t.cniAgentPodOnNode(ctx, cni, nodeName)
+		if err == nil && string(pod.UID) != oldUID && isPodReady(pod) {
+			return pod, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return nil, fmt.Errorf("no Ready %s agent pod replaced the deleted one on %s within %v", cni.Name, nodeName, timeout)
+}
+
+// TestCNIAgentRestart deletes the CNI agent pod on one node while a continuous pod-to-pod ping is in flight and
+// measures how long connectivity was interrupted, validating that the agent can be restarted or upgraded without
+// disrupting the dataplane of running pods
+func (t *Tester) TestCNIAgentRestart(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	if !config.CNIRestart {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "CNI agent restart test skipped - opt in with --cni-restart",
+			Details: details,
+		}
+	}
+	cni := t.detectCNI(ctx)
+	if cni.Name == CNIUnknown {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "CNI agent restart test skipped - no known CNI agent DaemonSet found",
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("ℹ️ CNI: %s (DaemonSet %s/%s)", cni.Name, cni.Namespace, cni.DaemonSet))
+
+	// Step 1: Client and target pods, on different worker nodes when possible
+	clientPodName := "client-cni-restart-test"
+	targetPodName := "target-cni-restart-test"
+	defer t.cleanupPods(ctx, clientPodName, targetPodName)
+	nodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(nodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "CNI agent restart test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+	clientNode, targetNode := nodes[0], nodes[0]
+	placement := "same node"
+	if len(nodes) > 1 {
+		targetNode = nodes[1]
+		placement = "cross-node"
+	}
+	for pod, node := range map[string]string{clientPodName: clientNode, targetPodName: targetNode} {
+		if _, err := t.createNetshootPod(ctx, pod, node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create pod %s: %v", pod, err),
+				Details: details,
+			}
+		}
+	}
+	for _, pod := range []string{clientPodName, targetPodName} {
+		if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s not ready: %v", pod, err),
+				Details: details,
+			}
+		}
+	}
+	target, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, targetPodName, metav1.GetOptions{})
+	if err != nil || target.Status.PodIP == "" {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Target pod %s has no IP", targetPodName),
+			Details: details,
+		}
+	}
+	agent, err := t.cniAgentPodOnNode(ctx, cni, targetNode)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to find the %s agent on %s: %v", cni.Name, targetNode, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Pods ready, %s (%s -> %s)", placement, clientNode, targetNode))
+
+	// Step 2: Continuous ping, then delete the agent pod on the target's node
+	sent := int(cniRestartProbeDuration / cniRestartProbeInterval)
+	probeDone := make(chan CommandOutput, 1)
+	received := map[int]bool{}
+	go func() {
+		// Replies are recorded as they arrive, so the probe's full output need not be kept
+		output, _ := t.execInPodStreaming(ctx, t.namespace, clientPodName, "netshoot",
+			[]string{"ping", "-c", fmt.Sprint(sent), "-i", fmt.Sprint(cniRestartProbeInterval), "-W", "1", target.Status.PodIP},
+			fmt.Sprintf("Continuous ping to %s across the agent restart", target.Status.PodIP),
+			func(line string) {
+				if seq, ok := pingReply(line); ok {
+					received[seq] = true
+				}
+			})
+		probeDone <- output
+	}()
+	sleepContext(ctx, cniRestartLead)
+
+	deleted := time.Now()
+	if err := t.clientset.CoreV1().Pods(cni.Namespace).Delete(ctx, agent.Name, metav1.DeleteOptions{}); err != nil {
+		<-probeDone
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete %s agent pod %s: %v", cni.Name, agent.Name, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Deleted %s agent pod %s on %s", cni.Name, agent.Name, targetNode))
+
+	metrics := map[string]float64{}
+	var problems []string
+	replacement, agentErr := t.waitForReplacementAgent(ctx, cni, targetNode, string(agent.UID), time.Duration(cniRestartProbeDuration)*time.Second)
+	if agentErr != nil {
+		problems = append(problems, agentErr.Error())
+		details = append(details, fmt.Sprintf("✗ %v", agentErr))
+	} else {
+		metrics["agent_ready_ms"] = float64(time.Since(deleted).Milliseconds())
+		details = append(details, fmt.Sprintf("✓ Replacement agent %s Ready after %v", replacement.Name, time.Since(deleted).Round(100*time.Millisecond)))
+	}
+
+	// Step 3: Evaluate the interruption seen by the ping
+	probe := <-probeDone
+	if len(received) == 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Continuous ping to %s got no replies", target.Status.PodIP),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Continuous Probe",
+				CommandOutputs: []CommandOutput{probe},
+				TroubleshootingHints: []string{
+					"Pod-to-pod connectivity failed outright - run the pod-to-pod and cross-node tests first",
+				},
+			},
+		}
+	}
+	interval := time.Duration(cniRestartProbeInterval * float64(time.Second))
+	gaps := pingGaps(received, sent)
+	var longest time.Duration
+	lost := 0
+	for _, gap := range gaps {
+		count := gap[1] - gap[0] + 1
+		lost += count
+		if duration := time.Duration(count) * interval; duration > longest {
+			longest = duration
+		}
+		offset := time.Duration(gap[0]-1)*interval - cniRestartLead
+		details = append(details, fmt.Sprintf("⚠️ Lost %d probes (%v) starting %v after the agent was deleted", count, time.Duration(count)*interval, offset.Round(100*time.Millisecond)))
+	}
+	if len(gaps) > 0 && gaps[len(gaps)-1][1] == sent {
+		problems = append(problems, "connectivity had not recovered when the probe ended")
+	}
+	metrics["probes"] = float64(sent)
+	metrics["lost_probes"] = float64(lost)
+	metrics["interruption_ms"] = float64(longest.Milliseconds())
+
+	switch {
+	case lost == 0:
+		details = append(details, fmt.Sprintf("✓ No interruption: all %d probes answered across the agent restart", sent))
+	case longest > cniRestartMaxInterruption:
+		problems = append(problems, fmt.Sprintf("connectivity interrupted for %v", longest))
+	}
+
+	if len(problems) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%s agent restart disrupted pod traffic: %s", cni.Name, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "CNI Agent Restart",
+				TechnicalError: strings.Join(problems, "; "),
+				CommandOutputs: []CommandOutput{probe},
+				TroubleshootingHints: append([]string{
+					"An agent restart should leave the programmed dataplane in place; an interruption means state is torn down on shutdown or rebuilt on start",
+					"Upgrade the CNI during a maintenance window until restarts are seamless",
+				}, cniTroubleshootingHints(cni)...),
+			},
+		}
+	}
+
+	message := fmt.Sprintf("%s agent restart on %s caused no pod traffic interruption (%d probes)", cni.Name, targetNode, sent)
+	var warnings []string
+	if lost > 0 {
+		message = fmt.Sprintf("%s agent restart on %s interrupted pod traffic for %v (%d of %d probes lost)", cni.Name, targetNode, longest, lost, sent)
+		warnings = append(warnings, fmt.Sprintf("pod traffic interrupted for %v during the agent restart (%d probes lost)", longest, lost))
+	}
+	return TestResult{
+		Success:  true,
+		Message:  message,
+		Details:  details,
+		Metrics:  metrics,
+		Warnings: warnings,
+	}
+}
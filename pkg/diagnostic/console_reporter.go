@@ -0,0 +1,250 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ConsoleReporter prints the progress and summary of a run for a terminal and records it in Logger, the output of
+// the test command. Logger is optional and Out defaults to stdout.
+type ConsoleReporter struct {
+	Logger  *Logger
+	Out     io.Writer
+	Verbose bool
+}
+
+func (r *ConsoleReporter) out() io.Writer {
+	if r.Out == nil {
+		return os.Stdout
+	}
+	return r.Out
+}
+
+func (r *ConsoleReporter) log(level LogLevel, format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.logWithLevel(level, format, args...)
+	}
+}
+
+func (r *ConsoleReporter) RunStarted(_ context.Context, run RunInfo) {
+	r.log(INFO, "Running %d tests in namespace %s", len(run.Tests), run.Namespace)
+	fmt.Fprintf(r.out(), "🧪 Running diagnostic tests...\n")
+}
+
+// TestStarted announces a test and sets the logger context for it
+func (r *ConsoleReporter) TestStarted(_ context.Context, number int, test Test) {
+	title := TestTitle(test)
+	// Select emoji based on test name
+	var testEmoji string
+	switch {
+	case strings.Contains(title, "Pod-to-Pod"):
+		testEmoji = "🔄"
+	case strings.Contains(title, "Service to Pod"):
+		testEmoji = "🌐"
+	case strings.Contains(title, "Cross-Node"):
+		testEmoji = "📡"
+	case strings.Contains(title, "DNS"):
+		testEmoji = "🔤"
+	case strings.Contains(title, "NodePort"):
+		testEmoji = "🚪"
+	case strings.Contains(title, "LoadBalancer"):
+		testEmoji = "⚖️"
+	default:
+		testEmoji = "🧪"
+	}
+	fmt.Fprintf(r.out(), "Test %d: %s %s\n", number, testEmoji, title)
+
+	if r.Logger != nil {
+		r.Logger.SetContext(fmt.Sprintf("Test %d: %s", number, title))
+	}
+	r.log(INFO, "Starting test %s", test.Name())
+}
+
+// StepOutput logs the output of a running command line by line, so long probes show progress with --verbose
+func (r *ConsoleReporter) StepOutput(_ context.Context, _ Test, description, line string) {
+	r.log(DEBUG, "%s: %s", description, line)
+}
+
+// StepCompleted logs a command of the running test as soon as it finished
+func (r *ConsoleReporter) StepCompleted(_ context.Context, _ Test, step CommandOutput) {
+	r.log(DEBUG, "Step completed: %s (exit code %d, %s)", step.Description, step.ExitCode, step.Duration)
+}
+
+// TestFinished logs and displays the result of a test and clears the logger context
+func (r *ConsoleReporter) TestFinished(_ context.Context, number int, _ Test, timedResult *TimedTestResult) {
+	result := timedResult.TestResult
+	executionTime := timedResult.EndTime.Sub(timedResult.StartTime)
+	r.log(INFO, "Test completed in %.2f seconds", executionTime.Seconds())
+
+	// Log test result details
+	if result.Skipped {
+		r.log(INFO, "Test SKIPPED: %s", result.Message)
+	} else if result.Status() == StatusWarning {
+		r.log(WARNING, "Test WARNING: %s", result.Message)
+		for _, warning := range result.Warnings {
+			r.log(WARNING, "Warning: %s", warning)
+		}
+	} else if result.Success {
+		r.log(INFO, "Test PASSED: %s", result.Message)
+	} else {
+		r.log(ERROR, "Test FAILED: %s", result.Message)
+	}
+
+	// Log detailed results
+	for _, detail := range result.Details {
+		r.log(DEBUG, "Detail: %s", detail)
+	}
+
+	// Log diagnostic info if available
+	if result.DetailedDiagnostics != nil {
+		if result.DetailedDiagnostics.FailureStage != "" {
+			r.log(WARNING, "Failure stage: %s", result.DetailedDiagnostics.FailureStage)
+		}
+		if result.DetailedDiagnostics.TechnicalError != "" {
+			r.log(ERROR, "Technical error: %s", result.DetailedDiagnostics.TechnicalError)
+		}
+
+		// Log command outputs
+		if r.Logger != nil {
+			for _, cmd := range result.DetailedDiagnostics.CommandOutputs {
+				r.Logger.CaptureCommandOutput(cmd)
+			}
+		}
+
+		// Log network context if available
+		if result.DetailedDiagnostics.NetworkContext != nil {
+			netContext := result.DetailedDiagnostics.NetworkContext
+			r.log(DEBUG, "Network context: source=%s, target=%s",
+				netContext.SourcePodIP, netContext.TargetPodIP)
+		}
+
+		// Log troubleshooting hints
+		for _, hint := range result.DetailedDiagnostics.TroubleshootingHints {
+			r.log(INFO, "Troubleshooting hint: %s", hint)
+		}
+	}
+
+	// Display result
+	out := r.out()
+	if result.Skipped {
+		fmt.Fprintf(out, "⏭️ Test %d SKIPPED: %s\n", number, result.SkipReason())
+	} else if result.Status() == StatusWarning {
+		// Warnings are shown without --verbose, they are what the run found
+		fmt.Fprintf(out, "⚠️ Test %d WARNING: %s\n", number, result.Message)
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(out, "    ⚠️ %s\n", warning)
+		}
+	} else if result.Success {
+		fmt.Fprintf(out, "✅ Test %d PASSED: %s\n", number, result.Message)
+	} else {
+		fmt.Fprintf(out, "❌ Test %d FAILED: %s\n", number, result.Message)
+	}
+
+	// Show verbose details if enabled
+	if r.Verbose && len(result.Details) > 0 {
+		fmt.Fprintf(out, "  Details:\n")
+		for _, detail := range result.Details {
+			fmt.Fprintf(out, "    %s\n", detail)
+		}
+	}
+	fmt.Fprintf(out, "\n")
+
+	// Clear test context
+	if r.Logger != nil {
+		r.Logger.ClearContext()
+	}
+}
+
+// RunFinished prints the summary of the run and the overall result
+func (r *ConsoleReporter) RunFinished(_ context.Context, report *DiagnosticReportJSON) error {
+	var passedTestNames, warningTestNames, failedTestNames, skippedTestNames, details []string
+	for _, test := range report.Tests {
+		if test.Status == StatusSkipped {
+			skippedTestNames = append(skippedTestNames, fmt.Sprintf("%s (%s)", test.TestName, test.SkipReason))
+			details = append(details, fmt.Sprintf("- SKIP: %s: %s", test.TestName, test.SkipReason))
+		} else if test.Status == StatusWarning {
+			warningTestNames = append(warningTestNames, fmt.Sprintf("%s (%s)", test.TestName, strings.Join(test.Warnings, "; ")))
+			details = append(details, fmt.Sprintf("⚠️ WARN: %s: %s", test.TestName, test.SuccessMessage))
+		} else if test.Status == StatusPassed {
+			passedTestNames = append(passedTestNames, test.TestName)
+			details = append(details, fmt.Sprintf("✓ PASS: %s: %s", test.TestName, test.SuccessMessage))
+		} else {
+			failedTestNames = append(failedTestNames, test.TestName)
+			details = append(details, fmt.Sprintf("✗ FAIL: %s: %s", test.TestName, test.ErrorMessage))
+		}
+	}
+
+	// Display test summary
+	out := r.out()
+	fmt.Fprintf(out, "\n📊 Test Summary:\n")
+	fmt.Fprintf(out, "  Total Tests: %d, Passed: %d, Warnings: %d, Failed: %d, Skipped: %d\n", len(report.Tests), len(passedTestNames),
+		len(warningTestNames), len(failedTestNames), len(skippedTestNames))
+
+	if len(passedTestNames) > 0 {
+		fmt.Fprintf(out, "  ✅ Passed Tests:\n")
+		for _, testName := range passedTestNames {
+			fmt.Fprintf(out, "    ✅ %s\n", testName)
+		}
+	}
+
+	if len(warningTestNames) > 0 {
+		fmt.Fprintf(out, "  ⚠️ Tests with Warnings:\n")
+		for _, testName := range warningTestNames {
+			fmt.Fprintf(out, "    ⚠️ %s\n", testName)
+		}
+	}
+
+	if len(failedTestNames) > 0 {
+		fmt.Fprintf(out, "  ❌ Failed Tests:\n")
+		for _, testName := range failedTestNames {
+			fmt.Fprintf(out, "    ❌ %s\n", testName)
+		}
+	}
+
+	if len(skippedTestNames) > 0 {
+		fmt.Fprintf(out, "  ⏭️ Skipped Tests:\n")
+		for _, testName := range skippedTestNames {
+			fmt.Fprintf(out, "    ⏭️ %s\n", testName)
+		}
+	}
+
+	// Display detailed results in verbose mode
+	if r.Verbose {
+		fmt.Fprintf(out, "\n📋 Detailed Test Results:\n")
+		for _, detail := range details {
+			fmt.Fprintf(out, "  %s\n", detail)
+		}
+	}
+
+	// Display final result
+	fmt.Fprintf(out, "\n")
+	if len(failedTestNames) == 0 && len(warningTestNames) > 0 {
+		fmt.Fprintf(out, "⚠️ Overall Result: All %d diagnostic tests that ran passed, %d with warnings (%d skipped)\n",
+			len(passedTestNames)+len(warningTestNames), len(warningTestNames), len(skippedTestNames))
+		if !r.Verbose && len(details) > 0 {
+			fmt.Fprintf(out, "💡 Run with --verbose for detailed test steps\n")
+		}
+	} else if len(failedTestNames) == 0 && len(skippedTestNames) > 0 {
+		fmt.Fprintf(out, "🎉 Overall Result: All %d diagnostic tests that ran passed (%d skipped)\n", len(passedTestNames), len(skippedTestNames))
+		if !r.Verbose && len(details) > 0 {
+			fmt.Fprintf(out, "💡 Run with --verbose for detailed test steps\n")
+		}
+	} else if len(failedTestNames) == 0 {
+		fmt.Fprintf(out, "🎉 Overall Result: All %d diagnostic tests passed\n", len(report.Tests))
+		if !r.Verbose && len(details) > 0 {
+			fmt.Fprintf(out, "💡 Run with --verbose for detailed test steps\n")
+		}
+	} else {
+		fmt.Fprintf(out, "🛑 Overall Result: %d of %d diagnostic tests failed\n", len(failedTestNames), len(report.Tests))
+		if !r.Verbose && len(details) > 0 {
+			fmt.Fprintf(out, "📋 Individual Test Results:\n")
+			for _, detail := range details {
+				fmt.Fprintf(out, "  %s\n", detail)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// etcdDBSizeWarn is 80% of etcd's default 2GiB backend quota; past the quota etcd only serves reads and deletes
+	etcdDBSizeWarn = 1.6 * 1024 * 1024 * 1024
+	// leaderLeaseStale is how old a leader election lease renewal may be before the component counts as without leader
+	leaderLeaseStale = 60 * time.Second
+	// componentRestartWindow is how recent a control-plane container restart must be to be reported
+	componentRestartWindow = time.Hour
+)
+
+// controlPlaneComponents are the kube-system static pod component labels of a self-managed (kubeadm-style) cluster
+var controlPlaneComponents = []string{"etcd", "kube-apiserver", "kube-scheduler", "kube-controller-manager"}
+
+// leaderElectedComponents hold a Lease in kube-system while they have an active leader
+var leaderElectedComponents = []string{"kube-scheduler", "kube-controller-manager"}
+
+// etcdDBSizeMetrics are the API server metrics reporting the etcd database size, by Kubernetes version
+var etcdDBSizeMetrics = []string{"apiserver_storage_db_total_size_in_bytes", "etcd_db_total_size_in_bytes", "apiserver_storage_size_bytes"}
+
+// ReadyzCheck is one named check of the API server's /readyz?verbose output
+type ReadyzCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// parseReadyzVerbose parses lines such as "[+]etcd ok" and "[-]etcd failed: reason withheld"
+func parseReadyzVerbose(output string) []ReadyzCheck {
+	var checks []ReadyzCheck
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 4 || line[0] != '[' || line[2] != ']' {
+			continue
+		}
+		name, detail, _ := strings.Cut(line[3:], " ")
+		checks = append(checks, ReadyzCheck{Name: name, Passed: line[1] == '+', Detail: detail})
+	}
+	return checks
+}
+
+// sumMetric adds up every series of a metric in Prometheus text format, reporting whether the metric was present
+func sumMetric(metrics, name string) (float64, bool) {
+	total, found := 0.0, false
+	for _, line := range strings.Split(metrics, "\n") {
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		rest := line[len(name):]
+		if rest == "" || (rest[0] != ' ' && rest[0] != '{') {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			continue
+		}
+		total += value
+		found = true
+	}
+	return total, found
+}
+
+// recentRestart returns the time of the container's last termination when it restarted within the window
+func recentRestart(status corev1.ContainerStatus, window time.Duration) (time.Time, bool) {
+	terminated := status.LastTerminationState.Terminated
+	if status.RestartCount == 0 || terminated == nil {
+		return time.Time{}, false
+	}
+	return terminated.FinishedAt.Time, time.Since(terminated.FinishedAt.Time) < window
+}
+
+// TestControlPlaneHealth takes a snapshot of control-plane health: the API server's /readyz checks (including
+// etcd), etcd database size and rejected requests from the API server metrics, leader election leases, and on
+// self-managed clusters the etcd, API server, scheduler and controller-manager pods in kube-system
+func (t *Tester) TestControlPlaneHealth(ctx context.Context) TestResult {
+	var details []string
+	var degraded, warnings []string
+	metrics := map[string]float64{}
+	restClient := t.clientset.Discovery().RESTClient()
+
+	// Step 1: API server readiness checks, which include etcd connectivity. A failing /readyz answers 500 with the
+	// check list, so the body is parsed before looking at the error
+	readyz, err := restClient.Get().AbsPath("/readyz").Param("verbose", "").DoRaw(ctx)
+	checks := parseReadyzVerbose(string(readyz))
+	if len(checks) == 0 && err != nil {
+		degraded = append(degraded, fmt.Sprintf("API server /readyz failed: %v", err))
+	} else {
+		failed := 0
+		for _, check := range checks {
+			if !check.Passed {
+				failed++
+				degraded = append(degraded, fmt.Sprintf("API server readiness check %s failed: %s", check.Name, check.Detail))
+			}
+		}
+		metrics["readyz_failed"] = float64(failed)
+		details = append(details, fmt.Sprintf("✓ API server /readyz: %d of %d checks passing", len(checks)-failed, len(checks)))
+	}
+
+	// Step 2: etcd size and request rejection from the API server metrics, when the caller may read them
+	apiMetrics, err := restClient.Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ API server metrics not accessible: %v", err))
+	} else {
+		for _, name := range etcdDBSizeMetrics {
+			if size, ok := sumMetric(string(apiMetrics), name); ok {
+				metrics["etcd_db_size_bytes"] = size
+				details = append(details, fmt.Sprintf("✓ etcd database size: %.0f MiB", size/(1024*1024)))
+				if size > etcdDBSizeWarn {
+					warnings = append(warnings, fmt.Sprintf("etcd database is %.0f MiB, above 80%% of the default 2GiB quota - compact and defragment or raise --quota-backend-bytes", size/(1024*1024)))
+				}
+				break
+			}
+		}
+		if rejected, ok := sumMetric(string(apiMetrics), "apiserver_flowcontrol_rejected_requests_total"); ok && rejected > 0 {
+			metrics["apf_rejected_requests"] = rejected
+			warnings = append(warnings, fmt.Sprintf("API Priority and Fairness rejected %.0f requests since the API server started", rejected))
+		}
+		if inflight, ok := sumMetric(string(apiMetrics), "apiserver_current_inflight_requests"); ok {
+			metrics["apiserver_inflight_requests"] = inflight
+		}
+	}
+
+	// Step 3: Leader election leases of the scheduler and controller-manager
+	for _, component := range leaderElectedComponents {
+		lease, err := t.clientset.CoordinationV1().Leases("kube-system").Get(ctx, component, metav1.GetOptions{})
+		if err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ %s leader lease not readable: %v", component, err))
+			continue
+		}
+		if lease.Spec.RenewTime == nil || lease.Spec.HolderIdentity == nil {
+			degraded = append(degraded, fmt.Sprintf("%s has no leader", component))
+			continue
+		}
+		age := time.Since(lease.Spec.RenewTime.Time)
+		if age > leaderLeaseStale {
+			degraded = append(degraded, fmt.Sprintf("%s leader lease held by %s was last renewed %v ago", component, *lease.Spec.HolderIdentity, age.Round(time.Second)))
+			continue
+		}
+		details = append(details, fmt.Sprintf("✓ %s leader %s renewed %v ago", component, *lease.Spec.HolderIdentity, age.Round(time.Second)))
+	}
+
+	// Step 4: Control-plane pods on self-managed clusters
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("component in (%s)", strings.Join(controlPlaneComponents, ",")),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		details = append(details, "ℹ️ No control-plane pods in kube-system - the control plane is managed outside the cluster")
+	} else {
+		details = append(details, fmt.Sprintf("  %-50s %-8s %s", "POD", "READY", "RESTARTS"))
+		for _, pod := range pods.Items {
+			restarts := int32(0)
+			for _, status := range pod.Status.ContainerStatuses {
+				restarts += status.RestartCount
+				if finished, recent := recentRestart(status, componentRestartWindow); recent {
+					warnings = append(warnings, fmt.Sprintf("%s restarted %v ago", pod.Name, time.Since(finished).Round(time.Second)))
+				}
+			}
+			ready := isPodReady(&pod)
+			if !ready {
+				degraded = append(degraded, fmt.Sprintf("%s on %s is not ready: %s", pod.Name, pod.Spec.NodeName, getPodFailureReason(&pod)))
+			}
+			details = append(details, fmt.Sprintf("  %-50s %-8t %d", pod.Name, ready, restarts))
+		}
+	}
+
+	for _, warning := range warnings {
+		details = append(details, fmt.Sprintf("⚠️ %s", warning))
+	}
+	if len(degraded) > 0 {
+		for _, problem := range degraded {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d degraded control-plane components", len(degraded)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Control Plane Health",
+				TechnicalError: strings.Join(degraded, "; "),
+				TroubleshootingHints: []string{
+					"Show the API server checks: kubectl get --raw='/readyz?verbose'",
+					"A failing etcd check points at etcd quorum, disk latency or certificates - check the etcd pod logs and etcdctl endpoint health",
+					"A stale leader lease means the scheduler or controller-manager lost its leader - new pods stay Pending or controllers stop reconciling",
+					"On managed clusters check the provider's control-plane status page, as the components are not visible in kube-system",
+				},
+			},
+		}
+	}
+
+	message := "Control plane healthy"
+	if len(warnings) > 0 {
+		message = fmt.Sprintf("Control plane healthy with %d warnings", len(warnings))
+	}
+	return TestResult{
+		Success:  true,
+		Message:  message,
+		Details:  details,
+		Metrics:  metrics,
+		Warnings: warnings,
+	}
+}
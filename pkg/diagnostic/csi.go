@@ -0,0 +1,253 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// csiAttachBacklogAge is how long a VolumeAttachment may wait to attach or detach before it counts as backlog
+const csiAttachBacklogAge = 2 * time.Minute
+
+// CSIDriverHealth summarizes one CSI driver: node registration, plugin pods and VolumeAttachment backlog
+type CSIDriverHealth struct {
+	Driver             string   `json:"driver"`
+	RegisteredNodes    int      `json:"registered_nodes"`
+	TotalNodes         int      `json:"total_nodes"`
+	NodePluginsReady   int      `json:"node_plugins_ready"`
+	NodePlugins        int      `json:"node_plugins"`
+	Attachments        int      `json:"attachments"`
+	PendingAttachments int      `json:"pending_attachments"`
+	StuckDetachments   int      `json:"stuck_detachments"`
+	AttachErrors       []string `json:"attach_errors,omitempty"`
+}
+
+// csiControllerSidecars identify CSI controller pods; node plugins are identified by node-driver-registrar
+var csiControllerSidecars = []string{"csi-provisioner", "csi-attacher", "csi-resizer"}
+
+// csiRegistrationDriver extracts the driver name from node-driver-registrar's --kubelet-registration-path
+// (/var/lib/kubelet/plugins/<driver>/csi.sock), or returns ""
+func csiRegistrationDriver(container corev1.Container) string {
+	for _, arg := range append(container.Args, container.Command...) {
+		if !strings.HasPrefix(arg, "--kubelet-registration-path=") {
+			continue
+		}
+		parts := strings.Split(strings.TrimPrefix(arg, "--kubelet-registration-path="), "/")
+		if len(parts) >= 2 {
+			return parts[len(parts)-2]
+		}
+	}
+	return ""
+}
+
+// collectCSIHealth gathers per-driver health and the CSI controller pods, returning the issues found
+func (t *Tester) collectCSIHealth(ctx context.Context) ([]CSIDriverHealth, []string, error) {
+	drivers, err := t.clientset.StorageV1().CSIDrivers().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list CSIDrivers: %v", err)
+	}
+	if len(drivers.Items) == 0 {
+		return nil, nil, nil
+	}
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	health := make(map[string]*CSIDriverHealth)
+	for _, driver := range drivers.Items {
+		health[driver.Name] = &CSIDriverHealth{Driver: driver.Name, TotalNodes: len(nodes.Items)}
+	}
+
+	// CSINode objects list the drivers each kubelet has registered
+	if csiNodes, err := t.clientset.StorageV1().CSINodes().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, csiNode := range csiNodes.Items {
+			for _, driver := range csiNode.Spec.Drivers {
+				if entry, ok := health[driver.Name]; ok {
+					entry.RegisteredNodes++
+				}
+			}
+		}
+	}
+
+	var issues []string
+	pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			if container.Name == "node-driver-registrar" {
+				if entry, ok := health[csiRegistrationDriver(container)]; ok {
+					entry.NodePlugins++
+					if isPodReady(&pod) {
+						entry.NodePluginsReady++
+					}
+				}
+				if !isPodReady(&pod) {
+					issues = append(issues, fmt.Sprintf("CSI node plugin %s/%s on %s is not ready: %s", pod.Namespace, pod.Name, pod.Spec.NodeName, getPodFailureReason(&pod)))
+				}
+				break
+			}
+			if containsString(csiControllerSidecars, container.Name) {
+				if !isPodReady(&pod) {
+					issues = append(issues, fmt.Sprintf("CSI controller %s/%s is not ready: %s", pod.Namespace, pod.Name, getPodFailureReason(&pod)))
+				}
+				break
+			}
+		}
+	}
+
+	attachments, err := t.clientset.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list VolumeAttachments: %v", err)
+	}
+	for _, attachment := range attachments.Items {
+		entry, ok := health[attachment.Spec.Attacher]
+		if !ok {
+			continue
+		}
+		entry.Attachments++
+		age := time.Since(attachment.CreationTimestamp.Time)
+		switch {
+		case attachment.DeletionTimestamp != nil && time.Since(attachment.DeletionTimestamp.Time) > csiAttachBacklogAge:
+			entry.StuckDetachments++
+		case attachment.DeletionTimestamp == nil && !attachment.Status.Attached && age > csiAttachBacklogAge:
+			entry.PendingAttachments++
+		}
+		if attachment.Status.AttachError != nil {
+			entry.AttachErrors = append(entry.AttachErrors, fmt.Sprintf("%s on %s: %s", attachment.Name, attachment.Spec.NodeName, attachment.Status.AttachError.Message))
+		}
+		if attachment.Status.DetachError != nil {
+			entry.AttachErrors = append(entry.AttachErrors, fmt.Sprintf("%s detach on %s: %s", attachment.Name, attachment.Spec.NodeName, attachment.Status.DetachError.Message))
+		}
+	}
+
+	var results []CSIDriverHealth
+	for _, entry := range health {
+		results = append(results, *entry)
+		if entry.NodePlugins > 0 && entry.RegisteredNodes < entry.NodePlugins {
+			issues = append(issues, fmt.Sprintf("CSI driver %s has %d node plugin pods but only %d nodes registered it in their CSINode", entry.Driver, entry.NodePlugins, entry.RegisteredNodes))
+		}
+		if entry.PendingAttachments > 0 {
+			issues = append(issues, fmt.Sprintf("CSI driver %s has %d VolumeAttachments waiting more than %v to attach", entry.Driver, entry.PendingAttachments, csiAttachBacklogAge))
+		}
+		if entry.StuckDetachments > 0 {
+			issues = append(issues, fmt.Sprintf("CSI driver %s has %d VolumeAttachments stuck detaching for more than %v", entry.Driver, entry.StuckDetachments, csiAttachBacklogAge))
+		}
+		for _, attachError := range entry.AttachErrors {
+			issues = append(issues, fmt.Sprintf("CSI driver %s attach error: %s", entry.Driver, attachError))
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Driver < results[j].Driver })
+	sort.Strings(issues)
+	return results, issues, nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// csiHealthHints explains how to read CSI issues against a storage failure
+func csiHealthHints(issues []string) []string {
+	if len(issues) == 0 {
+		return []string{"CSI drivers, node plugins and VolumeAttachments look healthy - a mount or write failure more likely comes from the backing filesystem or its permissions"}
+	}
+	return append(issues,
+		"CSI control-plane issues above point at provisioning/attach rather than the filesystem: kubectl get csidrivers,csinodes,volumeattachments")
+}
+
+// AttachCSIHealth adds CSI driver health to the diagnostics of a failed storage test, separating provisioning and
+// attach problems from filesystem problems
+func (t *Tester) AttachCSIHealth(ctx context.Context, result *TestResult) {
+	if result.Success {
+		return
+	}
+	drivers, issues, err := t.collectCSIHealth(ctx)
+	if err != nil || len(drivers) == 0 {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	for _, issue := range issues {
+		result.Details = append(result.Details, fmt.Sprintf("⚠️ %s", issue))
+	}
+	result.DetailedDiagnostics.TroubleshootingHints = append(result.DetailedDiagnostics.TroubleshootingHints, csiHealthHints(issues)...)
+}
+
+// TestCSIHealth checks the CSI controller and node plugin pods, CSIDriver/CSINode registration and the
+// VolumeAttachment backlog of every CSI driver
+func (t *Tester) TestCSIHealth(ctx context.Context) TestResult {
+	var details []string
+
+	drivers, issues, err := t.collectCSIHealth(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("CSI health check failed: %v", err),
+			Details: details,
+		}
+	}
+	if len(drivers) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "CSI health check skipped - no CSIDriver objects in the cluster",
+			Details: details,
+		}
+	}
+
+	metrics := map[string]float64{}
+	details = append(details, fmt.Sprintf("  %-40s %-12s %-14s %-12s %s", "DRIVER", "REGISTERED", "NODE PLUGINS", "ATTACHMENTS", "PENDING/STUCK"))
+	for _, driver := range drivers {
+		plugins := "-"
+		if driver.NodePlugins > 0 {
+			plugins = fmt.Sprintf("%d/%d ready", driver.NodePluginsReady, driver.NodePlugins)
+		}
+		details = append(details, fmt.Sprintf("  %-40s %-12s %-14s %-12d %d/%d", driver.Driver,
+			fmt.Sprintf("%d/%d", driver.RegisteredNodes, driver.TotalNodes), plugins, driver.Attachments,
+			driver.PendingAttachments, driver.StuckDetachments))
+		metrics["pending_attachments."+driver.Driver] = float64(driver.PendingAttachments)
+	}
+	details = append(details, "  kubectl get csidrivers,csinodes,volumeattachments")
+
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			details = append(details, fmt.Sprintf("✗ %s", issue))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d CSI issues found across %d drivers", len(issues), len(drivers)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "CSI Driver Health",
+				TechnicalError: strings.Join(issues, "; "),
+				TroubleshootingHints: []string{
+					"Node plugin pods not ready or not registered in CSINode prevent mounts on those nodes - check the node-driver-registrar logs",
+					"Pending VolumeAttachments point at the csi-attacher sidecar or the storage backend API",
+					"Attachments stuck detaching often follow a node shutdown - check the node and the backend for the volume's state",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%d CSI drivers healthy", len(drivers)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
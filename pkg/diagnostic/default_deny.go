@@ -0,0 +1,229 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// allowlistSuiteLabel is carried by every pod in the default-deny suite so its policies never select other workloads
+const allowlistSuiteLabel = "default-deny"
+
+// allowlistProbe is one flow checked after every step; a zero Port means a DNS lookup from Source
+type allowlistProbe struct {
+	Name   string
+	Source string
+	Port   int32
+}
+
+// allowlistStep is one cumulative policy layer and the full set of flows expected to be open once it is applied
+type allowlistStep struct {
+	Name       string
+	PolicyFile string
+	Expected   map[string]bool
+}
+
+// runAllowlistProbe reports whether the flow described by probe is currently open
+func (t *Tester) runAllowlistProbe(ctx context.Context, probe allowlistProbe, webIP string) (bool, CommandOutput) {
+	if probe.Port == 0 {
+		output, err := t.execInPodWithOutput(ctx, t.namespace, probe.Source, "netshoot",
+			[]string{"nslookup", "-timeout=2", "-retry=1", "kubernetes.default"},
+			fmt.Sprintf("DNS lookup from %s", probe.Source))
+		return err == nil, output
+	}
+	return t.probeTCPPort(ctx, probe.Source, webIP, probe.Port)
+}
+
+// TestDefaultDenyAllowlist isolates the suite pods with default-deny ingress and egress, then layers allow rules
+// and verifies after each step that exactly the intended flows are open
+func (t *Tester) TestDefaultDenyAllowlist(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	webPodName := "web-allowlist-test"
+	clientPodName := "client-allowlist-test"
+	otherPodName := "other-allowlist-test"
+	policyDir := "cilium-policies/9-default-deny-allowlist"
+
+	probes := []allowlistProbe{
+		{Name: "client→web:80", Source: clientPodName, Port: 80},
+		{Name: "client→web:8080", Source: clientPodName, Port: 8080},
+		{Name: "other→web:80", Source: otherPodName, Port: 80},
+		{Name: "other→web:8080", Source: otherPodName, Port: 8080},
+		{Name: "client DNS", Source: clientPodName},
+	}
+	steps := []allowlistStep{
+		{
+			Name:       "default-deny",
+			PolicyFile: policyDir + "/1-default-deny-policy.yaml",
+			Expected:   map[string]bool{},
+		},
+		{
+			Name:       "allow-dns-egress",
+			PolicyFile: policyDir + "/2-allow-dns-egress-policy.yaml",
+			Expected:   map[string]bool{"client DNS": true},
+		},
+		{
+			Name:       "allow-client-label",
+			PolicyFile: policyDir + "/3-allow-client-label-policy.yaml",
+			Expected:   map[string]bool{"client DNS": true, "client→web:80": true, "client→web:8080": true},
+		},
+		{
+			Name:       "allow-other-port-8080",
+			PolicyFile: policyDir + "/4-allow-other-port-8080-policy.yaml",
+			Expected:   map[string]bool{"client DNS": true, "client→web:80": true, "client→web:8080": true, "other→web:8080": true},
+		},
+	}
+
+	var appliedPolicies []string
+	cleanup := func() {
+		for i := len(appliedPolicies) - 1; i >= 0; i-- {
+			if err := t.deleteNetworkPolicy(ctx, appliedPolicies[i]); err != nil {
+				details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+			}
+		}
+		appliedPolicies = nil
+		t.cleanupPods(ctx, webPodName, clientPodName)
+		t.cleanupPod(ctx, otherPodName)
+	}
+
+	// Step 1: Create the suite pods
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{"policy-suite": allowlistSuiteLabel, "role": "web"}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	for podName, role := range map[string]string{clientPodName: "client", otherPodName: "other"} {
+		if err := t.createPolicyClientPod(ctx, t.namespace, podName, "", map[string]string{"policy-suite": allowlistSuiteLabel, "role": role}); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: err.Error(),
+				Details: details,
+			}
+		}
+	}
+	for _, podName := range []string{webPodName, clientPodName, otherPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	webIP := webPod.Status.PodIP
+	details = append(details, fmt.Sprintf("✓ Suite pods ready: %s (role=web, %s), %s (role=client), %s (role=other)",
+		webPodName, webIP, clientPodName, otherPodName))
+
+	// Step 2: Baseline - every flow must be open before isolation
+	for _, probe := range probes {
+		allowed, output := t.runAllowlistProbe(ctx, probe, webIP)
+		commandOutputs = append(commandOutputs, output)
+		if !allowed {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Baseline failed - %s is blocked before any policy is applied", probe.Name),
+				Details: append(details, fmt.Sprintf("✗ Baseline %s blocked", probe.Name)),
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:   "Baseline Connectivity",
+					TechnicalError: strings.TrimSpace(output.Stderr),
+					CommandOutputs: commandOutputs,
+					TroubleshootingHints: []string{
+						"Check for leftover policies selecting the suite pods: kubectl get ciliumclusterwidenetworkpolicies",
+						"Check cluster DNS is healthy: kubectl get pods -n kube-system -l k8s-app=kube-dns",
+					},
+				},
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Baseline: all %d flows open before isolation", len(probes)))
+
+	// Step 3: Layer the policies and check every flow after each one
+	header := fmt.Sprintf("ℹ️ %-24s", "STEP")
+	for _, probe := range probes {
+		header += fmt.Sprintf(" %-18s", probe.Name)
+	}
+	var rows []string
+	var mismatches []string
+	for i, step := range steps {
+		appliedPolicyName, err := t.applyNetworkPolicy(ctx, step.PolicyFile)
+		if err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to apply network policy %s: %v", step.Name, err),
+				Details: details,
+			}
+		}
+		appliedPolicies = append(appliedPolicies, appliedPolicyName)
+		details = append(details, fmt.Sprintf("✓ Step %d: applied %s from %s", i+1, appliedPolicyName, step.PolicyFile))
+
+		// Wait for policy to take effect
+		sleepContext(ctx, 5*time.Second)
+
+		row := fmt.Sprintf("ℹ️ %-24s", fmt.Sprintf("%d. %s", i+1, step.Name))
+		for _, probe := range probes {
+			allowed, output := t.runAllowlistProbe(ctx, probe, webIP)
+			commandOutputs = append(commandOutputs, output)
+			expected := step.Expected[probe.Name]
+			mark := "✓"
+			if allowed != expected {
+				mark = "✗"
+				mismatches = append(mismatches, fmt.Sprintf("step %d (%s): %s expected %s but was %s",
+					i+1, step.Name, probe.Name, reachability(expected), reachability(allowed)))
+			}
+			row += fmt.Sprintf(" %-18s", fmt.Sprintf("%s %s", mark, reachability(allowed)))
+		}
+		rows = append(rows, row)
+	}
+
+	cleanup()
+	details = append(details, "✓ Suite policies and pods cleaned up")
+	details = append(details, header)
+	details = append(details, rows...)
+
+	if len(mismatches) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d flow(s) did not match the allowlist after layering %d policies", len(mismatches), len(steps)),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Allowlist Verification",
+				TechnicalError: strings.Join(mismatches, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"A flow open after default-deny usually means policy enforcement is disabled or the pods are not selected",
+					"Label and port allow rules need both an ingress rule on the destination and an egress rule on the source",
+					"DNS egress must match the DNS pods' labels and both UDP and TCP port 53",
+					"Watch drops while probing: kubectl -n kube-system exec ds/cilium -- cilium monitor --type drop",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Default-deny and %d allowlist steps had exactly the intended effect", len(steps)-1),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
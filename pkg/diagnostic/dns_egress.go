@@ -0,0 +1,233 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dnsEgressProbe is one egress flow from the DNS egress client, checked in every phase
+type dnsEgressProbe struct {
+	Name string
+	Run  func() (bool, CommandOutput)
+}
+
+// TestEgressDNSAllow proves that with egress default-deny, allowing only port 53 to kube-dns restores name
+// resolution over UDP and TCP while all other egress stays blocked
+func (t *Tester) TestEgressDNSAllow(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	clientPodName := "client-dns-egress-test"
+	webPodName := "web-dns-egress-test"
+	policyDir := "cilium-policies/11-egress-dns"
+	lookupName := "kubernetes.default.svc.cluster.local"
+
+	var appliedPolicies []string
+	cleanup := func() {
+		for i := len(appliedPolicies) - 1; i >= 0; i-- {
+			if err := t.deleteNetworkPolicy(ctx, appliedPolicies[i]); err != nil {
+				details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+			}
+		}
+		appliedPolicies = nil
+		t.cleanupPods(ctx, webPodName, clientPodName)
+	}
+
+	// Step 1: Create the client and the in-cluster target
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{"run": "dns-egress-web"}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if err := t.createPolicyClientPod(ctx, t.namespace, clientPodName, "", map[string]string{"run": "dns-egress-client"}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	for _, podName := range []string{webPodName, clientPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	webIP := webPod.Status.PodIP
+	details = append(details, fmt.Sprintf("✓ Client '%s' (run=dns-egress-client) and target '%s' (%s) ready", clientPodName, webPodName, webIP))
+
+	dnsLookup := func(tcp bool) (bool, CommandOutput) {
+		args := []string{"dig", "+time=2", "+tries=1", "+short"}
+		description := "DNS lookup over UDP"
+		if tcp {
+			args = append(args, "+tcp")
+			description = "DNS lookup over TCP"
+		}
+		output, err := t.execInPodWithOutput(ctx, t.namespace, clientPodName, "netshoot", append(args, lookupName), description)
+		// dig exits 0 on a timeout with +short, so require an answer
+		return err == nil && strings.TrimSpace(output.Stdout) != "" && !strings.Contains(output.Stdout, "timed out"), output
+	}
+
+	probes := []dnsEgressProbe{
+		{Name: "DNS over UDP", Run: func() (bool, CommandOutput) { return dnsLookup(false) }},
+		{Name: "DNS over TCP", Run: func() (bool, CommandOutput) { return dnsLookup(true) }},
+		{Name: "web:80", Run: func() (bool, CommandOutput) { return t.probeTCPPort(ctx, clientPodName, webIP, 80) }},
+	}
+
+	// Non-DNS port on a DNS pod shows the allow rule is limited to port 53
+	dnsPods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=kube-dns"})
+	if err == nil && len(dnsPods.Items) > 0 && dnsPods.Items[0].Status.PodIP != "" {
+		dnsPodIP := dnsPods.Items[0].Status.PodIP
+		metricsProbe := dnsEgressProbe{
+			Name: "kube-dns:9153",
+			Run: func() (bool, CommandOutput) {
+				output, err := t.execInPodWithOutput(ctx, t.namespace, clientPodName, "netshoot",
+					[]string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", "3",
+						fmt.Sprintf("http://%s:9153/metrics", httpTargetForIP(dnsPodIP))},
+					"CoreDNS metrics probe (non-DNS port)")
+				code := strings.TrimSpace(output.Stdout)
+				return err == nil && code != "" && code != "000", output
+			},
+		}
+		if allowed, _ := metricsProbe.Run(); allowed {
+			probes = append(probes, metricsProbe)
+		} else {
+			details = append(details, fmt.Sprintf("ℹ️ kube-dns pod %s does not answer on 9153 at baseline - metrics probe skipped", dnsPodIP))
+		}
+	} else {
+		details = append(details, "ℹ️ No kube-dns pods found with k8s-app=kube-dns - metrics probe skipped")
+	}
+
+	// Step 2: Baseline - every flow must be open before egress is restricted
+	for _, probe := range probes {
+		allowed, output := probe.Run()
+		commandOutputs = append(commandOutputs, output)
+		if !allowed {
+			cleanup()
+			details = append(details, fmt.Sprintf("✗ Baseline %s blocked", probe.Name))
+			diagnostics := &DetailedDiagnostics{
+				FailureStage:   "Baseline Connectivity",
+				TechnicalError: strings.TrimSpace(output.Stderr),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check cluster DNS is healthy: kubectl get pods -n kube-system -l k8s-app=kube-dns",
+					"Check for leftover policies selecting the client: kubectl get ciliumclusterwidenetworkpolicies",
+				},
+			}
+			if strings.HasPrefix(probe.Name, "DNS") {
+				t.attachDNSLogs(ctx, diagnostics, &details, []string{lookupName})
+			}
+			return TestResult{
+				Success:             false,
+				Message:             fmt.Sprintf("Baseline failed - %s is blocked before any policy is applied", probe.Name),
+				Details:             details,
+				DetailedDiagnostics: diagnostics,
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Baseline: all %d egress flows open", len(probes)))
+
+	// Step 3: Egress default-deny, then DNS allow
+	phases := []allowlistStep{
+		{
+			Name:       "egress default-deny",
+			PolicyFile: policyDir + "/1-egress-default-deny-policy.yaml",
+			Expected:   map[string]bool{},
+		},
+		{
+			Name:       "DNS allow",
+			PolicyFile: policyDir + "/2-allow-dns-egress-policy.yaml",
+			Expected:   map[string]bool{"DNS over UDP": true, "DNS over TCP": true},
+		},
+	}
+
+	var mismatches []string
+	dnsBlocked := false
+	for _, phase := range phases {
+		appliedPolicyName, err := t.applyNetworkPolicy(ctx, phase.PolicyFile)
+		if err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to apply network policy for phase %s: %v", phase.Name, err),
+				Details: details,
+			}
+		}
+		appliedPolicies = append(appliedPolicies, appliedPolicyName)
+		details = append(details, fmt.Sprintf("ℹ️ Phase '%s': applied %s", phase.Name, appliedPolicyName))
+
+		// Wait for policy to take effect
+		sleepContext(ctx, 5*time.Second)
+
+		for _, probe := range probes {
+			allowed, output := probe.Run()
+			commandOutputs = append(commandOutputs, output)
+			expected := phase.Expected[probe.Name]
+			if allowed == expected {
+				details = append(details, fmt.Sprintf("✓ %s: %s %s as expected", phase.Name, probe.Name, reachability(allowed)))
+			} else {
+				details = append(details, fmt.Sprintf("✗ %s: %s %s, expected %s", phase.Name, probe.Name, reachability(allowed), reachability(expected)))
+				mismatches = append(mismatches, fmt.Sprintf("%s: %s expected %s but was %s",
+					phase.Name, probe.Name, reachability(expected), reachability(allowed)))
+				if expected && strings.HasPrefix(probe.Name, "DNS") {
+					dnsBlocked = true
+				}
+			}
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ DNS egress policies and test pods cleaned up")
+
+	if len(mismatches) > 0 {
+		diagnostics := &DetailedDiagnostics{
+			FailureStage:   "DNS Egress Verification",
+			TechnicalError: strings.Join(mismatches, "; "),
+			CommandOutputs: commandOutputs,
+			TroubleshootingHints: []string{
+				"The DNS allow rule must match the DNS pods' labels; some distributions label CoreDNS k8s-app=coredns",
+				"Allow both UDP and TCP port 53 - truncated responses are retried over TCP",
+				"With NodeLocal DNSCache the resolver is a link-local address on the node and needs a toCIDR or toEntities: host rule",
+				"Watch drops while probing: kubectl -n kube-system exec ds/cilium -- cilium monitor --type drop",
+			},
+		}
+		// Lookups that reached the DNS server and failed there are not a policy problem
+		if dnsBlocked {
+			t.attachDNSLogs(ctx, diagnostics, &details, []string{lookupName})
+		}
+		return TestResult{
+			Success:             false,
+			Message:             fmt.Sprintf("Egress DNS allow validation failed: %s", strings.Join(mismatches, "; ")),
+			Details:             details,
+			DetailedDiagnostics: diagnostics,
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: "DNS-only egress allow restores name resolution while other egress stays blocked",
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
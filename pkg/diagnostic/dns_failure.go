@@ -0,0 +1,363 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DNSFailureBlock blocks port 53 egress of the test client with a NetworkPolicy, affecting only the test pod
+	DNSFailureBlock = "block"
+	// DNSFailureCoreDNS scales CoreDNS to zero, a cluster-wide outage restored by the test
+	DNSFailureCoreDNS = "coredns"
+
+	// dnsFailurePolicyName is the NetworkPolicy blocking DNS for the test client
+	dnsFailurePolicyName = "k8s-diagnostic-dns-failure"
+	// dnsFailureWatchdog restores DNS even if the test is interrupted
+	dnsFailureWatchdog = 90 * time.Second
+	// dnsFailureSlowError flags applications that take long to see a resolution failure
+	dnsFailureSlowError = 10 * time.Second
+	// dnsCachedName is resolved before the outage so NodeLocal DNSCache holds it
+	dnsCachedName = "kubernetes.default.svc.cluster.local"
+	// defaultNodeLocalDNSIP is the link-local address NodeLocal DNSCache usually listens on
+	defaultNodeLocalDNSIP = "169.254.20.10"
+)
+
+// dnsQueryResult is the outcome of one dig query
+type dnsQueryResult struct {
+	Answered bool
+	Status   string // NOERROR, NXDOMAIN, SERVFAIL or "timeout"
+	Output   CommandOutput
+}
+
+// digQuery resolves name from the client pod, through the pod resolver or a given server
+func (t *Tester) digQuery(ctx context.Context, podName, server, name, description string) dnsQueryResult {
+	args := []string{"dig", "+time=2", "+tries=1", name}
+	if server != "" {
+		args = append(args, "@"+server)
+	}
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", args, description)
+	result := dnsQueryResult{Status: "timeout", Output: output}
+	for _, field := range []string{"NOERROR", "NXDOMAIN", "SERVFAIL", "REFUSED"} {
+		if strings.Contains(output.Stdout, "status: "+field) {
+			result.Status = field
+		}
+	}
+	result.Answered = result.Status == "NOERROR" && strings.Contains(output.Stdout, "ANSWER SECTION")
+	return result
+}
+
+// nodeLocalDNSAddress returns the NodeLocal DNSCache listen address from its DaemonSet, or "" when not installed
+func (t *Tester) nodeLocalDNSAddress(ctx context.Context) string {
+	daemonSets, err := t.clientset.AppsV1().DaemonSets("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=node-local-dns"})
+	if err != nil || len(daemonSets.Items) == 0 {
+		return ""
+	}
+	for _, container := range daemonSets.Items[0].Spec.Template.Spec.Containers {
+		for i, arg := range container.Args {
+			value := ""
+			switch {
+			case strings.HasPrefix(arg, "-localip="):
+				value = strings.TrimPrefix(arg, "-localip=")
+			case arg == "-localip" && i+1 < len(container.Args):
+				value = container.Args[i+1]
+			}
+			if value != "" {
+				return strings.Split(value, ",")[0]
+			}
+		}
+	}
+	return defaultNodeLocalDNSIP
+}
+
+// dnsBlockPolicy allows all egress of the selected pods except port 53
+func dnsBlockPolicy(selector map[string]string) *networkingv1.NetworkPolicy {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	var ports []networkingv1.NetworkPolicyPort
+	for _, protocol := range []*corev1.Protocol{&udp, &tcp} {
+		low, high := intstr.FromInt(1), intstr.FromInt(54)
+		lowEnd, highEnd := int32(52), int32(65535)
+		ports = append(ports,
+			networkingv1.NetworkPolicyPort{Protocol: protocol, Port: &low, EndPort: &lowEnd},
+			networkingv1.NetworkPolicyPort{Protocol: protocol, Port: &high, EndPort: &highEnd})
+	}
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: dnsFailurePolicyName},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: selector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{{Ports: ports}},
+		},
+	}
+}
+
+// kubeDNSEndpointCount returns the number of ready kube-dns endpoints
+func (t *Tester) kubeDNSEndpointCount(ctx context.Context) int {
+	slices, err := t.clientset.DiscoveryV1().EndpointSlices("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "kubernetes.io/service-name=kube-dns"})
+	if err != nil {
+		return -1
+	}
+	count := 0
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// TestDNSFailure injects a DNS outage - blocking port 53 for the test client, or scaling CoreDNS to zero - and
+// verifies applications see a prompt resolution failure, that NodeLocal DNSCache keeps answering a cached name, and
+// that DNS recovers once the outage is reverted. The revert runs on every exit path and from a watchdog.
+func (t *Tester) TestDNSFailure(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	mode := config.DNSFailure
+	if mode == "" {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "DNS failure injection skipped - opt in with --dns-failure=block or --dns-failure=coredns",
+			Details: details,
+		}
+	}
+	if mode != DNSFailureBlock && mode != DNSFailureCoreDNS {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Unknown --dns-failure mode %q (use %s or %s)", mode, DNSFailureBlock, DNSFailureCoreDNS),
+			Details: details,
+		}
+	}
+	clientPod := "client-dns-failure-test"
+	clientLabels := map[string]string{"run": "dns-failure-client"}
+	uncachedName := fmt.Sprintf("dns-failure-%d.%s.svc.cluster.local", time.Now().UnixNano(), t.namespace)
+	var commandOutputs []CommandOutput
+
+	// Step 1: Client pod, NodeLocal DNSCache detection and baseline resolution
+	if err := t.createPolicyClientPod(ctx, t.namespace, clientPod, "", clientLabels); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	defer t.cleanupPod(ctx, clientPod)
+	if err := t.waitForPodReady(ctx, clientPod, 60*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Pod %s did not become ready: %v", clientPod, err),
+			Details: details,
+		}
+	}
+	nodeLocal := t.nodeLocalDNSAddress(ctx)
+	if nodeLocal != "" {
+		details = append(details, fmt.Sprintf("ℹ️ NodeLocal DNSCache detected at %s", nodeLocal))
+	} else {
+		details = append(details, "ℹ️ NodeLocal DNSCache not installed - cached-name check skipped")
+	}
+	baseline := t.digQuery(ctx, clientPod, "", dnsCachedName, "Baseline resolution")
+	commandOutputs = append(commandOutputs, baseline.Output)
+	if !baseline.Answered {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Baseline failed - %s does not resolve before any fault is injected (%s)", dnsCachedName, baseline.Status),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Baseline",
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: []string{"Run the dns test first - DNS is broken before the outage"},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Baseline: %s resolves", dnsCachedName))
+
+	// Step 2: Inject the outage under a watchdog
+	var revertOnce sync.Once
+	var revertErr error
+	var revert func()
+	deployments := t.clientset.AppsV1().Deployments("kube-system")
+	switch mode {
+	case DNSFailureBlock:
+		if _, err := t.clientset.NetworkingV1().NetworkPolicies(t.namespace).Create(ctx, dnsBlockPolicy(clientLabels), metav1.CreateOptions{}); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create NetworkPolicy %s: %v", dnsFailurePolicyName, err),
+				Details: details,
+			}
+		}
+		revert = func() {
+			revertOnce.Do(func() {
+				revertCtx, cancel := cleanupContext(ctx)
+				defer cancel()
+				revertErr = t.clientset.NetworkingV1().NetworkPolicies(t.namespace).Delete(revertCtx, dnsFailurePolicyName, metav1.DeleteOptions{})
+			})
+		}
+		details = append(details, fmt.Sprintf("✓ Blocked port 53 egress of %s with NetworkPolicy %s", clientPod, dnsFailurePolicyName))
+	case DNSFailureCoreDNS:
+		list, err := deployments.List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=kube-dns"})
+		if err != nil || len(list.Items) == 0 {
+			return TestResult{
+				Success: true,
+				Skipped: true,
+				Message: "DNS failure injection skipped - no kube-dns Deployment in kube-system (managed or non-CoreDNS DNS)",
+				Details: details,
+			}
+		}
+		deploymentName := list.Items[0].Name
+		scale, err := deployments.GetScale(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to read the scale of %s: %v", deploymentName, err),
+				Details: details,
+			}
+		}
+		originalReplicas := scale.Spec.Replicas
+		scale.Spec.Replicas = 0
+		if _, err := deployments.UpdateScale(ctx, deploymentName, scale, metav1.UpdateOptions{}); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to scale %s to zero: %v", deploymentName, err),
+				Details: details,
+			}
+		}
+		revert = func() {
+			revertOnce.Do(func() {
+				revertCtx, cancel := cleanupContext(ctx)
+				defer cancel()
+				current, err := deployments.GetScale(revertCtx, deploymentName, metav1.GetOptions{})
+				if err == nil {
+					current.Spec.Replicas = originalReplicas
+					_, err = deployments.UpdateScale(revertCtx, deploymentName, current, metav1.UpdateOptions{})
+				}
+				if err != nil {
+					// May run from the watchdog goroutine, so report on the console as well
+					fmt.Printf("⚠️ Failed to restore CoreDNS, scale it back manually: kubectl -n kube-system scale deployment %s --replicas=%d\n", deploymentName, originalReplicas)
+					revertErr = err
+				}
+			})
+		}
+		details = append(details, fmt.Sprintf("✓ Scaled kube-system/%s from %d to 0 replicas", deploymentName, originalReplicas))
+	}
+	watchdog := time.AfterFunc(dnsFailureWatchdog, revert)
+	defer watchdog.Stop()
+	defer revert()
+
+	// Warm the NodeLocal cache right before the outage takes effect
+	if nodeLocal != "" {
+		t.digQuery(ctx, clientPod, nodeLocal, dnsCachedName, "Warm the NodeLocal DNSCache")
+	}
+	if mode == DNSFailureCoreDNS {
+		deadline := time.Now().Add(30 * time.Second)
+		for t.kubeDNSEndpointCount(ctx) != 0 && time.Now().Before(deadline) && ctx.Err() == nil {
+			sleepContext(ctx, time.Second)
+		}
+	} else {
+		// Wait for the policy to take effect
+		sleepContext(ctx, 5*time.Second)
+	}
+
+	// Step 3: Behaviour during the outage
+	metrics := map[string]float64{}
+	var problems []string
+	uncached := t.digQuery(ctx, clientPod, "", uncachedName, "Uncached lookup during the outage")
+	commandOutputs = append(commandOutputs, uncached.Output)
+	if uncached.Status == "NXDOMAIN" || uncached.Answered {
+		problems = append(problems, fmt.Sprintf("an uncached lookup still got %s during the outage - the fault was not effective", uncached.Status))
+		details = append(details, fmt.Sprintf("✗ Uncached lookup answered %s during the outage", uncached.Status))
+	} else {
+		details = append(details, fmt.Sprintf("✓ Uncached lookup fails during the outage (%s)", uncached.Status))
+	}
+
+	start := time.Now()
+	appOutput, appErr := t.execInPodWithOutput(ctx, t.namespace, clientPod, "netshoot",
+		[]string{"curl", "-s", "-o", "/dev/null", "--max-time", "30", "http://kubernetes.default.svc"}, "Application request by name during the outage")
+	appFailure := time.Since(start)
+	commandOutputs = append(commandOutputs, appOutput)
+	metrics["app_failure_ms"] = float64(appFailure.Milliseconds())
+	var warnings []string
+	switch {
+	case appErr == nil:
+		details = append(details, "⚠️ Application request by name succeeded during the outage (resolver or application cache)")
+	case appFailure > dnsFailureSlowError:
+		details = append(details, fmt.Sprintf("⚠️ Application saw the resolution failure only after %v - resolver timeouts multiplied by search domains (ndots:5) stall clients", appFailure.Round(100*time.Millisecond)))
+		warnings = append(warnings, fmt.Sprintf("applications saw the resolution failure only after %v", appFailure.Round(100*time.Millisecond)))
+	default:
+		details = append(details, fmt.Sprintf("✓ Application saw the resolution failure after %v", appFailure.Round(100*time.Millisecond)))
+	}
+
+	if nodeLocal != "" {
+		cached := t.digQuery(ctx, clientPod, nodeLocal, dnsCachedName, "Cached lookup through NodeLocal DNSCache during the outage")
+		commandOutputs = append(commandOutputs, cached.Output)
+		switch {
+		case cached.Answered:
+			metrics["nodelocal_cached_answer"] = 1
+			details = append(details, fmt.Sprintf("✓ NodeLocal DNSCache still answers the cached %s", dnsCachedName))
+		case mode == DNSFailureBlock:
+			details = append(details, "ℹ️ NodeLocal DNSCache unreachable - the policy blocks port 53 to every destination")
+		default:
+			metrics["nodelocal_cached_answer"] = 0
+			details = append(details, fmt.Sprintf("⚠️ NodeLocal DNSCache did not answer the cached %s (%s) - cluster records have a short TTL (5s) unless serve_stale is enabled", dnsCachedName, cached.Status))
+		}
+	}
+
+	// Step 4: Revert and wait for DNS to recover
+	watchdog.Stop()
+	revert()
+	if revertErr != nil {
+		problems = append(problems, fmt.Sprintf("failed to revert the outage: %v", revertErr))
+	}
+	recoveryStart := time.Now()
+	var recovered bool
+	for time.Since(recoveryStart) < 60*time.Second && ctx.Err() == nil {
+		if t.digQuery(ctx, clientPod, "", dnsCachedName, "Resolution after revert").Answered {
+			recovered = true
+			break
+		}
+		sleepContext(ctx, time.Second)
+	}
+	if recovered {
+		metrics["recovery_ms"] = float64(time.Since(recoveryStart).Milliseconds())
+		details = append(details, fmt.Sprintf("✓ DNS recovered %v after the revert", time.Since(recoveryStart).Round(100*time.Millisecond)))
+	} else {
+		problems = append(problems, "DNS did not recover within 60s of the revert")
+		details = append(details, "✗ DNS did not recover within 60s of the revert")
+	}
+
+	if len(problems) > 0 {
+		hints := []string{
+			"A fault that is not effective in block mode means the CNI does not enforce NetworkPolicy egress (or endPort)",
+			"Check CoreDNS is back: kubectl -n kube-system get deploy,pods -l k8s-app=kube-dns",
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("DNS failure injection (%s): %s", mode, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "DNS Failure Injection",
+				TechnicalError:       strings.Join(problems, "; "),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	return TestResult{
+		Success:  true,
+		Message:  fmt.Sprintf("DNS outage (%s) failed lookups as expected, applications saw it after %v, and DNS recovered", mode, appFailure.Round(100*time.Millisecond)),
+		Details:  details,
+		Metrics:  metrics,
+		Warnings: warnings,
+	}
+}
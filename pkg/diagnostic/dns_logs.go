@@ -0,0 +1,96 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dnsLogWindow is how far back DNS server logs are read after a DNS test failure
+const dnsLogWindow = 10 * time.Minute
+
+// dnsLogExcerptLimit caps the number of log lines kept per DNS pod in the report
+const dnsLogExcerptLimit = 40
+
+// dnsServerSelectors are the kube-system DNS servers whose logs are searched: CoreDNS and NodeLocal DNSCache
+var dnsServerSelectors = []struct {
+	Name     string
+	Selector string
+}{
+	{Name: "CoreDNS", Selector: "k8s-app=kube-dns"},
+	{Name: "NodeLocal DNSCache", Selector: "k8s-app=node-local-dns"},
+}
+
+// filterDNSFailureLines keeps SERVFAIL and timeout lines that mention any of the queried names
+func filterDNSFailureLines(logs string, names []string) []string {
+	var matched []string
+	for _, line := range strings.Split(logs, "\n") {
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "servfail") && !strings.Contains(lower, "timeout") && !strings.Contains(lower, "timed out") {
+			continue
+		}
+		for _, name := range names {
+			if strings.Contains(lower, strings.ToLower(strings.TrimSuffix(name, "."))) {
+				matched = append(matched, line)
+				break
+			}
+		}
+		if len(matched) == dnsLogExcerptLimit {
+			break
+		}
+	}
+	return matched
+}
+
+// attachDNSLogs searches the recent CoreDNS and NodeLocal DNSCache logs for SERVFAIL and timeout lines about the
+// queried names and adds the excerpts to the diagnostics as command outputs
+func (t *Tester) attachDNSLogs(ctx context.Context, diagnostics *DetailedDiagnostics, details *[]string, names []string) {
+	matchedLines := 0
+	searchedPods := 0
+	for _, server := range dnsServerSelectors {
+		pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: server.Selector})
+		if err != nil {
+			*details = append(*details, fmt.Sprintf("⚠️ Could not list %s pods: %v", server.Name, err))
+			continue
+		}
+		for _, pod := range pods.Items {
+			output := CommandOutput{
+				Command: fmt.Sprintf("kubectl logs -n kube-system %s --since=%s | grep -iE 'SERVFAIL|timeout|timed out' | grep -iF -e %s",
+					pod.Name, dnsLogWindow, strings.Join(names, " -e ")),
+				Description: fmt.Sprintf("%s log lines about the failed lookups on %s", server.Name, pod.Spec.NodeName),
+			}
+			logs, err := t.podLogs(ctx, "kube-system", pod.Name, "", dnsLogWindow)
+			if err != nil {
+				output.ExitCode = -1
+				output.Stderr = err.Error()
+				diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+				*details = append(*details, fmt.Sprintf("⚠️ Could not read logs of %s pod %s: %v", server.Name, pod.Name, err))
+				continue
+			}
+			searchedPods++
+			excerpt := filterDNSFailureLines(string(logs), names)
+			if len(excerpt) == 0 {
+				continue
+			}
+			matchedLines += len(excerpt)
+			output.Stdout = strings.Join(excerpt, "\n")
+			diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+			*details = append(*details, fmt.Sprintf("✗ %s pod %s logged %d SERVFAIL/timeout lines about the queried names", server.Name, pod.Name, len(excerpt)))
+		}
+	}
+
+	switch {
+	case searchedPods == 0:
+		return
+	case matchedLines == 0:
+		*details = append(*details, fmt.Sprintf("ℹ️ No SERVFAIL/timeout lines about the queried names in %d DNS pod logs (last %s)", searchedPods, dnsLogWindow))
+		diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints,
+			"No DNS server logged the failed lookups - the queries may never have reached it (check policies and the kube-dns Service endpoints), or the CoreDNS log plugin is not enabled in the Corefile")
+	default:
+		diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints,
+			"The DNS server received the queries but failed to answer - check CoreDNS upstream forwarders and its connectivity to the API server: kubectl -n kube-system logs -l k8s-app=kube-dns")
+	}
+}
@@ -0,0 +1,107 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testEventLimit caps the number of events attached to one test result
+const testEventLimit = 100
+
+// TestEvent is a Kubernetes Event recorded for a test resource while the test ran
+type TestEvent struct {
+	Time    string `json:"time"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Object  string `json:"object"`
+	Message string `json:"message"`
+	Count   int32  `json:"count,omitempty"`
+}
+
+// eventTimestamp returns when an event last occurred, whichever of the event's time fields is set
+func eventTimestamp(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// collectTestEvents returns the events in the test namespace that occurred since the given time, oldest first
+func (t *Tester) collectTestEvents(ctx context.Context, since time.Time, warningsOnly bool) ([]TestEvent, error) {
+	options := metav1.ListOptions{}
+	if warningsOnly {
+		options.FieldSelector = "type=Warning"
+	}
+	events, err := t.clientset.CoreV1().Events(t.namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Event timestamps have second precision
+	since = since.Truncate(time.Second)
+	var items []corev1.Event
+	for _, event := range events.Items {
+		if !eventTimestamp(event).Before(since) {
+			items = append(items, event)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return eventTimestamp(items[i]).Before(eventTimestamp(items[j])) })
+	if len(items) > testEventLimit {
+		items = items[len(items)-testEventLimit:]
+	}
+
+	var testEvents []TestEvent
+	for _, event := range items {
+		testEvents = append(testEvents, TestEvent{
+			Time:    eventTimestamp(event).Format(time.RFC3339),
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Object:  fmt.Sprintf("%s/%s", event.InvolvedObject.Kind, event.InvolvedObject.Name),
+			Message: event.Message,
+			Count:   event.Count,
+		})
+	}
+	return testEvents, nil
+}
+
+// AttachEvents adds the events of the test's resources to its diagnostics: every event for a failed test, and
+// warnings only for a passing one, since scheduling, image pull, CNI and probe problems show up there first
+func (t *Tester) AttachEvents(ctx context.Context, result *TestResult, since time.Time) {
+	// Test resources rejected by a failing admission webhook never get events of their own
+	if hint := webhookDenialHint(*result); hint != "" && !result.Success {
+		if result.DetailedDiagnostics == nil {
+			result.DetailedDiagnostics = &DetailedDiagnostics{}
+		}
+		result.DetailedDiagnostics.TroubleshootingHints = append(result.DetailedDiagnostics.TroubleshootingHints, hint)
+	}
+
+	events, err := t.collectTestEvents(ctx, since, result.Success)
+	if err != nil || len(events) == 0 {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	result.DetailedDiagnostics.Events = events
+
+	warnings := 0
+	for _, event := range events {
+		if event.Type == corev1.EventTypeWarning {
+			warnings++
+		}
+	}
+	if warnings > 0 {
+		result.Details = append(result.Details, fmt.Sprintf("⚠️ %d warning events recorded for test resources", warnings))
+		result.Details = append(result.Details, fmt.Sprintf("  kubectl get events -n %s --field-selector type=Warning", t.namespace))
+	}
+}
@@ -0,0 +1,61 @@
+package diagnostic
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// maxRetainedOutput caps the stdout and stderr kept in a CommandOutput. Longer output keeps its end, where ping,
+// iperf and curl print their summary.
+const maxRetainedOutput = 256 * 1024
+
+// outputStream receives the output of a command as it arrives. Each complete line is passed to onLine, and at most
+// maxRetainedOutput bytes of the end of the output are kept.
+type outputStream struct {
+	onLine  func(line string)
+	partial []byte
+	tail    []byte
+	dropped int
+}
+
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.tail = append(s.tail, p...)
+	if excess := len(s.tail) - maxRetainedOutput; excess > 0 {
+		s.dropped += excess
+		s.tail = append(s.tail[:0], s.tail[excess:]...)
+	}
+
+	if s.onLine != nil {
+		s.partial = append(s.partial, p...)
+		for {
+			end := bytes.IndexByte(s.partial, '\n')
+			if end < 0 {
+				break
+			}
+			s.onLine(string(bytes.TrimRight(s.partial[:end], "\r")))
+			s.partial = s.partial[end+1:]
+		}
+		// A line without a newline in sight is passed on in pieces rather than buffered without bound
+		if len(s.partial) > maxRetainedOutput {
+			s.onLine(string(s.partial))
+			s.partial = nil
+		}
+	}
+	return len(p), nil
+}
+
+// flush passes on the last line when the output did not end with a newline
+func (s *outputStream) flush() {
+	if s.onLine != nil && len(s.partial) > 0 {
+		s.onLine(string(s.partial))
+	}
+	s.partial = nil
+}
+
+// String returns the retained output, noting how much of the beginning was dropped
+func (s *outputStream) String() string {
+	if s.dropped > 0 {
+		return fmt.Sprintf("[... %d bytes of earlier output dropped ...]\n%s", s.dropped, s.tail)
+	}
+	return string(s.tail)
+}
@@ -0,0 +1,246 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// externalDNSHostnameAnnotation asks external-dns to publish a record for the service's load balancer address
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+	// externalDNSTTLAnnotation keeps the test record short-lived in resolver caches
+	externalDNSTTLAnnotation = "external-dns.alpha.kubernetes.io/ttl"
+	// defaultExternalDNSTimeout is the time budget for the record to resolve when none is configured
+	defaultExternalDNSTimeout = 5 * time.Minute
+	// externalDNSPollInterval is the time between two DNS queries for the record
+	externalDNSPollInterval = 10 * time.Second
+)
+
+// externalDNSResolver returns a resolver that sends every query to server (host or host:port), or the system
+// resolver when server is empty
+func externalDNSResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// zoneNameserver returns the first authoritative nameserver of zone; asking it directly sees a new record as soon as
+// external-dns wrote it, without the negative caching of recursive resolvers
+func zoneNameserver(ctx context.Context, zone string) (string, error) {
+	nameservers, err := net.DefaultResolver.LookupNS(ctx, zone)
+	if err != nil {
+		return "", err
+	}
+	if len(nameservers) == 0 {
+		return "", fmt.Errorf("zone %s has no NS records", zone)
+	}
+	return strings.TrimSuffix(nameservers[0].Host, "."), nil
+}
+
+// resolveExternalRecord looks the record up through resolver; an authoritative server that answers with a CNAME to
+// another zone is reported through the returned CNAME target instead of addresses
+func resolveExternalRecord(ctx context.Context, resolver *net.Resolver, hostname string) ([]string, string, error) {
+	addresses, err := resolver.LookupHost(ctx, hostname)
+	if err == nil {
+		return addresses, "", nil
+	}
+	if cname, cnameErr := resolver.LookupCNAME(ctx, hostname); cnameErr == nil && strings.TrimSuffix(cname, ".") != hostname {
+		return nil, strings.TrimSuffix(cname, "."), nil
+	}
+	return nil, "", err
+}
+
+// externalDNSControllers lists the namespaces running an external-dns controller
+func (t *Tester) externalDNSControllers(ctx context.Context) []string {
+	var namespaces []string
+	for _, selector := range []string{"app.kubernetes.io/name=external-dns", "app=external-dns"} {
+		deployments, err := t.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			continue
+		}
+		for _, deployment := range deployments.Items {
+			if !slices.Contains(namespaces, deployment.Namespace) {
+				namespaces = append(namespaces, deployment.Namespace)
+			}
+		}
+	}
+	return namespaces
+}
+
+// TestExternalDNS annotates a LoadBalancer test service for external-dns and polls the configured zone until the
+// record resolves to the load balancer address, timing the whole service to public DNS path
+func (t *Tester) TestExternalDNS(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	if config.ExternalDNSZone == "" {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "ExternalDNS test skipped - opt in with --external-dns-zone",
+			Details: details,
+		}
+	}
+	zone := strings.Trim(config.ExternalDNSZone, ".")
+	budget := config.ExternalDNSTimeout
+	if budget <= 0 {
+		budget = defaultExternalDNSTimeout
+	}
+	deploymentName := "web-external-dns"
+	serviceName := "web-external-dns"
+	hostname := fmt.Sprintf("%s-%d.%s", t.namespace, time.Now().Unix(), zone)
+
+	fail := func(stage, message string, hints ...string) TestResult {
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, "")
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       message,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 1: external-dns usually runs in the cluster, but may also run elsewhere against it
+	if controllers := t.externalDNSControllers(ctx); len(controllers) > 0 {
+		details = append(details, fmt.Sprintf("✓ external-dns controller found in namespace(s) %s", strings.Join(controllers, ", ")))
+	} else {
+		details = append(details, "⚠️ No external-dns Deployment found in the cluster; expecting a controller running elsewhere")
+	}
+
+	// Step 2: Choose the nameserver to poll
+	server := config.ExternalDNSServer
+	if server == "" {
+		if nameserver, err := zoneNameserver(ctx, zone); err == nil {
+			server = nameserver
+			details = append(details, fmt.Sprintf("ℹ️ Polling authoritative nameserver %s of zone %s", server, zone))
+		} else {
+			details = append(details, fmt.Sprintf("ℹ️ NS lookup of zone %s failed (%v); polling the system resolver", zone, err))
+		}
+	} else {
+		details = append(details, fmt.Sprintf("ℹ️ Polling nameserver %s", server))
+	}
+	resolver := externalDNSResolver(server)
+
+	// Step 3: Create the backend and a LoadBalancer service annotated with the test hostname
+	deploymentName, reused, err := t.nginxBackend(ctx, deploymentName)
+	if err != nil {
+		return fail("Backend Setup", fmt.Sprintf("Failed to create nginx deployment: %v", err))
+	}
+	details = append(details, fmt.Sprintf("✓ %s nginx deployment '%s'", fixtureAction(reused), deploymentName))
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		return fail("Backend Setup", fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err))
+	}
+
+	service, err := t.createNginxServiceWithType(ctx, serviceName, deploymentName, ServiceTypeLoadBalancer)
+	if err != nil {
+		return fail("Service Creation", fmt.Sprintf("Failed to create LoadBalancer service: %v", err))
+	}
+	service.Annotations = map[string]string{
+		externalDNSHostnameAnnotation: hostname,
+		externalDNSTTLAnnotation:      "60",
+	}
+	if _, err := t.clientset.CoreV1().Services(t.namespace).Update(ctx, service, metav1.UpdateOptions{}); err != nil {
+		return fail("Service Creation", fmt.Sprintf("Failed to annotate service %s: %v", serviceName, err))
+	}
+	details = append(details, fmt.Sprintf("✓ Created LoadBalancer service '%s' annotated with %s=%s", serviceName, externalDNSHostnameAnnotation, hostname))
+	annotated := time.Now()
+
+	lbTimeout := config.LoadBalancerTimeout
+	if lbTimeout <= 0 {
+		lbTimeout = 60 * time.Second
+	}
+	lbAddress, err := t.waitForLoadBalancerIngress(ctx, serviceName, lbTimeout)
+	if err != nil {
+		return fail("LoadBalancer Address Assignment", fmt.Sprintf("LoadBalancer service received no address, external-dns has nothing to publish: %v", err),
+			"external-dns publishes Service records only for an assigned load balancer address",
+			"Run the loadbalancer test first, or use --lb-timeout to wait longer")
+	}
+	details = append(details, fmt.Sprintf("✓ Load balancer address assigned: %s", lbAddress))
+
+	// A hostname address (e.g. AWS ELB) is published as a CNAME or alias; compare the addresses it resolves to
+	expected := []string{lbAddress}
+	lbHostname := net.ParseIP(lbAddress) == nil
+	if lbHostname {
+		if addresses, err := net.DefaultResolver.LookupHost(ctx, lbAddress); err == nil {
+			expected = addresses
+		}
+	}
+
+	// Step 4: Poll the zone until the record resolves to the load balancer address
+	details = append(details, fmt.Sprintf("⏳ Waiting up to %s for %s to resolve...", budget, hostname))
+	deadline := annotated.Add(budget)
+	var lastErr error
+	var lastAnswer string
+	for {
+		addresses, cname, err := resolveExternalRecord(ctx, resolver, hostname)
+		switch {
+		case err != nil:
+			lastErr = err
+		case cname != "":
+			lastAnswer = "CNAME " + cname
+			if lbHostname && strings.EqualFold(cname, lbAddress) {
+				elapsed := time.Since(annotated)
+				details = append(details, fmt.Sprintf("✓ %s is a CNAME to the load balancer %s after %s", hostname, lbAddress, elapsed.Round(time.Second)))
+				return t.externalDNSPassed(ctx, details, deploymentName, serviceName, hostname, elapsed)
+			}
+		default:
+			lastAnswer = strings.Join(addresses, ", ")
+			if slices.ContainsFunc(addresses, func(address string) bool { return slices.Contains(expected, address) }) {
+				elapsed := time.Since(annotated)
+				details = append(details, fmt.Sprintf("✓ %s resolves to %s after %s", hostname, lastAnswer, elapsed.Round(time.Second)))
+				return t.externalDNSPassed(ctx, details, deploymentName, serviceName, hostname, elapsed)
+			}
+		}
+		if time.Now().Add(externalDNSPollInterval).After(deadline) || ctx.Err() != nil {
+			break
+		}
+		sleepContext(ctx, externalDNSPollInterval)
+	}
+
+	hints := []string{
+		fmt.Sprintf("Check the external-dns logs for %s: kubectl logs -n <namespace> deploy/external-dns", hostname),
+		fmt.Sprintf("external-dns only manages zones matching its --domain-filter; it must include %s", zone),
+		"external-dns must watch Services (--source=service) and be allowed to write the zone (--policy sync or upsert-only)",
+		"Use --external-dns-timeout for providers that take longer to apply changes",
+	}
+	if lastAnswer != "" {
+		details = append(details, fmt.Sprintf("✗ %s resolves to %s, expected %s", hostname, lastAnswer, strings.Join(expected, ", ")))
+		return fail("Record Validation", fmt.Sprintf("ExternalDNS record %s does not point at the load balancer address %s", hostname, lbAddress),
+			append([]string{"A stale record from another owner is not overwritten; check the TXT ownership records in the zone"}, hints...)...)
+	}
+	details = append(details, fmt.Sprintf("✗ %s did not resolve within %s: %v", hostname, budget, lastErr))
+	return fail("Record Propagation", fmt.Sprintf("ExternalDNS record %s was not published within %s", hostname, budget), hints...)
+}
+
+// externalDNSPassed cleans up and reports the time the record took to resolve
+func (t *Tester) externalDNSPassed(ctx context.Context, details []string, deploymentName, serviceName, hostname string, elapsed time.Duration) TestResult {
+	t.cleanupServiceResources(ctx, deploymentName, serviceName, "")
+	details = append(details, "✓ Cleaned up ExternalDNS test resources")
+	details = append(details, "ℹ️ external-dns removes the record on its next sync only with --policy sync")
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("ExternalDNS record %s resolves to the load balancer address after %s", hostname, elapsed.Round(time.Second)),
+		Details: details,
+		Metrics: map[string]float64{
+			"record_propagation_ms": float64(elapsed.Milliseconds()),
+		},
+	}
+}
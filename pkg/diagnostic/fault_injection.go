@@ -0,0 +1,470 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// faultInterface is the pod interface netem is attached to
+	faultInterface = "eth0"
+	// faultPingCount and faultPingInterval control each RTT measurement
+	faultPingCount    = 20
+	faultPingInterval = "0.2"
+	// faultLatencyTolerance is the absolute slack allowed between injected and measured latency, on top of a
+	// relative 20%
+	faultLatencyTolerance = 2.0
+	// faultLossPings and faultLossRequests are the sample sizes of the packet loss measurements
+	faultLossPings    = 200
+	faultLossRequests = 50
+)
+
+// createNetAdminPod creates a netshoot pod with the NET_ADMIN capability, which tc needs to attach a qdisc to the
+// pod's own interface. The change stays inside the pod's network namespace and disappears with the pod.
+func (t *Tester) createNetAdminPod(ctx context.Context, name, nodeName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "netshoot-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+					SecurityContext: &corev1.SecurityContext{
+						Capabilities: &corev1.Capabilities{
+							Add: []corev1.Capability{"NET_ADMIN"},
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// applyNetem replaces the root qdisc of the pod's interface with netem using the given parameters, e.g. "delay 50ms"
+func (t *Tester) applyNetem(ctx context.Context, podName, parameters string) (CommandOutput, error) {
+	command := append([]string{"tc", "qdisc", "replace", "dev", faultInterface, "root", "netem"}, strings.Fields(parameters)...)
+	return t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", command, fmt.Sprintf("Inject %s on %s", parameters, faultInterface))
+}
+
+// clearNetem restores the pod interface's default qdisc
+func (t *Tester) clearNetem(ctx context.Context, podName string) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	t.execInPod(ctx, t.namespace, podName, "netshoot", []string{"tc", "qdisc", "del", "dev", faultInterface, "root"})
+}
+
+// measurePingRTT returns the average RTT in ms of a burst of pings, or 0 when no reply was received
+func (t *Tester) measurePingRTT(ctx context.Context, podName, targetIP, description string) (float64, CommandOutput) {
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"ping", "-c", fmt.Sprint(faultPingCount), "-i", faultPingInterval, "-W", "2", targetIP}, description)
+	return t.extractPingLatency(output.Stdout), output
+}
+
+// faultInjectionPods creates a NET_ADMIN client pod and a target pod, on different worker nodes when possible, and
+// returns the target's IP
+func (t *Tester) faultInjectionPods(ctx context.Context, clientPod, targetPod string) (string, string, error) {
+	nodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(nodes) == 0 {
+		return "", "", fmt.Errorf("fault injection requires at least 1 worker node")
+	}
+	clientNode, targetNode := nodes[0], nodes[0]
+	placement := "same node"
+	if len(nodes) > 1 {
+		targetNode = nodes[1]
+		placement = "cross-node"
+	}
+	if _, err := t.createNetAdminPod(ctx, clientPod, clientNode); err != nil {
+		return "", "", fmt.Errorf("failed to create client pod %s: %v", clientPod, err)
+	}
+	if _, err := t.createNetshootPod(ctx, targetPod, targetNode); err != nil {
+		return "", "", fmt.Errorf("failed to create target pod %s: %v", targetPod, err)
+	}
+	for _, pod := range []string{clientPod, targetPod} {
+		if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+			return "", "", fmt.Errorf("pod %s not ready: %v", pod, err)
+		}
+	}
+	target, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, targetPod, metav1.GetOptions{})
+	if err != nil || target.Status.PodIP == "" {
+		return "", "", fmt.Errorf("target pod %s has no IP", targetPod)
+	}
+	return target.Status.PodIP, fmt.Sprintf("%s (%s -> %s)", placement, clientNode, targetNode), nil
+}
+
+// netemFailureHints explain why tc could not attach netem inside the pod
+var netemFailureHints = []string{
+	"tc needs the NET_ADMIN capability - a restricted Pod Security Standard or policy engine on the test namespace rejects or strips it",
+	"The node kernel needs the sch_netem module: modprobe sch_netem on the node",
+}
+
+// TestLatencyInjection measures the pod-to-pod RTT, adds the configured delay with tc netem on the client pod's
+// interface, and verifies the measured RTT rises by the injected amount and returns to the baseline once the delay is
+// removed. It validates that the tool's latency measurement reflects real network delay.
+func (t *Tester) TestLatencyInjection(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	if config.InjectLatency <= 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Latency injection skipped - opt in with --inject-latency",
+			Details: details,
+		}
+	}
+	injected := float64(config.InjectLatency.Microseconds()) / 1000
+	clientPod := "netshoot-fault-latency-client"
+	targetPod := "netshoot-fault-latency-target"
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, clientPod, metav1.DeleteOptions{})
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, targetPod, metav1.DeleteOptions{})
+	}()
+
+	// Step 1: Pods and baseline RTT
+	targetIP, placement, err := t.faultInjectionPods(ctx, clientPod, targetPod)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Client and target pods ready, %s", placement))
+	baseline, baselineOutput := t.measurePingRTT(ctx, clientPod, targetIP, "Baseline RTT")
+	if baseline == 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("No baseline ping reply from %s", targetIP),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Baseline",
+				CommandOutputs: []CommandOutput{baselineOutput},
+				TroubleshootingHints: []string{
+					"Pod-to-pod ICMP fails before any fault is injected - run the pod-to-pod test first",
+				},
+			},
+		}
+	}
+
+	// Step 2: Inject the delay on the client's egress, so each round trip is delayed once
+	netemOutput, err := t.applyNetem(ctx, clientPod, fmt.Sprintf("delay %.3fms", injected))
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to inject latency with tc netem: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Fault Injection",
+				TechnicalError:       err.Error(),
+				CommandOutputs:       []CommandOutput{netemOutput},
+				TroubleshootingHints: netemFailureHints,
+			},
+		}
+	}
+	defer t.clearNetem(ctx, clientPod)
+	details = append(details, fmt.Sprintf("✓ Injected %.1fms delay with tc netem on %s of %s", injected, faultInterface, clientPod))
+	measured, measuredOutput := t.measurePingRTT(ctx, clientPod, targetIP, "RTT with injected latency")
+
+	// Step 3: Remove the delay and confirm the RTT recovers
+	t.clearNetem(ctx, clientPod)
+	recovered, recoveredOutput := t.measurePingRTT(ctx, clientPod, targetIP, "RTT after removing the fault")
+
+	increase := measured - baseline
+	tolerance := math.Max(0.2*injected, faultLatencyTolerance)
+	metrics := map[string]float64{
+		"baseline_rtt_ms":  baseline,
+		"injected_ms":      injected,
+		"measured_rtt_ms":  measured,
+		"increase_ms":      increase,
+		"recovered_rtt_ms": recovered,
+	}
+	// The typed latency and loss describe the path while the delay was injected
+	latency, loss := pingMetrics(measuredOutput.Stdout)
+	typedMetrics := &TestMetrics{Latency: latency, Loss: loss}
+	details = append(details, fmt.Sprintf("  %-22s %10s", "PHASE", "AVG RTT"))
+	details = append(details, fmt.Sprintf("  %-22s %8.2fms", "baseline", baseline))
+	details = append(details, fmt.Sprintf("  %-22s %8.2fms", fmt.Sprintf("+%.1fms injected", injected), measured))
+	details = append(details, fmt.Sprintf("  %-22s %8.2fms", "fault removed", recovered))
+
+	var problems []string
+	switch {
+	case measured == 0:
+		problems = append(problems, "no ping reply while the delay was injected")
+	case math.Abs(increase-injected) > tolerance:
+		problems = append(problems, fmt.Sprintf("measured increase %.2fms differs from the injected %.1fms by more than %.1fms", increase, injected, tolerance))
+	}
+	if recovered == 0 || recovered-baseline > math.Max(baseline, faultLatencyTolerance) {
+		problems = append(problems, fmt.Sprintf("RTT did not return to the baseline after removing the fault (%.2fms vs %.2fms)", recovered, baseline))
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success:      false,
+			Message:      fmt.Sprintf("Injected %.1fms latency was not measured correctly", injected),
+			Details:      details,
+			Metrics:      metrics,
+			TypedMetrics: typedMetrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Latency Measurement",
+				TechnicalError: strings.Join(problems, "; "),
+				CommandOutputs: []CommandOutput{baselineOutput, netemOutput, measuredOutput, recoveredOutput},
+				TroubleshootingHints: []string{
+					"A noisy baseline (high mdev in the ping output) hides the injected delay - rerun on a quieter cluster or inject a larger delay",
+					fmt.Sprintf("Check the qdisc was applied: kubectl exec -n %s %s -- tc qdisc show dev %s", t.namespace, clientPod, faultInterface),
+				},
+			},
+		}
+	}
+
+	details = append(details, fmt.Sprintf("✓ Measured +%.2fms for %.1fms injected (tolerance %.1fms) and recovered to %.2fms", increase, injected, tolerance, recovered))
+	return TestResult{
+		Success:      true,
+		Message:      fmt.Sprintf("Injected %.1fms latency measured as +%.2fms (baseline %.2fms)", injected, increase, baseline),
+		Details:      details,
+		Metrics:      metrics,
+		TypedMetrics: typedMetrics,
+	}
+}
+
+// pingLossPattern matches the summary line of ping, e.g. "100 packets transmitted, 91 received, 9% packet loss"
+var pingLossPattern = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+
+// measurePingLoss sends a burst of pings and returns the loss percentage, or -1 when the output has no summary
+func (t *Tester) measurePingLoss(ctx context.Context, podName, targetIP, description string) (float64, CommandOutput) {
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"ping", "-c", fmt.Sprint(faultLossPings), "-i", "0.05", "-W", "1", targetIP}, description)
+	match := pingLossPattern.FindStringSubmatch(output.Stdout)
+	if match == nil {
+		return -1, output
+	}
+	transmitted, _ := strconv.Atoi(match[1])
+	received, _ := strconv.Atoi(match[2])
+	if transmitted == 0 {
+		return -1, output
+	}
+	return 100 * float64(transmitted-received) / float64(transmitted), output
+}
+
+// serviceProbeStats summarizes a run of HTTP requests to a Service
+type serviceProbeStats struct {
+	Requests    int
+	Failed      int
+	P90         time.Duration
+	Latency     *LatencyMetrics
+	StatusCodes map[string]int
+}
+
+// measureServiceRequests sends sequential HTTP requests to the URL and returns the failure count and p90 duration
+func (t *Tester) measureServiceRequests(ctx context.Context, podName, url, description string) (serviceProbeStats, CommandOutput) {
+	script := fmt.Sprintf(`for i in $(seq %d); do curl -s -o /dev/null -w '%%{http_code} %%{time_total}\n' --max-time 3 %s; done`, faultLossRequests, url)
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"sh", "-c", script}, description)
+	stats := serviceProbeStats{Requests: faultLossRequests, StatusCodes: map[string]int{}}
+	var durations []time.Duration
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		durations = append(durations, time.Duration(seconds*float64(time.Second)))
+		stats.StatusCodes[fields[0]]++
+		if fields[0] != "200" {
+			stats.Failed++
+		}
+	}
+	// Requests without any output line never completed
+	missing := faultLossRequests - len(durations)
+	stats.Failed += missing
+	if missing > 0 {
+		stats.StatusCodes["000"] += missing
+	}
+	stats.P90 = latencyPercentile(durations, 90)
+	stats.Latency = latencyMetrics(durations)
+	return stats, output
+}
+
+// TestPacketLossInjection measures baseline pod-to-pod packet loss and Service request behaviour, drops the
+// configured share of the client pod's egress packets with tc netem, and reports the loss measured under injection
+// separately from the baseline, verifying that the measurement matches the injected rate. Service requests show how
+// TCP retransmissions turn the loss into latency and failures.
+func (t *Tester) TestPacketLossInjection(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	if config.InjectLoss <= 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Packet loss injection skipped - opt in with --inject-loss",
+			Details: details,
+		}
+	}
+	injected := math.Min(config.InjectLoss, 100)
+	clientPod := "netshoot-fault-loss-client"
+	targetPod := "netshoot-fault-loss-target"
+	deploymentName := "web-fault-loss"
+	serviceName := "web-fault-loss"
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, clientPod)
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, targetPod, metav1.DeleteOptions{})
+	}()
+
+	// Step 1: Pods, Service and baseline measurements
+	if _, err := t.createNginxDeployment(ctx, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create deployment %s: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNginxService(ctx, serviceName, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create service %s: %v", serviceName, err),
+			Details: details,
+		}
+	}
+	targetIP, placement, err := t.faultInjectionPods(ctx, clientPod, targetPod)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 60*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Client, target and service backends ready, %s", placement))
+	serviceURL := fmt.Sprintf("http://%s", serviceName)
+	baselineLoss, baselinePing := t.measurePingLoss(ctx, clientPod, targetIP, "Baseline pod-to-pod packet loss")
+	baselineService, baselineCurl := t.measureServiceRequests(ctx, clientPod, serviceURL, "Baseline service requests")
+	if baselineLoss < 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not measure baseline packet loss to %s", targetIP),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Baseline",
+				CommandOutputs: []CommandOutput{baselinePing},
+			},
+		}
+	}
+
+	// Step 2: Drop the configured share of the client's egress packets
+	netemOutput, err := t.applyNetem(ctx, clientPod, fmt.Sprintf("loss %.2f%%", injected))
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to inject packet loss with tc netem: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Fault Injection",
+				TechnicalError:       err.Error(),
+				CommandOutputs:       []CommandOutput{netemOutput},
+				TroubleshootingHints: netemFailureHints,
+			},
+		}
+	}
+	defer t.clearNetem(ctx, clientPod)
+	details = append(details, fmt.Sprintf("✓ Injected %.1f%% egress packet loss with tc netem on %s of %s", injected, faultInterface, clientPod))
+	faultLoss, faultPing := t.measurePingLoss(ctx, clientPod, targetIP, "Pod-to-pod packet loss under injection")
+	faultService, faultCurl := t.measureServiceRequests(ctx, clientPod, serviceURL, "Service requests under injection")
+	t.clearNetem(ctx, clientPod)
+
+	// Step 3: Separate the injected loss from the baseline
+	attributed := faultLoss - baselineLoss
+	// Three standard deviations of the binomial loss count, plus the baseline loss itself
+	tolerance := 300*math.Sqrt(injected/100*(1-injected/100)/faultLossPings) + baselineLoss + 1
+	metrics := map[string]float64{
+		"injected_loss_pct":       injected,
+		"baseline_loss_pct":       baselineLoss,
+		"measured_loss_pct":       faultLoss,
+		"attributed_loss_pct":     attributed,
+		"baseline_service_failed": float64(baselineService.Failed),
+		"injected_service_failed": float64(faultService.Failed),
+		"baseline_service_p90_ms": float64(baselineService.P90.Milliseconds()),
+		"injected_service_p90_ms": float64(faultService.P90.Milliseconds()),
+	}
+	// The typed loss, latency and status codes describe the phase under injection
+	_, faultPingLoss := pingMetrics(faultPing.Stdout)
+	typedMetrics := &TestMetrics{Latency: faultService.Latency, StatusCodes: faultService.StatusCodes, Loss: faultPingLoss}
+	details = append(details, fmt.Sprintf("  %-20s %12s %16s %12s", "PHASE", "PING LOSS", "HTTP FAILED", "HTTP P90"))
+	details = append(details, fmt.Sprintf("  %-20s %11.1f%% %16s %12v", "baseline", baselineLoss,
+		fmt.Sprintf("%d/%d", baselineService.Failed, baselineService.Requests), baselineService.P90.Round(time.Millisecond)))
+	details = append(details, fmt.Sprintf("  %-20s %11.1f%% %16s %12v", fmt.Sprintf("%.1f%% injected", injected), faultLoss,
+		fmt.Sprintf("%d/%d", faultService.Failed, faultService.Requests), faultService.P90.Round(time.Millisecond)))
+	details = append(details, fmt.Sprintf("ℹ️ Loss attributed to the injection: %.1f%% (measured %.1f%% minus baseline %.1f%%)", attributed, faultLoss, baselineLoss))
+	if baselineLoss > 0 {
+		details = append(details, fmt.Sprintf("⚠️ %.1f%% packet loss before any fault was injected - the baseline network is lossy", baselineLoss))
+	}
+	if faultService.Failed > baselineService.Failed {
+		details = append(details, fmt.Sprintf("ℹ️ %d service requests failed under injection - TCP could not retransmit within the 3s timeout", faultService.Failed-baselineService.Failed))
+	}
+
+	if faultLoss < 0 || math.Abs(attributed-injected) > tolerance {
+		problem := fmt.Sprintf("measured %.1f%% loss attributed to the injection vs %.1f%% injected (tolerance %.1f%%)", attributed, injected, tolerance)
+		if faultLoss < 0 {
+			problem = "ping produced no summary under injection"
+		}
+		details = append(details, fmt.Sprintf("✗ %s", problem))
+		return TestResult{
+			Success:      false,
+			Message:      fmt.Sprintf("Injected %.1f%% packet loss was not measured correctly", injected),
+			Details:      details,
+			Metrics:      metrics,
+			TypedMetrics: typedMetrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Packet Loss Measurement",
+				TechnicalError: problem,
+				CommandOutputs: []CommandOutput{baselinePing, baselineCurl, netemOutput, faultPing, faultCurl},
+				TroubleshootingHints: []string{
+					"A fluctuating baseline loss blurs the injected rate - rerun and compare the baseline of both runs",
+					fmt.Sprintf("Check the qdisc was applied: kubectl exec -n %s %s -- tc -s qdisc show dev %s", t.namespace, clientPod, faultInterface),
+				},
+			},
+		}
+	}
+
+	details = append(details, fmt.Sprintf("✓ Injected loss measured within %.1f%%", tolerance))
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Injected %.1f%% packet loss measured as %.1f%% above a %.1f%% baseline; %d/%d service requests failed", injected,
+			attributed, baselineLoss, faultService.Failed, faultService.Requests),
+		Details:      details,
+		Metrics:      metrics,
+		TypedMetrics: typedMetrics,
+	}
+}
@@ -0,0 +1,471 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// firewallConnectTimeout bounds each TCP connection attempt; a probe still pending after it counts as filtered
+	firewallConnectTimeout = 3
+	// firewallListenWindow is how long each node captures incoming datagrams on the UDP ports
+	firewallListenWindow = 10 * time.Second
+	// ciliumWireGuardPort is the UDP port Cilium's transparent encryption uses between nodes
+	ciliumWireGuardPort = 51871
+	// ciliumHealthPort is the TCP port cilium-health probes between nodes
+	ciliumHealthPort = 4240
+	// kubeProxyHealthPort is kube-proxy's healthz port, which load balancers probe for externalTrafficPolicy Local
+	kubeProxyHealthPort = 10256
+)
+
+// Reachability of a port from one node to another in the firewall port matrix
+const (
+	firewallOpen     = "open"     // TCP connection established
+	firewallClosed   = "closed"   // TCP connection refused: the firewall lets it through but nothing listens
+	firewallFiltered = "filtered" // TCP connection timed out: dropped on the way
+	firewallRejected = "rejected" // ICMP unreachable or prohibited: rejected on the way
+	firewallReceived = "received" // UDP datagrams arrived at the node
+	firewallDropped  = "dropped"  // UDP datagrams did not arrive
+	firewallError    = "error"
+)
+
+// firewallPort is a port the cluster needs open between nodes
+type firewallPort struct {
+	Name     string
+	Protocol string // "tcp" or "udp"
+	Port     int
+	// ControlPlaneOnly restricts the targets to control-plane nodes, PeersOnly also the sources
+	ControlPlaneOnly bool
+	PeersOnly        bool
+	Hint             string
+}
+
+// Label is how the port is shown in the matrix headers and failure messages
+func (p firewallPort) Label() string {
+	return fmt.Sprintf("%s %d (%s)", strings.ToUpper(p.Protocol), p.Port, p.Name)
+}
+
+// firewallNode is a node taking part in the port matrix
+type firewallNode struct {
+	overlayNode
+	ControlPlane bool
+}
+
+// isControlPlaneNode reports whether the node carries a control-plane or master role label
+func isControlPlaneNode(node corev1.Node) bool {
+	for key := range node.Labels {
+		if strings.Contains(key, "control-plane") || strings.Contains(key, "master") {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredFirewallPorts derives the ports the cluster needs open between nodes from its control plane, CNI and
+// kube-proxy; ports the cluster does not use are returned as notes
+func (t *Tester) requiredFirewallPorts(ctx context.Context, controlPlaneNodes int) ([]firewallPort, []string) {
+	var ports []firewallPort
+	var notes []string
+
+	if controlPlaneNodes > 0 {
+		ports = append(ports, firewallPort{Name: "API server", Protocol: "tcp", Port: 6443, ControlPlaneOnly: true,
+			Hint: "Allow TCP 6443 from every node to the control-plane nodes - kubelets, kube-proxy and the CNI agents talk to the API server on it"})
+		etcd, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "component=etcd"})
+		switch {
+		case err != nil || len(etcd.Items) == 0:
+			notes = append(notes, "No etcd static pods in kube-system (external or managed etcd) - etcd ports not checked")
+		case controlPlaneNodes < 2:
+			notes = append(notes, "Single control-plane node - etcd ports have no peer to be checked from")
+		default:
+			ports = append(ports,
+				firewallPort{Name: "etcd client", Protocol: "tcp", Port: 2379, ControlPlaneOnly: true, PeersOnly: true,
+					Hint: "Allow TCP 2379 between the control-plane nodes - the API servers reach etcd on it"},
+				firewallPort{Name: "etcd peer", Protocol: "tcp", Port: 2380, ControlPlaneOnly: true, PeersOnly: true,
+					Hint: "Allow TCP 2380 between the control-plane nodes - etcd members replicate on it and lose quorum without it"})
+		}
+	} else {
+		notes = append(notes, "No control-plane nodes visible (managed control plane) - API server and etcd ports not checked")
+	}
+
+	ports = append(ports, firewallPort{Name: "kubelet", Protocol: "tcp", Port: kubeletAPIPort,
+		Hint: fmt.Sprintf("Allow TCP %d to every node - the API server reaches kubelets on it for logs, exec, port-forward and metrics", kubeletAPIPort)})
+
+	if proxy := t.DetectKubeProxyMode(ctx); proxy.Mode != KubeProxyModeReplaced {
+		ports = append(ports, firewallPort{Name: "kube-proxy health", Protocol: "tcp", Port: kubeProxyHealthPort,
+			Hint: fmt.Sprintf("Allow TCP %d to every node - load balancer health checks for externalTrafficPolicy: Local use it", kubeProxyHealthPort)})
+	}
+
+	cni := t.detectCNI(ctx)
+	if cni.Name == CNICilium {
+		ports = append(ports, firewallPort{Name: "cilium-health", Protocol: "tcp", Port: ciliumHealthPort,
+			Hint: fmt.Sprintf("Allow TCP %d between all nodes - cilium-health reports nodes it cannot reach on it as unhealthy", ciliumHealthPort)})
+		if config, err := t.getCiliumConfig(ctx); err == nil && config["enable-wireguard"] == "true" {
+			ports = append(ports, firewallPort{Name: "WireGuard", Protocol: "udp", Port: ciliumWireGuardPort,
+				Hint: fmt.Sprintf("Allow UDP %d between all nodes - Cilium's WireGuard tunnels carry the encrypted pod traffic on it", ciliumWireGuardPort)})
+		} else {
+			notes = append(notes, "Cilium WireGuard encryption not enabled - UDP 51871 not checked")
+		}
+	}
+
+	if overlay, reason := t.detectOverlay(ctx, cni); overlay != nil {
+		ports = append(ports, firewallPort{Name: overlay.Protocol, Protocol: "udp", Port: overlay.Port,
+			Hint: fmt.Sprintf("Allow UDP %d between all nodes - the %s overlay carries cross-node pod traffic on it", overlay.Port, overlay.Protocol)})
+	} else {
+		notes = append(notes, fmt.Sprintf("No UDP overlay (%s) - VXLAN/Geneve port not checked", reason))
+	}
+
+	portRange, _ := t.serviceNodePortRange(ctx)
+	ports = append(ports, firewallPort{Name: "NodePort range", Protocol: "tcp", Port: portRange.First,
+		Hint: fmt.Sprintf("Allow TCP %s to every node - NodePort and LoadBalancer Services are served on it", portRange)})
+	return ports, notes
+}
+
+// firewallTargets returns the nodes a port is probed on from source, excluding source itself
+func firewallTargets(port firewallPort, source firewallNode, nodes []firewallNode) []firewallNode {
+	if port.PeersOnly && !source.ControlPlane {
+		return nil
+	}
+	var targets []firewallNode
+	for _, node := range nodes {
+		if node.Name == source.Name || (port.ControlPlaneOnly && !node.ControlPlane) {
+			continue
+		}
+		targets = append(targets, node)
+	}
+	return targets
+}
+
+// firewallConnectScript tries every TCP port on every target in parallel with bash's /dev/tcp and prints one
+// "<address> <port> <exit code> <error>" line per attempt; timeout exits with 124 (143 for busybox) when the
+// connection hangs
+func firewallConnectScript(probes map[string][]int) []string {
+	var lines []string
+	for address, ports := range probes {
+		for _, port := range ports {
+			lines = append(lines, fmt.Sprintf(`(r=$(timeout %d bash -c '</dev/tcp/%s/%d' 2>&1); c=$?; echo "%s %d $c" $r) &`,
+				firewallConnectTimeout, address, port, address, port))
+		}
+	}
+	sort.Strings(lines)
+	return []string{"sh", "-c", strings.Join(append(lines, "wait"), "\n")}
+}
+
+// parseFirewallConnect classifies the lines printed by firewallConnectScript, keyed by "<address>:<port>"
+func parseFirewallConnect(output string) map[string]string {
+	results := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		message := strings.Join(fields[3:], " ")
+		state := firewallError
+		switch {
+		case fields[2] == "0":
+			state = firewallOpen
+		case fields[2] == "124" || fields[2] == "143":
+			state = firewallFiltered
+		case strings.Contains(message, "refused"):
+			state = firewallClosed
+		case strings.Contains(message, "No route to host") || strings.Contains(message, "unreachable"):
+			state = firewallRejected
+		}
+		results[net.JoinHostPort(fields[0], fields[1])] = state
+	}
+	return results
+}
+
+// firewallBlocked reports whether a matrix cell means the firewall stops the traffic
+func firewallBlocked(state string) bool {
+	return state == firewallFiltered || state == firewallRejected || state == firewallDropped
+}
+
+// TestFirewallPorts checks that the ports the cluster needs between nodes - API server, etcd, kubelet, kube-proxy and
+// CNI health, the VXLAN/Geneve overlay, WireGuard and the NodePort range - get through host firewalls, security
+// groups and network ACLs. TCP ports are connected to from a hostNetwork pod on every node, so a refused connection
+// still shows the path is open; UDP ports are captured on the receiving node while the others send to them. The
+// result is a per-port matrix of node pairs with a remediation hint for every blocked port.
+func (t *Tester) TestFirewallPorts(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	// Step 1: Nodes and their roles
+	nodeList, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	var nodes []firewallNode
+	controlPlaneNodes := 0
+	for i, node := range nodeList.Items {
+		if isWindowsNode(node) {
+			details = append(details, fmt.Sprintf("ℹ️ Windows node %s skipped - the debug pods are Linux-only", node.Name))
+			continue
+		}
+		entry := firewallNode{overlayNode: overlayNode{Name: node.Name, Pod: fmt.Sprintf("firewall-debug-%d", i)}, ControlPlane: isControlPlaneNode(node)}
+		for _, address := range node.Status.Addresses {
+			entry.Addresses = append(entry.Addresses, address.Address)
+			if address.Type == corev1.NodeInternalIP && entry.Address == "" {
+				entry.Address = address.Address
+			}
+		}
+		if entry.Address == "" {
+			details = append(details, fmt.Sprintf("⚠️ Node %s has no InternalIP - skipped", node.Name))
+			continue
+		}
+		if entry.ControlPlane {
+			controlPlaneNodes++
+		}
+		nodes = append(nodes, entry)
+	}
+	if len(nodes) < 2 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Firewall port matrix skipped - needs at least two nodes with an InternalIP",
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ %d nodes, %d control-plane", len(nodes), controlPlaneNodes))
+
+	// Step 2: The ports this cluster needs
+	ports, notes := t.requiredFirewallPorts(ctx, controlPlaneNodes)
+	for _, note := range notes {
+		details = append(details, "ℹ️ "+note)
+	}
+	var labels []string
+	for _, port := range ports {
+		labels = append(labels, port.Label())
+	}
+	details = append(details, fmt.Sprintf("✓ Checking %s", strings.Join(labels, ", ")))
+
+	// Step 3: A privileged hostNetwork pod on every node
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, node := range nodes {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, node.Pod, metav1.DeleteOptions{})
+		}
+	}()
+	for _, node := range nodes {
+		if _, err := t.createPrivilegedDebugPod(ctx, node.Pod, node.Name); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create debug pod on %s: %v", node.Name, err),
+				Details: details,
+			}
+		}
+	}
+	var ready []firewallNode
+	for _, node := range nodes {
+		if err := t.waitForPodReady(ctx, node.Pod, 60*time.Second); err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Debug pod on %s not ready, node skipped: %v", node.Name, err))
+			continue
+		}
+		ready = append(ready, node)
+	}
+	if len(ready) < 2 {
+		return TestResult{
+			Success: false,
+			Message: "Debug pods became ready on fewer than two nodes",
+			Details: details,
+		}
+	}
+
+	// cells holds the state per "<port label>/<source>/<target>"
+	cells := map[string]string{}
+
+	// Step 4: TCP ports - every node connects to every target in parallel
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, source := range ready {
+		probes := map[string][]int{}
+		for _, port := range ports {
+			if port.Protocol != "tcp" {
+				continue
+			}
+			for _, target := range firewallTargets(port, source, ready) {
+				probes[target.Address] = append(probes[target.Address], port.Port)
+			}
+		}
+		if len(probes) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(source firewallNode, probes map[string][]int) {
+			defer wg.Done()
+			output, _ := t.execInPodWithOutput(ctx, t.namespace, source.Pod, "netshoot", firewallConnectScript(probes),
+				fmt.Sprintf("TCP connections from %s", source.Name))
+			results := parseFirewallConnect(output.Stdout)
+			mu.Lock()
+			defer mu.Unlock()
+			blocked := false
+			for _, port := range ports {
+				if port.Protocol != "tcp" {
+					continue
+				}
+				for _, target := range firewallTargets(port, source, ready) {
+					state, ok := results[net.JoinHostPort(target.Address, strconv.Itoa(port.Port))]
+					if !ok {
+						state = firewallError
+					}
+					cells[port.Label()+"/"+source.Name+"/"+target.Name] = state
+					blocked = blocked || firewallBlocked(state)
+				}
+			}
+			if blocked {
+				commandOutputs = append(commandOutputs, output)
+			}
+		}(source, probes)
+	}
+	wg.Wait()
+
+	// Step 5: UDP ports - every node captures incoming datagrams while all others send to it
+	var udpPorts []firewallPort
+	for _, port := range ports {
+		if port.Protocol == "udp" {
+			udpPorts = append(udpPorts, port)
+		}
+	}
+	if len(udpPorts) > 0 {
+		var filters []string
+		for _, port := range udpPorts {
+			filters = append(filters, fmt.Sprintf("dst port %d", port.Port))
+		}
+		captures := make([]CommandOutput, len(ready))
+		var captureGroup sync.WaitGroup
+		for i, node := range ready {
+			captureGroup.Add(1)
+			go func(i int, node firewallNode) {
+				defer captureGroup.Done()
+				captures[i], _ = t.execInPodWithOutput(ctx, t.namespace, node.Pod, "netshoot",
+					[]string{"sh", "-c", fmt.Sprintf("timeout %d tcpdump -i any -nn -l 'udp and (%s)' 2>/dev/null", int(firewallListenWindow.Seconds()), strings.Join(filters, " or "))},
+					fmt.Sprintf("Datagrams received on %s", node.Name))
+			}(i, node)
+		}
+		// Give tcpdump time to attach, then send from every node to every other node
+		sleepContext(ctx, 2*time.Second)
+		var senders sync.WaitGroup
+		for _, node := range ready {
+			var targets []string
+			for _, peer := range ready {
+				if peer.Name != node.Name {
+					targets = append(targets, peer.Address)
+				}
+			}
+			for _, port := range udpPorts {
+				senders.Add(1)
+				go func(node firewallNode, port int) {
+					defer senders.Done()
+					t.execInPod(ctx, t.namespace, node.Pod, "netshoot", overlaySendCommand(targets, port))
+				}(node, port.Port)
+			}
+		}
+		senders.Wait()
+		captureGroup.Wait()
+
+		for i, node := range ready {
+			blocked := false
+			for _, port := range udpPorts {
+				received := overlayReceivedFrom(captures[i].Stdout, node.overlayNode, port.Port)
+				for _, peer := range ready {
+					if peer.Name == node.Name {
+						continue
+					}
+					state := firewallDropped
+					for _, address := range peer.Addresses {
+						if received[address] {
+							state = firewallReceived
+						}
+					}
+					cells[port.Label()+"/"+peer.Name+"/"+node.Name] = state
+					blocked = blocked || firewallBlocked(state)
+				}
+			}
+			if blocked {
+				commandOutputs = append(commandOutputs, captures[i])
+			}
+		}
+	}
+
+	// Step 6: One matrix per port, rows are source nodes
+	width := 10
+	for _, node := range ready {
+		width = max(width, len(node.Name)+2)
+	}
+	metrics := map[string]float64{"ports": float64(len(ports))}
+	var problems []string
+	var hints []string
+	pairs, blockedPairs := 0, 0
+	for _, port := range ports {
+		var blocked []string
+		header := fmt.Sprintf("  %-*s", width, "FROM \\ TO")
+		for _, target := range ready {
+			header += fmt.Sprintf(" %-*s", width, target.Name)
+		}
+		details = append(details, fmt.Sprintf("  %s per node pair:", port.Label()), header)
+		for _, source := range ready {
+			row := fmt.Sprintf("  %-*s", width, source.Name)
+			for _, target := range ready {
+				state, ok := cells[port.Label()+"/"+source.Name+"/"+target.Name]
+				if !ok {
+					state = "-"
+				} else {
+					pairs++
+				}
+				if firewallBlocked(state) {
+					blocked = append(blocked, fmt.Sprintf("%s -> %s", source.Name, target.Name))
+				}
+				row += fmt.Sprintf(" %-*s", width, state)
+			}
+			details = append(details, row)
+		}
+		metrics[fmt.Sprintf("blocked_pairs.%s_%d", port.Protocol, port.Port)] = float64(len(blocked))
+		if len(blocked) > 0 {
+			blockedPairs += len(blocked)
+			problems = append(problems, fmt.Sprintf("%s blocked for %s", port.Label(), strings.Join(blocked, ", ")))
+			hints = append(hints, port.Hint)
+			details = append(details, fmt.Sprintf("✗ %s blocked for %d node pair(s)", port.Label(), len(blocked)))
+		}
+	}
+	details = append(details, "  open/received: reachable, closed: reachable but nothing listens, filtered/dropped: no answer, rejected: ICMP unreachable, '-': not checked")
+	metrics["pairs"] = float64(pairs)
+	metrics["blocked_pairs"] = float64(blockedPairs)
+
+	if len(problems) > 0 {
+		hints = append(hints,
+			"Check host firewalls on both ends (iptables -S, nft list ruleset, firewalld, ufw) as well as cloud security groups and network ACLs",
+			"A filtered port between nodes of different subnets or zones often means a rule only allows the local subnet")
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Required ports blocked between nodes for %d of %d checks: %s", blockedPairs, pairs, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Firewall Port Matrix",
+				TechnicalError:       strings.Join(problems, "; "),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d required ports open between %d nodes (%d checks)", len(ports), len(ready), pairs),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,150 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// sharedBackendName is the nginx deployment the service tests of a run share
+	sharedBackendName = "web-shared"
+	// sharedClientPrefix names the shared netshoot clients; clients pinned to a node get the node name appended
+	sharedClientPrefix = "netshoot-shared"
+)
+
+// fixtureManager tracks the nginx backend and netshoot clients shared by the tests of one run. Tests that only read
+// from a backend or exec in a client reuse them instead of creating their own; ReleaseFixtures deletes them when the
+// run ends.
+type fixtureManager struct {
+	mu          sync.Mutex
+	enabled     bool
+	deployments map[string]bool
+	pods        map[string]bool
+	observer    *NetworkObserver // observer of the running test, told about fixtures it did not see created
+}
+
+// EnableSharedFixtures makes the service and DNS tests share one nginx backend and their netshoot clients for the
+// rest of the run
+func (t *Tester) EnableSharedFixtures() {
+	t.fixtures.mu.Lock()
+	defer t.fixtures.mu.Unlock()
+	t.fixtures.enabled = true
+	t.fixtures.deployments = map[string]bool{}
+	t.fixtures.pods = map[string]bool{}
+}
+
+// nginxBackend returns the nginx deployment a test sends requests to: the shared backend, created on first use, or
+// a deployment of its own named name when sharing is off. reused is true when the deployment existed already.
+func (t *Tester) nginxBackend(ctx context.Context, name string) (string, bool, error) {
+	t.fixtures.mu.Lock()
+	defer t.fixtures.mu.Unlock()
+	if !t.fixtures.enabled {
+		_, err := t.createNginxDeployment(ctx, name)
+		return name, false, err
+	}
+
+	if t.fixtures.deployments[sharedBackendName] {
+		_, err := t.clientset.AppsV1().Deployments(t.namespace).Get(ctx, sharedBackendName, metav1.GetOptions{})
+		if err == nil {
+			t.observeFixture(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", sharedBackendName)})
+			return sharedBackendName, true, nil
+		}
+		delete(t.fixtures.deployments, sharedBackendName)
+	}
+	// A backend left in a kept namespace by an earlier run is reused as well
+	_, err := t.createNginxDeployment(ctx, sharedBackendName)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return sharedBackendName, false, err
+	}
+	t.fixtures.deployments[sharedBackendName] = true
+	return sharedBackendName, apierrors.IsAlreadyExists(err), nil
+}
+
+// netshootClient returns the netshoot pod a test runs its probes from, on nodeName or on any node when it is empty:
+// the shared client for that placement, created on first use, or a pod of its own named name when sharing is off.
+// A shared client that has exited or is being deleted is not reused; the test gets a pod of its own instead.
+func (t *Tester) netshootClient(ctx context.Context, name, nodeName string) (string, bool, error) {
+	t.fixtures.mu.Lock()
+	defer t.fixtures.mu.Unlock()
+	if !t.fixtures.enabled {
+		_, err := t.createNetshootPod(ctx, name, nodeName)
+		return name, false, err
+	}
+
+	sharedName := sharedClientPrefix
+	if nodeName != "" {
+		sharedName = fmt.Sprintf("%s-%s", sharedClientPrefix, nodeName)
+		if len(sharedName) > 63 {
+			sharedName = strings.TrimRight(sharedName[:63], "-.")
+		}
+	}
+	pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, sharedName, metav1.GetOptions{})
+	switch {
+	case err == nil && pod.DeletionTimestamp == nil && pod.Status.Phase != corev1.PodFailed && pod.Status.Phase != corev1.PodSucceeded:
+		t.fixtures.pods[sharedName] = true
+		t.observeFixture(ctx, metav1.ListOptions{FieldSelector: fmt.Sprintf("metadata.name=%s", sharedName)})
+		return sharedName, true, nil
+	case err == nil:
+		_, err := t.createNetshootPod(ctx, name, nodeName)
+		return name, false, err
+	case !apierrors.IsNotFound(err):
+		return sharedName, false, err
+	}
+	if _, err := t.createNetshootPod(ctx, sharedName, nodeName); err != nil {
+		return sharedName, false, err
+	}
+	t.fixtures.pods[sharedName] = true
+	return sharedName, false, nil
+}
+
+// observeFixture records reused fixture pods in the network context of the running test, whose watch started after
+// they were created
+func (t *Tester) observeFixture(ctx context.Context, options metav1.ListOptions) {
+	if t.fixtures.observer == nil {
+		return
+	}
+	pods, err := t.clientset.CoreV1().Pods(t.namespace).List(ctx, options)
+	if err != nil {
+		return
+	}
+	for i := range pods.Items {
+		t.fixtures.observer.recordPod(&pods.Items[i])
+	}
+}
+
+// isSharedFixture reports whether a deployment or pod belongs to the run rather than to one test
+func (t *Tester) isSharedFixture(name string) bool {
+	t.fixtures.mu.Lock()
+	defer t.fixtures.mu.Unlock()
+	return t.fixtures.deployments[name] || t.fixtures.pods[name]
+}
+
+// fixtureAction is the verb of the detail line for a fixture the test created or reused
+func fixtureAction(reused bool) string {
+	if reused {
+		return "Reusing shared"
+	}
+	return "Created"
+}
+
+// ReleaseFixtures deletes the shared fixtures at the end of a run
+func (t *Tester) ReleaseFixtures(ctx context.Context) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	t.fixtures.mu.Lock()
+	defer t.fixtures.mu.Unlock()
+	for name := range t.fixtures.deployments {
+		t.clientset.AppsV1().Deployments(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	for name := range t.fixtures.pods {
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	}
+	t.fixtures.deployments = map[string]bool{}
+	t.fixtures.pods = map[string]bool{}
+}
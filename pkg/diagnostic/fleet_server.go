@@ -0,0 +1,417 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fleetUploadLimit bounds the body of one upload; reports with command outputs of every test stay well below it
+const fleetUploadLimit = 32 << 20
+
+// FleetStore keeps the reports uploaded by many clusters as files under Dir, one directory per cluster, and in
+// memory for the aggregated views
+type FleetStore struct {
+	Dir     string
+	Skipped []string // stored reports that could not be loaded, with the reason
+
+	mu      sync.RWMutex
+	sources []MergeSource
+}
+
+// NewFleetStore opens the store in dir, loading the reports stored by earlier runs of the server
+func NewFleetStore(dir string) (*FleetStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	store := &FleetStore{Dir: dir}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		// One unreadable report must not keep the server from starting
+		report, err := LoadReport(path)
+		if err != nil {
+			store.Skipped = append(store.Skipped, err.Error())
+			return nil
+		}
+		store.sources = append(store.sources, MergeSource{File: path, Report: report})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored reports: %v", err)
+	}
+	return store, nil
+}
+
+// fleetFileName encodes a cluster name or run ID as a file name. The encoding is reversible, so two names never
+// share a file ("prod/eu" and "prod_eu" stay apart), and a leading dot is escaped so "." and ".." cannot leave the
+// data directory or hide the file.
+func fleetFileName(name string) string {
+	escaped := url.PathEscape(name)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+	return escaped
+}
+
+// Add stores the report of a cluster; the cluster is written into the report so it survives a restart
+func (s *FleetStore) Add(report *DiagnosticReportJSON, cluster string) (string, error) {
+	source := MergeSource{Cluster: cluster, Report: report}
+	report.ExecutionInfo.Cluster = source.cluster()
+	if report.ExecutionInfo.Cluster == "" {
+		return "", fmt.Errorf("the report names no cluster - set execution_info.cluster or pass ?cluster=<name>")
+	}
+	name := fleetFileName(report.ExecutionInfo.RunID)
+	if name == "" {
+		name = time.Now().UTC().Format("20060102-150405.000000000")
+	}
+	dir := filepath.Join(s.Dir, fleetFileName(report.ExecutionInfo.Cluster))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(dir, name+".json")
+	// A report uploaded again replaces the stored one, but never the run of another cluster
+	stored := -1
+	for i := range s.sources {
+		if s.sources[i].File != path {
+			continue
+		}
+		existing := s.sources[i].Report.ExecutionInfo
+		if existing.Cluster != report.ExecutionInfo.Cluster || existing.RunID != report.ExecutionInfo.RunID {
+			return "", fmt.Errorf("%s already holds run %q of cluster %q", path, existing.RunID, existing.Cluster)
+		}
+		stored = i
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", err
+	}
+	source.File = path
+	if stored >= 0 {
+		s.sources[stored] = source
+	} else {
+		s.sources = append(s.sources, source)
+	}
+	return path, nil
+}
+
+// Sources returns the stored reports of runs started within since of now, all of them when since is 0
+func (s *FleetStore) Sources(since time.Duration) []MergeSource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var sources []MergeSource
+	for _, source := range s.sources {
+		if since > 0 {
+			start, err := time.Parse(time.RFC3339, source.Report.ExecutionInfo.Timestamp)
+			if err != nil || time.Since(start) > since {
+				continue
+			}
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// FleetTestCluster is the failure rate of one test on one cluster
+type FleetTestCluster struct {
+	TestName       string  `json:"test_name"`
+	Cluster        string  `json:"cluster"`
+	Runs           int     `json:"runs"` // runs the test ran in, skips excluded
+	Failed         int     `json:"failed"`
+	FailureRatePct float64 `json:"failure_rate_pct"`
+}
+
+// FleetMatrixRow is the latest run of a cluster with the status of every test in it
+type FleetMatrixRow struct {
+	Cluster       string            `json:"cluster"`
+	RunID         string            `json:"run_id,omitempty"`
+	Timestamp     string            `json:"timestamp"`
+	OverallStatus string            `json:"overall_status"`
+	Statuses      map[string]string `json:"statuses"` // test name -> status
+}
+
+// FleetMatrix is the latest status of every test on every cluster
+type FleetMatrix struct {
+	Tests    []string         `json:"tests"`
+	Clusters []FleetMatrixRow `json:"clusters"`
+}
+
+// fleetTestClusters computes the failure rate of every test on every cluster, highest first
+func fleetTestClusters(sources []MergeSource) []FleetTestCluster {
+	rates := map[[2]string]*FleetTestCluster{}
+	for _, source := range sources {
+		cluster := source.cluster()
+		for _, test := range source.Report.Tests {
+			if test.Status == StatusSkipped {
+				continue
+			}
+			key := [2]string{test.TestName, cluster}
+			if rates[key] == nil {
+				rates[key] = &FleetTestCluster{TestName: test.TestName, Cluster: cluster}
+			}
+			rates[key].Runs++
+			if test.Status == StatusFailed {
+				rates[key].Failed++
+			}
+		}
+	}
+	list := make([]FleetTestCluster, 0, len(rates))
+	for _, rate := range rates {
+		rate.FailureRatePct = float64(rate.Failed) / float64(rate.Runs) * 100
+		list = append(list, *rate)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].FailureRatePct != list[j].FailureRatePct {
+			return list[i].FailureRatePct > list[j].FailureRatePct
+		}
+		if list[i].TestName != list[j].TestName {
+			return list[i].TestName < list[j].TestName
+		}
+		return list[i].Cluster < list[j].Cluster
+	})
+	return list
+}
+
+// fleetWorstClusters orders the per-cluster stats by pass rate, lowest first
+func fleetWorstClusters(merged MergedReport) []MergedStats {
+	clusters := append([]MergedStats{}, merged.Clusters...)
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].PassRatePct < clusters[j].PassRatePct })
+	return clusters
+}
+
+// fleetMatrix builds the status matrix from the latest run of each cluster
+func fleetMatrix(sources []MergeSource) FleetMatrix {
+	latest := map[string]MergeSource{}
+	latestStart := map[string]time.Time{}
+	for _, source := range sources {
+		cluster := source.cluster()
+		start, _ := time.Parse(time.RFC3339, source.Report.ExecutionInfo.Timestamp)
+		if _, ok := latest[cluster]; !ok || start.After(latestStart[cluster]) {
+			latest[cluster], latestStart[cluster] = source, start
+		}
+	}
+	matrix := FleetMatrix{Tests: []string{}, Clusters: []FleetMatrixRow{}}
+	seen := map[string]bool{}
+	for cluster, source := range latest {
+		row := FleetMatrixRow{
+			Cluster:       cluster,
+			RunID:         source.Report.ExecutionInfo.RunID,
+			Timestamp:     source.Report.ExecutionInfo.Timestamp,
+			OverallStatus: source.Report.Summary.OverallStatus,
+			Statuses:      map[string]string{},
+		}
+		for _, test := range source.Report.Tests {
+			row.Statuses[test.TestName] = test.Status
+			if !seen[test.TestName] {
+				seen[test.TestName] = true
+				matrix.Tests = append(matrix.Tests, test.TestName)
+			}
+		}
+		matrix.Clusters = append(matrix.Clusters, row)
+	}
+	sort.Strings(matrix.Tests)
+	sort.Slice(matrix.Clusters, func(i, j int) bool { return matrix.Clusters[i].Cluster < matrix.Clusters[j].Cluster })
+	return matrix
+}
+
+// FleetServer receives reports from many clusters and serves aggregated views of them:
+//
+//	POST /api/v1/reports          a report, or the events of --report-url (run_finished carries the report);
+//	                              ?cluster=<name> names the cluster when the report does not
+//	GET  /api/v1/summary          all runs merged like report merge
+//	GET  /api/v1/clusters         per-cluster stats, lowest pass rate first
+//	GET  /api/v1/tests            failure rate of every test on every cluster, highest first
+//	GET  /api/v1/matrix           status of every test in the latest run of every cluster
+//	GET  /                        HTML dashboard of the above
+//
+// The GET endpoints take ?days=<n> to only count runs of the last n days.
+type FleetServer struct {
+	Store *FleetStore
+	Log   func(format string, args ...interface{}) // optional
+}
+
+// Handler returns the HTTP handler of the server
+func (s *FleetServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/reports", s.handleUpload)
+	mux.HandleFunc("/api/v1/summary", s.handleView(func(sources []MergeSource) interface{} { return MergeReports(sources) }))
+	mux.HandleFunc("/api/v1/clusters", s.handleView(func(sources []MergeSource) interface{} {
+		return fleetWorstClusters(MergeReports(sources))
+	}))
+	mux.HandleFunc("/api/v1/tests", s.handleView(func(sources []MergeSource) interface{} { return fleetTestClusters(sources) }))
+	mux.HandleFunc("/api/v1/matrix", s.handleView(func(sources []MergeSource) interface{} { return fleetMatrix(sources) }))
+	mux.HandleFunc("/", s.handleDashboard)
+	return mux
+}
+
+func (s *FleetServer) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log(format, args...)
+	}
+}
+
+// handleUpload stores a posted report; progress events of --report-url other than run_finished are acknowledged
+// and dropped
+func (s *FleetServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a JSON report", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, fleetUploadLimit))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read the report: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var event struct {
+		Type   string          `json:"type"`
+		Report json.RawMessage `json:"report"`
+	}
+	if err := json.Unmarshal(body, &event); err == nil && event.Type != "" {
+		if event.Type != "run_finished" || len(event.Report) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		body = event.Report
+	}
+	report, err := ParseReport(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path, err := s.Store.Add(report, r.URL.Query().Get("cluster"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logf("Stored report of %s (%s) in %s", report.ExecutionInfo.Cluster, report.Summary.OverallStatus, path)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// fleetSince reads the ?days= window of a request
+func fleetSince(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("days")
+	if value == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("days must be a positive number, got %q", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// handleView serves a JSON view of the stored reports
+func (s *FleetServer) handleView(view func([]MergeSource) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		since, err := fleetSince(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(view(s.Store.Sources(since)))
+	}
+}
+
+// fleetDashboardTemplate shows the worst clusters, the latest status matrix and the least reliable tests
+var fleetDashboardTemplate = template.Must(template.New("fleet").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"status": func(row FleetMatrixRow, test string) string {
+		if status, ok := row.Statuses[test]; ok {
+			return status
+		}
+		return "-"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>k8s-diagnostic fleet</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.passed { color: #1a7f37; }
+.warning { color: #9a6700; }
+.failed { color: #cf222e; }
+.skipped { color: #6e7781; }
+</style>
+</head>
+<body>
+<h1>k8s-diagnostic fleet</h1>
+<p>{{.Merged.Summary.Runs}} runs of {{len .Merged.Clusters}} clusters{{if .Merged.FirstRun}} from {{.Merged.FirstRun}} to {{.Merged.LastRun}}{{end}}: {{printf "%.1f" .Merged.Summary.PassRatePct}}% of the tests that ran passed</p>
+
+<h2>Clusters, lowest pass rate first</h2>
+<table>
+<tr><th>Cluster</th><th>Runs</th><th>Failed runs</th><th>Pass rate</th><th>Failures per test</th></tr>
+{{- range .Clusters}}
+<tr><td>{{.Name}}</td><td>{{.Runs}}</td><td>{{.RunsFailed}}</td><td>{{printf "%.1f" .PassRatePct}}%</td><td>{{range $test, $count := .FailedTests}}{{$test}}: {{$count}}<br>{{end}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Latest run per cluster</h2>
+<table>
+<tr><th>Cluster</th><th>Run</th>{{range .Matrix.Tests}}<th>{{.}}</th>{{end}}</tr>
+{{- $tests := .Matrix.Tests}}
+{{- range $row := .Matrix.Clusters}}
+<tr><td>{{$row.Cluster}}</td><td class="{{lower $row.OverallStatus}}">{{$row.Timestamp}}</td>{{range $tests}}{{$status := status $row .}}<td class="{{lower $status}}">{{$status}}</td>{{end}}</tr>
+{{- end}}
+</table>
+
+<h2>Failing tests per cluster</h2>
+<table>
+<tr><th>Test</th><th>Cluster</th><th>Failed</th><th>Failure rate</th></tr>
+{{- range .Tests}}
+{{- if .Failed}}
+<tr><td>{{.TestName}}</td><td>{{.Cluster}}</td><td>{{.Failed}} of {{.Runs}}</td><td>{{printf "%.1f" .FailureRatePct}}%</td></tr>
+{{- end}}
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// handleDashboard renders the HTML dashboard
+func (s *FleetServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	since, err := fleetSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sources := s.Store.Sources(since)
+	merged := MergeReports(sources)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = fleetDashboardTemplate.Execute(w, struct {
+		Merged   MergedReport
+		Clusters []MergedStats
+		Matrix   FleetMatrix
+		Tests    []FleetTestCluster
+	}{merged, fleetWorstClusters(merged), fleetMatrix(sources), fleetTestClusters(sources)})
+	if err != nil {
+		s.logf("Failed to render the fleet dashboard: %v", err)
+	}
+}
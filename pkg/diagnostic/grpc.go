@@ -0,0 +1,218 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// grpcEchoImage serves hello.HelloService with server reflection on plaintext port 9000
+	grpcEchoImage = "moul/grpcbin"
+	grpcEchoPort  = 9000
+	// grpcTestHost is the virtual host used for ingress and gateway routes
+	grpcTestHost = "grpc.k8s-diagnostic.test"
+)
+
+// grpcProbe records a single grpcurl call against one path to the echo server
+type grpcProbe struct {
+	Path    string
+	Output  CommandOutput
+	Success bool
+}
+
+// runGRPCProbe calls the echo service through the given target, optionally overriding the :authority header
+func (t *Tester) runGRPCProbe(ctx context.Context, podName, path, target, authority string) grpcProbe {
+	args := []string{"grpcurl", "-plaintext", "-max-time", "5"}
+	if authority != "" {
+		args = append(args, "-authority", authority)
+	}
+	args = append(args, "-d", `{"greeting":"k8s-diagnostic"}`, target, "hello.HelloService/SayHello")
+
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", args,
+		fmt.Sprintf("gRPC unary call via %s", path))
+
+	return grpcProbe{
+		Path:    path,
+		Output:  output,
+		Success: err == nil && strings.Contains(output.Stdout, "hello k8s-diagnostic"),
+	}
+}
+
+// TestGRPCConnectivity runs a gRPC echo server and validates unary calls over ClusterIP and through Ingress/Gateway when present
+func (t *Tester) TestGRPCConnectivity(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	deploymentName := "grpc-echo"
+	serviceName := "grpc-echo"
+	ingressName := "grpc-echo"
+	routeName := "grpc-echo"
+	testPodName := "netshoot-grpc-test"
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+		t.cleanupIngress(ctx, ingressName)
+		t.dynamicClient.Resource(grpcRouteGVR).Namespace(t.namespace).Delete(ctx, routeName, metav1.DeleteOptions{})
+	}
+
+	// Step 1: Deploy the gRPC echo server
+	if _, err := t.createDeploymentWithImage(ctx, deploymentName, grpcEchoImage, nil, grpcEchoPort); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create gRPC echo deployment: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ gRPC echo deployment '%s' (%s) is ready", deploymentName, grpcEchoImage))
+
+	appProtocol := "kubernetes.io/h2c"
+	_, err := t.createServiceWithPorts(ctx, serviceName, deploymentName, []corev1.ServicePort{
+		{
+			Name:        "grpc",
+			Port:        grpcEchoPort,
+			TargetPort:  intstr.FromInt(grpcEchoPort),
+			Protocol:    corev1.ProtocolTCP,
+			AppProtocol: &appProtocol,
+		},
+	})
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create gRPC service: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created gRPC service '%s' on port %d (appProtocol %s)", serviceName, grpcEchoPort, appProtocol))
+
+	// Step 2: Client pod with grpcurl
+	if _, err := t.createNetshootPod(ctx, testPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForPodReady(ctx, testPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Test pod %s did not become ready: %v", testPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Test pod '%s' is ready", testPodName))
+
+	// Step 3: ClusterIP path, including server reflection
+	serviceTarget := fmt.Sprintf("%s:%d", serviceName, grpcEchoPort)
+	listOutput, listErr := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot",
+		[]string{"grpcurl", "-plaintext", "-max-time", "5", serviceTarget, "list"}, "gRPC server reflection via ClusterIP")
+	commandOutputs = append(commandOutputs, listOutput)
+	if listErr == nil {
+		details = append(details, fmt.Sprintf("✓ gRPC reflection lists services: %s", strings.Join(strings.Fields(listOutput.Stdout), ", ")))
+	} else {
+		details = append(details, fmt.Sprintf("✗ gRPC reflection failed: %s", strings.TrimSpace(listOutput.Stderr)))
+	}
+
+	probes := []grpcProbe{t.runGRPCProbe(ctx, testPodName, "ClusterIP", serviceTarget, "")}
+
+	// Step 4: Ingress path when an ingress controller is installed
+	if class, err := t.detectIngressClass(ctx); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Ingress path skipped: %v", err))
+	} else {
+		annotations := map[string]string{
+			"nginx.ingress.kubernetes.io/backend-protocol": "GRPC",
+		}
+		if _, err := t.createIngressForService(ctx, ingressName, class.Name, grpcTestHost, serviceName, grpcEchoPort, annotations); err != nil {
+			details = append(details, fmt.Sprintf("✗ Failed to create gRPC Ingress: %v", err))
+		} else if address, err := t.waitForIngressAddress(ctx, ingressName, 60*time.Second); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Ingress path skipped: %v", err))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Ingress '%s' (class %s) published address %s", ingressName, class.Name, address))
+			probes = append(probes, t.runGRPCProbe(ctx, testPodName, fmt.Sprintf("Ingress (%s)", class.Name),
+				fmt.Sprintf("%s:80", httpTargetForIP(address)), grpcTestHost))
+		}
+	}
+
+	// Step 5: Gateway API path when a Gateway is programmed
+	if gateway, err := t.detectGateway(ctx); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: %v", err))
+	} else {
+		backends := []interface{}{
+			map[string]interface{}{"name": serviceName, "port": int64(grpcEchoPort)},
+		}
+		if err := t.createGatewayRoute(ctx, grpcRouteGVR, "GRPCRoute", routeName, grpcTestHost, gateway, backends); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: could not create GRPCRoute: %v", err))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Attached GRPCRoute '%s' to Gateway %s/%s listener %s", routeName, gateway.Namespace, gateway.Name, gateway.Listener))
+			// Give the gateway controller a moment to program the route
+			sleepContext(ctx, 5*time.Second)
+			probes = append(probes, t.runGRPCProbe(ctx, testPodName, fmt.Sprintf("Gateway (%s/%s)", gateway.Namespace, gateway.Name),
+				fmt.Sprintf("%s:%d", httpTargetForIP(gateway.Address), gateway.Port), grpcTestHost))
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up gRPC test resources")
+
+	var failedPaths []string
+	for _, probe := range probes {
+		commandOutputs = append(commandOutputs, probe.Output)
+		if probe.Success {
+			details = append(details, fmt.Sprintf("✓ gRPC SayHello via %s succeeded (%s)", probe.Path, probe.Output.Duration))
+		} else {
+			failedPaths = append(failedPaths, probe.Path)
+			reason := strings.TrimSpace(probe.Output.Stderr)
+			if reason == "" {
+				reason = strings.TrimSpace(probe.Output.Stdout)
+			}
+			details = append(details, fmt.Sprintf("✗ gRPC SayHello via %s failed: %s", probe.Path, reason))
+		}
+		details = append(details, fmt.Sprintf("  %s", probe.Output.Command))
+	}
+
+	if len(failedPaths) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("gRPC connectivity failed via %s", strings.Join(failedPaths, ", ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "gRPC Call",
+				TechnicalError: fmt.Sprintf("%d of %d gRPC paths failed", len(failedPaths), len(probes)),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"gRPC requires HTTP/2 end to end; check that proxies in the path do not downgrade to HTTP/1.1",
+					"For ingress-nginx, gRPC backends need the nginx.ingress.kubernetes.io/backend-protocol: GRPC annotation",
+					"L7-aware CNIs or meshes may need the service port named 'grpc' or appProtocol set to detect the protocol",
+					"Long-lived gRPC connections are not rebalanced by kube-proxy; a client-side load balancer may be needed",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("gRPC connectivity test passed via %d path(s)", len(probes)),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
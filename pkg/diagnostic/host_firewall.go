@@ -0,0 +1,358 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	hostFirewallPolicyFile = "cilium-policies/12-host-firewall/host-deny-test-port-policy.yaml"
+	hostFirewallNodeLabel  = "k8s-diagnostic/host-firewall"
+	hostFirewallTestPort   = 39999
+	// hostFirewallWatchdog removes the host policy even if the test is interrupted
+	hostFirewallWatchdog = 60 * time.Second
+)
+
+// defaultHostFirewallAllowedPorts are node services that must stay reachable while the host policy is applied
+var defaultHostFirewallAllowedPorts = []int{10250}
+
+// labelNode sets (or with an empty value removes) a label on a node
+func (t *Tester) labelNode(ctx context.Context, nodeName, key, value string) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, key, value)
+	if value == "" {
+		patch = fmt.Sprintf(`{"metadata":{"labels":{%q:null}}}`, key)
+	}
+	_, err := t.clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// createHostPortListenerPod runs a TCP listener on the node's network namespace, pinned to nodeName
+func (t *Tester) createHostPortListenerPod(ctx context.Context, name, nodeName string, port int) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "host-firewall-listener",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:    nodeName,
+			HostNetwork: true,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"socat",
+						fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", port),
+						"SYSTEM:echo ok",
+					},
+				},
+			},
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// probeNodePort checks whether a TCP connection from the client pod to ip:port can be established
+func (t *Tester) probeNodePort(ctx context.Context, clientPodName, ip string, port int) (bool, CommandOutput) {
+	output, err := t.execInPodWithOutput(ctx, t.namespace, clientPodName, "netshoot",
+		[]string{"nc", "-z", "-w", "3", ip, fmt.Sprintf("%d", port)},
+		fmt.Sprintf("TCP connect to node port %d", port))
+	return err == nil, output
+}
+
+// TestHostFirewall tests Cilium host policies with the default (disabled) configuration
+func (t *Tester) TestHostFirewall(ctx context.Context) TestResult {
+	return t.TestHostFirewallWithConfig(ctx, TestConfig{})
+}
+
+// TestHostFirewallWithConfig applies a host policy blocking a test port on one node and verifies that the port is
+// blocked while allowed node services stay reachable; opt-in because a faulty host policy can lock out a node
+func (t *Tester) TestHostFirewallWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	if !config.HostFirewall {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Host firewall test skipped - opt in with --host-firewall",
+			Details: []string{"ℹ️ Host policies can lock out nodes, so this test only runs when explicitly enabled"},
+		}
+	}
+
+	allowedPorts := config.HostFirewallAllowedPorts
+	if len(allowedPorts) == 0 {
+		allowedPorts = defaultHostFirewallAllowedPorts
+	}
+
+	listenerPodName := "host-firewall-listener"
+	clientPodName := "netshoot-host-firewall-test"
+
+	// Step 1: Host firewall must be enabled in Cilium
+	ciliumConfig, err := t.getCiliumConfig(ctx)
+	if err != nil || ciliumConfig["enable-host-firewall"] != "true" {
+		return TestResult{
+			Success: false,
+			Message: "Cilium host firewall is not enabled (enable-host-firewall is not \"true\" in cilium-config)",
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage: "Prerequisites",
+				TroubleshootingHints: []string{
+					"Enable with: cilium config set enable-host-firewall true (or Helm value hostFirewall.enabled=true)",
+					"Host firewall requires Cilium to manage the node's devices (devices or auto-detection)",
+				},
+			},
+		}
+	}
+	details = append(details, "✓ Cilium host firewall is enabled")
+
+	// Step 2: Pick a single target node and, if possible, a different source node
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(workerNodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to find worker nodes: %v", err),
+			Details: details,
+		}
+	}
+	targetNode := workerNodes[0]
+	sourceNode := targetNode
+	if len(workerNodes) > 1 {
+		sourceNode = workerNodes[1]
+	}
+	node, err := t.clientset.CoreV1().Nodes().Get(ctx, targetNode, metav1.GetOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get node %s: %v", targetNode, err),
+			Details: details,
+		}
+	}
+	var targetIP string
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			targetIP = address.Address
+			break
+		}
+	}
+	if targetIP == "" {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Node %s has no InternalIP", targetNode),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Target node %s (%s), probing from a pod on %s", targetNode, targetIP, sourceNode))
+
+	// Rollback runs with its own context so it still happens when the test context has expired. It may run from the
+	// watchdog goroutine, so the state it undoes is guarded by rollbackMu.
+	var rollbackMu sync.Mutex
+	var appliedPolicyName string
+	var labeled bool
+	rollback := func() {
+		rollbackMu.Lock()
+		defer rollbackMu.Unlock()
+		rollbackCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if appliedPolicyName != "" {
+			// May run from the watchdog goroutine, so report on the console rather than in details
+			if err := t.deleteNetworkPolicy(rollbackCtx, appliedPolicyName); err != nil {
+				fmt.Printf("⚠️ Failed to remove host policy, remove it manually: kubectl delete ciliumclusterwidenetworkpolicy %s\n", appliedPolicyName)
+			}
+			appliedPolicyName = ""
+		}
+		if labeled {
+			if err := t.labelNode(rollbackCtx, targetNode, hostFirewallNodeLabel, ""); err != nil {
+				fmt.Printf("⚠️ Failed to remove the host firewall label, remove it manually: kubectl label node %s %s-\n", targetNode, hostFirewallNodeLabel)
+			}
+			labeled = false
+		}
+	}
+	defer func() {
+		rollback()
+		t.cleanupPods(ctx, listenerPodName, clientPodName)
+	}()
+
+	// Step 3: Listener on the target node and client on the source node
+	if _, err := t.createHostPortListenerPod(ctx, listenerPodName, targetNode, hostFirewallTestPort); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create host listener pod: %v", err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNetshootPod(ctx, clientPodName, sourceNode); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	for _, podName := range []string{listenerPodName, clientPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Host listener on %s:%d and client pod ready", targetNode, hostFirewallTestPort))
+
+	// Step 4: Baseline - the test port and allowed services must be reachable
+	reachable, output := t.probeNodePort(ctx, clientPodName, targetIP, hostFirewallTestPort)
+	commandOutputs = append(commandOutputs, output)
+	if !reachable {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Baseline failed - test port %d on %s is unreachable before the host policy is applied", hostFirewallTestPort, targetNode),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Baseline Connectivity",
+				TechnicalError: strings.TrimSpace(output.Stderr),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check for existing host policies: kubectl get ciliumclusterwidenetworkpolicies",
+					"Check that an infrastructure firewall does not block the port between nodes",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Baseline: test port %d reachable", hostFirewallTestPort))
+
+	var guardedPorts []int
+	for _, port := range allowedPorts {
+		reachable, output := t.probeNodePort(ctx, clientPodName, targetIP, port)
+		commandOutputs = append(commandOutputs, output)
+		if reachable {
+			guardedPorts = append(guardedPorts, port)
+			details = append(details, fmt.Sprintf("✓ Baseline: allowed node port %d reachable", port))
+		} else {
+			details = append(details, fmt.Sprintf("⚠️ Allowed node port %d is not reachable even without the host policy - it will not be checked", port))
+		}
+	}
+
+	// Step 5: Apply the host policy under a watchdog
+	// Marked before patching: removing a label that was never set is harmless, a label left behind is not
+	rollbackMu.Lock()
+	labeled = true
+	err = t.labelNode(ctx, targetNode, hostFirewallNodeLabel, "target")
+	rollbackMu.Unlock()
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to label node %s: %v", targetNode, err),
+			Details: details,
+		}
+	}
+
+	rollbackMu.Lock()
+	policyName, err := t.applyNetworkPolicy(ctx, hostFirewallPolicyFile)
+	appliedPolicyName = policyName
+	rollbackMu.Unlock()
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to apply host policy: %v", err),
+			Details: details,
+		}
+	}
+	// Armed once the policy exists, so the watchdog always removes it
+	watchdog := time.AfterFunc(hostFirewallWatchdog, rollback)
+	defer watchdog.Stop()
+	details = append(details, fmt.Sprintf("✓ Applied host policy %s to node %s (watchdog removes it after %v)", policyName, targetNode, hostFirewallWatchdog))
+
+	// Wait for policy to take effect
+	sleepContext(ctx, 5*time.Second)
+
+	// Allowed services first, rolling back immediately if any of them was cut off
+	var lostPorts []string
+	for _, port := range guardedPorts {
+		reachable, output := t.probeNodePort(ctx, clientPodName, targetIP, port)
+		commandOutputs = append(commandOutputs, output)
+		if reachable {
+			details = append(details, fmt.Sprintf("✓ With policy: allowed node port %d still reachable", port))
+		} else {
+			lostPorts = append(lostPorts, fmt.Sprintf("%d", port))
+		}
+	}
+	if len(lostPorts) > 0 {
+		rollback()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Host policy blocked allowed node port(s) %s - policy rolled back", strings.Join(lostPorts, ", ")),
+			Details: append(details, fmt.Sprintf("✗ Allowed node port(s) %s became unreachable; host policy removed immediately", strings.Join(lostPorts, ", "))),
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Host Policy Lockout Prevented",
+				TechnicalError: fmt.Sprintf("ports %s unreachable on %s with the host policy applied", strings.Join(lostPorts, ", "), targetNode),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"A host policy with any allow rule puts the node into default-deny; make sure every required node service is allowed",
+					"Check policy verdicts on the node: kubectl -n kube-system exec ds/cilium -- cilium monitor --type policy-verdict",
+				},
+			},
+		}
+	}
+
+	stillReachable, output := t.probeNodePort(ctx, clientPodName, targetIP, hostFirewallTestPort)
+	commandOutputs = append(commandOutputs, output)
+	testPortBlocked := !stillReachable
+
+	// Step 6: Roll back and confirm the port is restored
+	rollback()
+	sleepContext(ctx, 3*time.Second)
+	restored, output := t.probeNodePort(ctx, clientPodName, targetIP, hostFirewallTestPort)
+	commandOutputs = append(commandOutputs, output)
+
+	details = append(details, "✓ Host policy removed and node label cleared")
+
+	if !testPortBlocked {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Host policy did not block test port %d on %s", hostFirewallTestPort, targetNode),
+			Details: append(details, fmt.Sprintf("✗ With policy: test port %d still reachable", hostFirewallTestPort)),
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Host Policy Enforcement",
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check the node carries the label: kubectl get node %s --show-labels", targetNode),
+					"Check host endpoint policy enforcement: kubectl -n kube-system exec ds/cilium -- cilium endpoint list | grep reserved:host",
+					"Host firewall only applies on devices Cilium manages; check the devices setting in cilium-config",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ With policy: test port %d blocked", hostFirewallTestPort))
+
+	if !restored {
+		details = append(details, fmt.Sprintf("⚠️ Test port %d still unreachable 3s after the host policy was removed", hostFirewallTestPort))
+	} else {
+		details = append(details, fmt.Sprintf("✓ After rollback: test port %d reachable again", hostFirewallTestPort))
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Host firewall blocked port %d on %s while %d allowed node port(s) stayed reachable", hostFirewallTestPort, targetNode, len(guardedPorts)),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package diagnostic
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlReportTemplate renders a report as one self-contained page: the summary, then every test with its details
+// and warnings and, for failed tests, the failure stage, error and troubleshooting hints
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>k8s-diagnostic {{.ExecutionInfo.RunID}} - {{.Summary.OverallStatus}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.passed { color: #1a7f37; }
+.warning { color: #9a6700; }
+.failed { color: #cf222e; }
+.skipped { color: #6e7781; }
+pre { background: #f6f8fa; padding: 8px; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>k8s-diagnostic run {{.ExecutionInfo.RunID}}</h1>
+<table>
+<tr><th>Overall status</th><td class="{{lower .Summary.OverallStatus}}">{{.Summary.OverallStatus}}</td></tr>
+<tr><th>Tests</th><td>{{.Summary.TotalTests}} ({{.Summary.Passed}} passed, {{.Summary.Warnings}} with warnings, {{.Summary.Failed}} failed, {{.Summary.Skipped}} skipped)</td></tr>
+<tr><th>Namespace</th><td>{{.ExecutionInfo.Namespace}}</td></tr>
+<tr><th>Started</th><td>{{.ExecutionInfo.Timestamp}}</td></tr>
+<tr><th>Completed</th><td>{{.Summary.CompletionTime}} ({{printf "%.1f" .Summary.TotalExecutionTimeSeconds}}s)</td></tr>
+{{- if .ExecutionInfo.KubeProxyMode}}
+<tr><th>kube-proxy mode</th><td>{{.ExecutionInfo.KubeProxyMode}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Tests</h2>
+<table>
+<tr><th>#</th><th>Test</th><th>Status</th><th>Time</th><th>Message</th></tr>
+{{- range .Tests}}
+<tr><td>{{.TestNumber}}</td><td><a href="#test-{{.TestNumber}}">{{.TestName}}</a></td><td class="{{lower .Status}}">{{.Status}}</td><td>{{printf "%.1f" .ExecutionTimeSeconds}}s</td><td>{{if .ErrorMessage}}{{.ErrorMessage}}{{else if .SkipReason}}{{.SkipReason}}{{else}}{{.SuccessMessage}}{{end}}</td></tr>
+{{- end}}
+</table>
+
+{{- range .Tests}}
+<h3 id="test-{{.TestNumber}}">{{.TestNumber}}. {{.TestName}} <span class="{{lower .Status}}">{{.Status}}</span></h3>
+<p>{{.Description}}</p>
+{{- if .Warnings}}
+<ul class="warning">
+{{- range .Warnings}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Details}}
+<pre>{{range .Details}}{{.}}
+{{end}}</pre>
+{{- end}}
+{{- with .DetailedDiagnostics}}
+{{- if .FailureStage}}
+<p><b>Failure stage:</b> {{.FailureStage}}</p>
+{{- end}}
+{{- if .TechnicalError}}
+<p><b>Error:</b> {{.TechnicalError}}</p>
+{{- end}}
+{{- if .TroubleshootingHints}}
+<p><b>Troubleshooting hints:</b></p>
+<ul>
+{{- range .TroubleshootingHints}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .CommandOutputs}}
+<p><b>{{.Description}}</b> (exit code {{.ExitCode}})</p>
+<pre>$ {{.Command}}
+{{.Stdout}}{{.Stderr}}</pre>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// RenderHTMLReport writes the report as a standalone HTML page
+func RenderHTMLReport(w io.Writer, report *DiagnosticReportJSON) error {
+	return htmlReportTemplate.Execute(w, report)
+}
@@ -0,0 +1,224 @@
+package diagnostic
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPOptions tunes the HTTP probe of the service tests (service-to-pod, cross-node, nodeport, loadbalancer,
+// ip-family, cilium-lb-ipam and cilium-kpr). Zero values keep the defaults: GET http://<target>/ with 3s to connect
+// and 5s overall, not following redirects, passing on any 2xx status.
+type HTTPOptions struct {
+	Scheme          string        `json:"scheme,omitempty"`           // http or https; the certificate is not verified
+	Port            int           `json:"port,omitempty"`             // port for targets without one, e.g. service names (default: the scheme's port)
+	Path            string        `json:"path,omitempty"`             // request path (default /)
+	Method          string        `json:"method,omitempty"`           // request method (default GET)
+	Headers         []string      `json:"headers,omitempty"`          // extra request headers as "Name: value", e.g. a Host header
+	ConnectTimeout  time.Duration `json:"connect_timeout,omitempty"`  // time to establish the connection (default 3s)
+	Timeout         time.Duration `json:"timeout,omitempty"`          // total time of a request (default 5s)
+	FollowRedirects bool          `json:"follow_redirects,omitempty"` // follow 3xx responses to the final status
+	ExpectedStatus  []int         `json:"expected_status,omitempty"`  // status codes that pass (default: any 2xx)
+}
+
+const (
+	defaultHTTPConnectTimeout = 3 * time.Second
+	defaultHTTPTimeout        = 5 * time.Second
+
+	// maxCapturedBody bounds the response body kept in the report
+	maxCapturedBody = 4 * 1024
+	// httpProbeMarker starts the line curl writes after the response with the status code and timings
+	httpProbeMarker = "k8s-diagnostic-http-probe:"
+)
+
+// HTTPResponse is the response to an HTTP probe as the client pod saw it. Origin tells a response generated by a
+// proxy in the path, e.g. Envoy's "upstream connect error" or a 502 from an ingress controller, from one served by
+// the backend.
+type HTTPResponse struct {
+	URL        string      `json:"url"`
+	From       string      `json:"from"`                // client pod
+	StatusCode int         `json:"status_code"`         // 0 when no response arrived
+	RemoteIP   string      `json:"remote_ip,omitempty"` // address curl connected to
+	Headers    []string    `json:"headers,omitempty"`   // status and header lines of every response, including redirects
+	Body       string      `json:"body,omitempty"`      // first 4 KiB of the final response body
+	BodyBytes  int         `json:"body_bytes"`          // size of the whole body
+	Timing     *HTTPTiming `json:"timing,omitempty"`    // milliseconds from the start of the request
+	Origin     string      `json:"origin,omitempty"`    // proxy or backend
+	Error      string      `json:"error,omitempty"`     // curl error when the request failed
+}
+
+// HTTPTiming breaks a request down like curl's write-out variables; each value is the time from the start of the
+// request, so the connect time includes DNS and the time to first byte includes the backend processing
+type HTTPTiming struct {
+	DNSLookupMs    float64 `json:"dns_lookup_ms"`
+	ConnectMs      float64 `json:"connect_ms"`
+	TLSHandshakeMs float64 `json:"tls_handshake_ms,omitempty"`
+	FirstByteMs    float64 `json:"first_byte_ms"`
+	TotalMs        float64 `json:"total_ms"`
+}
+
+// url returns the URL the probe requests for target, a host or host:port
+func (o HTTPOptions) url(target string) string {
+	scheme := o.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil && o.Port > 0 {
+		target = fmt.Sprintf("%s:%d", target, o.Port)
+	}
+	path := o.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, target, path)
+}
+
+// curlCommand returns the curl command line requesting target, printing the response headers and body followed by
+// the status code and timings
+func (o HTTPOptions) curlCommand(target string) []string {
+	connectTimeout, timeout := o.ConnectTimeout, o.Timeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultHTTPConnectTimeout
+	}
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	command := []string{"curl", "-sS", "-i", "--connect-timeout", seconds(connectTimeout), "--max-time", seconds(timeout),
+		"-w", "\n" + httpProbeMarker + " %{http_code} %{time_namelookup} %{time_connect} %{time_appconnect} %{time_starttransfer} %{time_total} %{size_download} %{remote_ip}"}
+	if o.Scheme == "https" {
+		command = append(command, "-k")
+	}
+	switch method := strings.ToUpper(o.Method); method {
+	case "", "GET":
+	case "HEAD":
+		command = append(command, "--head")
+	default:
+		command = append(command, "-X", method)
+	}
+	for _, header := range o.Headers {
+		command = append(command, "-H", header)
+	}
+	if o.FollowRedirects {
+		command = append(command, "-L")
+	}
+	return append(command, o.url(target))
+}
+
+// evaluate judges the status code of a probe against the expected codes, or as evaluateHTTPStatusCode without them
+func (o HTTPOptions) evaluate(statusCode string) (bool, string) {
+	if len(o.ExpectedStatus) == 0 {
+		return evaluateHTTPStatusCode(statusCode)
+	}
+	code, err := strconv.Atoi(statusCode)
+	if err != nil {
+		return false, fmt.Sprintf("Invalid status code: %s", statusCode)
+	}
+	if slices.Contains(o.ExpectedStatus, code) {
+		return true, fmt.Sprintf("Expected status - HTTP %d", code)
+	}
+	return false, fmt.Sprintf("Unexpected status - HTTP %d (expected %s)", code, strings.Trim(fmt.Sprint(o.ExpectedStatus), "[]"))
+}
+
+// redirectWarning describes a 3xx answer to a probe that neither follows redirects nor lists expected codes - the
+// backend is reachable but does not serve the path itself - or returns "" for every other status
+func (o HTTPOptions) redirectWarning(statusCode string) string {
+	code, err := strconv.Atoi(statusCode)
+	if err != nil || o.FollowRedirects || len(o.ExpectedStatus) > 0 || code < 300 || code >= 400 {
+		return ""
+	}
+	return fmt.Sprintf("HTTP probe answered with redirect %d - use --http-follow-redirects or --http-expected-status to judge it", code)
+}
+
+// parseHTTPProbe splits the output of curlCommand into the response headers, body, status code and timings
+func parseHTTPProbe(stdout string) HTTPResponse {
+	var response HTTPResponse
+	end := strings.LastIndex(stdout, "\n"+httpProbeMarker)
+	if end < 0 {
+		return response
+	}
+	fields := strings.Fields(stdout[end+len(httpProbeMarker)+1:])
+	if len(fields) >= 7 {
+		response.StatusCode, _ = strconv.Atoi(fields[0])
+		ms := make([]float64, 5)
+		for i := range ms {
+			value, _ := strconv.ParseFloat(fields[i+1], 64)
+			ms[i] = value * 1000
+		}
+		if response.StatusCode > 0 {
+			response.Timing = &HTTPTiming{DNSLookupMs: ms[0], ConnectMs: ms[1], TLSHandshakeMs: ms[2], FirstByteMs: ms[3], TotalMs: ms[4]}
+		}
+		response.BodyBytes, _ = strconv.Atoi(fields[6])
+	}
+	if len(fields) >= 8 {
+		response.RemoteIP = fields[7]
+	}
+
+	// Each response of a redirect chain (or a 100 Continue) starts with its own status line
+	rest := stdout[:end]
+	for strings.HasPrefix(rest, "HTTP/") {
+		block := rest
+		rest = ""
+		if blockEnd := strings.Index(block, "\r\n\r\n"); blockEnd >= 0 {
+			block, rest = block[:blockEnd], block[blockEnd+4:]
+		} else if blockEnd := strings.Index(block, "\n\n"); blockEnd >= 0 {
+			block, rest = block[:blockEnd], block[blockEnd+2:]
+		}
+		for _, line := range strings.Split(block, "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				response.Headers = append(response.Headers, line)
+			}
+		}
+	}
+	if len(rest) > maxCapturedBody {
+		rest = strings.ToValidUTF8(rest[:maxCapturedBody], "") + fmt.Sprintf("\n[... %d more bytes ...]", len(rest)-maxCapturedBody)
+	}
+	response.Body = rest
+	response.Origin = responseOrigin(response)
+	return response
+}
+
+// proxyErrorSignatures are bodies proxies send instead of a backend response
+var proxyErrorSignatures = []string{
+	"upstream connect error",
+	"no healthy upstream",
+	"upstream request timeout",
+	"default backend - 404",
+}
+
+// responseOrigin tells whether a response came from a proxy in the path rather than the backend: gateway errors and
+// the error pages of Envoy and ingress-nginx are generated by the proxy
+func responseOrigin(response HTTPResponse) string {
+	if response.StatusCode == 0 {
+		return ""
+	}
+	if response.StatusCode == 502 || response.StatusCode == 504 {
+		return "proxy"
+	}
+	body := strings.ToLower(response.Body)
+	for _, signature := range proxyErrorSignatures {
+		if strings.Contains(body, signature) {
+			return "proxy"
+		}
+	}
+	// Envoy adds x-envoy-upstream-service-time to the responses it got from the backend
+	if response.StatusCode >= 500 {
+		fromEnvoy, fromUpstream := false, false
+		for _, header := range response.Headers {
+			header = strings.ToLower(header)
+			fromEnvoy = fromEnvoy || header == "server: envoy"
+			fromUpstream = fromUpstream || strings.HasPrefix(header, "x-envoy-upstream-service-time:")
+		}
+		if fromEnvoy && !fromUpstream {
+			return "proxy"
+		}
+	}
+	return "backend"
+}
+
+// seconds formats d for command line timeouts in seconds
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
@@ -0,0 +1,175 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// httpReporterTimeout bounds each POST, which is also sent after the run context has expired
+	httpReporterTimeout = 10 * time.Second
+	// httpReporterQueueSize is how many progress events wait for the background sender before new ones are dropped
+	httpReporterQueueSize = 256
+)
+
+// ReportEvent is the JSON body HTTPReporter posts for each event; Type is run_started, test_started,
+// step_completed, test_finished or run_finished
+type ReportEvent struct {
+	Type   string                `json:"type"`
+	Time   time.Time             `json:"time"`
+	Run    *RunInfo              `json:"run,omitempty"`
+	Number int                   `json:"test_number,omitempty"`
+	Test   string                `json:"test,omitempty"`
+	Step   *CommandOutput        `json:"step,omitempty"`
+	Result *TestResult           `json:"result,omitempty"`
+	Report *DiagnosticReportJSON `json:"report,omitempty"`
+}
+
+// HTTPReporter posts every event of a run as JSON to URL, e.g. a server collecting the runs of many clusters.
+// Progress events are queued to a background sender, so a slow server never delays the tests; when the queue is full
+// new events are dropped. After the first failed post, progress events are no longer sent. RunFinished waits briefly
+// for the queue to drain, then posts the final report and returns only its error.
+type HTTPReporter struct {
+	URL    string
+	Client *http.Client // http.DefaultClient when nil
+
+	mu       sync.Mutex
+	queue    chan ReportEvent // progress events for the background sender, created by the first one
+	drained  chan struct{}    // closed when the sender has handled every queued event
+	err      error            // first failed progress event
+	dropped  int              // progress events dropped because the queue was full
+	finished bool             // set by RunFinished; later progress events are dropped
+	stopped  bool             // set when RunFinished stopped waiting; queued events are discarded
+}
+
+func (r *HTTPReporter) RunStarted(ctx context.Context, run RunInfo) {
+	r.post(ctx, ReportEvent{Type: "run_started", Run: &run})
+}
+
+func (r *HTTPReporter) TestStarted(ctx context.Context, number int, test Test) {
+	r.post(ctx, ReportEvent{Type: "test_started", Number: number, Test: test.Name()})
+}
+
+// StepOutput is not posted: a request per output line would slow down the command. The output arrives with
+// step_completed.
+func (r *HTTPReporter) StepOutput(context.Context, Test, string, string) {}
+
+func (r *HTTPReporter) StepCompleted(ctx context.Context, test Test, step CommandOutput) {
+	r.post(ctx, ReportEvent{Type: "step_completed", Test: test.Name(), Step: &step})
+}
+
+func (r *HTTPReporter) TestFinished(ctx context.Context, number int, test Test, result *TimedTestResult) {
+	r.post(ctx, ReportEvent{Type: "test_finished", Number: number, Test: test.Name(), Result: &result.TestResult})
+}
+
+// RunFinished posts the final report even when progress events failed, and returns the error of that post only.
+// Progress events still queued after httpReporterTimeout are discarded, so the report is never held up for long.
+func (r *HTTPReporter) RunFinished(ctx context.Context, report *DiagnosticReportJSON) error {
+	r.mu.Lock()
+	queue, drained := r.queue, r.drained
+	r.finished = true
+	if queue != nil {
+		close(queue)
+	}
+	r.mu.Unlock()
+	if queue != nil {
+		select {
+		case <-drained:
+		case <-time.After(httpReporterTimeout):
+		}
+		r.mu.Lock()
+		r.stopped = true
+		r.mu.Unlock()
+	}
+	if err := r.send(ctx, ReportEvent{Type: "run_finished", Time: time.Now(), Report: report}); err != nil {
+		return fmt.Errorf("failed to post run_finished event to %s: %v", r.URL, err)
+	}
+	return nil
+}
+
+// ProgressError returns the first failed progress event, after which no more progress events were sent
+func (r *HTTPReporter) ProgressError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// DroppedEvents returns how many progress events were dropped because the server did not keep up
+func (r *HTTPReporter) DroppedEvents() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// post queues one progress event for the background sender, dropping it when the queue is full or a post failed
+func (r *HTTPReporter) post(ctx context.Context, event ReportEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.finished {
+		return
+	}
+	if r.queue == nil {
+		r.queue = make(chan ReportEvent, httpReporterQueueSize)
+		r.drained = make(chan struct{})
+		go r.sendQueued(context.WithoutCancel(ctx), r.queue, r.drained)
+	}
+	event.Time = time.Now()
+	select {
+	case r.queue <- event:
+	default:
+		r.dropped++
+	}
+}
+
+// sendQueued posts the queued progress events in order until the queue is closed, remembering the first failure
+func (r *HTTPReporter) sendQueued(ctx context.Context, queue <-chan ReportEvent, drained chan<- struct{}) {
+	defer close(drained)
+	for event := range queue {
+		r.mu.Lock()
+		skip := r.err != nil || r.stopped
+		r.mu.Unlock()
+		if skip {
+			continue
+		}
+		if err := r.send(ctx, event); err != nil {
+			r.mu.Lock()
+			if r.err == nil {
+				r.err = fmt.Errorf("failed to post %s event to %s: %v", event.Type, r.URL, err)
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *HTTPReporter) send(ctx context.Context, event ReportEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), httpReporterTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hubbleVerifyRequests is the number of HTTP requests sent for the Hubble cross-check
+const hubbleVerifyRequests = 5
+
+// hubbleFlowRecord is the subset of a `hubble observe -o jsonpb` line used to count connections
+type hubbleFlowRecord struct {
+	Flow struct {
+		Verdict          string `json:"verdict"`
+		TrafficDirection string `json:"traffic_direction"`
+		L4               struct {
+			TCP *struct {
+				DestinationPort int `json:"destination_port"`
+				Flags           *struct {
+					SYN bool `json:"SYN"`
+					ACK bool `json:"ACK"`
+				} `json:"flags"`
+			} `json:"TCP"`
+		} `json:"l4"`
+	} `json:"flow"`
+}
+
+// hubbleFlowSummary counts new TCP connections seen by Hubble per verdict
+type hubbleFlowSummary struct {
+	Connections int
+	Verdicts    map[string]int
+}
+
+// observeHubbleConnections counts egress TCP connection attempts (SYN without ACK) from a pod to port within the
+// last window, as seen by the Hubble server of the agent on nodeName
+func (t *Tester) observeHubbleConnections(ctx context.Context, podName, nodeName string, port int, window time.Duration) (hubbleFlowSummary, CommandOutput, error) {
+	summary := hubbleFlowSummary{Verdicts: map[string]int{}}
+
+	agentPod, err := t.getCiliumAgentPod(ctx, nodeName)
+	if err != nil {
+		return summary, CommandOutput{}, err
+	}
+	output, err := t.execInPodWithOutput(ctx, "kube-system", agentPod, "cilium-agent",
+		[]string{"hubble", "observe", "--from-pod", fmt.Sprintf("%s/%s", t.namespace, podName),
+			"--to-port", fmt.Sprintf("%d", port), "--protocol", "tcp",
+			"--since", fmt.Sprintf("%ds", int(window.Seconds())+1), "-o", "jsonpb"},
+		fmt.Sprintf("Hubble flows from %s on %s", podName, nodeName))
+	if err != nil {
+		return summary, output, fmt.Errorf("hubble observe failed in %s: %v", agentPod, err)
+	}
+
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var record hubbleFlowRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			continue
+		}
+		tcp := record.Flow.L4.TCP
+		if tcp == nil || tcp.Flags == nil || !tcp.Flags.SYN || tcp.Flags.ACK {
+			continue
+		}
+		if record.Flow.TrafficDirection != "" && record.Flow.TrafficDirection != "EGRESS" {
+			continue
+		}
+		summary.Connections++
+		summary.Verdicts[record.Flow.Verdict]++
+	}
+	return summary, output, nil
+}
+
+// verifyHubbleRequests sends a known number of HTTP requests from the client pod to target and checks that Hubble
+// observed the same number of forwarded connections to port. It returns false only on a confirmed mismatch; a
+// cluster without Hubble is reported in details but does not fail the check.
+func (t *Tester) verifyHubbleRequests(ctx context.Context, clientPodName, target string, port int, details *[]string, metrics map[string]float64) (bool, []CommandOutput) {
+	var commandOutputs []CommandOutput
+
+	ciliumConfig, err := t.getCiliumConfig(ctx)
+	if err != nil || ciliumConfig["enable-hubble"] != "true" {
+		*details = append(*details, "⚠️ Hubble cross-check skipped - enable-hubble is not \"true\" in cilium-config")
+		return true, commandOutputs
+	}
+
+	clientPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, clientPodName, metav1.GetOptions{})
+	if err != nil {
+		*details = append(*details, fmt.Sprintf("⚠️ Hubble cross-check skipped - could not get pod %s: %v", clientPodName, err))
+		return true, commandOutputs
+	}
+
+	start := time.Now()
+	for i := 0; i < hubbleVerifyRequests; i++ {
+		t.testHTTPConnectivityWithStatusCode(ctx, clientPodName, target, HTTPOptions{})
+	}
+	// Give the agent a moment to publish the flows to its ring buffer
+	sleepContext(ctx, 2*time.Second)
+
+	summary, output, err := t.observeHubbleConnections(ctx, clientPodName, clientPod.Spec.NodeName, port, time.Since(start))
+	commandOutputs = append(commandOutputs, output)
+	if err != nil {
+		*details = append(*details, fmt.Sprintf("⚠️ Hubble cross-check skipped - %v", err))
+		return true, commandOutputs
+	}
+
+	forwarded := summary.Verdicts["FORWARDED"]
+	metrics["hubble_requests_sent"] = float64(hubbleVerifyRequests)
+	metrics["hubble_connections_forwarded"] = float64(forwarded)
+	metrics["hubble_connections_dropped"] = float64(summary.Verdicts["DROPPED"])
+
+	var verdicts []string
+	for verdict, count := range summary.Verdicts {
+		verdicts = append(verdicts, fmt.Sprintf("%s=%d", verdict, count))
+	}
+	sort.Strings(verdicts)
+	*details = append(*details, fmt.Sprintf("  kubectl -n kube-system exec ds/cilium -c cilium-agent -- hubble observe --from-pod %s/%s --to-port %d",
+		t.namespace, clientPodName, port))
+
+	// SYN retransmits can add flows, so at least one forwarded connection per request is the expectation
+	if forwarded < hubbleVerifyRequests || summary.Verdicts["DROPPED"] > 0 {
+		*details = append(*details, fmt.Sprintf("✗ Hubble saw %d forwarded connections for %d requests (%s)",
+			forwarded, hubbleVerifyRequests, strings.Join(verdicts, ", ")))
+		return false, commandOutputs
+	}
+	*details = append(*details, fmt.Sprintf("✓ Hubble confirms %d requests traversed the datapath (%s)",
+		hubbleVerifyRequests, strings.Join(verdicts, ", ")))
+	return true, commandOutputs
+}
@@ -0,0 +1,270 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// idleEchoPort is the port of the TCP echo server behind the idle test's Service
+	idleEchoPort = 9000
+	// idleGrace is how long after the idle period the test waits for the echoed data
+	idleGrace = 15 * time.Second
+)
+
+// createIdleEchoPod runs a TCP echo server that keeps each connection open until the client closes it
+func (t *Tester) createIdleEchoPod(ctx context.Context, name, nodeName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"socat",
+						fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", idleEchoPort),
+						"EXEC:cat",
+					},
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: idleEchoPort,
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// idleConnectionScript opens one connection to the echo Service, sends a marker, stays idle for the given time and
+// sends a second marker, writing the echoed markers, socat errors and its exit code to logFile. It runs in the
+// background so the idle period does not depend on a long-lived exec stream.
+func idleConnectionScript(target string, idle time.Duration, logFile string) string {
+	return fmt.Sprintf(`nohup sh -c '(echo idle-start; sleep %d; echo idle-end; sleep 5) | socat -t 5 - TCP:%s:%d; echo "socat-exit $?"' > %s 2>&1 &`,
+		int(idle.Seconds()), target, idleEchoPort, logFile)
+}
+
+// idleConnectionOutcome classifies the log of one idle connection
+func idleConnectionOutcome(log string) string {
+	switch {
+	case strings.Contains(log, "idle-end"):
+		return "survived"
+	case strings.Contains(log, "Connection reset"):
+		return "reset"
+	case !strings.Contains(log, "idle-start"):
+		return "not established"
+	case !strings.Contains(log, "socat-exit"):
+		return "hung"
+	default:
+		return "silently dropped"
+	}
+}
+
+// TestIdleConnectionTimeout opens TCP connections through a ClusterIP Service, keeps each idle for one of the
+// configured durations and then sends data, detecting conntrack or NAT entries that expire silently while the
+// connection is idle - the failure that breaks pooled database and gRPC connections without TCP keepalive
+func (t *Tester) TestIdleConnectionTimeout(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	if len(config.IdleTimeouts) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Idle connection timeout test skipped - opt in with --idle-timeouts, e.g. 30s,5m,15m",
+			Details: details,
+		}
+	}
+	durations := append([]time.Duration{}, config.IdleTimeouts...)
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	longest := durations[len(durations)-1]
+	if longest > 50*time.Minute {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Idle period %v is too long - the test pods live for one hour, use at most 50m", longest),
+			Details: details,
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < longest+idleGrace+time.Minute {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Not enough time left in the run for a %v idle period", longest),
+			Details: details,
+		}
+	}
+
+	serverPod := "idle-echo-server"
+	serviceName := "idle-echo"
+	clientPod := "netshoot-idle-client"
+	defer t.cleanupPods(ctx, serverPod, clientPod)
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Services(t.namespace).Delete(ctx, serviceName, metav1.DeleteOptions{})
+	}()
+
+	// Step 1: Echo server, Service and client, on different worker nodes when possible
+	nodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(nodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Idle connection timeout test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+	clientNode, serverNode := nodes[0], nodes[0]
+	if len(nodes) > 1 {
+		serverNode = nodes[1]
+	}
+	if _, err := t.createIdleEchoPod(ctx, serverPod, serverNode); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create echo server %s: %v", serverPod, err),
+			Details: details,
+		}
+	}
+	service, err := t.clientset.CoreV1().Services(t.namespace).Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: t.namespace},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": serverPod},
+			Ports:    []corev1.ServicePort{{Port: idleEchoPort, TargetPort: intstr.FromInt(idleEchoPort)}},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create service %s: %v", serviceName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNetshootPod(ctx, clientPod, clientNode); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create client pod %s: %v", clientPod, err),
+			Details: details,
+		}
+	}
+	for _, pod := range []string{serverPod, clientPod} {
+		if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s not ready: %v", pod, err),
+				Details: details,
+			}
+		}
+	}
+	target := service.Spec.ClusterIP
+	details = append(details, fmt.Sprintf("✓ Echo server on %s behind ClusterIP %s:%d, client on %s", serverNode, target, idleEchoPort, clientNode))
+
+	// Step 2: Baseline - an immediate round trip through the Service
+	var commandOutputs []CommandOutput
+	baseline, _ := t.execInPodWithOutput(ctx, t.namespace, clientPod, "netshoot",
+		[]string{"sh", "-c", fmt.Sprintf("echo idle-baseline | socat -t 2 - TCP:%s:%d", target, idleEchoPort)}, "Baseline round trip through the Service")
+	commandOutputs = append(commandOutputs, baseline)
+	if !strings.Contains(baseline.Stdout, "idle-baseline") {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Baseline round trip through %s:%d failed", target, idleEchoPort),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Baseline",
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: []string{"Run the service-to-pod test first - the Service does not work before any idle period"},
+			},
+		}
+	}
+	details = append(details, "✓ Baseline round trip through the Service succeeded")
+
+	// Step 3: One connection per idle duration, all running concurrently in the client pod
+	logFiles := make([]string, len(durations))
+	for i, idle := range durations {
+		logFiles[i] = fmt.Sprintf("/tmp/idle-%d.log", i)
+		if _, err := t.execInPod(ctx, t.namespace, clientPod, "netshoot", []string{"sh", "-c", idleConnectionScript(target, idle, logFiles[i])}); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to open the %v idle connection: %v", idle, err),
+				Details: details,
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Opened %d connections, idling up to %v", len(durations), longest))
+	select {
+	case <-ctx.Done():
+		return TestResult{
+			Success: false,
+			Message: "Run timed out during the idle period",
+			Details: details,
+		}
+	case <-time.After(longest + idleGrace):
+	}
+
+	// Step 4: Collect each connection's outcome
+	metrics := map[string]float64{}
+	var failures []string
+	var silent bool
+	details = append(details, fmt.Sprintf("  %-10s %s", "IDLE", "RESULT"))
+	for i, idle := range durations {
+		output, _ := t.execInPodWithOutput(ctx, t.namespace, clientPod, "netshoot", []string{"cat", logFiles[i]},
+			fmt.Sprintf("Connection idle for %v", idle))
+		outcome := idleConnectionOutcome(output.Stdout)
+		survived := 0.0
+		marker := "✓"
+		if outcome != "survived" {
+			marker = "✗"
+			failures = append(failures, fmt.Sprintf("%v: %s", idle, outcome))
+			commandOutputs = append(commandOutputs, output)
+			silent = silent || outcome == "silently dropped" || outcome == "hung"
+		} else {
+			survived = 1
+		}
+		metrics[fmt.Sprintf("survived.%s", idle)] = survived
+		details = append(details, fmt.Sprintf("  %-10s %s %s", idle, marker, outcome))
+	}
+
+	if len(failures) > 0 {
+		hints := []string{
+			"Connections idle longer than a conntrack or NAT timeout lose their translation; enable TCP keepalive below the shortest failing idle period",
+			"Check nf_conntrack_tcp_timeout_established on the nodes (kube-proxy sets it via --conntrack-tcp-timeout-established) and any CNI conntrack GC settings",
+			"Cloud NAT gateways and load balancers have their own idle timeouts (often 350s or 4 minutes)",
+		}
+		if silent {
+			hints = append([]string{"Data after the idle period was lost without a reset - clients block until their TCP retransmission timeout"}, hints...)
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Idle connections through the Service broke: %s", strings.Join(failures, ", ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Idle Connection",
+				TechnicalError:       strings.Join(failures, "; "),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Connections through the Service survived idle periods up to %v", longest),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,189 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// netshootImage is the client and debug image of most tests
+	netshootImage = "nicolaka/netshoot"
+	// nginxImage is the HTTP backend of the service tests
+	nginxImage = "nginx:alpine"
+	// imageMirrorPullTimeout bounds the pull of one mirrored image in the mirror preflight
+	imageMirrorPullTimeout = 90 * time.Second
+)
+
+// ImageMirrors maps upstream images to the internal mirrors pods pull them from. A key is an image, a repository
+// (all its tags) or a registry or repository prefix, e.g. "nicolaka/netshoot", "nginx" or "registry.k8s.io"; names
+// without a registry host also match their docker.io form, e.g. "docker.io/library/nginx".
+type ImageMirrors map[string]string
+
+// ParseImageMirrors parses "upstream=mirror" entries of the --image-mirror flag or the image-mirror config list
+func ParseImageMirrors(entries []string) (ImageMirrors, error) {
+	mirrors := ImageMirrors{}
+	for _, entry := range entries {
+		upstream, mirror, ok := strings.Cut(entry, "=")
+		upstream, mirror = strings.TrimSpace(upstream), strings.TrimSpace(mirror)
+		if !ok || upstream == "" || mirror == "" {
+			return nil, fmt.Errorf("invalid image mirror %q, want upstream=mirror", entry)
+		}
+		mirrors[strings.TrimSuffix(upstream, "/")] = strings.TrimSuffix(mirror, "/")
+	}
+	return mirrors, nil
+}
+
+// canonicalImage spells an image out with Docker's defaults, e.g. nginx:alpine as docker.io/library/nginx:alpine
+func canonicalImage(image string) string {
+	ref := parseImageReference(image)
+	registry := ref.Registry
+	if registry == "registry-1.docker.io" {
+		registry = "docker.io"
+	}
+	separator := ":"
+	if strings.Contains(ref.Reference, ":") {
+		separator = "@"
+	}
+	return registry + "/" + ref.Repository + separator + ref.Reference
+}
+
+// Resolve returns the mirror of an image: the mapping of the image itself, or else of its longest matching prefix
+// with the rest of the name appended. Images without a mapping are returned unchanged.
+func (m ImageMirrors) Resolve(image string) string {
+	if len(m) == 0 {
+		return image
+	}
+	// Keys matching the image as written take precedence over keys matching its docker.io form
+	for _, name := range []string{image, canonicalImage(image)} {
+		if mirror, ok := m[name]; ok {
+			return mirror
+		}
+		best, bestRest := "", ""
+		for upstream := range m {
+			rest, ok := strings.CutPrefix(name, upstream)
+			if !ok || len(upstream) <= len(best) || (rest != "" && !strings.ContainsAny(rest[:1], "/:@")) {
+				continue
+			}
+			best, bestRest = upstream, rest
+		}
+		if best != "" {
+			return m[best] + bestRest
+		}
+	}
+	return image
+}
+
+// SetImageMirrors makes the tests pull their images from internal mirrors for the rest of the run
+func (t *Tester) SetImageMirrors(mirrors ImageMirrors) {
+	t.imageMirrors = mirrors
+}
+
+// image returns the image a test pod runs: the configured mirror of the upstream image, or the image itself
+func (t *Tester) image(upstream string) string {
+	return t.imageMirrors.Resolve(upstream)
+}
+
+// mirroredImages are the upstream images of the built-in tests that have a mirror configured, sorted
+func (t *Tester) mirroredImages() []string {
+	seen := map[string]bool{}
+	var images []string
+	for _, image := range append(append([]string{}, probeImages...), rolloutImage, grpcEchoImage) {
+		if !seen[image] && t.image(image) != image {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// pullImage runs a pod of the image on a Linux node and waits until its container starts, or reports why the image
+// could not be pulled
+func (t *Tester) pullImage(ctx context.Context, podName, image string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      podName,
+			Namespace: t.namespace,
+			Labels:    map[string]string{"app": "image-mirror-check"},
+		},
+		Spec: corev1.PodSpec{
+			NodeSelector: linuxNodeSelector,
+			Containers: []corev1.Container{
+				{
+					Name:  "image",
+					Image: image,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create pod: %v", err)
+	}
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+	}()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, imageMirrorPullTimeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(timeoutCtx, podName, metav1.GetOptions{})
+		if err == nil {
+			for _, status := range pod.Status.ContainerStatuses {
+				// A running or exited container means the image was pulled, whatever its entrypoint does without args
+				if status.State.Running != nil || status.State.Terminated != nil {
+					return nil
+				}
+				if waiting := status.State.Waiting; waiting != nil {
+					switch waiting.Reason {
+					case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+						return fmt.Errorf("%s: %s", waiting.Reason, waiting.Message)
+					}
+				}
+			}
+		}
+		select {
+		case <-timeoutCtx.Done():
+			return fmt.Errorf("not pulled within %v", imageMirrorPullTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckImageMirrors pulls every mirrored image of the built-in tests in a short-lived pod and describes the mirrors
+// that cannot be pulled, so a disconnected cluster fails up front instead of in every test with a pull timeout
+func (t *Tester) CheckImageMirrors(ctx context.Context) []string {
+	images := t.mirroredImages()
+	failures := make([]string, len(images))
+	var wg sync.WaitGroup
+	for i, upstream := range images {
+		wg.Add(1)
+		go func(i int, upstream string) {
+			defer wg.Done()
+			mirror := t.image(upstream)
+			if err := t.pullImage(ctx, fmt.Sprintf("image-mirror-check-%d", i), mirror); err != nil {
+				failures[i] = fmt.Sprintf("%s (mirror of %s): %v", mirror, upstream, err)
+			}
+		}(i, upstream)
+	}
+	wg.Wait()
+
+	var problems []string
+	for _, failure := range failures {
+		if failure != "" {
+			problems = append(problems, failure)
+		}
+	}
+	return problems
+}
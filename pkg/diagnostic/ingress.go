@@ -0,0 +1,187 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	gatewayGVR   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	httpRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	grpcRouteGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Resource: "grpcroutes"}
+)
+
+// GatewayTarget identifies a programmed Gateway API listener that test routes can attach to
+type GatewayTarget struct {
+	Name      string
+	Namespace string
+	Address   string
+	Port      int64
+	Listener  string
+}
+
+// detectIngressClass returns the default IngressClass, or the only one when exactly one exists
+func (t *Tester) detectIngressClass(ctx context.Context) (*networkingv1.IngressClass, error) {
+	classes, err := t.clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IngressClasses: %v", err)
+	}
+
+	for i := range classes.Items {
+		if classes.Items[i].Annotations[networkingv1.AnnotationIsDefaultIngressClass] == "true" {
+			return &classes.Items[i], nil
+		}
+	}
+	if len(classes.Items) == 1 {
+		return &classes.Items[0], nil
+	}
+	if len(classes.Items) == 0 {
+		return nil, fmt.Errorf("no IngressClass found")
+	}
+	return nil, fmt.Errorf("%d IngressClasses found and none is marked default", len(classes.Items))
+}
+
+// createIngressForService exposes a service port under a host name through the given IngressClass
+func (t *Tester) createIngressForService(ctx context.Context, name, className, host, serviceName string, servicePort int32, annotations map[string]string) (*networkingv1.Ingress, error) {
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   t.namespace,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &className,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: serviceName,
+											Port: networkingv1.ServiceBackendPort{Number: servicePort},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return t.clientset.NetworkingV1().Ingresses(t.namespace).Create(ctx, ingress, metav1.CreateOptions{})
+}
+
+// waitForIngressAddress waits for the ingress controller to publish an address on the Ingress status
+func (t *Tester) waitForIngressAddress(ctx context.Context, name string, timeout time.Duration) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		ingress, err := t.clientset.NetworkingV1().Ingresses(t.namespace).Get(timeoutCtx, name, metav1.GetOptions{})
+		if err == nil {
+			for _, lb := range ingress.Status.LoadBalancer.Ingress {
+				if lb.IP != "" {
+					return lb.IP, nil
+				}
+				if lb.Hostname != "" {
+					return lb.Hostname, nil
+				}
+			}
+		}
+
+		select {
+		case <-timeoutCtx.Done():
+			return "", fmt.Errorf("ingress %s received no address within %v", name, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanupIngress removes a test Ingress
+func (t *Tester) cleanupIngress(ctx context.Context, name string) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	t.clientset.NetworkingV1().Ingresses(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// detectGateway returns the first Gateway with a published address and an HTTP listener
+func (t *Tester) detectGateway(ctx context.Context) (*GatewayTarget, error) {
+	gateways, err := t.dynamicClient.Resource(gatewayGVR).Namespace("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Gateway API not available: %v", err)
+	}
+
+	for _, gateway := range gateways.Items {
+		addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		if len(addresses) == 0 {
+			continue
+		}
+		address, _ := addresses[0].(map[string]interface{})["value"].(string)
+		for _, rawListener := range listeners {
+			listener, ok := rawListener.(map[string]interface{})
+			if !ok || listener["protocol"] != "HTTP" {
+				continue
+			}
+			port, _ := listener["port"].(int64)
+			name, _ := listener["name"].(string)
+			return &GatewayTarget{
+				Name:      gateway.GetName(),
+				Namespace: gateway.GetNamespace(),
+				Address:   address,
+				Port:      port,
+				Listener:  name,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no Gateway with an address and an HTTP listener found")
+}
+
+// createGatewayRoute attaches an HTTPRoute or GRPCRoute for host to the given Gateway, forwarding to a service port
+func (t *Tester) createGatewayRoute(ctx context.Context, gvr schema.GroupVersionResource, kind, name, host string, gateway *GatewayTarget, backends []interface{}) error {
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", gvr.Group, gvr.Version),
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": t.namespace,
+			},
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{
+					map[string]interface{}{
+						"name":      gateway.Name,
+						"namespace": gateway.Namespace,
+					},
+				},
+				"hostnames": []interface{}{host},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"backendRefs": backends,
+					},
+				},
+			},
+		},
+	}
+
+	_, err := t.dynamicClient.Resource(gvr).Namespace(t.namespace).Create(ctx, route, metav1.CreateOptions{})
+	return err
+}
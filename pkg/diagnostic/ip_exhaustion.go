@@ -0,0 +1,111 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultIPFreeThreshold flags nodes with fewer free pod addresses than this
+const defaultIPFreeThreshold = 10
+
+// TestIPExhaustion computes how many more pods each node can start, bounded by its free pod addresses (node podCIDR
+// or Cilium cluster-pool allocation) and its max-pods, which cloud CNIs such as the AWS VPC CNI derive from the
+// instance's ENI address limit. Nodes below the threshold are flagged before exhaustion surfaces as Pending pods or
+// sandbox failures.
+func (t *Tester) TestIPExhaustion(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	threshold := config.IPFreeThreshold
+	if threshold <= 0 {
+		threshold = defaultIPFreeThreshold
+	}
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list pods: %v", err),
+			Details: details,
+		}
+	}
+	cni := t.detectCNI(ctx)
+	allocations := map[string]nodeIPAM{}
+	for _, allocation := range t.collectNodeIPAM(ctx, cni, nodes.Items, pods.Items) {
+		allocations[allocation.Node] = allocation
+	}
+	podCount := map[string]int{}
+	for _, pod := range pods.Items {
+		podCount[pod.Spec.NodeName]++
+	}
+	switch cni.Name {
+	case CNIAWSVPC:
+		details = append(details, "ℹ️ AWS VPC CNI: pod addresses are bounded by max-pods, which EKS derives from the instance's ENI and IP limits")
+	case CNICalico:
+		details = append(details, "ℹ️ Calico borrows addresses from shared IPAM blocks - only max-pods bounds each node")
+	}
+
+	// Step 1: Remaining pod capacity per node, whichever of addresses and max-pods runs out first
+	metrics := map[string]float64{}
+	var low []string
+	details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-8s %-22s %s", "NODE", "IP-FREE", "POD-SLOTS", "FREE", "LIMITED-BY", "RESULT"))
+	for _, node := range nodes.Items {
+		maxPods := node.Status.Allocatable.Pods().Value()
+		slots := int(maxPods) - podCount[node.Name]
+		free, limitedBy, ipFree := slots, "max-pods", "-"
+		// A Cilium ENI/Azure pool grows on demand up to the interface limits, so its current size is not a bound
+		if allocation, ok := allocations[node.Name]; ok && allocation.Source != "CiliumNode IP pool" && allocation.Capacity != math.MaxInt32 {
+			ipFree = fmt.Sprint(allocation.Free())
+			if allocation.Free() < free {
+				free, limitedBy = allocation.Free(), allocation.Source
+			}
+		}
+		marker := "✓"
+		if free < threshold {
+			marker = "✗"
+			if node.Spec.Unschedulable {
+				marker = "⚠️ cordoned"
+			} else {
+				low = append(low, fmt.Sprintf("%s (%d free, %s)", node.Name, free, limitedBy))
+			}
+		}
+		metrics[fmt.Sprintf("free_pod_ips.%s", node.Name)] = float64(free)
+		details = append(details, fmt.Sprintf("  %-30s %-10s %-10d %-8d %-22s %s", node.Name, ipFree, slots, free, limitedBy, marker))
+	}
+	metrics["nodes_below_threshold"] = float64(len(low))
+
+	if len(low) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d nodes have fewer than %d free pod addresses: %s", len(low), threshold, strings.Join(low, ", ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "IP Exhaustion Early Warning",
+				TechnicalError: strings.Join(low, "; "),
+				TroubleshootingHints: []string{
+					"New pods on these nodes will stay Pending or fail with FailedCreatePodSandBox once the addresses run out",
+					"Node podCIDR limits: a larger --node-cidr-mask-size (or Cilium cluster-pool-ipv4-mask-size) only applies to new nodes",
+					"AWS VPC CNI: enable prefix delegation (ENABLE_PREFIX_DELEGATION) or use larger instance types for more addresses per node",
+					"Spread workloads or add nodes; check for completed pods and leaked IPs still holding addresses",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d nodes have at least %d free pod addresses", len(nodes.Items), threshold),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,298 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ipFamilyOf returns the Kubernetes IP family of an address string
+func ipFamilyOf(address string) corev1.IPFamily {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if ip.To4() != nil {
+		return corev1.IPv4Protocol
+	}
+	return corev1.IPv6Protocol
+}
+
+// httpTargetForIP formats an IP address as an HTTP host, bracketing IPv6 literals
+func httpTargetForIP(address string) string {
+	if ipFamilyOf(address) == corev1.IPv6Protocol {
+		return fmt.Sprintf("[%s]", address)
+	}
+	return address
+}
+
+// createNginxServiceWithFamilies creates a ClusterIP service with an explicit ipFamilyPolicy and ipFamilies list
+func (t *Tester) createNginxServiceWithFamilies(ctx context.Context, serviceName, deploymentName string, policy corev1.IPFamilyPolicy, families []corev1.IPFamily) (*corev1.Service, error) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: t.namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				"app": deploymentName,
+			},
+			Ports: []corev1.ServicePort{
+				{
+					Port:       80,
+					TargetPort: intstr.FromInt(80),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+			Type:           corev1.ServiceTypeClusterIP,
+			IPFamilyPolicy: &policy,
+			IPFamilies:     families,
+		},
+	}
+
+	return t.clientset.CoreV1().Services(t.namespace).Create(ctx, service, metav1.CreateOptions{})
+}
+
+// validateServiceFamilies checks that the ClusterIPs assigned to a service match its ipFamilies, in order
+func validateServiceFamilies(service *corev1.Service) []string {
+	var problems []string
+
+	if len(service.Spec.ClusterIPs) != len(service.Spec.IPFamilies) {
+		problems = append(problems, fmt.Sprintf("service %s has %d ClusterIPs but %d ipFamilies",
+			service.Name, len(service.Spec.ClusterIPs), len(service.Spec.IPFamilies)))
+	}
+
+	for i, clusterIP := range service.Spec.ClusterIPs {
+		if i >= len(service.Spec.IPFamilies) {
+			break
+		}
+		if actual := ipFamilyOf(clusterIP); actual != service.Spec.IPFamilies[i] {
+			problems = append(problems, fmt.Sprintf("service %s ClusterIP %s is %s but ipFamilies[%d] is %s",
+				service.Name, clusterIP, actual, i, service.Spec.IPFamilies[i]))
+		}
+	}
+
+	return problems
+}
+
+// TestServiceIPFamilies validates ipFamilyPolicy/ipFamilies handling and per-family reachability of services
+func (t *Tester) TestServiceIPFamilies(ctx context.Context) TestResult {
+	return t.TestServiceIPFamiliesWithConfig(ctx, TestConfig{})
+}
+
+// TestServiceIPFamiliesWithConfig validates IP family handling, probing reachability with the configured HTTP probe
+func (t *Tester) TestServiceIPFamiliesWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+
+	deploymentName := "web-ipfamily"
+	dualServiceName := "web-ipfamily-dual"
+	testPodName := "netshoot-ipfamily-test"
+	familyServiceNames := map[corev1.IPFamily]string{
+		corev1.IPv4Protocol: "web-ipfamily-v4",
+		corev1.IPv6Protocol: "web-ipfamily-v6",
+	}
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, dualServiceName, testPodName)
+		for _, name := range familyServiceNames {
+			t.clientset.CoreV1().Services(t.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		}
+	}
+
+	// Step 1: Create the nginx backend
+	_, err := t.createNginxDeployment(ctx, deploymentName)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create nginx deployment: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created nginx deployment '%s' with 2 replicas", deploymentName))
+
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Deployment '%s' is ready", deploymentName))
+
+	// Step 2: Let the API server tell us which families it supports via a PreferDualStack service
+	dualService, err := t.createNginxServiceWithFamilies(ctx, dualServiceName, deploymentName, corev1.IPFamilyPolicyPreferDualStack, nil)
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create PreferDualStack service: %v", err),
+			Details: details,
+		}
+	}
+
+	var familyNames []string
+	for _, family := range dualService.Spec.IPFamilies {
+		familyNames = append(familyNames, string(family))
+	}
+	details = append(details, fmt.Sprintf("✓ PreferDualStack service '%s' assigned families [%s] with ClusterIPs %v",
+		dualServiceName, strings.Join(familyNames, ", "), dualService.Spec.ClusterIPs))
+	details = append(details, fmt.Sprintf("  kubectl get svc %s -n %s -o jsonpath='{.spec.ipFamilies} {.spec.clusterIPs}'", dualServiceName, t.namespace))
+
+	clusterDualStack := len(dualService.Spec.IPFamilies) > 1
+	if clusterDualStack {
+		details = append(details, "ℹ️ Cluster service CIDRs are dual-stack")
+	} else {
+		details = append(details, "ℹ️ Cluster service CIDRs are single-stack")
+	}
+
+	var problems []string
+	problems = append(problems, validateServiceFamilies(dualService)...)
+
+	// Step 3: Check that backend pods received an address for every family the services advertise
+	backendPods, err := t.clientset.CoreV1().Pods(t.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", deploymentName),
+	})
+	if err == nil {
+		for _, pod := range backendPods.Items {
+			podFamilies := map[corev1.IPFamily]bool{}
+			for _, podIP := range pod.Status.PodIPs {
+				podFamilies[ipFamilyOf(podIP.IP)] = true
+			}
+			for _, family := range dualService.Spec.IPFamilies {
+				if !podFamilies[family] {
+					problems = append(problems, fmt.Sprintf("backend pod %s has no %s address (podIPs: %v) although services are %s-capable",
+						pod.Name, family, pod.Status.PodIPs, family))
+				}
+			}
+		}
+		details = append(details, fmt.Sprintf("✓ Checked pod IP families on %d backend pods", len(backendPods.Items)))
+	}
+
+	// Step 4: Create a SingleStack service per family and validate the assigned ClusterIP
+	familyServiceIPs := map[corev1.IPFamily]string{}
+	for _, family := range []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol} {
+		serviceName := familyServiceNames[family]
+		service, err := t.createNginxServiceWithFamilies(ctx, serviceName, deploymentName, corev1.IPFamilyPolicySingleStack, []corev1.IPFamily{family})
+
+		supported := false
+		for _, advertised := range dualService.Spec.IPFamilies {
+			if advertised == family {
+				supported = true
+			}
+		}
+
+		if err != nil {
+			if supported {
+				problems = append(problems, fmt.Sprintf("SingleStack %s service rejected although the cluster advertises %s: %v", family, family, err))
+				details = append(details, fmt.Sprintf("✗ SingleStack %s service could not be created: %v", family, err))
+			} else {
+				details = append(details, fmt.Sprintf("ℹ️ SingleStack %s service not supported by this cluster (expected): %v", family, err))
+			}
+			continue
+		}
+
+		if !supported {
+			problems = append(problems, fmt.Sprintf("SingleStack %s service was accepted but PreferDualStack did not include %s", family, family))
+		}
+
+		serviceProblems := validateServiceFamilies(service)
+		problems = append(problems, serviceProblems...)
+		if len(serviceProblems) == 0 && len(service.Spec.ClusterIPs) > 0 {
+			familyServiceIPs[family] = service.Spec.ClusterIPs[0]
+			details = append(details, fmt.Sprintf("✓ SingleStack %s service '%s' assigned ClusterIP %s", family, serviceName, service.Spec.ClusterIPs[0]))
+		} else {
+			details = append(details, fmt.Sprintf("✗ SingleStack %s service '%s' has mismatched ClusterIPs %v", family, serviceName, service.Spec.ClusterIPs))
+		}
+	}
+
+	// Step 5: Reach each family from a client pod
+	_, err = t.createNetshootPod(ctx, testPodName, "")
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created test pod '%s'", testPodName))
+
+	if err := t.waitForPodReady(ctx, testPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Test pod %s did not become ready: %v", testPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Test pod '%s' is ready", testPodName))
+
+	for _, family := range []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol} {
+		clusterIP, ok := familyServiceIPs[family]
+		if !ok {
+			continue
+		}
+
+		target := httpTargetForIP(clusterIP)
+		statusCode, _, err := t.testHTTPConnectivityWithStatusCode(ctx, testPodName, target, config.HTTP)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s ClusterIP %s is not reachable from the client pod: %v", family, clusterIP, err))
+			details = append(details, fmt.Sprintf("✗ %s HTTP connectivity to %s failed: %v", family, clusterIP, err))
+			continue
+		}
+
+		success, message := config.HTTP.evaluate(statusCode)
+		if success {
+			details = append(details, fmt.Sprintf("✓ %s HTTP connectivity to %s successful - Status: %s", family, clusterIP, statusCode))
+			details = append(details, fmt.Sprintf("  curl -s -o /dev/null -w \"%%{http_code}\\n\" http://%s", target))
+		} else {
+			problems = append(problems, fmt.Sprintf("%s ClusterIP %s returned %s", family, clusterIP, message))
+			details = append(details, fmt.Sprintf("✗ %s HTTP connectivity issue - %s", family, message))
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up all IP family test resources")
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Service IP family validation failed - %d issue(s) found", len(problems)),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "IP Family Validation",
+				TechnicalError: strings.Join(problems, "; "),
+				NetworkContext: &NetworkContext{
+					AdditionalInfo: map[string]string{
+						"advertised_families": strings.Join(familyNames, ","),
+						"dual_stack":          fmt.Sprintf("%t", clusterDualStack),
+					},
+				},
+				TroubleshootingHints: []string{
+					"Check the --service-cluster-ip-range flag on kube-apiserver lists one CIDR per family",
+					"Check the --cluster-cidr flag on kube-controller-manager and the CNI IPAM configuration for both families",
+					"For Cilium, verify enable-ipv4/enable-ipv6 in the cilium-config ConfigMap",
+					"Inspect pod addresses with: kubectl get pods -o jsonpath='{.items[*].status.podIPs}'",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Service IP family validation passed - %d family(ies) assigned and reachable", len(familyServiceIPs)),
+		Details: details,
+	}
+}
@@ -0,0 +1,414 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ciliumNodeGVR is the CiliumNode CRD holding each node's IPAM allocation in Cilium cluster-pool and cloud modes
+var ciliumNodeGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnodes"}
+
+// ipamNearlyExhausted warns when fewer than this fraction of a node's pod addresses are free
+const ipamNearlyExhausted = 0.1
+
+// ipamPool is an address range pods are allocated from, with where it was found
+type ipamPool struct {
+	CIDR   string
+	Source string
+}
+
+// nodeIPAM is the pod address capacity and usage of one node
+type nodeIPAM struct {
+	Node     string
+	Source   string
+	CIDRs    []string
+	Capacity int
+	Used     int
+	MaxPods  int64
+	Pods     int // every pod on the node, host-network pods included, as they all count against max-pods
+}
+
+// Free returns the pod addresses still available on the node
+func (n nodeIPAM) Free() int {
+	return n.Capacity - n.Used
+}
+
+// cidrCapacity returns the usable addresses of a CIDR, excluding network and broadcast addresses
+func cidrCapacity(cidr string) int {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones >= 31 {
+		return math.MaxInt32
+	}
+	return max(0, 1<<(bits-ones)-2)
+}
+
+// cidrsOverlap reports whether two CIDRs share any address
+func cidrsOverlap(a, b string) bool {
+	_, first, errA := net.ParseCIDR(a)
+	_, second, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return first.Contains(second.IP) || second.Contains(first.IP)
+}
+
+// serviceClusterIPRange reads --service-cluster-ip-range from the kube-apiserver static pods, "" on managed
+// control planes where the flag is not visible
+func (t *Tester) serviceClusterIPRange(ctx context.Context) string {
+	return t.kubeAPIServerFlag(ctx, "--service-cluster-ip-range")
+}
+
+// kubeAPIServerFlag returns the value of a kube-apiserver flag from its static pods, "" when not set or not visible
+func (t *Tester) kubeAPIServerFlag(ctx context.Context, flag string) string {
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "component=kube-apiserver"})
+	if err != nil {
+		return ""
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			for _, arg := range append(container.Command, container.Args...) {
+				if value, ok := strings.CutPrefix(arg, flag+"="); ok {
+					return value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ciliumNodeIPAM returns the IPAM allocation of each CiliumNode: the pod CIDRs in cluster-pool mode or the size of
+// the pre-allocated IP pool in cloud (ENI, Azure) modes, and the number of addresses in use
+func (t *Tester) ciliumNodeIPAM(ctx context.Context) map[string]nodeIPAM {
+	list, err := t.dynamicClient.Resource(ciliumNodeGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	allocations := map[string]nodeIPAM{}
+	for _, item := range list.Items {
+		allocation := nodeIPAM{Node: item.GetName()}
+		allocation.CIDRs, _, _ = unstructured.NestedStringSlice(item.Object, "spec", "ipam", "podCIDRs")
+		used, _, _ := unstructured.NestedMap(item.Object, "status", "ipam", "used")
+		allocation.Used = len(used)
+		if len(allocation.CIDRs) > 0 {
+			allocation.Source = "CiliumNode podCIDRs"
+			for _, cidr := range allocation.CIDRs {
+				allocation.Capacity += cidrCapacity(cidr)
+			}
+		} else if pool, _, _ := unstructured.NestedMap(item.Object, "spec", "ipam", "pool"); len(pool) > 0 {
+			allocation.Source = "CiliumNode IP pool"
+			allocation.Capacity = len(pool)
+		} else {
+			continue
+		}
+		allocations[allocation.Node] = allocation
+	}
+	return allocations
+}
+
+// cniUsesNodePodCIDR reports whether the CNI allocates pod addresses from each node's spec.podCIDRs. Calico and
+// cloud CNIs use their own pools, so a podCIDR assigned by kube-controller-manager says nothing about them.
+func (t *Tester) cniUsesNodePodCIDR(ctx context.Context, cni CNIInfo) bool {
+	switch cni.Name {
+	case CNICalico, CNIAWSVPC, CNIWeave:
+		return false
+	case CNICilium:
+		config, err := t.getCiliumConfig(ctx)
+		return err == nil && config["ipam"] == "kubernetes"
+	}
+	return true
+}
+
+// ciliumPoolIPAM reports whether Cilium allocates from address ranges (cluster-pool or kubernetes IPAM) rather than
+// cloud network interfaces
+func (t *Tester) ciliumPoolIPAM(ctx context.Context) bool {
+	config, err := t.getCiliumConfig(ctx)
+	if err != nil {
+		return false
+	}
+	switch config["ipam"] {
+	case "", "cluster-pool", "kubernetes":
+		return true
+	}
+	return false
+}
+
+// collectNodeIPAM returns the pod address capacity and usage of each node, from CiliumNode objects where Cilium
+// manages IPAM and from spec.podCIDRs where the CNI uses them. Nodes whose CNI allocates from shared blocks are left
+// out.
+func (t *Tester) collectNodeIPAM(ctx context.Context, cni CNIInfo, nodes []corev1.Node, pods []corev1.Pod) []nodeIPAM {
+	podsOnNode := map[string][]corev1.Pod{}
+	podCount := map[string]int{}
+	for _, pod := range pods {
+		podCount[pod.Spec.NodeName]++
+		if !pod.Spec.HostNetwork && pod.Spec.NodeName != "" {
+			podsOnNode[pod.Spec.NodeName] = append(podsOnNode[pod.Spec.NodeName], pod)
+		}
+	}
+	ciliumNodes := t.ciliumNodeIPAM(ctx)
+	usesPodCIDR := t.cniUsesNodePodCIDR(ctx, cni)
+	var result []nodeIPAM
+	for _, node := range nodes {
+		allocation, ok := ciliumNodes[node.Name]
+		if !ok {
+			if !usesPodCIDR {
+				continue
+			}
+			allocation = nodeIPAM{Node: node.Name, Source: "node podCIDR", CIDRs: node.Spec.PodCIDRs}
+			if len(allocation.CIDRs) == 0 && node.Spec.PodCIDR != "" {
+				allocation.CIDRs = []string{node.Spec.PodCIDR}
+			}
+			if len(allocation.CIDRs) == 0 {
+				continue
+			}
+			// Dual-stack nodes have one CIDR per family; the IPv4 range is the one that runs out
+			allocation.Capacity = math.MaxInt32
+			for _, cidr := range allocation.CIDRs {
+				allocation.Capacity = min(allocation.Capacity, cidrCapacity(cidr))
+			}
+			for _, pod := range podsOnNode[node.Name] {
+				if cidrsContain(allocation.CIDRs, pod.Status.PodIP) {
+					allocation.Used++
+				}
+			}
+		}
+		allocation.Pods = podCount[node.Name]
+		allocation.MaxPods = node.Status.Allocatable.Pods().Value()
+		result = append(result, allocation)
+	}
+	return result
+}
+
+// ipamPools collects the address ranges pods are allocated from: node podCIDRs where the CNI uses them and the
+// CNI's own IPAM pools
+func (t *Tester) ipamPools(ctx context.Context, cni CNIInfo, nodes []corev1.Node) []ipamPool {
+	var pools []ipamPool
+	if t.cniUsesNodePodCIDR(ctx, cni) {
+		for _, node := range nodes {
+			for _, cidr := range node.Spec.PodCIDRs {
+				pools = append(pools, ipamPool{CIDR: cidr, Source: fmt.Sprintf("podCIDR of %s", node.Name)})
+			}
+		}
+	}
+	switch cni.Name {
+	case CNICalico:
+		if calicoPools, err := t.listCalicoIPPools(ctx); err == nil {
+			// Disabled pools still hold the addresses of existing pods
+			for _, pool := range calicoPools {
+				pools = append(pools, ipamPool{CIDR: pool.CIDR, Source: fmt.Sprintf("Calico IPPool %s", pool.Name)})
+			}
+		}
+	case CNICilium:
+		if config, err := t.getCiliumConfig(ctx); err == nil {
+			for _, cidr := range strings.Fields(config["cluster-pool-ipv4-cidr"]) {
+				pools = append(pools, ipamPool{CIDR: cidr, Source: "Cilium cluster-pool-ipv4-cidr"})
+			}
+		}
+		for node, allocation := range t.ciliumNodeIPAM(ctx) {
+			for _, cidr := range allocation.CIDRs {
+				pools = append(pools, ipamPool{CIDR: cidr, Source: fmt.Sprintf("CiliumNode %s", node)})
+			}
+		}
+	}
+	return pools
+}
+
+// TestIPAMSanity cross-checks node podCIDR allocations, CNI IPAM pools and actual pod IPs: overlapping node
+// allocations, pools overlapping the service CIDR or node addresses, duplicate pod IPs and pod IPs outside every pool
+// fail the test. A per-node table reports free pod addresses and warns on nodes that are nearly exhausted.
+func (t *Tester) TestIPAMSanity(ctx context.Context) TestResult {
+	var details []string
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list pods: %v", err),
+			Details: details,
+		}
+	}
+	services, err := t.clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list services: %v", err),
+			Details: details,
+		}
+	}
+	cni := t.detectCNI(ctx)
+	pools := t.ipamPools(ctx, cni, nodes.Items)
+	serviceCIDR := t.serviceClusterIPRange(ctx)
+	if serviceCIDR != "" {
+		details = append(details, fmt.Sprintf("ℹ️ Service CIDR %s (kube-apiserver --service-cluster-ip-range)", serviceCIDR))
+	} else {
+		details = append(details, "ℹ️ Service CIDR not visible (managed control plane) - checking Service ClusterIPs individually")
+	}
+	details = append(details, fmt.Sprintf("ℹ️ %d pod address pools found (%s IPAM)", len(pools), cni.Name))
+	var problems []string
+
+	// Step 1: Node podCIDR allocations must not overlap each other
+	for i, node := range nodes.Items {
+		for _, other := range nodes.Items[i+1:] {
+			for _, cidr := range node.Spec.PodCIDRs {
+				for _, otherCIDR := range other.Spec.PodCIDRs {
+					if cidrsOverlap(cidr, otherCIDR) {
+						problems = append(problems, fmt.Sprintf("podCIDR %s of %s overlaps podCIDR %s of %s", cidr, node.Name, otherCIDR, other.Name))
+					}
+				}
+			}
+		}
+	}
+
+	// Step 2: Pools must not overlap the service CIDR, Service ClusterIPs or node addresses
+	var clusterIPs []string
+	for _, service := range services.Items {
+		for _, ip := range service.Spec.ClusterIPs {
+			if ip != "" && ip != corev1.ClusterIPNone {
+				clusterIPs = append(clusterIPs, ip)
+			}
+		}
+	}
+	for _, pool := range pools {
+		if serviceCIDR != "" {
+			for _, cidr := range strings.Split(serviceCIDR, ",") {
+				if cidrsOverlap(pool.CIDR, cidr) {
+					problems = append(problems, fmt.Sprintf("%s %s overlaps the service CIDR %s", pool.Source, pool.CIDR, cidr))
+				}
+			}
+		}
+		for _, ip := range clusterIPs {
+			if cidrsContain([]string{pool.CIDR}, ip) {
+				problems = append(problems, fmt.Sprintf("%s %s contains Service ClusterIP %s", pool.Source, pool.CIDR, ip))
+				break
+			}
+		}
+		for _, node := range nodes.Items {
+			for _, address := range node.Status.Addresses {
+				if (address.Type == corev1.NodeInternalIP || address.Type == corev1.NodeExternalIP) && cidrsContain([]string{pool.CIDR}, address.Address) {
+					problems = append(problems, fmt.Sprintf("%s %s contains the address %s of node %s", pool.Source, pool.CIDR, address.Address, node.Name))
+				}
+			}
+		}
+	}
+
+	// Step 3: Pod IPs must be unique, outside the service range and inside a known pool
+	owners := map[string][]string{}
+	var outside []string
+	// Cloud CNIs hand out addresses of the node's network interfaces, which are in no pool
+	checkPools := cni.Name != CNIAWSVPC && cni.Name != CNIWeave && (cni.Name != CNICilium || t.ciliumPoolIPAM(ctx))
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.PodIP == "" {
+			continue
+		}
+		owners[pod.Status.PodIP] = append(owners[pod.Status.PodIP], fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
+		if serviceCIDR != "" && cidrsContain(strings.Split(serviceCIDR, ","), pod.Status.PodIP) {
+			problems = append(problems, fmt.Sprintf("pod %s/%s has IP %s inside the service CIDR", pod.Namespace, pod.Name, pod.Status.PodIP))
+		}
+		if len(pools) > 0 && checkPools {
+			inPool := false
+			for _, pool := range pools {
+				if cidrsContain([]string{pool.CIDR}, pod.Status.PodIP) {
+					inPool = true
+					break
+				}
+			}
+			if !inPool {
+				outside = append(outside, fmt.Sprintf("%s/%s (%s)", pod.Namespace, pod.Name, pod.Status.PodIP))
+			}
+		}
+	}
+	var duplicates []string
+	for ip, podNames := range owners {
+		if len(podNames) > 1 {
+			sort.Strings(podNames)
+			duplicates = append(duplicates, fmt.Sprintf("%s used by %s", ip, strings.Join(podNames, ", ")))
+		}
+	}
+	sort.Strings(duplicates)
+	for _, duplicate := range duplicates {
+		problems = append(problems, fmt.Sprintf("duplicate pod IP %s", duplicate))
+	}
+	if len(outside) > 0 {
+		problems = append(problems, fmt.Sprintf("%d pod IPs are outside every known pool, e.g. %s", len(outside), outside[0]))
+	}
+	if len(problems) == 0 {
+		details = append(details, fmt.Sprintf("✓ No overlaps between pod pools, services and node addresses; %d pod IPs unique and inside the pools", len(owners)))
+	}
+
+	// Step 4: Per-node free pod addresses
+	metrics := map[string]float64{}
+	allocations := t.collectNodeIPAM(ctx, cni, nodes.Items, pods.Items)
+	var exhausted []string
+	if len(allocations) > 0 {
+		details = append(details, "  Pod addresses per node:")
+		details = append(details, fmt.Sprintf("  %-30s %-20s %-10s %-10s %-10s %s", "NODE", "SOURCE", "CAPACITY", "USED", "MAX-PODS", "FREE"))
+		for _, allocation := range allocations {
+			free := allocation.Free()
+			marker := "✓"
+			if allocation.Capacity > 0 && float64(free) < ipamNearlyExhausted*float64(allocation.Capacity) {
+				marker = "⚠️"
+				exhausted = append(exhausted, allocation.Node)
+			}
+			capacity := fmt.Sprint(allocation.Capacity)
+			if allocation.Capacity == math.MaxInt32 {
+				capacity = "unbounded"
+			}
+			details = append(details, fmt.Sprintf("  %-30s %-20s %-10s %-10d %-10d %s %d", allocation.Node, allocation.Source, capacity,
+				allocation.Used, allocation.MaxPods, marker, free))
+			metrics[fmt.Sprintf("free_ips.%s", allocation.Node)] = float64(free)
+		}
+	} else {
+		details = append(details, fmt.Sprintf("ℹ️ %s allocates pod addresses from shared blocks - no per-node capacity to report", cni.Name))
+	}
+	if len(exhausted) > 0 {
+		details = append(details, fmt.Sprintf("⚠️ Nearly exhausted (under %.0f%% free): %s", ipamNearlyExhausted*100, strings.Join(exhausted, ", ")))
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("IPAM inconsistencies found: %d problems", len(problems)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "IPAM Sanity",
+				TechnicalError: strings.Join(problems, "; "),
+				TroubleshootingHints: []string{
+					"Pod ranges overlapping the service CIDR or node networks make traffic to those addresses route to the wrong place",
+					"Overlapping node podCIDRs or duplicate pod IPs usually follow a changed --cluster-cidr or a CNI migration that left stale allocations",
+					"Pod IPs outside every pool point at a second CNI or stale IPAM state on the node: check /var/lib/cni/networks",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Pod address allocation is consistent across %d pools and %d nodes", len(pools), len(nodes.Items)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
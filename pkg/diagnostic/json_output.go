@@ -0,0 +1,383 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// CommandOutputJSON represents a command execution result for JSON output
+type CommandOutputJSON struct {
+	Command     string `json:"command"`
+	ExitCode    int    `json:"exit_code"`
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr,omitempty"`
+	Duration    string `json:"duration,omitempty"`
+	Description string `json:"description"`
+}
+
+// NetworkContextJSON represents network diagnostic information for JSON output
+type NetworkContextJSON struct {
+	SourcePodIP    string            `json:"source_pod_ip,omitempty"`
+	TargetPodIP    string            `json:"target_pod_ip,omitempty"`
+	ServiceIP      string            `json:"service_ip,omitempty"`
+	SourceNode     string            `json:"source_node,omitempty"`
+	TargetNode     string            `json:"target_node,omitempty"`
+	RoutingInfo    []string          `json:"routing_info,omitempty"`
+	AdditionalInfo map[string]string `json:"additional_info,omitempty"`
+}
+
+// DetailedDiagnosticsJSON represents comprehensive diagnostic information for JSON output
+type DetailedDiagnosticsJSON struct {
+	FailureStage         string              `json:"failure_stage,omitempty"`
+	TechnicalError       string              `json:"technical_error,omitempty"`
+	CommandOutputs       []CommandOutputJSON `json:"command_outputs,omitempty"`
+	NetworkContext       *NetworkContextJSON `json:"network_context,omitempty"`
+	TroubleshootingHints []string            `json:"troubleshooting_hints,omitempty"`
+	KubectlCommands      []string            `json:"kubectl_commands,omitempty"`
+	HTTPResponses        []HTTPResponse      `json:"http_responses,omitempty"`
+	BPFMapPressure       []BPFMapUsage       `json:"bpf_map_pressure,omitempty"`
+	PacketCaptures       []PacketCapture     `json:"packet_captures,omitempty"`
+	Events               []TestEvent         `json:"events,omitempty"`
+}
+
+// TestResultJSON represents a single test result for JSON output
+type TestResultJSON struct {
+	TestNumber           int                      `json:"test_number"`
+	TestName             string                   `json:"test_name"`
+	Description          string                   `json:"description"`
+	Status               string                   `json:"status"`
+	SuccessMessage       string                   `json:"success_message,omitempty"`
+	SkipReason           string                   `json:"skip_reason,omitempty"`
+	Warnings             []string                 `json:"warnings,omitempty"`
+	ErrorMessage         string                   `json:"error_message,omitempty"`
+	Details              []string                 `json:"details"`
+	DetailedDiagnostics  *DetailedDiagnosticsJSON `json:"detailed_diagnostics,omitempty"`
+	StartTime            string                   `json:"start_time"`
+	EndTime              string                   `json:"end_time"`
+	ExecutionTimeSeconds float64                  `json:"execution_time_seconds"`
+	Placement            string                   `json:"placement,omitempty"`
+	LatencyMs            float64                  `json:"latency_ms,omitempty"`
+	ConnectivityType     string                   `json:"connectivity_type,omitempty"`
+	Metrics              *TestMetrics             `json:"metrics,omitempty"`
+}
+
+// ExecutionInfoJSON represents execution metadata
+type ExecutionInfoJSON struct {
+	Timestamp        string `json:"timestamp"`
+	RunID            string `json:"run_id,omitempty"`
+	Cluster          string `json:"cluster,omitempty"` // kubeconfig context or --cluster-name, keys the per-cluster view of report merge
+	Filename         string `json:"filename"`
+	Namespace        string `json:"namespace"`
+	KubeconfigSource string `json:"kubeconfig_source"`
+	VerboseMode      bool   `json:"verbose_mode"`
+	LogFile          string `json:"log_file,omitempty"`
+	KubeProxyMode    string `json:"kube_proxy_mode,omitempty"`
+}
+
+// SummaryJSON represents the overall test summary
+type SummaryJSON struct {
+	TotalTests                int      `json:"total_tests"`
+	Passed                    int      `json:"passed"`
+	Warnings                  int      `json:"warnings"`
+	Failed                    int      `json:"failed"`
+	Skipped                   int      `json:"skipped"`
+	OverallStatus             string   `json:"overall_status"`
+	TotalExecutionTimeSeconds float64  `json:"total_execution_time_seconds"`
+	ErrorsEncountered         []string `json:"errors_encountered"`
+	CompletionTime            string   `json:"completion_time"`
+}
+
+// DiagnosticReportJSON represents the complete JSON output structure
+type DiagnosticReportJSON struct {
+	SchemaVersion string            `json:"schema_version"`
+	ExecutionInfo ExecutionInfoJSON `json:"execution_info"`
+	Tests         []TestResultJSON  `json:"tests"`
+	Summary       SummaryJSON       `json:"summary"`
+	NodeNetwork   []NodeNetworkInfo `json:"node_network,omitempty"`
+}
+
+// TestDescriptions maps test names to their descriptions
+var TestDescriptions = map[string]string{
+	"Pod-to-Pod Connectivity":              "Validates direct pod communication across different worker nodes, testing CNI networking and inter-node communication",
+	"Service to Pod Connectivity":          "Validates Kubernetes service discovery, HTTP connectivity, and load balancing across multiple pod replicas",
+	"Cross-Node Service Connectivity":      "Validates kube-proxy inter-node routing by ensuring services work when accessed from pods on different nodes",
+	"DNS Resolution":                       "Comprehensively validates Kubernetes DNS infrastructure including service discovery, FQDN resolution, and DNS search domains",
+	"NodePort Range and Conflicts":         "Reads the cluster's NodePort range, reports its allocation by NodePort and LoadBalancer Services and node ports outside it, and lists host processes listening inside the range on every node, warning before the NodePort test is assigned a colliding port",
+	"ExternalDNS Record Publication":       "Annotates a LoadBalancer test service for external-dns and polls the configured DNS zone until the record resolves to the load balancer address within a time budget, reporting the propagation time",
+	"Cilium LB-IPAM LoadBalancer":          "Validates Cilium LB-IPAM by requesting a LoadBalancer address from a CiliumLoadBalancerIPPool and probing it from pod and host networks",
+	"TLS/HTTPS Connectivity":               "Validates HTTPS service connectivity including SNI, certificate chain verification, expiry and negotiated TLS protocol version",
+	"gRPC Connectivity":                    "Validates gRPC (HTTP/2) unary calls to an echo server over ClusterIP and through Ingress or Gateway API routes when present",
+	"Long-Lived Connection Idle Timeout":   "Opens TCP connections through a ClusterIP Service, keeps each idle for a configured period and then sends data, detecting conntrack or NAT entries that expire silently or reset idle connections",
+	"WebSocket and HTTP/2 Upgrade":         "Validates WebSocket upgrade handshakes, h2c prior-knowledge and HTTP/2 over TLS (ALPN) through ClusterIP, Ingress and Gateway paths",
+	"L4 Ingress Port Policies":             "Validates port-restricted ingress allow and deny policies by probing both the allowed and the denied port for each rule",
+	"L4 Egress Port Policies":              "Validates port-restricted egress allow and deny policies by probing both the allowed and the denied port for each rule",
+	"Default-Deny Allowlist Suite":         "Validates namespace isolation with default-deny ingress and egress, then verifies that each layered DNS, label and port allow rule opens exactly the intended flows",
+	"Namespace Isolation Policy":           "Validates namespaceSelector-based multi-tenant isolation: same-namespace traffic allowed, cross-namespace denied, then a single cross-namespace workload selectively allowed",
+	"Egress DNS Allow Policy":              "Validates that with egress default-deny, allowing only UDP/TCP 53 to kube-dns restores name resolution while all other egress remains blocked",
+	"Network Policy Propagation Latency":   "Measures per node how long a Cilium deny policy takes to block traffic after apply and to restore it after delete, reported as metrics in milliseconds",
+	"NetworkPolicy Ingress Conformance":    "Applies standard networking.k8s.io ingress policies (deny-all, podSelector, namespaceSelector, ports, ipBlock) one at a time and verifies allowed and denied flows on any policy-enforcing CNI",
+	"NetworkPolicy Egress Conformance":     "Applies standard networking.k8s.io egress policies (deny-all, podSelector with port, ipBlock with except) one at a time and verifies allowed and denied flows on any policy-enforcing CNI",
+	"Service Mesh L7 Authorization":        "Applies Istio AuthorizationPolicies or Linkerd Server/HTTPRoute/AuthorizationPolicy resources between test workloads in phases and verifies allow and deny decisions per client identity, HTTP method and path",
+	"Canary Weighted Routing":              "Configures a 90/10 weighted split between two backend versions with a Gateway API HTTPRoute on a Gateway or the Istio/Linkerd mesh and verifies the observed request distribution statistically, reporting the measured split",
+	"Service Mesh mTLS":                    "Detects Istio or Linkerd, runs a server and client in a meshed namespace requiring mTLS and verifies the client identity reaches the server while a pod without a sidecar is refused",
+	"Cilium Kube-Proxy Replacement":        "Detects Cilium kube-proxy replacement mode, verifies the agent service map contains the test service and validates hostPort handling and NodePort on secondary interfaces",
+	"Cilium Agent and Endpoint Health":     "Aggregates Cilium agent readiness, unhealthy endpoints, controller failures and node-to-node health-check results from every agent into a per-node report",
+	"Cilium Identity Resolution":           "Resolves the Cilium security identities of labelled test pods, verifies identity labels and flags init or reserved identities, shared identities and identity churn",
+	"Cilium BPF Map Pressure":              "Collects Cilium BPF map utilization (CT, NAT and policy maps) from every agent and flags maps above 90% utilization",
+	"Cilium BGP Control Plane":             "Detects Cilium BGP configuration, verifies BGP sessions are Established on each node and checks PodCIDR and LoadBalancer routes are advertised, reporting per-peer session state",
+	"Cilium CLI Connectivity Suite":        "Runs the upstream `cilium connectivity test` suite when the Cilium CLI is available and merges each scenario result from its JUnit report",
+	"Calico Node, BGP and Felix Health":    "Checks calico-node readiness, BGP sessions per node, IP pool encapsulation modes and recent Felix dataplane errors on Calico clusters",
+	"PVC Binding and Mount":                "Creates a PVC against the default or configured StorageClass, mounts it in a pod and writes and reads data, reporting binding, attach and mount latency and the failing stage",
+	"RWX Cross-Node Volume Access":         "Mounts a ReadWriteMany PVC from pods on two nodes, writes from both concurrently and verifies each node sees the other's data, reporting visibility latency",
+	"CSI Driver Health":                    "Checks CSI controller and node plugin pod readiness, CSIDriver registration in each node's CSINode and VolumeAttachments pending attach, stuck detaching or reporting errors",
+	"PVC Volume Expansion":                 "Expands a mounted PVC from a StorageClass with allowVolumeExpansion and verifies the filesystem grows inside the pod, timing the controller and filesystem resize phases",
+	"API Server Latency":                   "Measures GET, LIST and WATCH latency of small objects from the tool and GET and LIST from an in-cluster pod, reporting p50/p90/p99 and flagging latency above the usual range or the upstream SLOs",
+	"Admission Webhook Connectivity":       "Enumerates validating and mutating admission webhooks and verifies each service has ready endpoints, answers from inside the cluster and has no recent failed calls in Events",
+	"Control Plane Health":                 "Summarizes API server /readyz checks including etcd, etcd database size and rejected requests from the API server metrics, scheduler and controller-manager leader leases, and kube-system control-plane pods on self-managed clusters",
+	"Pod Scheduling Latency":               "Creates a batch of pause pods on each worker node and measures creation to Scheduled and Scheduled to Ready, reporting percentiles overall and per node to separate scheduler and kubelet delays from network problems",
+	"Certificate Expiry":                   "Inspects the API server serving certificate, each kubelet serving certificate and the cert-manager Certificates in the test namespace, flagging certificates that expire within the configured window",
+	"ServiceAccount Token Authentication":  "Checks that pod service account tokens are bound and expiring, authenticate to the API server from the pod, that TokenRequest issues working tokens, that projected tokens are refreshable in place and that tokens are invalidated with their pod",
+	"API Server Port-Forward":              "Opens a port-forward to an echo pod through the API server (SPDY upgrade via the kubelet, as kubectl port-forward and exec use) and verifies a payload sent through the tunnel is echoed back intact, reporting setup time and throughput",
+	"API Server Proxy Path":                "Requests a test service and one of its pods through the API server proxy subresource (API server to node to pod) and compares with direct service access from a pod, telling a broken proxy path apart from a broken service",
+	"Kubelet Port Audit":                   "Audits every node's kubelet: read-only port 10255 and healthz port 10248 exposure and anonymous access to 10250 from the pod network, and 10250 reachability through the API server, warning about insecure settings and inconsistencies between nodes",
+	"Deployment Rollout and Rollback":      "Performs a rolling update of an nginx deployment to a new image tag and rolls it back while a client pod sends continuous requests to its Service, failing on any failed request and reporting the downtime windows",
+	"HPA Scaling Responsiveness":           "Drives CPU load against a deployment behind a HorizontalPodAutoscaler and measures the time to the scale-up decision, to new replicas becoming Ready and to the Service sending them traffic",
+	"Pod Startup at Scale":                 "Creates a wave of pods across the nodes, deletes it and immediately creates a second wave, measuring scheduling, IP assignment and readiness latency under churn and detecting IPAM exhaustion from sandbox creation failures",
+	"Readiness Traffic Shifting":           "Fails one backend's readiness probe and restores it while a client sends continuous Service requests, timing the Ready condition, the EndpointSlice update and when the datapath stops and resumes sending the backend traffic",
+	"Namespace Create/Delete Churn":        "Creates and deletes labeled namespaces holding a ConfigMap, Service and pod, flags namespaces stuck Terminating with their blocking finalizers and deletion conditions, and reports existing stuck namespaces and failing API discovery",
+	"Latency Fault Injection":              "Adds a configured delay with tc netem on a test pod's interface and verifies the measured pod-to-pod RTT rises by the injected amount and returns to the baseline once the delay is removed",
+	"Packet Loss Fault Injection":          "Drops a configured percentage of a test pod's egress packets with tc netem and reports pod-to-pod packet loss and service request failures and latency under injection separately from the baseline",
+	"Node Isolation Simulation":            "Cordons a worker node and drops inbound traffic to the Service backend on it, verifies the endpoint is removed and the Service stops routing there, then rolls back and verifies the backend serves again",
+	"DNS Failure Injection":                "Blocks port 53 for a test pod with a NetworkPolicy or scales CoreDNS to zero, verifies an uncached lookup fails, times how long an application takes to see the failure, checks NodeLocal DNSCache still answers a cached name, then reverts and verifies DNS recovers",
+	"CNI Agent Restart Resilience":         "Deletes the CNI agent pod on the target node while a client pod pings the target every 200ms, waits for the replacement agent to become Ready and reports the longest connectivity interruption",
+	"Node Firewall Port Matrix":            "Checks that the ports the cluster needs between nodes (API server 6443, etcd, kubelet 10250, kube-proxy and Cilium health, the VXLAN/Geneve overlay, WireGuard 51871 and the NodePort range) are open for every node pair, reporting a per-port matrix with remediation hints",
+	"Host Firewall Policy":                 "Validates Cilium host policies by blocking a test port on one node while kubelet and other configured node services stay reachable, with automatic rollback",
+	"kube-dns Reachability Per Node":       "Queries the kube-dns ClusterIP from a probe pod on every worker node, and the CoreDNS endpoints directly where the ClusterIP fails, reporting a per-node table that localizes partial DNS outages",
+	"SNAT/Masquerade Validation":           "Connects from a pod to a host-network listener on another node that reports the observed source address, and checks pod IP versus node IP against ip-masq-agent, Cilium, Calico, Flannel or AWS VPC CNI masquerade settings",
+	"Cross-Zone Latency Matrix":            "Groups worker nodes by topology.kubernetes.io/zone, measures pod-to-pod latency within each zone and between every pair of zones, and flags cross-zone latency that is not higher than within-zone latency",
+	"Windows Node Connectivity":            "Runs client and HTTP server pods on a Linux worker and up to two Windows nodes and checks Linux to Windows, Windows to Linux and Windows to Windows pod and service connectivity, reporting a matrix; skipped on clusters without Windows nodes",
+	"Asymmetric Routing Detection":         "Pings and traceroutes between two worker nodes in both directions, at pod and host-network level, and flags one-way failures, replies routed out of a different interface than requests arrive on (with the effective rp_filter) and paths of different length",
+	"Native Routing Table Validation":      "For Cilium native routing, checks each node's routing table has a route to every other node's pod CIDR via that node; missing routes fail the test when auto-direct-node-routes is enabled",
+	"Overlay (VXLAN/Geneve) Health":        "For Cilium, Calico and flannel tunnel modes, checks the overlay interface exists on every node without a conflicting interface on its UDP port, that the port is reachable between every node pair and that cross-node pod traffic is encapsulated",
+	"Per-Node IP Exhaustion Early Warning": "Computes the pods each node can still start from its free pod addresses (node podCIDR or Cilium cluster-pool) and max-pods, and flags nodes below the threshold before exhaustion shows up as Pending pods",
+	"PodCIDR/IPAM Sanity":                  "Checks node podCIDRs do not overlap, IPAM pools do not overlap the service CIDR, Service ClusterIPs or node addresses, pod IPs are unique and inside a pool, and reports free pod addresses per node",
+	"Service IP Family Validation":         "Validates that services created with explicit ipFamilyPolicy/ipFamilies receive matching ClusterIPs and that each family is reachable",
+}
+
+// TimedTestResult represents a test result with timing information
+type TimedTestResult struct {
+	TestResult
+	Name      string // test name, e.g. "pod-to-pod"
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// SaveJSONReport saves the diagnostic report to a timestamped JSON file in test_results/
+func SaveJSONReport(report *DiagnosticReportJSON) error {
+	return saveJSONReport("test_results", "", report)
+}
+
+// saveJSONReport saves the diagnostic report to filename in testResultsDir, a timestamped file name when empty
+func saveJSONReport(testResultsDir, filename string, report *DiagnosticReportJSON) error {
+	// Create the results directory if it doesn't exist
+	if err := os.MkdirAll(testResultsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s directory: %v", testResultsDir, err)
+	}
+
+	// Create filename with timestamp
+	if filename == "" {
+		filename = fmt.Sprintf("k8s-diagnostic-results-%s.json",
+			time.Now().Format("20060102-150405"))
+	}
+
+	// Full path including directory
+	fullPath := fmt.Sprintf("%s/%s", testResultsDir, filename)
+
+	// Update filename in the report (just the filename, not the full path)
+	report.ExecutionInfo.Filename = filename
+
+	// Marshal to JSON with proper indentation
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+
+	// Write to file
+	err = os.WriteFile(fullPath, jsonData, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write JSON file %s: %v", fullPath, err)
+	}
+
+	return nil
+}
+
+// CreateJSONReport creates a DiagnosticReportJSON from test results
+func CreateJSONReport(
+	namespace string,
+	kubeconfigSource string,
+	verbose bool,
+	timedResults []TimedTestResult,
+	testNames []string,
+	startTime time.Time,
+	endTime time.Time,
+) DiagnosticReportJSON {
+
+	// Create execution info
+	executionInfo := ExecutionInfoJSON{
+		Timestamp:        startTime.Format(time.RFC3339),
+		Namespace:        namespace,
+		KubeconfigSource: kubeconfigSource,
+		VerboseMode:      verbose,
+	}
+
+	// Create test results
+	var jsonTests []TestResultJSON
+	var errorsEncountered []string
+	passedCount := 0
+	warningCount := 0
+	failedCount := 0
+	skippedCount := 0
+
+	for i, result := range timedResults {
+		testName := testNames[i]
+
+		// Determine status and messages
+		status := result.Status()
+		successMessage := ""
+		errorMessage := ""
+		skipReason := ""
+		var testDetails []string
+
+		// Convert DetailedDiagnostics to JSON format
+		var detailedDiagnosticsJSON *DetailedDiagnosticsJSON
+		if result.DetailedDiagnostics != nil {
+			// Convert CommandOutputs
+			var commandOutputsJSON []CommandOutputJSON
+			for _, cmd := range result.DetailedDiagnostics.CommandOutputs {
+				commandOutputsJSON = append(commandOutputsJSON, CommandOutputJSON{
+					Command:     cmd.Command,
+					ExitCode:    cmd.ExitCode,
+					Stdout:      cmd.Stdout,
+					Stderr:      cmd.Stderr,
+					Duration:    cmd.Duration,
+					Description: cmd.Description,
+				})
+			}
+
+			// Convert NetworkContext
+			var networkContextJSON *NetworkContextJSON
+			if result.DetailedDiagnostics.NetworkContext != nil {
+				networkContextJSON = &NetworkContextJSON{
+					SourcePodIP:    result.DetailedDiagnostics.NetworkContext.SourcePodIP,
+					TargetPodIP:    result.DetailedDiagnostics.NetworkContext.TargetPodIP,
+					ServiceIP:      result.DetailedDiagnostics.NetworkContext.ServiceIP,
+					SourceNode:     result.DetailedDiagnostics.NetworkContext.SourceNode,
+					TargetNode:     result.DetailedDiagnostics.NetworkContext.TargetNode,
+					RoutingInfo:    result.DetailedDiagnostics.NetworkContext.RoutingInfo,
+					AdditionalInfo: result.DetailedDiagnostics.NetworkContext.AdditionalInfo,
+				}
+			}
+
+			detailedDiagnosticsJSON = &DetailedDiagnosticsJSON{
+				FailureStage:         result.DetailedDiagnostics.FailureStage,
+				TechnicalError:       result.DetailedDiagnostics.TechnicalError,
+				CommandOutputs:       commandOutputsJSON,
+				NetworkContext:       networkContextJSON,
+				TroubleshootingHints: result.DetailedDiagnostics.TroubleshootingHints,
+				KubectlCommands:      result.DetailedDiagnostics.KubectlCommands,
+				HTTPResponses:        result.DetailedDiagnostics.HTTPResponses,
+				BPFMapPressure:       result.DetailedDiagnostics.BPFMapPressure,
+				PacketCaptures:       result.DetailedDiagnostics.PacketCaptures,
+				Events:               result.DetailedDiagnostics.Events,
+			}
+		}
+
+		if result.Skipped {
+			// Unmet preconditions are neither passes nor failures
+			successMessage = result.Message
+			skipReason = result.SkipReason()
+			skippedCount++
+			testDetails = result.Details
+		} else if result.Success {
+			successMessage = result.Message
+			if status == StatusWarning {
+				warningCount++
+			} else {
+				passedCount++
+			}
+			// For successful tests, include details if verbose mode is enabled; warnings keep theirs to explain them
+			if verbose || status == StatusWarning {
+				testDetails = result.Details
+			} else {
+				testDetails = []string{} // Empty details for successful tests in non-verbose mode
+			}
+		} else {
+			errorMessage = result.Message
+			errorsEncountered = append(errorsEncountered, fmt.Sprintf("Test %d (%s): %s", i+1, testName, result.Message))
+			failedCount++
+			// For failed tests, always include full details for debugging
+			testDetails = result.Details
+		}
+
+		// Get description
+		description := TestDescriptions[testName]
+		if description == "" {
+			description = fmt.Sprintf("Diagnostic test: %s", testName)
+		}
+
+		// Calculate execution time
+		executionTime := result.EndTime.Sub(result.StartTime).Seconds()
+
+		jsonTest := TestResultJSON{
+			TestNumber:           i + 1,
+			TestName:             testName,
+			Description:          description,
+			Status:               status,
+			SuccessMessage:       successMessage,
+			SkipReason:           skipReason,
+			Warnings:             result.Warnings,
+			ErrorMessage:         errorMessage,
+			Details:              testDetails,
+			DetailedDiagnostics:  detailedDiagnosticsJSON,
+			StartTime:            result.StartTime.Format(time.RFC3339),
+			EndTime:              result.EndTime.Format(time.RFC3339),
+			ExecutionTimeSeconds: executionTime,
+			Metrics:              reportMetrics(result.TestResult),
+		}
+
+		jsonTests = append(jsonTests, jsonTest)
+	}
+
+	// Determine overall status; warnings without failures leave a degraded but working cluster
+	overallStatus := StatusPassed
+	if failedCount > 0 {
+		overallStatus = StatusFailed
+	} else if warningCount > 0 {
+		overallStatus = StatusWarning
+	}
+
+	// Calculate total execution time
+	totalExecutionTime := endTime.Sub(startTime).Seconds()
+
+	// Create summary
+	summary := SummaryJSON{
+		TotalTests:                len(timedResults),
+		Passed:                    passedCount,
+		Warnings:                  warningCount,
+		Failed:                    failedCount,
+		Skipped:                   skippedCount,
+		OverallStatus:             overallStatus,
+		TotalExecutionTimeSeconds: totalExecutionTime,
+		ErrorsEncountered:         errorsEncountered,
+		CompletionTime:            endTime.Format(time.RFC3339),
+	}
+
+	return DiagnosticReportJSON{
+		SchemaVersion: ReportSchemaVersion,
+		ExecutionInfo: executionInfo,
+		Tests:         jsonTests,
+		Summary:       summary,
+	}
+}
@@ -0,0 +1,247 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// kubeDNSNodeQueries is how many queries each node's probe pod sends to the kube-dns ClusterIP
+	kubeDNSNodeQueries = 3
+	// kubeDNSProbeName is resolved by every probe; it exists in every cluster
+	kubeDNSProbeName = "kubernetes.default.svc.cluster.local"
+)
+
+// digQueryTimePattern extracts the query time dig reports
+var digQueryTimePattern = regexp.MustCompile(`Query time: (\d+) msec`)
+
+// kubeDNSNodeResult is the outcome of querying kube-dns from one node
+type kubeDNSNodeResult struct {
+	Node         string
+	ProbeReady   bool
+	Answered     int
+	QueryMs      []float64
+	Status       string // status of the last failed query
+	DirectOK     int
+	DirectTotal  int
+	DirectFailed []string
+}
+
+// kubeDNSTarget returns the ClusterIP of the kube-dns Service and the ready addresses of its endpoints
+func (t *Tester) kubeDNSTarget(ctx context.Context) (string, []string, error) {
+	services, err := t.clientset.CoreV1().Services("kube-system").List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=kube-dns"})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(services.Items) == 0 {
+		return "", nil, nil
+	}
+	service := services.Items[0]
+	slices, err := t.clientset.DiscoveryV1().EndpointSlices("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: "kubernetes.io/service-name=" + service.Name,
+	})
+	if err != nil {
+		return service.Spec.ClusterIP, nil, err
+	}
+	var endpoints []string
+	seen := map[string]bool{}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				if !seen[address] {
+					seen[address] = true
+					endpoints = append(endpoints, address)
+				}
+			}
+		}
+	}
+	return service.Spec.ClusterIP, endpoints, nil
+}
+
+// TestKubeDNSNodeReachability queries the kube-dns ClusterIP directly from a probe pod on every worker node and
+// reports a per-node table, localizing partial DNS outages to the nodes that cannot resolve. Nodes that fail through
+// the ClusterIP also query each CoreDNS endpoint directly, separating Service translation from pod network problems.
+func (t *Tester) TestKubeDNSNodeReachability(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: kube-dns Service and worker nodes
+	clusterIP, endpoints, err := t.kubeDNSTarget(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read the kube-dns Service: %v", err),
+			Details: details,
+		}
+	}
+	if clusterIP == "" {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "kube-dns reachability test skipped - no Service labeled k8s-app=kube-dns in kube-system",
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("ℹ️ kube-dns ClusterIP %s with %d ready endpoints", clusterIP, len(endpoints)))
+	if t.nodeLocalDNSAddress(ctx) != "" {
+		details = append(details, "ℹ️ NodeLocal DNSCache is installed - queries to the ClusterIP are answered by the cache on each node")
+	}
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(workerNodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "kube-dns reachability test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+
+	// Step 2: One probe pod per node
+	podNames := make([]string, len(workerNodes))
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, podName := range podNames {
+			if podName != "" {
+				t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+			}
+		}
+	}()
+	for i, node := range workerNodes {
+		podName := fmt.Sprintf("netshoot-dns-node-%d", i)
+		if _, err := t.createNetshootPod(ctx, podName, node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create probe pod on %s: %v", node, err),
+				Details: details,
+			}
+		}
+		podNames[i] = podName
+	}
+
+	// Step 3: Query the ClusterIP from every node, and the endpoints directly where it fails
+	results := make([]kubeDNSNodeResult, len(workerNodes))
+	var commandOutputs []CommandOutput
+	for i, node := range workerNodes {
+		result := kubeDNSNodeResult{Node: node}
+		if err := t.waitForPodReady(ctx, podNames[i], 60*time.Second); err != nil {
+			results[i] = result
+			continue
+		}
+		result.ProbeReady = true
+		for q := 0; q < kubeDNSNodeQueries; q++ {
+			query := t.digQuery(ctx, podNames[i], clusterIP, kubeDNSProbeName, fmt.Sprintf("Query kube-dns %s from %s", clusterIP, node))
+			if !query.Answered {
+				result.Status = query.Status
+				commandOutputs = append(commandOutputs, query.Output)
+				continue
+			}
+			result.Answered++
+			if match := digQueryTimePattern.FindStringSubmatch(query.Output.Stdout); match != nil {
+				if ms, err := strconv.ParseFloat(match[1], 64); err == nil {
+					result.QueryMs = append(result.QueryMs, ms)
+				}
+			}
+		}
+		if result.Answered < kubeDNSNodeQueries {
+			for _, endpoint := range endpoints {
+				result.DirectTotal++
+				if t.digQuery(ctx, podNames[i], endpoint, kubeDNSProbeName, fmt.Sprintf("Query CoreDNS endpoint %s from %s", endpoint, node)).Answered {
+					result.DirectOK++
+				} else {
+					result.DirectFailed = append(result.DirectFailed, endpoint)
+				}
+			}
+		}
+		results[i] = result
+	}
+
+	// Step 4: Per-node report
+	metrics := map[string]float64{}
+	var failures []string
+	var vipOnly []string
+	var unprobed []string
+	details = append(details, "  kube-dns reachability per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-12s %s", "NODE", "CLUSTERIP", "QUERY", "ENDPOINTS", "RESULT"))
+	for _, result := range results {
+		if !result.ProbeReady {
+			unprobed = append(unprobed, result.Node)
+			details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-12s %s", result.Node, "-", "-", "-", "⚠️ probe pod not ready"))
+			continue
+		}
+		query := "-"
+		if len(result.QueryMs) > 0 {
+			total := 0.0
+			for _, ms := range result.QueryMs {
+				total += ms
+			}
+			average := total / float64(len(result.QueryMs))
+			query = fmt.Sprintf("%.0fms", average)
+			metrics[fmt.Sprintf("query_ms.%s", result.Node)] = average
+		}
+		direct := "-"
+		if result.DirectTotal > 0 {
+			direct = fmt.Sprintf("%d/%d", result.DirectOK, result.DirectTotal)
+		}
+		marker := "✓"
+		switch {
+		case result.Answered == 0:
+			marker = fmt.Sprintf("✗ %s", result.Status)
+			failures = append(failures, result.Node)
+			if result.DirectTotal > 0 && result.DirectOK == result.DirectTotal {
+				vipOnly = append(vipOnly, result.Node)
+			}
+		case result.Answered < kubeDNSNodeQueries:
+			marker = fmt.Sprintf("⚠️ intermittent (%s)", result.Status)
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-10s %-10s %-12s %s",
+			result.Node, fmt.Sprintf("%d/%d", result.Answered, kubeDNSNodeQueries), query, direct, marker))
+	}
+	for _, result := range results {
+		if len(result.DirectFailed) > 0 {
+			details = append(details, fmt.Sprintf("✗ %s cannot query CoreDNS endpoints directly: %s", result.Node, strings.Join(result.DirectFailed, ", ")))
+		}
+	}
+	metrics["nodes"] = float64(len(workerNodes))
+	metrics["nodes_failed"] = float64(len(failures))
+	if len(unprobed) > 0 {
+		details = append(details, fmt.Sprintf("⚠️ Could not probe %d nodes (probe pod not ready): %s", len(unprobed), strings.Join(unprobed, ", ")))
+	}
+
+	if len(failures) > 0 {
+		hints := []string{
+			"Nodes failing through the ClusterIP but reaching every endpoint directly have broken Service translation - check kube-proxy (or the CNI's kube-proxy replacement) on those nodes",
+			"Nodes failing both ways cannot reach CoreDNS pods - check pod-to-pod connectivity from the node and host firewalls for UDP/TCP 53",
+			"Check the DNS pods and where they run: kubectl -n kube-system get pods -l k8s-app=kube-dns -o wide",
+		}
+		if len(vipOnly) > 0 {
+			hints = append([]string{fmt.Sprintf("Service translation suspected on: %s", strings.Join(vipOnly, ", "))}, hints...)
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("kube-dns %s unreachable from %d of %d nodes: %s", clusterIP, len(failures), len(workerNodes), strings.Join(failures, ", ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Per-Node kube-dns Reachability",
+				TechnicalError:       fmt.Sprintf("no answer from %s on %s", clusterIP, strings.Join(failures, ", ")),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("kube-dns %s answered from all %d probed nodes", clusterIP, len(workerNodes)-len(unprobed)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
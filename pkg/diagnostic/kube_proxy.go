@@ -0,0 +1,135 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kube-proxy modes reported by DetectKubeProxyMode
+const (
+	KubeProxyModeIPTables = "iptables"
+	KubeProxyModeIPVS     = "ipvs"
+	KubeProxyModeNFTables = "nftables"
+	KubeProxyModeReplaced = "replaced"
+	KubeProxyModeUnknown  = "unknown"
+)
+
+// KubeProxyInfo describes how Service traffic is programmed on the nodes
+type KubeProxyInfo struct {
+	Mode   string `json:"mode"`
+	Source string `json:"source,omitempty"`
+}
+
+// parseKubeProxyConfigMode returns the mode field of a KubeProxyConfiguration document
+func parseKubeProxyConfigMode(config string) string {
+	for _, line := range strings.Split(config, "\n") {
+		// Only the top-level field; nested keys are indented
+		if !strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "mode:")), `"'`)
+	}
+	return ""
+}
+
+// DetectKubeProxyMode determines whether kube-proxy runs in iptables, ipvs or nftables mode, or whether the CNI has
+// replaced it. The mode is read from the kube-proxy ConfigMap, then from the DaemonSet --proxy-mode flag, and
+// defaults to iptables as kube-proxy does on Linux. The result is cached on the Tester.
+func (t *Tester) DetectKubeProxyMode(ctx context.Context) KubeProxyInfo {
+	if t.kubeProxy != nil {
+		return *t.kubeProxy
+	}
+	info := t.detectKubeProxyMode(ctx)
+	t.kubeProxy = &info
+	return info
+}
+
+// detectKubeProxyMode performs the uncached detection for DetectKubeProxyMode
+func (t *Tester) detectKubeProxyMode(ctx context.Context) KubeProxyInfo {
+	daemonSet, err := t.clientset.AppsV1().DaemonSets("kube-system").Get(ctx, "kube-proxy", metav1.GetOptions{})
+	if err != nil {
+		if t.isCilium(ctx) {
+			if ciliumConfig, err := t.getCiliumConfig(ctx); err == nil && kubeProxyReplacementEnabled(ciliumConfig["kube-proxy-replacement"]) {
+				return KubeProxyInfo{
+					Mode:   KubeProxyModeReplaced,
+					Source: fmt.Sprintf("cilium-config kube-proxy-replacement=%s", ciliumConfig["kube-proxy-replacement"]),
+				}
+			}
+		}
+		return KubeProxyInfo{Mode: KubeProxyModeUnknown, Source: "no kube-proxy DaemonSet in kube-system"}
+	}
+
+	if configMap, err := t.clientset.CoreV1().ConfigMaps("kube-system").Get(ctx, "kube-proxy", metav1.GetOptions{}); err == nil {
+		for key, config := range configMap.Data {
+			if mode := parseKubeProxyConfigMode(config); mode != "" {
+				return KubeProxyInfo{Mode: strings.ToLower(mode), Source: fmt.Sprintf("configmap kube-system/kube-proxy %s", key)}
+			}
+		}
+	}
+
+	for _, container := range daemonSet.Spec.Template.Spec.Containers {
+		for _, arg := range append(container.Command, container.Args...) {
+			if mode, found := strings.CutPrefix(arg, "--proxy-mode="); found && mode != "" {
+				return KubeProxyInfo{Mode: strings.ToLower(mode), Source: "kube-proxy DaemonSet --proxy-mode flag"}
+			}
+		}
+	}
+
+	return KubeProxyInfo{Mode: KubeProxyModeIPTables, Source: "kube-proxy default (no mode configured)"}
+}
+
+// kubeProxyTroubleshootingHints returns service troubleshooting hints for the given kube-proxy mode
+func kubeProxyTroubleshootingHints(info KubeProxyInfo, serviceIP string) []string {
+	target := serviceIP
+	if target == "" {
+		target = "<cluster-ip>"
+	}
+	switch info.Mode {
+	case KubeProxyModeIPTables:
+		return []string{
+			fmt.Sprintf("Check the service chains on the node: iptables-save -t nat | grep %s", target),
+			"A KUBE-SVC-* chain without KUBE-SEP-* jumps means kube-proxy sees no ready endpoints",
+			"Check kube-proxy logs for sync errors: kubectl logs -n kube-system ds/kube-proxy",
+		}
+	case KubeProxyModeIPVS:
+		return []string{
+			fmt.Sprintf("Check the IPVS virtual server and its real servers on the node: ipvsadm -Ln | grep -A5 %s", target),
+			"Verify the ClusterIP is bound to kube-ipvs0 on the node: ip addr show kube-ipvs0",
+			"Verify the ip_vs kernel modules are loaded: lsmod | grep ip_vs",
+		}
+	case KubeProxyModeNFTables:
+		return []string{
+			fmt.Sprintf("Check the kube-proxy nftables rules on the node: nft list table ip kube-proxy | grep %s", target),
+			"nftables mode needs kernel 5.13+ and kube-proxy 1.29+; check kube-proxy logs for setup errors",
+		}
+	case KubeProxyModeReplaced:
+		return []string{
+			"kube-proxy is replaced by the CNI; check its service table: kubectl -n kube-system exec ds/cilium -- cilium-dbg service list",
+			fmt.Sprintf("Check the BPF load-balancer entries: kubectl -n kube-system exec ds/cilium -- cilium-dbg bpf lb list | grep %s", target),
+		}
+	}
+	return []string{
+		"No kube-proxy DaemonSet found; check which component implements Services (kube-proxy binary on the node, k3s, or the CNI)",
+	}
+}
+
+// serviceFailureDiagnostics builds the diagnostics for a failed ClusterIP/NodePort check, carrying the kube-proxy
+// mode in the network context and matching hints
+func (t *Tester) serviceFailureDiagnostics(ctx context.Context, stage, technicalError, serviceIP string) *DetailedDiagnostics {
+	kubeProxy := t.DetectKubeProxyMode(ctx)
+	return &DetailedDiagnostics{
+		FailureStage:   stage,
+		TechnicalError: technicalError,
+		NetworkContext: &NetworkContext{
+			ServiceIP: serviceIP,
+			AdditionalInfo: map[string]string{
+				"kube_proxy_mode":   kubeProxy.Mode,
+				"kube_proxy_source": kubeProxy.Source,
+			},
+		},
+		TroubleshootingHints: kubeProxyTroubleshootingHints(kubeProxy, serviceIP),
+	}
+}
@@ -0,0 +1,420 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kprHostPort is the hostPort exposed by the KPR test pod
+const kprHostPort = 18080
+
+// kprHostAddress is a global-scope address on a node interface
+type kprHostAddress struct {
+	Device  string
+	Address string
+}
+
+// kubeProxyReplacementEnabled reports whether a kube-proxy-replacement setting means Cilium serves services
+func kubeProxyReplacementEnabled(mode string) bool {
+	switch mode {
+	case "true", "strict", "partial", "probe":
+		return true
+	}
+	return false
+}
+
+// getCiliumAgentPod returns the name of the Cilium agent pod running on nodeName
+func (t *Tester) getCiliumAgentPod(ctx context.Context, nodeName string) (string, error) {
+	pods, err := t.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: "k8s-app=cilium",
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no Cilium agent pod found on node %s", nodeName)
+	}
+	return pods.Items[0].Name, nil
+}
+
+// execCiliumCLI runs a cilium CLI subcommand in an agent pod, using cilium-dbg where the image provides it
+func (t *Tester) execCiliumCLI(ctx context.Context, agentPod, args, description string) (CommandOutput, error) {
+	script := fmt.Sprintf("if command -v cilium-dbg >/dev/null 2>&1; then cilium-dbg %s; else cilium %s; fi", args, args)
+	return t.execInPodWithOutput(ctx, "kube-system", agentPod, "cilium-agent", []string{"sh", "-c", script}, description)
+}
+
+// createHostPortPod creates an nginx pod publishing port 80 on the node through a hostPort
+func (t *Tester) createHostPortPod(ctx context.Context, name, nodeName string, hostPort int32) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "kpr-hostport-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Name:  "nginx",
+					Image: t.image(nginxImage),
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: 80,
+							HostPort:      hostPort,
+							Protocol:      corev1.ProtocolTCP,
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// listHostAddresses returns the global-scope addresses of the node a hostNetwork pod runs on, skipping
+// interfaces created by the CNI and container runtimes
+func (t *Tester) listHostAddresses(ctx context.Context, hostPodName string) ([]kprHostAddress, CommandOutput) {
+	output, err := t.execInPodWithOutput(ctx, t.namespace, hostPodName, "netshoot",
+		[]string{"ip", "-o", "addr", "show", "scope", "global"}, "List node interface addresses")
+	if err != nil {
+		return nil, output
+	}
+
+	skipPrefixes := []string{"cilium_", "lxc", "docker", "cni", "veth", "kube-ipvs", "flannel", "vxlan", "genev"}
+	var addresses []kprHostAddress
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		// Example: 2: eth0    inet 172.18.0.2/16 brd 172.18.255.255 scope global eth0
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		device := strings.SplitN(fields[1], "@", 2)[0]
+		skip := false
+		for _, prefix := range skipPrefixes {
+			if strings.HasPrefix(device, prefix) {
+				skip = true
+				break
+			}
+		}
+		if skip {
+			continue
+		}
+		addresses = append(addresses, kprHostAddress{Device: device, Address: strings.SplitN(fields[3], "/", 2)[0]})
+	}
+	return addresses, output
+}
+
+// TestKubeProxyReplacement detects Cilium's kube-proxy replacement mode, verifies the agent's service map contains
+// the test service and checks the behaviours that differ from kube-proxy: hostPort handling and NodePort on
+// secondary interfaces
+func (t *Tester) TestKubeProxyReplacement(ctx context.Context) TestResult {
+	return t.TestKubeProxyReplacementWithConfig(ctx, TestConfig{})
+}
+
+// TestKubeProxyReplacementWithConfig checks kube-proxy replacement, probing hostPort and NodePort reachability with
+// the configured HTTP probe
+func (t *Tester) TestKubeProxyReplacementWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+	kprInfo := map[string]string{}
+
+	deploymentName := "web-kpr"
+	serviceName := "web-kpr"
+	clientPodName := "netshoot-kpr-test"
+	hostPodName := "netshoot-kpr-host"
+	hostPortPodName := "web-kpr-hostport"
+
+	// Step 1: Detect the KPR mode from the agent configuration
+	ciliumConfig, err := t.getCiliumConfig(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: "Kube-proxy replacement test requires Cilium - cilium-config ConfigMap not found",
+			Details: []string{fmt.Sprintf("✗ Could not read kube-system/cilium-config: %v", err)},
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Feature Detection",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"This test only applies to clusters running Cilium",
+				},
+			},
+		}
+	}
+	mode := ciliumConfig["kube-proxy-replacement"]
+	if mode == "" {
+		mode = "false"
+	}
+	kprInfo["kube_proxy_replacement"] = mode
+	for _, key := range []string{"enable-node-port", "enable-host-port", "bpf-lb-mode", "bpf-lb-sock", "enable-socket-lb", "devices"} {
+		if value, ok := ciliumConfig[key]; ok {
+			kprInfo[key] = value
+		}
+	}
+
+	_, kubeProxyErr := t.clientset.AppsV1().DaemonSets("kube-system").Get(ctx, "kube-proxy", metav1.GetOptions{})
+	kubeProxyPresent := kubeProxyErr == nil
+	kprInfo["kube_proxy_daemonset"] = fmt.Sprintf("%t", kubeProxyPresent)
+
+	if !kubeProxyReplacementEnabled(mode) {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Kube-proxy replacement test skipped - kube-proxy-replacement=%s, services are handled by kube-proxy", mode),
+			Details: []string{
+				fmt.Sprintf("ℹ️ kube-proxy-replacement=%s in cilium-config", mode),
+				fmt.Sprintf("ℹ️ kube-proxy DaemonSet present: %t", kubeProxyPresent),
+			},
+			DetailedDiagnostics: &DetailedDiagnostics{
+				NetworkContext: &NetworkContext{AdditionalInfo: kprInfo},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ kube-proxy-replacement=%s in cilium-config", mode))
+	if kubeProxyPresent {
+		details = append(details, "⚠️ kube-proxy DaemonSet still present - both kube-proxy and Cilium program services")
+	} else {
+		details = append(details, "✓ kube-proxy DaemonSet not present")
+	}
+
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(workerNodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Kube-proxy replacement test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+	targetNode := workerNodes[0]
+
+	cleanup := func() {
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, clientPodName)
+		t.cleanupPods(ctx, hostPodName, hostPortPodName)
+	}
+
+	// Step 2: Create the service, a hostPort pod and the probe pods
+	if _, err := t.createNginxDeployment(ctx, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create nginx deployment: %v", err),
+			Details: details,
+		}
+	}
+	service, err := t.createNginxServiceWithType(ctx, serviceName, deploymentName, ServiceTypeNodePort)
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create NodePort service: %v", err),
+			Details: details,
+		}
+	}
+	nodePort := int(service.Spec.Ports[0].NodePort)
+	if _, err := t.createHostPortPod(ctx, hostPortPodName, targetNode, kprHostPort); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create hostPort pod: %v", err),
+			Details: details,
+		}
+	}
+	if _, err := t.createHostNetworkPod(ctx, hostPodName, targetNode); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create host network pod: %v", err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNetshootPod(ctx, clientPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	for _, podName := range []string{hostPortPodName, hostPodName, clientPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	clientPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, clientPodName, metav1.GetOptions{})
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get pod %s: %v", clientPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ NodePort service '%s' (%s, node port %d) and hostPort pod on %s ready",
+		serviceName, service.Spec.ClusterIP, nodePort, targetNode))
+
+	var failures []string
+
+	// Step 3: The service must be in the agent's service map on the client's node and on the target node
+	frontend := fmt.Sprintf("%s:80", httpTargetForIP(service.Spec.ClusterIP))
+	agentNodes := []string{clientPod.Spec.NodeName}
+	if targetNode != clientPod.Spec.NodeName {
+		agentNodes = append(agentNodes, targetNode)
+	}
+	for _, nodeName := range agentNodes {
+		agentPod, err := t.getCiliumAgentPod(ctx, nodeName)
+		if err != nil {
+			details = append(details, fmt.Sprintf("✗ %v", err))
+			failures = append(failures, err.Error())
+			continue
+		}
+		output, err := t.execCiliumCLI(ctx, agentPod, "service list", fmt.Sprintf("Cilium service map on %s", nodeName))
+		commandOutputs = append(commandOutputs, output)
+		if err != nil || !strings.Contains(output.Stdout, frontend) {
+			details = append(details, fmt.Sprintf("✗ Service frontend %s missing from the service map of %s (%s)", frontend, agentPod, nodeName))
+			failures = append(failures, fmt.Sprintf("service %s not in Cilium service map on %s", frontend, nodeName))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Service frontend %s present in the service map of %s (%s)", frontend, agentPod, nodeName))
+		}
+		details = append(details, fmt.Sprintf("  kubectl -n kube-system exec %s -c cilium-agent -- cilium-dbg service list", agentPod))
+
+		if nodeName == targetNode {
+			statusOutput, err := t.execCiliumCLI(ctx, agentPod, "status --verbose", fmt.Sprintf("Cilium status on %s", nodeName))
+			commandOutputs = append(commandOutputs, statusOutput)
+			if err == nil {
+				for _, line := range strings.Split(statusOutput.Stdout, "\n") {
+					if strings.HasPrefix(strings.TrimSpace(line), "KubeProxyReplacement:") {
+						status := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "KubeProxyReplacement:"))
+						kprInfo["agent_status"] = status
+						details = append(details, fmt.Sprintf("ℹ️ Agent on %s reports KubeProxyReplacement: %s", nodeName, status))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Step 4: hostPort - handled by Cilium's BPF datapath under KPR instead of the portmap CNI plugin
+	nodeIP := ""
+	node, err := t.clientset.CoreV1().Nodes().Get(ctx, targetNode, metav1.GetOptions{})
+	if err == nil {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				nodeIP = address.Address
+				break
+			}
+		}
+	}
+	if nodeIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not determine the InternalIP of node %s", targetNode),
+			Details: details,
+		}
+	}
+	hostPortTarget := fmt.Sprintf("%s:%d", httpTargetForIP(nodeIP), kprHostPort)
+	statusCode, _, err := t.testHTTPConnectivityWithStatusCode(ctx, clientPodName, hostPortTarget, config.HTTP)
+	if ok, _ := config.HTTP.evaluate(statusCode); err == nil && ok {
+		details = append(details, fmt.Sprintf("✓ hostPort %d reachable on %s (%s), enable-host-port=%s",
+			kprHostPort, targetNode, nodeIP, kprInfo["enable-host-port"]))
+	} else {
+		details = append(details, fmt.Sprintf("✗ hostPort %d unreachable on %s (%s), enable-host-port=%s",
+			kprHostPort, targetNode, nodeIP, kprInfo["enable-host-port"]))
+		failures = append(failures, fmt.Sprintf("hostPort %d unreachable on %s", kprHostPort, nodeIP))
+	}
+	details = append(details, fmt.Sprintf("  curl -s -o /dev/null -w \"%%{http_code}\\n\" http://%s", hostPortTarget))
+
+	// Step 5: NodePort on every node interface - KPR only serves the devices it manages, kube-proxy serves all
+	addresses, addrOutput := t.listHostAddresses(ctx, hostPodName)
+	commandOutputs = append(commandOutputs, addrOutput)
+	var unmanaged []string
+	details = append(details, fmt.Sprintf("  NodePort %d reachability per interface on %s:", nodePort, targetNode))
+	details = append(details, fmt.Sprintf("  %-16s %-40s %s", "DEVICE", "ADDRESS", "RESULT"))
+	for _, address := range addresses {
+		target := fmt.Sprintf("%s:%d", httpTargetForIP(address.Address), nodePort)
+		statusCode, _, err := t.testHTTPConnectivityWithStatusCode(ctx, clientPodName, target, config.HTTP)
+		reachable, message := config.HTTP.evaluate(statusCode)
+		if err != nil {
+			reachable = false
+			message = fmt.Sprintf("error: %v", err)
+		}
+		marker := "✓"
+		if !reachable {
+			marker = "✗"
+			if address.Address == nodeIP {
+				failures = append(failures, fmt.Sprintf("NodePort %d unreachable on primary address %s", nodePort, nodeIP))
+			} else {
+				unmanaged = append(unmanaged, fmt.Sprintf("%s (%s)", address.Device, address.Address))
+			}
+		}
+		details = append(details, fmt.Sprintf("  %-16s %-40s %s %s", address.Device, address.Address, marker, message))
+	}
+	if len(addresses) == 0 {
+		details = append(details, "⚠️ Could not list node interfaces - NodePort per-interface check skipped")
+	} else if len(unmanaged) > 0 {
+		kprInfo["nodeport_unreachable_devices"] = strings.Join(unmanaged, ", ")
+		details = append(details, fmt.Sprintf("⚠️ NodePort not served on secondary interfaces %s - unlike kube-proxy, KPR only binds devices listed in 'devices'",
+			strings.Join(unmanaged, ", ")))
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up kube-proxy replacement test resources")
+
+	networkContext := &NetworkContext{
+		ServiceIP:      service.Spec.ClusterIP,
+		SourceNode:     clientPod.Spec.NodeName,
+		TargetNode:     targetNode,
+		AdditionalInfo: kprInfo,
+	}
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Kube-proxy replacement validation failed: %s", strings.Join(failures, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Kube-Proxy Replacement Validation",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				NetworkContext: networkContext,
+				TroubleshootingHints: []string{
+					"Check the agent's view of KPR: kubectl -n kube-system exec ds/cilium -- cilium-dbg status --verbose",
+					"A service missing from the service map usually means the agent lost its connection to the API server",
+					"hostPort under KPR requires enable-host-port (implicit with kube-proxy-replacement=true); otherwise the portmap CNI plugin must be chained",
+					"NodePort is only served on the devices Cilium attaches to; set the 'devices' Helm value to include secondary interfaces",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Kube-proxy replacement (%s) validated - service map, hostPort and NodePort behave as expected", mode),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+			NetworkContext: networkContext,
+		},
+	}
+}
@@ -0,0 +1,394 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"k8s.io/client-go/rest"
+)
+
+// maxRecordedManifest bounds the request bodies written into a transcript; larger manifests are left out
+const maxRecordedManifest = 16 * 1024
+
+// recordKubectl returns a copy of config whose clients report the kubectl equivalent of every API request to steps,
+// so the runner can give each test a transcript to reproduce it by hand
+func recordKubectl(config *rest.Config, steps *stepReporter) *rest.Config {
+	config = rest.CopyConfig(config)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &kubectlRecorder{next: rt, steps: steps}
+	})
+	return config
+}
+
+// kubectlRecorder translates the requests passing through it into kubectl commands
+type kubectlRecorder struct {
+	next  http.RoundTripper
+	steps *stepReporter
+}
+
+func (r *kubectlRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	command, manifest := kubectlEquivalent(req)
+	resp, err := r.next.RoundTrip(req)
+	if command == "" {
+		return resp, err
+	}
+
+	// The outcome goes on the first line, so a command with a manifest stays a valid heredoc
+	switch {
+	case err != nil:
+		command += "  # failed: " + strings.ReplaceAll(err.Error(), "\n", " ")
+	case resp.StatusCode/100 != 2:
+		command += "  # " + resp.Status
+	}
+	if manifest != "" {
+		command += "\n" + manifest + "\nEOF"
+	}
+	r.steps.recordCommand(command)
+	return resp, err
+}
+
+// apiTarget is the object addressed by an API server path
+type apiTarget struct {
+	group       string
+	namespace   string
+	resource    string
+	name        string
+	subresource string
+}
+
+// kubectlResource is the resource argument kubectl needs for the target, qualified with its API group
+func (a apiTarget) kubectlResource() string {
+	if a.group == "" {
+		return a.resource
+	}
+	return a.resource + "." + a.group
+}
+
+// parseAPIPath splits a resource path such as /apis/apps/v1/namespaces/default/deployments/web/scale. Discovery and
+// non-resource paths such as /readyz are not resource paths.
+func parseAPIPath(path string) (apiTarget, bool) {
+	var target apiTarget
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var rest []string
+	switch {
+	case segments[0] == "api" && len(segments) > 2:
+		rest = segments[2:]
+	case segments[0] == "apis" && len(segments) > 3:
+		target.group = segments[1]
+		rest = segments[3:]
+	default:
+		return target, false
+	}
+
+	if rest[0] == "namespaces" && len(rest) > 2 && rest[2] != "status" && rest[2] != "finalize" {
+		target.namespace = rest[1]
+		rest = rest[2:]
+	}
+	target.resource = rest[0]
+	if len(rest) > 1 {
+		target.name = rest[1]
+	}
+	if len(rest) > 2 {
+		target.subresource = strings.Join(rest[2:], "/")
+	}
+	return target, true
+}
+
+// kubectlEquivalent returns the kubectl command doing what req does and the manifest to pass on its stdin, if any.
+// Execs are not translated here: they are recorded with their output by execInPodStreaming.
+func kubectlEquivalent(req *http.Request) (string, string) {
+	target, ok := parseAPIPath(req.URL.Path)
+	if !ok {
+		// kubectl runs discovery by itself
+		if req.Method != http.MethodGet || strings.HasPrefix(req.URL.Path, "/api") {
+			return "", ""
+		}
+		return shellJoin("kubectl", "get", "--raw", req.URL.RequestURI()), ""
+	}
+	switch target.subresource {
+	case "exec", "attach", "portforward":
+		return "", ""
+	}
+
+	query := req.URL.Query()
+	resource := target.kubectlResource()
+	args := []string{"kubectl"}
+	scope := func() {
+		if target.name != "" {
+			args = append(args, target.name)
+		}
+		if target.namespace != "" {
+			args = append(args, "-n", target.namespace)
+		}
+	}
+	selectors := func() {
+		if target.namespace == "" && req.Method == http.MethodGet {
+			args = append(args, "-A")
+		}
+		if selector := query.Get("labelSelector"); selector != "" {
+			args = append(args, "-l", selector)
+		}
+		if selector := query.Get("fieldSelector"); selector != "" {
+			args = append(args, "--field-selector", selector)
+		}
+	}
+	body := requestBody(req)
+
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case target.subresource == "log":
+			args = append(args, "logs")
+			scope()
+			args = append(args, logFlags(query)...)
+		case target.subresource == "status" || target.subresource == "scale":
+			args = append(args, "get", resource)
+			scope()
+			args = append(args, "--subresource="+target.subresource, "-o", "yaml")
+		case target.subresource != "":
+			return shellJoin("kubectl", "get", "--raw", req.URL.RequestURI()), ""
+		case target.name != "":
+			args = append(args, "get", resource)
+			scope()
+			args = append(args, "-o", "yaml")
+		default:
+			args = append(args, "get", resource)
+			scope()
+			selectors()
+			if query.Get("watch") == "true" {
+				args = append(args, "--watch")
+			}
+			args = append(args, "-o", "yaml")
+		}
+		return shellJoin(args...), ""
+
+	case http.MethodPost:
+		switch {
+		case target.subresource == "token":
+			args = append(args, "create", "token")
+			scope()
+			args = append(args, tokenFlags(body)...)
+			return shellJoin(args...), ""
+		case target.subresource != "":
+			args = append(args, "create", "--raw", req.URL.RequestURI(), "-f", "-")
+		default:
+			args = append(args, "create", "-f", "-")
+		}
+		return withManifest(args, target, body)
+
+	case http.MethodPut:
+		switch target.subresource {
+		case "scale":
+			var scale struct {
+				Spec struct {
+					Replicas int32 `json:"replicas"`
+				} `json:"spec"`
+			}
+			if err := json.Unmarshal(body, &scale); err == nil {
+				args = append(args, "scale", resource)
+				scope()
+				args = append(args, fmt.Sprintf("--replicas=%d", scale.Spec.Replicas))
+				return shellJoin(args...), ""
+			}
+			args = append(args, "replace", "--subresource=scale", "-f", "-")
+		case "status":
+			args = append(args, "replace", "--subresource=status", "-f", "-")
+		case "":
+			args = append(args, "replace", "-f", "-")
+		default:
+			args = append(args, "replace", "--raw", req.URL.RequestURI(), "-f", "-")
+		}
+		return withManifest(args, target, body)
+
+	case http.MethodPatch:
+		patchType := map[string]string{
+			"application/json-patch+json":            "json",
+			"application/merge-patch+json":           "merge",
+			"application/strategic-merge-patch+json": "strategic",
+		}[req.Header.Get("Content-Type")]
+		if patchType == "" {
+			// Server-side apply
+			args = append(args, "apply", "--server-side")
+			if manager := query.Get("fieldManager"); manager != "" {
+				args = append(args, "--field-manager="+manager)
+			}
+			if query.Get("force") == "true" {
+				args = append(args, "--force-conflicts")
+			}
+			return withManifest(append(args, "-f", "-"), target, body)
+		}
+		args = append(args, "patch", resource)
+		scope()
+		if target.subresource != "" {
+			args = append(args, "--subresource="+target.subresource)
+		}
+		patch := string(body)
+		if target.resource == "secrets" {
+			patch = "REDACTED"
+		}
+		args = append(args, "--type="+patchType, "-p", patch)
+		return shellJoin(args...), ""
+
+	case http.MethodDelete:
+		if target.subresource != "" {
+			return shellJoin("kubectl", "delete", "--raw", req.URL.RequestURI()), ""
+		}
+		args = append(args, "delete", resource)
+		scope()
+		if target.name == "" {
+			selectors()
+			if query.Get("labelSelector") == "" && query.Get("fieldSelector") == "" {
+				args = append(args, "--all")
+			}
+		}
+		args = append(args, deleteFlags(body)...)
+		return shellJoin(args...), ""
+	}
+	return "", ""
+}
+
+// requestBody returns a copy of the body of req, leaving the body itself to the transport
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// withManifest completes a command reading body from a heredoc; secret data is masked and large or non-JSON bodies
+// are left out
+func withManifest(args []string, target apiTarget, body []byte) (string, string) {
+	command := shellJoin(args...)
+	if !json.Valid(body) {
+		return command + "  # request body not recorded", ""
+	}
+	if len(body) > maxRecordedManifest {
+		return command + fmt.Sprintf("  # manifest of %d bytes not recorded", len(body)), ""
+	}
+	if target.resource == "secrets" {
+		body = maskSecretData(body)
+	}
+	var manifest bytes.Buffer
+	if err := json.Indent(&manifest, body, "", "  "); err != nil {
+		return command + "  # request body not recorded", ""
+	}
+	return command + " <<'EOF'", manifest.String()
+}
+
+// maskSecretData replaces the values of a Secret manifest so keys and certificates stay out of the report
+func maskSecretData(body []byte) []byte {
+	var secret map[string]interface{}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return []byte(`"REDACTED"`)
+	}
+	for _, field := range []string{"data", "stringData"} {
+		if values, ok := secret[field].(map[string]interface{}); ok {
+			for key := range values {
+				values[key] = "REDACTED"
+			}
+		}
+	}
+	masked, err := json.Marshal(secret)
+	if err != nil {
+		return []byte(`"REDACTED"`)
+	}
+	return masked
+}
+
+// logFlags translates the query of a pod log request into kubectl logs flags
+func logFlags(query url.Values) []string {
+	var flags []string
+	get := query.Get
+	if container := get("container"); container != "" {
+		flags = append(flags, "-c", container)
+	}
+	if tail := get("tailLines"); tail != "" {
+		flags = append(flags, "--tail="+tail)
+	}
+	if since := get("sinceSeconds"); since != "" {
+		flags = append(flags, "--since="+since+"s")
+	}
+	if since := get("sinceTime"); since != "" {
+		flags = append(flags, "--since-time="+since)
+	}
+	if limit := get("limitBytes"); limit != "" {
+		flags = append(flags, "--limit-bytes="+limit)
+	}
+	if get("previous") == "true" {
+		flags = append(flags, "--previous")
+	}
+	if get("timestamps") == "true" {
+		flags = append(flags, "--timestamps")
+	}
+	return flags
+}
+
+// tokenFlags translates a TokenRequest into kubectl create token flags
+func tokenFlags(body []byte) []string {
+	var request struct {
+		Spec struct {
+			Audiences         []string `json:"audiences"`
+			ExpirationSeconds *int64   `json:"expirationSeconds"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil
+	}
+	var flags []string
+	for _, audience := range request.Spec.Audiences {
+		flags = append(flags, "--audience="+audience)
+	}
+	if request.Spec.ExpirationSeconds != nil {
+		flags = append(flags, fmt.Sprintf("--duration=%ds", *request.Spec.ExpirationSeconds))
+	}
+	return flags
+}
+
+// deleteFlags translates DeleteOptions into kubectl delete flags
+func deleteFlags(body []byte) []string {
+	var options struct {
+		GracePeriodSeconds *int64  `json:"gracePeriodSeconds"`
+		PropagationPolicy  *string `json:"propagationPolicy"`
+	}
+	if err := json.Unmarshal(body, &options); err != nil {
+		return nil
+	}
+	var flags []string
+	if options.GracePeriodSeconds != nil {
+		flags = append(flags, fmt.Sprintf("--grace-period=%d", *options.GracePeriodSeconds))
+	}
+	if options.PropagationPolicy != nil {
+		flags = append(flags, "--cascade="+strings.ToLower(*options.PropagationPolicy))
+	}
+	return flags
+}
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellJoin joins args into a command line that can be pasted into a shell
+func shellJoin(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafePattern.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,269 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kubelet ports checked by the kubelet port audit
+const (
+	kubeletReadOnlyPort = 10255
+	kubeletHealthzPort  = 10248
+	kubeletAPIPort      = 10250
+)
+
+// kubeletConfigz is the part of the kubelet's /configz the audit compares with what is reachable
+type kubeletConfigz struct {
+	KubeletConfig struct {
+		ReadOnlyPort       int    `json:"readOnlyPort"`
+		HealthzBindAddress string `json:"healthzBindAddress"`
+		Authentication     struct {
+			Anonymous struct {
+				Enabled *bool `json:"enabled"`
+			} `json:"anonymous"`
+		} `json:"authentication"`
+	} `json:"kubeletconfig"`
+}
+
+// kubeletPortAudit is what one node's kubelet exposes; every field but Node and Address is compared across nodes
+type kubeletPortAudit struct {
+	Node      string
+	Address   string
+	APIServer string // /healthz through the API server node proxy, the path logs, exec and metrics use
+	ReadOnly  string // port 10255 from the pod network
+	Healthz   string // port 10248 from the pod network
+	Anonymous string // what an anonymous request to port 10250 gets
+	Output    CommandOutput
+}
+
+// kubeletPortState classifies the HTTP status of a probe from the pod network
+func kubeletPortState(statusCode string) string {
+	switch {
+	case statusCode == "" || statusCode == "000":
+		return "closed"
+	case strings.HasPrefix(statusCode, "2"):
+		return "open"
+	}
+	return "HTTP " + statusCode
+}
+
+// kubeletAnonymousState classifies the answer of port 10250 to an anonymous request
+func kubeletAnonymousState(statusCode string) string {
+	switch statusCode {
+	case "", "000":
+		return "unreachable"
+	case "401":
+		return "rejected"
+	case "403":
+		return "authenticated, not authorized"
+	}
+	if strings.HasPrefix(statusCode, "2") {
+		return "allowed"
+	}
+	return "HTTP " + statusCode
+}
+
+// auditKubeletPorts probes the kubelet ports of one node from the pod network and through the API server
+func (t *Tester) auditKubeletPorts(ctx context.Context, podName string, node corev1.Node) kubeletPortAudit {
+	audit := kubeletPortAudit{Node: node.Name}
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP {
+			audit.Address = address.Address
+			break
+		}
+	}
+
+	body, err := t.clientset.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", node.Name, "proxy", "healthz").DoRaw(ctx)
+	t.steps.recordCommand(shellJoin("kubectl", "get", "--raw", fmt.Sprintf("/api/v1/nodes/%s/proxy/healthz", node.Name)))
+	switch {
+	case err != nil:
+		audit.APIServer = proxyErrorText(err)
+	case strings.TrimSpace(string(body)) == "ok":
+		audit.APIServer = "ok"
+	default:
+		audit.APIServer = strings.TrimSpace(string(body))
+	}
+
+	if audit.Address == "" {
+		audit.ReadOnly, audit.Healthz, audit.Anonymous = "no InternalIP", "no InternalIP", "no InternalIP"
+		return audit
+	}
+	var script []string
+	for _, url := range []string{
+		fmt.Sprintf("http://%s/healthz", net.JoinHostPort(audit.Address, fmt.Sprint(kubeletReadOnlyPort))),
+		fmt.Sprintf("http://%s/healthz", net.JoinHostPort(audit.Address, fmt.Sprint(kubeletHealthzPort))),
+		fmt.Sprintf("https://%s/healthz", net.JoinHostPort(audit.Address, fmt.Sprint(kubeletAPIPort))),
+	} {
+		script = append(script, fmt.Sprintf("curl -sk -o /dev/null -w '%%{http_code}\\n' --connect-timeout 2 --max-time 4 %s", url))
+	}
+	audit.Output, _ = t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"sh", "-c", strings.Join(script, "; ")},
+		fmt.Sprintf("Probe kubelet ports %d, %d and %d on %s", kubeletReadOnlyPort, kubeletHealthzPort, kubeletAPIPort, node.Name))
+	codes := append(strings.Fields(audit.Output.Stdout), "", "", "")
+	audit.ReadOnly = kubeletPortState(codes[0])
+	audit.Healthz = kubeletPortState(codes[1])
+	audit.Anonymous = kubeletAnonymousState(codes[2])
+	return audit
+}
+
+// kubeletConfig reads the kubelet configuration of a node through the API server, nil when /configz is unavailable
+func (t *Tester) kubeletConfig(ctx context.Context, nodeName string) *kubeletConfigz {
+	body, err := t.clientset.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", nodeName, "proxy", "configz").DoRaw(ctx)
+	if err != nil {
+		return nil
+	}
+	var config kubeletConfigz
+	if json.Unmarshal(body, &config) != nil {
+		return nil
+	}
+	return &config
+}
+
+// inconsistentValues groups the nodes by value and describes the groups when the nodes disagree
+func inconsistentValues(values map[string]string) string {
+	nodesByValue := map[string][]string{}
+	for node, value := range values {
+		nodesByValue[value] = append(nodesByValue[value], node)
+	}
+	if len(nodesByValue) < 2 {
+		return ""
+	}
+	var groups []string
+	for value, nodes := range nodesByValue {
+		sort.Strings(nodes)
+		groups = append(groups, fmt.Sprintf("%s on %s", value, strings.Join(nodes, ", ")))
+	}
+	sort.Strings(groups)
+	return strings.Join(groups, "; ")
+}
+
+// TestKubeletPorts audits every node's kubelet: the read-only port 10255 should be closed, the healthz port 10248
+// bound to localhost, and port 10250 reachable through the API server while rejecting anonymous requests. Insecure
+// settings and nodes that differ from the others are reported as warnings; a kubelet the API server cannot reach fails.
+func (t *Tester) TestKubeletPorts(ctx context.Context) TestResult {
+	var details []string
+	podName := "netshoot-kubelet-ports"
+
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+
+	// Step 1: Create the probe pod on the pod network
+	podName, reused, err := t.netshootClient(ctx, podName, "")
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create probe pod: %v", err),
+			Details: details,
+		}
+	}
+	defer t.cleanupPod(ctx, podName)
+	if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Probe pod did not become ready: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ %s probe pod '%s'", fixtureAction(reused), podName))
+
+	// Step 2: Probe every node
+	var audits []kubeletPortAudit
+	var commandOutputs []CommandOutput
+	for _, node := range nodes.Items {
+		audit := t.auditKubeletPorts(ctx, podName, node)
+		audits = append(audits, audit)
+		commandOutputs = append(commandOutputs, audit.Output)
+	}
+
+	details = append(details, "  Kubelet ports per node:")
+	details = append(details, fmt.Sprintf("  %-30s %-16s %-11s %-16s %-16s %s", "NODE", "ADDRESS", "API SERVER", "10255 (RO)", "10248 (HEALTHZ)", "10250 ANONYMOUS"))
+	var unreachable []string
+	var warnings []string
+	columns := map[string]map[string]string{"read-only port 10255": {}, "healthz port 10248": {}, "anonymous access to 10250": {}}
+	for _, audit := range audits {
+		apiServer := "ok"
+		if audit.APIServer != "ok" {
+			apiServer = "failed"
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-16s %-11s %-16s %-16s %s", audit.Node, audit.Address, apiServer, audit.ReadOnly, audit.Healthz, audit.Anonymous))
+		if audit.APIServer != "ok" {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%s)", audit.Node, audit.APIServer))
+		}
+		if audit.ReadOnly == "open" {
+			warnings = append(warnings, fmt.Sprintf("%s: read-only port %d is open - it serves pod specs and metrics without authentication", audit.Node, kubeletReadOnlyPort))
+		}
+		if audit.Healthz == "open" {
+			warnings = append(warnings, fmt.Sprintf("%s: healthz port %d is reachable from pods (healthzBindAddress is not localhost)", audit.Node, kubeletHealthzPort))
+		}
+		if audit.Anonymous == "allowed" {
+			warnings = append(warnings, fmt.Sprintf("%s: port %d answers anonymous requests - anonymous authentication with AlwaysAllow authorization", audit.Node, kubeletAPIPort))
+		}
+		columns["read-only port 10255"][audit.Node] = audit.ReadOnly
+		columns["healthz port 10248"][audit.Node] = audit.Healthz
+		columns["anonymous access to 10250"][audit.Node] = audit.Anonymous
+	}
+
+	// Step 3: The configuration nodes report should agree with what is reachable and between nodes
+	configured := map[string]string{}
+	for _, audit := range audits {
+		if config := t.kubeletConfig(ctx, audit.Node); config != nil {
+			configured[audit.Node] = fmt.Sprintf("readOnlyPort=%d healthzBindAddress=%s", config.KubeletConfig.ReadOnlyPort, config.KubeletConfig.HealthzBindAddress)
+			if anonymous := config.KubeletConfig.Authentication.Anonymous.Enabled; anonymous != nil && *anonymous {
+				details = append(details, fmt.Sprintf("ℹ️ %s: kubelet authentication.anonymous.enabled is true", audit.Node))
+			}
+		}
+	}
+	if len(configured) > 0 {
+		columns["kubelet configuration"] = configured
+	}
+	for _, name := range []string{"read-only port 10255", "healthz port 10248", "anonymous access to 10250", "kubelet configuration"} {
+		if groups := inconsistentValues(columns[name]); groups != "" {
+			warnings = append(warnings, fmt.Sprintf("%s differs between nodes: %s", name, groups))
+		}
+	}
+	for _, warning := range warnings {
+		details = append(details, "⚠️ "+warning)
+	}
+
+	if len(unreachable) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("API server cannot reach the kubelet on %d of %d nodes: %s", len(unreachable), len(audits), strings.Join(unreachable, ", ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Kubelet API Reachability",
+				TechnicalError: strings.Join(unreachable, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					fmt.Sprintf("kubectl logs, exec, port-forward and metrics-server need the API server to reach port %d on every node", kubeletAPIPort),
+					fmt.Sprintf("Check the firewall or security group allows TCP %d from the control plane to the nodes", kubeletAPIPort),
+					"Check the kubelet is running on the node: systemctl status kubelet",
+				},
+			},
+		}
+	}
+
+	message := fmt.Sprintf("Kubelet ports audited on %d nodes - API server reaches every kubelet", len(audits))
+	if len(warnings) > 0 {
+		message += fmt.Sprintf(", %d warning(s)", len(warnings))
+	}
+	return TestResult{
+		Success:  true,
+		Message:  message,
+		Details:  details,
+		Warnings: warnings,
+	}
+}
@@ -0,0 +1,261 @@
+package diagnostic
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Places the external address of the LoadBalancer test service is probed from
+const (
+	LoadBalancerProbeFromPod  = "pod"  // a hostNetwork pod, outside the pod network
+	LoadBalancerProbeFromHost = "host" // the machine running the CLI, outside the cluster
+)
+
+const (
+	// defaultLoadBalancerReadyTimeout bounds the wait for a cloud load balancer to pass its health checks and forward
+	defaultLoadBalancerReadyTimeout = 5 * time.Minute
+	// loadBalancerProbeInterval is the time between two probes of the external address while it is not ready yet
+	loadBalancerProbeInterval = 5 * time.Second
+)
+
+// LoadBalancer implementations recognised by detectLoadBalancerProvider
+const (
+	LoadBalancerProviderNone    = "none"
+	LoadBalancerProviderMetalLB = "metallb"
+	LoadBalancerProviderCloud   = "cloud"
+)
+
+var (
+	metalLBL2AdvertisementGVR  = schema.GroupVersionResource{Group: "metallb.io", Version: "v1beta1", Resource: "l2advertisements"}
+	metalLBBGPAdvertisementGVR = schema.GroupVersionResource{Group: "metallb.io", Version: "v1beta1", Resource: "bgpadvertisements"}
+)
+
+// detectLoadBalancerProvider looks for an in-cluster LoadBalancer implementation (MetalLB) or a cloud provider
+func (t *Tester) detectLoadBalancerProvider(ctx context.Context) (string, string) {
+	// MetalLB runs a controller and speakers, usually in metallb-system
+	pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		LabelSelector: "app=metallb",
+	})
+	if err == nil && len(pods.Items) > 0 {
+		return LoadBalancerProviderMetalLB, fmt.Sprintf("MetalLB detected (%d pods in namespace %s)", len(pods.Items), pods.Items[0].Namespace)
+	}
+	pods, err = t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		LabelSelector: "app.kubernetes.io/name=metallb",
+	})
+	if err == nil && len(pods.Items) > 0 {
+		return LoadBalancerProviderMetalLB, fmt.Sprintf("MetalLB detected (%d pods in namespace %s)", len(pods.Items), pods.Items[0].Namespace)
+	}
+
+	// Cloud controllers set a providerID on every node
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err == nil {
+		for _, node := range nodes.Items {
+			providerID := node.Spec.ProviderID
+			if providerID == "" || strings.HasPrefix(providerID, "kind://") {
+				continue
+			}
+			provider := strings.SplitN(providerID, "://", 2)[0]
+			return LoadBalancerProviderCloud, fmt.Sprintf("Cloud provider detected from node providerID (%s)", provider)
+		}
+	}
+
+	return LoadBalancerProviderNone, "No LoadBalancer implementation detected (no MetalLB pods, no cloud providerID on nodes)"
+}
+
+// waitForLoadBalancerIngress waits for a LoadBalancer service to receive an external IP or hostname
+func (t *Tester) waitForLoadBalancerIngress(ctx context.Context, serviceName string, timeout time.Duration) (string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		service, err := t.clientset.CoreV1().Services(t.namespace).Get(timeoutCtx, serviceName, metav1.GetOptions{})
+		if err == nil {
+			for _, ingress := range service.Status.LoadBalancer.Ingress {
+				if ingress.IP != "" {
+					return ingress.IP, nil
+				}
+				if ingress.Hostname != "" {
+					return ingress.Hostname, nil
+				}
+			}
+		}
+
+		select {
+		case <-timeoutCtx.Done():
+			return "", fmt.Errorf("service %s received no external IP or hostname within %v", serviceName, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// createHostNetworkPod creates a netshoot pod in the node's network namespace, optionally pinned to a node
+func (t *Tester) createHostNetworkPod(ctx context.Context, name, nodeName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "netshoot-hostnetwork-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:    nodeName,
+			HostNetwork: true,
+			DNSPolicy:   corev1.DNSClusterFirstWithHostNet,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+				},
+			},
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// checkMetalLBAnnouncement reports how MetalLB announces the service address (L2 or BGP) where detectable
+func (t *Tester) checkMetalLBAnnouncement(ctx context.Context, serviceName string) ([]string, bool) {
+	var findings []string
+	announced := false
+
+	// The speaker records which node answers ARP/NDP for the address in L2 mode
+	events, err := t.clientset.CoreV1().Events(t.namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Service", serviceName),
+	})
+	if err == nil {
+		for _, event := range events.Items {
+			switch event.Reason {
+			case "IPAllocated":
+				findings = append(findings, fmt.Sprintf("✓ MetalLB controller: %s", event.Message))
+			case "nodeAssigned":
+				findings = append(findings, fmt.Sprintf("✓ MetalLB speaker: %s", event.Message))
+				announced = true
+			case "AllocationFailed":
+				findings = append(findings, fmt.Sprintf("✗ MetalLB allocation failed: %s", event.Message))
+			}
+		}
+	}
+
+	if t.dynamicClient != nil {
+		if l2, err := t.dynamicClient.Resource(metalLBL2AdvertisementGVR).List(ctx, metav1.ListOptions{}); err == nil {
+			findings = append(findings, fmt.Sprintf("ℹ️ MetalLB L2Advertisements configured: %d", len(l2.Items)))
+		}
+		if bgp, err := t.dynamicClient.Resource(metalLBBGPAdvertisementGVR).List(ctx, metav1.ListOptions{}); err == nil {
+			findings = append(findings, fmt.Sprintf("ℹ️ MetalLB BGPAdvertisements configured: %d", len(bgp.Items)))
+			if len(bgp.Items) > 0 {
+				// BGP announcements are not visible as service events; trust the advertisement config
+				announced = true
+			}
+		}
+	}
+
+	return findings, announced
+}
+
+// hostHTTPProbe requests target with the HTTP probe options from the machine running the CLI and returns the status
+// code formatted like the pod probes
+func hostHTTPProbe(ctx context.Context, target string, options HTTPOptions) (string, error) {
+	connectTimeout, timeout := options.ConnectTimeout, options.Timeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultHTTPConnectTimeout
+	}
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:     (&net.Dialer{Timeout: connectTimeout}).DialContext,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	if !options.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	}
+
+	method := strings.ToUpper(options.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	request, err := http.NewRequestWithContext(ctx, method, options.url(target), nil)
+	if err != nil {
+		return "", err
+	}
+	for _, header := range options.Headers {
+		name, value, _ := strings.Cut(header, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Host") {
+			request.Host = strings.TrimSpace(value)
+			continue
+		}
+		request.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	return fmt.Sprintf("%03d", response.StatusCode), nil
+}
+
+// waitForExternalHTTP repeats probe until its status passes evaluate or timeout expires and returns the last status,
+// the time until the first passing response and the last failure
+func waitForExternalHTTP(ctx context.Context, timeout time.Duration, probe func() (string, error), evaluate func(string) (bool, string)) (string, time.Duration, string) {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		status, err := probe()
+		failure := ""
+		if err != nil {
+			failure = err.Error()
+		} else if ok, message := evaluate(status); ok {
+			return status, time.Since(start), ""
+		} else {
+			failure = message
+		}
+		if time.Now().Add(loadBalancerProbeInterval).After(deadline) || ctx.Err() != nil {
+			return status, time.Since(start), failure
+		}
+		sleepContext(ctx, loadBalancerProbeInterval)
+	}
+}
+
+// loadBalancerProbeSources returns the configured places to probe the external address from, both by default
+func loadBalancerProbeSources(config TestConfig) []string {
+	if len(config.LoadBalancerProbeFrom) == 0 {
+		return []string{LoadBalancerProbeFromPod, LoadBalancerProbeFromHost}
+	}
+	var sources []string
+	for _, source := range config.LoadBalancerProbeFrom {
+		if source = strings.ToLower(strings.TrimSpace(source)); !slices.Contains(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
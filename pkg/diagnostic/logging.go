@@ -43,7 +43,8 @@ type Logger struct {
 	timestampFmt  string
 	consoleOutput bool
 	minLevel      LogLevel
-	context       string // current context (e.g., test name, component)
+	context       string    // current context (e.g., test name, component)
+	redactor      *Redactor // masks the lines written to the log file, set by RedactFile
 }
 
 // NewLogger creates a new logger instance that writes to both console and file
@@ -62,8 +63,12 @@ func NewLoggerWithLevel(consoleOutput bool, level LogLevel) (*Logger, error) {
 	// Create timestamp-based filename (same format as JSON report)
 	timestamp := time.Now().Format("20060102-150405")
 	filename := fmt.Sprintf("k8s-diagnostic-logs-%s.log", timestamp)
-	fullPath := filepath.Join(logsDir, filename)
+	return NewLoggerToFile(consoleOutput, level, filepath.Join(logsDir, filename))
+}
 
+// NewLoggerToFile creates a logger with a specific minimum log level that writes to the given file, e.g. the log of
+// a run directory
+func NewLoggerToFile(consoleOutput bool, level LogLevel, fullPath string) (*Logger, error) {
 	// Open log file for writing
 	logFile, err := os.Create(fullPath)
 	if err != nil {
@@ -164,6 +169,9 @@ func (l *Logger) logWithLevel(level LogLevel, format string, args ...interface{}
 	}
 
 	// Write to log file
+	if l.redactor != nil {
+		logMessage = l.redactor.Redact(logMessage)
+	}
 	fmt.Fprintln(l.logFile, logMessage)
 }
 
@@ -223,9 +231,38 @@ func (l *Logger) LogNoTimestamp(format string, args ...interface{}) {
 	}
 
 	// Write to log file without timestamp
+	if l.redactor != nil {
+		message = l.redactor.Redact(message)
+	}
 	fmt.Fprint(l.logFile, message)
 }
 
+// RedactFile masks what the log file holds so far and every line written to it afterwards, e.g. the log of a run
+// directory meant to be shared. The console keeps the raw lines.
+func (l *Logger) RedactFile(redactor *Redactor) error {
+	if l.logFile == nil || redactor == nil {
+		return nil
+	}
+	if err := l.logFile.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %v", err)
+	}
+	content, err := os.ReadFile(l.logFilePath)
+	if err == nil {
+		err = os.WriteFile(l.logFilePath, []byte(redactor.Redact(string(content))), 0644)
+	}
+	// The file is reopened even when rewriting failed, so the rest of the run is still logged
+	logFile, openErr := os.OpenFile(l.logFilePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if openErr != nil {
+		return fmt.Errorf("failed to reopen log file: %v", openErr)
+	}
+	l.logFile = logFile
+	l.redactor = redactor
+	if err != nil {
+		return fmt.Errorf("failed to redact log file: %v", err)
+	}
+	return nil
+}
+
 // LogCommandExecution logs command execution details
 func (l *Logger) LogCommandExecution(command string, exitCode int, stdout string, stderr string, duration string) {
 	l.LogInfo("Command executed: %s", command)
@@ -0,0 +1,559 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Service meshes reported by detectMesh
+const (
+	MeshIstio   = "Istio"
+	MeshLinkerd = "Linkerd"
+	MeshNone    = "none"
+)
+
+var istioPeerAuthenticationGVR = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "peerauthentications"}
+
+// knownMeshControlPlanes selects the control plane Deployment of each supported mesh, checked in order
+var knownMeshControlPlanes = []struct {
+	name     string
+	selector string
+}{
+	{MeshIstio, "app=istiod"},
+	{MeshLinkerd, "linkerd.io/control-plane-component=destination"},
+}
+
+// meshProxyContainers are the sidecar containers each mesh injects into pods
+var meshProxyContainers = map[string]string{
+	MeshIstio:   "istio-proxy",
+	MeshLinkerd: "linkerd-proxy",
+}
+
+const (
+	// meshServerPort is the port of the echo server in the mTLS test
+	meshServerPort = 8080
+	// meshPolicyTimeout bounds the wait for the mesh to enforce mTLS on the server sidecar
+	meshPolicyTimeout = 30 * time.Second
+)
+
+// MeshInfo identifies the service mesh running in the cluster by its control plane, and whether it injects
+// sidecars into the test namespace
+type MeshInfo struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // namespace of the control plane
+	Revision  string `json:"revision,omitempty"`  // Istio control plane revision (istio.io/rev)
+	Injected  bool   `json:"injected"`            // sidecars are injected into pods of the test namespace
+}
+
+// detectMesh finds an Istio or Linkerd control plane and checks the injection settings of the test namespace. The
+// result is cached on the Tester; clusters without a known mesh report MeshNone.
+func (t *Tester) detectMesh(ctx context.Context) MeshInfo {
+	if t.mesh != nil {
+		return *t.mesh
+	}
+
+	detected := MeshInfo{Name: MeshNone}
+	for _, candidate := range knownMeshControlPlanes {
+		deployments, err := t.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: candidate.selector})
+		if err != nil || len(deployments.Items) == 0 {
+			continue
+		}
+		control := deployments.Items[0]
+		detected = MeshInfo{Name: candidate.name, Namespace: control.Namespace}
+		if candidate.name == MeshIstio {
+			detected.Revision = control.Labels["istio.io/rev"]
+		}
+		break
+	}
+	if detected.Name != MeshNone {
+		if ns, err := t.clientset.CoreV1().Namespaces().Get(ctx, t.namespace, metav1.GetOptions{}); err == nil {
+			detected.Injected = meshInjects(detected, ns)
+		}
+	}
+	t.mesh = &detected
+	return detected
+}
+
+// meshInjects reports whether the mesh injects sidecars into pods created in ns
+func meshInjects(mesh MeshInfo, ns *corev1.Namespace) bool {
+	switch mesh.Name {
+	case MeshIstio:
+		// istio-injection takes precedence over a revision label
+		if injection, ok := ns.Labels["istio-injection"]; ok {
+			return injection == "enabled"
+		}
+		return ns.Labels["istio.io/rev"] != ""
+	case MeshLinkerd:
+		return ns.Annotations["linkerd.io/inject"] == "enabled"
+	}
+	return false
+}
+
+// meshNamespaceMetadata returns the labels and annotations that enable or disable sidecar injection in a namespace
+func meshNamespaceMetadata(mesh MeshInfo, inject bool) (map[string]string, map[string]string) {
+	labels, annotations := map[string]string{}, map[string]string{}
+	switch mesh.Name {
+	case MeshIstio:
+		switch {
+		case !inject:
+			labels["istio-injection"] = "disabled"
+		case mesh.Revision != "" && mesh.Revision != "default":
+			labels["istio.io/rev"] = mesh.Revision
+		default:
+			labels["istio-injection"] = "enabled"
+		}
+	case MeshLinkerd:
+		annotations["linkerd.io/inject"] = "disabled"
+		if inject {
+			annotations["linkerd.io/inject"] = "enabled"
+			// Only meshed clients with an mTLS identity may reach the pods of the namespace
+			annotations["config.linkerd.io/default-inbound-policy"] = "all-authenticated"
+		}
+	}
+	return labels, annotations
+}
+
+// meshPodAnnotations returns the annotations of test pods in a meshed namespace. Istio starts the application
+// containers only after its proxy is ready, so a probe run as soon as the pod is Ready does not race the sidecar;
+// Linkerd does this by default.
+func meshPodAnnotations(mesh MeshInfo) map[string]string {
+	if mesh.Name == MeshIstio {
+		return map[string]string{"proxy.istio.io/config": `{"holdApplicationUntilProxyStarts": true}`}
+	}
+	return nil
+}
+
+// testPodAnnotations returns the annotations of test pods created in namespace, adapting them to an injecting mesh
+func (t *Tester) testPodAnnotations(ctx context.Context, namespace string) map[string]string {
+	if namespace != t.namespace {
+		return nil
+	}
+	if mesh := t.detectMesh(ctx); mesh.Injected {
+		return meshPodAnnotations(mesh)
+	}
+	return nil
+}
+
+// meshSidecar returns the mesh proxy container of pod, also when it runs as a native sidecar init container
+func meshSidecar(pod *corev1.Pod) string {
+	for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		for _, proxy := range meshProxyContainers {
+			if container.Name == proxy {
+				return proxy
+			}
+		}
+	}
+	return ""
+}
+
+// notReadyContainers lists the containers of a running pod that are not ready, marking mesh sidecars, so a pod
+// waiting for its proxy is told apart from a failing application
+func notReadyContainers(pod *corev1.Pod) []string {
+	var names []string
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		// Completed init containers are not ready by design
+		if status.Ready || status.State.Terminated != nil {
+			continue
+		}
+		name := status.Name
+		for _, proxy := range meshProxyContainers {
+			if name == proxy {
+				name += " (mesh sidecar)"
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// meshExcludedInboundPorts returns the inbound ports or port ranges the sidecar of pod does not intercept; traffic
+// to them bypasses the proxy and therefore mTLS
+func meshExcludedInboundPorts(pod *corev1.Pod) []string {
+	var excluded []string
+	for _, annotation := range []string{"traffic.sidecar.istio.io/excludeInboundPorts", "config.linkerd.io/skip-inbound-ports"} {
+		for _, port := range strings.Split(pod.Annotations[annotation], ",") {
+			if port = strings.TrimSpace(port); port != "" {
+				excluded = append(excluded, port)
+			}
+		}
+	}
+	return excluded
+}
+
+// portInRanges reports whether port is one of the ports or "low-high" ranges
+func portInRanges(port int, ranges []string) bool {
+	for _, r := range ranges {
+		low, high, isRange := strings.Cut(r, "-")
+		if !isRange {
+			high = low
+		}
+		lowPort, err1 := strconv.Atoi(low)
+		highPort, err2 := strconv.Atoi(high)
+		if err1 == nil && err2 == nil && port >= lowPort && port <= highPort {
+			return true
+		}
+	}
+	return false
+}
+
+// meshClientIdentity extracts the mTLS identity the server sidecar forwarded with a request from the echo server's
+// dump of the request headers: the URI of Istio's X-Forwarded-Client-Cert or Linkerd's l5d-client-id. Plaintext
+// requests carry neither.
+func meshClientIdentity(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		name, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(name) {
+		case "x-forwarded-client-cert":
+			for _, element := range strings.Split(value, ";") {
+				if uri, ok := strings.CutPrefix(element, "URI="); ok {
+					return uri
+				}
+			}
+			return value
+		case "l5d-client-id":
+			return value
+		}
+	}
+	return ""
+}
+
+// createMeshTestNamespace creates a namespace with sidecar injection enabled or disabled
+func (t *Tester) createMeshTestNamespace(ctx context.Context, name string, mesh MeshInfo, inject bool) error {
+	labels, annotations := meshNamespaceMetadata(mesh, inject)
+	_, err := t.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create namespace %s: %v", name, err)
+	}
+	return nil
+}
+
+// createMeshServer creates an echo server that dumps the request headers, and a Service in front of it so the
+// client sidecars route to it with mTLS rather than as passthrough traffic to a pod IP
+func (t *Tester) createMeshServer(ctx context.Context, namespace, name string, annotations map[string]string) error {
+	labels := map[string]string{"app": name}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "echo",
+					Image: t.image(upgradeEchoImage),
+					Env: []corev1.EnvVar{
+						{Name: "PORT", Value: strconv.Itoa(meshServerPort)},
+					},
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: meshServerPort,
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create server pod %s: %v", name, err)
+	}
+
+	appProtocol := "http"
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports: []corev1.ServicePort{
+				{
+					Name:        "http",
+					Port:        meshServerPort,
+					TargetPort:  intstr.FromInt(meshServerPort),
+					AppProtocol: &appProtocol,
+				},
+			},
+		},
+	}
+	if _, err := t.clientset.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create service %s: %v", name, err)
+	}
+	return nil
+}
+
+// createMeshClient creates a netshoot client pod in namespace, running as serviceAccount when set so mesh policies
+// can tell clients apart by their mTLS identity
+func (t *Tester) createMeshClient(ctx context.Context, namespace, name, serviceAccount string, annotations map[string]string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{"app": name},
+			Annotations: annotations,
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serviceAccount,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create client pod %s in namespace %s: %v", name, namespace, err)
+	}
+	return nil
+}
+
+// requireStrictMTLS applies an Istio PeerAuthentication that only accepts mTLS in namespace. Linkerd needs no
+// object: the default inbound policy of the meshed namespace already requires an authenticated client.
+func (t *Tester) requireStrictMTLS(ctx context.Context, mesh MeshInfo, namespace string) (string, error) {
+	if mesh.Name != MeshIstio {
+		return fmt.Sprintf("config.linkerd.io/default-inbound-policy=all-authenticated on namespace %s", namespace), nil
+	}
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "security.istio.io/v1beta1",
+		"kind":       "PeerAuthentication",
+		"metadata": map[string]interface{}{
+			"name":      "k8s-diagnostic-strict-mtls",
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"mtls": map[string]interface{}{"mode": "STRICT"},
+		},
+	}}
+	if _, err := t.dynamicClient.Resource(istioPeerAuthenticationGVR).Namespace(namespace).Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create PeerAuthentication in namespace %s: %v", namespace, err)
+	}
+	return fmt.Sprintf("PeerAuthentication %s/k8s-diagnostic-strict-mtls with mode STRICT", namespace), nil
+}
+
+// meshPlaintextDenied reports whether a probe status means the server sidecar refused a plaintext request: no HTTP
+// response (Istio resets the connection) or 403 (Linkerd's inbound policy)
+func meshPlaintextDenied(statusCode string) bool {
+	return statusCode == "" || statusCode == "000" || statusCode == "403"
+}
+
+// meshHint picks the troubleshooting hint for the detected mesh
+func meshHint(mesh MeshInfo, istio, linkerd string) string {
+	if mesh.Name == MeshLinkerd {
+		return linkerd
+	}
+	return istio
+}
+
+// TestMeshMTLS validates the service mesh dataplane: a meshed client reaches a meshed server over mTLS, and a client
+// without a sidecar is refused once the server namespace requires mTLS
+func (t *Tester) TestMeshMTLS(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Detect the mesh
+	mesh := t.detectMesh(ctx)
+	if mesh.Name == MeshNone {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Service mesh mTLS test skipped - no Istio or Linkerd control plane found",
+			Details: []string{"ℹ️ No istiod or linkerd-destination Deployment in the cluster"},
+		}
+	}
+	control := fmt.Sprintf("ℹ️ %s control plane in namespace %s", mesh.Name, mesh.Namespace)
+	if mesh.Revision != "" {
+		control += fmt.Sprintf(" (revision %s)", mesh.Revision)
+	}
+	details = append(details, control)
+	if mesh.Injected {
+		details = append(details, fmt.Sprintf("ℹ️ Sidecars are injected into the test namespace %s; test pods hold their containers until the proxy is ready", t.namespace))
+	} else {
+		details = append(details, fmt.Sprintf("ℹ️ Sidecar injection is not enabled in the test namespace %s", t.namespace))
+	}
+
+	suffix := time.Now().Unix()
+	meshedNamespace := fmt.Sprintf("%s-mesh-%d", t.namespace, suffix)
+	plainNamespace := fmt.Sprintf("%s-plain-%d", t.namespace, suffix)
+	serverName := "mesh-mtls-server"
+	meshedClientName := "mesh-mtls-client"
+	plainClientName := "plain-mtls-client"
+	target := fmt.Sprintf("%s.%s.svc.cluster.local:%d", serverName, meshedNamespace, meshServerPort)
+	sidecar := meshProxyContainers[mesh.Name]
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Namespaces().Delete(ctx, meshedNamespace, metav1.DeleteOptions{})
+		t.clientset.CoreV1().Namespaces().Delete(ctx, plainNamespace, metav1.DeleteOptions{})
+	}
+	fail := func(stage, message string, hints ...string) TestResult {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       message,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 2: Create a meshed namespace requiring mTLS and a namespace without injection
+	if err := t.createMeshTestNamespace(ctx, meshedNamespace, mesh, true); err != nil {
+		return fail("Namespace Setup", err.Error())
+	}
+	if err := t.createMeshTestNamespace(ctx, plainNamespace, mesh, false); err != nil {
+		return fail("Namespace Setup", err.Error())
+	}
+	details = append(details, fmt.Sprintf("✓ Created meshed namespace %s and plaintext namespace %s", meshedNamespace, plainNamespace))
+
+	policy, err := t.requireStrictMTLS(ctx, mesh, meshedNamespace)
+	if err != nil {
+		return fail("mTLS Policy", err.Error(),
+			"Check the Istio CRDs are installed: kubectl get crd peerauthentications.security.istio.io")
+	}
+	details = append(details, fmt.Sprintf("✓ Required mTLS with %s", policy))
+
+	// Step 3: Create the server and both clients
+	annotations := meshPodAnnotations(mesh)
+	if err := t.createMeshServer(ctx, meshedNamespace, serverName, annotations); err != nil {
+		return fail("Pod Creation", err.Error())
+	}
+	if err := t.createMeshClient(ctx, meshedNamespace, meshedClientName, "", annotations); err != nil {
+		return fail("Pod Creation", err.Error())
+	}
+	if err := t.createMeshClient(ctx, plainNamespace, plainClientName, "", nil); err != nil {
+		return fail("Pod Creation", err.Error())
+	}
+	pods := []struct{ namespace, name string }{
+		{meshedNamespace, serverName},
+		{meshedNamespace, meshedClientName},
+		{plainNamespace, plainClientName},
+	}
+	for _, pod := range pods {
+		if err := t.waitForPodReadyInNamespace(ctx, pod.namespace, pod.name, 180*time.Second); err != nil {
+			message := fmt.Sprintf("Pod %s did not become ready: %v", pod.name, err)
+			if current, getErr := t.clientset.CoreV1().Pods(pod.namespace).Get(ctx, pod.name, metav1.GetOptions{}); getErr == nil {
+				if notReady := notReadyContainers(current); len(notReady) > 0 {
+					message += fmt.Sprintf(" (not ready: %s)", strings.Join(notReady, ", "))
+				}
+			}
+			return fail("Pod Readiness", message,
+				fmt.Sprintf("Check the sidecar logs: kubectl logs -n %s %s -c %s", pod.namespace, pod.name, sidecar))
+		}
+	}
+
+	// Step 4: Check the sidecars were injected where expected and the server port is intercepted
+	server, err := t.clientset.CoreV1().Pods(meshedNamespace).Get(ctx, serverName, metav1.GetOptions{})
+	if err != nil {
+		return fail("Sidecar Injection", fmt.Sprintf("Failed to get pod %s: %v", serverName, err))
+	}
+	for _, pod := range pods {
+		current := server
+		if pod.name != serverName {
+			if current, err = t.clientset.CoreV1().Pods(pod.namespace).Get(ctx, pod.name, metav1.GetOptions{}); err != nil {
+				return fail("Sidecar Injection", fmt.Sprintf("Failed to get pod %s: %v", pod.name, err))
+			}
+		}
+		injected := meshSidecar(current) != ""
+		expected := pod.namespace == meshedNamespace
+		if injected != expected {
+			return fail("Sidecar Injection",
+				fmt.Sprintf("Pod %s/%s has sidecar=%t, expected %t", pod.namespace, pod.name, injected, expected),
+				fmt.Sprintf("Check the %s injector webhook: kubectl get mutatingwebhookconfigurations", mesh.Name),
+				fmt.Sprintf("Check the injection settings of the namespace: kubectl get namespace %s -o yaml", pod.namespace))
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ %s sidecar injected into the meshed pods and absent from %s", sidecar, plainClientName))
+
+	if excluded := meshExcludedInboundPorts(server); len(excluded) > 0 {
+		details = append(details, fmt.Sprintf("ℹ️ Inbound ports excluded from the sidecar of %s: %s", serverName, strings.Join(excluded, ",")))
+		if portInRanges(meshServerPort, excluded) {
+			cleanup()
+			return TestResult{
+				Success: true,
+				Skipped: true,
+				Message: fmt.Sprintf("Service mesh mTLS test skipped - the mesh does not intercept port %d of the test server", meshServerPort),
+				Details: append(details, fmt.Sprintf("⚠️ Port %d bypasses the sidecar, so neither mTLS nor plaintext denial can be checked on it", meshServerPort)),
+			}
+		}
+	}
+
+	// Step 5: The meshed client reaches the server with an mTLS identity
+	statusCode, body, err := t.testHTTPConnectivityWithNamespace(ctx, meshedClientName, meshedNamespace, target, HTTPOptions{})
+	if success, status := evaluateHTTPStatusCode(statusCode); err != nil || !success {
+		details = append(details, fmt.Sprintf("✗ Meshed client to %s: %s", target, status))
+		return fail("Meshed Connectivity", fmt.Sprintf("Meshed client could not reach the meshed server: %s", status),
+			fmt.Sprintf("Check the client proxy: kubectl logs -n %s %s -c %s", meshedNamespace, meshedClientName, sidecar),
+			fmt.Sprintf("Check the server proxy: kubectl logs -n %s %s -c %s", meshedNamespace, serverName, sidecar))
+	}
+	identity := meshClientIdentity(body)
+	if identity == "" {
+		details = append(details, "✗ Server received the request without a client identity header")
+		return fail("mTLS Verification", "Meshed request reached the server without mTLS - no client identity was forwarded",
+			meshHint(mesh,
+				fmt.Sprintf("Check the mTLS mode of the server: istioctl x describe pod -n %s %s", meshedNamespace, serverName),
+				fmt.Sprintf("Check the connection is secured: linkerd viz edges pod -n %s", meshedNamespace)))
+	}
+	details = append(details, fmt.Sprintf("✓ Meshed client reached the server over mTLS as %s", identity))
+
+	// Step 6: The plaintext client is refused; the policy can take a few seconds to reach the server sidecar
+	deadline := time.Now().Add(meshPolicyTimeout)
+	for {
+		statusCode, _, _ = t.testHTTPConnectivityWithNamespace(ctx, plainClientName, plainNamespace, target, HTTPOptions{})
+		if meshPlaintextDenied(statusCode) || time.Now().After(deadline) || ctx.Err() != nil {
+			break
+		}
+		sleepContext(ctx, 3*time.Second)
+	}
+	if !meshPlaintextDenied(statusCode) {
+		details = append(details, fmt.Sprintf("✗ Plaintext client got HTTP %s from the meshed server", statusCode))
+		return fail("Plaintext Denial", fmt.Sprintf("Plaintext request from a pod without a sidecar was accepted (HTTP %s) although the namespace requires mTLS", statusCode),
+			meshHint(mesh,
+				"Check for a PERMISSIVE PeerAuthentication or DestinationRule overriding the namespace: kubectl get peerauthentications -A",
+				fmt.Sprintf("Check the inbound policy of the server: linkerd diagnostics policy -n %s po/%s %d", meshedNamespace, serverName, meshServerPort)))
+	}
+	if statusCode == "403" {
+		details = append(details, "✓ Plaintext client denied with HTTP 403")
+	} else {
+		details = append(details, "✓ Plaintext client refused without an HTTP response")
+	}
+
+	cleanup()
+	details = append(details, fmt.Sprintf("✓ Cleaned up namespaces %s and %s", meshedNamespace, plainNamespace))
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%s mTLS verified - meshed pods communicate over mTLS and plaintext is denied", mesh.Name),
+		Details: details,
+	}
+}
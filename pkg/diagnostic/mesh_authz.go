@@ -0,0 +1,259 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// meshAuthzPolicyDirs holds the authorization policies of each mesh, applied in file order by the mesh-authz test
+var meshAuthzPolicyDirs = map[string]string{
+	MeshIstio:   "mesh-policies/istio-authorization",
+	MeshLinkerd: "mesh-policies/linkerd-authorization",
+}
+
+// meshAuthzProbe is one HTTP request checked against the server in every phase
+type meshAuthzProbe struct {
+	Name   string
+	Client string
+	Method string
+	Path   string
+}
+
+// meshAuthzPhase is a policy file applied on top of the previous phases and the probes expected to be allowed
+type meshAuthzPhase struct {
+	Name       string
+	PolicyFile string
+	Expected   map[string]bool
+}
+
+// meshAuthzOutcome classifies the status of a probe: allowed (2xx), denied by the mesh, or an error that never got
+// an authorization decision. Istio denies with 403; Linkerd also answers 404 when no HTTPRoute of the Server matches.
+func meshAuthzOutcome(mesh MeshInfo, statusCode string) string {
+	switch {
+	case strings.HasPrefix(statusCode, "2"):
+		return "allowed"
+	case statusCode == "403", mesh.Name == MeshLinkerd && statusCode == "404":
+		return "denied"
+	case statusCode == "" || statusCode == "000":
+		return "no response"
+	}
+	return "HTTP " + statusCode
+}
+
+// applyMeshPolicy applies the resources of a mesh policy file into namespace and returns their names
+func (t *Tester) applyMeshPolicy(ctx context.Context, namespace, policyFile string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", "apply", "-n", namespace, "-f", policyFile, "-o", "name")
+	t.steps.recordCommand(shellJoin(cmd.Args...))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply mesh policy %s: %v, output: %s", policyFile, err, output)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// runMeshAuthzProbes sends every probe from its client and returns the outcome per probe
+func (t *Tester) runMeshAuthzProbes(ctx context.Context, mesh MeshInfo, namespace, target string, probes []meshAuthzProbe) map[string]string {
+	outcomes := map[string]string{}
+	for _, probe := range probes {
+		statusCode, _, _ := t.testHTTPConnectivityWithNamespace(ctx, probe.Client, namespace, target,
+			HTTPOptions{Method: probe.Method, Path: probe.Path})
+		outcomes[probe.Name] = meshAuthzOutcome(mesh, statusCode)
+	}
+	return outcomes
+}
+
+// TestMeshAuthorizationPolicies applies Istio or Linkerd authorization policies between test workloads and verifies
+// the allow and deny decisions per identity, method and path
+func (t *Tester) TestMeshAuthorizationPolicies(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Detect the mesh
+	mesh := t.detectMesh(ctx)
+	if mesh.Name == MeshNone {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Mesh authorization policy test skipped - no Istio or Linkerd control plane found",
+			Details: []string{"ℹ️ No istiod or linkerd-destination Deployment in the cluster"},
+		}
+	}
+	policyDir := meshAuthzPolicyDirs[mesh.Name]
+	details = append(details, fmt.Sprintf("ℹ️ %s control plane in namespace %s; applying policies from %s", mesh.Name, mesh.Namespace, policyDir))
+
+	namespace := fmt.Sprintf("%s-mesh-authz-%d", t.namespace, time.Now().Unix())
+	serverName := "mesh-authz-server"
+	clientName := "mesh-authz-client"
+	otherName := "mesh-authz-other"
+	target := fmt.Sprintf("%s.%s.svc.cluster.local:%d", serverName, namespace, meshServerPort)
+	sidecar := meshProxyContainers[mesh.Name]
+
+	probes := []meshAuthzProbe{
+		{Name: "client GET /api/items", Client: clientName, Method: "GET", Path: "/api/items"},
+		{Name: "client POST /api/items", Client: clientName, Method: "POST", Path: "/api/items"},
+		{Name: "client GET /api/admin", Client: clientName, Method: "GET", Path: "/api/admin"},
+		{Name: "other GET /api/items", Client: otherName, Method: "GET", Path: "/api/items"},
+	}
+	phaseFiles := map[string][]string{
+		MeshIstio:   {"1-deny-all-policy.yaml", "2-allow-client-get-policy.yaml", "3-deny-admin-path-policy.yaml"},
+		MeshLinkerd: {"1-server-policy.yaml", "2-allow-client-get-policy.yaml", "3-protect-admin-route-policy.yaml"},
+	}[mesh.Name]
+	phases := []meshAuthzPhase{
+		{
+			Name:       "deny all",
+			PolicyFile: policyDir + "/" + phaseFiles[0],
+			Expected:   map[string]bool{},
+		},
+		{
+			Name:       "allow client GET",
+			PolicyFile: policyDir + "/" + phaseFiles[1],
+			Expected:   map[string]bool{"client GET /api/items": true, "client GET /api/admin": true},
+		},
+		{
+			Name:       "protect admin path",
+			PolicyFile: policyDir + "/" + phaseFiles[2],
+			Expected:   map[string]bool{"client GET /api/items": true},
+		},
+	}
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
+	}
+	fail := func(stage, message string, hints ...string) TestResult {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       message,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 2: Create the meshed namespace, the client identities and the workloads
+	if err := t.createMeshTestNamespace(ctx, namespace, mesh, true); err != nil {
+		return fail("Namespace Setup", err.Error())
+	}
+	for _, account := range []string{clientName, otherName} {
+		if _, err := t.clientset.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: account},
+		}, metav1.CreateOptions{}); err != nil {
+			return fail("Namespace Setup", fmt.Sprintf("Failed to create service account %s: %v", account, err))
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created meshed namespace %s with service accounts %s and %s", namespace, clientName, otherName))
+
+	annotations := meshPodAnnotations(mesh)
+	if err := t.createMeshServer(ctx, namespace, serverName, annotations); err != nil {
+		return fail("Pod Creation", err.Error())
+	}
+	for _, client := range []string{clientName, otherName} {
+		if err := t.createMeshClient(ctx, namespace, client, client, annotations); err != nil {
+			return fail("Pod Creation", err.Error())
+		}
+	}
+	for _, pod := range []string{serverName, clientName, otherName} {
+		if err := t.waitForPodReadyInNamespace(ctx, namespace, pod, 180*time.Second); err != nil {
+			return fail("Pod Readiness", fmt.Sprintf("Pod %s did not become ready: %v", pod, err),
+				fmt.Sprintf("Check the sidecar logs: kubectl logs -n %s %s -c %s", namespace, pod, sidecar))
+		}
+		current, err := t.clientset.CoreV1().Pods(namespace).Get(ctx, pod, metav1.GetOptions{})
+		if err != nil {
+			return fail("Sidecar Injection", fmt.Sprintf("Failed to get pod %s: %v", pod, err))
+		}
+		if meshSidecar(current) == "" {
+			return fail("Sidecar Injection", fmt.Sprintf("Pod %s has no %s sidecar - the policies would not be enforced", pod, sidecar),
+				fmt.Sprintf("Check the %s injector webhook: kubectl get mutatingwebhookconfigurations", mesh.Name))
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Server and clients ready with %s sidecars", sidecar))
+
+	// Step 3: Baseline - every request is allowed before any policy is applied
+	baseline := t.runMeshAuthzProbes(ctx, mesh, namespace, target, probes)
+	for _, probe := range probes {
+		if outcome := baseline[probe.Name]; outcome != "allowed" {
+			details = append(details, fmt.Sprintf("✗ Baseline %s: %s", probe.Name, outcome))
+			return fail("Baseline Connectivity", fmt.Sprintf("Baseline failed - %s was %s before any policy is applied", probe.Name, outcome),
+				meshHint(mesh,
+					"Check for existing AuthorizationPolicies in the mesh root namespace: kubectl get authorizationpolicies -A",
+					fmt.Sprintf("Check the inbound policy of the server: linkerd diagnostics policy -n %s po/%s %d", namespace, serverName, meshServerPort)))
+		}
+	}
+	details = append(details, "✓ Baseline: all requests allowed before any policy is applied")
+
+	// Step 4: Apply the phases cumulatively; each phase is given time to reach the server proxy before it counts
+	var mismatches []string
+	for _, phase := range phases {
+		applied, err := t.applyMeshPolicy(ctx, namespace, phase.PolicyFile)
+		if err != nil {
+			return fail("Policy Application", fmt.Sprintf("Failed to apply policies for phase %s: %v", phase.Name, err),
+				meshHint(mesh,
+					"Check the Istio CRDs are installed: kubectl get crd authorizationpolicies.security.istio.io",
+					"Check the Linkerd policy CRDs are installed: kubectl get crd servers.policy.linkerd.io httproutes.policy.linkerd.io"))
+		}
+		details = append(details, fmt.Sprintf("ℹ️ Phase '%s': applied %s", phase.Name, strings.Join(applied, ", ")))
+
+		var outcomes map[string]string
+		deadline := time.Now().Add(meshPolicyTimeout)
+		for {
+			outcomes = t.runMeshAuthzProbes(ctx, mesh, namespace, target, probes)
+			matched := true
+			for _, probe := range probes {
+				matched = matched && (outcomes[probe.Name] == "allowed") == phase.Expected[probe.Name]
+			}
+			if matched || time.Now().After(deadline) || ctx.Err() != nil {
+				break
+			}
+			sleepContext(ctx, 3*time.Second)
+		}
+
+		for _, probe := range probes {
+			expected := reachability(phase.Expected[probe.Name])
+			outcome := outcomes[probe.Name]
+			if outcome == expected {
+				details = append(details, fmt.Sprintf("✓ %s: %s %s as expected", phase.Name, probe.Name, outcome))
+			} else {
+				details = append(details, fmt.Sprintf("✗ %s: %s %s, expected %s", phase.Name, probe.Name, outcome, expected))
+				mismatches = append(mismatches, fmt.Sprintf("%s: %s expected %s but was %s", phase.Name, probe.Name, expected, outcome))
+			}
+		}
+	}
+
+	cleanup()
+	details = append(details, fmt.Sprintf("✓ Cleaned up namespace %s", namespace))
+
+	if len(mismatches) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Mesh authorization policies failed: %s", strings.Join(mismatches, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Authorization Verification",
+				TechnicalError: strings.Join(mismatches, "; "),
+				TroubleshootingHints: []string{
+					"A probe with no response or an unexpected status never got an authorization decision; check the server proxy logs",
+					meshHint(mesh,
+						"Check the policies reached the server proxy: istioctl proxy-status and istioctl x authz check",
+						"Check the policy of the server proxy: linkerd diagnostics policy po/mesh-authz-server 8080"),
+					fmt.Sprintf("See %s/README.md for the expected outcome of every phase", policyDir),
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%s authorization policies verified - identity, method and path rules allow and deny as expected", mesh.Name),
+		Details: details,
+	}
+}
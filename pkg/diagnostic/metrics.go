@@ -0,0 +1,148 @@
+package diagnostic
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportSchemaVersion is the version of the JSON report layout. Version 1 reports have no schema_version and a
+// flat metrics map; version 2 moved that map to metrics.values next to the typed latency, throughput, status code,
+// DNS and loss sections; version 3 added the SKIPPED test status with skip_reason and summary.skipped; version 4
+// added the WARNING test and overall status with per-test warnings and summary.warnings; version 5 added
+// execution_info.cluster.
+const ReportSchemaVersion = "5"
+
+// LatencyMetrics summarizes round-trip or request latency samples in milliseconds
+type LatencyMetrics struct {
+	Samples int     `json:"samples"`
+	MinMs   float64 `json:"min_ms"`
+	AvgMs   float64 `json:"avg_ms"`
+	P50Ms   float64 `json:"p50_ms"`
+	P90Ms   float64 `json:"p90_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// ThroughputMetrics is the rate of a transfer of a known size
+type ThroughputMetrics struct {
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	BitsPerSecond   float64 `json:"bits_per_second"`
+}
+
+// LossMetrics counts probes sent and answered
+type LossMetrics struct {
+	Sent        int     `json:"sent"`
+	Received    int     `json:"received"`
+	LossPercent float64 `json:"loss_pct"`
+}
+
+// DNSMetrics summarizes DNS queries and the resolver's answer times
+type DNSMetrics struct {
+	Queries  int             `json:"queries"`
+	Failures int             `json:"failures"`
+	Latency  *LatencyMetrics `json:"latency,omitempty"`
+}
+
+// TestMetrics holds the measurements of a test in a fixed schema, so tools reading the JSON report do not have to
+// parse the detail lines. Sections a test does not measure are left nil.
+type TestMetrics struct {
+	Latency     *LatencyMetrics    `json:"latency,omitempty"`
+	Throughput  *ThroughputMetrics `json:"throughput,omitempty"`
+	StatusCodes map[string]int     `json:"status_codes,omitempty"` // HTTP status code -> responses, "000" for no response
+	DNS         *DNSMetrics        `json:"dns,omitempty"`
+	Loss        *LossMetrics       `json:"loss,omitempty"`
+	Values      map[string]float64 `json:"values,omitempty"` // test-specific measurements from TestResult.Metrics
+}
+
+// pingSamplePattern matches the round-trip time of one ping reply, e.g. "time=0.412 ms"
+var pingSamplePattern = regexp.MustCompile(`time[=<]([0-9.]+) ?ms`)
+
+// dnsQueryTimePattern matches the answer time printed by dig, e.g. ";; Query time: 3 msec"
+var dnsQueryTimePattern = regexp.MustCompile(`;; Query time: (\d+) msec`)
+
+// latencyMetrics summarizes latency samples, or returns nil when there are none
+func latencyMetrics(samples []time.Duration) *LatencyMetrics {
+	if len(samples) == 0 {
+		return nil
+	}
+	milliseconds := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	var sum time.Duration
+	for _, sample := range samples {
+		sum += sample
+	}
+	return &LatencyMetrics{
+		Samples: len(samples),
+		MinMs:   milliseconds(latencyPercentile(samples, 0)),
+		AvgMs:   milliseconds(sum / time.Duration(len(samples))),
+		P50Ms:   milliseconds(latencyPercentile(samples, 50)),
+		P90Ms:   milliseconds(latencyPercentile(samples, 90)),
+		P99Ms:   milliseconds(latencyPercentile(samples, 99)),
+		MaxMs:   milliseconds(latencyPercentile(samples, 100)),
+	}
+}
+
+// pingMetrics parses ping output into the reply latencies and the packet loss; either is nil when the output does
+// not contain it
+func pingMetrics(output string) (*LatencyMetrics, *LossMetrics) {
+	var samples []time.Duration
+	for _, match := range pingSamplePattern.FindAllStringSubmatch(output, -1) {
+		if ms, err := strconv.ParseFloat(match[1], 64); err == nil {
+			samples = append(samples, time.Duration(ms*float64(time.Millisecond)))
+		}
+	}
+	var loss *LossMetrics
+	if match := pingLossPattern.FindStringSubmatch(output); match != nil {
+		sent, _ := strconv.Atoi(match[1])
+		received, _ := strconv.Atoi(match[2])
+		loss = &LossMetrics{Sent: sent, Received: received}
+		if sent > 0 {
+			loss.LossPercent = 100 * float64(sent-received) / float64(sent)
+		}
+	}
+	return latencyMetrics(samples), loss
+}
+
+// dnsMetrics parses the output of a series of dig queries; queries without a "Query time" line count as failures
+func dnsMetrics(output string, queries int) *DNSMetrics {
+	var samples []time.Duration
+	for _, match := range dnsQueryTimePattern.FindAllStringSubmatch(output, -1) {
+		if ms, err := strconv.Atoi(match[1]); err == nil {
+			samples = append(samples, time.Duration(ms)*time.Millisecond)
+		}
+	}
+	return &DNSMetrics{
+		Queries:  queries,
+		Failures: max(queries-len(samples), 0),
+		Latency:  latencyMetrics(samples),
+	}
+}
+
+// countStatusCode records one HTTP response by status code
+func (m *TestMetrics) countStatusCode(code string) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		code = "000"
+	}
+	if m.StatusCodes == nil {
+		m.StatusCodes = map[string]int{}
+	}
+	m.StatusCodes[code]++
+}
+
+// reportMetrics combines a result's typed metrics and test-specific values into the metrics section of the report
+func reportMetrics(result TestResult) *TestMetrics {
+	if result.TypedMetrics == nil && len(result.Metrics) == 0 {
+		return nil
+	}
+	metrics := TestMetrics{}
+	if result.TypedMetrics != nil {
+		metrics = *result.TypedMetrics
+	}
+	metrics.Values = result.Metrics
+	return &metrics
+}
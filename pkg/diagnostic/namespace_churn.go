@@ -0,0 +1,281 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// namespaceChurnCount is how many namespaces are created and deleted
+	namespaceChurnCount = 3
+	// namespaceChurnLabel marks the churn namespaces so leftovers of earlier runs can be found
+	namespaceChurnLabel = "k8s-diagnostic/namespace-churn"
+	// namespaceDeleteTimeout is how long a namespace may take to disappear before it counts as stuck
+	namespaceDeleteTimeout = 60 * time.Second
+	// namespaceStuckAge is how long a pre-existing namespace must have been Terminating to be reported as stuck
+	namespaceStuckAge = 5 * time.Minute
+)
+
+// namespaceChurnResult is the outcome of creating, populating and deleting one namespace
+type namespaceChurnResult struct {
+	Name     string
+	Populate time.Duration
+	Delete   time.Duration
+	Stuck    bool
+	Error    string
+}
+
+// populateChurnNamespace creates a ConfigMap, a Service and a pause pod in the namespace and waits for the pod to
+// run, so deletion has to tear down real content including a pod sandbox and endpoints
+func (t *Tester) populateChurnNamespace(ctx context.Context, namespace string) error {
+	labels := map[string]string{"app": "namespace-churn"}
+	if _, err := t.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "churn", Labels: labels},
+		Data:       map[string]string{"created-by": "k8s-diagnostic"},
+	}, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create ConfigMap: %v", err)
+	}
+	if _, err := t.clientset.CoreV1().Services(namespace).Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "churn", Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: labels,
+			Ports:    []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(80)}},
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create Service: %v", err)
+	}
+	if _, err := t.clientset.CoreV1().Pods(namespace).Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "churn", Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "pause",
+					Image: t.image(schedulingTestImage),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("1m"),
+							corev1.ResourceMemory: resource.MustParse("8Mi"),
+						},
+					},
+				},
+			},
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create pod: %v", err)
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+		pod, err := t.clientset.CoreV1().Pods(namespace).Get(ctx, "churn", metav1.GetOptions{})
+		if err == nil && pod.Status.Phase == corev1.PodRunning {
+			return nil
+		}
+		sleepContext(ctx, time.Second)
+	}
+	return fmt.Errorf("pod did not start running within 60s")
+}
+
+// churnNamespace creates a labeled namespace, populates it and deletes it, timing how long the namespace takes to
+// disappear
+func (t *Tester) churnNamespace(ctx context.Context) namespaceChurnResult {
+	namespace, err := t.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: t.namespace + "-churn-",
+			Labels: map[string]string{
+				namespaceChurnLabel:            "true",
+				"app.kubernetes.io/managed-by": "k8s-diagnostic",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return namespaceChurnResult{Error: fmt.Sprintf("failed to create namespace: %v", err)}
+	}
+	result := namespaceChurnResult{Name: namespace.Name}
+
+	start := time.Now()
+	if err := t.populateChurnNamespace(ctx, namespace.Name); err != nil {
+		// The namespace is still deleted and timed, deletion does not depend on the workload running
+		result.Error = err.Error()
+	}
+	result.Populate = time.Since(start)
+
+	start = time.Now()
+	if err := t.clientset.CoreV1().Namespaces().Delete(ctx, namespace.Name, metav1.DeleteOptions{}); err != nil {
+		result.Error = fmt.Sprintf("failed to delete namespace: %v", err)
+		return result
+	}
+	for time.Since(start) < namespaceDeleteTimeout && ctx.Err() == nil {
+		_, err := t.clientset.CoreV1().Namespaces().Get(ctx, namespace.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			result.Delete = time.Since(start)
+			return result
+		}
+		sleepContext(ctx, 500*time.Millisecond)
+	}
+	result.Stuck = true
+	result.Delete = time.Since(start)
+	return result
+}
+
+// namespaceBlockers describes what keeps a Terminating namespace from being removed: its finalizers and the
+// namespace controller's deletion conditions, which name remaining content and the finalizers on it
+func namespaceBlockers(namespace *corev1.Namespace) []string {
+	var blockers []string
+	if len(namespace.Spec.Finalizers) > 0 {
+		var finalizers []string
+		for _, finalizer := range namespace.Spec.Finalizers {
+			finalizers = append(finalizers, string(finalizer))
+		}
+		blockers = append(blockers, fmt.Sprintf("spec.finalizers: %s", strings.Join(finalizers, ", ")))
+	}
+	if len(namespace.Finalizers) > 0 {
+		blockers = append(blockers, fmt.Sprintf("metadata.finalizers: %s", strings.Join(namespace.Finalizers, ", ")))
+	}
+	for _, condition := range namespace.Status.Conditions {
+		if condition.Status == corev1.ConditionTrue {
+			blockers = append(blockers, fmt.Sprintf("%s: %s", condition.Type, condition.Message))
+		}
+	}
+	return blockers
+}
+
+// TestNamespaceChurn creates and deletes labeled namespaces holding a small workload, flagging namespaces that stay
+// Terminating together with the finalizers and content blocking them. Namespaces already stuck Terminating in the
+// cluster and API groups whose discovery fails, the usual cause, are reported as well.
+func (t *Tester) TestNamespaceChurn(ctx context.Context) TestResult {
+	var details []string
+	metrics := map[string]float64{}
+
+	// Step 1: Namespaces already stuck Terminating, including churn namespaces left behind by earlier runs
+	var preexisting []string
+	namespaces, err := t.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list namespaces: %v", err),
+			Details: details,
+		}
+	}
+	for i := range namespaces.Items {
+		namespace := &namespaces.Items[i]
+		if namespace.Status.Phase != corev1.NamespaceTerminating || namespace.DeletionTimestamp == nil {
+			continue
+		}
+		if age := time.Since(namespace.DeletionTimestamp.Time); age > namespaceStuckAge {
+			preexisting = append(preexisting, namespace.Name)
+			details = append(details, fmt.Sprintf("⚠️ Namespace %s has been Terminating for %v", namespace.Name, age.Round(time.Minute)))
+			for _, blocker := range namespaceBlockers(namespace) {
+				details = append(details, fmt.Sprintf("    %s", blocker))
+			}
+		}
+	}
+
+	// Step 2: Failing API discovery blocks the namespace controller from enumerating content to delete
+	var discoveryFailures []string
+	if _, _, err := t.clientset.Discovery().ServerGroupsAndResources(); err != nil && discovery.IsGroupDiscoveryFailedError(err) {
+		for groupVersion, groupErr := range err.(*discovery.ErrGroupDiscoveryFailed).Groups {
+			discoveryFailures = append(discoveryFailures, fmt.Sprintf("%s: %v", groupVersion.String(), groupErr))
+		}
+		sort.Strings(discoveryFailures)
+		for _, failure := range discoveryFailures {
+			details = append(details, fmt.Sprintf("⚠️ API discovery failed for %s", failure))
+		}
+	}
+
+	// Step 3: Create, populate and delete the churn namespaces concurrently
+	results := make([]namespaceChurnResult, namespaceChurnCount)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = t.churnNamespace(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	details = append(details, fmt.Sprintf("  %-36s %12s %12s %s", "NAMESPACE", "POPULATE", "DELETE", "STATUS"))
+	var deleteTimes []time.Duration
+	var stuck []string
+	var problems []string
+	for _, result := range results {
+		status := "deleted"
+		switch {
+		case result.Stuck:
+			status = "stuck Terminating"
+		case result.Name == "":
+			status = result.Error
+		default:
+			deleteTimes = append(deleteTimes, result.Delete)
+		}
+		details = append(details, fmt.Sprintf("  %-36s %12v %12v %s", result.Name, result.Populate.Round(time.Millisecond), result.Delete.Round(time.Millisecond), status))
+		if result.Error != "" && result.Name != "" {
+			details = append(details, fmt.Sprintf("ℹ️ %s: %s", result.Name, result.Error))
+		}
+		if result.Name == "" {
+			problems = append(problems, result.Error)
+		}
+		if !result.Stuck {
+			continue
+		}
+		stuck = append(stuck, result.Name)
+		namespace, err := t.clientset.CoreV1().Namespaces().Get(ctx, result.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		blockers := namespaceBlockers(namespace)
+		for _, blocker := range blockers {
+			details = append(details, fmt.Sprintf("✗ %s blocked by %s", result.Name, blocker))
+		}
+		problems = append(problems, fmt.Sprintf("%s stuck Terminating (%s)", result.Name, strings.Join(blockers, "; ")))
+	}
+	if len(deleteTimes) > 0 {
+		metrics["delete_p50_ms"] = float64(latencyPercentile(deleteTimes, 50).Milliseconds())
+		metrics["delete_max_ms"] = float64(latencyPercentile(deleteTimes, 100).Milliseconds())
+	}
+	metrics["stuck_namespaces"] = float64(len(stuck))
+	metrics["preexisting_stuck_namespaces"] = float64(len(preexisting))
+
+	if len(problems) > 0 {
+		hints := []string{
+			"Namespace deletion waits for every resource in it - NamespaceContentRemaining/NamespaceFinalizersRemaining name the resources and finalizers still present",
+			"Resource finalizers are removed by their controller; a missing or crashing controller (e.g. an uninstalled operator) leaves them forever",
+			"NamespaceDeletionDiscoveryFailure means an APIService is unavailable: kubectl get apiservices | grep False",
+			fmt.Sprintf("Inspect a stuck namespace: kubectl get namespace <name> -o yaml; list leftover churn namespaces: kubectl get namespaces -l %s", namespaceChurnLabel),
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d churn namespaces failed or stuck Terminating", len(problems), namespaceChurnCount),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Namespace Deletion",
+				TechnicalError:       strings.Join(problems, "; "),
+				TroubleshootingHints: append(hints, discoveryFailures...),
+			},
+		}
+	}
+
+	details = append(details, fmt.Sprintf("✓ %d namespaces created, populated and deleted (max %v)", namespaceChurnCount, latencyPercentile(deleteTimes, 100).Round(time.Millisecond)))
+	message := fmt.Sprintf("%d namespaces deleted cleanly (p50 %v)", namespaceChurnCount, latencyPercentile(deleteTimes, 50).Round(time.Millisecond))
+	if len(preexisting) > 0 {
+		message += fmt.Sprintf(", but %d existing namespaces are stuck Terminating", len(preexisting))
+	}
+	return TestResult{
+		Success: true,
+		Message: message,
+		Details: details,
+		Metrics: metrics,
+	}
+}
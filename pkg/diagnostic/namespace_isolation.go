@@ -0,0 +1,276 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tenantLabelKey marks namespaces as tenants for the namespace isolation policies
+const tenantLabelKey = "diagnostic-tenant"
+
+// namespaceIsolationProbe is one source pod checked against the web pod in every phase
+type namespaceIsolationProbe struct {
+	Name      string
+	Namespace string
+	Pod       string
+}
+
+// namespaceIsolationPhase is a set of applied policies and the sources expected to reach the web pod
+type namespaceIsolationPhase struct {
+	Name       string
+	PolicyFile string
+	Expected   map[string]bool
+}
+
+// setNamespaceLabel sets a label on a namespace and returns a function restoring the previous state
+func (t *Tester) setNamespaceLabel(ctx context.Context, namespace, key, value string) (func(), error) {
+	ns, err := t.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %v", namespace, err)
+	}
+	previous, existed := ns.Labels[key]
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels[key] = value
+	if _, err := t.clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to label namespace %s: %v", namespace, err)
+	}
+
+	return func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		ns, err := t.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+		if err != nil {
+			return
+		}
+		if existed {
+			ns.Labels[key] = previous
+		} else {
+			delete(ns.Labels, key)
+		}
+		t.clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+	}, nil
+}
+
+// TestNamespaceIsolation validates namespace-selector policies: same-tenant traffic allowed, cross-tenant denied,
+// then a single cross-tenant workload selectively allowed
+func (t *Tester) TestNamespaceIsolation(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	tenantBNamespace := fmt.Sprintf("%s-tenant-b-%d", t.namespace, time.Now().Unix())
+	webPodName := "web-ns-isolation-test"
+	localClientName := "client-ns-isolation-test"
+	remoteClientName := "client-ns-isolation-test"
+	remoteOtherName := "other-ns-isolation-test"
+	policyDir := "cilium-policies/10-namespace-isolation"
+
+	probes := []namespaceIsolationProbe{
+		{Name: "tenant-a client", Namespace: t.namespace, Pod: localClientName},
+		{Name: "tenant-b client", Namespace: tenantBNamespace, Pod: remoteClientName},
+		{Name: "tenant-b other", Namespace: tenantBNamespace, Pod: remoteOtherName},
+	}
+	phases := []namespaceIsolationPhase{
+		{
+			Name:       "isolation",
+			PolicyFile: policyDir + "/1-tenant-isolation-policy.yaml",
+			Expected:   map[string]bool{"tenant-a client": true},
+		},
+		{
+			Name:       "selective allow",
+			PolicyFile: policyDir + "/2-allow-tenant-b-client-policy.yaml",
+			Expected:   map[string]bool{"tenant-a client": true, "tenant-b client": true},
+		},
+	}
+
+	var appliedPolicies []string
+	restoreTenantLabel := func() {}
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for i := len(appliedPolicies) - 1; i >= 0; i-- {
+			if err := t.deleteNetworkPolicy(ctx, appliedPolicies[i]); err != nil {
+				details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+			}
+		}
+		appliedPolicies = nil
+		t.cleanupPods(ctx, webPodName, localClientName)
+		t.clientset.CoreV1().Namespaces().Delete(ctx, tenantBNamespace, metav1.DeleteOptions{})
+		restoreTenantLabel()
+	}
+
+	// Step 1: Label the tenant namespaces
+	restore, err := t.setNamespaceLabel(ctx, t.namespace, tenantLabelKey, "a")
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	restoreTenantLabel = restore
+	details = append(details, fmt.Sprintf("✓ Labelled namespace %s with %s=a", t.namespace, tenantLabelKey))
+
+	_, err = t.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   tenantBNamespace,
+			Labels: map[string]string{tenantLabelKey: "b"},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		restoreTenantLabel()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create namespace %s: %v", tenantBNamespace, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created namespace %s with %s=b", tenantBNamespace, tenantLabelKey))
+
+	// Step 2: Create the web pod and one client per source
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{"run": "ns-isolation-web"}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	clients := []struct {
+		namespace, name, run string
+	}{
+		{t.namespace, localClientName, "ns-isolation-client"},
+		{tenantBNamespace, remoteClientName, "ns-isolation-client"},
+		{tenantBNamespace, remoteOtherName, "ns-isolation-other"},
+	}
+	for _, client := range clients {
+		if err := t.createPolicyClientPod(ctx, client.namespace, client.name, "", map[string]string{"run": client.run}); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: err.Error(),
+				Details: details,
+			}
+		}
+	}
+	if err := t.waitForPodReady(ctx, webPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Pod %s did not become ready: %v", webPodName, err),
+			Details: details,
+		}
+	}
+	for _, client := range clients {
+		if err := t.waitForPodReadyInNamespace(ctx, client.namespace, client.name, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", client.name, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	webIP := webPod.Status.PodIP
+	details = append(details, fmt.Sprintf("✓ Web pod '%s' ready at %s; clients ready in %s and %s", webPodName, webIP, t.namespace, tenantBNamespace))
+
+	// Step 3: Baseline - all sources reach the web pod without policies
+	for _, probe := range probes {
+		allowed, output := t.probeTCPPortFromNamespace(ctx, probe.Namespace, probe.Pod, webIP, 80)
+		commandOutputs = append(commandOutputs, output)
+		if !allowed {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Baseline failed - %s cannot reach the web pod before any policy is applied", probe.Name),
+				Details: append(details, fmt.Sprintf("✗ Baseline %s blocked", probe.Name)),
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:   "Baseline Connectivity",
+					TechnicalError: strings.TrimSpace(output.Stderr),
+					CommandOutputs: commandOutputs,
+					TroubleshootingHints: []string{
+						"Check for leftover policies selecting the web pod: kubectl get ciliumclusterwidenetworkpolicies",
+					},
+				},
+			}
+		}
+	}
+	details = append(details, "✓ Baseline: all sources reach the web pod before any policy is applied")
+
+	// Step 4: Apply the phases cumulatively and check every source
+	var mismatches []string
+	for _, phase := range phases {
+		appliedPolicyName, err := t.applyNetworkPolicy(ctx, phase.PolicyFile)
+		if err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to apply network policy for phase %s: %v", phase.Name, err),
+				Details: details,
+			}
+		}
+		appliedPolicies = append(appliedPolicies, appliedPolicyName)
+		details = append(details, fmt.Sprintf("ℹ️ Phase '%s': applied %s", phase.Name, appliedPolicyName))
+
+		// Wait for policy to take effect
+		sleepContext(ctx, 5*time.Second)
+
+		for _, probe := range probes {
+			allowed, output := t.probeTCPPortFromNamespace(ctx, probe.Namespace, probe.Pod, webIP, 80)
+			commandOutputs = append(commandOutputs, output)
+			expected := phase.Expected[probe.Name]
+			if allowed == expected {
+				details = append(details, fmt.Sprintf("✓ %s: %s %s as expected", phase.Name, probe.Name, reachability(allowed)))
+			} else {
+				details = append(details, fmt.Sprintf("✗ %s: %s %s, expected %s", phase.Name, probe.Name, reachability(allowed), reachability(expected)))
+				mismatches = append(mismatches, fmt.Sprintf("%s: %s expected %s but was %s",
+					phase.Name, probe.Name, reachability(expected), reachability(allowed)))
+			}
+		}
+	}
+
+	cleanup()
+	details = append(details, fmt.Sprintf("✓ Cleaned up policies, namespace %s and tenant label", tenantBNamespace))
+
+	if len(mismatches) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Namespace isolation failed: %s", strings.Join(mismatches, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Namespace Isolation Verification",
+				TechnicalError: strings.Join(mismatches, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Cilium exposes namespace labels as k8s:io.cilium.k8s.namespace.labels.<key>; check the namespaces carry the tenant label",
+					"Namespace label changes take a few seconds to reach endpoint identities; retry if only the first phase failed",
+					fmt.Sprintf("Check the identities of the client pods: kubectl get ciliumendpoints -n %s", tenantBNamespace),
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: "Namespace isolation verified - same-tenant allowed, cross-tenant denied and selectively allowed",
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
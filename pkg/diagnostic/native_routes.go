@@ -0,0 +1,304 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeRoute is the next hop of one route in a node's main routing table
+type nodeRoute struct {
+	Via string
+	Dev string
+}
+
+// routedNode is a node with the pod CIDRs other nodes need routes for
+type routedNode struct {
+	Name      string
+	Address   string   // InternalIP used as next hop by auto-direct-node-routes
+	Addresses []string // every address of the node, accepted as next hop
+	PodCIDRs  []string
+}
+
+// routeCheck is the result of checking one node's routing table
+type routeCheck struct {
+	Node    string
+	Missing []string // "<cidr> via <address>" routes that should exist
+	Wrong   []string // routes pointing somewhere other than the owning node
+	Output  CommandOutput
+	Error   string
+}
+
+// normalizeCIDR returns the canonical form of a CIDR, or the input when it does not parse
+func normalizeCIDR(cidr string) string {
+	if _, network, err := net.ParseCIDR(cidr); err == nil {
+		return network.String()
+	}
+	return cidr
+}
+
+// parseRoutes parses `ip route show` output into destination -> next hop, keeping only prefixes
+func parseRoutes(output string) map[string]nodeRoute {
+	routes := make(map[string]nodeRoute)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.Contains(fields[0], "/") {
+			continue
+		}
+		var route nodeRoute
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				route.Via = fields[i+1]
+			case "dev":
+				route.Dev = fields[i+1]
+			}
+		}
+		routes[normalizeCIDR(fields[0])] = route
+	}
+	return routes
+}
+
+// checkNodeRoutes compares one node's routes with the pod CIDRs of every other node
+func checkNodeRoutes(node routedNode, routes map[string]nodeRoute, peers []routedNode) ([]string, []string) {
+	var missing, wrong []string
+	for _, peer := range peers {
+		if peer.Name == node.Name {
+			continue
+		}
+		for _, cidr := range peer.PodCIDRs {
+			route, found := routes[normalizeCIDR(cidr)]
+			if !found {
+				missing = append(missing, fmt.Sprintf("%s via %s", cidr, peer.Address))
+				continue
+			}
+			owner := false
+			for _, address := range peer.Addresses {
+				owner = owner || route.Via == address
+			}
+			if !owner {
+				nextHop := route.Via
+				if nextHop == "" {
+					nextHop = "dev " + route.Dev
+				}
+				wrong = append(wrong, fmt.Sprintf("%s via %s, expected via %s (%s)", cidr, nextHop, peer.Address, peer.Name))
+			}
+		}
+	}
+	return missing, wrong
+}
+
+// collectRouteCheck reads a node's IPv4 and IPv6 routing tables through a short-lived host-network pod and checks
+// them against the pod CIDRs of the other nodes
+func (t *Tester) collectRouteCheck(ctx context.Context, node routedNode, peers []routedNode) routeCheck {
+	check := routeCheck{Node: node.Name}
+	podName := fmt.Sprintf("node-routes-%s", node.Name)
+	if len(podName) > 63 {
+		podName = strings.TrimRight(podName[:63], "-.")
+	}
+	if _, err := t.createHostNetworkPod(ctx, podName, node.Name); err != nil {
+		check.Error = fmt.Sprintf("failed to create host-network pod: %v", err)
+		return check
+	}
+	defer t.cleanupPod(ctx, podName)
+	if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+		check.Error = fmt.Sprintf("host-network pod did not become ready: %v", err)
+		return check
+	}
+
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"sh", "-c", "ip route show; ip -6 route show"}, fmt.Sprintf("Routing table on %s", node.Name))
+	check.Output = output
+	if err != nil {
+		check.Error = fmt.Sprintf("failed to read routes: %v", err)
+		return check
+	}
+	check.Missing, check.Wrong = checkNodeRoutes(node, parseRoutes(output.Stdout), peers)
+	return check
+}
+
+// TestNativeRoutingTable verifies, for Cilium native routing, that every node has a route to every other node's pod
+// CIDR via that node. With auto-direct-node-routes Cilium installs these routes itself, so a missing or misdirected
+// route fails the test; without it the underlay router is expected to route pod CIDRs and the node routes are only
+// reported.
+func (t *Tester) TestNativeRoutingTable(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Cilium in native routing mode
+	if !t.isCilium(ctx) {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Native routing table check skipped - Cilium is not the CNI",
+			Details: details,
+		}
+	}
+	config, err := t.getCiliumConfig(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read cilium-config: %v", err),
+			Details: details,
+		}
+	}
+	mode, _ := ciliumRoutingMode(config)
+	if mode == "tunnel" {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Native routing table check skipped - Cilium uses tunnel routing, see the overlay-health test",
+			Details: details,
+		}
+	}
+	if !t.ciliumPoolIPAM(ctx) {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Native routing table check skipped - ipam=%s routes pod addresses through the cloud network", config["ipam"]),
+			Details: details,
+		}
+	}
+	autoDirect := config["auto-direct-node-routes"] == "true"
+	details = append(details, fmt.Sprintf("✓ Cilium routing-mode=%s, auto-direct-node-routes=%t", mode, autoDirect))
+	if nativeCIDR := config["ipv4-native-routing-cidr"]; nativeCIDR != "" {
+		details = append(details, fmt.Sprintf("ℹ️ ipv4-native-routing-cidr: %s", nativeCIDR))
+	}
+
+	// Step 2: Pod CIDRs of every node, from CiliumNode allocations or spec.podCIDRs
+	nodeList, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	allocations := t.ciliumNodeIPAM(ctx)
+	var nodes []routedNode
+	for _, node := range nodeList.Items {
+		entry := routedNode{Name: node.Name, PodCIDRs: allocations[node.Name].CIDRs}
+		if len(entry.PodCIDRs) == 0 {
+			entry.PodCIDRs = node.Spec.PodCIDRs
+		}
+		for _, address := range node.Status.Addresses {
+			entry.Addresses = append(entry.Addresses, address.Address)
+			if address.Type == corev1.NodeInternalIP && entry.Address == "" {
+				entry.Address = address.Address
+			}
+		}
+		if len(entry.PodCIDRs) == 0 {
+			details = append(details, fmt.Sprintf("⚠️ Node %s has no pod CIDR - other nodes are not checked for routes to it", node.Name))
+		}
+		nodes = append(nodes, entry)
+	}
+	if len(nodes) < 2 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Native routing table check skipped - needs at least 2 nodes",
+			Details: details,
+		}
+	}
+
+	// Step 3: Every node's routing table, read in parallel
+	checks := make([]routeCheck, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node routedNode) {
+			defer wg.Done()
+			checks[i] = t.collectRouteCheck(ctx, node, nodes)
+		}(i, node)
+	}
+	wg.Wait()
+
+	metrics := map[string]float64{}
+	var missingRoutes, wrongRoutes []string
+	var commandOutputs []CommandOutput
+	details = append(details, "  Routes to other nodes' pod CIDRs:")
+	details = append(details, fmt.Sprintf("  %-30s %-8s %-8s %s", "NODE", "MISSING", "WRONG", "RESULT"))
+	for _, check := range checks {
+		if check.Error != "" {
+			details = append(details, fmt.Sprintf("  %-30s %-8s %-8s ⚠️ %s", check.Node, "-", "-", check.Error))
+			continue
+		}
+		marker := "✓"
+		if len(check.Missing) > 0 || len(check.Wrong) > 0 {
+			marker = "✗"
+			if !autoDirect {
+				marker = "ℹ️ via underlay"
+			}
+			commandOutputs = append(commandOutputs, check.Output)
+		}
+		details = append(details, fmt.Sprintf("  %-30s %-8d %-8d %s", check.Node, len(check.Missing), len(check.Wrong), marker))
+		for _, route := range check.Missing {
+			missingRoutes = append(missingRoutes, fmt.Sprintf("%s: %s", check.Node, route))
+		}
+		for _, route := range check.Wrong {
+			wrongRoutes = append(wrongRoutes, fmt.Sprintf("%s: %s", check.Node, route))
+		}
+	}
+	metrics["missing_routes"] = float64(len(missingRoutes))
+	metrics["wrong_routes"] = float64(len(wrongRoutes))
+	sort.Strings(missingRoutes)
+	sort.Strings(wrongRoutes)
+
+	// Without auto-direct-node-routes, pod CIDRs are routed by the underlay or by routes installed outside Cilium
+	var problems []string
+	if !autoDirect {
+		for _, route := range missingRoutes {
+			details = append(details, fmt.Sprintf("ℹ️ No node route on %s - left to the underlay router", route))
+		}
+		for _, route := range wrongRoutes {
+			details = append(details, fmt.Sprintf("ℹ️ Route on %s - installed outside Cilium", route))
+		}
+	} else {
+		for _, route := range missingRoutes {
+			details = append(details, fmt.Sprintf("✗ Missing route on %s", route))
+			problems = append(problems, route)
+		}
+		for _, route := range wrongRoutes {
+			details = append(details, fmt.Sprintf("✗ Misdirected route on %s", route))
+			problems = append(problems, route)
+		}
+	}
+
+	if len(problems) > 0 {
+		hints := []string{
+			"auto-direct-node-routes only installs routes to nodes on the same L2 segment - nodes in other subnets need the underlay (or BGP) to route their pod CIDRs",
+			"Check the Cilium agent logs on the node for route installation errors: kubectl logs -n kube-system <cilium-pod> | grep -i route",
+			"Add a missing route by hand to confirm the diagnosis: ip route add <pod-cidr> via <node-ip> (on the node listed)",
+			"Misdirected routes usually come from a stale node address or another route source (BGP, cloud route tables) overriding Cilium",
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d pod CIDR routes missing or misdirected in native routing mode", len(problems)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "Native Routing Table",
+				TechnicalError:       strings.Join(problems, "; "),
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	message := fmt.Sprintf("Every node routes all other nodes' pod CIDRs via the owning node (%d nodes)", len(nodes))
+	if !autoDirect && len(missingRoutes)+len(wrongRoutes) > 0 {
+		message = fmt.Sprintf("%d pod CIDR routes are left to the underlay router or other route sources (auto-direct-node-routes=false)", len(missingRoutes)+len(wrongRoutes))
+	}
+	return TestResult{
+		Success: true,
+		Message: message,
+		Details: details,
+		Metrics: metrics,
+	}
+}
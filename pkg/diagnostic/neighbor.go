@@ -0,0 +1,190 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// neighborEntry is one line of `ip neigh show`
+type neighborEntry struct {
+	IP     string
+	Device string
+	MAC    string
+	State  string
+}
+
+// neighborScript pings each address once so the kernel resolves it, then prints the neighbor table
+const neighborScript = `for ip in "$@"; do ping -c 1 -W 1 "$ip" >/dev/null 2>&1; done; ip neigh show`
+
+// parseNeighbors parses `ip neigh show` output, e.g. "10.244.1.5 dev eth0 lladdr 6a:1f:... REACHABLE"
+func parseNeighbors(output string) []neighborEntry {
+	var entries []neighborEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		entry := neighborEntry{IP: fields[0], State: fields[len(fields)-1]}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				entry.Device = fields[i+1]
+			case "lladdr":
+				entry.MAC = strings.ToLower(fields[i+1])
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// neighborFindings checks the entries for the target addresses (address -> label): unresolved (INCOMPLETE/FAILED)
+// and STALE entries, and MACs claimed by more than one address on the same device. Failed resolutions are returned
+// as problems, stale entries as warnings.
+func neighborFindings(host string, entries []neighborEntry, targets map[string]string) ([]string, []string) {
+	claims := make(map[string][]string)
+	for _, entry := range entries {
+		if entry.MAC != "" && entry.State != "PERMANENT" && entry.State != "NOARP" {
+			key := entry.Device + "/" + entry.MAC
+			claims[key] = append(claims[key], entry.IP)
+		}
+	}
+
+	var problems, warnings []string
+	for _, entry := range entries {
+		label, ok := targets[entry.IP]
+		if !ok {
+			continue
+		}
+		switch entry.State {
+		case "INCOMPLETE", "FAILED":
+			problems = append(problems, fmt.Sprintf("%s: %s (%s) is %s on %s - no ARP/NDP reply", host, entry.IP, label, entry.State, entry.Device))
+		case "STALE":
+			warnings = append(warnings, fmt.Sprintf("%s: %s (%s) is STALE on %s (lladdr %s)", host, entry.IP, label, entry.Device, entry.MAC))
+		}
+		if others := claims[entry.Device+"/"+entry.MAC]; entry.MAC != "" && len(others) > 1 {
+			sort.Strings(others)
+			problems = append(problems, fmt.Sprintf("%s: MAC %s on %s is claimed by %s - duplicate MAC or IP conflict", host, entry.MAC, entry.Device, strings.Join(others, ", ")))
+		}
+	}
+	return problems, warnings
+}
+
+// podDefaultGateway returns the next hop of a test pod's default route, or "" when it has none (point-to-point
+// routes without a gateway)
+func (t *Tester) podDefaultGateway(ctx context.Context, podName string) string {
+	output, err := t.execInPod(ctx, t.namespace, podName, "netshoot", []string{"ip", "route", "show", "default"})
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "via" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// collectNeighbors reads the neighbor table in a pod after pinging the target addresses
+func (t *Tester) collectNeighbors(ctx context.Context, podName string, targets map[string]string, description string) (CommandOutput, []neighborEntry, error) {
+	command := []string{"sh", "-c", neighborScript, "neigh"}
+	for address := range targets {
+		command = append(command, address)
+	}
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", command, description)
+	if err != nil {
+		return output, nil, err
+	}
+	return output, parseNeighbors(output.Stdout), nil
+}
+
+// attachNeighborDiagnostics reads the neighbor tables of the source pod and of the given nodes after a same-node or
+// node-local failure, and adds unresolved or stale entries for the target addresses (address -> label) and duplicate
+// MACs to the result's diagnostics. The source pod's default gateway is checked as well.
+func (t *Tester) attachNeighborDiagnostics(ctx context.Context, result *TestResult, details *[]string, sourcePod string, nodeNames []string, targets map[string]string) {
+	if result.Success {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	diagnostics := result.DetailedDiagnostics
+
+	podTargets := make(map[string]string)
+	for address, label := range targets {
+		podTargets[address] = label
+	}
+	if gateway := t.podDefaultGateway(ctx, sourcePod); gateway != "" {
+		podTargets[gateway] = fmt.Sprintf("default gateway of %s", sourcePod)
+	}
+
+	var problems, warnings []string
+	output, entries, err := t.collectNeighbors(ctx, sourcePod, podTargets, fmt.Sprintf("Neighbor table in pod %s", sourcePod))
+	if output.Command != "" {
+		diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+	}
+	if err != nil {
+		*details = append(*details, fmt.Sprintf("⚠️ Could not read the neighbor table in %s: %v", sourcePod, err))
+	} else {
+		problems, warnings = neighborFindings(sourcePod, entries, podTargets)
+	}
+
+	seen := make(map[string]bool)
+	for _, nodeName := range nodeNames {
+		if nodeName == "" || seen[nodeName] {
+			continue
+		}
+		seen[nodeName] = true
+
+		debugPodName := fmt.Sprintf("neigh-debug-%s", nodeName)
+		if len(debugPodName) > 63 {
+			debugPodName = strings.TrimRight(debugPodName[:63], "-.")
+		}
+		if _, err := t.createHostNetworkPod(ctx, debugPodName, nodeName); err != nil {
+			*details = append(*details, fmt.Sprintf("⚠️ Could not read the neighbor table on %s: %v", nodeName, err))
+			continue
+		}
+		if err := t.waitForPodReady(ctx, debugPodName, 60*time.Second); err != nil {
+			t.cleanupPod(ctx, debugPodName)
+			*details = append(*details, fmt.Sprintf("⚠️ Could not read the neighbor table on %s: %v", nodeName, err))
+			continue
+		}
+		output, entries, err := t.collectNeighbors(ctx, debugPodName, targets, fmt.Sprintf("Neighbor table on node %s", nodeName))
+		t.cleanupPod(ctx, debugPodName)
+		if output.Command != "" {
+			diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+		}
+		if err != nil {
+			*details = append(*details, fmt.Sprintf("⚠️ Could not read the neighbor table on %s: %v", nodeName, err))
+			continue
+		}
+		nodeProblems, nodeWarnings := neighborFindings(nodeName, entries, targets)
+		problems = append(problems, nodeProblems...)
+		warnings = append(warnings, nodeWarnings...)
+	}
+
+	if len(problems) == 0 && len(warnings) == 0 {
+		*details = append(*details, "ℹ️ Neighbor tables show no unresolved, stale or duplicate entries for the test addresses")
+		return
+	}
+	for _, problem := range problems {
+		*details = append(*details, fmt.Sprintf("✗ %s", problem))
+		diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints, fmt.Sprintf("Neighbor table: %s", problem))
+	}
+	for _, warning := range warnings {
+		*details = append(*details, fmt.Sprintf("⚠️ %s", warning))
+	}
+	if len(problems) > 0 {
+		diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints,
+			"INCOMPLETE/FAILED entries mean ARP/NDP requests got no answer: check the veth or bridge the address sits behind, proxy_arp on the host side and L2 filtering (ebtables, port security)",
+			"A MAC shared by several addresses points at an IP conflict or stale entries after pod IP reuse - flush with 'ip neigh flush dev <device>' and compare with 'arping -D -I <device> <address>'")
+	}
+	if len(warnings) > 0 {
+		diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints,
+			"STALE entries that stay stale after traffic may hold an old MAC, e.g. for a recreated pod that reused the IP - compare the lladdr with 'ip link show eth0' inside the pod")
+	}
+}
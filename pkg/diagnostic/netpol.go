@@ -0,0 +1,436 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// netpolLabelKey is carried by every pod in the NetworkPolicy conformance tests and used by their selectors
+const netpolLabelKey = "netpol-role"
+
+// netpolProbe is one source pod and port checked against the web pod in every case
+type netpolProbe struct {
+	Name      string
+	Namespace string
+	Pod       string
+	Port      int32
+}
+
+// netpolEnv holds the addresses and namespaces a conformance case builds its policy from
+type netpolEnv struct {
+	OtherNamespace string
+	ClientIP       string
+	WebIP          string
+}
+
+// netpolCase is one standalone NetworkPolicy and the set of probes expected to reach the web pod while it is applied
+type netpolCase struct {
+	Name     string
+	Policy   func(env netpolEnv) *networkingv1.NetworkPolicy
+	Expected map[string]bool
+}
+
+// netpolProtocol returns a pointer to the TCP protocol for NetworkPolicyPort
+func netpolProtocol() *corev1.Protocol {
+	protocol := corev1.ProtocolTCP
+	return &protocol
+}
+
+// netpolPorts returns TCP NetworkPolicyPorts for the given port numbers
+func netpolPorts(ports ...int) []networkingv1.NetworkPolicyPort {
+	var policyPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		value := intstr.FromInt(port)
+		policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: netpolProtocol(), Port: &value})
+	}
+	return policyPorts
+}
+
+// netpolPodSelector selects the conformance pods with the given role
+func netpolPodSelector(role string) metav1.LabelSelector {
+	return metav1.LabelSelector{MatchLabels: map[string]string{netpolLabelKey: role}}
+}
+
+// netpolIngressCases returns the ingress subset of the upstream NetworkPolicy conformance checks
+func netpolIngressCases() []netpolCase {
+	return []netpolCase{
+		{
+			Name: "ingress deny-all",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-ingress-deny-all"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("web"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+					},
+				}
+			},
+			Expected: map[string]bool{},
+		},
+		{
+			Name: "ingress from podSelector",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-ingress-pod-selector"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("web"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+						Ingress: []networkingv1.NetworkPolicyIngressRule{
+							{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{netpolLabelKey: "client"}}}}},
+						},
+					},
+				}
+			},
+			// A bare podSelector only matches pods in the policy's own namespace
+			Expected: map[string]bool{"client→web:80": true, "client→web:8080": true},
+		},
+		{
+			Name: "ingress from namespaceSelector",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-ingress-namespace-selector"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("web"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+						Ingress: []networkingv1.NetworkPolicyIngressRule{
+							{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{"kubernetes.io/metadata.name": env.OtherNamespace},
+							}}}},
+						},
+					},
+				}
+			},
+			Expected: map[string]bool{"remote client→web:80": true},
+		},
+		{
+			Name: "ingress port restriction",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-ingress-port-80"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("web"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+						Ingress: []networkingv1.NetworkPolicyIngressRule{
+							{Ports: netpolPorts(80)},
+						},
+					},
+				}
+			},
+			Expected: map[string]bool{"client→web:80": true, "other→web:80": true, "remote client→web:80": true},
+		},
+		{
+			Name: "ingress from ipBlock",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-ingress-ip-block"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("web"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+						Ingress: []networkingv1.NetworkPolicyIngressRule{
+							{From: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: hostCIDR(env.ClientIP)}}}},
+						},
+					},
+				}
+			},
+			Expected: map[string]bool{"client→web:80": true, "client→web:8080": true},
+		},
+	}
+}
+
+// netpolEgressCases returns the egress subset of the upstream NetworkPolicy conformance checks
+func netpolEgressCases() []netpolCase {
+	return []netpolCase{
+		{
+			Name: "egress deny-all",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-egress-deny-all"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("client"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+					},
+				}
+			},
+			// The policy lives in the test namespace, so the remote client is unaffected
+			Expected: map[string]bool{"other→web:80": true, "other→web:8080": true, "remote client→web:80": true},
+		},
+		{
+			Name: "egress to podSelector with port",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-egress-pod-selector-port"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("client"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+						Egress: []networkingv1.NetworkPolicyEgressRule{
+							{
+								To:    []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{netpolLabelKey: "web"}}}},
+								Ports: netpolPorts(8080),
+							},
+						},
+					},
+				}
+			},
+			Expected: map[string]bool{"client→web:8080": true, "other→web:80": true, "other→web:8080": true, "remote client→web:80": true},
+		},
+		{
+			Name: "egress to ipBlock with except",
+			Policy: func(env netpolEnv) *networkingv1.NetworkPolicy {
+				return &networkingv1.NetworkPolicy{
+					ObjectMeta: metav1.ObjectMeta{Name: "netpol-egress-ip-block"},
+					Spec: networkingv1.NetworkPolicySpec{
+						PodSelector: netpolPodSelector("client"),
+						PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+						Egress: []networkingv1.NetworkPolicyEgressRule{
+							{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{
+								CIDR:   anyCIDR(env.WebIP),
+								Except: []string{hostCIDR(env.WebIP)},
+							}}}},
+						},
+					},
+				}
+			},
+			Expected: map[string]bool{"other→web:80": true, "other→web:8080": true, "remote client→web:80": true},
+		},
+	}
+}
+
+// hostCIDR returns the single-address CIDR for ip
+func hostCIDR(ip string) string {
+	if strings.Contains(ip, ":") {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
+
+// anyCIDR returns the all-addresses CIDR of ip's family
+func anyCIDR(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// TestNetpolIngressConformance runs the ingress subset of the NetworkPolicy conformance checks
+func (t *Tester) TestNetpolIngressConformance(ctx context.Context) TestResult {
+	return t.runNetpolConformance(ctx, "ingress", netpolIngressCases())
+}
+
+// TestNetpolEgressConformance runs the egress subset of the NetworkPolicy conformance checks
+func (t *Tester) TestNetpolEgressConformance(ctx context.Context) TestResult {
+	return t.runNetpolConformance(ctx, "egress", netpolEgressCases())
+}
+
+// runNetpolConformance applies each networking.k8s.io NetworkPolicy on its own and verifies every probe against
+// the case's expectation. Only standard API objects are used, so the checks are valid on any policy-enforcing CNI.
+func (t *Tester) runNetpolConformance(ctx context.Context, direction string, cases []netpolCase) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	otherNamespace := fmt.Sprintf("%s-netpol-%s-%d", t.namespace, direction, time.Now().Unix())
+	webPodName := fmt.Sprintf("web-netpol-%s-test", direction)
+	clientPodName := fmt.Sprintf("client-netpol-%s-test", direction)
+	otherPodName := fmt.Sprintf("other-netpol-%s-test", direction)
+	remotePodName := fmt.Sprintf("remote-netpol-%s-test", direction)
+
+	probes := []netpolProbe{
+		{Name: "client→web:80", Namespace: t.namespace, Pod: clientPodName, Port: 80},
+		{Name: "client→web:8080", Namespace: t.namespace, Pod: clientPodName, Port: 8080},
+		{Name: "other→web:80", Namespace: t.namespace, Pod: otherPodName, Port: 80},
+		{Name: "other→web:8080", Namespace: t.namespace, Pod: otherPodName, Port: 8080},
+		{Name: "remote client→web:80", Namespace: otherNamespace, Pod: remotePodName, Port: 80},
+	}
+
+	appliedPolicyName := ""
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if appliedPolicyName != "" {
+			t.clientset.NetworkingV1().NetworkPolicies(t.namespace).Delete(ctx, appliedPolicyName, metav1.DeleteOptions{})
+			appliedPolicyName = ""
+		}
+		t.cleanupPods(ctx, webPodName, clientPodName)
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, otherPodName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().Namespaces().Delete(ctx, otherNamespace, metav1.DeleteOptions{})
+	}
+
+	// Step 1: Create the second namespace and the test pods
+	_, err := t.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: otherNamespace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create namespace %s: %v", otherNamespace, err),
+			Details: details,
+		}
+	}
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{netpolLabelKey: "web"}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	clients := []struct {
+		namespace, name, role string
+	}{
+		{t.namespace, clientPodName, "client"},
+		{t.namespace, otherPodName, "other"},
+		{otherNamespace, remotePodName, "client"},
+	}
+	for _, client := range clients {
+		if err := t.createPolicyClientPod(ctx, client.namespace, client.name, "", map[string]string{netpolLabelKey: client.role}); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: err.Error(),
+				Details: details,
+			}
+		}
+	}
+	if err := t.waitForPodReady(ctx, webPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Pod %s did not become ready: %v", webPodName, err),
+			Details: details,
+		}
+	}
+	for _, client := range clients {
+		if err := t.waitForPodReadyInNamespace(ctx, client.namespace, client.name, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", client.name, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	clientPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, clientPodName, metav1.GetOptions{})
+	if err != nil || clientPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", clientPodName),
+			Details: details,
+		}
+	}
+	env := netpolEnv{
+		OtherNamespace: otherNamespace,
+		ClientIP:       clientPod.Status.PodIP,
+		WebIP:          webPod.Status.PodIP,
+	}
+	details = append(details, fmt.Sprintf("✓ Web pod '%s' ready at %s; clients ready in %s and %s", webPodName, env.WebIP, t.namespace, otherNamespace))
+
+	// Step 2: Baseline - every probe succeeds without policies
+	for _, probe := range probes {
+		allowed, output := t.probeTCPPortFromNamespace(ctx, probe.Namespace, probe.Pod, env.WebIP, probe.Port)
+		commandOutputs = append(commandOutputs, output)
+		if !allowed {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Baseline failed - %s is blocked before any policy is applied", probe.Name),
+				Details: append(details, fmt.Sprintf("✗ Baseline %s blocked", probe.Name)),
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:   "Baseline Connectivity",
+					TechnicalError: strings.TrimSpace(output.Stderr),
+					CommandOutputs: commandOutputs,
+					TroubleshootingHints: []string{
+						fmt.Sprintf("Check for leftover NetworkPolicies: kubectl get networkpolicies -n %s", t.namespace),
+					},
+				},
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Baseline: all %d probes allowed", len(probes)))
+
+	// Step 3: Apply each case on its own and check every probe
+	var mismatches []string
+	for _, c := range cases {
+		policy := c.Policy(env)
+		if _, err := t.clientset.NetworkingV1().NetworkPolicies(t.namespace).Create(ctx, policy, metav1.CreateOptions{}); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create NetworkPolicy for case %s: %v", c.Name, err),
+				Details: details,
+			}
+		}
+		appliedPolicyName = policy.Name
+		details = append(details, fmt.Sprintf("ℹ️ Case '%s': applied NetworkPolicy %s", c.Name, policy.Name))
+
+		// Wait for policy to take effect
+		sleepContext(ctx, 5*time.Second)
+
+		for _, probe := range probes {
+			allowed, output := t.probeTCPPortFromNamespace(ctx, probe.Namespace, probe.Pod, env.WebIP, probe.Port)
+			commandOutputs = append(commandOutputs, output)
+			expected := c.Expected[probe.Name]
+			if allowed == expected {
+				details = append(details, fmt.Sprintf("✓ %s: %s %s as expected", c.Name, probe.Name, reachability(allowed)))
+			} else {
+				details = append(details, fmt.Sprintf("✗ %s: %s %s, expected %s", c.Name, probe.Name, reachability(allowed), reachability(expected)))
+				mismatches = append(mismatches, fmt.Sprintf("%s: %s expected %s but was %s",
+					c.Name, probe.Name, reachability(expected), reachability(allowed)))
+			}
+		}
+
+		if err := t.clientset.NetworkingV1().NetworkPolicies(t.namespace).Delete(ctx, policy.Name, metav1.DeleteOptions{}); err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Failed to delete NetworkPolicy %s: %v", policy.Name, err))
+		} else {
+			appliedPolicyName = ""
+		}
+		// Let the deletion settle before the next case
+		sleepContext(ctx, 3*time.Second)
+	}
+
+	cleanup()
+	details = append(details, fmt.Sprintf("✓ Cleaned up NetworkPolicies, test pods and namespace %s", otherNamespace))
+
+	if len(mismatches) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("NetworkPolicy %s conformance failed: %s", direction, strings.Join(mismatches, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   fmt.Sprintf("NetworkPolicy Conformance (%s)", direction),
+				TechnicalError: strings.Join(mismatches, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check that the CNI enforces NetworkPolicy at all (flannel and kindnet without a policy add-on do not)",
+					"ipBlock peers are matched against the source IP after any SNAT; masquerading between nodes breaks ipBlock ingress rules",
+					"namespaceSelector cases rely on the kubernetes.io/metadata.name label (Kubernetes 1.21+)",
+					fmt.Sprintf("Inspect applied policies while the test runs: kubectl describe networkpolicies -n %s", t.namespace),
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d NetworkPolicy %s conformance cases behaved as expected", len(cases), direction),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
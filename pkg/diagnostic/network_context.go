@@ -0,0 +1,226 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// networkContextPodLimit caps the pods listed in the network context of one test
+const networkContextPodLimit = 20
+
+// observedPod is a pod seen in the test namespace while a test ran, with the last address and node it reported
+type observedPod struct {
+	Name        string
+	IP          string
+	Node        string
+	HostNetwork bool
+}
+
+// observedService is a Service seen in the test namespace while a test ran
+type observedService struct {
+	Name      string
+	ClusterIP string
+}
+
+// NetworkObserver watches the pods and Services created in the test namespace while a test runs, so their addresses
+// and nodes can be recorded in the result after the test has deleted them
+type NetworkObserver struct {
+	mu       sync.Mutex
+	pods     []observedPod
+	services []observedService
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// ObserveNetwork starts watching the test namespace; Stop must be called when the test returns
+func (t *Tester) ObserveNetwork(ctx context.Context) *NetworkObserver {
+	watchCtx, cancel := context.WithCancel(ctx)
+	observer := &NetworkObserver{cancel: cancel}
+	t.fixtures.mu.Lock()
+	t.fixtures.observer = observer
+	t.fixtures.mu.Unlock()
+
+	// Watch from the current state so pods left over from earlier tests are not attributed to this one
+	if pods, err := t.clientset.CoreV1().Pods(t.namespace).List(watchCtx, metav1.ListOptions{}); err == nil {
+		observer.wg.Add(1)
+		go observer.watch(watchCtx, pods.ResourceVersion, func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			return t.clientset.CoreV1().Pods(t.namespace).Watch(ctx, options)
+		})
+	}
+	if services, err := t.clientset.CoreV1().Services(t.namespace).List(watchCtx, metav1.ListOptions{}); err == nil {
+		observer.wg.Add(1)
+		go observer.watch(watchCtx, services.ResourceVersion, func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			return t.clientset.CoreV1().Services(t.namespace).Watch(ctx, options)
+		})
+	}
+	return observer
+}
+
+// watch records objects from one watch, re-establishing it from the last seen version when the server closes it
+func (o *NetworkObserver) watch(ctx context.Context, resourceVersion string, start func(context.Context, metav1.ListOptions) (watch.Interface, error)) {
+	defer o.wg.Done()
+	for ctx.Err() == nil {
+		watcher, err := start(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+		if err != nil {
+			return
+		}
+		for event := range watcher.ResultChan() {
+			// An expired resource version cannot be resumed; what was recorded so far is kept
+			if event.Type == watch.Error {
+				watcher.Stop()
+				return
+			}
+			switch object := event.Object.(type) {
+			case *corev1.Pod:
+				resourceVersion = object.ResourceVersion
+				o.recordPod(object)
+			case *corev1.Service:
+				resourceVersion = object.ResourceVersion
+				o.recordService(object)
+			}
+		}
+		watcher.Stop()
+	}
+}
+
+// recordPod keeps the latest address and node of a pod, in the order the pods appeared
+func (o *NetworkObserver) recordPod(pod *corev1.Pod) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.pods {
+		if o.pods[i].Name == pod.Name {
+			if pod.Status.PodIP != "" {
+				o.pods[i].IP = pod.Status.PodIP
+			}
+			if pod.Spec.NodeName != "" {
+				o.pods[i].Node = pod.Spec.NodeName
+			}
+			return
+		}
+	}
+	o.pods = append(o.pods, observedPod{Name: pod.Name, IP: pod.Status.PodIP, Node: pod.Spec.NodeName, HostNetwork: pod.Spec.HostNetwork})
+}
+
+// recordService keeps the ClusterIP of a Service, in the order the Services appeared
+func (o *NetworkObserver) recordService(service *corev1.Service) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := range o.services {
+		if o.services[i].Name == service.Name {
+			o.services[i].ClusterIP = service.Spec.ClusterIP
+			return
+		}
+	}
+	o.services = append(o.services, observedService{Name: service.Name, ClusterIP: service.Spec.ClusterIP})
+}
+
+// Stop ends the watches and waits for them to finish
+func (o *NetworkObserver) Stop() {
+	o.cancel()
+	o.wg.Wait()
+}
+
+// endpoints picks the source and target of the test from the observed pods: the first netshoot pod is the client,
+// as in every test of this tool, and the first other pod network pod is the target
+func (o *NetworkObserver) endpoints() (*observedPod, *observedPod) {
+	var source, target *observedPod
+	for i := range o.pods {
+		if strings.HasPrefix(o.pods[i].Name, "netshoot") && !o.pods[i].HostNetwork {
+			source = &o.pods[i]
+			break
+		}
+	}
+	if source == nil && len(o.pods) > 0 {
+		source = &o.pods[0]
+	}
+	for i := range o.pods {
+		if &o.pods[i] != source && o.pods[i].IP != "" && (target == nil || target.HostNetwork && !o.pods[i].HostNetwork) {
+			target = &o.pods[i]
+		}
+	}
+	return source, target
+}
+
+// routingInfo describes how the cluster forwards pod and Service traffic: the CNI, Cilium's routing mode and the
+// kube-proxy mode
+func (t *Tester) routingInfo(ctx context.Context) []string {
+	cni := t.detectCNI(ctx)
+	info := []string{fmt.Sprintf("cni: %s", cni.Name)}
+	if cni.Name == CNICilium {
+		if config, err := t.getCiliumConfig(ctx); err == nil {
+			mode, protocol := ciliumRoutingMode(config)
+			if mode == "tunnel" {
+				mode = fmt.Sprintf("tunnel (%s)", protocol)
+			}
+			info = append(info, fmt.Sprintf("cilium routing-mode: %s", mode))
+		}
+	}
+	return append(info, fmt.Sprintf("kube-proxy mode: %s", t.DetectKubeProxyMode(ctx).Mode))
+}
+
+// AttachNetworkContext fills the network context of a test result from what the observer saw: source and target
+// pod addresses and nodes, the Service IP, every test pod and Service, and the routing setup. Values a test set
+// itself are kept.
+func (t *Tester) AttachNetworkContext(ctx context.Context, result *TestResult, observer *NetworkObserver) {
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	if result.DetailedDiagnostics.NetworkContext == nil {
+		result.DetailedDiagnostics.NetworkContext = &NetworkContext{}
+	}
+	networkContext := result.DetailedDiagnostics.NetworkContext
+	setIfEmpty := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	source, target := observer.endpoints()
+	if source != nil {
+		setIfEmpty(&networkContext.SourcePodIP, source.IP)
+		setIfEmpty(&networkContext.SourceNode, source.Node)
+	}
+	if target != nil {
+		setIfEmpty(&networkContext.TargetPodIP, target.IP)
+		setIfEmpty(&networkContext.TargetNode, target.Node)
+	}
+	for _, service := range observer.services {
+		if service.ClusterIP != "" && service.ClusterIP != corev1.ClusterIPNone {
+			setIfEmpty(&networkContext.ServiceIP, service.ClusterIP)
+			break
+		}
+	}
+	if len(networkContext.RoutingInfo) == 0 {
+		networkContext.RoutingInfo = t.routingInfo(ctx)
+	}
+
+	if networkContext.AdditionalInfo == nil {
+		networkContext.AdditionalInfo = map[string]string{}
+	}
+	var pods []string
+	for i, pod := range observer.pods {
+		if i == networkContextPodLimit {
+			pods = append(pods, fmt.Sprintf("... %d more", len(observer.pods)-i))
+			break
+		}
+		pods = append(pods, fmt.Sprintf("%s %s@%s", pod.Name, valueOrUnknown(pod.IP), valueOrUnknown(pod.Node)))
+	}
+	if _, set := networkContext.AdditionalInfo["test_pods"]; !set && len(pods) > 0 {
+		networkContext.AdditionalInfo["test_pods"] = strings.Join(pods, ", ")
+	}
+	var services []string
+	for _, service := range observer.services {
+		services = append(services, fmt.Sprintf("%s %s", service.Name, service.ClusterIP))
+	}
+	if _, set := networkContext.AdditionalInfo["test_services"]; !set && len(services) > 0 {
+		networkContext.AdditionalInfo["test_services"] = strings.Join(services, ", ")
+	}
+}
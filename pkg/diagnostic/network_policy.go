@@ -31,5 +31,7 @@ package diagnostic
  *    ./build_test_k8s.sh              # Uses default tunnel mode
  *
  * The diagnostic tests should detect connectivity issues when an incompatible
- * routing mode is used for the network environment.
+ * routing mode is used for the network environment. For tunnel mode, the
+ * overlay-health test (overlay.go) checks the overlay interface, the UDP port
+ * between every node pair and that pod traffic is actually encapsulated.
  */
@@ -0,0 +1,225 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeNetworkSysctls are the kernel settings that most often explain pod and service traffic failures
+var nodeNetworkSysctls = []string{
+	"net.ipv4.ip_forward",
+	"net.ipv6.conf.all.forwarding",
+	"net.ipv4.conf.all.rp_filter",
+	"net.ipv4.conf.default.rp_filter",
+	"net.bridge.bridge-nf-call-iptables",
+	"net.netfilter.nf_conntrack_max",
+}
+
+// NodeInterface is one network interface of a node
+type NodeInterface struct {
+	Name  string `json:"name"`
+	MTU   int    `json:"mtu"`
+	State string `json:"state,omitempty"`
+}
+
+// NodeNetworkInfo is the network environment of one node
+type NodeNetworkInfo struct {
+	Node             string            `json:"node"`
+	KernelVersion    string            `json:"kernel_version"`
+	Sysctls          map[string]string `json:"sysctls,omitempty"`
+	Interfaces       []NodeInterface   `json:"interfaces,omitempty"`
+	DefaultRoutes    []string          `json:"default_routes,omitempty"`
+	DefaultInterface string            `json:"default_interface,omitempty"`
+	DefaultMTU       int               `json:"default_mtu,omitempty"` // MTU of the default-route interface
+	Error            string            `json:"error,omitempty"`
+}
+
+// nodeNetworkScript prints the kernel version, sysctls, links and default routes as prefixed lines
+func nodeNetworkScript() string {
+	return fmt.Sprintf(`echo "kernel $(uname -r)"
+for key in %s; do echo "sysctl $key $(sysctl -n $key 2>/dev/null)"; done
+ip -o link show | sed 's/^/link /'
+ip route show default | sed 's/^/route /'
+ip -6 route show default | sed 's/^/route /'`, strings.Join(nodeNetworkSysctls, " "))
+}
+
+// parseNodeNetwork fills info from the output of nodeNetworkScript
+func parseNodeNetwork(info *NodeNetworkInfo, output string) {
+	info.Sysctls = make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		kind, rest, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		switch kind {
+		case "kernel":
+			info.KernelVersion = rest
+		case "sysctl":
+			if key, value, found := strings.Cut(rest, " "); found && value != "" {
+				info.Sysctls[key] = value
+			}
+		case "link":
+			// 2: eth0@if5: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP mode DEFAULT ...
+			fields := strings.Fields(rest)
+			if len(fields) < 2 {
+				continue
+			}
+			name, _, _ := strings.Cut(strings.TrimSuffix(fields[1], ":"), "@")
+			iface := NodeInterface{Name: name}
+			for i := 2; i+1 < len(fields); i++ {
+				switch fields[i] {
+				case "mtu":
+					iface.MTU, _ = strconv.Atoi(fields[i+1])
+				case "state":
+					iface.State = fields[i+1]
+				}
+			}
+			info.Interfaces = append(info.Interfaces, iface)
+		case "route":
+			info.DefaultRoutes = append(info.DefaultRoutes, rest)
+			if info.DefaultInterface == "" {
+				fields := strings.Fields(rest)
+				for i := 0; i+1 < len(fields); i++ {
+					if fields[i] == "dev" {
+						info.DefaultInterface = fields[i+1]
+					}
+				}
+			}
+		}
+	}
+	for _, iface := range info.Interfaces {
+		if iface.Name == info.DefaultInterface {
+			info.DefaultMTU = iface.MTU
+		}
+	}
+}
+
+// collectNodeNetworkInfo reads the network environment of one node through a short-lived privileged pod
+func (t *Tester) collectNodeNetworkInfo(ctx context.Context, nodeName, kernelVersion string) NodeNetworkInfo {
+	// The kubelet-reported kernel version is kept if the pod cannot run
+	info := NodeNetworkInfo{Node: nodeName, KernelVersion: kernelVersion}
+
+	podName := fmt.Sprintf("node-env-%s", nodeName)
+	if len(podName) > 63 {
+		podName = strings.TrimRight(podName[:63], "-.")
+	}
+	if _, err := t.createPrivilegedDebugPod(ctx, podName, nodeName); err != nil {
+		info.Error = fmt.Sprintf("failed to create privileged pod: %v", err)
+		return info
+	}
+	defer t.cleanupPod(ctx, podName)
+	if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+		info.Error = fmt.Sprintf("privileged pod did not become ready: %v", err)
+		return info
+	}
+
+	output, err := t.execInPod(ctx, t.namespace, podName, "netshoot", []string{"sh", "-c", nodeNetworkScript()})
+	if err != nil {
+		info.Error = fmt.Sprintf("failed to read node network settings: %v", err)
+		return info
+	}
+	parseNodeNetwork(&info, output)
+	return info
+}
+
+// CollectNodeNetwork gathers kernel version, forwarding and rp_filter sysctls, interface MTUs and default routes
+// from every node in parallel. The result is cached on the Tester.
+func (t *Tester) CollectNodeNetwork(ctx context.Context) []NodeNetworkInfo {
+	if t.nodeNetwork != nil {
+		return t.nodeNetwork
+	}
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return []NodeNetworkInfo{{Error: fmt.Sprintf("failed to list nodes: %v", err)}}
+	}
+
+	infos := make([]NodeNetworkInfo, len(nodes.Items))
+	var wg sync.WaitGroup
+	for i, node := range nodes.Items {
+		if isWindowsNode(node) {
+			infos[i] = NodeNetworkInfo{Node: node.Name, KernelVersion: node.Status.NodeInfo.KernelVersion, Error: "Windows node - not inspected"}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, nodeName, kernelVersion string) {
+			defer wg.Done()
+			infos[i] = t.collectNodeNetworkInfo(ctx, nodeName, kernelVersion)
+		}(i, node.Name, node.Status.NodeInfo.KernelVersion)
+	}
+	wg.Wait()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Node < infos[j].Node })
+	t.nodeNetwork = infos
+	return infos
+}
+
+// nodeNetworkFindings correlates the node environments with known causes of connectivity failures
+func (t *Tester) nodeNetworkFindings(ctx context.Context, infos []NodeNetworkInfo) []string {
+	nativeRouting := false
+	if t.isCilium(ctx) {
+		if ciliumConfig, err := t.getCiliumConfig(ctx); err == nil {
+			nativeRouting = ciliumConfig["routing-mode"] == "native" || ciliumConfig["tunnel"] == "disabled"
+		}
+	} else {
+		// Calico BGP, Flannel host-gw, kindnet and the AWS VPC CNI all route pod traffic natively
+		nativeRouting = t.detectCNI(ctx).Name != CNIUnknown
+	}
+	kubeProxyMode := t.DetectKubeProxyMode(ctx).Mode
+
+	var findings []string
+	mtus := make(map[int][]string)
+	for _, info := range infos {
+		if info.Error != "" {
+			continue
+		}
+		if value := info.Sysctls["net.ipv4.ip_forward"]; value == "0" {
+			findings = append(findings, fmt.Sprintf("net.ipv4.ip_forward=0 on %s - the node does not forward pod traffic", info.Node))
+		}
+		if nativeRouting && (info.Sysctls["net.ipv4.conf.all.rp_filter"] == "1" || info.Sysctls["net.ipv4.conf.default.rp_filter"] == "1") {
+			findings = append(findings, fmt.Sprintf("Strict reverse path filtering (rp_filter=1) on %s with native routing drops asymmetrically routed pod traffic - set it to 0 or 2", info.Node))
+		}
+		if value := info.Sysctls["net.bridge.bridge-nf-call-iptables"]; value == "0" && (kubeProxyMode == KubeProxyModeIPTables || kubeProxyMode == KubeProxyModeIPVS) {
+			findings = append(findings, fmt.Sprintf("net.bridge.bridge-nf-call-iptables=0 on %s - bridged pod traffic bypasses kube-proxy service rules", info.Node))
+		}
+		if info.DefaultInterface == "" {
+			findings = append(findings, fmt.Sprintf("No default route on %s", info.Node))
+		} else if info.DefaultMTU > 0 {
+			mtus[info.DefaultMTU] = append(mtus[info.DefaultMTU], info.Node)
+		}
+	}
+	if len(mtus) > 1 {
+		var parts []string
+		for mtu, nodeNames := range mtus {
+			parts = append(parts, fmt.Sprintf("%d on %s", mtu, strings.Join(nodeNames, ", ")))
+		}
+		sort.Strings(parts)
+		findings = append(findings, fmt.Sprintf("Default-route interface MTU differs across nodes (%s) - the CNI MTU must fit the smallest", strings.Join(parts, "; ")))
+	}
+	return findings
+}
+
+// AttachNodeNetwork adds the node network findings to the diagnostics of a failed test, collecting the node
+// environments on first use
+func (t *Tester) AttachNodeNetwork(ctx context.Context, result *TestResult) {
+	if result.Success {
+		return
+	}
+	findings := t.nodeNetworkFindings(ctx, t.CollectNodeNetwork(ctx))
+	if len(findings) == 0 {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	for _, finding := range findings {
+		result.Details = append(result.Details, fmt.Sprintf("⚠️ %s", finding))
+	}
+	result.DetailedDiagnostics.TroubleshootingHints = append(result.DetailedDiagnostics.TroubleshootingHints, findings...)
+}
@@ -0,0 +1,89 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodePortExternalProbeLocal probes the NodePorts from the machine running the CLI; any other value of
+// --nodeport-external-probe is an SSH destination the probe runs on
+const NodePortExternalProbeLocal = "local"
+
+// externalProbeLocation describes where the external NodePort probe runs for test details
+func externalProbeLocation(probe string) string {
+	if probe == NodePortExternalProbeLocal {
+		return "the CLI host"
+	}
+	return fmt.Sprintf("%s over SSH", probe)
+}
+
+// externalNodePortTargets returns the addresses an outside client would use per node: the ExternalIPs, or the
+// InternalIPs of nodes without one (on-premises clusters usually route those)
+func externalNodePortTargets(nodes []corev1.Node) []nodePortProbeResult {
+	var targets []nodePortProbeResult
+	for _, node := range nodes {
+		addressType := corev1.NodeInternalIP
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeExternalIP {
+				addressType = corev1.NodeExternalIP
+				break
+			}
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType {
+				targets = append(targets, nodePortProbeResult{
+					NodeName:    node.Name,
+					AddressType: address.Type,
+					Address:     address.Address,
+				})
+			}
+		}
+	}
+	return targets
+}
+
+// sshHTTPProbe runs the curl probe on an SSH destination ([user@]host or ssh://[user@]host[:port]) and returns the
+// status code formatted like the pod probes
+func (t *Tester) sshHTTPProbe(ctx context.Context, destination, target string, options HTTPOptions) (string, error) {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=10", destination, "--", shellJoin(options.curlCommand(target)...)}
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	t.steps.recordCommand(shellJoin(cmd.Args...))
+	output, err := cmd.Output()
+	response := parseHTTPProbe(string(output))
+	if response.StatusCode == 0 {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 255 {
+			return "", fmt.Errorf("ssh to %s failed: %s", destination, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		if err != nil {
+			return "", fmt.Errorf("curl on %s failed: %v", destination, err)
+		}
+		return "", fmt.Errorf("no HTTP response")
+	}
+	return fmt.Sprintf("%03d", response.StatusCode), nil
+}
+
+// probeNodePortsExternally requests the NodePort on every externally used node address from the configured location
+func (t *Tester) probeNodePortsExternally(ctx context.Context, config TestConfig, nodes []corev1.Node, nodePort int) []nodePortProbeResult {
+	probes := externalNodePortTargets(nodes)
+	for i := range probes {
+		probe := &probes[i]
+		target := fmt.Sprintf("%s:%d", httpTargetForIP(probe.Address), nodePort)
+		var statusCode string
+		var err error
+		if config.NodePortExternalProbe == NodePortExternalProbeLocal {
+			statusCode, err = hostHTTPProbe(ctx, target, config.HTTP)
+		} else {
+			statusCode, err = t.sshHTTPProbe(ctx, config.NodePortExternalProbe, target, config.HTTP)
+		}
+		if err != nil {
+			probe.Status = fmt.Sprintf("error: %v", err)
+			continue
+		}
+		probe.Reachable, probe.Status = config.HTTP.evaluate(statusCode)
+	}
+	return probes
+}
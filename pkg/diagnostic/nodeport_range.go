@@ -0,0 +1,272 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultNodePortRange is the API server's --service-node-port-range when the flag is not set or not visible
+	defaultNodePortRange = "30000-32767"
+	// nodePortUsageWarning is the share of the range in use above which the allocation is reported as nearly exhausted
+	nodePortUsageWarning = 0.8
+)
+
+// nodePortRange is an inclusive port range
+type nodePortRange struct {
+	First int
+	Last  int
+}
+
+// parseNodePortRange parses the --service-node-port-range formats "30000-32767" and "30000+2767"
+func parseNodePortRange(value string) (nodePortRange, error) {
+	if first, size, ok := strings.Cut(value, "+"); ok {
+		base, errBase := strconv.Atoi(strings.TrimSpace(first))
+		offset, errSize := strconv.Atoi(strings.TrimSpace(size))
+		if errBase != nil || errSize != nil {
+			return nodePortRange{}, fmt.Errorf("invalid port range %q", value)
+		}
+		return nodePortRange{First: base, Last: base + offset}, nil
+	}
+	first, last, ok := strings.Cut(value, "-")
+	if !ok {
+		return nodePortRange{}, fmt.Errorf("invalid port range %q", value)
+	}
+	base, errFirst := strconv.Atoi(strings.TrimSpace(first))
+	end, errLast := strconv.Atoi(strings.TrimSpace(last))
+	if errFirst != nil || errLast != nil || end < base {
+		return nodePortRange{}, fmt.Errorf("invalid port range %q", value)
+	}
+	return nodePortRange{First: base, Last: end}, nil
+}
+
+// Size is the number of ports in the range
+func (r nodePortRange) Size() int {
+	return r.Last - r.First + 1
+}
+
+// Contains reports whether port is in the range
+func (r nodePortRange) Contains(port int) bool {
+	return port >= r.First && port <= r.Last
+}
+
+// String formats the range like the API server flag
+func (r nodePortRange) String() string {
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// nodePortListeners are the ports inside the NodePort range host processes listen on, with the nodes per port
+type nodePortListeners map[int][]string
+
+// listeningPorts parses `ss -Htuln` output into the local ports in the range
+func listeningPorts(output string, portRange nodePortRange) []int {
+	var ports []int
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		// netid state recv-q send-q local peer
+		local := fields[4]
+		if !strings.HasPrefix(fields[0], "tcp") && !strings.HasPrefix(fields[0], "udp") {
+			local = fields[3]
+		}
+		_, portText, err := net.SplitHostPort(strings.ReplaceAll(local, "%", "_"))
+		if err != nil {
+			continue
+		}
+		if port, err := strconv.Atoi(portText); err == nil && portRange.Contains(port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// serviceNodePortRange reads --service-node-port-range from the kube-apiserver static pods; the boolean is false on
+// managed control planes where the flag is not visible and the default range is assumed
+func (t *Tester) serviceNodePortRange(ctx context.Context) (nodePortRange, bool) {
+	if value := t.kubeAPIServerFlag(ctx, "--service-node-port-range"); value != "" {
+		if portRange, err := parseNodePortRange(value); err == nil {
+			return portRange, true
+		}
+	}
+	portRange, _ := parseNodePortRange(defaultNodePortRange)
+	return portRange, false
+}
+
+// scanNodePortListeners lists the host listeners inside the NodePort range on every node from hostNetwork pods
+func (t *Tester) scanNodePortListeners(ctx context.Context, nodes []string, portRange nodePortRange) (nodePortListeners, []string, []CommandOutput) {
+	podNames := make([]string, len(nodes))
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, podName := range podNames {
+			if podName != "" {
+				t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+			}
+		}
+	}()
+	for i, node := range nodes {
+		podName := fmt.Sprintf("netshoot-nodeport-scan-%d", i)
+		if _, err := t.createHostNetworkPod(ctx, podName, node); err == nil {
+			podNames[i] = podName
+		}
+	}
+
+	listeners := nodePortListeners{}
+	var unscanned []string
+	var outputs []CommandOutput
+	for i, node := range nodes {
+		if podNames[i] == "" || t.waitForPodReady(ctx, podNames[i], 60*time.Second) != nil {
+			unscanned = append(unscanned, node)
+			continue
+		}
+		output, err := t.execInPodWithOutput(ctx, t.namespace, podNames[i], "netshoot", []string{"ss", "-Htuln"},
+			fmt.Sprintf("List listening sockets on %s", node))
+		if err != nil {
+			unscanned = append(unscanned, node)
+			continue
+		}
+		outputs = append(outputs, output)
+		seen := map[int]bool{}
+		for _, port := range listeningPorts(output.Stdout, portRange) {
+			if !seen[port] {
+				seen[port] = true
+				listeners[port] = append(listeners[port], node)
+			}
+		}
+	}
+	return listeners, unscanned, outputs
+}
+
+// TestNodePortRange reads the cluster's NodePort range, reports how much of it Services have allocated and finds host
+// processes listening inside it, which collide with a NodePort allocated on the same number. The listeners are kept
+// for the nodeport test, which warns when the port it is assigned is one of them.
+func (t *Tester) TestNodePortRange(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: The range
+	portRange, configured := t.serviceNodePortRange(ctx)
+	if configured {
+		details = append(details, fmt.Sprintf("✓ NodePort range %s (%d ports) from the kube-apiserver --service-node-port-range flag", portRange, portRange.Size()))
+	} else {
+		details = append(details, fmt.Sprintf("ℹ️ --service-node-port-range not visible (managed control plane or default); assuming %s", portRange))
+	}
+
+	// Step 2: Allocated node ports across all namespaces
+	services, err := t.clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list services: %v", err),
+			Details: details,
+		}
+	}
+	allocated := map[int]string{}
+	var outside []string
+	for _, service := range services.Items {
+		if service.Spec.Type != corev1.ServiceTypeNodePort && service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			continue
+		}
+		for _, port := range service.Spec.Ports {
+			if port.NodePort == 0 {
+				continue
+			}
+			name := fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+			allocated[int(port.NodePort)] = name
+			if !portRange.Contains(int(port.NodePort)) {
+				outside = append(outside, fmt.Sprintf("%s:%d", name, port.NodePort))
+			}
+		}
+	}
+	usage := float64(len(allocated)) / float64(portRange.Size())
+	details = append(details, fmt.Sprintf("ℹ️ %d node ports allocated by Services (%.1f%% of the range, %d free)", len(allocated), usage*100, portRange.Size()-len(allocated)))
+	var warnings []string
+	if usage >= nodePortUsageWarning {
+		warnings = append(warnings, fmt.Sprintf("NodePort range is %.0f%% allocated - new NodePort and LoadBalancer Services will fail once it is exhausted", usage*100))
+	}
+	if len(outside) > 0 && configured {
+		warnings = append(warnings, fmt.Sprintf("node ports outside the configured range (range changed after they were allocated): %s", strings.Join(outside, ", ")))
+	}
+
+	// Step 3: Host processes listening inside the range on every node
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	var nodeNames []string
+	for _, node := range nodes.Items {
+		if isWindowsNode(node) {
+			details = append(details, fmt.Sprintf("ℹ️ Windows node %s not scanned - the scan pods are Linux-only", node.Name))
+			continue
+		}
+		nodeNames = append(nodeNames, node.Name)
+	}
+	listeners, unscanned, outputs := t.scanNodePortListeners(ctx, nodeNames, portRange)
+	t.nodePortListeners = listeners
+	details = append(details, fmt.Sprintf("✓ Scanned listening sockets on %d of %d nodes", len(nodeNames)-len(unscanned), len(nodeNames)))
+	if len(unscanned) > 0 {
+		details = append(details, fmt.Sprintf("⚠️ Could not scan %s (hostNetwork pod not ready)", strings.Join(unscanned, ", ")))
+	}
+
+	ports := make([]int, 0, len(listeners))
+	for port := range listeners {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	var conflicts []string
+	for _, port := range ports {
+		nodes := strings.Join(listeners[port], ", ")
+		if service, ok := allocated[port]; ok {
+			// kube-proxy and the CNI may hold the sockets of their own NodePorts; compare with the owning Service
+			details = append(details, fmt.Sprintf("ℹ️ Port %d is NodePort of %s and has a host listener on %s", port, service, nodes))
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%d (%s)", port, nodes))
+	}
+	if len(conflicts) > 0 {
+		warnings = append(warnings, fmt.Sprintf("host processes listen inside the NodePort range on ports a Service may be assigned: %s", strings.Join(conflicts, "; ")))
+	}
+
+	for _, warning := range warnings {
+		details = append(details, "⚠️ "+warning)
+	}
+
+	message := fmt.Sprintf("NodePort range %s: %d of %d ports allocated, %d conflicting host listener port(s)", portRange, len(allocated), portRange.Size(), len(conflicts))
+	result := TestResult{
+		Success: true,
+		Message: message,
+		Details: details,
+		Metrics: map[string]float64{
+			"range_size":        float64(portRange.Size()),
+			"allocated":         float64(len(allocated)),
+			"usage_pct":         usage * 100,
+			"conflicting_ports": float64(len(conflicts)),
+			"unscanned_nodes":   float64(len(unscanned)),
+		},
+		Warnings: warnings,
+	}
+	if len(warnings) > 0 {
+		result.DetailedDiagnostics = &DetailedDiagnostics{
+			CommandOutputs: outputs,
+			TroubleshootingHints: []string{
+				"Move host services out of the NodePort range, or exclude their ports with a narrower --service-node-port-range",
+				"kube-proxy reserves NodePort sockets only in some modes; a foreign listener on a NodePort receives traffic the Service should get",
+				"Free unused NodePort and LoadBalancer Services, or set allocateLoadBalancerNodePorts: false where the load balancer routes to pods directly",
+			},
+		}
+	}
+	return result
+}
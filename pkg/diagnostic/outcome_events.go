@@ -0,0 +1,130 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Event reasons and the summary ConfigMap name used to publish test outcomes in the cluster
+const (
+	EventReasonTestPassed  = "DiagnosticTestPassed"
+	EventReasonTestWarning = "DiagnosticTestWarning"
+	EventReasonTestFailed  = "DiagnosticTestFailed"
+	EventReasonTestSkipped = "DiagnosticTestSkipped"
+	SummaryConfigMapName   = "k8s-diagnostic-summary"
+)
+
+// eventMessageLimit keeps event messages within what the API server accepts
+const eventMessageLimit = 1024
+
+// EmitTestEvent records a test outcome as an Event on the test namespace, Normal for a pass and Warning for a
+// failure, so alerting and operators in the cluster can react without reading the local reports. A skipped test is
+// a Normal event, so unmet preconditions do not page anyone; a test passing with warnings is a Warning event with its
+// own reason, so alerts on DiagnosticTestFailed stay quiet for degraded but working paths.
+func (t *Tester) EmitTestEvent(ctx context.Context, testName string, result TestResult) error {
+	eventType, reason := corev1.EventTypeNormal, EventReasonTestPassed
+	message := fmt.Sprintf("%s: %s", testName, result.Message)
+	switch result.Status() {
+	case StatusSkipped:
+		reason = EventReasonTestSkipped
+	case StatusWarning:
+		eventType, reason = corev1.EventTypeWarning, EventReasonTestWarning
+		message += " (" + strings.Join(result.Warnings, "; ") + ")"
+	case StatusFailed:
+		eventType, reason = corev1.EventTypeWarning, EventReasonTestFailed
+	}
+	if len(message) > eventMessageLimit {
+		message = message[:eventMessageLimit-3] + "..."
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "k8s-diagnostic-",
+			Namespace:    t.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "k8s-diagnostic",
+			},
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+			Name:       t.namespace,
+			Namespace:  t.namespace,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: "k8s-diagnostic"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err := t.clientset.CoreV1().Events(t.namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create event for %s: %v", testName, err)
+	}
+	return nil
+}
+
+// WriteSummaryConfigMap creates or replaces the summary ConfigMap in the test namespace with the outcome of the run
+func (t *Tester) WriteSummaryConfigMap(ctx context.Context, testNames []string, results []TestResult) error {
+	passed := 0
+	var warningTests, failedTests, skippedTests []string
+	for i, result := range results {
+		switch result.Status() {
+		case StatusPassed:
+			passed++
+		case StatusWarning:
+			warningTests = append(warningTests, testNames[i])
+		case StatusSkipped:
+			skippedTests = append(skippedTests, testNames[i])
+		default:
+			failedTests = append(failedTests, testNames[i])
+		}
+	}
+	status := StatusPassed
+	if len(failedTests) > 0 {
+		status = StatusFailed
+	} else if len(warningTests) > 0 {
+		status = StatusWarning
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SummaryConfigMapName,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "k8s-diagnostic",
+			},
+		},
+		Data: map[string]string{
+			"status":        status,
+			"total":         strconv.Itoa(len(results)),
+			"passed":        strconv.Itoa(passed),
+			"warnings":      strconv.Itoa(len(warningTests)),
+			"warning_tests": strings.Join(warningTests, "\n"),
+			"failed":        strconv.Itoa(len(failedTests)),
+			"failed_tests":  strings.Join(failedTests, "\n"),
+			"skipped":       strconv.Itoa(len(skippedTests)),
+			"skipped_tests": strings.Join(skippedTests, "\n"),
+			"completed_at":  time.Now().Format(time.RFC3339),
+		},
+	}
+
+	configMaps := t.clientset.CoreV1().ConfigMaps(t.namespace)
+	_, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write summary ConfigMap %s: %v", SummaryConfigMapName, err)
+	}
+	return nil
+}
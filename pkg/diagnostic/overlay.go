@@ -0,0 +1,526 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// overlayListenWindow is how long each node captures incoming overlay-port datagrams during the pair check
+	overlayListenWindow = 10 * time.Second
+	// overlayProbePayload marks the datagrams sent by the pair check
+	overlayProbePayload = "k8s-diagnostic-overlay-probe"
+)
+
+// overlayPacketPattern matches a tcpdump line for a UDP datagram, capturing source and destination address and
+// destination port, e.g. "eth0 In  IP 10.0.0.2.41234 > 10.0.0.3.8472: UDP, length 28"
+var overlayPacketPattern = regexp.MustCompile(`IP6? (\S+)\.\d+ > (\S+)\.(\d+): `)
+
+// overlayLinkPattern matches the header line of `ip -d link show`, e.g. "7: cilium_vxlan: <BROADCAST,...>"
+var overlayLinkPattern = regexp.MustCompile(`^\d+: ([^:@]+)(@\S+)?: <`)
+
+// overlayConfig is the UDP overlay the CNI runs between nodes
+type overlayConfig struct {
+	Protocol  string // "vxlan" or "geneve"
+	Port      int
+	Interface string // device the CNI creates on every node
+	Source    string // configuration the overlay was derived from
+	// Partial is set when not all pod traffic between nodes is encapsulated (Calico CrossSubnet, Cilium with
+	// WireGuard), so the encapsulation check is informational
+	Partial string
+}
+
+// overlayDevice is a VXLAN or Geneve interface found on a node
+type overlayDevice struct {
+	Name string
+	Kind string
+	Port int
+}
+
+// overlayNode is a node taking part in the overlay checks, with the debug pod running on it
+type overlayNode struct {
+	Name      string
+	Address   string   // InternalIP the overlay probes are sent to
+	Addresses []string // every address of the node, to attribute received datagrams
+	Pod       string
+}
+
+// ciliumRoutingMode returns the routing mode and tunnel protocol from cilium-config, applying Cilium's defaults
+// (tunnel mode with VXLAN)
+func ciliumRoutingMode(config map[string]string) (string, string) {
+	mode, protocol := config["routing-mode"], config["tunnel-protocol"]
+	// Cilium before 1.14 configures both with the single "tunnel" key
+	if legacy := config["tunnel"]; legacy == "disabled" {
+		mode = "native"
+	} else if legacy != "" && mode == "" {
+		mode, protocol = "tunnel", legacy
+	}
+	if mode == "" {
+		mode = "tunnel"
+	}
+	if protocol == "" {
+		protocol = "vxlan"
+	}
+	return mode, protocol
+}
+
+// detectOverlay derives the UDP overlay from the CNI configuration. It returns nil and the reason when the cluster
+// does not run one.
+func (t *Tester) detectOverlay(ctx context.Context, cni CNIInfo) (*overlayConfig, string) {
+	switch cni.Name {
+	case CNICilium:
+		config, err := t.getCiliumConfig(ctx)
+		if err != nil {
+			return nil, fmt.Sprintf("cilium-config is not readable: %v", err)
+		}
+		mode, protocol := ciliumRoutingMode(config)
+		if mode != "tunnel" {
+			return nil, fmt.Sprintf("Cilium uses %s routing (routing-mode=%s)", mode, mode)
+		}
+		overlay := &overlayConfig{Protocol: protocol, Port: 8472, Interface: "cilium_" + protocol, Source: fmt.Sprintf("cilium-config tunnel-protocol=%s", protocol)}
+		if protocol == "geneve" {
+			overlay.Port = 6081
+		}
+		if port, err := strconv.Atoi(config["tunnel-port"]); err == nil && port > 0 {
+			overlay.Port = port
+		}
+		if config["enable-wireguard"] == "true" {
+			overlay.Partial = "WireGuard encrypts pod traffic between nodes outside the tunnel"
+		}
+		return overlay, ""
+	case CNICalico:
+		pools, err := t.listCalicoIPPools(ctx)
+		if err != nil {
+			return nil, fmt.Sprintf("Calico IPPools are not readable: %v", err)
+		}
+		overlay := &overlayConfig{Protocol: "vxlan", Port: 4789, Interface: "vxlan.calico"}
+		ipip := false
+		for _, pool := range pools {
+			if pool.Disabled {
+				continue
+			}
+			ipip = ipip || pool.IPIPMode != "Never"
+			if pool.VXLANMode == "Never" {
+				continue
+			}
+			overlay.Source = fmt.Sprintf("Calico IPPool %s vxlanMode=%s", pool.Name, pool.VXLANMode)
+			if pool.VXLANMode == "CrossSubnet" {
+				overlay.Partial = "vxlanMode=CrossSubnet only encapsulates traffic between nodes in different subnets"
+			}
+		}
+		if overlay.Source != "" {
+			return overlay, ""
+		}
+		if ipip {
+			return nil, "Calico uses IPIP (IP protocol 4), not a UDP overlay"
+		}
+		return nil, "No Calico IPPool uses VXLAN"
+	case CNIFlannel:
+		configMap, err := t.clientset.CoreV1().ConfigMaps(cni.Namespace).Get(ctx, "kube-flannel-cfg", metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Sprintf("kube-flannel-cfg is not readable: %v", err)
+		}
+		var netConf struct {
+			Backend struct {
+				Type string
+				Port int
+				VNI  int
+			}
+		}
+		if err := json.Unmarshal([]byte(configMap.Data["net-conf.json"]), &netConf); err != nil {
+			return nil, fmt.Sprintf("kube-flannel-cfg net-conf.json is not valid JSON: %v", err)
+		}
+		if netConf.Backend.Type != "vxlan" {
+			return nil, fmt.Sprintf("flannel uses the %s backend", netConf.Backend.Type)
+		}
+		overlay := &overlayConfig{Protocol: "vxlan", Port: 8472, Interface: "flannel.1", Source: "kube-flannel-cfg Backend.Type=vxlan"}
+		if netConf.Backend.Port > 0 {
+			overlay.Port = netConf.Backend.Port
+		}
+		if netConf.Backend.VNI > 0 {
+			overlay.Interface = fmt.Sprintf("flannel.%d", netConf.Backend.VNI)
+		}
+		return overlay, ""
+	}
+	return nil, fmt.Sprintf("Overlay detection is not supported for CNI %s", cni.Name)
+}
+
+// parseOverlayDevices parses `ip -d link show type vxlan` and `ip -d link show type geneve` output
+func parseOverlayDevices(output string) []overlayDevice {
+	var devices []overlayDevice
+	for _, line := range strings.Split(output, "\n") {
+		if match := overlayLinkPattern.FindStringSubmatch(line); match != nil {
+			devices = append(devices, overlayDevice{Name: match[1]})
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(devices) == 0 || len(fields) == 0 || (fields[0] != "vxlan" && fields[0] != "geneve") {
+			continue
+		}
+		device := &devices[len(devices)-1]
+		device.Kind = fields[0]
+		for i := 1; i+1 < len(fields); i++ {
+			if fields[i] == "dstport" {
+				device.Port, _ = strconv.Atoi(fields[i+1])
+			}
+		}
+	}
+	return devices
+}
+
+// overlayDeviceFindings checks that the CNI's overlay interface exists and that no other VXLAN or Geneve interface
+// uses the same UDP port. Other overlay interfaces on different ports are returned as notes.
+func overlayDeviceFindings(nodeName string, overlay *overlayConfig, devices []overlayDevice) ([]string, []string) {
+	var problems, notes []string
+	found := false
+	for _, device := range devices {
+		switch {
+		case device.Name == overlay.Interface:
+			found = true
+			if device.Kind != overlay.Protocol || device.Port != overlay.Port {
+				problems = append(problems, fmt.Sprintf("%s: %s is %s on UDP %d, expected %s on UDP %d", nodeName, device.Name, device.Kind, device.Port, overlay.Protocol, overlay.Port))
+			}
+		case device.Port == overlay.Port:
+			problems = append(problems, fmt.Sprintf("%s: %s (%s) also uses UDP %d - it competes with %s for the overlay traffic", nodeName, device.Name, device.Kind, device.Port, overlay.Interface))
+		default:
+			notes = append(notes, fmt.Sprintf("%s: other overlay interface %s (%s, UDP %d)", nodeName, device.Name, device.Kind, device.Port))
+		}
+	}
+	if !found {
+		problems = append(problems, fmt.Sprintf("%s: overlay interface %s is missing", nodeName, overlay.Interface))
+	}
+	return problems, notes
+}
+
+// overlayReceivedFrom parses tcpdump output captured on a node and returns the sources seen sending to any of the
+// node's addresses on the overlay port
+func overlayReceivedFrom(output string, node overlayNode, port int) map[string]bool {
+	local := make(map[string]bool)
+	for _, address := range node.Addresses {
+		local[address] = true
+	}
+	sources := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		match := overlayPacketPattern.FindStringSubmatch(line)
+		if match == nil || !local[match[2]] || match[3] != strconv.Itoa(port) {
+			continue
+		}
+		sources[match[1]] = true
+	}
+	return sources
+}
+
+// overlaySendCommand sends three marked datagrams to the overlay port of every target address
+func overlaySendCommand(targets []string, port int) []string {
+	var sends []string
+	for _, target := range targets {
+		address := fmt.Sprintf("UDP4-SENDTO:%s:%d", target, port)
+		if ip := net.ParseIP(target); ip != nil && ip.To4() == nil {
+			address = fmt.Sprintf("UDP6-SENDTO:[%s]:%d", target, port)
+		}
+		for i := 0; i < 3; i++ {
+			sends = append(sends, fmt.Sprintf("echo %s | socat -u - %s", overlayProbePayload, address))
+		}
+	}
+	return []string{"sh", "-c", strings.Join(sends, "; ")}
+}
+
+// TestOverlayHealth checks the VXLAN/Geneve overlay of tunnel routing modes: the overlay interface exists on every
+// node without a conflicting interface on the same UDP port, every node receives datagrams on the overlay port from
+// every other node, and cross-node pod traffic actually travels encapsulated
+func (t *Tester) TestOverlayHealth(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	// Step 1: Overlay protocol, port and interface from the CNI configuration
+	cni := t.detectCNI(ctx)
+	overlay, reason := t.detectOverlay(ctx, cni)
+	if overlay == nil {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Overlay health check skipped - %s", reason),
+			Details: []string{fmt.Sprintf("ℹ️ CNI: %s", cni.Name)},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ %s overlay %s on UDP %d, interface %s (%s)", cni.Name, overlay.Protocol, overlay.Port, overlay.Interface, overlay.Source))
+
+	// Step 2: A privileged host-network debug pod on every node
+	nodeList, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	var nodes []overlayNode
+	for _, node := range nodeList.Items {
+		if isWindowsNode(node) {
+			details = append(details, fmt.Sprintf("ℹ️ Windows node %s skipped - the debug pods are Linux-only", node.Name))
+			continue
+		}
+		entry := overlayNode{Name: node.Name, Pod: fmt.Sprintf("overlay-debug-%s", node.Name)}
+		if len(entry.Pod) > 63 {
+			entry.Pod = strings.TrimRight(entry.Pod[:63], "-.")
+		}
+		for _, address := range node.Status.Addresses {
+			entry.Addresses = append(entry.Addresses, address.Address)
+			if address.Type == corev1.NodeInternalIP && entry.Address == "" {
+				entry.Address = address.Address
+			}
+		}
+		if entry.Address == "" {
+			details = append(details, fmt.Sprintf("⚠️ Node %s has no InternalIP - skipped", node.Name))
+			continue
+		}
+		nodes = append(nodes, entry)
+	}
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, node := range nodes {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, node.Pod, metav1.DeleteOptions{})
+		}
+	}()
+	for _, node := range nodes {
+		if _, err := t.createPrivilegedDebugPod(ctx, node.Pod, node.Name); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create debug pod on %s: %v", node.Name, err),
+				Details: details,
+			}
+		}
+	}
+	var ready []overlayNode
+	for _, node := range nodes {
+		if err := t.waitForPodReady(ctx, node.Pod, 60*time.Second); err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Debug pod on %s not ready, node skipped: %v", node.Name, err))
+			continue
+		}
+		ready = append(ready, node)
+	}
+	if len(ready) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "No debug pod became ready on any node",
+			Details: details,
+		}
+	}
+
+	// Step 3: The CNI's overlay interface on every node, and no other interface on its port
+	var problems []string
+	for _, node := range ready {
+		output, err := t.execInPodWithOutput(ctx, t.namespace, node.Pod, "netshoot",
+			[]string{"sh", "-c", "ip -d link show type vxlan; ip -d link show type geneve"}, fmt.Sprintf("Overlay interfaces on %s", node.Name))
+		if err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Could not list overlay interfaces on %s: %v", node.Name, err))
+			continue
+		}
+		nodeProblems, notes := overlayDeviceFindings(node.Name, overlay, parseOverlayDevices(output.Stdout))
+		for _, note := range notes {
+			details = append(details, fmt.Sprintf("ℹ️ %s", note))
+		}
+		for _, problem := range nodeProblems {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		if len(nodeProblems) > 0 {
+			problems = append(problems, nodeProblems...)
+			commandOutputs = append(commandOutputs, output)
+		}
+	}
+	if len(problems) == 0 {
+		details = append(details, fmt.Sprintf("✓ %s present on %d nodes with no conflicting interface on UDP %d", overlay.Interface, len(ready), overlay.Port))
+	}
+
+	// Step 4: Every node pair - each node captures incoming datagrams on the overlay port while all others send to it
+	metrics := map[string]float64{}
+	if len(ready) > 1 {
+		captures := make([]CommandOutput, len(ready))
+		var wg sync.WaitGroup
+		for i, node := range ready {
+			wg.Add(1)
+			go func(i int, node overlayNode) {
+				defer wg.Done()
+				captures[i], _ = t.execInPodWithOutput(ctx, t.namespace, node.Pod, "netshoot",
+					[]string{"sh", "-c", fmt.Sprintf("timeout %d tcpdump -i any -nn -l udp dst port %d 2>/dev/null", int(overlayListenWindow.Seconds()), overlay.Port)},
+					fmt.Sprintf("Datagrams received on UDP %d on %s", overlay.Port, node.Name))
+			}(i, node)
+		}
+		// Give tcpdump time to attach, then send from every node to every other node
+		sleepContext(ctx, 2*time.Second)
+		var senders sync.WaitGroup
+		for _, node := range ready {
+			var targets []string
+			for _, peer := range ready {
+				if peer.Name != node.Name {
+					targets = append(targets, peer.Address)
+				}
+			}
+			senders.Add(1)
+			go func(node overlayNode, targets []string) {
+				defer senders.Done()
+				t.execInPod(ctx, t.namespace, node.Pod, "netshoot", overlaySendCommand(targets, overlay.Port))
+			}(node, targets)
+		}
+		senders.Wait()
+		wg.Wait()
+
+		var unreachable []string
+		details = append(details, fmt.Sprintf("  Overlay UDP %d reachability per node pair:", overlay.Port))
+		details = append(details, fmt.Sprintf("  %-30s %-30s %s", "FROM", "TO", "RESULT"))
+		for i, node := range ready {
+			received := overlayReceivedFrom(captures[i].Stdout, node, overlay.Port)
+			for _, peer := range ready {
+				if peer.Name == node.Name {
+					continue
+				}
+				marker := "✓ received"
+				seen := false
+				for _, address := range peer.Addresses {
+					seen = seen || received[address]
+				}
+				if !seen {
+					marker = "✗ not received"
+					unreachable = append(unreachable, fmt.Sprintf("%s -> %s", peer.Name, node.Name))
+				}
+				details = append(details, fmt.Sprintf("  %-30s %-30s %s", peer.Name, node.Name, marker))
+			}
+		}
+		pairs := len(ready) * (len(ready) - 1)
+		metrics["pairs"] = float64(pairs)
+		metrics["unreachable_pairs"] = float64(len(unreachable))
+		if len(unreachable) > 0 {
+			problems = append(problems, fmt.Sprintf("UDP %d not received for %d of %d node pairs: %s", overlay.Port, len(unreachable), pairs, strings.Join(unreachable, ", ")))
+			for i := range ready {
+				commandOutputs = append(commandOutputs, captures[i])
+			}
+		} else {
+			details = append(details, fmt.Sprintf("✓ UDP %d reachable between all %d node pairs", overlay.Port, pairs))
+		}
+	} else {
+		details = append(details, "ℹ️ Only one node - node pair and encapsulation checks need at least two")
+	}
+
+	// Step 5: Cross-node pod traffic travels encapsulated - ping between pods on two workers while capturing the
+	// overlay traffic on the source node
+	workers, _ := t.getWorkerNodes(ctx)
+	if len(workers) > 1 {
+		sourcePod, targetPod := "netshoot-overlay-1", "netshoot-overlay-2"
+		defer t.cleanupPods(ctx, sourcePod, targetPod)
+		encapProblem := ""
+		var source, target *corev1.Pod
+		if _, err := t.createNetshootPod(ctx, sourcePod, workers[0]); err != nil {
+			encapProblem = fmt.Sprintf("failed to create pod %s: %v", sourcePod, err)
+		} else if _, err := t.createNetshootPod(ctx, targetPod, workers[1]); err != nil {
+			encapProblem = fmt.Sprintf("failed to create pod %s: %v", targetPod, err)
+		}
+		for _, pod := range []string{sourcePod, targetPod} {
+			if encapProblem == "" {
+				if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+					encapProblem = fmt.Sprintf("pod %s not ready: %v", pod, err)
+				}
+			}
+		}
+		if encapProblem == "" {
+			source, _ = t.clientset.CoreV1().Pods(t.namespace).Get(ctx, sourcePod, metav1.GetOptions{})
+			target, _ = t.clientset.CoreV1().Pods(t.namespace).Get(ctx, targetPod, metav1.GetOptions{})
+			if source == nil || target == nil || source.Status.PodIP == "" || target.Status.PodIP == "" {
+				encapProblem = "test pods have no IP"
+			}
+		}
+		var captureNode *overlayNode
+		for i := range ready {
+			if ready[i].Name == workers[0] {
+				captureNode = &ready[i]
+			}
+		}
+		if encapProblem == "" && captureNode == nil {
+			encapProblem = fmt.Sprintf("no debug pod on %s", workers[0])
+		}
+
+		if encapProblem == "" {
+			// tcpdump only decodes VXLAN on 4789 by default, so force it for the CNI's port to see the inner packets
+			decode := ""
+			if overlay.Protocol == "vxlan" {
+				decode = "-T vxlan"
+			}
+			var capture CommandOutput
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				capture, _ = t.execInPodWithOutput(ctx, t.namespace, captureNode.Pod, "netshoot",
+					[]string{"sh", "-c", fmt.Sprintf("timeout 8 tcpdump -i any -nn -l %s udp port %d 2>/dev/null", decode, overlay.Port)},
+					fmt.Sprintf("Encapsulated traffic on %s during a cross-node ping", workers[0]))
+			}()
+			sleepContext(ctx, 2*time.Second)
+			ping, pingErr := t.execInPodWithOutput(ctx, t.namespace, sourcePod, "netshoot",
+				[]string{"ping", "-c", "5", "-W", "1", target.Status.PodIP}, fmt.Sprintf("Ping %s on %s", target.Status.PodIP, workers[1]))
+			wg.Wait()
+
+			encapsulated := 0
+			for _, line := range strings.Split(capture.Stdout, "\n") {
+				if strings.Contains(line, "ICMP") && strings.Contains(line, source.Status.PodIP) && strings.Contains(line, target.Status.PodIP) {
+					encapsulated++
+				}
+			}
+			metrics["encapsulated_packets"] = float64(encapsulated)
+			switch {
+			case pingErr != nil:
+				encapProblem = fmt.Sprintf("ping from %s to %s failed", workers[0], workers[1])
+				commandOutputs = append(commandOutputs, ping, capture)
+			case encapsulated > 0:
+				details = append(details, fmt.Sprintf("✓ Cross-node ping %s -> %s seen encapsulated in %s (%d packets)", source.Status.PodIP, target.Status.PodIP, overlay.Protocol, encapsulated))
+			case overlay.Partial != "":
+				details = append(details, fmt.Sprintf("ℹ️ Cross-node ping succeeded without %s encapsulation: %s", overlay.Protocol, overlay.Partial))
+			default:
+				encapProblem = fmt.Sprintf("cross-node ping succeeded but no %s-encapsulated ICMP was seen on UDP %d - traffic bypasses the overlay", overlay.Protocol, overlay.Port)
+				commandOutputs = append(commandOutputs, capture)
+			}
+		}
+		if encapProblem != "" {
+			details = append(details, fmt.Sprintf("✗ Encapsulation: %s", encapProblem))
+			problems = append(problems, fmt.Sprintf("encapsulation: %s", encapProblem))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%s overlay unhealthy: %s", overlay.Protocol, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Overlay Health",
+				TechnicalError: strings.Join(problems, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Allow UDP %d between all node addresses in host firewalls, security groups and network ACLs", overlay.Port),
+					"A missing overlay interface means the CNI agent on that node is not running or failed to set up the tunnel - check its logs",
+					"Another VXLAN interface on the same port (e.g. Docker overlay, a second CNI, leftover flannel.1) takes the traffic; remove it or move one overlay to another port",
+					"Encapsulated packets are larger by 50 (VXLAN) or more (Geneve) bytes - check the pod MTU is reduced accordingly",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%s overlay on UDP %d healthy across %d nodes", overlay.Protocol, overlay.Port, len(ready)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
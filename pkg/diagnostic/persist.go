@@ -0,0 +1,99 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// persistedRunLabel selects the ConfigMaps holding persisted run reports
+const persistedRunLabel = "k8s-diagnostic/run"
+
+// configMapDataLimit leaves headroom below the 1 MiB object size limit for metadata
+const configMapDataLimit = 900 * 1024
+
+// PersistReport writes the report into a ConfigMap in the given namespace, labelled with the run status, and deletes
+// the oldest persisted runs beyond keep, so the run history stays inspectable in the cluster after the CLI host is
+// gone. It returns the name of the ConfigMap.
+func (t *Tester) PersistReport(ctx context.Context, report *DiagnosticReportJSON, namespace string, keep int) (string, error) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal report: %v", err)
+	}
+
+	startTime, err := time.Parse(time.RFC3339, report.ExecutionInfo.Timestamp)
+	if err != nil {
+		startTime = time.Now()
+	}
+	name := fmt.Sprintf("k8s-diagnostic-run-%s", startTime.UTC().Format("20060102-150405"))
+	data := map[string]string{
+		"status":       report.Summary.OverallStatus,
+		"total":        strconv.Itoa(report.Summary.TotalTests),
+		"passed":       strconv.Itoa(report.Summary.Passed),
+		"warnings":     strconv.Itoa(report.Summary.Warnings),
+		"failed":       strconv.Itoa(report.Summary.Failed),
+		"skipped":      strconv.Itoa(report.Summary.Skipped),
+		"started_at":   report.ExecutionInfo.Timestamp,
+		"completed_at": report.Summary.CompletionTime,
+		"errors":       strings.Join(report.Summary.ErrorsEncountered, "\n"),
+	}
+	// Oversized reports keep the summary keys only; the full report stays in the local file
+	if len(content) <= configMapDataLimit {
+		data["report.json"] = string(content)
+	} else {
+		data["report_omitted"] = fmt.Sprintf("report.json is %d bytes, above the ConfigMap limit; see %s on the CLI host", len(content), report.ExecutionInfo.Filename)
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":  "k8s-diagnostic",
+				persistedRunLabel:               "true",
+				"k8s-diagnostic/status":         strings.ToLower(report.Summary.OverallStatus),
+				"k8s-diagnostic/test-namespace": report.ExecutionInfo.Namespace,
+			},
+		},
+		Data: data,
+	}
+	if _, err := t.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create ConfigMap %s/%s: %v", namespace, name, err)
+	}
+
+	if keep > 0 {
+		if err := t.prunePersistedReports(ctx, namespace, keep); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+// prunePersistedReports deletes the oldest persisted run ConfigMaps beyond keep
+func (t *Tester) prunePersistedReports(ctx context.Context, namespace string, keep int) error {
+	configMaps, err := t.clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", persistedRunLabel),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list persisted runs: %v", err)
+	}
+	items := configMaps.Items
+	if len(items) <= keep {
+		return nil
+	}
+	// Names embed the run start time, so they sort chronologically
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	for _, configMap := range items[:len(items)-keep] {
+		if err := t.clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, configMap.Name, metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("failed to delete old run %s: %v", configMap.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package diagnostic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// PingOptions tunes the ICMP probe of the pod-to-pod test, e.g. 20 packets with a 1400-byte payload to measure
+// jitter close to the MTU. Zero values keep the defaults.
+type PingOptions struct {
+	Count     int           `json:"count,omitempty"`     // echo requests per probe (default 3)
+	Interval  time.Duration `json:"interval,omitempty"`  // between echo requests (default 1s; below 200ms needs root, which netshoot has)
+	Size      int           `json:"size,omitempty"`      // payload bytes (default 56)
+	Deadline  time.Duration `json:"deadline,omitempty"`  // overall limit of one probe (default: none)
+	Interface string        `json:"interface,omitempty"` // source interface or address in the client pod (default: routed)
+	// WarnLatency is the soft latency threshold: a probe that gets through with a higher average round trip passes
+	// with a warning (default: none)
+	WarnLatency time.Duration `json:"warn_latency,omitempty"`
+}
+
+// defaultPing is the probe of the pod-to-pod test before it was configurable
+var defaultPing = PingOptions{Count: 3, Interval: time.Second}
+
+// pingReplyTimeout is how long ping waits for the last reply
+const pingReplyTimeout = 3 * time.Second
+
+// withDefaults fills the unset options from defaults
+func (o PingOptions) withDefaults(defaults PingOptions) PingOptions {
+	if o.Count <= 0 {
+		o.Count = defaults.Count
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaults.Interval
+	}
+	if o.Size <= 0 {
+		o.Size = defaults.Size
+	}
+	if o.Deadline <= 0 {
+		o.Deadline = defaults.Deadline
+	}
+	if o.Interface == "" {
+		o.Interface = defaults.Interface
+	}
+	if o.WarnLatency <= 0 {
+		o.WarnLatency = defaults.WarnLatency
+	}
+	return o
+}
+
+// command returns the ping command line probing target
+func (o PingOptions) command(target string) []string {
+	command := []string{"ping", "-c", strconv.Itoa(o.Count), "-W", strconv.Itoa(int(pingReplyTimeout.Seconds())),
+		"-i", strconv.FormatFloat(o.Interval.Seconds(), 'f', -1, 64)}
+	if o.Size > 0 {
+		command = append(command, "-s", strconv.Itoa(o.Size))
+	}
+	if o.Deadline > 0 {
+		command = append(command, "-w", strconv.Itoa(int(math.Ceil(o.Deadline.Seconds()))))
+	}
+	if o.Interface != "" {
+		command = append(command, "-I", o.Interface)
+	}
+	return append(command, target)
+}
+
+// duration is the longest one probe can take
+func (o PingOptions) duration() time.Duration {
+	if o.Deadline > 0 {
+		return o.Deadline
+	}
+	return time.Duration(o.Count-1)*o.Interval + pingReplyTimeout
+}
+
+// String describes the probe for test details
+func (o PingOptions) String() string {
+	description := fmt.Sprintf("%d packets every %s", o.Count, o.Interval)
+	if o.Size > 0 {
+		description += fmt.Sprintf(", %d-byte payload", o.Size)
+	}
+	if o.Deadline > 0 {
+		description += fmt.Sprintf(", %s deadline", o.Deadline)
+	}
+	if o.Interface != "" {
+		description += fmt.Sprintf(", via %s", o.Interface)
+	}
+	if o.WarnLatency > 0 {
+		description += fmt.Sprintf(", warning above %s average", o.WarnLatency)
+	}
+	return description
+}
+
+// latencyWarning describes an average round trip in milliseconds above the soft threshold, or returns ""
+func (o PingOptions) latencyWarning(avgLatency float64) string {
+	threshold := float64(o.WarnLatency) / float64(time.Millisecond)
+	if o.WarnLatency <= 0 || avgLatency <= threshold {
+		return ""
+	}
+	return fmt.Sprintf("average ping latency %.2fms is above the %s warning threshold", avgLatency, o.WarnLatency)
+}
@@ -0,0 +1,200 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginDescribeTimeout bounds how long an executable plugin may take to describe itself at startup
+const pluginDescribeTimeout = 10 * time.Second
+
+var (
+	registeredTestsMu sync.Mutex
+	registeredTests   = map[string]Test{}
+)
+
+// RegisterTest adds a test to the plugin registry; names must be unique
+func RegisterTest(test Test) error {
+	registeredTestsMu.Lock()
+	defer registeredTestsMu.Unlock()
+	name := test.Name()
+	if name == "" {
+		return errors.New("plugin test has no name")
+	}
+	if _, exists := registeredTests[name]; exists {
+		return fmt.Errorf("plugin test %q is already registered", name)
+	}
+	registeredTests[name] = test
+	return nil
+}
+
+// RegisteredTests returns the registered plugin tests sorted by name
+func RegisteredTests() []Test {
+	registeredTestsMu.Lock()
+	defer registeredTestsMu.Unlock()
+	var tests []Test
+	for _, test := range registeredTests {
+		tests = append(tests, test)
+	}
+	sort.Slice(tests, func(i, j int) bool { return tests[i].Name() < tests[j].Name() })
+	return tests
+}
+
+// pluginDescription is what an executable plugin prints for `<plugin> describe`
+type pluginDescription struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Timeout     string `json:"timeout,omitempty"` // Go duration the run may take beyond the default test budget
+}
+
+// pluginRequest is written to the stdin of `<plugin> run`
+type pluginRequest struct {
+	Namespace  string     `json:"namespace"`
+	Kubeconfig string     `json:"kubeconfig,omitempty"`
+	Config     TestConfig `json:"config"`
+}
+
+// executablePlugin runs a test implemented by an external executable. The executable prints a JSON
+// pluginDescription for `describe`, and for `run` reads a pluginRequest on stdin and prints a TestResult as JSON.
+type executablePlugin struct {
+	path        string
+	name        string
+	description string
+	timeout     time.Duration
+}
+
+func (p *executablePlugin) Name() string     { return p.name }
+func (p *executablePlugin) Describe() string { return p.description }
+
+// Timeout extends the run budget by the time the plugin declared in its description
+func (p *executablePlugin) Timeout() time.Duration { return p.timeout }
+
+// Run executes `<plugin> run`; a non-zero exit without a result, or output that is not a result, fails the test with
+// the plugin's output attached
+func (p *executablePlugin) Run(ctx context.Context, t *Tester, config TestConfig) TestResult {
+	request, err := json.Marshal(pluginRequest{Namespace: t.namespace, Kubeconfig: t.kubeconfig, Config: config})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode the plugin request: %v", err),
+		}
+	}
+	command := exec.CommandContext(ctx, p.path, "run")
+	command.Stdin = bytes.NewReader(request)
+	command.Env = append(os.Environ(), fmt.Sprintf("K8S_DIAGNOSTIC_NAMESPACE=%s", t.namespace))
+	if t.kubeconfig != "" {
+		command.Env = append(command.Env, fmt.Sprintf("KUBECONFIG=%s", t.kubeconfig))
+	}
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	start := time.Now()
+	runErr := command.Run()
+	output := CommandOutput{
+		Command:     fmt.Sprintf("%s run", p.path),
+		ExitCode:    command.ProcessState.ExitCode(),
+		Stdout:      stdout.String(),
+		Stderr:      stderr.String(),
+		Duration:    time.Since(start).Round(time.Millisecond).String(),
+		Description: fmt.Sprintf("Plugin %s", p.name),
+	}
+
+	var result TestResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil || result.Message == "" {
+		problem := "the plugin did not print a test result"
+		if runErr != nil {
+			problem = fmt.Sprintf("the plugin failed: %v", runErr)
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Plugin %s: %s", p.name, problem),
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Plugin Execution",
+				TechnicalError: problem,
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Run the plugin by hand: echo '{\"namespace\":\"%s\"}' | %s run", t.namespace, p.path),
+					"A plugin must print a JSON test result with at least \"success\" and \"message\" on stdout; logs belong on stderr",
+				},
+			},
+		}
+	}
+	// A skipped test is never a failure, whatever the plugin set as success
+	if result.Skipped {
+		result.Success = true
+	}
+	// A result that claims success from a failing process is not trusted
+	if runErr != nil && result.Success {
+		result.Success = false
+		result.Skipped = false
+		result.Message = fmt.Sprintf("%s (plugin exited with %v)", result.Message, runErr)
+	}
+	if !result.Success {
+		if result.DetailedDiagnostics == nil {
+			result.DetailedDiagnostics = &DetailedDiagnostics{}
+		}
+		result.DetailedDiagnostics.CommandOutputs = append(result.DetailedDiagnostics.CommandOutputs, output)
+	}
+	return result
+}
+
+// LoadExecutablePlugins describes every executable file in dir and registers it as a test. A missing directory is not
+// an error; plugins that fail to describe themselves are skipped and reported in the returned warnings.
+func LoadExecutablePlugins(ctx context.Context, dir string) ([]Test, []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, []string{fmt.Sprintf("failed to read plugin directory %s: %v", dir, err)}
+	}
+	var loaded []Test
+	var warnings []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		describeCtx, cancel := context.WithTimeout(ctx, pluginDescribeTimeout)
+		output, err := exec.CommandContext(describeCtx, path, "describe").Output()
+		cancel()
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("plugin %s: describe failed: %v", path, err))
+			continue
+		}
+		var description pluginDescription
+		if err := json.Unmarshal(output, &description); err != nil {
+			warnings = append(warnings, fmt.Sprintf("plugin %s: describe did not print JSON: %v", path, err))
+			continue
+		}
+		if description.Name == "" {
+			description.Name = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		plugin := &executablePlugin{path: path, name: description.Name, description: description.Description}
+		if description.Timeout != "" {
+			timeout, err := time.ParseDuration(description.Timeout)
+			if err != nil || timeout <= 0 {
+				warnings = append(warnings, fmt.Sprintf("plugin %s: invalid timeout %q", path, description.Timeout))
+				continue
+			}
+			plugin.timeout = timeout
+		}
+		if err := RegisterTest(plugin); err != nil {
+			warnings = append(warnings, fmt.Sprintf("plugin %s: %v", path, err))
+			continue
+		}
+		loaded = append(loaded, plugin)
+	}
+	return loaded, warnings
+}
@@ -0,0 +1,413 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultPodChurnCount is the number of pods per wave when no count is configured
+	defaultPodChurnCount = 100
+	// podChurnConcurrency is how many pod creations are in flight at once
+	podChurnConcurrency = 10
+	// podChurnWaveTimeout bounds how long one wave may take to become Ready
+	podChurnWaveTimeout = 90 * time.Second
+	// podChurnSlowIPP90 flags slow IP assignment
+	podChurnSlowIPP90 = 5 * time.Second
+	// podChurnSelector selects the pods of both waves
+	podChurnSelector = "app=pod-churn-test"
+)
+
+// ipamExhaustionPatterns are fragments of CNI sandbox errors that mean no pod IP could be allocated
+var ipamExhaustionPatterns = []string{
+	"no ip addresses available",
+	"range is full",
+	"no available ip",
+	"failed to allocate",
+	"ipam",
+	"exhausted",
+	"insufficient ip",
+}
+
+// podChurnTimes records when the watch observed a pod scheduled, with an IP and Ready, relative to its creation
+type podChurnTimes struct {
+	Node      string
+	Scheduled time.Duration
+	IP        time.Duration
+	Ready     time.Duration
+}
+
+// podChurnWave is one batch of pods created at once
+type podChurnWave struct {
+	Name  string
+	Times map[string]*podChurnTimes
+}
+
+// createChurnPod creates a pause pod spread across nodes by a soft topology spread constraint
+func createChurnPod(ctx context.Context, clientset *kubernetes.Clientset, namespace, name, image string) error {
+	gracePeriod := int64(0)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": "pod-churn-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			TerminationGracePeriodSeconds: &gracePeriod,
+			Containers: []corev1.Container{
+				{
+					Name:  "pause",
+					Image: image,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("1m"),
+							corev1.ResourceMemory: resource.MustParse("8Mi"),
+						},
+					},
+				},
+			},
+			TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,
+					TopologyKey:       "kubernetes.io/hostname",
+					WhenUnsatisfiable: corev1.ScheduleAnyway,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "pod-churn-test"},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	_, err := clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// runPodChurnWave creates count pods concurrently and follows them through the watch while they are created, until
+// all are Ready or the wave times out
+func (t *Tester) runPodChurnWave(ctx context.Context, clientset *kubernetes.Clientset, watcher watch.Interface, wave string, count int) (*podChurnWave, error) {
+	result := &podChurnWave{Name: wave, Times: map[string]*podChurnTimes{}}
+	for i := 0; i < count; i++ {
+		result.Times[fmt.Sprintf("pod-churn-%s-%d", wave, i)] = &podChurnTimes{}
+	}
+
+	// Creation times are recorded before each request so watch events can never precede them
+	var mu sync.Mutex
+	created := map[string]time.Time{}
+	createErrors := make(chan error, 1)
+	go func() {
+		names := make(chan string)
+		var wg sync.WaitGroup
+		for i := 0; i < podChurnConcurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for name := range names {
+					mu.Lock()
+					created[name] = time.Now()
+					mu.Unlock()
+					if err := createChurnPod(ctx, clientset, t.namespace, name, t.image(schedulingTestImage)); err != nil {
+						select {
+						case createErrors <- fmt.Errorf("failed to create pod %s: %v", name, err):
+						default:
+						}
+					}
+				}
+			}()
+		}
+		for i := 0; i < count; i++ {
+			names <- fmt.Sprintf("pod-churn-%s-%d", wave, i)
+		}
+		close(names)
+		wg.Wait()
+	}()
+
+	readyCount := 0
+	timeout := time.After(podChurnWaveTimeout)
+	for readyCount < count {
+		var event watch.Event
+		var ok bool
+		select {
+		case event, ok = <-watcher.ResultChan():
+		case err := <-createErrors:
+			return result, err
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if !ok {
+			break
+		}
+		pod, isPod := event.Object.(*corev1.Pod)
+		if !isPod {
+			continue
+		}
+		entry, tracked := result.Times[pod.Name]
+		if !tracked {
+			continue
+		}
+		mu.Lock()
+		since := time.Since(created[pod.Name])
+		mu.Unlock()
+		if pod.Spec.NodeName != "" {
+			entry.Node = pod.Spec.NodeName
+		}
+		if entry.Scheduled == 0 && podScheduled(pod) {
+			entry.Scheduled = since
+		}
+		if entry.IP == 0 && pod.Status.PodIP != "" {
+			entry.IP = since
+		}
+		if entry.Ready == 0 && isPodReady(pod) {
+			entry.Ready = since
+			readyCount++
+		}
+	}
+	return result, nil
+}
+
+// podChurnPercentiles returns the scheduled, IP and Ready latency samples of a wave
+func podChurnPercentiles(wave *podChurnWave) (scheduled, ip, ready []time.Duration) {
+	for _, entry := range wave.Times {
+		if entry.Scheduled > 0 {
+			scheduled = append(scheduled, entry.Scheduled)
+		}
+		if entry.IP > 0 {
+			ip = append(ip, entry.IP)
+		}
+		if entry.Ready > 0 {
+			ready = append(ready, entry.Ready)
+		}
+	}
+	return scheduled, ip, ready
+}
+
+// podCIDRUsage reports per node how many pod network addresses are in use out of the node's podCIDR, for nodes
+// whose CNI allocates from spec.podCIDR
+func (t *Tester) podCIDRUsage(ctx context.Context) []string {
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	pods, err := t.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		return nil
+	}
+	inUse := map[string]int{}
+	for _, pod := range pods.Items {
+		if !pod.Spec.HostNetwork && pod.Spec.NodeName != "" {
+			inUse[pod.Spec.NodeName]++
+		}
+	}
+	var usage []string
+	for _, node := range nodes.Items {
+		_, cidr, err := net.ParseCIDR(node.Spec.PodCIDR)
+		if err != nil {
+			continue
+		}
+		ones, bits := cidr.Mask.Size()
+		if bits-ones > 16 {
+			continue
+		}
+		capacity := 1<<(bits-ones) - 2
+		usage = append(usage, fmt.Sprintf("%s: %d pods in %s (%d addresses, max-pods %s)", node.Name, inUse[node.Name], node.Spec.PodCIDR,
+			capacity, node.Status.Allocatable.Pods().String()))
+	}
+	return usage
+}
+
+// TestPodChurn creates a wave of pause pods spread across the nodes, measures how long they take to be scheduled,
+// get a pod IP and become Ready, then deletes them and immediately creates a second wave so IP release and reuse is
+// exercised. CNI sandbox failures that mean the IP pool is exhausted fail the test.
+func (t *Tester) TestPodChurn(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	count := config.PodChurnCount
+	if count <= 0 {
+		count = defaultPodChurnCount
+	}
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: podChurnSelector})
+	}()
+
+	// Client-side throttling would measure the tool rather than the cluster
+	clientset, err := t.unlimitedClientset()
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create API client: %v", err),
+			Details: details,
+		}
+	}
+	watcher, err := t.clientset.CoreV1().Pods(t.namespace).Watch(ctx, metav1.ListOptions{LabelSelector: podChurnSelector})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to watch test pods: %v", err),
+			Details: details,
+		}
+	}
+	defer watcher.Stop()
+	started := time.Now()
+
+	// Step 1: First wave on an idle namespace
+	first, err := t.runPodChurnWave(ctx, clientset, watcher, "a", count)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+
+	// Step 2: Delete the first wave without a grace period and immediately create the second, so its IPs come from
+	// addresses being released
+	zero := int64(0)
+	clientset.CoreV1().Pods(t.namespace).DeleteCollection(ctx, metav1.DeleteOptions{GracePeriodSeconds: &zero}, metav1.ListOptions{LabelSelector: podChurnSelector})
+	second, err := t.runPodChurnWave(ctx, clientset, watcher, "b", count)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+
+	// Step 3: Latency distribution of each wave
+	metrics := map[string]float64{"pods": float64(count)}
+	details = append(details, fmt.Sprintf("  %-24s %10s %10s %10s %10s", "WAVE / PHASE", "P50", "P90", "P99", "COUNT"))
+	var stuck []string
+	nodes := map[string]int{}
+	ipP90 := map[string]time.Duration{}
+	for _, wave := range []*podChurnWave{first, second} {
+		scheduled, ip, ready := podChurnPercentiles(wave)
+		for _, phase := range []struct {
+			name    string
+			samples []time.Duration
+		}{{"scheduled", scheduled}, {"ip", ip}, {"ready", ready}} {
+			for _, p := range []float64{50, 90, 99} {
+				metrics[fmt.Sprintf("wave_%s.%s_p%.0f_ms", wave.Name, phase.name, p)] = float64(latencyPercentile(phase.samples, p).Milliseconds())
+			}
+			details = append(details, fmt.Sprintf("  %-24s %10v %10v %10v %10d", fmt.Sprintf("%s: created -> %s", wave.Name, phase.name),
+				latencyPercentile(phase.samples, 50).Round(time.Millisecond), latencyPercentile(phase.samples, 90).Round(time.Millisecond),
+				latencyPercentile(phase.samples, 99).Round(time.Millisecond), len(phase.samples)))
+		}
+		ipP90[wave.Name] = latencyPercentile(ip, 90)
+		for name, entry := range wave.Times {
+			if entry.Node != "" {
+				nodes[entry.Node]++
+			}
+			if entry.Ready > 0 {
+				continue
+			}
+			state := "not scheduled"
+			switch {
+			case entry.IP > 0:
+				state = "has IP but not ready"
+			case entry.Scheduled > 0:
+				state = fmt.Sprintf("scheduled on %s without IP", entry.Node)
+			}
+			stuck = append(stuck, fmt.Sprintf("%s (%s)", name, state))
+		}
+	}
+	sort.Strings(stuck)
+	details = append(details, fmt.Sprintf("ℹ️ %d pods per wave spread across %d nodes", count, len(nodes)))
+
+	if ipP90["b"] > podChurnSlowIPP90 || (ipP90["b"] > 2*ipP90["a"] && ipP90["b"]-ipP90["a"] > 2*time.Second) {
+		details = append(details, fmt.Sprintf("⚠️ IP assignment p90 is %v under churn vs %v on the first wave - the CNI slows down while addresses are released",
+			ipP90["b"].Round(time.Millisecond), ipP90["a"].Round(time.Millisecond)))
+	} else if ipP90["a"] > podChurnSlowIPP90 {
+		details = append(details, fmt.Sprintf("⚠️ IP assignment p90 is %v - CNI sandbox setup is slow", ipP90["a"].Round(time.Millisecond)))
+	}
+
+	// Step 4: Sandbox failures that name IP allocation point at an exhausted pool
+	var ipamErrors []string
+	var sandboxErrors int
+	if events, err := t.collectTestEvents(ctx, started, true); err == nil {
+		for _, event := range events {
+			if event.Reason != "FailedCreatePodSandBox" {
+				continue
+			}
+			sandboxErrors++
+			message := strings.ToLower(event.Message)
+			for _, pattern := range ipamExhaustionPatterns {
+				if strings.Contains(message, pattern) {
+					ipamErrors = append(ipamErrors, fmt.Sprintf("%s: %s", event.Object, event.Message))
+					break
+				}
+			}
+		}
+	}
+	metrics["sandbox_failures"] = float64(sandboxErrors)
+
+	if len(ipamErrors) > 0 {
+		for _, problem := range ipamErrors {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		hints := []string{
+			"The CNI could not allocate pod IPs - check the per-node pool sizes below and the CNI IPAM mode",
+			"Cilium: cilium status --verbose shows IPAM allocations; Calico: calicoctl ipam show --show-blocks; AWS VPC CNI: check ENI/IP limits of the instance type",
+			"Addresses held by deleted pods point at slow sandbox teardown - check the CNI agent logs for release errors",
+		}
+		hints = append(hints, t.podCIDRUsage(ctx)...)
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("IPAM exhaustion: %d pod sandboxes failed to get an IP", len(ipamErrors)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         "IPAM Exhaustion",
+				TechnicalError:       ipamErrors[0],
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	if len(stuck) > 0 {
+		for i, pod := range stuck {
+			if i == 10 {
+				details = append(details, fmt.Sprintf("✗ ... and %d more", len(stuck)-10))
+				break
+			}
+			details = append(details, fmt.Sprintf("✗ %s", pod))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d pods not Ready within %v", len(stuck), 2*count, podChurnWaveTimeout),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Pod Startup at Scale",
+				TechnicalError: fmt.Sprintf("%d pods not ready, %d sandbox creation failures", len(stuck), sandboxErrors),
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check the pending pods: kubectl get pods -n %s -l %s -o wide", t.namespace, podChurnSelector),
+					"Scheduled pods without an IP point at the CNI - check FailedCreatePodSandBox events and the CNI agent on those nodes",
+					"Unscheduled pods point at node max-pods or resource limits",
+				},
+			},
+		}
+	}
+
+	_, ip, ready := podChurnPercentiles(second)
+	details = append(details, fmt.Sprintf("✓ All %d pods of both waves Ready", 2*count))
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%d pods per wave got IPs (p90 %v) and became Ready (p90 %v) under churn", count,
+			latencyPercentile(ip, 90).Round(time.Millisecond), latencyPercentile(ready, 90).Round(time.Millisecond)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,247 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+const (
+	// portForwardEchoPort is where the echo pod returns every byte it receives
+	portForwardEchoPort = 8080
+	// portForwardPayloadSize is the amount of data sent through the tunnel and expected back
+	portForwardPayloadSize = 1 << 20
+	// portForwardReadyTimeout bounds the SPDY upgrade and the setup of the local listener
+	portForwardReadyTimeout = 30 * time.Second
+	// portForwardTransferTimeout bounds the echo of the payload
+	portForwardTransferTimeout = 60 * time.Second
+)
+
+// createEchoPod creates a pod that echoes every TCP connection on port back to the sender
+func (t *Tester) createEchoPod(ctx context.Context, name string, port int) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   t.namespace,
+			Labels:      map[string]string{"app": name},
+			Annotations: t.testPodAnnotations(ctx, t.namespace),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:    "netshoot",
+					Image:   t.image(netshootImage),
+					Command: []string{"socat", fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", port), "EXEC:cat"},
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: int32(port),
+						},
+					},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(port)},
+						},
+						PeriodSeconds: 2,
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// startPortForward opens a port-forward to a pod port through the API server, the way kubectl port-forward does, and
+// returns the local port and a function closing the tunnel
+func (t *Tester) startPortForward(ctx context.Context, podName string, port int) (uint16, func(), error) {
+	transport, upgrader, err := spdy.RoundTripperFor(t.config)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create SPDY round tripper: %v", err)
+	}
+	request := t.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(t.namespace).
+		Name(podName).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, request.URL())
+	t.steps.recordCommand(shellJoin("kubectl", "port-forward", "-n", t.namespace, "pod/"+podName, fmt.Sprintf(":%d", port)))
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopChan, readyChan, io.Discard, io.Discard)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create port forwarder: %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- forwarder.ForwardPorts()
+	}()
+	stop := sync.OnceFunc(func() {
+		close(stopChan)
+	})
+
+	select {
+	case <-readyChan:
+	case err := <-errChan:
+		return 0, nil, fmt.Errorf("port-forward failed before it was ready: %v", err)
+	case <-time.After(portForwardReadyTimeout):
+		stop()
+		return 0, nil, fmt.Errorf("port-forward was not ready within %v", portForwardReadyTimeout)
+	case <-ctx.Done():
+		stop()
+		return 0, nil, ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		stop()
+		return 0, nil, fmt.Errorf("port-forward has no local port: %v", err)
+	}
+	return ports[0].Local, stop, nil
+}
+
+// echoThroughPort sends payload to the local port and reads the same number of bytes back
+func echoThroughPort(localPort uint16, payload []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", localPort), 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the local port: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(portForwardTransferTimeout))
+
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(payload)
+		writeErr <- err
+	}()
+
+	received := make([]byte, len(payload))
+	n, err := io.ReadFull(conn, received)
+	if err != nil {
+		return received[:n], fmt.Errorf("received %d of %d bytes: %v", n, len(payload), err)
+	}
+	if err := <-writeErr; err != nil {
+		return received, fmt.Errorf("failed to send payload: %v", err)
+	}
+	return received, nil
+}
+
+// apiServerProxy returns the proxy configured for requests to the API server, if any
+func (t *Tester) apiServerProxy() string {
+	request, err := http.NewRequest(http.MethodGet, t.config.Host, nil)
+	if err != nil {
+		return ""
+	}
+	proxy := http.ProxyFromEnvironment
+	if t.config.Proxy != nil {
+		proxy = t.config.Proxy
+	}
+	if proxyURL, err := proxy(request); err == nil && proxyURL != nil {
+		return (&url.URL{Scheme: proxyURL.Scheme, Host: proxyURL.Host}).String()
+	}
+	return ""
+}
+
+// TestPortForward opens a port-forward to an echo pod through the API server and verifies data sent through the
+// tunnel comes back unchanged, exercising the SPDY upgrade path kubectl port-forward and exec depend on
+func (t *Tester) TestPortForward(ctx context.Context) TestResult {
+	var details []string
+	podName := "port-forward-echo"
+
+	fail := func(stage, message string, hints ...string) TestResult {
+		t.cleanupPod(ctx, podName)
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       message,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 1: Create the echo pod
+	if err := t.createEchoPod(ctx, podName, portForwardEchoPort); err != nil {
+		return fail("Pod Creation", fmt.Sprintf("Failed to create echo pod: %v", err))
+	}
+	if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+		return fail("Pod Readiness", fmt.Sprintf("Echo pod did not become ready: %v", err))
+	}
+	details = append(details, fmt.Sprintf("✓ Echo pod '%s' listening on port %d", podName, portForwardEchoPort))
+	if proxy := t.apiServerProxy(); proxy != "" {
+		details = append(details, fmt.Sprintf("ℹ️ Requests to the API server go through proxy %s", proxy))
+	}
+
+	// Step 2: Upgrade the portforward request to a SPDY stream through the API server and kubelet
+	start := time.Now()
+	localPort, stop, err := t.startPortForward(ctx, podName, portForwardEchoPort)
+	if err != nil {
+		details = append(details, fmt.Sprintf("✗ %v", err))
+		return fail("Port-Forward Upgrade", err.Error(),
+			"The API server answers port-forward and exec with an HTTP Upgrade to SPDY/3.1; every proxy or load balancer in front of it must pass the Upgrade and Connection headers",
+			"Check HTTPS_PROXY/NO_PROXY: corporate proxies often break streaming upgrades, add the API server to NO_PROXY",
+			"A 403 means RBAC does not allow create on pods/portforward",
+			"Reproduce with: kubectl port-forward -v=8 -n "+t.namespace+" pod/"+podName+fmt.Sprintf(" :%d", portForwardEchoPort))
+	}
+	defer stop()
+	established := time.Since(start)
+	details = append(details, fmt.Sprintf("✓ Port-forward established in %s on 127.0.0.1:%d", established.Round(time.Millisecond), localPort))
+
+	// Step 3: Send a random payload through the tunnel and compare the echo
+	payload := make([]byte, portForwardPayloadSize)
+	rand.Read(payload)
+	start = time.Now()
+	received, err := echoThroughPort(localPort, payload)
+	transfer := time.Since(start)
+	if err != nil {
+		details = append(details, fmt.Sprintf("✗ Data transfer failed: %v", err))
+		return fail("Data Transfer", fmt.Sprintf("Port-forward connected but data transfer failed: %v", err),
+			"The tunnel was established, so the upgrade works; a stream reset points at the kubelet or the container runtime streaming server",
+			"Check the kubelet --streaming-connection-idle-timeout and proxies with short idle or request timeouts",
+			fmt.Sprintf("Check the echo pod logs: kubectl logs -n %s %s", t.namespace, podName))
+	}
+	if !bytes.Equal(received, payload) {
+		offset := 0
+		for offset < len(payload) && received[offset] == payload[offset] {
+			offset++
+		}
+		details = append(details, fmt.Sprintf("✗ Echoed data differs from the payload at byte %d", offset))
+		return fail("Data Integrity", fmt.Sprintf("Port-forward returned corrupted data (first difference at byte %d of %d)", offset, len(payload)),
+			"Data is modified in the streaming path; check proxies that rewrite or buffer upgraded connections")
+	}
+	throughput := float64(2*len(payload)) / transfer.Seconds() / (1 << 20)
+	details = append(details, fmt.Sprintf("✓ Echoed %d KiB through the tunnel in %s (%.1f MiB/s both directions), data intact",
+		len(payload)/1024, transfer.Round(time.Millisecond), throughput))
+
+	stop()
+	t.cleanupPod(ctx, podName)
+	details = append(details, "✓ Cleaned up port-forward test resources")
+
+	return TestResult{
+		Success: true,
+		Message: "Port-forward through the API server works - SPDY upgrade and data transfer verified",
+		Details: details,
+		Metrics: map[string]float64{
+			"establish_ms":    float64(established.Milliseconds()),
+			"transfer_ms":     float64(transfer.Milliseconds()),
+			"throughput_mbps": throughput * 8 * (1 << 20) / 1e6,
+		},
+	}
+}
@@ -0,0 +1,313 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// portPolicyPorts are the ports served by the L4 policy web pod
+var portPolicyPorts = []int32{80, 8080}
+
+// portPolicyRule is one port-restricted policy and the reachability expected for each probed port while it is applied
+type portPolicyRule struct {
+	Name       string
+	PolicyFile string
+	Expected   map[int32]bool
+}
+
+// portPolicyOutcome is the observed result for one rule and port
+type portPolicyOutcome struct {
+	Rule     string
+	Port     int32
+	Expected bool
+	Actual   bool
+}
+
+// reachability renders an allowed flag for the per-rule results table
+func reachability(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+// createPolicyWebPod creates a pod serving HTTP on port 80 (nginx) and port 8080 (echo server) for policy tests
+func (t *Tester) createPolicyWebPod(ctx context.Context, name string, labels map[string]string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   t.namespace,
+			Labels:      labels,
+			Annotations: t.testPodAnnotations(ctx, t.namespace),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "nginx",
+					Image: t.image(nginxImage),
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: 80,
+						},
+					},
+				},
+				{
+					Name:  "echo",
+					Image: t.image(upgradeEchoImage),
+					Env: []corev1.EnvVar{
+						{Name: "PORT", Value: "8080"},
+					},
+					Ports: []corev1.ContainerPort{
+						{
+							ContainerPort: 8080,
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create web pod %s: %v", name, err)
+	}
+	return nil
+}
+
+// createPolicyClientPod creates a labelled netshoot pod in namespace, optionally pinned to a node, used as a traffic source in policy tests
+func (t *Tester) createPolicyClientPod(ctx context.Context, namespace, name, nodeName string, labels map[string]string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: t.testPodAnnotations(ctx, namespace),
+		},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create client pod %s in namespace %s: %v", name, namespace, err)
+	}
+	return nil
+}
+
+// probeTCPPort reports whether an HTTP request from the client pod to ip:port gets any response
+func (t *Tester) probeTCPPort(ctx context.Context, clientPodName, ip string, port int32) (bool, CommandOutput) {
+	return t.probeTCPPortFromNamespace(ctx, t.namespace, clientPodName, ip, port)
+}
+
+// probeTCPPortFromNamespace is probeTCPPort for a client pod in another namespace
+func (t *Tester) probeTCPPortFromNamespace(ctx context.Context, namespace, clientPodName, ip string, port int32) (bool, CommandOutput) {
+	output, err := t.execInPodWithOutput(ctx, namespace, clientPodName, "netshoot",
+		[]string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", "3",
+			fmt.Sprintf("http://%s:%d/", httpTargetForIP(ip), port)},
+		fmt.Sprintf("HTTP probe to port %d", port))
+
+	code := strings.TrimSpace(output.Stdout)
+	return err == nil && code != "" && code != "000", output
+}
+
+// testPortPolicies applies each port-restricted policy in turn and verifies every port against the rule's expectation
+func (t *Tester) testPortPolicies(ctx context.Context, direction string, rules []portPolicyRule) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	webPodName := fmt.Sprintf("web-l4-%s-test", direction)
+	clientPodName := fmt.Sprintf("client-l4-%s-test", direction)
+
+	cleanup := func() {
+		t.cleanupPods(ctx, webPodName, clientPodName)
+	}
+
+	// Step 1: Create the web and client pods
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{"run": "l4-web"}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if err := t.createPolicyClientPod(ctx, t.namespace, clientPodName, "", map[string]string{"run": "l4-client"}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	for _, podName := range []string{webPodName, clientPodName} {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	webIP := webPod.Status.PodIP
+	details = append(details, fmt.Sprintf("✓ Web pod '%s' (run=l4-web) ready at %s serving ports 80 and 8080", webPodName, webIP))
+	details = append(details, fmt.Sprintf("✓ Client pod '%s' (run=l4-client) ready", clientPodName))
+
+	// Step 2: Baseline - every port must be reachable before any policy is applied
+	for _, port := range portPolicyPorts {
+		allowed, output := t.probeTCPPort(ctx, clientPodName, webIP, port)
+		commandOutputs = append(commandOutputs, output)
+		if !allowed {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Baseline failed - port %d is unreachable before any policy is applied", port),
+				Details: append(details, fmt.Sprintf("✗ Baseline port %d unreachable", port)),
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:   "Baseline Connectivity",
+					TechnicalError: strings.TrimSpace(output.Stderr),
+					CommandOutputs: commandOutputs,
+					TroubleshootingHints: []string{
+						"Check for leftover policies selecting the test pods: kubectl get ciliumclusterwidenetworkpolicies",
+						fmt.Sprintf("Check both containers in the web pod are running: kubectl get pod %s -n %s", webPodName, t.namespace),
+					},
+				},
+			}
+		}
+		details = append(details, fmt.Sprintf("✓ Baseline: port %d reachable", port))
+	}
+
+	// Step 3: Apply each rule in isolation and probe every port
+	var outcomes []portPolicyOutcome
+	for _, rule := range rules {
+		details = append(details, fmt.Sprintf("ℹ️ Applying policy from: %s", rule.PolicyFile))
+		appliedPolicyName, err := t.applyNetworkPolicy(ctx, rule.PolicyFile)
+		if err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to apply network policy %s: %v", rule.Name, err),
+				Details: details,
+			}
+		}
+
+		// Wait for policy to take effect
+		sleepContext(ctx, 5*time.Second)
+
+		for _, port := range portPolicyPorts {
+			allowed, output := t.probeTCPPort(ctx, clientPodName, webIP, port)
+			commandOutputs = append(commandOutputs, output)
+			outcomes = append(outcomes, portPolicyOutcome{
+				Rule:     rule.Name,
+				Port:     port,
+				Expected: rule.Expected[port],
+				Actual:   allowed,
+			})
+		}
+
+		if err := t.deleteNetworkPolicy(ctx, appliedPolicyName); err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+		}
+		// Let the policy removal propagate before the next rule
+		sleepContext(ctx, 2*time.Second)
+	}
+
+	cleanup()
+	details = append(details, "✓ Network policies and test pods cleaned up")
+
+	// Step 4: Per-rule results
+	details = append(details, fmt.Sprintf("ℹ️ %-28s %-6s %-9s %-9s %s", "RULE", "PORT", "EXPECTED", "ACTUAL", "RESULT"))
+	var mismatches []string
+	for _, outcome := range outcomes {
+		result := "✓"
+		if outcome.Expected != outcome.Actual {
+			result = "✗"
+			mismatches = append(mismatches, fmt.Sprintf("%s port %d expected %s but was %s",
+				outcome.Rule, outcome.Port, reachability(outcome.Expected), reachability(outcome.Actual)))
+		}
+		details = append(details, fmt.Sprintf("ℹ️ %-28s %-6d %-9s %-9s %s",
+			outcome.Rule, outcome.Port, reachability(outcome.Expected), reachability(outcome.Actual), result))
+	}
+
+	if len(mismatches) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d %s port checks did not match the policy", len(mismatches), len(outcomes), direction),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Port Policy Verification",
+				TechnicalError: strings.Join(mismatches, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check policy enforcement mode: kubectl get configmap -n kube-system cilium-config -o jsonpath='{.data.enable-policy}'",
+					"An allowed port reported as denied usually means the policy is not yet realized; check cilium endpoint list",
+					"A denied port reported as allowed means the rule did not select the pod; verify the run= labels",
+					"Watch drops while probing: kubectl -n kube-system exec ds/cilium -- cilium monitor --type drop",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d %s port checks matched the applied policies", len(outcomes), direction),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
+
+// TestL4IngressPortPolicies verifies port-restricted ingress allow and deny rules
+func (t *Tester) TestL4IngressPortPolicies(ctx context.Context) TestResult {
+	return t.testPortPolicies(ctx, "ingress", []portPolicyRule{
+		{
+			Name:       "l4-ingress-allow-port-80",
+			PolicyFile: "cilium-policies/8-l4-policies/basic-port-policies/ingress-allow-port-80-policy.yaml",
+			Expected:   map[int32]bool{80: true, 8080: false},
+		},
+		{
+			Name:       "l4-ingress-deny-port-8080",
+			PolicyFile: "cilium-policies/8-l4-policies/basic-port-policies/ingress-deny-port-8080-policy.yaml",
+			Expected:   map[int32]bool{80: true, 8080: false},
+		},
+	})
+}
+
+// TestL4EgressPortPolicies verifies port-restricted egress allow and deny rules
+func (t *Tester) TestL4EgressPortPolicies(ctx context.Context) TestResult {
+	return t.testPortPolicies(ctx, "egress", []portPolicyRule{
+		{
+			Name:       "l4-egress-allow-port-80",
+			PolicyFile: "cilium-policies/8-l4-policies/basic-port-policies/egress-allow-port-80-policy.yaml",
+			Expected:   map[int32]bool{80: true, 8080: false},
+		},
+		{
+			Name:       "l4-egress-deny-port-8080",
+			PolicyFile: "cilium-policies/8-l4-policies/basic-port-policies/egress-deny-port-8080-policy.yaml",
+			Expected:   map[int32]bool{80: true, 8080: false},
+		},
+	})
+}
@@ -0,0 +1,447 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Permission is one verb on one resource that a test needs, in a namespace or cluster-wide ("" namespace)
+type Permission struct {
+	Verb        string   `json:"verb"`
+	Group       string   `json:"group,omitempty"`
+	Resource    string   `json:"resource"`
+	Subresource string   `json:"subresource,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Tests       []string `json:"tests,omitempty"` // tests that need the permission, empty for every run
+}
+
+// String renders the permission the way kubectl auth can-i takes it
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource += "." + p.Group
+	}
+	if p.Subresource != "" {
+		resource += "/" + p.Subresource
+	}
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s (cluster-wide)", p.Verb, resource)
+	}
+	return fmt.Sprintf("%s %s -n %s", p.Verb, resource, p.Namespace)
+}
+
+// permissionScope says where a rule applies: the test namespace, kube-system or cluster-wide
+type permissionScope int
+
+const (
+	scopeTest permissionScope = iota
+	scopeKubeSystem
+	scopeCluster
+)
+
+// permissionRule groups the verbs needed on one resource
+type permissionRule struct {
+	verbs    []string
+	group    string
+	resource string
+	scope    permissionScope
+}
+
+// basePermissions are needed by every run: the test namespace, test pods, node discovery and dataplane detection
+var basePermissions = []permissionRule{
+	{[]string{"get", "create", "delete"}, "", "namespaces", scopeCluster},
+	{[]string{"get", "list", "create", "delete"}, "", "pods", scopeTest},
+	{[]string{"create"}, "", "pods/exec", scopeTest},
+	{[]string{"list"}, "", "events", scopeTest},
+	{[]string{"list"}, "", "nodes", scopeCluster},
+	{[]string{"get"}, "", "configmaps", scopeKubeSystem},
+	{[]string{"get"}, "apps", "daemonsets", scopeKubeSystem},
+	{[]string{"list"}, "", "pods", scopeKubeSystem},
+}
+
+// Shared rule sets of tests that deploy the same kind of resources
+var (
+	serviceBackendPermissions = []permissionRule{
+		{[]string{"get", "create", "delete"}, "apps", "deployments", scopeTest},
+		{[]string{"get", "create", "delete"}, "", "services", scopeTest},
+		{[]string{"get"}, "", "pods/log", scopeKubeSystem},
+	}
+	tlsBackendPermissions = []permissionRule{
+		{[]string{"get", "create", "delete"}, "apps", "deployments", scopeTest},
+		{[]string{"get", "create", "delete"}, "", "services", scopeTest},
+		{[]string{"get", "create", "delete"}, "", "secrets", scopeTest},
+		{[]string{"create", "delete"}, "", "configmaps", scopeTest},
+	}
+	ciliumAgentPermissions = []permissionRule{
+		{[]string{"create"}, "", "pods/exec", scopeKubeSystem},
+	}
+	ciliumPolicyPermissions = []permissionRule{
+		{[]string{"get", "create", "patch", "delete"}, "cilium.io", "ciliumnetworkpolicies", scopeTest},
+		{[]string{"get", "list", "create", "patch", "delete"}, "cilium.io", "ciliumclusterwidenetworkpolicies", scopeCluster},
+		{[]string{"update"}, "", "namespaces", scopeCluster},
+	}
+	storagePermissions = []permissionRule{
+		{[]string{"get", "create", "delete"}, "", "persistentvolumeclaims", scopeTest},
+		{[]string{"get", "list"}, "storage.k8s.io", "storageclasses", scopeCluster},
+		{[]string{"list"}, "storage.k8s.io", "volumeattachments", scopeCluster},
+	}
+)
+
+// testPermissions lists what each test needs beyond basePermissions
+var testPermissions = map[string][]permissionRule{
+	"service-to-pod": serviceBackendPermissions,
+	"cross-node":     serviceBackendPermissions,
+	"dns":            serviceBackendPermissions,
+	"nodeport":       serviceBackendPermissions,
+	"nodeport-range": {
+		{[]string{"list"}, "", "services", scopeCluster},
+	},
+	"loadbalancer": append([]permissionRule{
+		{[]string{"list"}, "", "pods", scopeCluster},
+	}, serviceBackendPermissions...),
+	"external-dns": append([]permissionRule{
+		{[]string{"list"}, "apps", "deployments", scopeCluster},
+		{[]string{"update"}, "", "services", scopeTest},
+	}, serviceBackendPermissions...),
+	"ip-family": {
+		{[]string{"get", "create", "delete"}, "", "services", scopeTest},
+	},
+	"snat": {
+		{[]string{"get"}, "", "nodes", scopeCluster},
+		{[]string{"get"}, "", "configmaps", scopeKubeSystem},
+		{[]string{"get"}, "apps", "daemonsets", scopeCluster},
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+	},
+	"ipam-sanity": {
+		{[]string{"list"}, "", "pods", scopeCluster},
+		{[]string{"list"}, "", "services", scopeCluster},
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+		{[]string{"list"}, "cilium.io", "ciliumnodes", scopeCluster},
+	},
+	"ip-exhaustion": {
+		{[]string{"list"}, "", "pods", scopeCluster},
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+		{[]string{"list"}, "cilium.io", "ciliumnodes", scopeCluster},
+	},
+	"overlay-health": {
+		{[]string{"get"}, "", "configmaps", scopeCluster},
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+	},
+	"native-routes": {
+		{[]string{"list"}, "cilium.io", "ciliumnodes", scopeCluster},
+	},
+	"asymmetric-routing": {
+		{[]string{"get"}, "", "nodes", scopeCluster},
+	},
+	"windows-connectivity": {
+		{[]string{"get", "create", "delete"}, "", "services", scopeTest},
+	},
+	"dns-nodes": {
+		{[]string{"list"}, "", "services", scopeKubeSystem},
+		{[]string{"list"}, "discovery.k8s.io", "endpointslices", scopeKubeSystem},
+		{[]string{"list"}, "apps", "daemonsets", scopeKubeSystem},
+	},
+	"cilium-lb-ipam": append([]permissionRule{
+		{[]string{"list", "create", "delete"}, "cilium.io", "ciliumloadbalancerippools", scopeCluster},
+	}, serviceBackendPermissions...),
+	"cilium-kpr":    append(append([]permissionRule{}, ciliumAgentPermissions...), serviceBackendPermissions...),
+	"cilium-health": ciliumAgentPermissions,
+	"cilium-identity": append([]permissionRule{
+		{[]string{"list"}, "cilium.io", "ciliumendpoints", scopeCluster},
+		{[]string{"get", "list"}, "cilium.io", "ciliumidentities", scopeCluster},
+	}, ciliumAgentPermissions...),
+	"cilium-bpf-maps": ciliumAgentPermissions,
+	"cilium-bgp": append([]permissionRule{
+		{[]string{"list"}, "cilium.io", "ciliumbgppeeringpolicies", scopeCluster},
+		{[]string{"list"}, "", "services", scopeCluster},
+	}, ciliumAgentPermissions...),
+	"calico-health": {
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+		{[]string{"list"}, "crd.projectcalico.org", "bgppeers", scopeCluster},
+		{[]string{"get"}, "", "pods/log", scopeKubeSystem},
+	},
+	"tls":             tlsBackendPermissions,
+	"grpc":            serviceBackendPermissions,
+	"websocket-http2": tlsBackendPermissions,
+	"idle-timeout": {
+		{[]string{"create", "delete"}, "", "services", scopeTest},
+	},
+	"accepting-all-pods":     ciliumPolicyPermissions,
+	"rejecting-all-pods":     ciliumPolicyPermissions,
+	"l4-ingress-ports":       ciliumPolicyPermissions,
+	"l4-egress-ports":        ciliumPolicyPermissions,
+	"default-deny-allowlist": ciliumPolicyPermissions,
+	"namespace-isolation":    ciliumPolicyPermissions,
+	"egress-dns-allow":       ciliumPolicyPermissions,
+	"policy-propagation":     ciliumPolicyPermissions,
+	"firewall-ports": {
+		{[]string{"get"}, "", "configmaps", scopeCluster},
+		{[]string{"list"}, "", "pods", scopeKubeSystem},
+		{[]string{"list"}, "crd.projectcalico.org", "ippools", scopeCluster},
+	},
+	"host-firewall": append([]permissionRule{
+		{[]string{"get", "patch"}, "", "nodes", scopeCluster},
+	}, ciliumPolicyPermissions...),
+	"netpol-ingress": {
+		{[]string{"create", "delete"}, "networking.k8s.io", "networkpolicies", scopeTest},
+	},
+	"netpol-egress": {
+		{[]string{"create", "delete"}, "networking.k8s.io", "networkpolicies", scopeTest},
+	},
+	"mesh-mtls": {
+		{[]string{"list"}, "apps", "deployments", scopeCluster},
+		{[]string{"get", "create"}, "", "pods", scopeCluster},
+		{[]string{"create"}, "", "pods/exec", scopeCluster},
+		{[]string{"create"}, "", "services", scopeCluster},
+		{[]string{"create"}, "security.istio.io", "peerauthentications", scopeCluster},
+	},
+	"weighted-routing": {
+		{[]string{"list"}, "gateway.networking.k8s.io", "gateways", scopeCluster},
+		{[]string{"list"}, "apps", "deployments", scopeCluster},
+		{[]string{"get", "create", "delete"}, "", "pods", scopeCluster},
+		{[]string{"create"}, "", "pods/exec", scopeCluster},
+		{[]string{"create", "delete"}, "", "services", scopeCluster},
+		{[]string{"create", "delete"}, "gateway.networking.k8s.io", "httproutes", scopeCluster},
+	},
+	"mesh-authz": {
+		{[]string{"list"}, "apps", "deployments", scopeCluster},
+		{[]string{"get", "create"}, "", "pods", scopeCluster},
+		{[]string{"create"}, "", "pods/exec", scopeCluster},
+		{[]string{"create"}, "", "services", scopeCluster},
+		{[]string{"create"}, "", "serviceaccounts", scopeCluster},
+		{[]string{"get", "create", "patch"}, "security.istio.io", "authorizationpolicies", scopeCluster},
+		{[]string{"get", "create", "patch"}, "policy.linkerd.io", "servers", scopeCluster},
+		{[]string{"get", "create", "patch"}, "policy.linkerd.io", "httproutes", scopeCluster},
+		{[]string{"get", "create", "patch"}, "policy.linkerd.io", "authorizationpolicies", scopeCluster},
+		{[]string{"get", "create", "patch"}, "policy.linkerd.io", "meshtlsauthentications", scopeCluster},
+	},
+	"pvc-access": storagePermissions,
+	"pvc-rwx":    storagePermissions,
+	"pvc-expand": append([]permissionRule{
+		{[]string{"patch"}, "", "persistentvolumeclaims", scopeTest},
+		{[]string{"get"}, "", "persistentvolumes", scopeCluster},
+	}, storagePermissions...),
+	"csi-health": {
+		{[]string{"list"}, "storage.k8s.io", "csidrivers", scopeCluster},
+		{[]string{"list"}, "storage.k8s.io", "csinodes", scopeCluster},
+		{[]string{"list"}, "storage.k8s.io", "volumeattachments", scopeCluster},
+		{[]string{"list"}, "", "pods", scopeCluster},
+	},
+	"apiserver-latency": {
+		{[]string{"get", "list", "watch", "create", "delete"}, "", "configmaps", scopeTest},
+		{[]string{"create", "delete"}, "rbac.authorization.k8s.io", "roles", scopeTest},
+		{[]string{"create", "delete"}, "rbac.authorization.k8s.io", "rolebindings", scopeTest},
+	},
+	"admission-webhooks": {
+		{[]string{"list"}, "admissionregistration.k8s.io", "validatingwebhookconfigurations", scopeCluster},
+		{[]string{"list"}, "admissionregistration.k8s.io", "mutatingwebhookconfigurations", scopeCluster},
+		{[]string{"get"}, "", "services", scopeCluster},
+		{[]string{"list"}, "discovery.k8s.io", "endpointslices", scopeCluster},
+		{[]string{"list"}, "", "events", scopeCluster},
+	},
+	"control-plane-health": {
+		{[]string{"get"}, "coordination.k8s.io", "leases", scopeKubeSystem},
+	},
+	"scheduling-latency": {
+		{[]string{"watch", "deletecollection"}, "", "pods", scopeTest},
+	},
+	"serviceaccount-token": {
+		{[]string{"create", "delete"}, "", "serviceaccounts", scopeTest},
+		{[]string{"create"}, "", "serviceaccounts/token", scopeTest},
+		{[]string{"create"}, "authentication.k8s.io", "tokenreviews", scopeCluster},
+	},
+	"cert-expiry": {
+		{[]string{"list"}, "cert-manager.io", "certificates", scopeTest},
+	},
+	"port-forward": {
+		{[]string{"create"}, "", "pods/portforward", scopeTest},
+	},
+	"apiserver-proxy": append([]permissionRule{
+		{[]string{"get"}, "", "services/proxy", scopeTest},
+		{[]string{"get"}, "", "pods/proxy", scopeTest},
+		{[]string{"list"}, "", "pods", scopeCluster},
+	}, serviceBackendPermissions...),
+	"kubelet-ports": {
+		{[]string{"get"}, "", "nodes/proxy", scopeCluster},
+	},
+	"deployment-rollout": append([]permissionRule{
+		{[]string{"patch", "update"}, "apps", "deployments", scopeTest},
+		{[]string{"list"}, "apps", "replicasets", scopeTest},
+	}, serviceBackendPermissions...),
+	"hpa-scaling": append([]permissionRule{
+		{[]string{"get", "create", "delete"}, "autoscaling", "horizontalpodautoscalers", scopeTest},
+	}, serviceBackendPermissions...),
+	"pod-churn": {
+		{[]string{"watch", "deletecollection"}, "", "pods", scopeTest},
+		{[]string{"list"}, "", "pods", scopeCluster},
+	},
+	"fault-loss": serviceBackendPermissions,
+	"node-isolation": append([]permissionRule{
+		{[]string{"get", "patch"}, "", "nodes", scopeCluster},
+		{[]string{"list"}, "discovery.k8s.io", "endpointslices", scopeTest},
+	}, serviceBackendPermissions...),
+	"dns-failure": {
+		{[]string{"create", "delete"}, "networking.k8s.io", "networkpolicies", scopeTest},
+		{[]string{"list"}, "apps", "deployments", scopeKubeSystem},
+		{[]string{"get", "update"}, "apps", "deployments/scale", scopeKubeSystem},
+		{[]string{"list"}, "apps", "daemonsets", scopeKubeSystem},
+		{[]string{"list"}, "discovery.k8s.io", "endpointslices", scopeKubeSystem},
+	},
+	"cni-restart": {
+		{[]string{"get"}, "apps", "daemonsets", scopeCluster},
+		{[]string{"list", "delete"}, "", "pods", scopeCluster},
+	},
+	"namespace-churn": {
+		{[]string{"list"}, "", "namespaces", scopeCluster},
+		{[]string{"get", "create"}, "", "pods", scopeCluster},
+		{[]string{"create"}, "", "services", scopeCluster},
+		{[]string{"create"}, "", "configmaps", scopeCluster},
+	},
+	"readiness-shift": append([]permissionRule{
+		{[]string{"list"}, "discovery.k8s.io", "endpointslices", scopeTest},
+	}, serviceBackendPermissions...),
+}
+
+// RequiredPermissions expands the permission rules of the selected tests for the test namespace, merging
+// duplicates and recording which tests need each permission
+func RequiredPermissions(tests []string, namespace string) []Permission {
+	merged := map[string]*Permission{}
+	base := map[string]bool{}
+	var order []string
+	add := func(rule permissionRule, test string) {
+		resource, subresource, _ := strings.Cut(rule.resource, "/")
+		ruleNamespace := ""
+		switch rule.scope {
+		case scopeTest:
+			ruleNamespace = namespace
+		case scopeKubeSystem:
+			ruleNamespace = "kube-system"
+		}
+		for _, verb := range rule.verbs {
+			permission := Permission{Verb: verb, Group: rule.group, Resource: resource, Subresource: subresource, Namespace: ruleNamespace}
+			key := permission.String()
+			existing, ok := merged[key]
+			if !ok {
+				existing = &permission
+				merged[key] = existing
+				order = append(order, key)
+			}
+			if test == "" {
+				base[key] = true
+			} else if !containsString(existing.Tests, test) {
+				existing.Tests = append(existing.Tests, test)
+			}
+		}
+	}
+	for _, rule := range basePermissions {
+		add(rule, "")
+	}
+	for _, test := range tests {
+		for _, rule := range testPermissions[test] {
+			add(rule, test)
+		}
+	}
+
+	permissions := make([]Permission, 0, len(order))
+	for _, key := range order {
+		permission := *merged[key]
+		// A permission every run needs is not attributed to individual tests
+		if base[key] {
+			permission.Tests = nil
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions
+}
+
+// CheckPermissions evaluates each permission with a SelfSubjectAccessReview and returns those that are denied
+func (t *Tester) CheckPermissions(ctx context.Context, permissions []Permission) ([]Permission, error) {
+	// Dozens of reviews would take seconds under the default client-side rate limit
+	clientset, err := t.unlimitedClientset()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %v", err)
+	}
+	var missing []Permission
+	for _, permission := range permissions {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   permission.Namespace,
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+				},
+			},
+		}
+		result, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("SelfSubjectAccessReview for %s failed: %v", permission, err)
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, permission)
+		}
+	}
+	return missing, nil
+}
+
+// PermissionsRoleYAML renders Roles (per namespace) and a ClusterRole granting the given permissions, to be bound to
+// the user running the diagnostics
+func PermissionsRoleYAML(permissions []Permission) string {
+	type ruleKey struct{ namespace, group, resource string }
+	verbs := map[ruleKey][]string{}
+	var keys []ruleKey
+	for _, permission := range permissions {
+		resource := permission.Resource
+		if permission.Subresource != "" {
+			resource += "/" + permission.Subresource
+		}
+		key := ruleKey{permission.Namespace, permission.Group, resource}
+		if _, ok := verbs[key]; !ok {
+			keys = append(keys, key)
+		}
+		if !containsString(verbs[key], permission.Verb) {
+			verbs[key] = append(verbs[key], permission.Verb)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].resource < keys[j].resource
+	})
+
+	var documents []string
+	var current strings.Builder
+	namespace := "\x00"
+	for _, key := range keys {
+		if key.namespace != namespace {
+			if current.Len() > 0 {
+				documents = append(documents, current.String())
+				current.Reset()
+			}
+			namespace = key.namespace
+			current.WriteString("apiVersion: rbac.authorization.k8s.io/v1\n")
+			if namespace == "" {
+				current.WriteString("kind: ClusterRole\nmetadata:\n  name: k8s-diagnostic\n")
+			} else {
+				current.WriteString(fmt.Sprintf("kind: Role\nmetadata:\n  name: k8s-diagnostic\n  namespace: %s\n", namespace))
+			}
+			current.WriteString("rules:\n")
+		}
+		current.WriteString(fmt.Sprintf("- apiGroups: [\"%s\"]\n  resources: [\"%s\"]\n  verbs: [\"%s\"]\n",
+			key.group, key.resource, strings.Join(verbs[key], "\", \"")))
+	}
+	if current.Len() > 0 {
+		documents = append(documents, current.String())
+	}
+	return strings.Join(documents, "---\n")
+}
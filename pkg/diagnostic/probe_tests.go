@@ -0,0 +1,316 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// defaultProbeImage runs probe commands that do not name an image
+	defaultProbeImage = netshootImage
+	// defaultProbeTimeout bounds scheduling, image pull and the command of a probe test
+	defaultProbeTimeout = 60 * time.Second
+)
+
+// probeTestFile is the YAML layout of a --probe-file; a file may hold several documents
+type probeTestFile struct {
+	Tests []probeTestSpec `json:"tests"`
+}
+
+// probeTestSpec declares a test that runs one command in a pod and checks its exit code and output
+type probeTestSpec struct {
+	Name          string              `json:"name"`
+	Description   string              `json:"description"`
+	Image         string              `json:"image"`
+	Command       []string            `json:"command"`
+	Expect        probeExpectation    `json:"expect"`
+	Placement     probePlacement      `json:"placement"`
+	TargetService *probeTargetService `json:"targetService"`
+	Timeout       string              `json:"timeout"` // Go duration, default 60s
+}
+
+// probeExpectation is what a passing probe command does
+type probeExpectation struct {
+	ExitCode *int   `json:"exitCode"` // default 0
+	Output   string `json:"output"`   // regular expression matched against the command output
+}
+
+// probePlacement selects where the probe pod runs
+type probePlacement struct {
+	Node         string            `json:"node"`
+	NodeSelector map[string]string `json:"nodeSelector"`
+	HostNetwork  bool              `json:"hostNetwork"`
+}
+
+// probeTargetService is a Service the probe command reaches through TARGET_HOST, TARGET_IP and TARGET_PORT
+type probeTargetService struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"` // default: the test namespace
+	Port      int32  `json:"port"`      // default: the Service's first port
+}
+
+// probeTest is a declarative probe test registered as a Test
+type probeTest struct {
+	spec     probeTestSpec
+	exitCode int
+	output   *regexp.Regexp
+	timeout  time.Duration
+}
+
+func (p *probeTest) Name() string     { return p.spec.Name }
+func (p *probeTest) Describe() string { return p.spec.Description }
+
+// Timeout extends the run budget by the probe's wait for its pod and command
+func (p *probeTest) Timeout() time.Duration { return p.timeout }
+
+// LoadProbeTests reads probe test definitions from a YAML file, validates them and registers each as a test
+func LoadProbeTests(path string) ([]Test, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open probe file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var specs []probeTestSpec
+	decoder := yaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		var document probeTestFile
+		if err := decoder.Decode(&document); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse probe file %s: %v", path, err)
+		}
+		specs = append(specs, document.Tests...)
+	}
+
+	var tests []Test
+	for _, spec := range specs {
+		test, err := newProbeTest(spec)
+		if err != nil {
+			return tests, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := RegisterTest(test); err != nil {
+			return tests, fmt.Errorf("%s: %v", path, err)
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
+
+// newProbeTest validates a probe test definition and fills in its defaults
+func newProbeTest(spec probeTestSpec) (*probeTest, error) {
+	if errs := validation.IsDNS1123Label(spec.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("probe test name %q is not a valid name: %s", spec.Name, strings.Join(errs, "; "))
+	}
+	if len(spec.Command) == 0 {
+		return nil, fmt.Errorf("probe test %s has no command", spec.Name)
+	}
+	if spec.TargetService != nil && spec.TargetService.Name == "" {
+		return nil, fmt.Errorf("probe test %s has a targetService without a name", spec.Name)
+	}
+	if spec.Image == "" {
+		spec.Image = defaultProbeImage
+	}
+	test := &probeTest{spec: spec, timeout: defaultProbeTimeout}
+	if spec.Expect.ExitCode != nil {
+		test.exitCode = *spec.Expect.ExitCode
+	}
+	if spec.Expect.Output != "" {
+		pattern, err := regexp.Compile(spec.Expect.Output)
+		if err != nil {
+			return nil, fmt.Errorf("probe test %s: invalid output pattern: %v", spec.Name, err)
+		}
+		test.output = pattern
+	}
+	if spec.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("probe test %s: invalid timeout %q", spec.Name, spec.Timeout)
+		}
+		test.timeout = timeout
+	}
+	return test, nil
+}
+
+// Run starts a pod that runs the probe command to completion, then checks the exit code and the output
+func (p *probeTest) Run(ctx context.Context, t *Tester, config TestConfig) TestResult {
+	var details []string
+	// Each run gets its own pod name, since the pod of an earlier run in a kept namespace may still be terminating
+	generateName := fmt.Sprintf("probe-%s", p.spec.Name)
+	if len(generateName) > 57 {
+		generateName = strings.TrimRight(generateName[:57], "-.")
+	}
+	generateName += "-"
+
+	// Step 1: Target Service, handed to the command as environment variables
+	var env []corev1.EnvVar
+	if target := p.spec.TargetService; target != nil {
+		namespace := target.Namespace
+		if namespace == "" {
+			namespace = t.namespace
+		}
+		service, err := t.clientset.CoreV1().Services(namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Target service %s/%s not found: %v", namespace, target.Name, err),
+				Details: details,
+			}
+		}
+		port := target.Port
+		if port == 0 && len(service.Spec.Ports) > 0 {
+			port = service.Spec.Ports[0].Port
+		}
+		env = []corev1.EnvVar{
+			{Name: "TARGET_HOST", Value: fmt.Sprintf("%s.%s.svc", service.Name, namespace)},
+			{Name: "TARGET_IP", Value: service.Spec.ClusterIP},
+			{Name: "TARGET_PORT", Value: fmt.Sprint(port)},
+		}
+		details = append(details, fmt.Sprintf("✓ Target service %s/%s: %s port %d", namespace, service.Name, service.Spec.ClusterIP, port))
+	}
+
+	// Step 2: A pod that runs the command once
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: generateName,
+			Namespace:    t.namespace,
+			Labels: map[string]string{
+				"app": "probe-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:     p.spec.Placement.Node,
+			NodeSelector: p.spec.Placement.NodeSelector,
+			HostNetwork:  p.spec.Placement.HostNetwork,
+			Containers: []corev1.Container{
+				{
+					Name:    "probe",
+					Image:   t.image(p.spec.Image),
+					Command: p.spec.Command,
+					Env:     env,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	created, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create probe pod: %v", err),
+			Details: details,
+		}
+	}
+	podName := created.Name
+	defer t.cleanupPod(ctx, podName)
+	details = append(details, fmt.Sprintf("✓ Created probe pod '%s' (%s)", podName, p.spec.Image))
+
+	// Step 3: Wait for the command to finish
+	var terminated *corev1.ContainerStateTerminated
+	var node, reason string
+	deadline := time.Now().Add(p.timeout)
+	for terminated == nil && time.Now().Before(deadline) && ctx.Err() == nil {
+		current, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err == nil {
+			node = current.Spec.NodeName
+			reason = getPodFailureReason(current)
+			if reason == "Unknown failure" {
+				reason = fmt.Sprintf("pod still %s", current.Status.Phase)
+			}
+			for _, status := range current.Status.ContainerStatuses {
+				if status.Name == "probe" && status.State.Terminated != nil {
+					terminated = status.State.Terminated
+				}
+			}
+		}
+		if terminated == nil {
+			sleepContext(ctx, time.Second)
+		}
+	}
+	if terminated == nil {
+		details = append(details, fmt.Sprintf("✗ Probe command did not finish within %v: %s", p.timeout, reason))
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Probe %s did not finish within %v", p.spec.Name, p.timeout),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Probe Pod",
+				TechnicalError: reason,
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check the probe pod events: kubectl describe pod %s -n %s", podName, t.namespace),
+					"Image pull errors need a reachable registry or an imagePullSecret; a long-running command needs a larger timeout",
+				},
+			},
+		}
+	}
+
+	// Step 4: Compare the exit code and the output with the expectation
+	logs, logErr := t.clientset.CoreV1().Pods(t.namespace).GetLogs(podName, &corev1.PodLogOptions{Container: "probe"}).DoRaw(ctx)
+	output := CommandOutput{
+		Command:     strings.Join(p.spec.Command, " "),
+		ExitCode:    int(terminated.ExitCode),
+		Stdout:      string(logs),
+		Duration:    terminated.FinishedAt.Sub(terminated.StartedAt.Time).String(),
+		Description: fmt.Sprintf("Probe %s on %s", p.spec.Name, valueOrUnknown(node)),
+	}
+	if logErr != nil {
+		output.Stderr = fmt.Sprintf("logs unavailable: %v", logErr)
+	}
+	metrics := map[string]float64{
+		"exit_code":   float64(terminated.ExitCode),
+		"duration_ms": float64(terminated.FinishedAt.Sub(terminated.StartedAt.Time).Milliseconds()),
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Command ran on %s and exited with %d", valueOrUnknown(node), terminated.ExitCode))
+
+	var problems []string
+	if int(terminated.ExitCode) != p.exitCode {
+		problems = append(problems, fmt.Sprintf("exit code %d, expected %d", terminated.ExitCode, p.exitCode))
+	} else {
+		details = append(details, fmt.Sprintf("✓ Exit code %d as expected", p.exitCode))
+	}
+	if p.output != nil {
+		if p.output.Match(logs) {
+			details = append(details, fmt.Sprintf("✓ Output matches /%s/", p.output))
+		} else {
+			problems = append(problems, fmt.Sprintf("output does not match /%s/", p.output))
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			details = append(details, fmt.Sprintf("✗ %s", problem))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Probe %s failed: %s", p.spec.Name, strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Probe Command",
+				TechnicalError: strings.Join(problems, "; "),
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					"The command output is attached - compare it with the expected pattern",
+					fmt.Sprintf("Rerun the command by hand: kubectl run -n %s probe-debug --rm -it --image=%s -- %s", t.namespace, p.spec.Image, strings.Join(p.spec.Command, " ")),
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Probe %s passed", p.spec.Name),
+		Details: details,
+		Metrics: metrics,
+	}
+}
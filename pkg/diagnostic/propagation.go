@@ -0,0 +1,267 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	propagationProbeInterval = 200 * time.Millisecond
+	propagationTimeout       = 30 * time.Second
+)
+
+// propagationClient is a probe pod pinned to one node
+type propagationClient struct {
+	Node string
+	Pod  string
+}
+
+// probePropagation reports whether a single fast HTTP request from the client pod reaches ip:80
+func (t *Tester) probePropagation(ctx context.Context, clientPodName, ip string) bool {
+	output, err := t.execInPodWithOutput(ctx, t.namespace, clientPodName, "netshoot",
+		[]string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", "1",
+			fmt.Sprintf("http://%s/", httpTargetForIP(ip))},
+		"Propagation probe")
+
+	code := strings.TrimSpace(output.Stdout)
+	return err == nil && code != "" && code != "000"
+}
+
+// measurePropagation polls from every client in parallel and returns, per node, the time from start until
+// the probe result first equals want. Nodes that never reach it within the timeout are missing from the map.
+func (t *Tester) measurePropagation(ctx context.Context, clients []propagationClient, ip string, want bool, start time.Time) map[string]time.Duration {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	elapsed := make(map[string]time.Duration)
+
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client propagationClient) {
+			defer wg.Done()
+			for time.Since(start) < propagationTimeout && ctx.Err() == nil {
+				probeStart := time.Now()
+				if t.probePropagation(ctx, client.Pod, ip) == want {
+					// Credit the change to when the probe was sent, not when a timed-out probe returned
+					mu.Lock()
+					elapsed[client.Node] = probeStart.Sub(start)
+					mu.Unlock()
+					return
+				}
+				if wait := propagationProbeInterval - time.Since(probeStart); wait > 0 {
+					sleepContext(ctx, wait)
+				}
+			}
+		}(client)
+	}
+	wg.Wait()
+
+	return elapsed
+}
+
+// TestPolicyPropagation measures how long a deny policy takes to block traffic on every worker node, and how long
+// its removal takes to restore it
+func (t *Tester) TestPolicyPropagation(ctx context.Context) TestResult {
+	var details []string
+	metrics := make(map[string]float64)
+
+	webPodName := "web-propagation-test"
+	policyFile := "cilium-policies/13-policy-propagation/deny-propagation-web-policy.yaml"
+
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get worker nodes: %v", err),
+			Details: details,
+		}
+	}
+	if len(workerNodes) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Policy propagation test skipped - no schedulable Linux worker node",
+			Details: details,
+		}
+	}
+
+	var clients []propagationClient
+	for i, node := range workerNodes {
+		clients = append(clients, propagationClient{Node: node, Pod: fmt.Sprintf("client-propagation-%d", i+1)})
+	}
+
+	appliedPolicyName := ""
+	cleanup := func() {
+		if appliedPolicyName != "" {
+			if err := t.deleteNetworkPolicy(ctx, appliedPolicyName); err != nil {
+				details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+			}
+			appliedPolicyName = ""
+		}
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, webPodName, metav1.DeleteOptions{})
+		for _, client := range clients {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, client.Pod, metav1.DeleteOptions{})
+		}
+	}
+
+	// Step 1: Create the web pod and one client per worker node
+	if err := t.createPolicyWebPod(ctx, webPodName, map[string]string{"run": "propagation-web"}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	for _, client := range clients {
+		if err := t.createPolicyClientPod(ctx, t.namespace, client.Pod, client.Node, map[string]string{"run": "propagation-client"}); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: err.Error(),
+				Details: details,
+			}
+		}
+	}
+	podNames := []string{webPodName}
+	for _, client := range clients {
+		podNames = append(podNames, client.Pod)
+	}
+	for _, podName := range podNames {
+		if err := t.waitForPodReady(ctx, podName, 120*time.Second); err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s did not become ready: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	webPod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, webPodName, metav1.GetOptions{})
+	if err != nil || webPod.Status.PodIP == "" {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not get IP for pod %s", webPodName),
+			Details: details,
+		}
+	}
+	webIP := webPod.Status.PodIP
+	details = append(details, fmt.Sprintf("✓ Web pod '%s' ready at %s on %s; %d clients ready, one per worker node",
+		webPodName, webIP, webPod.Spec.NodeName, len(clients)))
+
+	// Step 2: Baseline - every node reaches the web pod
+	for _, client := range clients {
+		if !t.probePropagation(ctx, client.Pod, webIP) {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Baseline failed - client on %s cannot reach the web pod before the policy is applied", client.Node),
+				Details: append(details, fmt.Sprintf("✗ Baseline from %s blocked", client.Node)),
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage: "Baseline Connectivity",
+					TroubleshootingHints: []string{
+						"Check for leftover policies selecting the clients: kubectl get ciliumclusterwidenetworkpolicies",
+					},
+				},
+			}
+		}
+	}
+	details = append(details, "✓ Baseline: web pod reachable from every node")
+
+	// Step 3: Apply the deny policy and time until every node blocks
+	appliedPolicyName, err = t.applyNetworkPolicy(ctx, policyFile)
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to apply network policy: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Applied deny policy %s", appliedPolicyName))
+	details = append(details, fmt.Sprintf("  kubectl apply -f %s", policyFile))
+	blockTimes := t.measurePropagation(ctx, clients, webIP, false, time.Now())
+
+	// Step 4: Delete the policy and time until every node is restored
+	var restoreTimes map[string]time.Duration
+	if err := t.deleteNetworkPolicy(ctx, appliedPolicyName); err != nil {
+		details = append(details, fmt.Sprintf("⚠️ Failed to delete network policy: %v", err))
+	} else {
+		appliedPolicyName = ""
+		details = append(details, "ℹ️ Deleted deny policy")
+		restoreTimes = t.measurePropagation(ctx, clients, webIP, true, time.Now())
+	}
+
+	// Step 5: Report per-node and cluster-wide times
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Node < clients[j].Node })
+	var failures []string
+	var maxBlock, maxRestore time.Duration
+	for _, client := range clients {
+		if block, ok := blockTimes[client.Node]; ok {
+			metrics["block_ms."+client.Node] = float64(block.Milliseconds())
+			if block > maxBlock {
+				maxBlock = block
+			}
+			details = append(details, fmt.Sprintf("✓ %s: blocked after %dms", client.Node, block.Milliseconds()))
+		} else {
+			details = append(details, fmt.Sprintf("✗ %s: not blocked within %s", client.Node, propagationTimeout))
+			failures = append(failures, fmt.Sprintf("%s not blocked within %s", client.Node, propagationTimeout))
+		}
+		if restoreTimes == nil {
+			continue
+		}
+		if restore, ok := restoreTimes[client.Node]; ok {
+			metrics["restore_ms."+client.Node] = float64(restore.Milliseconds())
+			if restore > maxRestore {
+				maxRestore = restore
+			}
+			details = append(details, fmt.Sprintf("✓ %s: restored after %dms", client.Node, restore.Milliseconds()))
+		} else {
+			details = append(details, fmt.Sprintf("✗ %s: not restored within %s", client.Node, propagationTimeout))
+			failures = append(failures, fmt.Sprintf("%s not restored within %s", client.Node, propagationTimeout))
+		}
+	}
+	if len(blockTimes) > 0 {
+		metrics["block_ms.max"] = float64(maxBlock.Milliseconds())
+	}
+	if len(restoreTimes) > 0 {
+		metrics["restore_ms.max"] = float64(maxRestore.Milliseconds())
+	}
+
+	cleanup()
+	details = append(details, "✓ Propagation policy and test pods cleaned up")
+
+	if restoreTimes == nil {
+		failures = append(failures, "deny policy could not be deleted, restore time not measured")
+	}
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Policy propagation incomplete: %s", strings.Join(failures, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Policy Propagation",
+				TechnicalError: strings.Join(failures, "; "),
+				TroubleshootingHints: []string{
+					"Check the agent on the slow node: kubectl -n kube-system get pods -l k8s-app=cilium -o wide",
+					"Look for endpoints stuck regenerating: kubectl -n kube-system exec ds/cilium -- cilium endpoint list",
+					"Check that the cluster supports egressDeny rules (Cilium 1.9+)",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Deny policy enforced on all %d nodes within %dms, removal restored traffic within %dms",
+			len(clients), maxBlock.Milliseconds(), maxRestore.Milliseconds()),
+		Details: details,
+		Metrics: metrics,
+	}
+}
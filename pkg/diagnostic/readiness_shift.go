@@ -0,0 +1,329 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// readinessUnreadyFile fails the readiness probe of the backend's control sidecar while it exists
+	readinessUnreadyFile = "/tmp/unready"
+	// readinessProbeStopFile ends the prober loop in the client pod
+	readinessProbeStopFile = "/tmp/readiness-probe.stop"
+	// readinessSettle keeps probing after the endpoint change so the datapath has time to follow
+	readinessSettle = 5 * time.Second
+	// readinessDatapathLag warns when traffic follows the endpoint change this much later
+	readinessDatapathLag = 3 * time.Second
+)
+
+// createReadinessDeployment runs two echo-server backends whose readiness the test controls: a sidecar's readiness
+// probe fails while readinessUnreadyFile exists, taking the pod out of the Service without affecting the server
+func (t *Tester) createReadinessDeployment(ctx context.Context, name string) (*appsv1.Deployment, error) {
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "echo",
+							Image: t.image(upgradeEchoImage),
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 8080,
+								},
+							},
+						},
+						{
+							Name:    "netshoot",
+							Image:   t.image(netshootImage),
+							Command: []string{"sleep", "3600"},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									Exec: &corev1.ExecAction{Command: []string{"test", "!", "-f", readinessUnreadyFile}},
+								},
+								PeriodSeconds:    1,
+								FailureThreshold: 1,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return t.clientset.AppsV1().Deployments(t.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// waitForPodReadiness waits until the pod's Ready condition matches ready, returning how long it took
+func (t *Tester) waitForPodReadiness(ctx context.Context, podName string, ready bool, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	for time.Since(start) < timeout && ctx.Err() == nil {
+		pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err == nil && isPodReady(pod) == ready {
+			return time.Since(start), nil
+		}
+		sleepContext(ctx, 200*time.Millisecond)
+	}
+	return 0, fmt.Errorf("pod %s did not change its Ready condition to %t within %v", podName, ready, timeout)
+}
+
+// readTestPodUptime reads /proc/uptime in the pod, the clock of the prober loop
+func (t *Tester) readTestPodUptime(ctx context.Context, podName string) (float64, error) {
+	output, err := t.execInPod(ctx, t.namespace, podName, "netshoot", []string{"cut", "-d", " ", "-f1", "/proc/uptime"})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(output), 64)
+}
+
+// TestReadinessTrafficShift flips one backend's readiness off and on while a client pod sends continuous requests
+// to the Service, measuring each step of the chain: probe to Ready condition, Ready condition to EndpointSlice, and
+// EndpointSlice to the datapath no longer (then again) sending the pod traffic
+func (t *Tester) TestReadinessTrafficShift(ctx context.Context) TestResult {
+	var details []string
+	deploymentName := "web-readiness"
+	serviceName := "web-readiness"
+	clientPod := "netshoot-readiness-client"
+	defer t.cleanupServiceResources(ctx, deploymentName, serviceName, clientPod)
+
+	// Step 1: Two backends with a controllable readiness probe, a Service and the client
+	if _, err := t.createReadinessDeployment(ctx, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create deployment %s: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.clientset.CoreV1().Services(t.namespace).Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: serviceName, Namespace: t.namespace},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": deploymentName},
+			Ports:    []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(8080)}},
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create service %s: %v", serviceName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNetshootPod(ctx, clientPod, ""); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create client pod %s: %v", clientPod, err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 90*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if err := t.waitForPodReady(ctx, clientPod, 60*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Client pod %s not ready: %v", clientPod, err),
+			Details: details,
+		}
+	}
+	pods, err := t.clientset.CoreV1().Pods(t.namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", deploymentName)})
+	if err != nil || len(pods.Items) == 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list backend pods: %v", err),
+			Details: details,
+		}
+	}
+	targetPod := pods.Items[0].Name
+	details = append(details, fmt.Sprintf("✓ Deployment '%s' (2 replicas) and service ready, flipping the readiness of %s", deploymentName, targetPod))
+
+	// Step 2: Start the continuous prober; each line records which backend served the request
+	loop := fmt.Sprintf(`rm -f %s; while [ ! -f %s ]; do r=$(curl -s --max-time 1 http://%s | sed -n 's/^Request served by //p'); echo "$(cut -d" " -f1 /proc/uptime) ${r:-FAILED}"; sleep 0.1; done`,
+		readinessProbeStopFile, readinessProbeStopFile, serviceName)
+	probeDone := make(chan string, 1)
+	go func() {
+		output, _ := t.execInPod(ctx, t.namespace, clientPod, "netshoot", []string{"sh", "-c", loop})
+		probeDone <- output
+	}()
+	stopProber := func() []probeSample {
+		t.execInPod(ctx, t.namespace, clientPod, "netshoot", []string{"touch", readinessProbeStopFile})
+		select {
+		case output := <-probeDone:
+			return parseProbeSamples(output)
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}
+	sleepContext(ctx, 2*time.Second)
+
+	// Step 3: Flip the backend unready and back, timing the Ready condition and the EndpointSlice
+	metrics := map[string]float64{}
+	flip := func(ready bool) (float64, error) {
+		base, err := t.readTestPodUptime(ctx, clientPod)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the client pod clock: %v", err)
+		}
+		command := []string{"touch", readinessUnreadyFile}
+		phase := "removal"
+		if ready {
+			command = []string{"rm", "-f", readinessUnreadyFile}
+			phase = "add"
+		}
+		start := time.Now()
+		if _, err := t.execInPod(ctx, t.namespace, targetPod, "netshoot", command); err != nil {
+			return 0, fmt.Errorf("failed to change the readiness of %s: %v", targetPod, err)
+		}
+		if _, err := t.waitForPodReadiness(ctx, targetPod, ready, nodeIsolationTimeout); err != nil {
+			return 0, err
+		}
+		conditionTime := time.Since(start)
+		if _, err := t.waitForEndpointState(ctx, serviceName, targetPod, ready); err != nil {
+			return 0, err
+		}
+		endpointTime := time.Since(start)
+		metrics[fmt.Sprintf("ready_condition_%s_ms", phase)] = float64(conditionTime.Milliseconds())
+		metrics[fmt.Sprintf("endpoint_%s_ms", phase)] = float64(endpointTime.Milliseconds())
+		details = append(details, fmt.Sprintf("✓ Readiness %s: Ready condition after %v, EndpointSlice after %v",
+			phase, conditionTime.Round(10*time.Millisecond), endpointTime.Round(10*time.Millisecond)))
+		sleepContext(ctx, readinessSettle)
+		return base, nil
+	}
+	unreadyBase, err := flip(false)
+	var readyBase float64
+	if err == nil {
+		readyBase, err = flip(true)
+	}
+	samples := stopProber()
+	if err != nil {
+		// Leave the backend ready for the remaining cleanup
+		t.execInPod(ctx, t.namespace, targetPod, "netshoot", []string{"rm", "-f", readinessUnreadyFile})
+		details = append(details, fmt.Sprintf("✗ %v", err))
+		return TestResult{
+			Success: false,
+			Message: "Readiness change did not propagate to the Service endpoints",
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Readiness Propagation",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"A slow Ready condition points at the kubelet's probe workers; a slow EndpointSlice at kube-controller-manager",
+					"Check the endpointslice controller: kubectl -n kube-system logs -l component=kube-controller-manager",
+				},
+			},
+		}
+	}
+	if len(samples) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Continuous prober returned no results",
+			Details: details,
+			Metrics: metrics,
+		}
+	}
+
+	// Step 4: When did the datapath stop and resume sending the backend traffic
+	lastServed, firstResumed := -1.0, -1.0
+	failed, drainSamples := 0, 0
+	for _, sample := range samples {
+		if sample.Status == "FAILED" {
+			failed++
+		}
+		switch {
+		case sample.Uptime >= unreadyBase && sample.Uptime < readyBase:
+			drainSamples++
+			if sample.Status == targetPod {
+				lastServed = sample.Uptime - unreadyBase
+			}
+		case sample.Uptime >= readyBase && firstResumed < 0 && sample.Status == targetPod:
+			firstResumed = sample.Uptime - readyBase
+		}
+	}
+	metrics["requests"] = float64(len(samples))
+	metrics["failed_requests"] = float64(failed)
+
+	var problems []string
+	endpointRemoval := metrics["endpoint_removal_ms"] / 1000
+	endpointAdd := metrics["endpoint_add_ms"] / 1000
+	readyOffset := readyBase - unreadyBase
+	switch {
+	case lastServed < 0:
+		metrics["datapath_removal_ms"] = 0
+		details = append(details, "✓ The unready backend received no requests after the flip")
+	case lastServed >= readyOffset-readinessSettle.Seconds()/2:
+		problems = append(problems, fmt.Sprintf("%s kept receiving traffic until %.1fs after it turned unready", targetPod, lastServed))
+		details = append(details, fmt.Sprintf("✗ Unready %s still served requests %.1fs after the flip - the datapath did not drop the endpoint", targetPod, lastServed))
+	default:
+		metrics["datapath_removal_ms"] = lastServed * 1000
+		marker := "✓"
+		if lastServed-endpointRemoval > readinessDatapathLag.Seconds() {
+			marker = "⚠️"
+		}
+		details = append(details, fmt.Sprintf("%s Last request served by the unready backend %.1fs after the flip (EndpointSlice updated at %.1fs)", marker, lastServed, endpointRemoval))
+	}
+	if firstResumed < 0 {
+		problems = append(problems, fmt.Sprintf("%s received no traffic after turning ready again", targetPod))
+		details = append(details, fmt.Sprintf("✗ %s did not receive traffic again within %v of turning ready", targetPod, readinessSettle))
+	} else {
+		metrics["datapath_add_ms"] = firstResumed * 1000
+		marker := "✓"
+		if firstResumed-endpointAdd > readinessDatapathLag.Seconds() {
+			marker = "⚠️"
+		}
+		details = append(details, fmt.Sprintf("%s Backend served again %.1fs after turning ready (EndpointSlice updated at %.1fs)", marker, firstResumed, endpointAdd))
+	}
+	if failed > 0 {
+		details = append(details, fmt.Sprintf("⚠️ %d of %d requests failed - the backend kept serving throughout, so failures point at the Service datapath", failed, len(samples)))
+	}
+
+	if len(problems) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Readiness changes did not shift Service traffic: %s", strings.Join(problems, "; ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Readiness Traffic Shift",
+				TechnicalError: strings.Join(problems, "; "),
+				TroubleshootingHints: []string{
+					"The EndpointSlice changed but traffic did not follow - check kube-proxy (or the CNI's kube-proxy replacement) is syncing endpoint changes",
+					"Established keep-alive connections are not rebalanced; the prober opens a new connection per request",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Traffic shifted away from the unready backend and back (endpoint removal %.1fs, add %.1fs)", endpointRemoval, endpointAdd),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,205 @@
+package diagnostic
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// redactIPv4Pattern matches IPv4 addresses; candidates are validated with net.ParseIP
+	redactIPv4Pattern = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+	// redactIPv6Pattern matches IPv6 candidates; timestamps and MACs are rejected by net.ParseIP
+	redactIPv6Pattern = regexp.MustCompile(`[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`)
+)
+
+// redactMinNameLength skips names so short that masking them would garble unrelated words; they are listed in
+// Redactor.Unmasked so the caller can warn about them
+const redactMinNameLength = 3
+
+// redactFieldNamePattern matches JSON object keys shaped like field names ("status", "test_name"), which are left
+// alone by RedactJSON even when a namespace or node has the same name
+var redactFieldNamePattern = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+// Redactor replaces node names, namespace names and IP addresses with stable tokens. Tokens are keyed HMACs, so the
+// same value maps to the same token in every report and bundle produced with the same key.
+type Redactor struct {
+	key   []byte
+	names map[string]string
+	// namePattern matches any known name as a whole word, longest names first
+	namePattern *regexp.Regexp
+	// Unmasked lists the names too short to be masked; they appear in clear text
+	Unmasked []string
+}
+
+// NewRedactor learns the node and namespace names of the cluster. Without a key the kube-system namespace UID is
+// used, which is stable for the cluster but does not appear in any report.
+func (t *Tester) NewRedactor(ctx context.Context, key string) (*Redactor, error) {
+	if key == "" {
+		kubeSystem, err := t.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive redaction key: %v", err)
+		}
+		key = string(kubeSystem.UID)
+	}
+	redactor := &Redactor{key: []byte(key), names: make(map[string]string)}
+
+	nodes, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	for _, node := range nodes.Items {
+		redactor.AddName(node.Name, "node")
+	}
+
+	namespaces, err := t.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	for _, namespace := range namespaces.Items {
+		// System namespaces reveal nothing about the cluster and keep kubectl hints readable
+		if namespace.Name == "default" || strings.HasPrefix(namespace.Name, "kube-") {
+			continue
+		}
+		redactor.AddName(namespace.Name, "ns")
+	}
+	// The test namespace may not exist yet when the redactor is created
+	redactor.AddName(t.namespace, "ns")
+	return redactor, nil
+}
+
+// token returns the stable token for value
+func (r *Redactor) token(prefix, value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return fmt.Sprintf("%s-%s", prefix, hex.EncodeToString(mac.Sum(nil))[:8])
+}
+
+// AddName registers a name to be replaced with a token of the given kind, e.g. "node" or "ns"
+func (r *Redactor) AddName(name, kind string) {
+	if _, exists := r.names[name]; exists || name == "" || slices.Contains(r.Unmasked, name) {
+		return
+	}
+	if len(name) < redactMinNameLength {
+		r.Unmasked = append(r.Unmasked, name)
+		return
+	}
+	r.names[name] = r.token(kind, name)
+
+	names := make([]string, 0, len(r.names))
+	for known := range r.names {
+		names = append(names, regexp.QuoteMeta(known))
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	r.namePattern = regexp.MustCompile(`\b(?:` + strings.Join(names, "|") + `)\b`)
+}
+
+// Redact masks known names and all IP addresses in s
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	if r.namePattern != nil {
+		s = r.namePattern.ReplaceAllStringFunc(s, func(name string) string {
+			return r.names[name]
+		})
+	}
+	s = redactIPv4Pattern.ReplaceAllStringFunc(s, func(candidate string) string {
+		if net.ParseIP(candidate) == nil {
+			return candidate
+		}
+		return r.token("ip", candidate)
+	})
+	return redactIPv6Pattern.ReplaceAllStringFunc(s, func(candidate string) string {
+		ip := net.ParseIP(candidate)
+		if ip == nil || ip.IsUnspecified() {
+			return candidate
+		}
+		return r.token("ip6", ip.String())
+	})
+}
+
+// RedactJSON masks the string values of a JSON document and the object keys that are not shaped like field names,
+// e.g. the node names keying a map, so a namespace named "status" does not rename the status fields
+func (r *Redactor) RedactJSON(content []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.redactJSONValue(document)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// redactFile masks the content of a file: the values of a JSON file, so its field names stay intact, and any other
+// file as text
+func (r *Redactor) redactFile(path string, content []byte) []byte {
+	if filepath.Ext(path) == ".json" {
+		if redacted, err := r.RedactJSON(content); err == nil {
+			return redacted
+		}
+	}
+	return []byte(r.Redact(string(content)))
+}
+
+// redactJSONValue masks a decoded JSON value
+func (r *Redactor) redactJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		return r.Redact(value)
+	case []interface{}:
+		for i, item := range value {
+			value[i] = r.redactJSONValue(item)
+		}
+		return value
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if !redactFieldNamePattern.MatchString(key) {
+				key = r.Redact(key)
+			}
+			redacted[key] = r.redactJSONValue(item)
+		}
+		return redacted
+	}
+	return value
+}
+
+// RedactJSONReport masks node names, namespace names and IP addresses in the values of the report
+func RedactJSONReport(report *DiagnosticReportJSON, redactor *Redactor) error {
+	if redactor == nil {
+		return nil
+	}
+	content, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report for redaction: %v", err)
+	}
+	if content, err = redactor.RedactJSON(content); err != nil {
+		return fmt.Errorf("failed to redact report: %v", err)
+	}
+	var redacted DiagnosticReportJSON
+	if err := json.Unmarshal(content, &redacted); err != nil {
+		return fmt.Errorf("failed to redact report: %v", err)
+	}
+	*report = redacted
+	return nil
+}
@@ -0,0 +1,258 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MergedReportVersion is the version of the merged report layout, independent of ReportSchemaVersion
+const MergedReportVersion = "1"
+
+// MergeSource is one report to merge with the cluster it counts for
+type MergeSource struct {
+	File    string
+	Cluster string // overrides execution_info.cluster when set
+	Report  *DiagnosticReportJSON
+}
+
+// MergedReport aggregates the reports of several runs, e.g. the per-cluster reports of a fleet run or a week of runs
+// on one cluster, with per-cluster, per-day and per-test breakdowns for reliability reviews
+type MergedReport struct {
+	SchemaVersion        string             `json:"schema_version"`
+	GeneratedAt          string             `json:"generated_at"`
+	FirstRun             string             `json:"first_run"`
+	LastRun              string             `json:"last_run"`
+	ReportSchemaVersions map[string]int     `json:"report_schema_versions"` // schema_version -> reports, to spot mixed releases
+	Runs                 []MergedRun        `json:"runs"`
+	Summary              MergedStats        `json:"summary"`
+	Clusters             []MergedStats      `json:"clusters"`
+	Days                 []MergedStats      `json:"days"`
+	Tests                []MergedTestStats  `json:"tests"` // least reliable first
+	Failures             []MergedTestResult `json:"failures,omitempty"`
+}
+
+// MergedRun identifies one merged report
+type MergedRun struct {
+	File          string `json:"file"`
+	Cluster       string `json:"cluster"`
+	RunID         string `json:"run_id,omitempty"`
+	Timestamp     string `json:"timestamp"`
+	OverallStatus string `json:"overall_status"`
+	SchemaVersion string `json:"schema_version"`
+}
+
+// MergedStats counts runs and test results of all reports, a cluster or a day
+type MergedStats struct {
+	Name        string         `json:"name,omitempty"` // cluster or day (UTC, 2006-01-02)
+	Runs        int            `json:"runs"`
+	RunsFailed  int            `json:"runs_failed"`
+	Tests       int            `json:"tests"`
+	Passed      int            `json:"passed"`
+	Warnings    int            `json:"warnings"`
+	Failed      int            `json:"failed"`
+	Skipped     int            `json:"skipped"`
+	PassRatePct float64        `json:"pass_rate_pct"`          // passed and warnings of the tests that ran
+	FailedTests map[string]int `json:"failed_tests,omitempty"` // test name -> failures
+}
+
+// MergedTestStats counts the results of one test across the merged runs
+type MergedTestStats struct {
+	TestName       string   `json:"test_name"`
+	Runs           int      `json:"runs"`
+	Passed         int      `json:"passed"`
+	Warnings       int      `json:"warnings"`
+	Failed         int      `json:"failed"`
+	Skipped        int      `json:"skipped"`
+	FailureRatePct float64  `json:"failure_rate_pct"` // failures of the runs the test ran in
+	FailedClusters []string `json:"failed_clusters,omitempty"`
+}
+
+// MergedTestResult is one failure of a merged run
+type MergedTestResult struct {
+	Cluster   string `json:"cluster"`
+	Timestamp string `json:"timestamp"`
+	TestName  string `json:"test_name"`
+	Message   string `json:"message"`
+}
+
+// LoadReport reads a JSON report of this or an older release; older layouts are read for the fields they share
+func LoadReport(path string) (*DiagnosticReportJSON, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report, err := ParseReport(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return report, nil
+}
+
+// ParseReport decodes a JSON report of this or an older release
+func ParseReport(content []byte) (*DiagnosticReportJSON, error) {
+	var report DiagnosticReportJSON
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, fmt.Errorf("not a JSON report: %v", err)
+	}
+	if report.SchemaVersion == "" {
+		report.SchemaVersion = "1"
+	}
+	version, errVersion := strconv.Atoi(report.SchemaVersion)
+	current, _ := strconv.Atoi(ReportSchemaVersion)
+	if errVersion != nil || version > current {
+		return nil, fmt.Errorf("schema_version %s is newer than this k8s-diagnostic reads (%s)", report.SchemaVersion, ReportSchemaVersion)
+	}
+	return &report, nil
+}
+
+// cluster is the cluster a report counts for: the source's, else execution_info.cluster, else the kubeconfig source
+func (s MergeSource) cluster() string {
+	if s.Cluster != "" {
+		return s.Cluster
+	}
+	if s.Report.ExecutionInfo.Cluster != "" {
+		return s.Report.ExecutionInfo.Cluster
+	}
+	return s.Report.ExecutionInfo.KubeconfigSource
+}
+
+// add counts the results of one report
+func (s *MergedStats) add(report *DiagnosticReportJSON) {
+	s.Runs++
+	if report.Summary.OverallStatus == StatusFailed {
+		s.RunsFailed++
+	}
+	for _, test := range report.Tests {
+		s.Tests++
+		switch test.Status {
+		case StatusPassed:
+			s.Passed++
+		case StatusWarning:
+			s.Warnings++
+		case StatusSkipped:
+			s.Skipped++
+		default:
+			s.Failed++
+			if s.FailedTests == nil {
+				s.FailedTests = map[string]int{}
+			}
+			s.FailedTests[test.TestName]++
+		}
+	}
+	if ran := s.Tests - s.Skipped; ran > 0 {
+		s.PassRatePct = float64(s.Passed+s.Warnings) / float64(ran) * 100
+	}
+}
+
+// MergeReports aggregates reports into one document. The cluster of a report is its MergeSource.Cluster, else
+// execution_info.cluster, else its kubeconfig source; its day is the UTC date it started.
+func MergeReports(sources []MergeSource) MergedReport {
+	merged := MergedReport{
+		SchemaVersion:        MergedReportVersion,
+		GeneratedAt:          time.Now().Format(time.RFC3339),
+		ReportSchemaVersions: map[string]int{},
+	}
+	clusters := map[string]*MergedStats{}
+	days := map[string]*MergedStats{}
+	tests := map[string]*MergedTestStats{}
+	failedClusters := map[string]map[string]bool{}
+	var first, last time.Time
+
+	for _, source := range sources {
+		report := source.Report
+		cluster := source.cluster()
+		day := "unknown"
+		if start, err := time.Parse(time.RFC3339, report.ExecutionInfo.Timestamp); err == nil {
+			day = start.UTC().Format("2006-01-02")
+			if first.IsZero() || start.Before(first) {
+				first = start
+			}
+			if start.After(last) {
+				last = start
+			}
+		}
+
+		merged.Runs = append(merged.Runs, MergedRun{
+			File:          source.File,
+			Cluster:       cluster,
+			RunID:         report.ExecutionInfo.RunID,
+			Timestamp:     report.ExecutionInfo.Timestamp,
+			OverallStatus: report.Summary.OverallStatus,
+			SchemaVersion: report.SchemaVersion,
+		})
+		merged.ReportSchemaVersions[report.SchemaVersion]++
+		merged.Summary.add(report)
+		if clusters[cluster] == nil {
+			clusters[cluster] = &MergedStats{Name: cluster}
+		}
+		clusters[cluster].add(report)
+		if days[day] == nil {
+			days[day] = &MergedStats{Name: day}
+		}
+		days[day].add(report)
+
+		for _, test := range report.Tests {
+			stats := tests[test.TestName]
+			if stats == nil {
+				stats = &MergedTestStats{TestName: test.TestName}
+				tests[test.TestName] = stats
+				failedClusters[test.TestName] = map[string]bool{}
+			}
+			stats.Runs++
+			switch test.Status {
+			case StatusPassed:
+				stats.Passed++
+			case StatusWarning:
+				stats.Warnings++
+			case StatusSkipped:
+				stats.Skipped++
+			default:
+				stats.Failed++
+				failedClusters[test.TestName][cluster] = true
+				merged.Failures = append(merged.Failures, MergedTestResult{
+					Cluster:   cluster,
+					Timestamp: report.ExecutionInfo.Timestamp,
+					TestName:  test.TestName,
+					Message:   test.ErrorMessage,
+				})
+			}
+		}
+	}
+
+	for _, stats := range clusters {
+		merged.Clusters = append(merged.Clusters, *stats)
+	}
+	sort.Slice(merged.Clusters, func(i, j int) bool { return merged.Clusters[i].Name < merged.Clusters[j].Name })
+	for _, stats := range days {
+		merged.Days = append(merged.Days, *stats)
+	}
+	sort.Slice(merged.Days, func(i, j int) bool { return merged.Days[i].Name < merged.Days[j].Name })
+
+	for name, stats := range tests {
+		if ran := stats.Runs - stats.Skipped; ran > 0 {
+			stats.FailureRatePct = float64(stats.Failed) / float64(ran) * 100
+		}
+		for cluster := range failedClusters[name] {
+			stats.FailedClusters = append(stats.FailedClusters, cluster)
+		}
+		sort.Strings(stats.FailedClusters)
+		merged.Tests = append(merged.Tests, *stats)
+	}
+	// Least reliable tests first
+	sort.Slice(merged.Tests, func(i, j int) bool {
+		if merged.Tests[i].FailureRatePct != merged.Tests[j].FailureRatePct {
+			return merged.Tests[i].FailureRatePct > merged.Tests[j].FailureRatePct
+		}
+		return merged.Tests[i].TestName < merged.Tests[j].TestName
+	})
+
+	if !first.IsZero() {
+		merged.FirstRun = first.Format(time.RFC3339)
+		merged.LastRun = last.Format(time.RFC3339)
+	}
+	return merged
+}
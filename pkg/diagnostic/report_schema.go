@@ -0,0 +1,217 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reportSchemaEnums are the closed value sets of the report, by path in the document
+var reportSchemaEnums = map[string][]string{
+	"tests[].status":         {StatusPassed, StatusWarning, StatusFailed, StatusSkipped},
+	"summary.overall_status": {StatusPassed, StatusWarning, StatusFailed},
+}
+
+// ReportSchema returns the JSON Schema of the current report layout, generated from DiagnosticReportJSON so it cannot
+// drift from what the test command writes. Objects do not allow unknown properties, so a report of another layout
+// fails validation instead of being read half right.
+func ReportSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(DiagnosticReportJSON{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "k8s-diagnostic report"
+	schema["description"] = fmt.Sprintf("JSON report of a k8s-diagnostic test run, schema_version %s", ReportSchemaVersion)
+	schema["properties"].(map[string]interface{})["schema_version"] = map[string]interface{}{"type": "string", "const": ReportSchemaVersion}
+	return schema
+}
+
+// typeSchema describes a Go type the way encoding/json writes it; path locates it in the report for the enums
+func typeSchema(t reflect.Type, path string) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := typeSchema(t.Elem(), path)
+		schema["type"] = []string{schema["type"].(string), "null"}
+		return schema
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			properties[name] = typeSchema(field.Type, fieldPath)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), path+"{}")}
+	case reflect.Slice, reflect.Array:
+		// nil slices are written as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(t.Elem(), path+"[]")}
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := reportSchemaEnums[path]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// ValidateReport checks a JSON report against ReportSchema and returns its schema version and what does not match.
+// A report of another schema version is not checked field by field: the version mismatch is the one problem returned.
+func ValidateReport(content []byte) (string, []string) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return "", []string{fmt.Sprintf("not JSON: %v", err)}
+	}
+	report, ok := document.(map[string]interface{})
+	if !ok {
+		return "", []string{"not a report: the document is not a JSON object"}
+	}
+
+	// Version 1 reports were written before schema_version existed
+	version := "1"
+	if value, ok := report["schema_version"].(string); ok {
+		version = value
+	}
+	if version != ReportSchemaVersion {
+		problem := fmt.Sprintf("schema_version %s, this k8s-diagnostic reads version %s", version, ReportSchemaVersion)
+		if newer, err := strconv.Atoi(version); err == nil {
+			if current, _ := strconv.Atoi(ReportSchemaVersion); newer > current {
+				problem += " - the report was written by a newer release"
+			} else {
+				problem += " - the report was written by an older release; see the schema_version history in the README"
+			}
+		}
+		return version, []string{problem}
+	}
+
+	var problems []string
+	validateSchema(document, ReportSchema(), "", &problems)
+	return version, problems
+}
+
+// validateSchema checks value against the subset of JSON Schema typeSchema generates
+func validateSchema(value interface{}, schema map[string]interface{}, path string, problems *[]string) {
+	location := path
+	if location == "" {
+		location = "report"
+	}
+	if want, ok := schema["type"]; ok {
+		types, ok := want.([]string)
+		if !ok {
+			types = []string{want.(string)}
+		}
+		matched := false
+		for _, t := range types {
+			matched = matched || jsonTypeMatches(value, t)
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Sprintf("%s: %s, want %s", location, jsonTypeName(value), strings.Join(types, " or ")))
+			return
+		}
+	}
+	if want, ok := schema["const"].(string); ok && value != want {
+		*problems = append(*problems, fmt.Sprintf("%s: %v, want %q", location, value, want))
+	}
+	if values, ok := schema["enum"].([]string); ok {
+		if text, isString := value.(string); isString && !slices.Contains(values, text) {
+			*problems = append(*problems, fmt.Sprintf("%s: %q is not one of %s", location, text, strings.Join(values, ", ")))
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := value[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing %s", location, name))
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			childPath := name
+			if path != "" {
+				childPath = path + "." + name
+			}
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				validateSchema(value[name], property, childPath, problems)
+			} else if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				validateSchema(value[name], additional, childPath, problems)
+			} else if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+				*problems = append(*problems, fmt.Sprintf("%s: unknown property %s", location, name))
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range value {
+				validateSchema(item, items, fmt.Sprintf("%s[%d]", location, i), problems)
+			}
+		}
+	}
+}
+
+// jsonTypeMatches reports whether a decoded JSON value has a JSON Schema type
+func jsonTypeMatches(value interface{}, t string) bool {
+	switch value := value.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case json.Number:
+		if t == "number" {
+			return true
+		}
+		_, err := value.Int64()
+		return t == "integer" && err == nil
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+// jsonTypeName names the JSON type of a decoded value for problems
+func jsonTypeName(value interface{}) string {
+	for _, t := range []string{"null", "boolean", "string", "integer", "number", "array", "object"} {
+		if jsonTypeMatches(value, t) {
+			return t
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,206 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RunInfo describes a run as it starts
+type RunInfo struct {
+	Namespace string    `json:"namespace"`
+	Tests     []string  `json:"tests"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// Reporter receives the progress of a run as it happens, so output formats stay out of the test logic. The runner
+// sends TestStarted, StepCompleted and TestFinished; RunStarted and RunFinished are sent by the caller, which builds
+// the report. StepOutput and StepCompleted may be called from several goroutines of a test, but never concurrently.
+type Reporter interface {
+	RunStarted(ctx context.Context, run RunInfo)
+	TestStarted(ctx context.Context, number int, test Test)
+	// StepOutput reports a line of output of a command the running test is executing in a test pod, as it arrives
+	StepOutput(ctx context.Context, test Test, description, line string)
+	// StepCompleted reports a command the running test executed in a test pod
+	StepCompleted(ctx context.Context, test Test, step CommandOutput)
+	TestFinished(ctx context.Context, number int, test Test, result *TimedTestResult)
+	RunFinished(ctx context.Context, report *DiagnosticReportJSON) error
+}
+
+// NopReporter ignores every event; reporters embed it to implement only the events they use
+type NopReporter struct{}
+
+func (NopReporter) RunStarted(context.Context, RunInfo)                       {}
+func (NopReporter) TestStarted(context.Context, int, Test)                    {}
+func (NopReporter) StepOutput(context.Context, Test, string, string)          {}
+func (NopReporter) StepCompleted(context.Context, Test, CommandOutput)        {}
+func (NopReporter) TestFinished(context.Context, int, Test, *TimedTestResult) {}
+func (NopReporter) RunFinished(context.Context, *DiagnosticReportJSON) error  { return nil }
+
+// MultiReporter sends every event to each of its reporters in order
+type MultiReporter []Reporter
+
+func (m MultiReporter) RunStarted(ctx context.Context, run RunInfo) {
+	for _, r := range m {
+		r.RunStarted(ctx, run)
+	}
+}
+
+func (m MultiReporter) TestStarted(ctx context.Context, number int, test Test) {
+	for _, r := range m {
+		r.TestStarted(ctx, number, test)
+	}
+}
+
+func (m MultiReporter) StepOutput(ctx context.Context, test Test, description, line string) {
+	for _, r := range m {
+		r.StepOutput(ctx, test, description, line)
+	}
+}
+
+func (m MultiReporter) StepCompleted(ctx context.Context, test Test, step CommandOutput) {
+	for _, r := range m {
+		r.StepCompleted(ctx, test, step)
+	}
+}
+
+func (m MultiReporter) TestFinished(ctx context.Context, number int, test Test, result *TimedTestResult) {
+	for _, r := range m {
+		r.TestFinished(ctx, number, test, result)
+	}
+}
+
+// RunFinished sends the report to every reporter, also after one of them failed, and returns their errors joined
+func (m MultiReporter) RunFinished(ctx context.Context, report *DiagnosticReportJSON) error {
+	var errs []error
+	for _, r := range m {
+		if err := r.RunFinished(ctx, report); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// JSONFileReporter writes the report to Filename, or a timestamped file, in Dir (test_results when empty) and records
+// the file name in the report's execution info
+type JSONFileReporter struct {
+	NopReporter
+	Dir      string
+	Filename string
+}
+
+func (r JSONFileReporter) RunFinished(_ context.Context, report *DiagnosticReportJSON) error {
+	dir := r.Dir
+	if dir == "" {
+		dir = "test_results"
+	}
+	return saveJSONReport(dir, r.Filename, report)
+}
+
+// HTMLFileReporter renders the report as a standalone HTML page into Path
+type HTMLFileReporter struct {
+	NopReporter
+	Path string
+}
+
+func (r HTMLFileReporter) RunFinished(_ context.Context, report *DiagnosticReportJSON) error {
+	file, err := os.Create(r.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", r.Path, err)
+	}
+	if err := RenderHTMLReport(file, report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// JSONWriterReporter writes the report as indented JSON to Writer
+type JSONWriterReporter struct {
+	NopReporter
+	Writer io.Writer
+}
+
+func (r JSONWriterReporter) RunFinished(_ context.Context, report *DiagnosticReportJSON) error {
+	encoder := json.NewEncoder(r.Writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// ConfigMapReporter keeps the report in a ConfigMap in Namespace, pruning all but the newest Keep reports (0 keeps
+// all), like --persist-namespace
+type ConfigMapReporter struct {
+	NopReporter
+	Tester    *Tester
+	Namespace string
+	Keep      int
+}
+
+func (r ConfigMapReporter) RunFinished(ctx context.Context, report *DiagnosticReportJSON) error {
+	_, err := r.Tester.PersistReport(ctx, report, r.Namespace, r.Keep)
+	return err
+}
+
+// stepHandlers receive what the running test does; nil handlers are skipped
+type stepHandlers struct {
+	output    func(description, line string) // a line of output of a command executing in a test pod
+	completed func(step CommandOutput)       // a command executed in a test pod
+	command   func(command string)           // the kubectl equivalent of an API request or exec
+	response  func(response HTTPResponse)    // a response to an HTTP probe
+}
+
+// stepReporter forwards the steps of the running test to the runner, which records them in the test's diagnostics
+// and passes them on to its reporter
+type stepReporter struct {
+	mu       sync.Mutex
+	handlers stepHandlers
+}
+
+// reportSteps sends the steps of the running test to handlers until they are replaced; empty handlers stop
+// reporting
+func (t *Tester) reportSteps(handlers stepHandlers) {
+	t.steps.mu.Lock()
+	defer t.steps.mu.Unlock()
+	t.steps.handlers = handlers
+}
+
+// stepOutput reports a line of output of a running command to the current output handler, if any
+func (t *Tester) stepOutput(description, line string) {
+	t.steps.mu.Lock()
+	defer t.steps.mu.Unlock()
+	if t.steps.handlers.output != nil {
+		t.steps.handlers.output(description, line)
+	}
+}
+
+// stepCompleted reports an executed command to the current step handler, if any
+func (t *Tester) stepCompleted(step CommandOutput) {
+	t.steps.mu.Lock()
+	defer t.steps.mu.Unlock()
+	if t.steps.handlers.completed != nil {
+		t.steps.handlers.completed(step)
+	}
+}
+
+// stepResponse reports the response to an HTTP probe to the current handler, if any
+func (t *Tester) stepResponse(response HTTPResponse) {
+	t.steps.mu.Lock()
+	defer t.steps.mu.Unlock()
+	if t.steps.handlers.response != nil {
+		t.steps.handlers.response(response)
+	}
+}
+
+// recordCommand reports the kubectl equivalent of an action of the running test to the current handler, if any
+func (s *stepReporter) recordCommand(command string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers.command != nil {
+		s.handlers.command(command)
+	}
+}
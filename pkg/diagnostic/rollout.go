@@ -0,0 +1,396 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// rolloutImage is the tag the rollout test updates the nginx deployment to before rolling back
+	rolloutImage = "nginx:stable-alpine"
+	// rolloutTimeout bounds each of the rollout and the rollback
+	rolloutTimeout = 90 * time.Second
+	// rolloutProbeStopFile ends the prober loop in the client pod
+	rolloutProbeStopFile = "/tmp/rollout-probe.stop"
+)
+
+// createRolloutDeployment creates a 2-replica nginx deployment configured for zero-downtime updates: a readiness
+// probe, no unavailable replicas during the update and a preStop delay covering endpoint propagation
+func (t *Tester) createRolloutDeployment(ctx context.Context, name string) (*appsv1.Deployment, error) {
+	replicas := int32(2)
+	maxUnavailable := intstr.FromInt(0)
+	maxSurge := intstr.FromInt(1)
+	gracePeriod := int64(20)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: &maxUnavailable,
+					MaxSurge:       &maxSurge,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: &gracePeriod,
+					Containers: []corev1.Container{
+						{
+							Name:  "nginx",
+							Image: t.image(nginxImage),
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 80,
+								},
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+								},
+								PeriodSeconds: 1,
+							},
+							Lifecycle: &corev1.Lifecycle{
+								PreStop: &corev1.LifecycleHandler{
+									Exec: &corev1.ExecAction{Command: []string{"sleep", "5"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return t.clientset.AppsV1().Deployments(t.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// waitForRolloutComplete waits until every replica runs the current template and is available, with no old
+// replicas left, returning how long it took
+func (t *Tester) waitForRolloutComplete(ctx context.Context, name string, started time.Time) (time.Duration, error) {
+	for time.Since(started) < rolloutTimeout {
+		deployment, err := t.clientset.AppsV1().Deployments(t.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil {
+			replicas := *deployment.Spec.Replicas
+			status := deployment.Status
+			if status.ObservedGeneration >= deployment.Generation && status.UpdatedReplicas == replicas &&
+				status.AvailableReplicas == replicas && status.Replicas == replicas {
+				return time.Since(started), nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return 0, fmt.Errorf("deployment %s did not finish rolling out within %v", name, rolloutTimeout)
+}
+
+// rollbackDeployment restores the pod template of the previous revision's ReplicaSet, like kubectl rollout undo
+func (t *Tester) rollbackDeployment(ctx context.Context, name string) error {
+	replicaSets, err := t.clientset.AppsV1().ReplicaSets(t.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list ReplicaSets: %v", err)
+	}
+	revisions := map[int]appsv1.ReplicaSet{}
+	var numbers []int
+	for _, replicaSet := range replicaSets.Items {
+		revision, err := strconv.Atoi(replicaSet.Annotations["deployment.kubernetes.io/revision"])
+		if err != nil {
+			continue
+		}
+		revisions[revision] = replicaSet
+		numbers = append(numbers, revision)
+	}
+	if len(numbers) < 2 {
+		return fmt.Errorf("no previous revision to roll back to (%d ReplicaSets)", len(numbers))
+	}
+	sort.Ints(numbers)
+	previous := revisions[numbers[len(numbers)-2]]
+
+	deployment, err := t.clientset.AppsV1().Deployments(t.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to read deployment %s: %v", name, err)
+	}
+	template := previous.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	deployment.Spec.Template = *template
+	if _, err := t.clientset.AppsV1().Deployments(t.namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to roll back deployment %s: %v", name, err)
+	}
+	return nil
+}
+
+// probeSample is one request of the continuous prober, timed by the client pod's /proc/uptime
+type probeSample struct {
+	Uptime float64
+	Status string
+}
+
+// parseProbeSamples parses "<uptime> <http status>" lines of the prober loop
+func parseProbeSamples(output string) []probeSample {
+	var samples []probeSample
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		uptime, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, probeSample{Uptime: uptime, Status: fields[1]})
+	}
+	return samples
+}
+
+// downtimeWindow is a run of consecutive failed probes, in seconds relative to the start of the rollout
+type downtimeWindow struct {
+	Start, End float64
+	Failures   int
+}
+
+// downtimeWindows groups consecutive failed probes into windows relative to the base uptime
+func downtimeWindows(samples []probeSample, base float64) []downtimeWindow {
+	var windows []downtimeWindow
+	var current *downtimeWindow
+	for _, sample := range samples {
+		if sample.Status == "200" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			windows = append(windows, downtimeWindow{Start: sample.Uptime - base, End: sample.Uptime - base})
+			current = &windows[len(windows)-1]
+		}
+		current.End = sample.Uptime - base
+		current.Failures++
+	}
+	return windows
+}
+
+// TestDeploymentRollout performs a rolling update of an nginx deployment to a new image tag and rolls it back while
+// a client pod sends continuous requests to its Service, reporting failed requests and any downtime window
+func (t *Tester) TestDeploymentRollout(ctx context.Context) TestResult {
+	var details []string
+	deploymentName := "web-rollout"
+	serviceName := "web-rollout"
+	clientPodName := "netshoot-rollout-test"
+	defer t.cleanupServiceResources(ctx, deploymentName, serviceName, clientPodName)
+
+	// Step 1: Deployment, Service and the prober pod
+	if _, err := t.createRolloutDeployment(ctx, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create deployment %s: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNginxService(ctx, serviceName, deploymentName); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create service %s: %v", serviceName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createNetshootPod(ctx, clientPodName, ""); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create client pod %s: %v", clientPodName, err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 60*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if err := t.waitForPodReady(ctx, clientPodName, 60*time.Second); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Client pod %s not ready: %v", clientPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Deployment '%s' (2 replicas, maxUnavailable 0, readiness probe, preStop delay) and service ready", deploymentName))
+
+	// Step 2: Start the continuous prober; it runs until the stop file appears
+	loop := fmt.Sprintf(`rm -f %s; while [ ! -f %s ]; do echo "$(cut -d" " -f1 /proc/uptime) $(curl -s -o /dev/null -w %%{http_code} --max-time 1 http://%s)"; sleep 0.1; done`,
+		rolloutProbeStopFile, rolloutProbeStopFile, serviceName)
+	probeDone := make(chan string, 1)
+	go func() {
+		output, _ := t.execInPod(ctx, t.namespace, clientPodName, "netshoot", []string{"sh", "-c", loop})
+		probeDone <- output
+	}()
+	sleepContext(ctx, 2*time.Second)
+	baseOutput, err := t.execInPod(ctx, t.namespace, clientPodName, "netshoot", []string{"cut", "-d", " ", "-f1", "/proc/uptime"})
+	base, parseErr := strconv.ParseFloat(strings.TrimSpace(baseOutput), 64)
+	if err != nil || parseErr != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read the client pod clock: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Continuous prober started against http://%s", serviceName))
+
+	// Step 3: Rolling update to a new image tag, then roll back
+	metrics := map[string]float64{}
+	stopProber := func() []probeSample {
+		t.execInPod(ctx, t.namespace, clientPodName, "netshoot", []string{"touch", rolloutProbeStopFile})
+		select {
+		case output := <-probeDone:
+			return parseProbeSamples(output)
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}
+	started := time.Now()
+	patch := fmt.Sprintf(`{"spec":{"template":{"spec":{"containers":[{"name":"nginx","image":"%s"}]}}}}`, t.image(rolloutImage))
+	if _, err := t.clientset.AppsV1().Deployments(t.namespace).Patch(ctx, deploymentName, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		stopProber()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to update deployment image: %v", err),
+			Details: details,
+		}
+	}
+	rolloutTime, err := t.waitForRolloutComplete(ctx, deploymentName, started)
+	if err != nil {
+		stopProber()
+		details = append(details, fmt.Sprintf("✗ %v", err))
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Rolling update to %s did not complete", rolloutImage),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Rollout",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check the new pods: kubectl get pods -n %s -l app=%s", t.namespace, deploymentName),
+					fmt.Sprintf("An image pull failure for %s stalls the rollout with maxUnavailable 0 - check registry access", rolloutImage),
+				},
+			},
+		}
+	}
+	metrics["rollout_ms"] = float64(rolloutTime.Milliseconds())
+	details = append(details, fmt.Sprintf("✓ Rolled out %s in %v", rolloutImage, rolloutTime.Round(time.Millisecond)))
+
+	rollbackStarted := time.Now()
+	if err := t.rollbackDeployment(ctx, deploymentName); err != nil {
+		stopProber()
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+			Metrics: metrics,
+		}
+	}
+	rollbackTime, err := t.waitForRolloutComplete(ctx, deploymentName, rollbackStarted)
+	if err != nil {
+		stopProber()
+		details = append(details, fmt.Sprintf("✗ %v", err))
+		return TestResult{
+			Success: false,
+			Message: "Rollback did not complete",
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Rollback",
+				TechnicalError: err.Error(),
+			},
+		}
+	}
+	metrics["rollback_ms"] = float64(rollbackTime.Milliseconds())
+	details = append(details, fmt.Sprintf("✓ Rolled back to the previous revision in %v", rollbackTime.Round(time.Millisecond)))
+	// Keep probing through the end of the old pods' preStop delay and termination
+	sleepContext(ctx, 5*time.Second)
+
+	// Step 4: Evaluate the prober results
+	samples := stopProber()
+	if len(samples) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Continuous prober returned no results",
+			Details: details,
+			Metrics: metrics,
+		}
+	}
+	rollbackOffset := rollbackStarted.Sub(started).Seconds()
+	windows := downtimeWindows(samples, base)
+	failed := 0
+	maxDowntime := 0.0
+	for _, window := range windows {
+		failed += window.Failures
+		if window.End-window.Start > maxDowntime {
+			maxDowntime = window.End - window.Start
+		}
+	}
+	metrics["requests"] = float64(len(samples))
+	metrics["failed_requests"] = float64(failed)
+	metrics["max_downtime_ms"] = maxDowntime * 1000
+
+	if failed > 0 {
+		var windowDescriptions []string
+		for _, window := range windows {
+			phase := "rollout"
+			if window.Start >= rollbackOffset {
+				phase = "rollback"
+			}
+			windowDescriptions = append(windowDescriptions, fmt.Sprintf("%.1fs-%.1fs (%s, %d failed)", window.Start, window.End, phase, window.Failures))
+			details = append(details, fmt.Sprintf("✗ Downtime during %s from %.1fs to %.1fs after the update started: %d failed requests", phase, window.Start, window.End, window.Failures))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d requests failed during rollout and rollback (longest downtime %.1fs)", failed, len(samples), maxDowntime),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Zero-Downtime Rollout",
+				TechnicalError: strings.Join(windowDescriptions, "; "),
+				TroubleshootingHints: []string{
+					"The deployment waits for readiness and delays termination by 5s, so failures point at Service endpoint propagation lagging pod changes",
+					"Check how quickly kube-proxy or the CNI dataplane syncs endpoint changes (kube-proxy sync_proxy_rules_duration_seconds, Cilium agent logs)",
+					"Failures right after new pods turn ready mean traffic reached pods before they served - check the readiness probe",
+				},
+			},
+		}
+	}
+
+	details = append(details, fmt.Sprintf("✓ All %d requests succeeded during rollout and rollback", len(samples)))
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Rolled out and rolled back with zero failed requests (%d requests)", len(samples)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
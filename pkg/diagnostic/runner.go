@@ -0,0 +1,340 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TestDependencies lists the prerequisites of a test: it is not run when a prerequisite ran earlier in the same run
+// and failed, since it would fail with the same root cause. Tests that diagnose the layers below (CNI health,
+// overlay, routes, kube-proxy) have no prerequisites so they still run when connectivity is broken.
+var TestDependencies = map[string][]string{
+	"accepting-all-pods":     {"pod-to-pod"},
+	"rejecting-all-pods":     {"pod-to-pod"},
+	"l4-ingress-ports":       {"pod-to-pod"},
+	"l4-egress-ports":        {"pod-to-pod"},
+	"default-deny-allowlist": {"pod-to-pod", "dns"},
+	"namespace-isolation":    {"pod-to-pod"},
+	"egress-dns-allow":       {"pod-to-pod", "dns"},
+	"policy-propagation":     {"pod-to-pod"},
+	"host-firewall":          {"pod-to-pod"},
+	"netpol-ingress":         {"pod-to-pod"},
+	"netpol-egress":          {"pod-to-pod"},
+	"cross-node":             {"service-to-pod"},
+	"tls":                    {"service-to-pod"},
+	"grpc":                   {"service-to-pod"},
+	"websocket-http2":        {"service-to-pod"},
+	"idle-timeout":           {"service-to-pod"},
+	"pvc-rwx":                {"pvc-access"},
+	"pvc-expand":             {"pvc-access"},
+	"deployment-rollout":     {"service-to-pod"},
+	"hpa-scaling":            {"service-to-pod"},
+	"readiness-shift":        {"service-to-pod"},
+	"fault-latency":          {"pod-to-pod"},
+	"fault-loss":             {"pod-to-pod", "service-to-pod"},
+	"node-isolation":         {"service-to-pod"},
+	"dns-failure":            {"dns"},
+	"cni-restart":            {"pod-to-pod"},
+}
+
+// defaultTestTimeout is the time budget of a test that waits for nothing beyond its pods and probes
+const defaultTestTimeout = 3 * time.Minute
+
+// testTimeouts gives the tests that wait on purpose, e.g. for a cloud load balancer, a budget on top of
+// defaultTestTimeout that covers their configured waits
+var testTimeouts = map[string]func(config TestConfig) time.Duration{
+	"loadbalancer": func(config TestConfig) time.Duration {
+		assign, ready := config.LoadBalancerTimeout, config.LoadBalancerReadyTimeout
+		if assign <= 0 {
+			assign = 60 * time.Second
+		}
+		// The provider is only detected when the test runs, so the cloud wait is budgeted
+		if ready <= 0 {
+			ready = defaultLoadBalancerReadyTimeout
+		}
+		return assign + ready
+	},
+	"external-dns": func(config TestConfig) time.Duration {
+		assign, resolve := config.LoadBalancerTimeout, config.ExternalDNSTimeout
+		if assign <= 0 {
+			assign = 60 * time.Second
+		}
+		if resolve <= 0 {
+			resolve = defaultExternalDNSTimeout
+		}
+		return assign + resolve
+	},
+	// Storage, workload and chaos tests wait for volumes, rollouts, scaling and recovery on their own timeouts
+	"pvc-access":         fixedTimeout(storageTestTimeout),
+	"pvc-rwx":            fixedTimeout(2*storageTestTimeout + 2*rwxVisibilityTimeout),
+	"pvc-expand":         fixedTimeout(storageTestTimeout + storageExpandTimeout),
+	"deployment-rollout": fixedTimeout(2 * rolloutTimeout),
+	"hpa-scaling":        fixedTimeout(hpaMetricsTimeout + hpaScaleTimeout),
+	"pod-churn":          fixedTimeout(2 * podChurnWaveTimeout),
+	"namespace-churn":    fixedTimeout(namespaceDeleteTimeout),
+	"readiness-shift":    fixedTimeout(2 * nodeIsolationTimeout),
+	"fault-latency":      fixedTimeout(2 * time.Minute),
+	"fault-loss":         fixedTimeout(2 * time.Minute),
+	"node-isolation":     fixedTimeout(nodeIsolationWatchdog + 2*nodeIsolationTimeout),
+	"dns-failure":        fixedTimeout(dnsFailureWatchdog),
+	"cni-restart":        fixedTimeout(cniRestartProbeDuration * time.Second),
+	"idle-timeout": func(config TestConfig) time.Duration {
+		// Idle periods run concurrently, so the longest one extends the test
+		var longest time.Duration
+		for _, idle := range config.IdleTimeouts {
+			if idle > longest {
+				longest = idle
+			}
+		}
+		return longest
+	},
+}
+
+// fixedTimeout is a test budget that does not depend on the configuration
+func fixedTimeout(timeout time.Duration) func(TestConfig) time.Duration {
+	return func(TestConfig) time.Duration { return timeout }
+}
+
+// TimedTest is implemented by plugin and probe tests that wait longer than defaultTestTimeout covers; Timeout is
+// their budget on top of it, like the entries of testTimeouts for the built-in tests
+type TimedTest interface {
+	Timeout() time.Duration
+}
+
+// TestTimeout returns the time budget of a test with the given configuration
+func TestTimeout(test Test, config TestConfig) time.Duration {
+	if extra, ok := testTimeouts[test.Name()]; ok {
+		return defaultTestTimeout + extra(config)
+	}
+	if timed, ok := test.(TimedTest); ok {
+		return defaultTestTimeout + timed.Timeout()
+	}
+	return defaultTestTimeout
+}
+
+// RunTimeout returns the time budget of a run of the tests: TestRunner runs them one at a time, so their budgets add up
+func RunTimeout(tests []Test, config TestConfig) time.Duration {
+	var total time.Duration
+	for _, test := range tests {
+		total += TestTimeout(test, config)
+	}
+	return total
+}
+
+// Runner runs tests against a cluster and returns one timed result per test, in the order the tests ran
+type Runner interface {
+	Run(ctx context.Context, tests []Test) []TimedTestResult
+}
+
+// TestRunner runs tests one at a time, prerequisites first. The events and network context of each test are
+// attached to its result, and the shared fixtures are released after the last test. Once ctx is cancelled the
+// remaining tests are reported as not run.
+type TestRunner struct {
+	Tester *Tester
+	Config TestConfig
+	// Dependencies maps a test name to the tests that must pass before it; nil runs the tests in the given order
+	// without skipping any
+	Dependencies map[string][]string
+	// BeforeTest is called before each test runs and AfterTest with its complete result; both are optional
+	BeforeTest func(test Test)
+	AfterTest  func(test Test, result *TimedTestResult)
+	// Reporter, when set, receives TestStarted, StepOutput, StepCompleted and TestFinished for each test, the latter
+	// after AfterTest
+	Reporter Reporter
+}
+
+// NewRunner returns a TestRunner for the tester that honors TestDependencies
+func NewRunner(tester *Tester, config TestConfig) *TestRunner {
+	return &TestRunner{Tester: tester, Config: config, Dependencies: TestDependencies}
+}
+
+// Run executes the tests and returns their results in the order they ran
+func (r *TestRunner) Run(ctx context.Context, tests []Test) []TimedTestResult {
+	var results []TimedTestResult
+	outcomes := map[string]TestResult{}
+	for i, test := range r.orderByDependencies(tests) {
+		number := i + 1
+		if r.BeforeTest != nil {
+			r.BeforeTest(test)
+		}
+		if r.Reporter != nil {
+			r.Reporter.TestStarted(ctx, number, test)
+		}
+
+		var result TimedTestResult
+		if ctx.Err() != nil {
+			now := time.Now()
+			result = TimedTestResult{
+				TestResult: TestResult{
+					Success: false,
+					Message: fmt.Sprintf("Not run - run cancelled: %v", ctx.Err()),
+					DetailedDiagnostics: &DetailedDiagnostics{
+						FailureStage:   "Cancelled",
+						TechnicalError: ctx.Err().Error(),
+						TroubleshootingHints: []string{
+							"The run timed out or was interrupted before this test started - run it on its own",
+						},
+					},
+				},
+				Name:      test.Name(),
+				StartTime: now,
+				EndTime:   now,
+			}
+		} else if dependency, dependencyResult, failed := r.failedDependency(test.Name(), outcomes); failed && dependencyResult.Skipped {
+			// The precondition the prerequisite missed, e.g. too few nodes, applies to this test as well
+			now := time.Now()
+			result = TimedTestResult{
+				TestResult: TestResult{
+					Success: true,
+					Skipped: true,
+					Message: fmt.Sprintf("Not run - prerequisite test %s skipped - %s", dependency, dependencyResult.SkipReason()),
+					Details: []string{fmt.Sprintf("ℹ️ %s: %s", dependency, dependencyResult.Message)},
+				},
+				Name:      test.Name(),
+				StartTime: now,
+				EndTime:   now,
+			}
+		} else if failed {
+			now := time.Now()
+			result = TimedTestResult{
+				TestResult: TestResult{
+					Success: false,
+					Message: fmt.Sprintf("Not run - prerequisite test %s failed", dependency),
+					Details: []string{fmt.Sprintf("✗ %s: %s", dependency, dependencyResult.Message)},
+					DetailedDiagnostics: &DetailedDiagnostics{
+						FailureStage:   "Prerequisite",
+						TechnicalError: dependencyResult.Message,
+						TroubleshootingHints: []string{
+							fmt.Sprintf("Fix the %s failure first - this test would fail with the same root cause", dependency),
+							"Run with --ignore-dependencies to run this test anyway",
+						},
+					},
+				},
+				Name:      test.Name(),
+				StartTime: now,
+				EndTime:   now,
+			}
+		} else {
+			// Pods and Services are deleted by the tests, so their addresses are recorded while the test runs
+			observer := r.Tester.ObserveNetwork(ctx)
+			var executed []CommandOutput
+			var transcript []string
+			var responses []HTTPResponse
+			record := func(command string) {
+				// Polling repeats the same request; the transcript keeps it once
+				if len(transcript) == 0 || transcript[len(transcript)-1] != command {
+					transcript = append(transcript, command)
+				}
+			}
+			r.Tester.reportSteps(stepHandlers{
+				output: func(description, line string) {
+					if r.Reporter != nil {
+						r.Reporter.StepOutput(ctx, test, description, line)
+					}
+				},
+				completed: func(step CommandOutput) {
+					executed = append(executed, step)
+					if step.ExitCode != 0 {
+						record(fmt.Sprintf("%s  # exit code %d", step.Command, step.ExitCode))
+					} else {
+						record(step.Command)
+					}
+					if r.Reporter != nil {
+						r.Reporter.StepCompleted(ctx, test, step)
+					}
+				},
+				command: record,
+				response: func(response HTTPResponse) {
+					responses = append(responses, response)
+				},
+			})
+			result.Name = test.Name()
+			result.StartTime = time.Now()
+			result.TestResult = test.Run(ctx, r.Tester, r.Config)
+			result.EndTime = time.Now()
+			r.Tester.reportSteps(stepHandlers{})
+			observer.Stop()
+			attachCommandOutputs(&result.TestResult, executed)
+			if len(transcript) > 0 || len(responses) > 0 {
+				if result.DetailedDiagnostics == nil {
+					result.DetailedDiagnostics = &DetailedDiagnostics{}
+				}
+				result.DetailedDiagnostics.KubectlCommands = append(result.DetailedDiagnostics.KubectlCommands, transcript...)
+				result.DetailedDiagnostics.HTTPResponses = append(result.DetailedDiagnostics.HTTPResponses, responses...)
+			}
+
+			// Events of the test's resources explain scheduling, image pull, CNI and probe failures
+			r.Tester.AttachEvents(ctx, &result.TestResult, result.StartTime)
+			r.Tester.AttachNetworkContext(ctx, &result.TestResult, observer)
+		}
+
+		if r.AfterTest != nil {
+			r.AfterTest(test, &result)
+		}
+		if r.Reporter != nil {
+			r.Reporter.TestFinished(ctx, number, test, &result)
+		}
+		outcomes[test.Name()] = result.TestResult
+		results = append(results, result)
+	}
+	r.Tester.ReleaseFixtures(ctx)
+	return results
+}
+
+// attachCommandOutputs adds the commands a test executed in its pods to its diagnostics, skipping those the test
+// already reported itself
+func attachCommandOutputs(result *TestResult, executed []CommandOutput) {
+	if len(executed) == 0 {
+		return
+	}
+	if result.DetailedDiagnostics == nil {
+		result.DetailedDiagnostics = &DetailedDiagnostics{}
+	}
+	reported := map[CommandOutput]bool{}
+	for _, output := range result.DetailedDiagnostics.CommandOutputs {
+		reported[output] = true
+	}
+	for _, output := range executed {
+		if !reported[output] {
+			result.DetailedDiagnostics.CommandOutputs = append(result.DetailedDiagnostics.CommandOutputs, output)
+		}
+	}
+}
+
+// orderByDependencies moves the selected prerequisites of a test before it, keeping the requested order otherwise
+func (r *TestRunner) orderByDependencies(tests []Test) []Test {
+	selected := map[string]Test{}
+	for _, test := range tests {
+		selected[test.Name()] = test
+	}
+	var ordered []Test
+	placed := map[string]bool{}
+	var place func(test Test)
+	place = func(test Test) {
+		if placed[test.Name()] {
+			return
+		}
+		placed[test.Name()] = true
+		for _, dependency := range r.Dependencies[test.Name()] {
+			if prerequisite, ok := selected[dependency]; ok {
+				place(prerequisite)
+			}
+		}
+		ordered = append(ordered, test)
+	}
+	for _, test := range tests {
+		place(test)
+	}
+	return ordered
+}
+
+// failedDependency returns the first prerequisite of a test that already ran and failed or was skipped in this run
+func (r *TestRunner) failedDependency(test string, outcomes map[string]TestResult) (string, TestResult, bool) {
+	for _, dependency := range r.Dependencies[test] {
+		if result, ran := outcomes[dependency]; ran && (!result.Success || result.Skipped) {
+			return dependency, result, true
+		}
+	}
+	return "", TestResult{}, false
+}
@@ -0,0 +1,277 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// schedulingTestImage is a minimal image so the measurement is dominated by scheduling and pod startup
+	schedulingTestImage = "registry.k8s.io/pause:3.9"
+	// schedulingPodsPerNode is the number of test pods pinned to each worker node
+	schedulingPodsPerNode = 3
+	// schedulingMaxNodes caps how many worker nodes get test pods on large clusters
+	schedulingMaxNodes = 10
+	// schedulingTimeout bounds how long the batch may take to become Ready
+	schedulingTimeout = 120 * time.Second
+	// schedulingSlowP90 and startupSlowP90 flag unusually slow scheduling and kubelet startup
+	schedulingSlowP90 = time.Second
+	startupSlowP90    = 10 * time.Second
+)
+
+// podStartTimes records when the watch observed a test pod being scheduled and becoming Ready
+type podStartTimes struct {
+	Node      string
+	Scheduled time.Duration
+	Ready     time.Duration
+}
+
+// createSchedulingPod creates a pause pod pinned to the node through node affinity, so it still goes through the
+// scheduler (nodeName would bypass it)
+func (t *Tester) createSchedulingPod(ctx context.Context, name, nodeName string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "scheduling-latency-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "pause",
+					Image: t.image(schedulingTestImage),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("1m"),
+							corev1.ResourceMemory: resource.MustParse("8Mi"),
+						},
+					},
+				},
+			},
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      "kubernetes.io/hostname",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{nodeName},
+							}},
+						}},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// podScheduled reports whether the pod's PodScheduled condition is true
+func podScheduled(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// TestSchedulingLatency creates a batch of pause pods on each worker node and measures the time from creation to
+// Scheduled and to Ready, reporting percentiles overall and per node. Scheduling latency reflects the scheduler,
+// the Scheduled-to-Ready time reflects the kubelet, image pull and CNI setup on the node.
+func (t *Tester) TestSchedulingLatency(ctx context.Context) TestResult {
+	var details []string
+	selector := "app=scheduling-latency-test"
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: selector})
+	}()
+
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(workerNodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "Scheduling latency test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+	if len(workerNodes) > schedulingMaxNodes {
+		details = append(details, fmt.Sprintf("ℹ️ Measuring the first %d of %d worker nodes", schedulingMaxNodes, len(workerNodes)))
+		workerNodes = workerNodes[:schedulingMaxNodes]
+	}
+
+	// Step 1: Watch the test pods before creating them so no transition is missed
+	watcher, err := t.clientset.CoreV1().Pods(t.namespace).Watch(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to watch test pods: %v", err),
+			Details: details,
+		}
+	}
+	defer watcher.Stop()
+
+	// Step 2: Create the batch, recording each pod's creation time
+	created := map[string]time.Time{}
+	times := map[string]*podStartTimes{}
+	for _, node := range workerNodes {
+		for i := 0; i < schedulingPodsPerNode; i++ {
+			name := fmt.Sprintf("scheduling-latency-%s-%d", node, i)
+			if len(name) > 63 {
+				name = fmt.Sprintf("scheduling-latency-%d-%d", len(created), i)
+			}
+			created[name] = time.Now()
+			if err := t.createSchedulingPod(ctx, name, node); err != nil {
+				return TestResult{
+					Success: false,
+					Message: fmt.Sprintf("Failed to create pod %s: %v", name, err),
+					Details: details,
+				}
+			}
+			times[name] = &podStartTimes{Node: node}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created %d pause pods across %d worker nodes", len(created), len(workerNodes)))
+
+	// Step 3: Record Scheduled and Ready as the watch reports them
+	readyCount := 0
+	timeout := time.After(schedulingTimeout)
+	for readyCount < len(created) {
+		var event watch.Event
+		var ok bool
+		select {
+		case event, ok = <-watcher.ResultChan():
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if !ok {
+			break
+		}
+		pod, isPod := event.Object.(*corev1.Pod)
+		if !isPod {
+			continue
+		}
+		entry, tracked := times[pod.Name]
+		if !tracked {
+			continue
+		}
+		if entry.Scheduled == 0 && podScheduled(pod) {
+			entry.Scheduled = time.Since(created[pod.Name])
+		}
+		if entry.Ready == 0 && isPodReady(pod) {
+			entry.Ready = time.Since(created[pod.Name])
+			if entry.Scheduled == 0 {
+				entry.Scheduled = entry.Ready
+			}
+			readyCount++
+		}
+	}
+
+	// Step 4: Percentiles overall and per node
+	var scheduled, startup []time.Duration
+	perNode := map[string][]time.Duration{}
+	var stuck []string
+	for name, entry := range times {
+		if entry.Ready == 0 {
+			state := "not scheduled"
+			if entry.Scheduled > 0 {
+				state = "scheduled but not ready"
+			}
+			stuck = append(stuck, fmt.Sprintf("%s on %s (%s)", name, entry.Node, state))
+			if entry.Scheduled > 0 {
+				scheduled = append(scheduled, entry.Scheduled)
+			}
+			continue
+		}
+		scheduled = append(scheduled, entry.Scheduled)
+		startup = append(startup, entry.Ready-entry.Scheduled)
+		perNode[entry.Node] = append(perNode[entry.Node], entry.Ready)
+	}
+	sort.Strings(stuck)
+
+	metrics := map[string]float64{}
+	for _, p := range []float64{50, 90, 99} {
+		metrics[fmt.Sprintf("scheduled_p%.0f_ms", p)] = float64(latencyPercentile(scheduled, p).Milliseconds())
+		metrics[fmt.Sprintf("startup_p%.0f_ms", p)] = float64(latencyPercentile(startup, p).Milliseconds())
+	}
+	details = append(details, fmt.Sprintf("  %-30s %10s %10s %10s", "PHASE", "P50", "P90", "P99"))
+	details = append(details, fmt.Sprintf("  %-30s %10v %10v %10v", "created -> scheduled",
+		latencyPercentile(scheduled, 50).Round(time.Millisecond), latencyPercentile(scheduled, 90).Round(time.Millisecond), latencyPercentile(scheduled, 99).Round(time.Millisecond)))
+	details = append(details, fmt.Sprintf("  %-30s %10v %10v %10v", "scheduled -> ready",
+		latencyPercentile(startup, 50).Round(time.Millisecond), latencyPercentile(startup, 90).Round(time.Millisecond), latencyPercentile(startup, 99).Round(time.Millisecond)))
+
+	details = append(details, fmt.Sprintf("  %-30s %10s %10s", "NODE (created -> ready)", "P50", "MAX"))
+	var nodeMedians []time.Duration
+	for _, node := range workerNodes {
+		if len(perNode[node]) == 0 {
+			continue
+		}
+		median := latencyPercentile(perNode[node], 50)
+		nodeMedians = append(nodeMedians, median)
+		metrics[fmt.Sprintf("ready_p50_ms.%s", node)] = float64(median.Milliseconds())
+		details = append(details, fmt.Sprintf("  %-30s %10v %10v", node, median.Round(time.Millisecond), latencyPercentile(perNode[node], 100).Round(time.Millisecond)))
+	}
+
+	if p90 := latencyPercentile(scheduled, 90); p90 > schedulingSlowP90 {
+		details = append(details, fmt.Sprintf("⚠️ Scheduling p90 is %v - the scheduler is slow or busy (check kube-scheduler logs and scheduler_pending_pods)", p90.Round(time.Millisecond)))
+	}
+	if p90 := latencyPercentile(startup, 90); p90 > startupSlowP90 {
+		details = append(details, fmt.Sprintf("⚠️ Scheduled-to-Ready p90 is %v - kubelet, image pull or CNI setup is slow, not the network", p90.Round(time.Millisecond)))
+	}
+	// A node far slower than the cluster median points at that node's kubelet, runtime or CNI agent
+	clusterMedian := latencyPercentile(nodeMedians, 50)
+	for _, node := range workerNodes {
+		if len(perNode[node]) == 0 {
+			continue
+		}
+		if median := latencyPercentile(perNode[node], 50); median > 2*clusterMedian && median-clusterMedian > 2*time.Second {
+			details = append(details, fmt.Sprintf("⚠️ %s starts pods in %v vs the cluster median %v", node, median.Round(time.Millisecond), clusterMedian.Round(time.Millisecond)))
+		}
+	}
+
+	if len(stuck) > 0 {
+		for _, pod := range stuck {
+			details = append(details, fmt.Sprintf("✗ %s", pod))
+		}
+		stage := "Pod Startup"
+		if len(scheduled) < len(created) {
+			stage = "Pod Scheduling"
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d test pods not Ready within %v", len(stuck), len(created), schedulingTimeout),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   stage,
+				TechnicalError: strings.Join(stuck, "; "),
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check why pods are pending: kubectl get pods -n %s -l %s -o wide", t.namespace, selector),
+					"Unscheduled pods point at the scheduler, taints or node resources - check the FailedScheduling events",
+					fmt.Sprintf("Scheduled pods that never become Ready point at image pulls (%s) or CNI sandbox setup on the node", schedulingTestImage),
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("%d pods scheduled (p90 %v) and Ready (p90 %v after scheduling) across %d nodes", len(created),
+			latencyPercentile(scheduled, 90).Round(time.Millisecond), latencyPercentile(startup, 90).Round(time.Millisecond), len(workerNodes)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
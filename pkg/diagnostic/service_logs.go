@@ -0,0 +1,121 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceLogWindow is how far back kube-proxy and CNI agent logs are read after a service test failure
+const serviceLogWindow = 10 * time.Minute
+
+// serviceLogExcerptLimit caps the number of log lines kept per agent pod in the report
+const serviceLogExcerptLimit = 40
+
+// agentErrorLinePattern matches klog error lines (kube-proxy, Calico, Antrea) and logfmt error lines (Cilium)
+var agentErrorLinePattern = regexp.MustCompile(`^E\d{4} |level=error|"level":"error"`)
+
+// filterServiceLogLines keeps log lines that mention the service by name or IP, and error lines, up to
+// serviceLogExcerptLimit
+func filterServiceLogLines(logs string, needles []string) []string {
+	var matched []string
+	for _, line := range strings.Split(logs, "\n") {
+		keep := agentErrorLinePattern.MatchString(line)
+		for _, needle := range needles {
+			if keep {
+				break
+			}
+			keep = needle != "" && strings.Contains(line, needle)
+		}
+		if keep {
+			matched = append(matched, line)
+		}
+		if len(matched) == serviceLogExcerptLimit {
+			break
+		}
+	}
+	return matched
+}
+
+// serviceDataplaneAgents returns the DaemonSets programming service rules: kube-proxy unless the CNI replaced it,
+// and the detected CNI agent
+func (t *Tester) serviceDataplaneAgents(ctx context.Context) []CNIInfo {
+	var agents []CNIInfo
+	if mode := t.DetectKubeProxyMode(ctx).Mode; mode != KubeProxyModeReplaced && mode != KubeProxyModeUnknown {
+		agents = append(agents, CNIInfo{Name: "kube-proxy", Namespace: "kube-system", DaemonSet: "kube-proxy"})
+	}
+	if cni := t.detectCNI(ctx); cni.Name != CNIUnknown {
+		agents = append(agents, cni)
+	}
+	return agents
+}
+
+// backendNodeNames returns the nodes running the pods behind a test deployment
+func (t *Tester) backendNodeNames(ctx context.Context, deploymentName string) []string {
+	pods, err := t.clientset.CoreV1().Pods(t.namespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", deploymentName)})
+	if err != nil {
+		return nil
+	}
+	var nodeNames []string
+	for _, pod := range pods.Items {
+		nodeNames = append(nodeNames, pod.Spec.NodeName)
+	}
+	return nodeNames
+}
+
+// attachServiceLogs adds the recent kube-proxy and CNI agent log lines mentioning the service, and their error
+// lines, from the given source and backend nodes to the diagnostics as command outputs
+func (t *Tester) attachServiceLogs(ctx context.Context, diagnostics *DetailedDiagnostics, details *[]string, serviceName, serviceIP string, nodeNames []string) {
+	needles := []string{serviceName, serviceIP}
+	for _, agent := range t.serviceDataplaneAgents(ctx) {
+		daemonSet, err := t.clientset.AppsV1().DaemonSets(agent.Namespace).Get(ctx, agent.DaemonSet, metav1.GetOptions{})
+		if err != nil {
+			*details = append(*details, fmt.Sprintf("⚠️ Could not read %s DaemonSet %s/%s: %v", agent.Name, agent.Namespace, agent.DaemonSet, err))
+			continue
+		}
+		container := daemonSet.Spec.Template.Spec.Containers[0].Name
+
+		seen := make(map[string]bool)
+		for _, nodeName := range nodeNames {
+			if nodeName == "" || seen[nodeName] {
+				continue
+			}
+			seen[nodeName] = true
+
+			pods, err := t.clientset.CoreV1().Pods(agent.Namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: metav1.FormatLabelSelector(daemonSet.Spec.Selector),
+				FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+			})
+			if err != nil || len(pods.Items) == 0 {
+				*details = append(*details, fmt.Sprintf("⚠️ No %s pod found on %s to read logs from", agent.Name, nodeName))
+				continue
+			}
+			podName := pods.Items[0].Name
+			output := CommandOutput{
+				Command: fmt.Sprintf("kubectl logs -n %s %s -c %s --since=%s | grep -E '%s|%s|^E[0-9]{4} |level=error'",
+					agent.Namespace, podName, container, serviceLogWindow, serviceName, serviceIP),
+				Description: fmt.Sprintf("%s log lines about %s and errors on %s", agent.Name, serviceName, nodeName),
+			}
+			logs, err := t.podLogs(ctx, agent.Namespace, podName, container, serviceLogWindow)
+			if err != nil {
+				output.ExitCode = -1
+				output.Stderr = err.Error()
+				diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+				*details = append(*details, fmt.Sprintf("⚠️ Could not read %s logs on %s: %v", agent.Name, nodeName, err))
+				continue
+			}
+			excerpt := filterServiceLogLines(string(logs), needles)
+			if len(excerpt) == 0 {
+				*details = append(*details, fmt.Sprintf("ℹ️ No %s log lines about %s or errors on %s (last %s)", agent.Name, serviceName, nodeName, serviceLogWindow))
+				continue
+			}
+			output.Stdout = strings.Join(excerpt, "\n")
+			diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+			*details = append(*details, fmt.Sprintf("ℹ️ Attached %d %s log lines from %s", len(excerpt), agent.Name, nodeName))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceRulesExcerptLimit caps the number of rule lines kept per node in the report
+const serviceRulesExcerptLimit = 60
+
+// createPrivilegedDebugPod creates a privileged hostNetwork netshoot pod on the node for reading its dataplane rules
+func (t *Tester) createPrivilegedDebugPod(ctx context.Context, name, nodeName string) (*corev1.Pod, error) {
+	privileged := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "netshoot-node-debug",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:    nodeName,
+			HostNetwork: true,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: &privileged,
+					},
+				},
+			},
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// podNodeName returns the node a pod in the test namespace is scheduled on, or "" if unknown
+func (t *Tester) podNodeName(ctx context.Context, podName string) string {
+	pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return ""
+	}
+	return pod.Spec.NodeName
+}
+
+// filterRuleLines keeps the lines containing any of the needles, up to serviceRulesExcerptLimit
+func filterRuleLines(output string, needles ...string) []string {
+	var matched []string
+	for _, line := range strings.Split(output, "\n") {
+		for _, needle := range needles {
+			if needle != "" && strings.Contains(line, needle) {
+				matched = append(matched, line)
+				break
+			}
+		}
+		if len(matched) == serviceRulesExcerptLimit {
+			break
+		}
+	}
+	return matched
+}
+
+// checkIPTablesServiceRules reports missing kube-proxy iptables rules for the service in an iptables-save excerpt
+func checkIPTablesServiceRules(excerpt []string, serviceIP string, nodePort int) []string {
+	var hasService, hasEndpoints, hasNodePort bool
+	for _, line := range excerpt {
+		switch {
+		case strings.Contains(line, "KUBE-SERVICES") && strings.Contains(line, serviceIP):
+			hasService = true
+		case strings.Contains(line, "-j KUBE-SEP-"):
+			hasEndpoints = true
+		}
+		if strings.Contains(line, "KUBE-NODEPORTS") && strings.Contains(line, fmt.Sprintf("--dport %d", nodePort)) {
+			hasNodePort = true
+		}
+	}
+	var missing []string
+	if !hasService {
+		missing = append(missing, fmt.Sprintf("no KUBE-SERVICES rule for %s", serviceIP))
+	}
+	if !hasEndpoints {
+		missing = append(missing, "no KUBE-SEP endpoint rules")
+	}
+	if nodePort > 0 && !hasNodePort {
+		missing = append(missing, fmt.Sprintf("no KUBE-NODEPORTS rule for port %d", nodePort))
+	}
+	return missing
+}
+
+// ipvsVirtualServers parses `ipvsadm -Ln` output into virtual server address -> real server count
+func ipvsVirtualServers(output string) map[string]int {
+	servers := make(map[string]int)
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case fields[0] == "TCP" || fields[0] == "UDP" || fields[0] == "SCTP":
+			current = fields[1]
+			servers[current] = 0
+		case fields[0] == "->" && current != "" && fields[1] != "RemoteAddress:Port":
+			servers[current]++
+		}
+	}
+	return servers
+}
+
+// checkIPVSServiceRules reports missing virtual servers or real servers for the service
+func checkIPVSServiceRules(servers map[string]int, serviceIP string, port, nodePort int) ([]string, []string) {
+	var excerpt, missing []string
+	clusterAddress := fmt.Sprintf("%s:%d", serviceIP, port)
+	if realServers, found := servers[clusterAddress]; !found {
+		missing = append(missing, fmt.Sprintf("no IPVS virtual server for %s", clusterAddress))
+	} else {
+		excerpt = append(excerpt, fmt.Sprintf("TCP %s -> %d real servers", clusterAddress, realServers))
+		if realServers == 0 {
+			missing = append(missing, fmt.Sprintf("IPVS virtual server %s has no real servers", clusterAddress))
+		}
+	}
+	if nodePort > 0 {
+		suffix := fmt.Sprintf(":%d", nodePort)
+		found := false
+		for address, realServers := range servers {
+			if strings.HasSuffix(address, suffix) {
+				found = true
+				excerpt = append(excerpt, fmt.Sprintf("TCP %s -> %d real servers", address, realServers))
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("no IPVS virtual server for NodePort %d", nodePort))
+		}
+	}
+	return excerpt, missing
+}
+
+// collectNodeServiceRules reads the rules programmed for the service on one node, returning the command output
+// (reduced to the relevant excerpt) and the rules found missing
+func (t *Tester) collectNodeServiceRules(ctx context.Context, mode, nodeName, serviceName, serviceIP string, port, nodePort int) (CommandOutput, []string, error) {
+	serviceComment := fmt.Sprintf("%s/%s", t.namespace, serviceName)
+
+	// Cilium replaces kube-proxy: the agent's service table is the source of truth
+	if mode == KubeProxyModeReplaced {
+		agentPod, err := t.getCiliumAgentPod(ctx, nodeName)
+		if err != nil {
+			return CommandOutput{}, nil, err
+		}
+		output, err := t.execCiliumCLI(ctx, agentPod, "service list", fmt.Sprintf("Cilium service table on %s", nodeName))
+		if err != nil {
+			return output, nil, err
+		}
+		clusterAddress := fmt.Sprintf("%s:%d", serviceIP, port)
+		nodePortNeedle := ""
+		if nodePort > 0 {
+			nodePortNeedle = fmt.Sprintf(":%d ", nodePort)
+		}
+		excerpt := filterRuleLines(output.Stdout, clusterAddress, nodePortNeedle)
+		output.Stdout = strings.Join(excerpt, "\n")
+		var missing []string
+		found := false
+		for _, line := range excerpt {
+			if strings.Contains(line, clusterAddress) {
+				found = true
+				if !strings.Contains(line, "=>") {
+					missing = append(missing, fmt.Sprintf("Cilium service %s has no backends", clusterAddress))
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("no Cilium service entry for %s", clusterAddress))
+		}
+		return output, missing, nil
+	}
+
+	debugPodName := fmt.Sprintf("node-debug-%s", nodeName)
+	if len(debugPodName) > 63 {
+		debugPodName = strings.TrimRight(debugPodName[:63], "-.")
+	}
+	if _, err := t.createPrivilegedDebugPod(ctx, debugPodName, nodeName); err != nil {
+		return CommandOutput{}, nil, fmt.Errorf("failed to create privileged debug pod: %v", err)
+	}
+	defer t.cleanupPod(ctx, debugPodName)
+	if err := t.waitForPodReady(ctx, debugPodName, 60*time.Second); err != nil {
+		return CommandOutput{}, nil, fmt.Errorf("privileged debug pod did not become ready: %v", err)
+	}
+
+	switch mode {
+	case KubeProxyModeIPVS:
+		output, err := t.execInPodWithOutput(ctx, t.namespace, debugPodName, "netshoot", []string{"ipvsadm", "-Ln"},
+			fmt.Sprintf("IPVS virtual servers on %s", nodeName))
+		if err != nil {
+			return output, nil, err
+		}
+		excerpt, missing := checkIPVSServiceRules(ipvsVirtualServers(output.Stdout), serviceIP, port, nodePort)
+		output.Stdout = strings.Join(excerpt, "\n")
+		return output, missing, nil
+	case KubeProxyModeNFTables:
+		output, err := t.execInPodWithOutput(ctx, t.namespace, debugPodName, "netshoot", []string{"nft", "list", "table", "ip", "kube-proxy"},
+			fmt.Sprintf("kube-proxy nftables rules on %s", nodeName))
+		if err != nil {
+			return output, nil, err
+		}
+		excerpt := filterRuleLines(output.Stdout, serviceIP, serviceComment)
+		output.Stdout = strings.Join(excerpt, "\n")
+		var missing []string
+		if len(filterRuleLines(output.Stdout, serviceIP)) == 0 {
+			missing = append(missing, fmt.Sprintf("no nftables rule for %s", serviceIP))
+		}
+		return output, missing, nil
+	}
+
+	// iptables, and the best guess when the mode is unknown
+	output, err := t.execInPodWithOutput(ctx, t.namespace, debugPodName, "netshoot", []string{"iptables-save", "-t", "nat"},
+		fmt.Sprintf("kube-proxy iptables NAT rules on %s", nodeName))
+	if err != nil {
+		return output, nil, err
+	}
+	excerpt := filterRuleLines(output.Stdout, serviceIP, serviceComment)
+	output.Stdout = strings.Join(excerpt, "\n")
+	return output, checkIPTablesServiceRules(excerpt, serviceIP, nodePort), nil
+}
+
+// attachServiceRules collects the rules programmed for the service on the given nodes and adds them to the
+// diagnostics as command outputs, flagging missing rules. It must run before the service is deleted.
+func (t *Tester) attachServiceRules(ctx context.Context, diagnostics *DetailedDiagnostics, details *[]string, serviceName, serviceIP string, port, nodePort int, nodeNames []string) {
+	mode := t.DetectKubeProxyMode(ctx).Mode
+	seen := make(map[string]bool)
+	for _, nodeName := range nodeNames {
+		if nodeName == "" || seen[nodeName] {
+			continue
+		}
+		seen[nodeName] = true
+
+		output, missing, err := t.collectNodeServiceRules(ctx, mode, nodeName, serviceName, serviceIP, port, nodePort)
+		if output.Command != "" {
+			diagnostics.CommandOutputs = append(diagnostics.CommandOutputs, output)
+		}
+		if err != nil {
+			*details = append(*details, fmt.Sprintf("⚠️ Could not read service rules on %s: %v", nodeName, err))
+			continue
+		}
+		if len(missing) == 0 {
+			*details = append(*details, fmt.Sprintf("ℹ️ Service rules for %s present on %s (%s)", serviceIP, nodeName, mode))
+			continue
+		}
+		for _, rule := range missing {
+			*details = append(*details, fmt.Sprintf("✗ %s: %s", nodeName, rule))
+			diagnostics.TroubleshootingHints = append(diagnostics.TroubleshootingHints,
+				fmt.Sprintf("Missing on %s: %s - check kube-proxy (or the CNI service datapath) on that node", nodeName, rule))
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package diagnostic
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tokenTestExpirationSeconds is the projected token lifetime, the minimum the API server accepts
+	tokenTestExpirationSeconds = 600
+	// tokenTestAudience is the audience of the projected token, distinct from the API server's
+	tokenTestAudience = "k8s-diagnostic"
+	// tokenTestMountPath holds the projected token with the custom audience
+	tokenTestMountPath = "/var/run/secrets/k8s-diagnostic"
+	// defaultTokenPath is where the kubelet mounts the API server token
+	defaultTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// serviceAccountClaims are the JWT claims of a service account token that the test inspects
+type serviceAccountClaims struct {
+	Issuer     string      `json:"iss"`
+	Audience   interface{} `json:"aud"`
+	Expiry     int64       `json:"exp"`
+	IssuedAt   int64       `json:"iat"`
+	Subject    string      `json:"sub"`
+	Kubernetes *struct {
+		Namespace string `json:"namespace"`
+		WarnAfter int64  `json:"warnafter"`
+		Pod       *struct {
+			Name string `json:"name"`
+		} `json:"pod"`
+	} `json:"kubernetes.io"`
+}
+
+// parseTokenClaims decodes the payload of a JWT without verifying it; the API server verifies it in TokenReview
+func parseTokenClaims(token string) (serviceAccountClaims, error) {
+	var claims serviceAccountClaims
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("token is not a JWT (%d segments)", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, fmt.Errorf("failed to decode token payload: %v", err)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("failed to parse token claims: %v", err)
+	}
+	return claims, nil
+}
+
+// boundToPod reports whether the token is a bound token tied to the named pod
+func (c serviceAccountClaims) boundToPod(podName string) bool {
+	return c.Kubernetes != nil && c.Kubernetes.Pod != nil && c.Kubernetes.Pod.Name == podName
+}
+
+// reviewToken asks the API server whether the token authenticates, returning the username it maps to
+func (t *Tester) reviewToken(ctx context.Context, token string, audiences []string) (bool, string, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}
+	result, err := t.clientset.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("TokenReview failed: %v", err)
+	}
+	return result.Status.Authenticated, result.Status.User.Username, nil
+}
+
+// createTokenTestPod creates a netshoot pod running as the service account, with the default API token and a
+// projected token for tokenTestAudience
+func (t *Tester) createTokenTestPod(ctx context.Context, name, serviceAccount string) error {
+	expiration := int64(tokenTestExpirationSeconds)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "netshoot-token-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serviceAccount,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "audience-token", MountPath: tokenTestMountPath, ReadOnly: true},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "audience-token",
+					VolumeSource: corev1.VolumeSource{
+						Projected: &corev1.ProjectedVolumeSource{
+							Sources: []corev1.VolumeProjection{{
+								ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+									Audience:          tokenTestAudience,
+									ExpirationSeconds: &expiration,
+									Path:              "token",
+								},
+							}},
+						},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// TestServiceAccountTokens validates bound service account tokens end to end: the pod's projected tokens are bound
+// to the pod and expire, authenticate to the API server from the pod, the TokenRequest API issues working tokens,
+// the token files are refreshed in place by the kubelet, and tokens bound to a pod stop working once it is deleted
+func (t *Tester) TestServiceAccountTokens(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+	serviceAccount := "token-test-sa"
+	podName := "netshoot-token-test"
+	username := fmt.Sprintf("system:serviceaccount:%s:%s", t.namespace, serviceAccount)
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().ServiceAccounts(t.namespace).Delete(ctx, serviceAccount, metav1.DeleteOptions{})
+	}
+	fail := func(stage, message, technicalError string, hints ...string) TestResult {
+		cleanup()
+		details = append(details, fmt.Sprintf("✗ %s", message))
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       technicalError,
+				CommandOutputs:       commandOutputs,
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+
+	// Step 1: Service account and a pod using it
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: serviceAccount, Namespace: t.namespace}}
+	if _, err := t.clientset.CoreV1().ServiceAccounts(t.namespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+		return fail("Setup", fmt.Sprintf("Failed to create service account %s", serviceAccount), err.Error())
+	}
+	if err := t.createTokenTestPod(ctx, podName, serviceAccount); err != nil {
+		return fail("Setup", fmt.Sprintf("Failed to create pod %s", podName), err.Error())
+	}
+	if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+		return fail("Token Mount", fmt.Sprintf("Pod %s using projected tokens did not become ready", podName), err.Error(),
+			fmt.Sprintf("Check the pod events for FailedMount of the token volume: kubectl describe pod %s -n %s", podName, t.namespace),
+			"Projected service account tokens need the API server flags --service-account-issuer and --service-account-signing-key-file")
+	}
+	details = append(details, fmt.Sprintf("✓ Pod '%s' running as service account '%s'", podName, serviceAccount))
+
+	// Step 2: Both mounted tokens must be bound to the pod and expire
+	for _, tokenFile := range []struct{ path, audience string }{
+		{defaultTokenPath, "API server"},
+		{tokenTestMountPath + "/token", tokenTestAudience},
+	} {
+		output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"cat", tokenFile.path},
+			fmt.Sprintf("Read the %s token", tokenFile.audience))
+		if err != nil {
+			return fail("Token Mount", fmt.Sprintf("Failed to read %s", tokenFile.path), err.Error())
+		}
+		claims, err := parseTokenClaims(output.Stdout)
+		if err != nil {
+			return fail("Token Format", fmt.Sprintf("Token at %s is not a valid JWT", tokenFile.path), err.Error())
+		}
+		if claims.Expiry == 0 || !claims.boundToPod(podName) {
+			return fail("Bound Token", fmt.Sprintf("Token at %s is a legacy non-expiring token, not bound to the pod", tokenFile.path),
+				fmt.Sprintf("exp=%d, bound to pod=%t", claims.Expiry, claims.boundToPod(podName)),
+				"Bound tokens replace Secret-based tokens since Kubernetes 1.22 - check the API server service account flags and admission plugins",
+				"Workloads reading the token once at startup break when the token expires - client libraries must re-read the file")
+		}
+		lifetime := time.Duration(claims.Expiry-claims.IssuedAt) * time.Second
+		details = append(details, fmt.Sprintf("✓ %s token bound to pod %s, issued by %s, valid for %v", tokenFile.audience, podName, claims.Issuer, lifetime))
+		if claims.Kubernetes.WarnAfter > 0 {
+			details = append(details, fmt.Sprintf("ℹ️ API server token is extended for legacy clients; usage is warned about after %s",
+				time.Unix(claims.Kubernetes.WarnAfter, 0).UTC().Format(time.RFC3339)))
+		}
+	}
+
+	// Step 3: The pod authenticates to the API server with its token
+	script := fmt.Sprintf(`curl -sk -o /dev/null -w '%%{http_code}' --max-time 10 -H "Authorization: Bearer $(cat %s)" https://kubernetes.default.svc/api`, defaultTokenPath)
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"sh", "-c", script},
+		"Authenticate to the API server with the pod's token")
+	commandOutputs = append(commandOutputs, output)
+	if status := strings.TrimSpace(output.Stdout); status != "200" {
+		return fail("In-Pod Authentication", fmt.Sprintf("Pod token rejected by the API server (HTTP %s)", status), output.Stderr,
+			"HTTP 401 means the API server cannot verify the token - check that --service-account-key-file matches the signing key",
+			"HTTP 000 means the pod cannot reach kubernetes.default.svc - run the service connectivity tests")
+	}
+	details = append(details, "✓ Pod authenticated to the API server with its bound token")
+
+	// Step 4: TokenRequest issues fresh tokens, and each one authenticates
+	pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return fail("TokenRequest", fmt.Sprintf("Failed to read pod %s", podName), err.Error())
+	}
+	expiration := int64(tokenTestExpirationSeconds)
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		request := &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: &expiration,
+				BoundObjectRef:    &authenticationv1.BoundObjectReference{Kind: "Pod", APIVersion: "v1", Name: pod.Name, UID: pod.UID},
+			},
+		}
+		issued, err := t.clientset.CoreV1().ServiceAccounts(t.namespace).CreateToken(ctx, serviceAccount, request, metav1.CreateOptions{})
+		if err != nil {
+			return fail("TokenRequest", "TokenRequest API failed", err.Error(),
+				"The TokenRequest API needs --service-account-issuer and --service-account-signing-key-file on the API server")
+		}
+		tokens = append(tokens, issued.Status.Token)
+	}
+	if tokens[0] == tokens[1] {
+		return fail("TokenRequest", "TokenRequest returned the same token twice", "rotated tokens are identical")
+	}
+	for _, token := range tokens {
+		authenticated, user, err := t.reviewToken(ctx, token, nil)
+		if err != nil || !authenticated || user != username {
+			technicalError := fmt.Sprintf("authenticated=%t user=%q", authenticated, user)
+			if err != nil {
+				technicalError = err.Error()
+			}
+			return fail("TokenReview", "Token issued by TokenRequest does not authenticate", technicalError)
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ TokenRequest issued distinct pod-bound tokens that authenticate as %s", username))
+
+	// Step 5: The kubelet writes projected tokens through an atomic ..data symlink, which is what lets it refresh
+	// them in place; applications must re-read the file rather than cache it
+	output, err = t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"sh", "-c", fmt.Sprintf("readlink %s/..data && readlink %s/token", tokenTestMountPath, tokenTestMountPath)},
+		"Check the projected token is refreshable in place")
+	commandOutputs = append(commandOutputs, output)
+	if err != nil || !strings.Contains(output.Stdout, "..data") {
+		return fail("Token Rotation", "Projected token is not written through the kubelet's atomic ..data symlink", output.Stdout+output.Stderr,
+			"The kubelet refreshes projected tokens after 80% of their lifetime by swapping the ..data symlink - a plain file will not be refreshed")
+	}
+	details = append(details, fmt.Sprintf("✓ Projected token refreshable in place (kubelet refreshes after %ds)", tokenTestExpirationSeconds*8/10))
+
+	// Step 6: Tokens bound to the pod must stop working once the pod is deleted
+	gracePeriod := int64(0)
+	t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+	invalidated := false
+	for start := time.Now(); time.Since(start) < 30*time.Second && ctx.Err() == nil; sleepContext(ctx, 2*time.Second) {
+		if _, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{}); err == nil {
+			continue
+		}
+		if authenticated, _, err := t.reviewToken(ctx, tokens[0], nil); err == nil && !authenticated {
+			invalidated = true
+			break
+		}
+	}
+	if !invalidated {
+		return fail("Token Invalidation", "Token bound to a deleted pod still authenticates", "bound token accepted after pod deletion",
+			"Bound token invalidation needs the API server to look up the bound object - check that service account lookup (--service-account-lookup) is enabled")
+	}
+	details = append(details, "✓ Token bound to the deleted pod no longer authenticates")
+
+	cleanup()
+	details = append(details, "✓ Cleaned up token test pod and service account")
+	return TestResult{
+		Success: true,
+		Message: "Bound service account tokens are mounted, authenticate, are issued by TokenRequest and invalidated with their pod",
+		Details: details,
+	}
+}
@@ -0,0 +1,281 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// snatEchoPort is the host port of the echo server that reports the observed source address
+	snatEchoPort = 39123
+	// Source address kinds observed by the echo server
+	snatSourcePod   = "pod IP (no SNAT)"
+	snatSourceNode  = "client node IP (masqueraded)"
+	snatSourceOther = "other address"
+)
+
+// cidrPattern finds CIDRs in free-form configuration such as the ip-masq-agent config
+var cidrPattern = regexp.MustCompile(`[0-9a-fA-F:.]+/\d{1,3}`)
+
+// createSourceEchoPod runs a listener in the node's network namespace that answers each connection with the
+// source address it observed, so a pod can see how its traffic leaves the pod network
+func (t *Tester) createSourceEchoPod(ctx context.Context, name, nodeName string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "snat-source-echo",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:    nodeName,
+			HostNetwork: true,
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"socat",
+						fmt.Sprintf("TCP-LISTEN:%d,fork,reuseaddr", snatEchoPort),
+						"SYSTEM:echo source=$SOCAT_PEERADDR",
+					},
+				},
+			},
+			Tolerations: []corev1.Toleration{
+				{
+					Operator: corev1.TolerationOpExists,
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	return t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+}
+
+// cidrsContain reports whether ip falls in any of the CIDRs
+func cidrsContain(cidrs []string, ip string) bool {
+	address := net.ParseIP(ip)
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(strings.TrimSpace(cidr)); err == nil && address != nil && network.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedPodSource derives from the masquerade configuration whether traffic from clientPodIP to destination
+// should leave with the pod IP or the node IP. It returns "" when the configuration does not determine it.
+func (t *Tester) expectedPodSource(ctx context.Context, cni CNIInfo, clientPodIP, destination string) (string, string) {
+	// ip-masq-agent takes precedence where deployed: everything outside nonMasqueradeCIDRs is masqueraded
+	if configMap, err := t.clientset.CoreV1().ConfigMaps("kube-system").Get(ctx, "ip-masq-agent", metav1.GetOptions{}); err == nil {
+		cidrs := cidrPattern.FindAllString(configMap.Data["config"], -1)
+		if cidrsContain(cidrs, destination) {
+			return snatSourcePod, fmt.Sprintf("ip-masq-agent lists %s in nonMasqueradeCIDRs", destination)
+		}
+		return snatSourceNode, fmt.Sprintf("ip-masq-agent masquerades %s (not in nonMasqueradeCIDRs %s)", destination, strings.Join(cidrs, ", "))
+	}
+
+	switch cni.Name {
+	case CNICilium:
+		config, err := t.getCiliumConfig(ctx)
+		if err != nil {
+			return "", ""
+		}
+		if config["enable-ipv4-masquerade"] == "false" {
+			return snatSourcePod, "cilium-config enable-ipv4-masquerade=false"
+		}
+		nativeCIDR := config["ipv4-native-routing-cidr"]
+		if nativeCIDR == "" {
+			nativeCIDR = config["native-routing-cidr"]
+		}
+		if nativeCIDR != "" && cidrsContain([]string{nativeCIDR}, destination) {
+			return snatSourcePod, fmt.Sprintf("cilium-config ipv4-native-routing-cidr %s contains %s", nativeCIDR, destination)
+		}
+	case CNICalico:
+		pools, err := t.listCalicoIPPools(ctx)
+		if err != nil {
+			return "", ""
+		}
+		for _, pool := range pools {
+			if !cidrsContain([]string{pool.CIDR}, clientPodIP) {
+				continue
+			}
+			if pool.NATOutgoing {
+				return snatSourceNode, fmt.Sprintf("Calico IPPool %s has natOutgoing enabled", pool.Name)
+			}
+			return snatSourcePod, fmt.Sprintf("Calico IPPool %s has natOutgoing disabled", pool.Name)
+		}
+	case CNIFlannel:
+		daemonSet, err := t.clientset.AppsV1().DaemonSets(cni.Namespace).Get(ctx, cni.DaemonSet, metav1.GetOptions{})
+		if err != nil {
+			return "", ""
+		}
+		for _, container := range daemonSet.Spec.Template.Spec.Containers {
+			for _, arg := range append(container.Command, container.Args...) {
+				if arg == "--ip-masq" || arg == "--ip-masq=true" {
+					return snatSourceNode, "flannel runs with --ip-masq"
+				}
+			}
+		}
+		return snatSourcePod, "flannel runs without --ip-masq"
+	case CNIAWSVPC:
+		return snatSourcePod, "AWS VPC CNI only SNATs traffic leaving the VPC, and node addresses are inside it"
+	}
+	return "", ""
+}
+
+// TestSNATMasquerade has a pod connect to a listener on another node's address, outside the pod network, which
+// reports the source address it observed. The observed source - pod IP, node IP or another address such as an egress
+// gateway - is compared with what the CNI or ip-masq-agent masquerade configuration implies.
+func (t *Tester) TestSNATMasquerade(ctx context.Context) TestResult {
+	var details []string
+	clientPodName := "netshoot-snat-client"
+	echoPodName := "snat-source-echo"
+	defer t.cleanupPods(ctx, clientPodName, echoPodName)
+
+	// Step 1: Client pod and a host-network echo server, on different worker nodes when possible
+	nodes, err := t.getWorkerNodes(ctx)
+	if err != nil || len(nodes) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "SNAT test requires at least 1 worker node",
+			Details: details,
+		}
+	}
+	clientNode, echoNode := nodes[0], nodes[0]
+	if len(nodes) > 1 {
+		echoNode = nodes[1]
+	}
+	if _, err := t.createNetshootPod(ctx, clientPodName, clientNode); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create client pod %s: %v", clientPodName, err),
+			Details: details,
+		}
+	}
+	if _, err := t.createSourceEchoPod(ctx, echoPodName, echoNode); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create echo pod %s: %v", echoPodName, err),
+			Details: details,
+		}
+	}
+	for _, pod := range []string{clientPodName, echoPodName} {
+		if err := t.waitForPodReady(ctx, pod, 60*time.Second); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Pod %s not ready: %v", pod, err),
+				Details: details,
+			}
+		}
+	}
+	client, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, clientPodName, metav1.GetOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read client pod %s: %v", clientPodName, err),
+			Details: details,
+		}
+	}
+	echo, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, echoPodName, metav1.GetOptions{})
+	if err != nil || echo.Status.PodIP == "" {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Echo pod %s has no node address", echoPodName),
+			Details: details,
+		}
+	}
+	destination := echo.Status.PodIP
+	clientNodeAddresses := map[string]bool{}
+	if node, err := t.clientset.CoreV1().Nodes().Get(ctx, clientNode, metav1.GetOptions{}); err == nil {
+		for _, address := range node.Status.Addresses {
+			clientNodeAddresses[address.Address] = true
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Client pod %s on %s, echo server on %s at %s:%d", client.Status.PodIP, clientNode, echoNode, destination, snatEchoPort))
+	if clientNode == echoNode {
+		details = append(details, "ℹ️ Only one worker node - traffic to the local node address is usually not masqueraded, so the expectation is not checked")
+	}
+
+	// Step 2: Connect and read the source address the echo server observed
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, clientPodName, "netshoot",
+		[]string{"sh", "-c", fmt.Sprintf("nc -w 3 %s %d < /dev/null", destination, snatEchoPort)},
+		fmt.Sprintf("Connect to the source echo server on %s", echoNode))
+	observed := ""
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "source="); ok {
+			observed = value
+		}
+	}
+	if observed == "" {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("The pod could not reach the echo server on node address %s:%d", destination, snatEchoPort),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Pod to Node Connection",
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					fmt.Sprintf("Check host firewalls or security groups allow TCP %d between nodes", snatEchoPort),
+					"Check pods can reach node addresses at all: the CNI may drop pod-to-host traffic by policy",
+				},
+			},
+		}
+	}
+	kind := snatSourceOther
+	switch {
+	case observed == client.Status.PodIP:
+		kind = snatSourcePod
+	case clientNodeAddresses[observed]:
+		kind = snatSourceNode
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Observed source %s: %s", observed, kind))
+	if kind == snatSourceOther {
+		details = append(details, "⚠️ The source is neither the pod nor its node - an egress gateway, SNAT pool or overlay address rewrote it")
+	}
+
+	// Step 3: Compare with the masquerade configuration
+	cni := t.detectCNI(ctx)
+	expected, reason := t.expectedPodSource(ctx, cni, client.Status.PodIP, destination)
+	if expected == "" || clientNode == echoNode {
+		return TestResult{
+			Success: true,
+			Message: fmt.Sprintf("Pod traffic to node addresses leaves as %s (%s); expectation not determined from the %s configuration", observed, kind, cni.Name),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("ℹ️ Expected %s: %s", expected, reason))
+	if kind != expected {
+		details = append(details, fmt.Sprintf("✗ Observed %s, expected %s", kind, expected))
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Masquerading does not match the configuration: observed %s (%s), expected %s", observed, kind, expected),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "SNAT/Masquerade",
+				TechnicalError: fmt.Sprintf("source %s seen by %s, expected %s because %s", observed, destination, expected, reason),
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					"Unexpected masquerading hides pod identities from external firewalls and logs; missing masquerading breaks return traffic where the network cannot route pod CIDRs",
+					"Check ip-masq-agent nonMasqueradeCIDRs, Cilium enable-ipv4-masquerade/ipv4-native-routing-cidr or Calico IPPool natOutgoing",
+					"Check for egress gateway policies selecting the test namespace",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Masquerading matches the configuration (%s)", reason))
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Pod traffic to node addresses leaves as %s, as configured", kind),
+		Details: details,
+	}
+}
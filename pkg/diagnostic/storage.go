@@ -0,0 +1,380 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// storageTestSize is the capacity requested by the storage test PVCs
+	storageTestSize = "1Gi"
+	// storageTestTimeout bounds binding, attach and mount of a test volume
+	storageTestTimeout = 150 * time.Second
+	// storageTestMountPath is where test volumes are mounted in the test pods
+	storageTestMountPath = "/data"
+)
+
+// defaultStorageClassAnnotations mark the default StorageClass (GA and the older beta annotation)
+var defaultStorageClassAnnotations = []string{
+	"storageclass.kubernetes.io/is-default-class",
+	"storageclass.beta.kubernetes.io/is-default-class",
+}
+
+// resolveStorageClass returns the named StorageClass, or the cluster default when name is empty
+func (t *Tester) resolveStorageClass(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	if name != "" {
+		storageClass, err := t.clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("StorageClass %s not found: %v", name, err)
+		}
+		return storageClass, nil
+	}
+	storageClasses, err := t.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StorageClasses: %v", err)
+	}
+	for i, storageClass := range storageClasses.Items {
+		for _, annotation := range defaultStorageClassAnnotations {
+			if storageClass.Annotations[annotation] == "true" {
+				return &storageClasses.Items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no default StorageClass found among %d StorageClasses - set one or use --storage-class", len(storageClasses.Items))
+}
+
+// waitsForFirstConsumer reports whether volumes of the StorageClass are only bound once a pod is scheduled
+func waitsForFirstConsumer(storageClass *storagev1.StorageClass) bool {
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer
+}
+
+// createTestPVC creates a PVC of storageTestSize with the given access mode and StorageClass
+func (t *Tester) createTestPVC(ctx context.Context, name, storageClass string, accessMode corev1.PersistentVolumeAccessMode) error {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{accessMode},
+			StorageClassName: &storageClass,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(storageTestSize),
+				},
+			},
+		},
+	}
+	_, err := t.clientset.CoreV1().PersistentVolumeClaims(t.namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	return err
+}
+
+// createVolumePod creates a netshoot pod mounting the PVC at storageTestMountPath. The node is pinned through
+// node affinity rather than nodeName so the scheduler still triggers WaitForFirstConsumer provisioning.
+func (t *Tester) createVolumePod(ctx context.Context, name, pvcName, nodeName string) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels: map[string]string{
+				"app": "netshoot-storage-test",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "netshoot",
+					Image: t.image(netshootImage),
+					Command: []string{
+						"sleep",
+						"3600",
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "data", MountPath: storageTestMountPath},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+					},
+				},
+			},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+	if nodeName != "" {
+		pod.Spec.Affinity = &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key:      "kubernetes.io/hostname",
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{nodeName},
+						}},
+					}},
+				},
+			},
+		}
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// cleanupStorageResources deletes the test pods and then the PVCs, whose volumes are released by the provisioner
+func (t *Tester) cleanupStorageResources(ctx context.Context, podNames, pvcNames []string) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	for _, podName := range podNames {
+		t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, podName, metav1.DeleteOptions{})
+	}
+	for _, pvcName := range pvcNames {
+		t.clientset.CoreV1().PersistentVolumeClaims(t.namespace).Delete(ctx, pvcName, metav1.DeleteOptions{})
+	}
+}
+
+// volumeStages records when a test volume reached each stage, measured from the PVC creation
+type volumeStages struct {
+	Bound    time.Duration // PVC bound to a PV
+	Attached time.Duration // VolumeAttachment reported attached, 0 for drivers without attach
+	Ready    time.Duration // pod Ready, i.e. the volume is mounted
+	PVName   string
+	Node     string
+	// AttachSeen is set once a VolumeAttachment exists for the volume; drivers without attach never create one
+	AttachSeen bool
+}
+
+// failedStage names the first stage the volume did not reach
+func (s volumeStages) failedStage() string {
+	switch {
+	case s.Bound == 0:
+		return "PVC Binding"
+	case s.AttachSeen && s.Attached == 0:
+		return "Volume Attach"
+	}
+	return "Volume Mount"
+}
+
+// volumeAttached reports whether a VolumeAttachment for the PV on the node is attached, and whether one exists
+func (t *Tester) volumeAttached(ctx context.Context, pvName, nodeName string) (bool, bool) {
+	attachments, err := t.clientset.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, false
+	}
+	for _, attachment := range attachments.Items {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source != nil && *source == pvName && attachment.Spec.NodeName == nodeName {
+			return attachment.Status.Attached, true
+		}
+	}
+	return false, false
+}
+
+// waitForVolumeStages polls the PVC, its VolumeAttachment and the pod until the pod is Ready, recording when
+// each stage was reached. On timeout the returned error names the pod or PVC state.
+func (t *Tester) waitForVolumeStages(ctx context.Context, pvcName, podName string, created time.Time) (volumeStages, error) {
+	var stages volumeStages
+	deadline := time.Now().Add(storageTestTimeout)
+	for time.Now().Before(deadline) {
+		if stages.Bound == 0 {
+			pvc, err := t.clientset.CoreV1().PersistentVolumeClaims(t.namespace).Get(ctx, pvcName, metav1.GetOptions{})
+			if err == nil && pvc.Status.Phase == corev1.ClaimBound {
+				stages.Bound = time.Since(created)
+				stages.PVName = pvc.Spec.VolumeName
+			}
+		}
+		pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err == nil {
+			stages.Node = pod.Spec.NodeName
+			if stages.Bound > 0 && stages.Attached == 0 && stages.Node != "" {
+				attached, exists := t.volumeAttached(ctx, stages.PVName, stages.Node)
+				stages.AttachSeen = stages.AttachSeen || exists
+				if attached {
+					stages.Attached = time.Since(created)
+				}
+			}
+			if isPodReady(pod) {
+				stages.Ready = time.Since(created)
+				return stages, nil
+			}
+			if pod.Status.Phase == corev1.PodFailed {
+				return stages, fmt.Errorf("pod %s failed: %s", podName, getPodFailureReason(pod))
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return stages, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return stages, fmt.Errorf("timed out after %v and pod %s could not be read: %v", storageTestTimeout, podName, err)
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			return stages, fmt.Errorf("timed out after %v, pod %s unschedulable: %s", storageTestTimeout, podName, condition.Message)
+		}
+	}
+	return stages, fmt.Errorf("timed out after %v, pod %s: %s", storageTestTimeout, podName, getPodFailureReason(pod))
+}
+
+// storageFailureHints returns troubleshooting hints for the stage a test volume failed at
+func storageFailureHints(stage, storageClass, pvcName, podName, namespace string) []string {
+	switch stage {
+	case "PVC Binding":
+		return []string{
+			fmt.Sprintf("Check the PVC events for provisioning errors: kubectl describe pvc %s -n %s", pvcName, namespace),
+			fmt.Sprintf("Check the provisioner of StorageClass %s is running: kubectl get storageclass %s -o yaml", storageClass, storageClass),
+			"CSI provisioning errors appear in the csi-provisioner sidecar logs of the driver's controller pod",
+		}
+	case "Volume Attach":
+		return []string{
+			"Check the VolumeAttachment status: kubectl get volumeattachments",
+			"Attach errors appear in the csi-attacher sidecar logs of the driver's controller pod",
+			"A volume still attached to another node (e.g. after a node failure) blocks ReadWriteOnce attachment",
+		}
+	}
+	return []string{
+		fmt.Sprintf("Check the pod events for FailedMount: kubectl describe pod %s -n %s", podName, namespace),
+		"Check the CSI node plugin pod on the pod's node is running: kubectl get csinodes",
+		"Mount errors appear in the kubelet log and the node-driver-registrar/CSI node plugin logs",
+	}
+}
+
+// TestPVCBindingAndMount creates a PVC against the default (or configured) StorageClass, mounts it in a pod, writes
+// and reads back data, and reports binding, attach and mount latency with the stage any failure occurred at
+func (t *Tester) TestPVCBindingAndMount(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	pvcName := "pvc-access-test"
+	podName := "netshoot-pvc-access-test"
+
+	storageClass, err := t.resolveStorageClass(ctx, config.StorageClass)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "StorageClass Discovery",
+				TechnicalError: err.Error(),
+				TroubleshootingHints: []string{
+					"List StorageClasses: kubectl get storageclass",
+					"Mark one as default: kubectl annotate storageclass <name> storageclass.kubernetes.io/is-default-class=true",
+				},
+			},
+		}
+	}
+	bindingMode := storagev1.VolumeBindingImmediate
+	if waitsForFirstConsumer(storageClass) {
+		bindingMode = storagev1.VolumeBindingWaitForFirstConsumer
+	}
+	details = append(details, fmt.Sprintf("✓ Using StorageClass %s (provisioner %s, binding mode %s)", storageClass.Name, storageClass.Provisioner, bindingMode))
+
+	// Step 1: Create the PVC and the pod mounting it
+	created := time.Now()
+	if err := t.createTestPVC(ctx, pvcName, storageClass.Name, corev1.ReadWriteOnce); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create PVC %s: %v", pvcName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created PVC '%s' (%s, ReadWriteOnce)", pvcName, storageTestSize))
+	if err := t.createVolumePod(ctx, podName, pvcName, ""); err != nil {
+		t.cleanupStorageResources(ctx, nil, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create pod %s: %v", podName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created pod '%s' mounting the PVC at %s", podName, storageTestMountPath))
+
+	// Step 2: Wait for binding, attach and mount
+	metrics := map[string]float64{}
+	stages, err := t.waitForVolumeStages(ctx, pvcName, podName, created)
+	if stages.Bound > 0 {
+		metrics["bind_ms"] = float64(stages.Bound.Milliseconds())
+		details = append(details, fmt.Sprintf("✓ PVC bound to %s after %v", stages.PVName, stages.Bound.Round(time.Millisecond)))
+	}
+	if stages.Attached > 0 {
+		metrics["attach_ms"] = float64((stages.Attached - stages.Bound).Milliseconds())
+		details = append(details, fmt.Sprintf("✓ Volume attached to %s after %v", stages.Node, (stages.Attached-stages.Bound).Round(time.Millisecond)))
+	}
+	if err != nil {
+		stage := stages.failedStage()
+		details = append(details, fmt.Sprintf("✗ %s failed: %v", stage, err))
+		t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%s failed for StorageClass %s", stage, storageClass.Name),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       err.Error(),
+				TroubleshootingHints: storageFailureHints(stage, storageClass.Name, pvcName, podName, t.namespace),
+			},
+		}
+	}
+	mountStart := stages.Bound
+	if stages.Attached > 0 {
+		mountStart = stages.Attached
+	} else {
+		details = append(details, "ℹ️ No VolumeAttachment seen - the driver mounts without a separate attach step")
+	}
+	metrics["mount_ms"] = float64((stages.Ready - mountStart).Milliseconds())
+	metrics["ready_ms"] = float64(stages.Ready.Milliseconds())
+	details = append(details, fmt.Sprintf("✓ Pod ready with the volume mounted on %s after %v", stages.Node, stages.Ready.Round(time.Millisecond)))
+
+	// Step 3: Write and read back through the mount
+	token := fmt.Sprintf("k8s-diagnostic-%d", time.Now().UnixNano())
+	file := storageTestMountPath + "/k8s-diagnostic-test"
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"sh", "-c", fmt.Sprintf("echo %s > %s && sync && cat %s", token, file, file)},
+		"Write and read back a file on the test volume")
+	t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+	if err != nil || strings.TrimSpace(output.Stdout) != token {
+		technicalError := strings.TrimSpace(output.Stderr)
+		if err != nil {
+			technicalError = err.Error()
+		}
+		details = append(details, fmt.Sprintf("✗ Write/read on %s failed: %s", storageTestMountPath, technicalError))
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Volume from StorageClass %s mounted but write/read failed", storageClass.Name),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Volume Write/Read",
+				TechnicalError: technicalError,
+				CommandOutputs: []CommandOutput{output},
+				TroubleshootingHints: []string{
+					"A read-only mount or a full volume points at the driver or the backing storage",
+					"Permission denied usually means the volume's ownership does not match the pod's user - check fsGroup support of the driver",
+				},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Wrote and read back data on %s", storageTestMountPath))
+	details = append(details, "✓ Cleaned up storage test pod and PVC")
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("PVC bound, mounted and writable with StorageClass %s in %v", storageClass.Name, stages.Ready.Round(time.Second)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
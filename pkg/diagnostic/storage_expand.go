@@ -0,0 +1,256 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// storageExpandedSize is the capacity the expansion test grows its PVC to
+	storageExpandedSize = "2Gi"
+	// storageExpandTimeout bounds the controller and filesystem expansion of the test volume
+	storageExpandTimeout = 180 * time.Second
+)
+
+// volumeFilesystemKB returns the size of the filesystem mounted at storageTestMountPath in the pod, in KiB
+func (t *Tester) volumeFilesystemKB(ctx context.Context, podName string) (int64, CommandOutput, error) {
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+		[]string{"sh", "-c", fmt.Sprintf("df -k %s | tail -1 | awk '{print $2}'", storageTestMountPath)},
+		"Read the filesystem size of the test volume")
+	if err != nil {
+		return 0, output, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(output.Stdout), 10, 64)
+	if err != nil {
+		return 0, output, fmt.Errorf("unexpected df output %q", strings.TrimSpace(output.Stdout))
+	}
+	return size, output, nil
+}
+
+// expansionStages records when each phase of a volume expansion completed, measured from the resize request
+type expansionStages struct {
+	Controller time.Duration // PV capacity grown by the csi-resizer
+	Filesystem time.Duration // filesystem inside the pod grown
+	Status     time.Duration // PVC status capacity updated
+	// ResizePending is set when the PVC reported FileSystemResizePending, i.e. the driver is waiting for a node step
+	ResizePending bool
+}
+
+// failedStage names the first expansion phase that did not complete
+func (s expansionStages) failedStage() string {
+	if s.Controller == 0 {
+		return "Controller Expansion"
+	}
+	return "Filesystem Expansion"
+}
+
+// waitForExpansion polls the PV, the PVC and the pod's filesystem until the filesystem exceeds initialKB and the
+// PVC status reports the new size, recording when each phase completed
+func (t *Tester) waitForExpansion(ctx context.Context, pvcName, pvName, podName string, target resource.Quantity, initialKB int64, requested time.Time) (expansionStages, error) {
+	var stages expansionStages
+	deadline := time.Now().Add(storageExpandTimeout)
+	for time.Now().Before(deadline) {
+		if stages.Controller == 0 {
+			pv, err := t.clientset.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+			if err == nil {
+				if capacity, ok := pv.Spec.Capacity[corev1.ResourceStorage]; ok && capacity.Cmp(target) >= 0 {
+					stages.Controller = time.Since(requested)
+				}
+			}
+		}
+		if stages.Filesystem == 0 {
+			if size, _, err := t.volumeFilesystemKB(ctx, podName); err == nil && size > initialKB {
+				stages.Filesystem = time.Since(requested)
+			}
+		}
+		if stages.Status == 0 {
+			pvc, err := t.clientset.CoreV1().PersistentVolumeClaims(t.namespace).Get(ctx, pvcName, metav1.GetOptions{})
+			if err == nil {
+				if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok && capacity.Cmp(target) >= 0 {
+					stages.Status = time.Since(requested)
+				}
+				for _, condition := range pvc.Status.Conditions {
+					if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+						stages.ResizePending = true
+					}
+				}
+			}
+		}
+		if stages.Filesystem > 0 && stages.Status > 0 {
+			// Drivers that resize the filesystem without a separate controller step never grow the PV first
+			if stages.Controller == 0 {
+				stages.Controller = stages.Status
+			}
+			return stages, nil
+		}
+		select {
+		case <-ctx.Done():
+			return stages, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+	if stages.Controller == 0 {
+		return stages, fmt.Errorf("PV %s was not resized to %s within %v", pvName, target.String(), storageExpandTimeout)
+	}
+	if stages.ResizePending {
+		return stages, fmt.Errorf("PVC %s still reports FileSystemResizePending after %v - the driver does not expand mounted volumes online", pvcName, storageExpandTimeout)
+	}
+	return stages, fmt.Errorf("filesystem in pod %s did not grow within %v", podName, storageExpandTimeout)
+}
+
+// TestVolumeExpansion expands a bound and mounted PVC when its StorageClass allows volume expansion, verifies the
+// filesystem grows inside the pod and reports how long the controller and filesystem phases took
+func (t *Tester) TestVolumeExpansion(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	pvcName := "pvc-expand-test"
+	podName := "netshoot-pvc-expand-test"
+
+	if !config.VolumeExpansion {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Volume expansion test skipped - opt in with --volume-expansion",
+			Details: details,
+		}
+	}
+
+	storageClass, err := t.resolveStorageClass(ctx, config.StorageClass)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Volume expansion test skipped - StorageClass %s does not set allowVolumeExpansion", storageClass.Name),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Using StorageClass %s (provisioner %s, allowVolumeExpansion)", storageClass.Name, storageClass.Provisioner))
+
+	// Step 1: Create and mount a PVC of the original size
+	created := time.Now()
+	if err := t.createTestPVC(ctx, pvcName, storageClass.Name, corev1.ReadWriteOnce); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create PVC %s: %v", pvcName, err),
+			Details: details,
+		}
+	}
+	if err := t.createVolumePod(ctx, podName, pvcName, ""); err != nil {
+		t.cleanupStorageResources(ctx, nil, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create pod %s: %v", podName, err),
+			Details: details,
+		}
+	}
+	volume, err := t.waitForVolumeStages(ctx, pvcName, podName, created)
+	if err != nil {
+		stage := volume.failedStage()
+		details = append(details, fmt.Sprintf("✗ %s failed: %v", stage, err))
+		t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%s failed for StorageClass %s before expansion", stage, storageClass.Name),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       err.Error(),
+				TroubleshootingHints: storageFailureHints(stage, storageClass.Name, pvcName, podName, t.namespace),
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ PVC '%s' (%s) bound to %s and mounted on %s", pvcName, storageTestSize, volume.PVName, volume.Node))
+
+	initialKB, output, err := t.volumeFilesystemKB(ctx, podName)
+	if err != nil {
+		t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read the filesystem size of the test volume: %v", err),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Filesystem Size",
+				TechnicalError: err.Error(),
+				CommandOutputs: []CommandOutput{output},
+			},
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Filesystem size before expansion: %d KiB", initialKB))
+
+	// Step 2: Request the larger size and wait for the controller and filesystem phases
+	target := resource.MustParse(storageExpandedSize)
+	patch := fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":"%s"}}}}`, storageExpandedSize)
+	requested := time.Now()
+	if _, err := t.clientset.CoreV1().PersistentVolumeClaims(t.namespace).Patch(ctx, pvcName, types.MergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to request expansion of PVC %s to %s: %v", pvcName, storageExpandedSize, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Requested expansion to %s", storageExpandedSize))
+
+	metrics := map[string]float64{}
+	stages, err := t.waitForExpansion(ctx, pvcName, volume.PVName, podName, target, initialKB, requested)
+	if stages.Controller > 0 {
+		metrics["controller_resize_ms"] = float64(stages.Controller.Milliseconds())
+		details = append(details, fmt.Sprintf("✓ PV resized by the controller after %v", stages.Controller.Round(time.Millisecond)))
+	}
+	if stages.Filesystem > 0 {
+		metrics["fs_resize_ms"] = float64(stages.Filesystem.Milliseconds())
+		details = append(details, fmt.Sprintf("✓ Filesystem grew inside the pod after %v", stages.Filesystem.Round(time.Millisecond)))
+	}
+	if err != nil {
+		stage := stages.failedStage()
+		details = append(details, fmt.Sprintf("✗ %s failed: %v", stage, err))
+		t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+		hints := []string{
+			fmt.Sprintf("Check the PVC conditions and events: kubectl describe pvc %s -n %s", pvcName, t.namespace),
+			"Controller expansion errors appear in the csi-resizer sidecar logs of the driver's controller pod",
+		}
+		if stage == "Filesystem Expansion" {
+			hints = append(hints,
+				"Filesystem expansion runs in the CSI node plugin on the pod's node - check its logs for NodeExpandVolume errors",
+				"Drivers without online expansion only grow the filesystem when the pod is restarted (FileSystemResizePending)")
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%s failed for StorageClass %s", stage, storageClass.Name),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:         stage,
+				TechnicalError:       err.Error(),
+				TroubleshootingHints: hints,
+			},
+		}
+	}
+	metrics["expand_ms"] = float64(stages.Status.Milliseconds())
+	finalKB, _, _ := t.volumeFilesystemKB(ctx, podName)
+	details = append(details, fmt.Sprintf("✓ PVC status reports %s after %v (filesystem %d KiB -> %d KiB)", storageExpandedSize, stages.Status.Round(time.Millisecond), initialKB, finalKB))
+
+	t.cleanupStorageResources(ctx, []string{podName}, []string{pvcName})
+	details = append(details, "✓ Cleaned up volume expansion test pod and PVC")
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("PVC expanded online from %s to %s with StorageClass %s in %v", storageTestSize, storageExpandedSize, storageClass.Name, stages.Status.Round(time.Second)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
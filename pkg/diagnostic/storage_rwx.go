@@ -0,0 +1,220 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rwxVisibilityTimeout bounds how long a write from one node may take to become visible on the other
+const rwxVisibilityTimeout = 30 * time.Second
+
+// rwxProvisionerHints are provisioner name fragments of shared-filesystem drivers that support ReadWriteMany
+var rwxProvisionerHints = []string{"nfs", "cephfs", "efs", "file.csi.azure.com", "azure-file", "filestore", "longhorn", "glusterfs", "smb", "portworx"}
+
+// findRWXStorageClass returns the named StorageClass, or the first one whose provisioner is a known
+// shared-filesystem driver; nil without error means no capable StorageClass was found
+func (t *Tester) findRWXStorageClass(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	if name != "" {
+		return t.resolveStorageClass(ctx, name)
+	}
+	storageClasses, err := t.clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list StorageClasses: %v", err)
+	}
+	for i, storageClass := range storageClasses.Items {
+		provisioner := strings.ToLower(storageClass.Provisioner)
+		for _, hint := range rwxProvisionerHints {
+			if strings.Contains(provisioner, hint) {
+				return &storageClasses.Items[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// waitForFileContent polls a file in the pod until it holds the expected content, returning how long it took
+func (t *Tester) waitForFileContent(ctx context.Context, podName, file, expected string) (time.Duration, CommandOutput, error) {
+	start := time.Now()
+	var output CommandOutput
+	for time.Since(start) < rwxVisibilityTimeout && ctx.Err() == nil {
+		var err error
+		output, err = t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", []string{"cat", file},
+			fmt.Sprintf("Read %s written from the other node", file))
+		if err == nil && strings.TrimSpace(output.Stdout) == expected {
+			return time.Since(start), output, nil
+		}
+		sleepContext(ctx, time.Second)
+	}
+	return 0, output, fmt.Errorf("%s did not show the other node's write within %v", file, rwxVisibilityTimeout)
+}
+
+// TestRWXCrossNodeAccess provisions a ReadWriteMany PVC, mounts it from pods on two nodes, writes from both at the
+// same time and verifies each node sees the other's data
+func (t *Tester) TestRWXCrossNodeAccess(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	pvcName := "pvc-rwx-test"
+	podNames := []string{"netshoot-rwx-test-1", "netshoot-rwx-test-2"}
+
+	storageClass, err := t.findRWXStorageClass(ctx, config.RWXStorageClass)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if storageClass == nil {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "RWX cross-node test skipped - no StorageClass with a known ReadWriteMany provisioner (use --rwx-storage-class)",
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Using StorageClass %s (provisioner %s)", storageClass.Name, storageClass.Provisioner))
+
+	workerNodes, err := t.getWorkerNodes(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get worker nodes: %v", err),
+			Details: details,
+		}
+	}
+	if len(workerNodes) < 2 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("RWX cross-node test skipped - needs at least 2 worker nodes, found %d", len(workerNodes)),
+			Details: details,
+		}
+	}
+
+	// Step 1: Create the RWX PVC and a pod mounting it on each of two nodes
+	created := time.Now()
+	if err := t.createTestPVC(ctx, pvcName, storageClass.Name, corev1.ReadWriteMany); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create PVC %s: %v", pvcName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created PVC '%s' (%s, ReadWriteMany)", pvcName, storageTestSize))
+	for i, podName := range podNames {
+		if err := t.createVolumePod(ctx, podName, pvcName, workerNodes[i]); err != nil {
+			t.cleanupStorageResources(ctx, podNames, []string{pvcName})
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create pod %s: %v", podName, err),
+				Details: details,
+			}
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created pods '%s' on %s and '%s' on %s", podNames[0], workerNodes[0], podNames[1], workerNodes[1]))
+
+	// Step 2: Both pods must bind, attach and mount the same volume
+	metrics := map[string]float64{}
+	for i, podName := range podNames {
+		stages, err := t.waitForVolumeStages(ctx, pvcName, podName, created)
+		if err != nil {
+			stage := stages.failedStage()
+			details = append(details, fmt.Sprintf("✗ %s failed for %s on %s: %v", stage, podName, workerNodes[i], err))
+			hints := storageFailureHints(stage, storageClass.Name, pvcName, podName, t.namespace)
+			if i > 0 {
+				hints = append([]string{"The first node mounted the volume but the second did not - the driver may not support ReadWriteMany or multi-node attach"}, hints...)
+			}
+			t.cleanupStorageResources(ctx, podNames, []string{pvcName})
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("%s failed on %s for ReadWriteMany StorageClass %s", stage, workerNodes[i], storageClass.Name),
+				Details: details,
+				Metrics: metrics,
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:         stage,
+					TechnicalError:       err.Error(),
+					TroubleshootingHints: hints,
+				},
+			}
+		}
+		if i == 0 {
+			metrics["bind_ms"] = float64(stages.Bound.Milliseconds())
+		}
+		metrics[fmt.Sprintf("ready_ms.%s", workerNodes[i])] = float64(stages.Ready.Milliseconds())
+		details = append(details, fmt.Sprintf("✓ %s mounted the volume on %s after %v", podName, workerNodes[i], stages.Ready.Round(time.Millisecond)))
+	}
+
+	// Step 3: Write from both nodes at the same time, then read the other node's file
+	tokens := make([]string, len(podNames))
+	writeErrors := make([]error, len(podNames))
+	var wg sync.WaitGroup
+	for i, podName := range podNames {
+		tokens[i] = fmt.Sprintf("k8s-diagnostic-%s-%d", workerNodes[i], time.Now().UnixNano())
+		wg.Add(1)
+		go func(i int, podName string) {
+			defer wg.Done()
+			_, writeErrors[i] = t.execInPod(ctx, t.namespace, podName, "netshoot",
+				[]string{"sh", "-c", fmt.Sprintf("echo %s > %s/from-node-%d && sync", tokens[i], storageTestMountPath, i+1)})
+		}(i, podName)
+	}
+	wg.Wait()
+
+	var commandOutputs []CommandOutput
+	var failures []string
+	for i, err := range writeErrors {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("write from %s failed: %v", workerNodes[i], err))
+		}
+	}
+	if len(failures) == 0 {
+		details = append(details, "✓ Both pods wrote to the shared volume concurrently")
+		for i, podName := range podNames {
+			other := 1 - i
+			file := fmt.Sprintf("%s/from-node-%d", storageTestMountPath, other+1)
+			visibility, output, err := t.waitForFileContent(ctx, podName, file, tokens[other])
+			commandOutputs = append(commandOutputs, output)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s on %s: %v", podName, workerNodes[i], err))
+				details = append(details, fmt.Sprintf("✗ %s does not see the write from %s", workerNodes[i], workerNodes[other]))
+				continue
+			}
+			metrics[fmt.Sprintf("visibility_ms.%s", workerNodes[i])] = float64(visibility.Milliseconds())
+			details = append(details, fmt.Sprintf("✓ %s sees the write from %s after %v", workerNodes[i], workerNodes[other], visibility.Round(time.Millisecond)))
+		}
+	}
+
+	t.cleanupStorageResources(ctx, podNames, []string{pvcName})
+	details = append(details, "✓ Cleaned up RWX test pods and PVC")
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("ReadWriteMany volume from StorageClass %s is not shared consistently across nodes", storageClass.Name),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Cross-Node Read/Write Visibility",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Writes not visible on the other node point at client-side caching - check the NFS mount options (actimeo, lookupcache) in the StorageClass mountOptions",
+					"Check both pods mount the same export: kubectl get pv -o wide and compare the volume handle",
+					"Permission errors on write usually mean the export squashes root or the driver ignores fsGroup",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("ReadWriteMany volume from StorageClass %s shared across %s and %s", storageClass.Name, workerNodes[0], workerNodes[1]),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,564 @@
+package diagnostic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var certManagerCertificateGVR = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+// tlsNginxConfig serves the default nginx page over TLS only
+const tlsNginxConfig = `server {
+    listen 443 ssl;
+    server_name _;
+    ssl_certificate /etc/nginx/tls/tls.crt;
+    ssl_certificate_key /etc/nginx/tls/tls.key;
+    location / {
+        root /usr/share/nginx/html;
+        index index.html;
+    }
+}
+`
+
+// TLSCertificateInfo holds the certificate and handshake details parsed from an openssl client session
+type TLSCertificateInfo struct {
+	Protocol     string
+	Cipher       string
+	Subject      string
+	Issuer       string
+	NotAfter     time.Time
+	SANs         string
+	ChainDepth   int
+	VerifyResult string
+}
+
+// serviceDNSNames returns the in-cluster DNS names of a service, shortest first
+func serviceDNSNames(serviceName, namespace string) []string {
+	return []string{
+		serviceName,
+		fmt.Sprintf("%s.%s", serviceName, namespace),
+		fmt.Sprintf("%s.%s.svc", serviceName, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, namespace),
+	}
+}
+
+// generateTestCertificates creates a throwaway CA and a server certificate for the given DNS names
+func generateTestCertificates(dnsNames []string, validity time.Duration) (caPEM, certPEM, keyPEM []byte, err error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate CA key: %v", err)
+	}
+
+	notBefore := time.Now().Add(-5 * time.Minute)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "k8s-diagnostic test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate server key: %v", err)
+	}
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano() + 1),
+		Subject:      pkix.Name{CommonName: dnsNames[len(dnsNames)-1]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, caTemplate, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create server certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(serverKey)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to marshal server key: %v", err)
+	}
+
+	caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return caPEM, certPEM, keyPEM, nil
+}
+
+// requestCertManagerCertificate asks cert-manager to issue a certificate into secretName and returns the CA it reports
+func (t *Tester) requestCertManagerCertificate(ctx context.Context, name, secretName, issuer string, dnsNames []string, timeout time.Duration) ([]byte, error) {
+	names := make([]interface{}, 0, len(dnsNames))
+	for _, dnsName := range dnsNames {
+		names = append(names, dnsName)
+	}
+
+	certificate := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": t.namespace,
+			},
+			"spec": map[string]interface{}{
+				"secretName": secretName,
+				"dnsNames":   names,
+				"issuerRef": map[string]interface{}{
+					"name": issuer,
+					"kind": "ClusterIssuer",
+				},
+			},
+		},
+	}
+	if _, err := t.dynamicClient.Resource(certManagerCertificateGVR).Namespace(t.namespace).Create(ctx, certificate, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to create cert-manager Certificate: %v", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			return nil, fmt.Errorf("cert-manager did not issue secret %s within %v", secretName, timeout)
+		case <-ticker.C:
+			secret, err := t.clientset.CoreV1().Secrets(t.namespace).Get(ctx, secretName, metav1.GetOptions{})
+			if err != nil || len(secret.Data[corev1.TLSCertKey]) == 0 {
+				continue
+			}
+			return secret.Data["ca.crt"], nil
+		}
+	}
+}
+
+// createTLSNginxDeployment creates an nginx deployment serving HTTPS with the certificate in secretName
+func (t *Tester) createTLSNginxDeployment(ctx context.Context, name, secretName, configMapName string) (*appsv1.Deployment, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName,
+			Namespace: t.namespace,
+		},
+		Data: map[string]string{
+			"default.conf": tlsNginxConfig,
+		},
+	}
+	if _, err := t.clientset.CoreV1().ConfigMaps(t.namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to create nginx TLS config: %v", err)
+	}
+
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "nginx",
+							Image: t.image(nginxImage),
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 443,
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "tls", MountPath: "/etc/nginx/tls", ReadOnly: true},
+								{Name: "config", MountPath: "/etc/nginx/conf.d", ReadOnly: true},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "tls",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+							},
+						},
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return t.clientset.AppsV1().Deployments(t.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// parseOpenSSLSession extracts handshake and certificate details from openssl s_client/x509 output
+func parseOpenSSLSession(output string) TLSCertificateInfo {
+	var info TLSCertificateInfo
+	for _, rawLine := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(rawLine)
+		switch {
+		case strings.HasPrefix(line, "Protocol version:"):
+			info.Protocol = strings.TrimSpace(strings.TrimPrefix(line, "Protocol version:"))
+		case strings.HasPrefix(line, "Protocol  :"):
+			info.Protocol = strings.TrimSpace(strings.TrimPrefix(line, "Protocol  :"))
+		case strings.HasPrefix(line, "Ciphersuite:"):
+			info.Cipher = strings.TrimSpace(strings.TrimPrefix(line, "Ciphersuite:"))
+		case strings.HasPrefix(line, "Cipher    :"):
+			info.Cipher = strings.TrimSpace(strings.TrimPrefix(line, "Cipher    :"))
+		case strings.HasPrefix(line, "Verification:"):
+			info.VerifyResult = strings.TrimSpace(strings.TrimPrefix(line, "Verification:"))
+		case strings.HasPrefix(line, "Verify return code:"):
+			info.VerifyResult = strings.TrimSpace(strings.TrimPrefix(line, "Verify return code:"))
+		case strings.HasPrefix(line, "subject="):
+			info.Subject = strings.TrimSpace(strings.TrimPrefix(line, "subject="))
+		case strings.HasPrefix(line, "issuer="):
+			info.Issuer = strings.TrimSpace(strings.TrimPrefix(line, "issuer="))
+		case strings.HasPrefix(line, "notAfter="):
+			if notAfter, err := time.Parse("Jan _2 15:04:05 2006 MST", strings.TrimPrefix(line, "notAfter=")); err == nil {
+				info.NotAfter = notAfter
+			}
+		case strings.HasPrefix(line, "DNS:"):
+			info.SANs = line
+		case strings.HasPrefix(line, "depth="):
+			info.ChainDepth++
+		}
+	}
+	return info
+}
+
+// TestTLSConnectivity tests HTTPS service connectivity with a generated certificate
+func (t *Tester) TestTLSConnectivity(ctx context.Context) TestResult {
+	return t.TestTLSConnectivityWithConfig(ctx, TestConfig{})
+}
+
+// TestTLSConnectivityWithConfig serves nginx over TLS and validates handshakes, SNI, chain, expiry and protocol from a client pod
+func (t *Tester) TestTLSConnectivityWithConfig(ctx context.Context, config TestConfig) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	deploymentName := "web-tls"
+	serviceName := "web-tls"
+	secretName := "web-tls-cert"
+	configMapName := "web-tls-nginx"
+	testPodName := "netshoot-tls-test"
+	dnsNames := serviceDNSNames(serviceName, t.namespace)
+	sniName := dnsNames[len(dnsNames)-1]
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+		t.clientset.CoreV1().Secrets(t.namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().ConfigMaps(t.namespace).Delete(ctx, configMapName, metav1.DeleteOptions{})
+		if config.TLSIssuer != "" {
+			t.dynamicClient.Resource(certManagerCertificateGVR).Namespace(t.namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+		}
+	}
+
+	// Step 1: Obtain a serving certificate
+	var caPEM []byte
+	if config.TLSIssuer != "" {
+		var err error
+		caPEM, err = t.requestCertManagerCertificate(ctx, secretName, secretName, config.TLSIssuer, dnsNames, 90*time.Second)
+		if err != nil {
+			cleanup()
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to obtain certificate from cert-manager issuer %s: %v", config.TLSIssuer, err),
+				Details: details,
+				DetailedDiagnostics: &DetailedDiagnostics{
+					FailureStage:   "Certificate Issuance",
+					TechnicalError: err.Error(),
+					TroubleshootingHints: []string{
+						fmt.Sprintf("Check the Certificate status: kubectl describe certificate %s -n %s", secretName, t.namespace),
+						fmt.Sprintf("Check the ClusterIssuer is ready: kubectl get clusterissuer %s", config.TLSIssuer),
+					},
+				},
+			}
+		}
+		details = append(details, fmt.Sprintf("✓ cert-manager issued certificate into secret '%s' via ClusterIssuer %s", secretName, config.TLSIssuer))
+	} else {
+		var certPEM, keyPEM []byte
+		var err error
+		caPEM, certPEM, keyPEM, err = generateTestCertificates(dnsNames, 24*time.Hour)
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to generate test certificates: %v", err),
+				Details: details,
+			}
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: t.namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+				"ca.crt":                caPEM,
+			},
+		}
+		if _, err := t.clientset.CoreV1().Secrets(t.namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create TLS secret: %v", err),
+				Details: details,
+			}
+		}
+		details = append(details, fmt.Sprintf("✓ Generated test CA and server certificate for %s", strings.Join(dnsNames, ", ")))
+	}
+
+	// Step 2: Serve HTTPS from nginx
+	if _, err := t.createTLSNginxDeployment(ctx, deploymentName, secretName, configMapName); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create TLS nginx deployment: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ TLS nginx deployment '%s' is ready", deploymentName))
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: t.namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": deploymentName},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "https",
+					Port:       443,
+					TargetPort: intstr.FromInt(443),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+	if _, err := t.clientset.CoreV1().Services(t.namespace).Create(ctx, service, metav1.CreateOptions{}); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create HTTPS service: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created HTTPS service '%s' on port 443", serviceName))
+
+	// Step 3: Prepare the client pod with the CA bundle
+	if _, err := t.createNetshootPod(ctx, testPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForPodReady(ctx, testPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Test pod %s did not become ready: %v", testPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Test pod '%s' is ready", testPodName))
+
+	caFileArgs := ""
+	if len(caPEM) > 0 {
+		writeCA := []string{"sh", "-c", fmt.Sprintf("printf '%%s' '%s' > /tmp/ca.crt", string(caPEM))}
+		if _, err := t.execInPod(ctx, t.namespace, testPodName, "netshoot", writeCA); err != nil {
+			details = append(details, fmt.Sprintf("⚠️ Could not install CA bundle in client pod: %v", err))
+		} else {
+			caFileArgs = "-CAfile /tmp/ca.crt"
+		}
+	} else {
+		details = append(details, "⚠️ Issuer did not provide ca.crt - chain verification uses the client's default trust store")
+	}
+
+	// Step 4: Handshake with SNI and hostname verification
+	target := fmt.Sprintf("%s:443", serviceName)
+	handshakeCmd := fmt.Sprintf("openssl s_client -connect %s -servername %s -verify_hostname %s %s -brief </dev/null 2>&1", target, sniName, sniName, caFileArgs)
+	handshake, handshakeErr := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot", []string{"sh", "-c", handshakeCmd},
+		"TLS handshake with SNI and hostname verification")
+	commandOutputs = append(commandOutputs, handshake)
+
+	certCmd := fmt.Sprintf("openssl s_client -connect %s -servername %s -showcerts </dev/null 2>/dev/null | openssl x509 -noout -subject -issuer -enddate -ext subjectAltName", target, sniName)
+	certOutput, _ := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot", []string{"sh", "-c", certCmd},
+		"Served certificate details")
+	commandOutputs = append(commandOutputs, certOutput)
+
+	chainCmd := fmt.Sprintf("openssl s_client -connect %s -servername %s %s </dev/null 2>&1 | grep -E '^depth=|Verify return code'", target, sniName, caFileArgs)
+	chainOutput, _ := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot", []string{"sh", "-c", chainCmd},
+		"Certificate chain verification")
+	commandOutputs = append(commandOutputs, chainOutput)
+
+	legacyCmd := fmt.Sprintf("openssl s_client -connect %s -servername %s -tls1_1 </dev/null 2>&1", target, sniName)
+	legacyOutput, legacyErr := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot", []string{"sh", "-c", legacyCmd},
+		"Legacy TLS 1.1 handshake (expected to be rejected)")
+	commandOutputs = append(commandOutputs, legacyOutput)
+
+	httpsCmd := fmt.Sprintf("curl -s -o /dev/null -w '%%{http_code}' --connect-timeout 3 --max-time 5 %s https://%s", strings.Replace(caFileArgs, "-CAfile", "--cacert", 1), sniName)
+	httpsOutput, httpsErr := t.execInPodWithOutput(ctx, t.namespace, testPodName, "netshoot", []string{"sh", "-c", httpsCmd},
+		"HTTPS request with certificate validation")
+	commandOutputs = append(commandOutputs, httpsOutput)
+
+	cleanup()
+	details = append(details, "✓ Cleaned up TLS test resources")
+
+	info := parseOpenSSLSession(handshake.Stdout + "\n" + certOutput.Stdout + "\n" + chainOutput.Stdout)
+	var problems []string
+
+	if handshakeErr != nil || info.Protocol == "" {
+		problems = append(problems, fmt.Sprintf("TLS handshake with SNI %s failed", sniName))
+		details = append(details, fmt.Sprintf("✗ TLS handshake failed: %s", strings.TrimSpace(handshake.Stdout)))
+	} else {
+		details = append(details, fmt.Sprintf("✓ TLS handshake succeeded with SNI %s - %s, %s", sniName, info.Protocol, info.Cipher))
+	}
+
+	if info.Protocol != "" && info.Protocol != "TLSv1.2" && info.Protocol != "TLSv1.3" {
+		problems = append(problems, fmt.Sprintf("negotiated protocol %s is older than TLS 1.2", info.Protocol))
+	}
+	if legacyErr == nil && strings.Contains(legacyOutput.Stdout, "BEGIN CERTIFICATE") {
+		problems = append(problems, "server accepted a TLS 1.1 handshake")
+		details = append(details, "✗ Server accepts legacy TLS 1.1")
+	} else {
+		details = append(details, "✓ Legacy TLS 1.1 handshake rejected")
+	}
+
+	if caFileArgs != "" {
+		if strings.HasPrefix(info.VerifyResult, "OK") || strings.HasPrefix(info.VerifyResult, "0 (ok)") {
+			details = append(details, fmt.Sprintf("✓ Certificate chain verified (depth %d)", info.ChainDepth))
+		} else {
+			problems = append(problems, fmt.Sprintf("certificate chain verification failed: %s", info.VerifyResult))
+			details = append(details, fmt.Sprintf("✗ Certificate chain verification failed: %s", info.VerifyResult))
+		}
+	}
+
+	if info.Subject != "" {
+		details = append(details, fmt.Sprintf("  Subject: %s", info.Subject))
+		details = append(details, fmt.Sprintf("  Issuer: %s", info.Issuer))
+	}
+	if info.SANs != "" {
+		details = append(details, fmt.Sprintf("  SANs: %s", info.SANs))
+	}
+	if !info.NotAfter.IsZero() {
+		remaining := time.Until(info.NotAfter)
+		details = append(details, fmt.Sprintf("  Expires: %s (in %.1f days)", info.NotAfter.Format(time.RFC3339), remaining.Hours()/24))
+		if remaining <= 0 {
+			problems = append(problems, fmt.Sprintf("certificate expired at %s", info.NotAfter.Format(time.RFC3339)))
+		} else if remaining < 7*24*time.Hour && config.TLSIssuer != "" {
+			details = append(details, "⚠️ Certificate expires within 7 days")
+		}
+	}
+
+	if httpsErr != nil {
+		problems = append(problems, fmt.Sprintf("HTTPS request failed (curl exit code %d)", httpsOutput.ExitCode))
+		details = append(details, fmt.Sprintf("✗ HTTPS request to https://%s failed", sniName))
+	} else if success, message := evaluateHTTPStatusCode(strings.Trim(httpsOutput.Stdout, "' \n")); success {
+		details = append(details, fmt.Sprintf("✓ HTTPS request to https://%s successful - %s", sniName, message))
+	} else {
+		problems = append(problems, fmt.Sprintf("HTTPS request returned %s", message))
+		details = append(details, fmt.Sprintf("✗ HTTPS request issue - %s", message))
+	}
+
+	tlsContext := &NetworkContext{
+		AdditionalInfo: map[string]string{
+			"tls_sni":          sniName,
+			"tls_protocol":     info.Protocol,
+			"tls_cipher":       info.Cipher,
+			"cert_subject":     info.Subject,
+			"cert_issuer":      info.Issuer,
+			"cert_sans":        info.SANs,
+			"cert_verify":      info.VerifyResult,
+			"cert_chain_depth": fmt.Sprintf("%d", info.ChainDepth),
+			"cert_source":      "generated",
+		},
+	}
+	if !info.NotAfter.IsZero() {
+		tlsContext.AdditionalInfo["cert_not_after"] = info.NotAfter.Format(time.RFC3339)
+	}
+	if config.TLSIssuer != "" {
+		tlsContext.AdditionalInfo["cert_source"] = fmt.Sprintf("cert-manager/%s", config.TLSIssuer)
+	}
+
+	if len(problems) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("TLS connectivity test failed - %s", strings.Join(problems, "; ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "TLS Validation",
+				TechnicalError: strings.Join(problems, "; "),
+				CommandOutputs: commandOutputs,
+				NetworkContext: tlsContext,
+				TroubleshootingHints: []string{
+					"Check that a proxy or mesh sidecar is not terminating TLS in front of the backend",
+					"Verify the served certificate SANs include the name clients connect with",
+					"Compare the negotiated protocol with the server's ssl_protocols setting",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("TLS connectivity test passed - %s handshake with valid certificate", info.Protocol),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+			NetworkContext: tlsContext,
+		},
+	}
+}
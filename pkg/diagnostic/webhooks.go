@@ -0,0 +1,288 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// webhookEventWindow is how far back webhook call failures in Events are counted
+const webhookEventWindow = time.Hour
+
+// webhookFailurePattern extracts the webhook name from API server admission errors recorded in Events
+var webhookFailurePattern = regexp.MustCompile(`failed calling webhook "([^"]+)"`)
+
+// webhookTarget is one webhook of a Validating or MutatingWebhookConfiguration
+type webhookTarget struct {
+	Configuration string
+	Kind          string // "validating" or "mutating"
+	Name          string
+	FailurePolicy admissionregistrationv1.FailurePolicyType
+	Service       *admissionregistrationv1.ServiceReference
+	URL           string
+	CoversPods    bool // the webhook's rules match pod creation, so it can block our own test pods
+}
+
+// endpoint returns the https address the API server calls for the webhook
+func (w webhookTarget) endpoint() string {
+	if w.Service == nil {
+		return w.URL
+	}
+	port := int32(443)
+	if w.Service.Port != nil {
+		port = *w.Service.Port
+	}
+	path := ""
+	if w.Service.Path != nil {
+		path = *w.Service.Path
+	}
+	return fmt.Sprintf("https://%s.%s.svc:%d%s", w.Service.Name, w.Service.Namespace, port, path)
+}
+
+// rulesCoverPodCreate reports whether any rule intercepts CREATE of core/v1 pods
+func rulesCoverPodCreate(rules []admissionregistrationv1.RuleWithOperations) bool {
+	matches := func(values []string, want string) bool {
+		for _, value := range values {
+			if value == "*" || value == want {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rule := range rules {
+		operations := make([]string, len(rule.Operations))
+		for i, operation := range rule.Operations {
+			operations[i] = string(operation)
+		}
+		if matches(operations, "CREATE") && matches(rule.APIGroups, "") && matches(rule.Resources, "pods") {
+			return true
+		}
+	}
+	return false
+}
+
+// collectWebhookTargets lists the webhooks of all Validating and MutatingWebhookConfigurations
+func (t *Tester) collectWebhookTargets(ctx context.Context) ([]webhookTarget, error) {
+	var targets []webhookTarget
+	validating, err := t.clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ValidatingWebhookConfigurations: %v", err)
+	}
+	for _, configuration := range validating.Items {
+		for _, webhook := range configuration.Webhooks {
+			target := webhookTarget{Configuration: configuration.Name, Kind: "validating", Name: webhook.Name,
+				FailurePolicy: admissionregistrationv1.Fail, Service: webhook.ClientConfig.Service, CoversPods: rulesCoverPodCreate(webhook.Rules)}
+			if webhook.FailurePolicy != nil {
+				target.FailurePolicy = *webhook.FailurePolicy
+			}
+			if webhook.ClientConfig.URL != nil {
+				target.URL = *webhook.ClientConfig.URL
+			}
+			targets = append(targets, target)
+		}
+	}
+	mutating, err := t.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MutatingWebhookConfigurations: %v", err)
+	}
+	for _, configuration := range mutating.Items {
+		for _, webhook := range configuration.Webhooks {
+			target := webhookTarget{Configuration: configuration.Name, Kind: "mutating", Name: webhook.Name,
+				FailurePolicy: admissionregistrationv1.Fail, Service: webhook.ClientConfig.Service, CoversPods: rulesCoverPodCreate(webhook.Rules)}
+			if webhook.FailurePolicy != nil {
+				target.FailurePolicy = *webhook.FailurePolicy
+			}
+			if webhook.ClientConfig.URL != nil {
+				target.URL = *webhook.ClientConfig.URL
+			}
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
+
+// webhookServiceProblem returns why the webhook's service cannot serve requests, or "" when it has ready endpoints
+func (t *Tester) webhookServiceProblem(ctx context.Context, service *admissionregistrationv1.ServiceReference) string {
+	if _, err := t.clientset.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{}); err != nil {
+		return fmt.Sprintf("service %s/%s not found", service.Namespace, service.Name)
+	}
+	slices, err := t.clientset.DiscoveryV1().EndpointSlices(service.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("kubernetes.io/service-name=%s", service.Name),
+	})
+	if err != nil {
+		return fmt.Sprintf("failed to list endpoints of %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("service %s/%s has no ready endpoints", service.Namespace, service.Name)
+}
+
+// recentWebhookFailures counts "failed calling webhook" Events per webhook name within webhookEventWindow and keeps
+// the latest message of each
+func (t *Tester) recentWebhookFailures(ctx context.Context) (map[string]int, map[string]string) {
+	counts := map[string]int{}
+	messages := map[string]string{}
+	events, err := t.clientset.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return counts, messages
+	}
+	since := time.Now().Add(-webhookEventWindow)
+	latest := map[string]time.Time{}
+	for _, event := range events.Items {
+		match := webhookFailurePattern.FindStringSubmatch(event.Message)
+		if match == nil || eventTimestamp(event).Before(since) {
+			continue
+		}
+		count := int(event.Count)
+		if count == 0 {
+			count = 1
+		}
+		counts[match[1]] += count
+		if eventTimestamp(event).After(latest[match[1]]) {
+			latest[match[1]] = eventTimestamp(event)
+			messages[match[1]] = event.Message
+		}
+	}
+	return counts, messages
+}
+
+// TestAdmissionWebhooks enumerates the admission webhooks and verifies each one is reachable: the service exists
+// with ready endpoints, the endpoint answers from inside the cluster, and no recent Events report failed calls
+func (t *Tester) TestAdmissionWebhooks(ctx context.Context) TestResult {
+	var details []string
+	podName := "netshoot-webhook-test"
+
+	targets, err := t.collectWebhookTargets(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: err.Error(),
+			Details: details,
+		}
+	}
+	if len(targets) == 0 {
+		return TestResult{
+			Success: true,
+			Message: "No admission webhooks configured",
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Found %d admission webhooks", len(targets)))
+
+	// Step 1: Create the probe pod - a broken webhook intercepting pods can already fail here
+	probeAvailable := true
+	if _, err := t.createNetshootPod(ctx, podName, ""); err != nil {
+		probeAvailable = false
+		details = append(details, fmt.Sprintf("✗ Creating the probe pod failed: %v", err))
+	} else if err := t.waitForPodReady(ctx, podName, 60*time.Second); err != nil {
+		probeAvailable = false
+		details = append(details, fmt.Sprintf("ℹ️ Endpoint probes skipped - probe pod not ready: %v", err))
+	}
+	defer t.cleanupPod(ctx, podName)
+
+	// Step 2: Check every webhook's service, endpoint and recent failures
+	failureCounts, failureMessages := t.recentWebhookFailures(ctx)
+	var failures, warnings []string
+	var commandOutputs []CommandOutput
+	probed := map[string]string{}
+	details = append(details, fmt.Sprintf("  %-50s %-10s %-7s %s", "WEBHOOK", "KIND", "POLICY", "STATUS"))
+	for _, target := range targets {
+		var problems []string
+		if target.Service != nil {
+			if problem := t.webhookServiceProblem(ctx, target.Service); problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+		endpoint := target.endpoint()
+		if probeAvailable && len(problems) == 0 && endpoint != "" {
+			// Any HTTP status means the TLS endpoint answered; 000 means the connection failed
+			status, seen := probed[endpoint]
+			if !seen {
+				output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot",
+					[]string{"curl", "-sk", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", "5", "-X", "POST", endpoint},
+					fmt.Sprintf("Probe webhook endpoint %s", endpoint))
+				commandOutputs = append(commandOutputs, output)
+				status = strings.TrimSpace(output.Stdout)
+				probed[endpoint] = status
+			}
+			if status == "" || status == "000" {
+				problems = append(problems, fmt.Sprintf("%s did not answer within 5s", endpoint))
+			}
+		}
+		if count := failureCounts[target.Name]; count > 0 {
+			problems = append(problems, fmt.Sprintf("%d failed calls in the last %v: %s", count, webhookEventWindow, failureMessages[target.Name]))
+		}
+
+		status := "reachable"
+		if len(problems) > 0 {
+			status = strings.Join(problems, "; ")
+			issue := fmt.Sprintf("%s webhook %s (%s): %s", target.Kind, target.Name, target.Configuration, status)
+			if target.CoversPods {
+				issue += " - intercepts pod creation"
+			}
+			if target.FailurePolicy == admissionregistrationv1.Fail {
+				failures = append(failures, issue)
+			} else {
+				warnings = append(warnings, issue)
+			}
+		}
+		details = append(details, fmt.Sprintf("  %-50s %-10s %-7s %s", target.Name, target.Kind, target.FailurePolicy, status))
+	}
+
+	for _, warning := range warnings {
+		details = append(details, fmt.Sprintf("⚠️ %s (failurePolicy Ignore - requests are admitted without it)", warning))
+	}
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			details = append(details, fmt.Sprintf("✗ %s", failure))
+		}
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d admission webhooks with failurePolicy Fail are unreachable or failing - matching requests are rejected", len(failures), len(targets)),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Admission Webhook Reachability",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Check the webhook's backing pods: kubectl get pods -n <namespace> for the service listed above",
+					"Webhooks left behind by an uninstalled operator block requests until removed: kubectl get validatingwebhookconfigurations,mutatingwebhookconfigurations",
+					"The API server calls webhooks from the control plane - on managed clusters a firewall between the control plane and nodes can block the webhook port even when pods reach it",
+					"Certificate errors in the failure Events mean the caBundle does not match the webhook's serving certificate",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success:  true,
+		Message:  fmt.Sprintf("All %d admission webhooks with failurePolicy Fail are reachable", len(targets)-len(warnings)),
+		Details:  details,
+		Warnings: warnings,
+	}
+}
+
+// webhookDenialHint explains a test failure caused by a failing admission webhook, or returns "" for other failures
+func webhookDenialHint(result TestResult) string {
+	text := result.Message
+	if result.DetailedDiagnostics != nil {
+		text += " " + result.DetailedDiagnostics.TechnicalError
+	}
+	match := webhookFailurePattern.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return fmt.Sprintf("Resource creation was blocked by admission webhook %q - run the admission-webhooks test to check its reachability", match[1])
+}
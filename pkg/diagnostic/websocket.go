@@ -0,0 +1,404 @@
+package diagnostic
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// upgradeEchoImage answers plain HTTP and echoes WebSocket frames on any path
+	upgradeEchoImage = "jmalloc/echo-server"
+	upgradeTestHost  = "upgrade.k8s-diagnostic.test"
+
+	// websocketKey is the sample nonce from RFC 6455; the server must answer with its derived accept value
+	websocketKey  = "dGhlIHNhbXBsZSBub25jZQ=="
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// h2NginxConfig serves HTTP/2 with prior knowledge (h2c) on 8081 and HTTP/2 over TLS (ALPN) on 8443
+const h2NginxConfig = `server {
+    listen 8081;
+    http2 on;
+    server_name _;
+    location / {
+        root /usr/share/nginx/html;
+        index index.html;
+    }
+}
+
+server {
+    listen 8443 ssl;
+    http2 on;
+    server_name _;
+    ssl_certificate /etc/nginx/tls/tls.crt;
+    ssl_certificate_key /etc/nginx/tls/tls.key;
+    location / {
+        root /usr/share/nginx/html;
+        index index.html;
+    }
+}
+`
+
+// upgradeProbe records the outcome of one protocol check against one network path
+type upgradeProbe struct {
+	Protocol string
+	Path     string
+	Output   CommandOutput
+	Success  bool
+	Observed string
+}
+
+// websocketAcceptValue computes the Sec-WebSocket-Accept header expected for a Sec-WebSocket-Key
+func websocketAcceptValue(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// createUpgradeEchoDeployment runs a WebSocket echo server next to an HTTP/2-enabled nginx in the same pods
+func (t *Tester) createUpgradeEchoDeployment(ctx context.Context, name, secretName, configMapName string) (*appsv1.Deployment, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName,
+			Namespace: t.namespace,
+		},
+		Data: map[string]string{
+			"default.conf": h2NginxConfig,
+		},
+	}
+	if _, err := t.clientset.CoreV1().ConfigMaps(t.namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to create nginx HTTP/2 config: %v", err)
+	}
+
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "echo",
+							Image: t.image(upgradeEchoImage),
+							Env: []corev1.EnvVar{
+								{Name: "PORT", Value: "8080"},
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 8080,
+								},
+							},
+						},
+						{
+							Name:  "nginx",
+							Image: t.image(nginxImage),
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 8081,
+								},
+								{
+									ContainerPort: 8443,
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "tls", MountPath: "/etc/nginx/tls", ReadOnly: true},
+								{Name: "config", MountPath: "/etc/nginx/conf.d", ReadOnly: true},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "tls",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: secretName},
+							},
+						},
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return t.clientset.AppsV1().Deployments(t.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// probeWebSocketUpgrade sends an RFC 6455 handshake and verifies the 101 response and accept key
+func (t *Tester) probeWebSocketUpgrade(ctx context.Context, podName, path, url, host string) upgradeProbe {
+	args := []string{"curl", "-s", "-i", "-N", "--http1.1", "--max-time", "3",
+		"-H", "Connection: Upgrade",
+		"-H", "Upgrade: websocket",
+		"-H", "Sec-WebSocket-Version: 13",
+		"-H", fmt.Sprintf("Sec-WebSocket-Key: %s", websocketKey)}
+	if host != "" {
+		args = append(args, "-H", fmt.Sprintf("Host: %s", host))
+	}
+	args = append(args, url)
+
+	// curl keeps the upgraded connection open until --max-time, so a timeout exit code is expected
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", args,
+		fmt.Sprintf("WebSocket upgrade via %s", path))
+
+	probe := upgradeProbe{Protocol: "WebSocket", Path: path, Output: output}
+	statusLine := strings.SplitN(strings.TrimSpace(output.Stdout), "\n", 2)[0]
+	probe.Observed = strings.TrimSpace(statusLine)
+	if !strings.Contains(statusLine, " 101") {
+		return probe
+	}
+
+	expectedAccept := websocketAcceptValue(websocketKey)
+	for _, line := range strings.Split(output.Stdout, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "Sec-WebSocket-Accept") {
+			if strings.TrimSpace(value) == expectedAccept {
+				probe.Success = true
+			} else {
+				probe.Observed = fmt.Sprintf("101 with wrong Sec-WebSocket-Accept %s", strings.TrimSpace(value))
+			}
+		}
+	}
+	return probe
+}
+
+// probeHTTP2 requests url with the given curl HTTP/2 mode and verifies the negotiated HTTP version is 2
+func (t *Tester) probeHTTP2(ctx context.Context, podName, protocol, path, url string, extraArgs ...string) upgradeProbe {
+	args := []string{"curl", "-s", "-k", "-o", "/dev/null", "--max-time", "5", "-w", "%{http_version} %{http_code}"}
+	args = append(args, extraArgs...)
+	args = append(args, url)
+
+	output, err := t.execInPodWithOutput(ctx, t.namespace, podName, "netshoot", args,
+		fmt.Sprintf("%s request via %s", protocol, path))
+
+	probe := upgradeProbe{Protocol: protocol, Path: path, Output: output}
+	fields := strings.Fields(output.Stdout)
+	if len(fields) == 2 {
+		probe.Observed = fmt.Sprintf("HTTP/%s status %s", fields[0], fields[1])
+		probe.Success = err == nil && fields[0] == "2" && !strings.HasPrefix(fields[1], "5")
+	} else {
+		probe.Observed = strings.TrimSpace(output.Stderr)
+	}
+	return probe
+}
+
+// TestWebSocketAndHTTP2 validates WebSocket upgrades and HTTP/2 (h2c and TLS) through ClusterIP and ingress paths
+func (t *Tester) TestWebSocketAndHTTP2(ctx context.Context) TestResult {
+	var details []string
+	var commandOutputs []CommandOutput
+
+	deploymentName := "upgrade-echo"
+	serviceName := "upgrade-echo"
+	secretName := "upgrade-echo-cert"
+	configMapName := "upgrade-echo-nginx"
+	ingressName := "upgrade-echo"
+	routeName := "upgrade-echo"
+	testPodName := "netshoot-upgrade-test"
+
+	cleanup := func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		t.cleanupServiceResources(ctx, deploymentName, serviceName, testPodName)
+		t.cleanupIngress(ctx, ingressName)
+		t.dynamicClient.Resource(httpRouteGVR).Namespace(t.namespace).Delete(ctx, routeName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().Secrets(t.namespace).Delete(ctx, secretName, metav1.DeleteOptions{})
+		t.clientset.CoreV1().ConfigMaps(t.namespace).Delete(ctx, configMapName, metav1.DeleteOptions{})
+	}
+
+	// Step 1: Certificate for the HTTP/2 TLS listener
+	_, certPEM, keyPEM, err := generateTestCertificates(serviceDNSNames(serviceName, t.namespace), 24*time.Hour)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to generate test certificates: %v", err),
+			Details: details,
+		}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: t.namespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	if _, err := t.clientset.CoreV1().Secrets(t.namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create TLS secret: %v", err),
+			Details: details,
+		}
+	}
+
+	// Step 2: WebSocket echo server and HTTP/2 nginx
+	if _, err := t.createUpgradeEchoDeployment(ctx, deploymentName, secretName, configMapName); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create upgrade echo deployment: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForDeploymentReady(ctx, deploymentName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Deployment %s did not become ready: %v", deploymentName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Deployment '%s' is ready (WebSocket echo on 8080, nginx h2c on 8081, h2 over TLS on 8443)", deploymentName))
+
+	wsProtocol := "kubernetes.io/ws"
+	h2cProtocol := "kubernetes.io/h2c"
+	_, err = t.createServiceWithPorts(ctx, serviceName, deploymentName, []corev1.ServicePort{
+		{Name: "ws", Port: 80, TargetPort: intstr.FromInt(8080), Protocol: corev1.ProtocolTCP, AppProtocol: &wsProtocol},
+		{Name: "h2c", Port: 81, TargetPort: intstr.FromInt(8081), Protocol: corev1.ProtocolTCP, AppProtocol: &h2cProtocol},
+		{Name: "https", Port: 443, TargetPort: intstr.FromInt(8443), Protocol: corev1.ProtocolTCP},
+	})
+	if err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create service: %v", err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Created service '%s' with ports 80 (ws), 81 (h2c) and 443 (https)", serviceName))
+
+	// Step 3: Client pod
+	if _, err := t.createNetshootPod(ctx, testPodName, ""); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create test pod: %v", err),
+			Details: details,
+		}
+	}
+	if err := t.waitForPodReady(ctx, testPodName, 120*time.Second); err != nil {
+		cleanup()
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Test pod %s did not become ready: %v", testPodName, err),
+			Details: details,
+		}
+	}
+	details = append(details, fmt.Sprintf("✓ Test pod '%s' is ready", testPodName))
+
+	// Step 4: ClusterIP path
+	probes := []upgradeProbe{
+		t.probeWebSocketUpgrade(ctx, testPodName, "ClusterIP", fmt.Sprintf("http://%s:80/", serviceName), ""),
+		t.probeHTTP2(ctx, testPodName, "HTTP/2 h2c", "ClusterIP", fmt.Sprintf("http://%s:81/", serviceName), "--http2-prior-knowledge"),
+		t.probeHTTP2(ctx, testPodName, "HTTP/2 TLS", "ClusterIP", fmt.Sprintf("https://%s:443/", serviceName), "--http2"),
+	}
+
+	// Step 5: Ingress path when an ingress controller is installed
+	if class, err := t.detectIngressClass(ctx); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Ingress path skipped: %v", err))
+	} else if _, err := t.createIngressForService(ctx, ingressName, class.Name, upgradeTestHost, serviceName, 80, nil); err != nil {
+		details = append(details, fmt.Sprintf("✗ Failed to create Ingress: %v", err))
+	} else if address, err := t.waitForIngressAddress(ctx, ingressName, 60*time.Second); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Ingress path skipped: %v", err))
+	} else {
+		details = append(details, fmt.Sprintf("✓ Ingress '%s' (class %s) published address %s", ingressName, class.Name, address))
+		path := fmt.Sprintf("Ingress (%s)", class.Name)
+		target := httpTargetForIP(address)
+		probes = append(probes,
+			t.probeWebSocketUpgrade(ctx, testPodName, path, fmt.Sprintf("http://%s/", target), upgradeTestHost),
+			t.probeHTTP2(ctx, testPodName, "HTTP/2 TLS", path, fmt.Sprintf("https://%s/", upgradeTestHost),
+				"--http2", "--resolve", fmt.Sprintf("%s:443:%s", upgradeTestHost, address)),
+		)
+	}
+
+	// Step 6: Gateway API path when a Gateway is programmed
+	if gateway, err := t.detectGateway(ctx); err != nil {
+		details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: %v", err))
+	} else {
+		backends := []interface{}{
+			map[string]interface{}{"name": serviceName, "port": int64(80)},
+		}
+		if err := t.createGatewayRoute(ctx, httpRouteGVR, "HTTPRoute", routeName, upgradeTestHost, gateway, backends); err != nil {
+			details = append(details, fmt.Sprintf("ℹ️ Gateway path skipped: could not create HTTPRoute: %v", err))
+		} else {
+			details = append(details, fmt.Sprintf("✓ Attached HTTPRoute '%s' to Gateway %s/%s listener %s", routeName, gateway.Namespace, gateway.Name, gateway.Listener))
+			// Give the gateway controller a moment to program the route
+			sleepContext(ctx, 5*time.Second)
+			probes = append(probes, t.probeWebSocketUpgrade(ctx, testPodName, fmt.Sprintf("Gateway (%s/%s)", gateway.Namespace, gateway.Name),
+				fmt.Sprintf("http://%s:%d/", httpTargetForIP(gateway.Address), gateway.Port), upgradeTestHost))
+		}
+	}
+
+	cleanup()
+	details = append(details, "✓ Cleaned up WebSocket/HTTP/2 test resources")
+
+	// Per-path results table
+	details = append(details, fmt.Sprintf("ℹ️ %-12s %-28s %-8s %s", "PROTOCOL", "PATH", "RESULT", "OBSERVED"))
+	var failed []string
+	for _, probe := range probes {
+		commandOutputs = append(commandOutputs, probe.Output)
+		result := "OK"
+		if !probe.Success {
+			result = "FAILED"
+			failed = append(failed, fmt.Sprintf("%s via %s", probe.Protocol, probe.Path))
+		}
+		details = append(details, fmt.Sprintf("ℹ️ %-12s %-28s %-8s %s", probe.Protocol, probe.Path, result, probe.Observed))
+	}
+
+	if len(failed) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d upgrade checks failed: %s", len(failed), len(probes), strings.Join(failed, ", ")),
+			Details: details,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Protocol Upgrade",
+				TechnicalError: strings.Join(failed, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"WebSocket needs the Upgrade and Connection headers forwarded by every proxy hop",
+					"Proxies with short idle timeouts will close long-lived WebSocket connections; check proxy-read-timeout settings",
+					"h2c (HTTP/2 without TLS) is often downgraded to HTTP/1.1 by L7 proxies unless appProtocol kubernetes.io/h2c is honoured",
+					"HTTP/2 over TLS relies on ALPN; a terminating proxy must advertise h2 to clients",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("WebSocket and HTTP/2 checks passed on %d probe(s)", len(probes)),
+		Details: details,
+		DetailedDiagnostics: &DetailedDiagnostics{
+			CommandOutputs: commandOutputs,
+		},
+	}
+}
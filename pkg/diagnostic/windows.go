@@ -0,0 +1,389 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// windowsProbeImageRepository is the Windows client image; Server Core ships PowerShell and curl.exe
+	windowsProbeImageRepository = "mcr.microsoft.com/windows/servercore"
+	// windowsBuildLabel carries the Windows build of a node, which the container image must match
+	windowsBuildLabel = "node.kubernetes.io/windows-build"
+	// crossOSServerImage serves HTTP on Linux and Windows nodes from one multi-OS manifest, unlike nginx
+	crossOSServerImage = "registry.k8s.io/e2e-test-images/agnhost:2.47"
+	// windowsPodReadyTimeout allows for the multi-gigabyte Windows image pulls on a node's first run
+	windowsPodReadyTimeout = 10 * time.Minute
+)
+
+// windowsImageTags maps Windows builds to the Server Core tag that runs on them with process isolation
+var windowsImageTags = map[string]string{
+	"10.0.17763": "ltsc2019",
+	"10.0.20348": "ltsc2022",
+	"10.0.26100": "ltsc2025",
+}
+
+// linuxNodeSelector keeps the Linux-only netshoot and nginx pods off Windows nodes on mixed clusters
+var linuxNodeSelector = map[string]string{corev1.LabelOSStable: "linux"}
+
+// nodeOS returns the operating system of a node from its kubernetes.io/os label, or from what the kubelet reports
+func nodeOS(node corev1.Node) string {
+	if os := node.Labels[corev1.LabelOSStable]; os != "" {
+		return os
+	}
+	if os := node.Status.NodeInfo.OperatingSystem; os != "" {
+		return os
+	}
+	return "linux"
+}
+
+// isWindowsNode reports whether a node runs Windows, where the Linux diagnostic pods cannot run
+func isWindowsNode(node corev1.Node) bool {
+	return nodeOS(node) == "windows"
+}
+
+// windowsProbeImage returns the Server Core image matching the node's Windows build
+func windowsProbeImage(node corev1.Node) string {
+	tag, ok := windowsImageTags[node.Labels[windowsBuildLabel]]
+	if !ok {
+		tag = "ltsc2022"
+	}
+	return fmt.Sprintf("%s:%s", windowsProbeImageRepository, tag)
+}
+
+// windowsPodSpec pins a pod to a Windows node; Windows nodes are commonly tainted so Linux pods stay off them
+func windowsPodSpec(nodeName string, container corev1.Container) corev1.PodSpec {
+	return corev1.PodSpec{
+		NodeName:     nodeName,
+		NodeSelector: map[string]string{corev1.LabelOSStable: "windows"},
+		Containers:   []corev1.Container{container},
+		Tolerations: []corev1.Toleration{
+			{
+				Key:      "os",
+				Operator: corev1.TolerationOpExists,
+			},
+			{
+				Key:      corev1.LabelOSStable,
+				Operator: corev1.TolerationOpExists,
+			},
+		},
+		RestartPolicy: corev1.RestartPolicyNever,
+	}
+}
+
+// createWindowsProbePod creates a Server Core client pod on a Windows node
+func (t *Tester) createWindowsProbePod(ctx context.Context, name string, node corev1.Node) error {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels:    map[string]string{"app": name},
+		},
+		Spec: windowsPodSpec(node.Name, corev1.Container{
+			Name:    "probe",
+			Image:   t.image(windowsProbeImage(node)),
+			Command: []string{"powershell.exe", "-NoProfile", "-Command", "Start-Sleep -Seconds 3600"},
+		}),
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// createCrossOSServerPod creates an HTTP server on port 80 labelled app=<app> on a Linux or Windows node
+func (t *Tester) createCrossOSServerPod(ctx context.Context, name, app string, node corev1.Node) error {
+	container := corev1.Container{
+		Name:  "server",
+		Image: t.image(crossOSServerImage),
+		Args:  []string{"netexec", "--http-port=80"},
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: 80,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+			},
+			PeriodSeconds: 2,
+		},
+	}
+	spec := corev1.PodSpec{
+		NodeName:      node.Name,
+		NodeSelector:  linuxNodeSelector,
+		Containers:    []corev1.Container{container},
+		RestartPolicy: corev1.RestartPolicyNever,
+	}
+	if isWindowsNode(node) {
+		spec = windowsPodSpec(node.Name, container)
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: t.namespace,
+			Labels:    map[string]string{"app": app},
+		},
+		Spec: spec,
+	}
+	_, err := t.clientset.CoreV1().Pods(t.namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+// windowsHTTPProbe requests target from a Windows pod with curl.exe and returns the status code; on no response it
+// runs Test-NetConnection to tell a failed TCP connection from an HTTP problem
+func (t *Tester) windowsHTTPProbe(ctx context.Context, podName, host string, port int) (string, string, []CommandOutput) {
+	target := fmt.Sprintf("%s:%d", httpTargetForIP(host), port)
+	output, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "probe",
+		[]string{"curl.exe", "-s", "-o", "NUL", "-w", "%{http_code}", "--connect-timeout", "5", "--max-time", "10", "http://" + target + "/"},
+		fmt.Sprintf("curl.exe http://%s/", target))
+	statusCode := strings.TrimSpace(output.Stdout)
+	if statusCode != "" && statusCode != "000" {
+		return statusCode, "", nil
+	}
+	tcp, _ := t.execInPodWithOutput(ctx, t.namespace, podName, "probe",
+		[]string{"powershell.exe", "-NoProfile", "-Command",
+			fmt.Sprintf("(Test-NetConnection -ComputerName %s -Port %d -WarningAction SilentlyContinue).TcpTestSucceeded", host, port)},
+		fmt.Sprintf("Test-NetConnection %s -Port %d", host, port))
+	if strings.TrimSpace(tcp.Stdout) == "True" {
+		return "000", "TCP connects, no HTTP response", []CommandOutput{output, tcp}
+	}
+	return "000", "TCP connection failed", []CommandOutput{output, tcp}
+}
+
+// crossOSEndpoint is a node taking part in the Windows connectivity matrix with its client and server pods
+type crossOSEndpoint struct {
+	Node     corev1.Node
+	OS       string
+	Client   string
+	Server   string
+	ServerIP string
+}
+
+// TestWindowsConnectivity runs a client and an HTTP server pod on a Linux worker and on up to two Windows nodes and
+// requests every server pod, and a ClusterIP service per operating system, from every client: Linux to Windows,
+// Windows to Linux and Windows to Windows, same node and across nodes. Windows clients probe with curl.exe and fall
+// back to Test-NetConnection to tell a blocked connection from an HTTP failure. Skipped without Windows nodes.
+func (t *Tester) TestWindowsConnectivity(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Linux and Windows nodes
+	nodeList, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	var linux, windows []corev1.Node
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		switch {
+		case isWindowsNode(node):
+			windows = append(windows, node)
+		case !isControlPlaneNode(node):
+			linux = append(linux, node)
+		}
+	}
+	if len(windows) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Windows connectivity test skipped - no schedulable Windows nodes",
+			Details: []string{fmt.Sprintf("ℹ️ %d nodes, none with %s=windows", len(nodeList.Items), corev1.LabelOSStable)},
+		}
+	}
+	if len(linux) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: "Windows connectivity test skipped - no schedulable Linux worker node to pair with",
+			Details: details,
+		}
+	}
+	var endpoints []crossOSEndpoint
+	endpoints = append(endpoints, crossOSEndpoint{Node: linux[0], OS: "linux"})
+	for _, node := range windows[:min(len(windows), 2)] {
+		endpoints = append(endpoints, crossOSEndpoint{Node: node, OS: "windows"})
+		details = append(details, fmt.Sprintf("✓ Windows node %s (build %s, %s)", node.Name, valueOrUnknown(node.Labels[windowsBuildLabel]), windowsProbeImage(node)))
+	}
+	details = append(details, fmt.Sprintf("✓ Linux node %s", linux[0].Name))
+
+	// Step 2: A client and a server pod per node, and a service per operating system
+	serviceFor := map[string]string{"linux": "web-windows-matrix-linux", "windows": "web-windows-matrix-windows"}
+	var pods []string
+	defer func() {
+		ctx, cancel := cleanupContext(ctx)
+		defer cancel()
+		for _, pod := range pods {
+			t.clientset.CoreV1().Pods(t.namespace).Delete(ctx, pod, metav1.DeleteOptions{})
+		}
+		for _, service := range serviceFor {
+			t.clientset.CoreV1().Services(t.namespace).Delete(ctx, service, metav1.DeleteOptions{})
+		}
+	}()
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		endpoint.Client = fmt.Sprintf("netshoot-windows-matrix-%d", i)
+		endpoint.Server = fmt.Sprintf("web-windows-matrix-%d", i)
+		pods = append(pods, endpoint.Client, endpoint.Server)
+		if err := t.createCrossOSServerPod(ctx, endpoint.Server, serviceFor[endpoint.OS], endpoint.Node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create server pod on %s: %v", endpoint.Node.Name, err),
+				Details: details,
+			}
+		}
+		if endpoint.OS == "windows" {
+			err = t.createWindowsProbePod(ctx, endpoint.Client, endpoint.Node)
+		} else {
+			_, err = t.createNetshootPod(ctx, endpoint.Client, endpoint.Node.Name)
+		}
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create client pod on %s: %v", endpoint.Node.Name, err),
+				Details: details,
+			}
+		}
+	}
+	for _, service := range serviceFor {
+		if _, err := t.createNginxService(ctx, service, service); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create service %s: %v", service, err),
+				Details: details,
+			}
+		}
+	}
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		for _, pod := range []string{endpoint.Server, endpoint.Client} {
+			if err := t.waitForPodReady(ctx, pod, windowsPodReadyTimeout); err != nil {
+				return TestResult{
+					Success: false,
+					Message: fmt.Sprintf("Pod %s on %s (%s) did not become ready: %v", pod, endpoint.Node.Name, endpoint.OS, err),
+					Details: details,
+					DetailedDiagnostics: &DetailedDiagnostics{
+						FailureStage:   "Pod Readiness",
+						TechnicalError: err.Error(),
+						TroubleshootingHints: []string{
+							fmt.Sprintf("Windows images must match the node's build (%s label); check the pod events for an OS version mismatch", windowsBuildLabel),
+							"A first pull of a Windows Server Core image takes several minutes",
+							fmt.Sprintf("Check the pod events: kubectl describe pod -n %s %s", t.namespace, pod),
+						},
+					},
+				}
+			}
+		}
+		server, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, endpoint.Server, metav1.GetOptions{})
+		if err != nil || server.Status.PodIP == "" {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Server pod on %s has no IP", endpoint.Node.Name),
+				Details: details,
+			}
+		}
+		endpoint.ServerIP = server.Status.PodIP
+	}
+	details = append(details, fmt.Sprintf("✓ Client and server pods ready on %d nodes", len(endpoints)))
+
+	// Step 3: Every client requests every server pod and both services
+	type column struct {
+		Name, OS, Host string
+	}
+	var columns []column
+	for _, endpoint := range endpoints {
+		columns = append(columns, column{Name: "pod@" + endpoint.Node.Name, OS: endpoint.OS, Host: endpoint.ServerIP})
+	}
+	for _, os := range []string{"linux", "windows"} {
+		columns = append(columns, column{Name: "svc/" + os, OS: os, Host: serviceFor[os]})
+	}
+	var commandOutputs []CommandOutput
+	var failures []string
+	cells := make([][]string, len(endpoints))
+	metrics := map[string]float64{}
+	for i, client := range endpoints {
+		for _, target := range columns {
+			var statusCode, reason string
+			if client.OS == "windows" {
+				var outputs []CommandOutput
+				statusCode, reason, outputs = t.windowsHTTPProbe(ctx, client.Client, target.Host, 80)
+				commandOutputs = append(commandOutputs, outputs...)
+			} else {
+				statusCode, _, err = t.testHTTPConnectivityWithStatusCode(ctx, client.Client, httpTargetForIP(target.Host), HTTPOptions{})
+				if err != nil {
+					reason = err.Error()
+				}
+			}
+			ok, message := false, reason
+			if reason == "" {
+				ok, message = evaluateHTTPStatusCode(statusCode)
+			}
+			key := fmt.Sprintf("%s_to_%s", client.OS, target.OS)
+			metrics["checks."+key]++
+			if ok {
+				cells[i] = append(cells[i], "ok")
+				continue
+			}
+			metrics["failures."+key]++
+			cells[i] = append(cells[i], "fail")
+			failures = append(failures, fmt.Sprintf("%s (%s) -> %s: %s", client.Node.Name, client.OS, target.Name, message))
+		}
+	}
+
+	// Step 4: The matrix, rows are client nodes
+	width := 12
+	for _, target := range columns {
+		width = max(width, len(target.Name)+2)
+	}
+	header := fmt.Sprintf("  %-*s", width, "FROM \\ TO")
+	for _, target := range columns {
+		header += fmt.Sprintf(" %-*s", width, target.Name)
+	}
+	details = append(details, "  HTTP from client pods (rows) to server pods and services (columns):", header)
+	for i, client := range endpoints {
+		row := fmt.Sprintf("  %-*s", width, fmt.Sprintf("%s (%s)", client.Node.Name, client.OS))
+		for _, cell := range cells[i] {
+			row += fmt.Sprintf(" %-*s", width, cell)
+		}
+		details = append(details, row)
+	}
+	for _, failure := range failures {
+		details = append(details, "✗ "+failure)
+	}
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d of %d Linux/Windows connectivity checks failed", len(failures), len(endpoints)*len(columns)),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Windows Connectivity",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Failures only from or to Windows pods point at the Windows CNI (Calico for Windows, flannel host-gw/VXLAN, Antrea) or the HNS networks on the node: Get-HnsNetwork, Get-HnsEndpoint",
+					"Service failures from Windows clients only point at kube-proxy on Windows (winkernel mode) - check its logs on the node",
+					"The VXLAN overlay on Windows needs the same VNI and UDP port as on Linux (flannel uses VNI 4096 and port 4789 for Windows)",
+					"Windows Defender Firewall on the node can drop pod traffic; check the inbound rules for the pod CIDR",
+				},
+			},
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Linux and Windows pods reach each other and both services across %d nodes", len(endpoints)),
+		Details: details,
+		Metrics: metrics,
+	}
+}
@@ -0,0 +1,260 @@
+package diagnostic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// zoneLatencyNodesPerZone is how many worker nodes per zone get a probe pod; two allow a within-zone measurement
+const zoneLatencyNodesPerZone = 2
+
+// zoneProbePod is a probe pod on one node of a zone
+type zoneProbePod struct {
+	Zone string
+	Node string
+	Pod  string
+	IP   string
+}
+
+// zoneLatencyCell is one measurement of the zone matrix
+type zoneLatencyCell struct {
+	From, To string // zones
+	Pods     string // "<from node> -> <to node>"
+	Latency  float64
+	Err      string
+}
+
+// TestZoneLatencyMatrix groups worker nodes by topology.kubernetes.io/zone, places a probe pod on up to two nodes
+// per zone and measures pod-to-pod latency within each zone and between every pair of zones, reporting a zone×zone
+// matrix. Cross-zone latency that is not higher than within-zone latency is flagged, as it suggests zone labels
+// that do not match the real placement.
+func (t *Tester) TestZoneLatencyMatrix(ctx context.Context) TestResult {
+	var details []string
+
+	// Step 1: Worker nodes grouped by zone
+	workers, err := t.getWorkerNodes(ctx)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get worker nodes: %v", err),
+			Details: details,
+		}
+	}
+	nodeList, err := t.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to list nodes: %v", err),
+			Details: details,
+		}
+	}
+	isWorker := map[string]bool{}
+	for _, worker := range workers {
+		isWorker[worker] = true
+	}
+	zoneNodes := map[string][]string{}
+	var unlabeled []string
+	for _, node := range nodeList.Items {
+		if !isWorker[node.Name] || node.Spec.Unschedulable {
+			continue
+		}
+		zone := node.Labels[corev1.LabelTopologyZone]
+		if zone == "" {
+			unlabeled = append(unlabeled, node.Name)
+			continue
+		}
+		if len(zoneNodes[zone]) < zoneLatencyNodesPerZone {
+			zoneNodes[zone] = append(zoneNodes[zone], node.Name)
+		}
+	}
+	if len(unlabeled) > 0 {
+		details = append(details, fmt.Sprintf("ℹ️ %d worker nodes without a %s label are left out: %s", len(unlabeled), corev1.LabelTopologyZone, strings.Join(unlabeled, ", ")))
+	}
+	if len(zoneNodes) == 0 {
+		return TestResult{
+			Success: true,
+			Skipped: true,
+			Message: fmt.Sprintf("Zone latency matrix skipped - no schedulable worker node has a %s label", corev1.LabelTopologyZone),
+			Details: details,
+		}
+	}
+	var zones []string
+	for zone := range zoneNodes {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		details = append(details, fmt.Sprintf("✓ Zone %s: %s", zone, strings.Join(zoneNodes[zone], ", ")))
+	}
+
+	// Step 2: A probe pod on each selected node
+	var probes []zoneProbePod
+	for zoneIndex, zone := range zones {
+		for nodeIndex, node := range zoneNodes[zone] {
+			probes = append(probes, zoneProbePod{Zone: zone, Node: node, Pod: fmt.Sprintf("netshoot-zone-%d-%d", zoneIndex, nodeIndex)})
+		}
+	}
+	defer func() {
+		for _, probe := range probes {
+			t.cleanupPod(ctx, probe.Pod)
+		}
+	}()
+	for _, probe := range probes {
+		if _, err := t.createNetshootPod(ctx, probe.Pod, probe.Node); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create probe pod on %s: %v", probe.Node, err),
+				Details: details,
+			}
+		}
+	}
+	for i := range probes {
+		if err := t.waitForPodReady(ctx, probes[i].Pod, 120*time.Second); err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Probe pod on %s not ready: %v", probes[i].Node, err),
+				Details: details,
+			}
+		}
+		pod, err := t.clientset.CoreV1().Pods(t.namespace).Get(ctx, probes[i].Pod, metav1.GetOptions{})
+		if err != nil || pod.Status.PodIP == "" {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Probe pod on %s has no IP", probes[i].Node),
+				Details: details,
+			}
+		}
+		probes[i].IP = pod.Status.PodIP
+	}
+
+	// Step 3: One measurement per zone pair - within a zone between its two nodes, across zones between the first
+	// node of each
+	first := map[string]zoneProbePod{}
+	second := map[string]zoneProbePod{}
+	for _, probe := range probes {
+		if _, ok := first[probe.Zone]; !ok {
+			first[probe.Zone] = probe
+		} else {
+			second[probe.Zone] = probe
+		}
+	}
+	metrics := map[string]float64{}
+	cells := map[string]zoneLatencyCell{}
+	var commandOutputs []CommandOutput
+	var failures []string
+	for _, from := range zones {
+		for _, to := range zones {
+			source, target := first[from], first[to]
+			if from == to {
+				peer, ok := second[to]
+				if !ok {
+					continue
+				}
+				target = peer
+			}
+			cell := zoneLatencyCell{From: from, To: to, Pods: fmt.Sprintf("%s -> %s", source.Node, target.Node)}
+			output, err := t.execInPodWithOutput(ctx, t.namespace, source.Pod, "netshoot",
+				[]string{"ping", "-c", "10", "-i", "0.2", "-W", "1", target.IP}, fmt.Sprintf("Ping from zone %s to zone %s (%s)", from, to, cell.Pods))
+			cell.Latency = t.extractPingLatency(output.Stdout)
+			if err != nil || cell.Latency == 0 {
+				cell.Err = "unreachable"
+				failures = append(failures, fmt.Sprintf("%s -> %s (%s)", from, to, cell.Pods))
+				commandOutputs = append(commandOutputs, output)
+			} else {
+				metrics[fmt.Sprintf("latency_ms.%s.%s", from, to)] = cell.Latency
+			}
+			cells[from+"/"+to] = cell
+		}
+	}
+
+	// Step 4: The zone×zone matrix, rows are source zones
+	width := 12
+	for _, zone := range zones {
+		width = max(width, len(zone)+2)
+	}
+	header := fmt.Sprintf("  %-*s", width, "FROM \\ TO")
+	for _, zone := range zones {
+		header += fmt.Sprintf(" %-*s", width, zone)
+	}
+	details = append(details, "  Pod-to-pod latency per zone pair (avg ms):")
+	details = append(details, header)
+	for _, from := range zones {
+		row := fmt.Sprintf("  %-*s", width, from)
+		for _, to := range zones {
+			value := "-"
+			if cell, ok := cells[from+"/"+to]; ok {
+				value = cell.Err
+				if value == "" {
+					value = fmt.Sprintf("%.3f", cell.Latency)
+				}
+			}
+			row += fmt.Sprintf(" %-*s", width, value)
+		}
+		details = append(details, row)
+	}
+	details = append(details, "  '-' within a zone means it has only one schedulable worker node")
+
+	// Step 5: Within-zone against cross-zone latency
+	var withinSum, crossSum float64
+	var withinCount, crossCount int
+	for _, cell := range cells {
+		if cell.Err != "" {
+			continue
+		}
+		if cell.From == cell.To {
+			withinSum += cell.Latency
+			withinCount++
+		} else {
+			crossSum += cell.Latency
+			crossCount++
+		}
+	}
+	if withinCount > 0 {
+		metrics["within_zone_avg_ms"] = withinSum / float64(withinCount)
+	}
+	if crossCount > 0 {
+		metrics["cross_zone_avg_ms"] = crossSum / float64(crossCount)
+	}
+	if withinCount > 0 && crossCount > 0 {
+		within, cross := withinSum/float64(withinCount), crossSum/float64(crossCount)
+		details = append(details, fmt.Sprintf("ℹ️ Average within-zone %.3fms, cross-zone %.3fms", within, cross))
+		if cross <= within {
+			details = append(details, "⚠️ Cross-zone latency is not higher than within-zone latency - the zone labels may not reflect where the nodes run")
+		}
+	}
+
+	if len(failures) > 0 {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Pods could not reach each other for %d zone pairs: %s", len(failures), strings.Join(failures, ", ")),
+			Details: details,
+			Metrics: metrics,
+			DetailedDiagnostics: &DetailedDiagnostics{
+				FailureStage:   "Zone Latency Matrix",
+				TechnicalError: strings.Join(failures, "; "),
+				CommandOutputs: commandOutputs,
+				TroubleshootingHints: []string{
+					"Failures limited to some zone pairs point at routing or firewalls between those zones (security groups, NACLs, inter-zone peering)",
+					"Run the cross-node and overlay-health tests to see whether node-to-node traffic between the zones works",
+				},
+			},
+		}
+	}
+
+	message := fmt.Sprintf("Measured pod-to-pod latency for %d zones", len(zones))
+	if len(zones) == 1 {
+		message = fmt.Sprintf("Only one zone (%s) - measured within-zone latency only", zones[0])
+	}
+	return TestResult{
+		Success: true,
+		Message: message,
+		Details: details,
+		Metrics: metrics,
+	}
+}